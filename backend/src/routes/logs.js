@@ -2,6 +2,32 @@ const express = require('express');
 const router = express.Router();
 const { connectToContract } = require('../fabric/network');
 const { v4: uuidv4 } = require('uuid');
+const zlib = require('zlib');
+
+/**
+ * Helper function to decode compressed metadata
+ * Compressed metadata is stored as {"$encoding": "gzip" | "zstd", "$data": "<base64>"}
+ * zstd needs a Node.js version with zlib.zstdDecompressSync; otherwise the envelope is returned as is
+ */
+const decodeMetadata = (metadata) => {
+  if (!metadata || typeof metadata !== 'object' || typeof metadata.$encoding !== 'string') {
+    return metadata;
+  }
+
+  const data = Buffer.from(metadata.$data || '', 'base64');
+  try {
+    if (metadata.$encoding === 'gzip') {
+      return JSON.parse(zlib.gunzipSync(data).toString());
+    }
+    if (metadata.$encoding === 'zstd' && typeof zlib.zstdDecompressSync === 'function') {
+      return JSON.parse(zlib.zstdDecompressSync(data).toString());
+    }
+  } catch (e) {
+    console.error(`Failed to decode ${metadata.$encoding} metadata: ${e.message}`);
+  }
+
+  return metadata;
+};
 
 /**
  * Helper function to process log metadata
@@ -16,7 +42,7 @@ const processLogMetadata = (log) => {
   } else if (typeof processedLog.metadata === 'string') {
     try {
       // Try to parse the metadata if it's a JSON string
-      processedLog.metadata = JSON.parse(processedLog.metadata);
+      processedLog.metadata = decodeMetadata(JSON.parse(processedLog.metadata));
     } catch (e) {
       // If parsing fails, set to empty object
       console.error(`Failed to parse metadata for log ${processedLog.id}: ${e.message}`);
@@ -62,7 +88,7 @@ router.get('/', async (req, res) => {
           processedLog.metadata = {};
         } else if (typeof processedLog.metadata === 'string') {
           try {
-            processedLog.metadata = JSON.parse(processedLog.metadata);
+            processedLog.metadata = decodeMetadata(JSON.parse(processedLog.metadata));
           } catch (e) {
             // If parsing fails, set to empty object
             processedLog.metadata = {};
@@ -236,7 +262,7 @@ router.get('/', async (req, res) => {
                 processedLog.metadata = {};
               } else if (typeof processedLog.metadata === 'string') {
                 try {
-                  processedLog.metadata = JSON.parse(processedLog.metadata);
+                  processedLog.metadata = decodeMetadata(JSON.parse(processedLog.metadata));
                 } catch (e) {
                   processedLog.metadata = {};
                 }
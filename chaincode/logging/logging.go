@@ -1,13 +1,86 @@
 package main
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"regexp"
 	"time"
 
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
+// logObjectType is the composite-key namespace under which all log records are stored
+const logObjectType = "LOG"
+
+// configObjectType is the composite-key namespace of the contract's settings, kept apart from
+// the logs so log queries never see them
+const configObjectType = "CONFIG"
+
+// prunedObjectType is the composite-key namespace of the tombstones PruneLogs leaves, so a pruned
+// log can be told apart from one that was never recorded
+const prunedObjectType = "PRUNED"
+
+// retentionPolicyName is the setting holding the RetentionPolicy
+const retentionPolicyName = "retention"
+
+// Maximum field lengths accepted for a log
+const (
+	maxIDLength          = 128
+	maxUserIDLength      = 128
+	maxActionLength      = 64
+	maxResourceLength    = 512
+	maxDescriptionLength = 4096
+	maxMetadataLength    = 16384
+)
+
+// actionPattern restricts actions to upper-case identifiers such as LOGIN or API_CALL
+var actionPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// Compressed metadata is stored as {"$encoding": "<encoding>", "$data": "<base64 payload>"}
+const (
+	metadataEncodingField = "$encoding"
+	metadataDataField     = "$data"
+)
+
+// metadataEncodings are the compression schemes accepted for metadata
+var metadataEncodings = map[string]bool{"gzip": true, "zstd": true}
+
+// Chaincode events emitted when logs are written or pruned. Fabric keeps one event per
+// transaction, so a batch emits a single LogsCreated event whose payload is the array of stored
+// logs, and PruneLogs a single LogsPruned event whose payload is the array of tombstones it left.
+const (
+	logCreatedEvent  = "LogCreated"
+	logsCreatedEvent = "LogsCreated"
+	logsPrunedEvent  = "LogsPruned"
+)
+
+// privateLogTransientKey is the transient map entry carrying the sensitive fields of a private log
+const privateLogTransientKey = "privateLog"
+
+// Error codes prefixed to chaincode errors as "[CODE] message", so clients can tell failures apart
+// without matching on message text
+const (
+	errCodeNotFound      = "LOG_NOT_FOUND"
+	errCodeAlreadyExists = "LOG_ALREADY_EXISTS"
+	errCodeInvalid       = "INVALID_LOG"
+	errCodeUnauthorized  = "ACCESS_DENIED"
+	errCodeNoPolicy      = "POLICY_NOT_FOUND"
+)
+
+// pingKey is the world state key Ping reads. Nothing is stored under it.
+const pingKey = "ping"
+
+// restoreAttribute is the certificate attribute, set to "true", that allows an identity to call
+// RestoreLogsBatch. Restores keep the original timestamps, so the right must be granted explicitly.
+const restoreAttribute = "logging.restore"
+
+// retentionAttribute is the certificate attribute, set to "true", that allows an identity to call
+// PruneLogs and SetRetentionPolicy. Pruned logs leave the world state for good, so the right must
+// be granted explicitly.
+const retentionAttribute = "logging.retention"
+
 // LoggingContract provides functions for logging user events
 type LoggingContract struct {
 	contractapi.Contract
@@ -15,19 +88,69 @@ type LoggingContract struct {
 
 // LogEvent represents a user event log in the blockchain
 type LogEvent struct {
-	ID          string    `json:"id"`
-	UserID      string    `json:"userId"`
-	Action      string    `json:"action"`
-	Resource    string    `json:"resource"`
-	Timestamp   string    `json:"timestamp"`
-	Description string    `json:"description"`
-	Metadata    string    `json:"metadata,omitempty"`
+	DocType     string `json:"docType"`
+	ID          string `json:"id"`
+	UserID      string `json:"userId"`
+	Action      string `json:"action"`
+	Resource    string `json:"resource"`
+	Timestamp   string `json:"timestamp"`
+	Description string `json:"description"`
+	Metadata    string `json:"metadata,omitempty"`
+	Collection  string `json:"collection,omitempty"`
+}
+
+// PrivateLogDetails holds the sensitive fields of a log kept in a private data collection
+type PrivateLogDetails struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	Metadata    string `json:"metadata,omitempty"`
+}
+
+// LogFilter holds the structured criteria accepted by QueryLogs
+type LogFilter struct {
+	UserID    string `json:"userId,omitempty"`
+	Action    string `json:"action,omitempty"`
+	Resource  string `json:"resource,omitempty"`
+	StartTime string `json:"startTime,omitempty"`
+	EndTime   string `json:"endTime,omitempty"`
+}
+
+// RetentionPolicy is how long logs are kept before retention services prune them
+type RetentionPolicy struct {
+	// MaxAgeDays is the number of days logs are kept
+	MaxAgeDays int `json:"maxAgeDays"`
+	// Archive asks for logs to be archived before they are pruned
+	Archive bool `json:"archive"`
+	// UpdatedAt and UpdatedBy, the MSP ID of the identity, are set by the chaincode
+	UpdatedAt string `json:"updatedAt"`
+	UpdatedBy string `json:"updatedBy"`
+}
+
+// PrunedLog is the tombstone of a log deleted by PruneLogs
+type PrunedLog struct {
+	DocType string `json:"docType"`
+	ID      string `json:"id"`
+	// Timestamp is the time the log was recorded
+	Timestamp string `json:"timestamp"`
+	// PrunedAt is the timestamp of the pruning transaction, and PrunedBy the MSP ID of its submitter
+	PrunedAt string `json:"prunedAt"`
+	PrunedBy string `json:"prunedBy"`
+	// Before is the cutoff the log was pruned under
+	Before string `json:"before"`
+}
+
+// PaginatedQueryResult holds one page of logs and the bookmark for the next page
+type PaginatedQueryResult struct {
+	Records             []*LogEvent `json:"records"`
+	FetchedRecordsCount int32       `json:"fetchedRecordsCount"`
+	Bookmark            string      `json:"bookmark"`
 }
 
 // InitLedger adds a base set of logs to the ledger
 func (s *LoggingContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
 	logs := []LogEvent{
 		{
+			DocType:     logObjectType,
 			ID:          "LOG0",
 			UserID:      "user1",
 			Action:      "VISIT",
@@ -43,13 +166,23 @@ func (s *LoggingContract) InitLedger(ctx contractapi.TransactionContextInterface
 			return err
 		}
 
-		err = ctx.GetStub().PutState(log.ID, logJSON)
+		key, err := logKey(ctx, log.ID)
+		if err != nil {
+			return err
+		}
+
+		err = ctx.GetStub().PutState(key, logJSON)
 		if err != nil {
 			return fmt.Errorf("failed to put to world state: %v", err)
 		}
 	}
 
-	return nil
+	eventJSON, err := json.Marshal(logs)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().SetEvent(logsCreatedEvent, eventJSON)
 }
 
 // CreateLog issues a new log to the world state with given details
@@ -60,10 +193,11 @@ func (s *LoggingContract) CreateLog(ctx contractapi.TransactionContextInterface,
 		return err
 	}
 	if exists {
-		return fmt.Errorf("the log %s already exists", id)
+		return codedError(errCodeAlreadyExists, "the log %s already exists", id)
 	}
 
 	log := LogEvent{
+		DocType:     logObjectType,
 		ID:          id,
 		UserID:      userId,
 		Action:      action,
@@ -73,22 +207,508 @@ func (s *LoggingContract) CreateLog(ctx contractapi.TransactionContextInterface,
 		Metadata:    metadata,
 	}
 
+	err = validateLog(log)
+	if err != nil {
+		return err
+	}
+
+	logJSON, err := json.Marshal(log)
+	if err != nil {
+		return err
+	}
+
+	key, err := logKey(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, logJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put to world state: %v", err)
+	}
+
+	return ctx.GetStub().SetEvent(logCreatedEvent, logJSON)
+}
+
+// CreateLogsBatch issues several new logs to the world state in a single transaction.
+// logsJSON is a JSON array of logs; the whole batch is rejected if any log already exists.
+func (s *LoggingContract) CreateLogsBatch(ctx contractapi.TransactionContextInterface, logsJSON string) error {
+	// Every endorser must write the same logs, so they take the transaction's time
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to read transaction timestamp: %v", err)
+	}
+	timestamp := txTimestamp.AsTime().UTC().Format(time.RFC3339)
+
+	return s.putLogsBatch(ctx, logsJSON, func(log *LogEvent) error {
+		log.Timestamp = timestamp
+		return nil
+	})
+}
+
+// RestoreLogsBatch writes logs recovered from a backup, keeping their original IDs and timestamps.
+// It is otherwise CreateLogsBatch, and is only allowed for identities holding the logging.restore attribute.
+func (s *LoggingContract) RestoreLogsBatch(ctx contractapi.TransactionContextInterface, logsJSON string) error {
+	if err := ctx.GetClientIdentity().AssertAttributeValue(restoreAttribute, "true"); err != nil {
+		return codedError(errCodeUnauthorized, "restoring logs requires the %s attribute: %v", restoreAttribute, err)
+	}
+
+	return s.putLogsBatch(ctx, logsJSON, func(log *LogEvent) error {
+		if _, err := time.Parse(time.RFC3339, log.Timestamp); err != nil {
+			return codedError(errCodeInvalid, "the timestamp of log %s must be RFC 3339: %v", log.ID, err)
+		}
+		return nil
+	})
+}
+
+// MigrateLegacyLogs moves up to limit logs stored under their bare ID, as versions of the contract
+// before the LOG namespace stored them, to their namespaced key, where reads and queries find
+// them, and returns how many it moved. Call it until it returns 0. A legacy log whose ID is
+// already taken in the namespace is left in place. Migrated logs keep their timestamps, so only
+// identities holding the logging.restore attribute may migrate.
+func (s *LoggingContract) MigrateLegacyLogs(ctx contractapi.TransactionContextInterface, limit int) (int, error) {
+	if err := ctx.GetClientIdentity().AssertAttributeValue(restoreAttribute, "true"); err != nil {
+		return 0, codedError(errCodeUnauthorized, "migrating logs requires the %s attribute: %v", restoreAttribute, err)
+	}
+	if limit < 1 {
+		return 0, codedError(errCodeInvalid, "the migration limit must be at least 1")
+	}
+
+	// Range queries never return composite keys, so only bare keys are read
+	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return 0, err
+	}
+	defer resultsIterator.Close()
+
+	migrated := 0
+	for migrated < limit && resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return migrated, err
+		}
+
+		var log LogEvent
+		if err := json.Unmarshal(queryResponse.Value, &log); err != nil || log.ID != queryResponse.Key || log.DocType != "" {
+			continue
+		}
+		exists, err := s.LogExists(ctx, log.ID)
+		if err != nil {
+			return migrated, err
+		}
+		if exists {
+			continue
+		}
+
+		log.DocType = logObjectType
+		logJSON, err := json.Marshal(log)
+		if err != nil {
+			return migrated, err
+		}
+		key, err := logKey(ctx, log.ID)
+		if err != nil {
+			return migrated, err
+		}
+		if err := ctx.GetStub().PutState(key, logJSON); err != nil {
+			return migrated, fmt.Errorf("failed to put to world state: %v", err)
+		}
+		if err := ctx.GetStub().DelState(queryResponse.Key); err != nil {
+			return migrated, fmt.Errorf("failed to delete from world state: %v", err)
+		}
+		migrated++
+	}
+
+	return migrated, nil
+}
+
+// putLogsBatch stores the JSON array of new logs, stamping each with stamp, and emits a single
+// LogsCreated event
+func (s *LoggingContract) putLogsBatch(ctx contractapi.TransactionContextInterface, logsJSON string, stamp func(log *LogEvent) error) error {
+	var logs []LogEvent
+	err := json.Unmarshal([]byte(logsJSON), &logs)
+	if err != nil {
+		return fmt.Errorf("failed to parse logs batch: %v", err)
+	}
+	if len(logs) == 0 {
+		return codedError(errCodeInvalid, "the logs batch is empty")
+	}
+
+	seen := make(map[string]bool, len(logs))
+	for i, log := range logs {
+		err := validateLog(log)
+		if err != nil {
+			return err
+		}
+		// A public log naming a collection would claim private details that were never written,
+		// and PruneLogs would then fail deleting them from a collection the caller chose
+		if log.Collection != "" {
+			return codedError(errCodeInvalid, "the log %s names collection %s; only CreatePrivateLog writes private logs", log.ID, log.Collection)
+		}
+		if seen[log.ID] {
+			return codedError(errCodeAlreadyExists, "the log %s appears more than once in the batch", log.ID)
+		}
+		seen[log.ID] = true
+
+		exists, err := s.LogExists(ctx, log.ID)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return codedError(errCodeAlreadyExists, "the log %s already exists", log.ID)
+		}
+
+		log.DocType = logObjectType
+		if err := stamp(&log); err != nil {
+			return err
+		}
+		logs[i] = log
+
+		logJSON, err := json.Marshal(log)
+		if err != nil {
+			return err
+		}
+
+		key, err := logKey(ctx, log.ID)
+		if err != nil {
+			return err
+		}
+
+		err = ctx.GetStub().PutState(key, logJSON)
+		if err != nil {
+			return fmt.Errorf("failed to put to world state: %v", err)
+		}
+	}
+
+	eventJSON, err := json.Marshal(logs)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().SetEvent(logsCreatedEvent, eventJSON)
+}
+
+// PruneLogs deletes logs from the world state, with the private details of private logs, as
+// retention policies require. idsJSON is a JSON array of log IDs; logs already deleted are skipped,
+// and nothing is deleted if any of the logs was recorded at or after before, an RFC 3339 timestamp,
+// so a wrong ID cannot remove a recent log. Only identities holding the logging.retention attribute
+// may prune. The tombstones of the deleted logs are emitted in a LogsPruned event.
+func (s *LoggingContract) PruneLogs(ctx contractapi.TransactionContextInterface, before string, idsJSON string) error {
+	if err := ctx.GetClientIdentity().AssertAttributeValue(retentionAttribute, "true"); err != nil {
+		return codedError(errCodeUnauthorized, "pruning logs requires the %s attribute: %v", retentionAttribute, err)
+	}
+
+	cutoff, err := time.Parse(time.RFC3339, before)
+	if err != nil {
+		return codedError(errCodeInvalid, "the cutoff must be RFC 3339: %v", err)
+	}
+
+	var ids []string
+	err = json.Unmarshal([]byte(idsJSON), &ids)
+	if err != nil {
+		return fmt.Errorf("failed to parse log IDs: %v", err)
+	}
+	if len(ids) == 0 {
+		return codedError(errCodeInvalid, "no logs to prune")
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to read client MSP ID: %v", err)
+	}
+	// Every endorser must write the same tombstones, so they take the transaction's time
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to read transaction timestamp: %v", err)
+	}
+	prunedAt := txTimestamp.AsTime().UTC().Format(time.RFC3339)
+
+	var tombstones []PrunedLog
+	for _, id := range ids {
+		key, err := logKey(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		logJSON, err := ctx.GetStub().GetState(key)
+		if err != nil {
+			return fmt.Errorf("failed to read from world state: %v", err)
+		}
+		if logJSON == nil {
+			continue
+		}
+
+		var log LogEvent
+		err = json.Unmarshal(logJSON, &log)
+		if err != nil {
+			return err
+		}
+		timestamp, err := time.Parse(time.RFC3339, log.Timestamp)
+		if err != nil || !timestamp.Before(cutoff) {
+			return codedError(errCodeInvalid, "the log %s was recorded at %s, not before %s", id, log.Timestamp, before)
+		}
+
+		if log.Collection != "" {
+			err = ctx.GetStub().DelPrivateData(log.Collection, key)
+			if err != nil {
+				return fmt.Errorf("failed to delete private log details from collection %s: %v", log.Collection, err)
+			}
+		}
+		err = ctx.GetStub().DelState(key)
+		if err != nil {
+			return fmt.Errorf("failed to delete from world state: %v", err)
+		}
+
+		tombstone := PrunedLog{
+			DocType:   prunedObjectType,
+			ID:        id,
+			Timestamp: log.Timestamp,
+			PrunedAt:  prunedAt,
+			PrunedBy:  mspID,
+			Before:    before,
+		}
+		tombstoneJSON, err := json.Marshal(tombstone)
+		if err != nil {
+			return err
+		}
+		tombstoneKey, err := ctx.GetStub().CreateCompositeKey(prunedObjectType, []string{id})
+		if err != nil {
+			return fmt.Errorf("failed to create key for the tombstone of log %s: %v", id, err)
+		}
+		err = ctx.GetStub().PutState(tombstoneKey, tombstoneJSON)
+		if err != nil {
+			return fmt.Errorf("failed to put to world state: %v", err)
+		}
+		tombstones = append(tombstones, tombstone)
+	}
+	if len(tombstones) == 0 {
+		return nil
+	}
+
+	// Mirrors of the ledger fed by its events learn from this one to delete the logs too
+	eventJSON, err := json.Marshal(tombstones)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().SetEvent(logsPrunedEvent, eventJSON)
+}
+
+// GetPrunedLog returns the tombstone PruneLogs left for the log with given id
+func (s *LoggingContract) GetPrunedLog(ctx contractapi.TransactionContextInterface, id string) (*PrunedLog, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(prunedObjectType, []string{id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key for the tombstone of log %s: %v", id, err)
+	}
+
+	tombstoneJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if tombstoneJSON == nil {
+		return nil, codedError(errCodeNotFound, "the log %s has not been pruned", id)
+	}
+
+	var tombstone PrunedLog
+	err = json.Unmarshal(tombstoneJSON, &tombstone)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tombstone, nil
+}
+
+// SetRetentionPolicy replaces the retention policy, given as JSON. The chaincode only records
+// the policy; retention services read it and prune accordingly. Only identities holding the
+// logging.retention attribute may set it.
+func (s *LoggingContract) SetRetentionPolicy(ctx contractapi.TransactionContextInterface, policyJSON string) error {
+	if err := ctx.GetClientIdentity().AssertAttributeValue(retentionAttribute, "true"); err != nil {
+		return codedError(errCodeUnauthorized, "setting the retention policy requires the %s attribute: %v", retentionAttribute, err)
+	}
+
+	var policy RetentionPolicy
+	err := json.Unmarshal([]byte(policyJSON), &policy)
+	if err != nil {
+		return codedError(errCodeInvalid, "failed to parse retention policy: %v", err)
+	}
+	if policy.MaxAgeDays < 1 {
+		return codedError(errCodeInvalid, "the retention policy must keep logs for at least one day")
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to read client MSP ID: %v", err)
+	}
+	// Every endorser must write the same policy, so it takes the transaction's time
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to read transaction timestamp: %v", err)
+	}
+	policy.UpdatedAt = txTimestamp.AsTime().UTC().Format(time.RFC3339)
+	policy.UpdatedBy = mspID
+
+	storedJSON, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(configObjectType, []string{retentionPolicyName})
+	if err != nil {
+		return fmt.Errorf("failed to create key for retention policy: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(key, storedJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put to world state: %v", err)
+	}
+
+	return nil
+}
+
+// GetRetentionPolicy returns the retention policy last set
+func (s *LoggingContract) GetRetentionPolicy(ctx contractapi.TransactionContextInterface) (*RetentionPolicy, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(configObjectType, []string{retentionPolicyName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key for retention policy: %v", err)
+	}
+
+	policyJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if policyJSON == nil {
+		return nil, codedError(errCodeNoPolicy, "no retention policy has been set")
+	}
+
+	var policy RetentionPolicy
+	err = json.Unmarshal(policyJSON, &policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// CreatePrivateLog issues a new log whose description and metadata are stored in the given
+// private data collection. The sensitive fields are passed in the "privateLog" transient map
+// entry so they never appear in the transaction; the public log records only the collection.
+func (s *LoggingContract) CreatePrivateLog(ctx contractapi.TransactionContextInterface, collection string, id string, userId string, action string, resource string) error {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("failed to get transient data: %v", err)
+	}
+
+	detailsJSON, ok := transientMap[privateLogTransientKey]
+	if !ok {
+		return fmt.Errorf("the %s key was not found in the transient map", privateLogTransientKey)
+	}
+
+	var details PrivateLogDetails
+	err = json.Unmarshal(detailsJSON, &details)
+	if err != nil {
+		return fmt.Errorf("failed to parse private log details: %v", err)
+	}
+	details.ID = id
+
+	exists, err := s.LogExists(ctx, id)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return codedError(errCodeAlreadyExists, "the log %s already exists", id)
+	}
+
+	// Every endorser must write the same log, so it takes the transaction's time
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to read transaction timestamp: %v", err)
+	}
+
+	log := LogEvent{
+		DocType:     logObjectType,
+		ID:          id,
+		UserID:      userId,
+		Action:      action,
+		Resource:    resource,
+		Timestamp:   txTimestamp.AsTime().UTC().Format(time.RFC3339),
+		Description: details.Description,
+		Metadata:    details.Metadata,
+		Collection:  collection,
+	}
+
+	err = validateLog(log)
+	if err != nil {
+		return err
+	}
+
+	// The sensitive fields only live in the collection
+	log.Description = ""
+	log.Metadata = ""
+
 	logJSON, err := json.Marshal(log)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(id, logJSON)
+	privateJSON, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	key, err := logKey(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutPrivateData(collection, key, privateJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put private log details in collection %s: %v", collection, err)
+	}
+
+	err = ctx.GetStub().PutState(key, logJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put to world state: %v", err)
+	}
+
+	return ctx.GetStub().SetEvent(logCreatedEvent, logJSON)
+}
+
+// ReadPrivateLog returns the sensitive fields of a private log from the given collection
+func (s *LoggingContract) ReadPrivateLog(ctx contractapi.TransactionContextInterface, collection string, id string) (*PrivateLogDetails, error) {
+	key, err := logKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	detailsJSON, err := ctx.GetStub().GetPrivateData(collection, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from collection %s: %v", collection, err)
+	}
+	if detailsJSON == nil {
+		return nil, codedError(errCodeNotFound, "the private details of log %s do not exist in collection %s", id, collection)
+	}
+
+	var details PrivateLogDetails
+	err = json.Unmarshal(detailsJSON, &details)
+	if err != nil {
+		return nil, err
+	}
+
+	return &details, nil
 }
 
 // ReadLog returns the log stored in the world state with given id
 func (s *LoggingContract) ReadLog(ctx contractapi.TransactionContextInterface, id string) (*LogEvent, error) {
-	logJSON, err := ctx.GetStub().GetState(id)
+	key, err := logKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	logJSON, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read from world state: %v", err)
 	}
 	if logJSON == nil {
-		return nil, fmt.Errorf("the log %s does not exist", id)
+		return nil, codedError(errCodeNotFound, "the log %s does not exist", id)
 	}
 
 	var log LogEvent
@@ -102,57 +722,105 @@ func (s *LoggingContract) ReadLog(ctx contractapi.TransactionContextInterface, i
 
 // GetAllLogs returns all logs found in world state
 func (s *LoggingContract) GetAllLogs(ctx contractapi.TransactionContextInterface) ([]*LogEvent, error) {
-	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(logObjectType, []string{})
 	if err != nil {
 		return nil, err
 	}
 	defer resultsIterator.Close()
 
-	var logs []*LogEvent
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
-		if err != nil {
-			return nil, err
-		}
+	return constructLogsFromIterator(resultsIterator)
+}
 
-		var log LogEvent
-		err = json.Unmarshal(queryResponse.Value, &log)
-		if err != nil {
-			return nil, err
-		}
-		logs = append(logs, &log)
+// GetAllLogsWithPagination returns one page of logs found in world state, starting at bookmark
+func (s *LoggingContract) GetAllLogsWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*PaginatedQueryResult, error) {
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(logObjectType, []string{}, pageSize, bookmark)
+	if err != nil {
+		return nil, err
 	}
+	defer resultsIterator.Close()
 
-	return logs, nil
+	logs, err := constructLogsFromIterator(resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedQueryResult{
+		Records:             logs,
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:            responseMetadata.Bookmark,
+	}, nil
 }
 
 // GetLogsByUser returns all logs for a specific user
 func (s *LoggingContract) GetLogsByUser(ctx contractapi.TransactionContextInterface, userId string) ([]*LogEvent, error) {
-	queryString := fmt.Sprintf(`{"selector":{"userId":"%s"}}`, userId)
-	return getQueryResultForQueryString(ctx, queryString)
+	return queryLogs(ctx, LogFilter{UserID: userId})
 }
 
 // GetLogsByAction returns all logs for a specific action
 func (s *LoggingContract) GetLogsByAction(ctx contractapi.TransactionContextInterface, action string) ([]*LogEvent, error) {
-	queryString := fmt.Sprintf(`{"selector":{"action":"%s"}}`, action)
-	return getQueryResultForQueryString(ctx, queryString)
+	return queryLogs(ctx, LogFilter{Action: action})
 }
 
 // GetLogsByResource returns all logs for a specific resource
 func (s *LoggingContract) GetLogsByResource(ctx contractapi.TransactionContextInterface, resource string) ([]*LogEvent, error) {
-	queryString := fmt.Sprintf(`{"selector":{"resource":"%s"}}`, resource)
-	return getQueryResultForQueryString(ctx, queryString)
+	return queryLogs(ctx, LogFilter{Resource: resource})
 }
 
 // GetLogsByTimeRange returns all logs between two timestamps
 func (s *LoggingContract) GetLogsByTimeRange(ctx contractapi.TransactionContextInterface, startTime string, endTime string) ([]*LogEvent, error) {
-	queryString := fmt.Sprintf(`{"selector":{"timestamp":{"$gte":"%s","$lte":"%s"}}}`, startTime, endTime)
-	return getQueryResultForQueryString(ctx, queryString)
+	return queryLogs(ctx, LogFilter{StartTime: startTime, EndTime: endTime})
+}
+
+// QueryLogs returns all logs matching the given JSON encoded LogFilter
+func (s *LoggingContract) QueryLogs(ctx contractapi.TransactionContextInterface, filterJSON string) ([]*LogEvent, error) {
+	var filter LogFilter
+	err := json.Unmarshal([]byte(filterJSON), &filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse log filter: %v", err)
+	}
+
+	return queryLogs(ctx, filter)
+}
+
+// QueryLogsWithPagination returns one page of logs matching the given JSON encoded LogFilter, starting at bookmark
+func (s *LoggingContract) QueryLogsWithPagination(ctx contractapi.TransactionContextInterface, filterJSON string, pageSize int32, bookmark string) (*PaginatedQueryResult, error) {
+	var filter LogFilter
+	err := json.Unmarshal([]byte(filterJSON), &filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse log filter: %v", err)
+	}
+
+	queryString, err := buildQueryString(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetQueryResultWithPagination(queryString, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	logs, err := constructLogsFromIterator(resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedQueryResult{
+		Records:             logs,
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:            responseMetadata.Bookmark,
+	}, nil
 }
 
 // LogExists returns true when log with given ID exists in world state
 func (s *LoggingContract) LogExists(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
-	logJSON, err := ctx.GetStub().GetState(id)
+	key, err := logKey(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	logJSON, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return false, fmt.Errorf("failed to read from world state: %v", err)
 	}
@@ -160,6 +828,149 @@ func (s *LoggingContract) LogExists(ctx contractapi.TransactionContextInterface,
 	return logJSON != nil, nil
 }
 
+// Ping reads the world state without changing it, so clients can check that the chaincode is
+// running and its state database answers
+func (s *LoggingContract) Ping(ctx contractapi.TransactionContextInterface) error {
+	if _, err := ctx.GetStub().GetState(pingKey); err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+
+	return nil
+}
+
+// validateLog checks the fields of a log before it is written to the ledger
+func validateLog(log LogEvent) error {
+	required := []struct {
+		name  string
+		value string
+	}{
+		{"id", log.ID},
+		{"userId", log.UserID},
+		{"action", log.Action},
+		{"resource", log.Resource},
+	}
+	for _, field := range required {
+		if field.value == "" {
+			return codedError(errCodeInvalid, "the log %s is missing the required field %s", log.ID, field.name)
+		}
+	}
+
+	limits := []struct {
+		name   string
+		value  string
+		length int
+	}{
+		{"id", log.ID, maxIDLength},
+		{"userId", log.UserID, maxUserIDLength},
+		{"action", log.Action, maxActionLength},
+		{"resource", log.Resource, maxResourceLength},
+		{"description", log.Description, maxDescriptionLength},
+		{"metadata", log.Metadata, maxMetadataLength},
+	}
+	for _, field := range limits {
+		if len(field.value) > field.length {
+			return codedError(errCodeInvalid, "the %s of log %s exceeds %d characters", field.name, log.ID, field.length)
+		}
+	}
+
+	if !actionPattern.MatchString(log.Action) {
+		return codedError(errCodeInvalid, "the action %s of log %s must be upper-case letters, digits and underscores", log.Action, log.ID)
+	}
+
+	if log.Metadata != "" {
+		var metadata map[string]interface{}
+		if err := json.Unmarshal([]byte(log.Metadata), &metadata); err != nil {
+			return codedError(errCodeInvalid, "the metadata of log %s must be a JSON object", log.ID)
+		}
+		if err := validateMetadataEncoding(metadata); err != nil {
+			return codedError(errCodeInvalid, "the metadata of log %s is invalid: %v", log.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// validateMetadataEncoding checks the envelope of compressed metadata. The payload itself is
+// not decompressed; readers decode it.
+func validateMetadataEncoding(metadata map[string]interface{}) error {
+	encoding, ok := metadata[metadataEncodingField]
+	if !ok {
+		return nil
+	}
+
+	name, ok := encoding.(string)
+	if !ok || !metadataEncodings[name] {
+		return fmt.Errorf("unsupported encoding %v", encoding)
+	}
+
+	data, ok := metadata[metadataDataField].(string)
+	if !ok {
+		return fmt.Errorf("missing %s payload", metadataDataField)
+	}
+	if _, err := base64.StdEncoding.DecodeString(data); err != nil {
+		return fmt.Errorf("%s payload is not base64: %v", metadataDataField, err)
+	}
+
+	return nil
+}
+
+// codedError formats an error message prefixed with its error code
+func codedError(code string, format string, args ...interface{}) error {
+	return fmt.Errorf("[%s] %s", code, fmt.Sprintf(format, args...))
+}
+
+// logKey builds the namespaced world state key for the log with given id
+func logKey(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(logObjectType, []string{id})
+	if err != nil {
+		return "", fmt.Errorf("failed to create key for log %s: %v", id, err)
+	}
+
+	return key, nil
+}
+
+// buildQueryString compiles a LogFilter into a CouchDB selector scoped to log records
+func buildQueryString(filter LogFilter) (string, error) {
+	selector := map[string]interface{}{"docType": logObjectType}
+	if filter.UserID != "" {
+		selector["userId"] = filter.UserID
+	}
+	if filter.Action != "" {
+		selector["action"] = filter.Action
+	}
+	if filter.Resource != "" {
+		selector["resource"] = filter.Resource
+	}
+	if filter.StartTime != "" || filter.EndTime != "" {
+		timestamp := map[string]string{}
+		if filter.StartTime != "" {
+			timestamp["$gte"] = filter.StartTime
+		}
+		if filter.EndTime != "" {
+			timestamp["$lte"] = filter.EndTime
+		}
+		selector["timestamp"] = timestamp
+	}
+
+	queryJSON, err := json.Marshal(map[string]interface{}{"selector": selector})
+	if err != nil {
+		return "", err
+	}
+
+	return string(queryJSON), nil
+}
+
+// queryLogs returns the logs matching filter. Selectors are always built by buildQueryString,
+// which encodes the arguments, so no argument can widen a query beyond the log records.
+func queryLogs(ctx contractapi.TransactionContextInterface, filter LogFilter) ([]*LogEvent, error) {
+	queryString, err := buildQueryString(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return getQueryResultForQueryString(ctx, queryString)
+}
+
 // Helper function for querying the ledger
 func getQueryResultForQueryString(ctx contractapi.TransactionContextInterface, queryString string) ([]*LogEvent, error) {
 	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
@@ -168,6 +979,11 @@ func getQueryResultForQueryString(ctx contractapi.TransactionContextInterface, q
 	}
 	defer resultsIterator.Close()
 
+	return constructLogsFromIterator(resultsIterator)
+}
+
+// constructLogsFromIterator decodes every log returned by a world state iterator
+func constructLogsFromIterator(resultsIterator shim.StateQueryIteratorInterface) ([]*LogEvent, error) {
 	var logs []*LogEvent
 	for resultsIterator.HasNext() {
 		queryResponse, err := resultsIterator.Next()
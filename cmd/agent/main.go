@@ -0,0 +1,96 @@
+// Command agent ships existing application logs to the ledger. It follows the files, or standard
+// input, listed in its configuration file, parses their entries and submits them in batched
+// transactions, checkpointing how far each file has been read (see package tail).
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/tail"
+)
+
+func main() {
+	configPath := flag.String("config", os.Getenv("AGENT_CONFIG"), "agent configuration file of the inputs to follow")
+	checkpointPath := flag.String("checkpoint", os.Getenv("AGENT_CHECKPOINT_PATH"), "file the positions reached are saved to; the checkpoint of the configuration file when empty")
+	fromEnd := flag.Bool("from-end", false, "start files without a checkpointed position at their end instead of their start")
+	batchSize := flag.Int("batch-size", client.DefaultMaxBatchSize, "most logs submitted per transaction")
+	flushInterval := flag.Duration("flush-interval", client.DefaultFlushInterval, "longest a log waits before its batch is submitted")
+	maxBuffered := flag.Int("max-buffered", 10000, "most logs waiting to be submitted before reading pauses")
+	deadLetters := flag.String("dead-letter-dir", os.Getenv("DEAD_LETTER_DIR"), "directory keeping the logs the chaincode rejects, for fablog or the REST API to resubmit")
+	profile := flag.String("profile", "", "connection profile; CONNECTION_PROFILE_PATH is used when empty")
+	org := flag.String("org", "", "organization in the connection profile; ORG is used when empty")
+	flag.Parse()
+
+	if *configPath == "" {
+		log.Fatal("no agent configuration: set -config or AGENT_CONFIG")
+	}
+	agentConfig, err := tail.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	options, err := agentConfig.Options()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *checkpointPath != "" {
+		options.CheckpointPath = *checkpointPath
+	}
+	options.FromEnd = *fromEnd
+
+	cfg, err := client.LoadConfig(*profile, *org)
+	if err != nil {
+		log.Fatalf("failed to load configuration: %v", err)
+	}
+	var clientOptions []client.Option
+	if *deadLetters != "" {
+		queue, err := client.NewDeadLetterQueue(*deadLetters)
+		if err != nil {
+			log.Fatalf("failed to open dead-letter queue: %v", err)
+		}
+		clientOptions = append(clientOptions, client.WithDeadLetterQueue(queue))
+	}
+	c, err := client.Connect(cfg, clientOptions...)
+	if err != nil {
+		log.Fatalf("failed to connect to gateway: %v", err)
+	}
+	defer c.Close()
+
+	submitter := client.NewBatchingSubmitter(c, client.BatchOptions{
+		MaxBatchSize:  *batchSize,
+		FlushInterval: *flushInterval,
+		MaxBuffered:   *maxBuffered,
+		OnError: func(logs []client.LogEvent, err error) {
+			log.Printf("failed to submit %d logs: %v", len(logs), err)
+		},
+	})
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		submitter.Close(ctx)
+	}()
+	options.Submitter = submitter
+	options.Flush = submitter.Flush
+	options.OnError = func(err error) {
+		log.Print(err)
+	}
+
+	agent, err := tail.NewAgent(options)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("shipping %d inputs to channel %s", len(options.Inputs), cfg.ChannelName)
+	if err := agent.Run(ctx); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,73 @@
+// Command alerter follows the logs committed to the logging chaincode and routes notifications
+// about the high-severity ones to Slack, PagerDuty, Opsgenie and email, as configured in an alert
+// routing file (see package alert).
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/alert"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+func main() {
+	configPath := flag.String("config", os.Getenv("ALERT_CONFIG"), "alert routing file of receivers and routes")
+	checkpointPath := flag.String("checkpoint", os.Getenv("ALERT_CHECKPOINT_PATH"), "file recording the last log handled, so a restart resumes after it; logs committed while stopped are skipped when empty")
+	timeout := flag.Duration("notify-timeout", 10*time.Second, "how long a notification may take")
+	profile := flag.String("profile", "", "connection profile; CONNECTION_PROFILE_PATH is used when empty")
+	org := flag.String("org", "", "organization in the connection profile; ORG is used when empty")
+	flag.Parse()
+
+	if *configPath == "" {
+		log.Fatal("no alert routing file: set -config or ALERT_CONFIG")
+	}
+	alertConfig, err := alert.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	options, err := alertConfig.Options(&http.Client{Timeout: *timeout})
+	if err != nil {
+		log.Fatal(err)
+	}
+	options.OnError = func(err error) {
+		log.Print(err)
+	}
+	router, err := alert.NewRouter(options)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfg, err := client.LoadConfig(*profile, *org)
+	if err != nil {
+		log.Fatalf("failed to load configuration: %v", err)
+	}
+	c, err := client.Connect(cfg)
+	if err != nil {
+		log.Fatalf("failed to connect to gateway: %v", err)
+	}
+	defer c.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var listenOptions []client.ListenOption
+	if *checkpointPath != "" {
+		listenOptions = append(listenOptions, client.WithCheckpointer(client.NewFileCheckpointer(*checkpointPath)))
+	}
+	listener := c.NewEventListener(func(event client.ContractEvent) error {
+		router.Handle(ctx, event)
+		return nil
+	}, listenOptions...)
+
+	log.Printf("routing alerts of %d routes on channel %s", len(options.Routes), cfg.ChannelName)
+	if err := listener.Run(ctx); err != nil && ctx.Err() == nil {
+		log.Fatalf("event listener stopped: %v", err)
+	}
+}
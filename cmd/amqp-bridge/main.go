@@ -0,0 +1,113 @@
+// Command amqp-bridge drains a RabbitMQ queue onto the ledger (see amqp.Bridge): it consumes the
+// queue with manual acknowledgements, maps each message to a log by its exchange and routing key
+// with the templates of a rules file, and acknowledges messages only once their logs are committed
+// on the ledger.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"log"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/amqp"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+func main() {
+	rawURL := flag.String("url", envOr("AMQP_URL", amqp.DefaultURL), "server URL with the user and virtual host, such as amqp://user@host:5672/vhost; amqps:// requires TLS. AMQP_PASSWORD holds the password unless the URL does")
+	queue := flag.String("queue", os.Getenv("AMQP_QUEUE"), "queue to drain")
+	rulesPath := flag.String("rules", os.Getenv("AMQP_RULES"), "rules file of the templates turning messages into logs by exchange and routing key; the defaults are used when empty")
+	tlsCA := flag.String("tls-ca", os.Getenv("AMQP_TLS_CA"), "CA certificates of the server, enabling TLS; the system pool is used when empty")
+	tlsCert := flag.String("tls-cert", os.Getenv("AMQP_TLS_CERT"), "client certificate presented to the server, enabling TLS; it authenticates the connection when the URL has no user")
+	tlsKey := flag.String("tls-key", os.Getenv("AMQP_TLS_KEY"), "private key of the client certificate")
+	prefetch := flag.Int("prefetch", 0, "most messages delivered before they are acknowledged; twice the batch size when 0")
+	batchSize := flag.Int("batch-size", amqp.DefaultBatchSize, "most messages submitted per transaction")
+	flushInterval := flag.Duration("flush-interval", amqp.DefaultFlushInterval, "longest the bridge waits for a batch to fill")
+	maxMessageSize := flag.Int("max-message-size", amqp.DefaultMaxMessageSize, "largest message body recorded; larger messages are rejected")
+	metricsAddr := flag.String("metrics-addr", os.Getenv("METRICS_ADDR"), "address to serve Prometheus metrics on at /metrics; not served when empty")
+	profile := flag.String("profile", "", "connection profile; CONNECTION_PROFILE_PATH is used when empty")
+	org := flag.String("org", "", "organization in the connection profile; ORG is used when empty")
+	flag.Parse()
+
+	if *queue == "" {
+		log.Fatal("no queue configured: set -queue or AMQP_QUEUE")
+	}
+	// The password is only read from the environment, so it does not show in the process list
+	serverURL, err := url.Parse(*rawURL)
+	if err != nil {
+		log.Fatalf("invalid AMQP URL: %v", err)
+	}
+	if password := os.Getenv("AMQP_PASSWORD"); password != "" && serverURL.User != nil {
+		if _, set := serverURL.User.Password(); !set {
+			serverURL.User = url.UserPassword(serverURL.User.Username(), password)
+		}
+	}
+	var rules []amqp.Rule
+	if *rulesPath != "" {
+		if rules, err = amqp.LoadRules(*rulesPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+	var tlsConfig *tls.Config
+	if *tlsCA != "" || *tlsCert != "" {
+		if tlsConfig, err = client.ClientTLSConfig(*tlsCA, *tlsCert, *tlsKey); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	cfg, err := client.LoadConfig(*profile, *org)
+	if err != nil {
+		log.Fatalf("failed to load configuration: %v", err)
+	}
+	c, err := client.Connect(cfg)
+	if err != nil {
+		log.Fatalf("failed to connect to gateway: %v", err)
+	}
+	defer c.Close()
+
+	bridge, err := amqp.NewBridge(c, amqp.Options{
+		URL:            serverURL.String(),
+		Queue:          *queue,
+		Name:           "fabric-logging-amqp-bridge",
+		TLS:            tlsConfig,
+		Rules:          rules,
+		Prefetch:       *prefetch,
+		BatchSize:      *batchSize,
+		FlushInterval:  *flushInterval,
+		MaxMessageSize: *maxMessageSize,
+		OnError: func(err error) {
+			log.Print(err)
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *metricsAddr != "" {
+		metrics, err := client.ServeMetrics(*metricsAddr, nil)
+		if err != nil {
+			log.Fatalf("failed to serve metrics: %v", err)
+		}
+		defer metrics.Close()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("draining queue %s on %s", *queue, serverURL.Redacted())
+	if err := bridge.Run(ctx); err != nil {
+		log.Printf("bridge stopped: %v", err)
+	}
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,365 @@
+// Command api serves the logging chaincode over HTTP and JSON. It connects to the Fabric Gateway
+// described by a connection profile and the usual environment variables (see client.LoadConfig)
+// and exposes the routes of package rest, and with -grpc-addr the gRPC service of package rpc.
+// With -tenants it serves several tenants, each on its own channel or chaincode with its own
+// identity, routing each request by the tenant of the caller's token or API key.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/rest"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/rpc"
+	bolt "go.etcd.io/bbolt"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	addr := flag.String("addr", envOr("API_ADDR", ":8080"), "address to listen on")
+	grpcAddr := flag.String("grpc-addr", os.Getenv("GRPC_ADDR"), "address to serve the gRPC LoggingService on; not served when empty")
+	profile := flag.String("profile", "", "connection profile; CONNECTION_PROFILE_PATH is used when empty")
+	org := flag.String("org", "", "organization in the connection profile; ORG is used when empty")
+	issuer := flag.String("oidc-issuer", os.Getenv("OIDC_ISSUER"), "OpenID Connect issuer whose bearer tokens are required; no authentication when empty")
+	audience := flag.String("oidc-audience", os.Getenv("OIDC_AUDIENCE"), "audience required in bearer tokens")
+	userClaim := flag.String("oidc-user-claim", os.Getenv("OIDC_USER_CLAIM"), "token claim holding the caller's user ID; sub when empty")
+	tenantClaim := flag.String("oidc-tenant-claim", envOr("OIDC_TENANT_CLAIM", "tenant"), "with -tenants, token claim holding the caller's tenant")
+	roleClaim := flag.String("oidc-role-claim", os.Getenv("OIDC_ROLE_CLAIM"), "token claim holding the caller's roles, such as realm_access.roles; every caller gets the default roles when empty")
+	roleMap := flag.String("oidc-role-map", os.Getenv("OIDC_ROLE_MAP"), "comma-separated value=role pairs translating role claim values to roles; values are role names when empty")
+	defaultRoles := flag.String("oidc-default-roles", envOr("OIDC_DEFAULT_ROLES", "reader,writer"), "comma-separated roles of every caller when -oidc-role-claim is not set")
+	bindUserID := flag.Bool("bind-user-id", false, "record logs under the caller's user ID and reject logs of other users")
+	userWallet := flag.String("user-wallet", os.Getenv("USER_WALLET_PATH"), "wallet of per-user Fabric identities, labelled by user ID, to sign each caller's logs with; implies -bind-user-id")
+	apiKeysPath := flag.String("api-keys", os.Getenv("API_KEYS_PATH"), "database of API keys for machine clients; API keys are disabled when empty")
+	exportsPath := flag.String("exports", os.Getenv("EXPORTS_PATH"), "directory for the files of export jobs; exports are disabled when empty")
+	webhooksPath := flag.String("webhooks", os.Getenv("WEBHOOKS_PATH"), "webhook sources file of the signatures and templates of /ingest/webhook/{source}; webhooks are disabled when empty")
+	tenantsPath := flag.String("tenants", os.Getenv("TENANTS_PATH"), "tenants file mapping each tenant to its channel or chaincode and identity; a single tenant is served when empty")
+	issueAdminKey := flag.String("issue-admin-key", "", "issue an admin API key with this name, print its token and exit")
+	issueTenant := flag.String("tenant", "", "with -issue-admin-key, the tenant the key administers")
+	accessLog := flag.String("access-log", os.Getenv("ACCESS_LOG_PATH"), "file to append a JSON line to for every administrative and export request, or - for standard output")
+	auditToLedger := flag.Bool("audit-to-ledger", false, "also record every administrative and export request as an API_ACCESS log on the ledger")
+	callerRate := flag.Float64("rate-limit", 0, "requests per second each authenticated caller may make on average; unlimited when zero")
+	callerBurst := flag.Int("rate-burst", 0, "requests each caller may make at once; the rate, rounded up, when zero")
+	ipRate := flag.Float64("ip-rate-limit", 0, "requests per second each client address may make on average; unlimited when zero")
+	ipBurst := flag.Int("ip-rate-burst", 0, "requests each client address may make at once; the rate, rounded up, when zero")
+	forwardedFor := flag.Bool("forwarded-for", false, "take client addresses from X-Forwarded-For, as set by a proxy in front of the server")
+	metricsAddr := flag.String("metrics-addr", os.Getenv("METRICS_ADDR"), "address to serve Prometheus metrics on at /metrics; not served when empty")
+	drainDelay := flag.Duration("drain-delay", 5*time.Second, "how long /readyz fails on shutdown before new connections are refused, so load balancers stop routing here")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 30*time.Second, "how long shutdown waits for in-flight requests, such as submissions awaiting commit")
+	flag.Parse()
+
+	var apiKeys *rest.APIKeys
+	if *apiKeysPath != "" {
+		db, err := bolt.Open(*apiKeysPath, 0600, &bolt.Options{Timeout: 5 * time.Second})
+		if err != nil {
+			log.Fatalf("failed to open API key database: %v", err)
+		}
+		defer db.Close()
+		store, err := rest.NewBoltAPIKeyStore(db)
+		if err != nil {
+			log.Fatalf("failed to open API key store: %v", err)
+		}
+		apiKeys = rest.NewAPIKeys(store)
+	}
+	if *issueAdminKey != "" {
+		if apiKeys == nil {
+			log.Fatalf("issuing an API key requires -api-keys")
+		}
+		token, _, err := apiKeys.IssueForTenant(*issueTenant, *issueAdminKey, "", []rest.Scope{rest.ScopeAdmin}, 0)
+		if err != nil {
+			log.Fatalf("failed to issue API key: %v", err)
+		}
+		fmt.Println(token)
+		return
+	}
+
+	var tenants map[string]tenantConfig
+	if *tenantsPath != "" {
+		// Each tenant brings its own identity, and callers are routed by tenant rather than by
+		// user, so these apply to a single tenant only
+		switch {
+		case *userWallet != "":
+			log.Fatalf("-user-wallet cannot be used with -tenants")
+		case *webhooksPath != "":
+			log.Fatalf("-webhooks cannot be used with -tenants; give each tenant its webhooks file")
+		case *grpcAddr != "":
+			log.Fatalf("-grpc-addr cannot be used with -tenants")
+		case *issuer == "" && apiKeys == nil:
+			log.Fatalf("serving tenants requires authentication")
+		}
+		var err error
+		if tenants, err = loadTenants(*tenantsPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	cfg, err := client.LoadConfig(*profile, *org)
+	if err != nil {
+		log.Fatalf("failed to load configuration: %v", err)
+	}
+
+	options := rest.Options{APIKeys: apiKeys, BindUserID: *bindUserID || *userWallet != ""}
+	var auditors []rest.AccessAuditor
+	switch *accessLog {
+	case "":
+	case "-":
+		auditors = append(auditors, rest.NewAccessLog(os.Stdout))
+	default:
+		f, err := os.OpenFile(*accessLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			log.Fatalf("failed to open access log: %v", err)
+		}
+		defer f.Close()
+		auditors = append(auditors, rest.NewAccessLog(f))
+	}
+	if len(auditors) > 0 {
+		options.AccessAuditor = rest.AuditAll(auditors...)
+	}
+	if len(auditors) > 0 || *auditToLedger {
+		options.AuditErrors = func(record rest.AccessRecord, err error) {
+			log.Printf("failed to audit %s %s: %v", record.Method, record.Path, err)
+		}
+	}
+	if *callerRate > 0 || *ipRate > 0 {
+		options.RateLimiter, err = rest.NewRequestLimiter(rest.RequestLimiterOptions{
+			PerCaller:    rest.RateLimit{Rate: *callerRate, Burst: *callerBurst},
+			PerIP:        rest.RateLimit{Rate: *ipRate, Burst: *ipBurst},
+			ForwardedFor: *forwardedFor,
+		})
+		if err != nil {
+			log.Fatalf("failed to set up rate limits: %v", err)
+		}
+	}
+	if *metricsAddr != "" {
+		metrics, err := client.ServeMetrics(*metricsAddr, nil)
+		if err != nil {
+			log.Fatalf("failed to serve metrics: %v", err)
+		}
+		defer metrics.Close()
+	}
+	if *issuer != "" {
+		roles, err := rest.ParseRoles(*defaultRoles)
+		if err != nil {
+			log.Fatalf("invalid default roles: %v", err)
+		}
+		mapping, err := parseRoleMap(*roleMap)
+		if err != nil {
+			log.Fatalf("invalid role map: %v", err)
+		}
+
+		// Callers of a single tenant gateway belong to no tenant
+		oidcTenantClaim := ""
+		if tenants != nil {
+			oidcTenantClaim = *tenantClaim
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		options.Authenticator, err = rest.NewOIDCAuthenticator(ctx, rest.OIDCOptions{
+			Issuer:       *issuer,
+			Audience:     *audience,
+			UserClaim:    *userClaim,
+			RoleClaim:    *roleClaim,
+			RoleMapping:  mapping,
+			DefaultRoles: roles,
+			TenantClaim:  oidcTenantClaim,
+			Leeway:       time.Minute,
+		})
+		cancel()
+		if err != nil {
+			log.Fatalf("failed to set up authentication: %v", err)
+		}
+	} else if apiKeys == nil {
+		log.Printf("no OIDC issuer or API keys configured; every request is accepted without authentication")
+	}
+	if options.BindUserID && options.Authenticator == nil && apiKeys == nil {
+		log.Fatalf("binding user IDs requires authentication")
+	}
+
+	var handler interface {
+		http.Handler
+		Drain()
+	}
+	var backend client.LoggingClient
+	if tenants != nil {
+		router, closeTenants, err := newTenantRouter(tenants, cfg, options, *exportsPath, *auditToLedger)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer closeTenants()
+		handler = router
+	} else {
+		c, err := client.Connect(cfg)
+		if err != nil {
+			log.Fatalf("failed to connect to the gateway: %v", err)
+		}
+		defer c.Close()
+
+		backend = c
+		if *userWallet != "" {
+			wallet, err := client.NewFileSystemWallet(*userWallet)
+			if err != nil {
+				log.Fatalf("failed to open user wallet: %v", err)
+			}
+			backend = client.NewUserIdentityClient(c, client.WalletResolver(wallet))
+		}
+		if *exportsPath != "" {
+			options.Exports, err = rest.NewExportJobs(rest.ExportJobOptions{Directory: *exportsPath})
+			if err != nil {
+				log.Fatalf("failed to set up exports: %v", err)
+			}
+			defer options.Exports.Close()
+		}
+		if *webhooksPath != "" {
+			webhooks, err := rest.LoadWebhookConfig(*webhooksPath)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if options.Webhooks, err = rest.NewWebhooks(webhooks); err != nil {
+				log.Fatalf("failed to set up webhooks: %v", err)
+			}
+		}
+		if *auditToLedger {
+			auditor, closeAuditor := newLedgerAuditor(c)
+			defer closeAuditor()
+			options.AccessAuditor = rest.AuditAll(options.AccessAuditor, auditor)
+		}
+		handler = rest.NewServer(backend, options)
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		// Submissions wait for commit, which can take several block intervals
+		WriteTimeout: 2 * time.Minute,
+		IdleTimeout:  2 * time.Minute,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	served := make(chan error, 2)
+	go func() {
+		log.Printf("serving the logging API on %s", *addr)
+		served <- server.ListenAndServe()
+	}()
+
+	var grpcHandler *rpc.Server
+	var grpcServer *grpc.Server
+	if *grpcAddr != "" {
+		// gRPC callers present the same bearer tokens and API keys as REST callers
+		var authenticators []rest.Authenticator
+		if options.Authenticator != nil {
+			authenticators = append(authenticators, options.Authenticator)
+		}
+		if apiKeys != nil {
+			authenticators = append(authenticators, apiKeys)
+		}
+		grpcOptions := rpc.Options{IDGenerator: options.IDGenerator, BindUserID: options.BindUserID}
+		if len(authenticators) > 0 {
+			grpcOptions.Authenticator = rest.AnyOf(authenticators...)
+		}
+
+		listener, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			log.Fatalf("failed to listen for gRPC: %v", err)
+		}
+		grpcHandler = rpc.NewServer(backend, grpcOptions)
+		grpcServer = grpc.NewServer()
+		grpcHandler.Register(grpcServer)
+		go func() {
+			log.Printf("serving the gRPC LoggingService on %s", *grpcAddr)
+			served <- grpcServer.Serve(listener)
+		}()
+	}
+
+	select {
+	case err := <-served:
+		log.Printf("server stopped: %v", err)
+		return
+	case <-ctx.Done():
+	}
+	// A second signal kills the process without waiting
+	stop()
+
+	log.Printf("shutting down; draining for %s", *drainDelay)
+	handler.Drain()
+	if grpcHandler != nil {
+		grpcHandler.Drain()
+	}
+	time.Sleep(*drainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		// In-flight calls that outlast the timeout are cancelled
+		defer func() {
+			select {
+			case <-stopped:
+			case <-shutdownCtx.Done():
+				grpcServer.Stop()
+			}
+		}()
+	}
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("failed to wait for in-flight requests: %v", err)
+		return
+	}
+	log.Printf("server stopped")
+}
+
+// newLedgerAuditor returns an auditor recording accesses on the ledger of c, under the gateway's
+// own identity, in batches so requests never wait for them, and a function flushing the batches
+func newLedgerAuditor(c *client.Client) (rest.AccessAuditor, func()) {
+	submitter := client.NewBatchingSubmitter(c, client.BatchOptions{
+		OnError: func(logs []client.LogEvent, err error) {
+			log.Printf("failed to record %d accesses on the ledger: %v", len(logs), err)
+		},
+	})
+	return rest.NewLedgerAuditor(submitter), func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		submitter.Close(ctx)
+	}
+}
+
+// parseRoleMap parses value=role pairs separated by commas
+func parseRoleMap(s string) (map[string]rest.Role, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	mapping := map[string]rest.Role{}
+	for _, pair := range strings.Split(s, ",") {
+		value, name, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not a value=role pair", pair)
+		}
+		role, err := rest.ParseRole(name)
+		if err != nil {
+			return nil, err
+		}
+		mapping[strings.TrimSpace(value)] = role
+	}
+	return mapping, nil
+}
+
+func envOr(name string, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/rest"
+	"gopkg.in/yaml.v2"
+)
+
+// tenantsFile is the file of -tenants, in YAML or JSON, keyed by tenant ID
+type tenantsFile struct {
+	Tenants map[string]tenantConfig `json:"tenants" yaml:"tenants"`
+}
+
+// tenantConfig maps a tenant to the channel or chaincode namespace holding its logs, and to the
+// Fabric identity that writes and reads them. Settings left empty are the gateway's own.
+type tenantConfig struct {
+	// Profile and Org select the tenant's organization in another connection profile
+	Profile string `json:"profile,omitempty" yaml:"profile,omitempty"`
+	Org     string `json:"org,omitempty" yaml:"org,omitempty"`
+	Channel string `json:"channel,omitempty" yaml:"channel,omitempty"`
+	// Chaincode is the chaincode whose namespace of the world state holds the tenant's logs
+	Chaincode string `json:"chaincode,omitempty" yaml:"chaincode,omitempty"`
+	MSPID     string `json:"mspId,omitempty" yaml:"mspId,omitempty"`
+	Cert      string `json:"cert,omitempty" yaml:"cert,omitempty"`
+	// Key is the private key file, or a keystore directory holding a single key
+	Key string `json:"key,omitempty" yaml:"key,omitempty"`
+	// Webhooks is the tenant's webhook sources file, as -webhooks takes; source names must be
+	// unique across tenants
+	Webhooks string `json:"webhooks,omitempty" yaml:"webhooks,omitempty"`
+}
+
+// loadTenants reads the tenants file at path. Each tenant must name its own channel or chaincode,
+// so no two tenants share the logs of a channel's chaincode.
+func loadTenants(path string) (map[string]tenantConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants file: %v", err)
+	}
+	var file tenantsFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse tenants file %s: %v", path, err)
+	}
+	if len(file.Tenants) == 0 {
+		return nil, fmt.Errorf("no tenants in %s", path)
+	}
+
+	for id, tenant := range file.Tenants {
+		if id == "" {
+			return nil, fmt.Errorf("a tenant of %s has no ID", path)
+		}
+		if tenant.Channel == "" && tenant.Chaincode == "" {
+			return nil, fmt.Errorf("tenant %s names neither a channel nor a chaincode", id)
+		}
+		if (tenant.Cert == "") != (tenant.Key == "") {
+			return nil, fmt.Errorf("tenant %s needs both cert and key", id)
+		}
+	}
+	return file.Tenants, nil
+}
+
+// clientConfig returns the configuration connecting to the tenant's channel and chaincode with its
+// identity, starting from the gateway's
+func (t tenantConfig) clientConfig(base client.Config) (client.Config, error) {
+	cfg := base
+	if t.Profile != "" || t.Org != "" {
+		loaded, err := client.LoadConfig(t.Profile, t.Org)
+		if err != nil {
+			return client.Config{}, err
+		}
+		cfg = loaded
+	}
+	if t.Channel != "" {
+		cfg.ChannelName = t.Channel
+	}
+	if t.Chaincode != "" {
+		cfg.ChaincodeName = t.Chaincode
+	}
+	if t.MSPID != "" {
+		cfg.MSPID = t.MSPID
+	}
+	if t.Cert != "" {
+		cfg.CertPath, cfg.KeyPath = t.Cert, t.Key
+		cfg.WalletPath, cfg.IdentityLabel, cfg.HSM = "", "", nil
+	}
+	return cfg, nil
+}
+
+// newTenantRouter connects to the network once per tenant, with the tenant's identity, and returns
+// the router of their servers with a function closing what it opened. Every server shares options,
+// besides its own export directory under exportsPath, its webhooks and, with auditToLedger, the
+// ledger recording its accesses.
+func newTenantRouter(tenants map[string]tenantConfig, base client.Config, options rest.Options, exportsPath string, auditToLedger bool) (router *rest.TenantRouter, closeAll func(), err error) {
+	var closers []func()
+	closeAll = func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+	defer func() {
+		if err != nil {
+			closeAll()
+		}
+	}()
+
+	servers := map[string]*rest.Server{}
+	namespaces := map[string]string{}
+	for id, tenant := range tenants {
+		cfg, err := tenant.clientConfig(base)
+		if err != nil {
+			return nil, nil, fmt.Errorf("tenant %s: %v", id, err)
+		}
+		if cfg.ChaincodeName == "" {
+			cfg.ChaincodeName = client.DefaultChaincodeName
+		}
+		namespace := cfg.ChannelName + "/" + cfg.ChaincodeName
+		if other, ok := namespaces[namespace]; ok {
+			return nil, nil, fmt.Errorf("tenants %s and %s share chaincode %s on channel %s", other, id, cfg.ChaincodeName, cfg.ChannelName)
+		}
+		namespaces[namespace] = id
+
+		c, err := client.Connect(cfg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("tenant %s: failed to connect to the gateway: %v", id, err)
+		}
+		closers = append(closers, func() { c.Close() })
+
+		tenantOptions := options
+		tenantOptions.Tenant = id
+		if exportsPath != "" {
+			tenantOptions.Exports, err = rest.NewExportJobs(rest.ExportJobOptions{Directory: filepath.Join(exportsPath, id)})
+			if err != nil {
+				return nil, nil, fmt.Errorf("tenant %s: failed to set up exports: %v", id, err)
+			}
+			closers = append(closers, func() { tenantOptions.Exports.Close() })
+		}
+		if tenant.Webhooks != "" {
+			webhooks, err := rest.LoadWebhookConfig(tenant.Webhooks)
+			if err != nil {
+				return nil, nil, fmt.Errorf("tenant %s: %v", id, err)
+			}
+			if tenantOptions.Webhooks, err = rest.NewWebhooks(webhooks); err != nil {
+				return nil, nil, fmt.Errorf("tenant %s: failed to set up webhooks: %v", id, err)
+			}
+		}
+		if auditToLedger {
+			auditor, closeAuditor := newLedgerAuditor(c)
+			closers = append(closers, closeAuditor)
+			tenantOptions.AccessAuditor = rest.AuditAll(options.AccessAuditor, auditor)
+		}
+
+		servers[id] = rest.NewServer(c, tenantOptions)
+		log.Printf("tenant %s: logs of chaincode %s on channel %s, as %s", id, cfg.ChaincodeName, cfg.ChannelName, cfg.MSPID)
+	}
+
+	authenticators := []rest.Authenticator{options.Authenticator}
+	if options.APIKeys != nil {
+		authenticators = append(authenticators, options.APIKeys)
+	}
+	if router, err = rest.NewTenantRouter(rest.AnyOf(authenticators...), servers); err != nil {
+		return nil, nil, err
+	}
+	// /healthz and /readyz answer without naming tenants, so their failures are logged here
+	router.HealthErrors = func(tenant string, err error) {
+		log.Printf("tenant %s: %v", tenant, err)
+	}
+	return router, closeAll, nil
+}
@@ -0,0 +1,126 @@
+// Command beats receives events from Filebeat, Winlogbeat and the other Beats over the lumberjack
+// protocol of their Logstash output and records them on the ledger in batched transactions (see
+// package lumberjack).
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/lumberjack"
+)
+
+func main() {
+	addr := flag.String("addr", envOr("BEATS_ADDR", ":5044"), "address to receive Beats connections on")
+	tlsCert := flag.String("tls-cert", os.Getenv("BEATS_TLS_CERT"), "certificate to serve TLS with; plain TCP is served when empty")
+	tlsKey := flag.String("tls-key", os.Getenv("BEATS_TLS_KEY"), "private key of the TLS certificate")
+	tlsClientCA := flag.String("tls-client-ca", os.Getenv("BEATS_TLS_CLIENT_CA"), "CA certificates Beats must present a certificate of; any Beat is accepted when empty")
+	userID := flag.String("user-id", os.Getenv("BEATS_USER_ID"), "user recorded for events without user.name; the Beat's type when empty")
+	action := flag.String("action", os.Getenv("BEATS_ACTION"), "action recorded for events without event.action; LOG_<LEVEL> when empty")
+	maxPayloadSize := flag.Int("max-payload-size", lumberjack.DefaultMaxPayloadSize, "largest event, or compressed window once expanded, read, in bytes")
+	keepalive := flag.Duration("keepalive", lumberjack.DefaultKeepaliveInterval, "how often a Beat waiting for its window to be committed is told to keep waiting")
+	batchSize := flag.Int("batch-size", client.DefaultMaxBatchSize, "most logs submitted per transaction")
+	flushInterval := flag.Duration("flush-interval", client.DefaultFlushInterval, "longest a log waits before its batch is submitted")
+	maxBuffered := flag.Int("max-buffered", 10000, "most logs waiting to be submitted before Beats are held back")
+	deadLetters := flag.String("dead-letter-dir", os.Getenv("DEAD_LETTER_DIR"), "directory keeping the logs the chaincode rejects, for fablog or the REST API to resubmit")
+	metricsAddr := flag.String("metrics-addr", os.Getenv("METRICS_ADDR"), "address to serve Prometheus metrics on at /metrics; not served when empty")
+	profile := flag.String("profile", "", "connection profile; CONNECTION_PROFILE_PATH is used when empty")
+	org := flag.String("org", "", "organization in the connection profile; ORG is used when empty")
+	flag.Parse()
+
+	var tlsConfig *tls.Config
+	if *tlsCert != "" {
+		var err error
+		if tlsConfig, err = client.ServerTLSConfig(*tlsCert, *tlsKey, *tlsClientCA); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	cfg, err := client.LoadConfig(*profile, *org)
+	if err != nil {
+		log.Fatalf("failed to load configuration: %v", err)
+	}
+	var clientOptions []client.Option
+	if *deadLetters != "" {
+		queue, err := client.NewDeadLetterQueue(*deadLetters)
+		if err != nil {
+			log.Fatalf("failed to open dead-letter queue: %v", err)
+		}
+		clientOptions = append(clientOptions, client.WithDeadLetterQueue(queue))
+	}
+	c, err := client.Connect(cfg, clientOptions...)
+	if err != nil {
+		log.Fatalf("failed to connect to gateway: %v", err)
+	}
+	defer c.Close()
+
+	submitter := client.NewBatchingSubmitter(c, client.BatchOptions{
+		MaxBatchSize:  *batchSize,
+		FlushInterval: *flushInterval,
+		MaxBuffered:   *maxBuffered,
+		OnError: func(logs []client.LogEvent, err error) {
+			log.Printf("failed to submit %d logs: %v", len(logs), err)
+		},
+	})
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		submitter.Close(ctx)
+	}()
+
+	server, err := lumberjack.NewServer(lumberjack.Options{
+		Submitter:         submitter,
+		Flush:             submitter.Flush,
+		UserID:            *userID,
+		Action:            *action,
+		MaxPayloadSize:    *maxPayloadSize,
+		KeepaliveInterval: *keepalive,
+		OnError: func(err error) {
+			log.Print(err)
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *metricsAddr != "" {
+		metrics, err := client.ServeMetrics(*metricsAddr, nil)
+		if err != nil {
+			log.Fatalf("failed to serve metrics: %v", err)
+		}
+		defer metrics.Close()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatalf("failed to listen on %s: %v", *addr, err)
+	}
+	if tlsConfig != nil {
+		log.Printf("receiving Beats events over TLS on %s", listener.Addr())
+		err = server.ServeTLS(ctx, listener, tlsConfig)
+	} else {
+		log.Printf("receiving Beats events on %s", listener.Addr())
+		err = server.Serve(ctx, listener)
+	}
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,76 @@
+// Command dashboard serves the web dashboard of package dashboard. The dashboard reads logs through
+// the REST API of a cmd/api server, which it proxies under /api so the page, its API calls and its
+// event stream share one origin; callers authenticate to that server as they would directly.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/dashboard"
+)
+
+func main() {
+	addr := flag.String("addr", envOr("DASHBOARD_ADDR", ":8081"), "address to listen on")
+	apiURL := flag.String("api", envOr("API_URL", "http://localhost:8080"), "URL of the logging API server the dashboard reads from")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "how long shutdown waits for in-flight requests")
+	flag.Parse()
+
+	target, err := url.Parse(*apiURL)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		log.Fatalf("invalid API URL %q", *apiURL)
+	}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	// Event streams are passed on as each event arrives
+	proxy.FlushInterval = -1
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		log.Printf("failed to reach the API for %s %s: %v", r.Method, r.URL.Path, err)
+		http.Error(w, `{"error":"the logging API cannot be reached"}`, http.StatusBadGateway)
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           dashboard.Handler(proxy),
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	served := make(chan error, 1)
+	go func() {
+		log.Printf("serving the dashboard on %s, reading from %s", *addr, target)
+		served <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-served:
+		log.Printf("server stopped: %v", err)
+		return
+	case <-ctx.Done():
+	}
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	// Open event streams only end when their clients leave, so they are cut at the timeout
+	if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		log.Printf("shutdown: %v", err)
+	}
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
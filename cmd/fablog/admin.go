@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// adminCommands are the subcommands of fablog admin
+var adminCommands []*command
+
+func init() {
+	adminCommands = []*command{
+		{name: "admin show", summary: "print every setting the chaincode records on the ledger", run: runAdminShow},
+		{name: "admin set-retention", summary: "change the retention policy, printing the fields changed; check it only with -dry-run", run: runAdminSetRetention},
+	}
+}
+
+func runAdmin(cmd *command, args []string) error {
+	if len(args) > 0 {
+		for _, sub := range adminCommands {
+			if sub.name == cmd.name+" "+args[0] {
+				return sub.run(sub, args[1:])
+			}
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "usage: fablog admin <command> [flags]\n\n%s\n\ncommands:\n", cmd.summary)
+	for _, sub := range adminCommands {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", sub.name[len(cmd.name)+1:], sub.summary)
+	}
+	return exitStatus(2)
+}
+
+// onChainConfig is every setting the chaincode records, null when never set, as GET /admin/config
+// of the REST gateway returns them
+type onChainConfig struct {
+	Retention *client.RetentionPolicy `json:"retention"`
+}
+
+func runAdminShow(cmd *command, args []string) error {
+	fs := cmd.flags()
+	conn := connectionFlags(fs, defaultTimeout)
+	out := outputFlags(fs, outputTable)
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if err := out.check(); err != nil {
+		return err
+	}
+
+	c, ctx, cancel, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	defer cancel()
+
+	var config onChainConfig
+	config.Retention, err = c.GetRetentionPolicy(ctx)
+	if err != nil && !errors.Is(err, client.ErrNoPolicy) {
+		return fmt.Errorf("failed to read retention policy: %v", err)
+	}
+
+	return out.print(os.Stdout, config, func(w io.Writer, wide bool) error {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		if wide {
+			fmt.Fprintln(tw, "SETTING\tFIELD\tVALUE\tUPDATED AT\tUPDATED BY")
+		} else {
+			fmt.Fprintln(tw, "SETTING\tFIELD\tVALUE")
+		}
+		if p := config.Retention; p == nil {
+			fmt.Fprintln(tw, "retention\t\t(unset)")
+		} else {
+			for _, field := range []struct {
+				name  string
+				value interface{}
+			}{{"maxAgeDays", p.MaxAgeDays}, {"archive", p.Archive}} {
+				fmt.Fprintf(tw, "retention\t%s\t%v", field.name, field.value)
+				if wide {
+					fmt.Fprintf(tw, "\t%s\t%s", p.UpdatedAt, p.UpdatedBy)
+				}
+				fmt.Fprintln(tw)
+			}
+		}
+		return tw.Flush()
+	})
+}
+
+// settingChanges is the output of a change to an on-chain setting
+type settingChanges struct {
+	Setting       string                 `json:"setting"`
+	Changes       []client.SettingChange `json:"changes"`
+	DryRun        bool                   `json:"dryRun"`
+	Applied       bool                   `json:"applied"`
+	TransactionID string                 `json:"transactionId,omitempty"`
+	BlockNumber   uint64                 `json:"blockNumber,omitempty"`
+}
+
+func runAdminSetRetention(cmd *command, args []string) error {
+	fs := cmd.flags()
+	conn := connectionFlags(fs, defaultTimeout)
+	maxAgeDays := fs.Int("max-age-days", 0, "number of days logs are kept; required")
+	archive := fs.Bool("archive", false, "ask for logs to be archived before they are pruned")
+	dryRun := fs.Bool("dry-run", false, "only check the change with the chaincode and print its differences, recording nothing")
+	out := outputFlags(fs, outputTable)
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if err := out.check(); err != nil {
+		return err
+	}
+	if *maxAgeDays < 1 {
+		return fmt.Errorf("-max-age-days must be at least 1")
+	}
+
+	c, ctx, cancel, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	defer cancel()
+
+	current, err := c.GetRetentionPolicy(ctx)
+	if errors.Is(err, client.ErrNoPolicy) {
+		current, err = nil, nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read retention policy: %v", err)
+	}
+	policy := client.RetentionPolicy{MaxAgeDays: *maxAgeDays, Archive: *archive}
+	changes := settingChanges{Setting: "retention", Changes: policy.Changes(current), DryRun: *dryRun}
+
+	switch {
+	case *dryRun:
+		err = c.CheckRetentionPolicy(ctx, policy)
+	case len(changes.Changes) > 0:
+		var result *client.SubmitResult
+		if result, err = c.SetRetentionPolicy(ctx, policy); err == nil {
+			changes.Applied = true
+			changes.TransactionID, changes.BlockNumber = result.TransactionID, result.BlockNumber
+		}
+	}
+	if errors.Is(err, client.ErrUnauthorized) {
+		return fmt.Errorf("setting the policy requires an identity holding the logging.retention attribute: %v", err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set retention policy: %v", err)
+	}
+
+	switch {
+	case changes.Applied:
+		log.Printf("set retention policy in transaction %s, block %d", changes.TransactionID, changes.BlockNumber)
+	case *dryRun:
+		log.Printf("dry run: the chaincode accepts the retention policy; nothing was recorded")
+	default:
+		log.Printf("the retention policy is unchanged; nothing was submitted")
+	}
+	return out.print(os.Stdout, changes, func(w io.Writer, wide bool) error {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "SETTING\tFIELD\tFROM\tTO")
+		for _, change := range changes.Changes {
+			from := "(unset)"
+			if change.From != nil {
+				from = fmt.Sprint(change.From)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%v\n", changes.Setting, change.Field, from, change.To)
+		}
+		return tw.Flush()
+	})
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// Environment variables read by fablog
+const (
+	// envHome overrides the directory holding fablog's configuration, ~/.fablog by default
+	envHome = "FABLOG_HOME"
+	// envContext selects a context instead of the current one
+	envContext = "FABLOG_CONTEXT"
+)
+
+// configFile is the file in the fablog directory holding the contexts
+const configFile = "config.json"
+
+// config is fablog's configuration file
+type config struct {
+	// Current names the context used when none is selected
+	Current  string                 `json:"current,omitempty"`
+	Contexts map[string]*netContext `json:"contexts"`
+}
+
+// netContext is a named set of connection settings: a network, an organization and an identity.
+// Empty fields leave the setting to the connection profile and the environment.
+type netContext struct {
+	ConnectionProfile  string `json:"connectionProfile,omitempty"`
+	Org                string `json:"org,omitempty"`
+	PeerEndpoint       string `json:"peerEndpoint,omitempty"`
+	ServerNameOverride string `json:"serverNameOverride,omitempty"`
+	TLSCACert          string `json:"tlsCACert,omitempty"`
+	TLSClientCert      string `json:"tlsClientCert,omitempty"`
+	TLSClientKey       string `json:"tlsClientKey,omitempty"`
+	MSPID              string `json:"mspId,omitempty"`
+	Cert               string `json:"cert,omitempty"`
+	Key                string `json:"key,omitempty"`
+	Wallet             string `json:"wallet,omitempty"`
+	Identity           string `json:"identity,omitempty"`
+	Channel            string `json:"channel,omitempty"`
+	Chaincode          string `json:"chaincode,omitempty"`
+}
+
+// apply sets the fields of cfg the context sets
+func (nc *netContext) apply(cfg *client.Config) {
+	set := func(field *string, value string) {
+		if value != "" {
+			*field = value
+		}
+	}
+	set(&cfg.PeerEndpoint, nc.PeerEndpoint)
+	set(&cfg.ServerNameOverride, nc.ServerNameOverride)
+	set(&cfg.TLSCACertPath, nc.TLSCACert)
+	set(&cfg.MSPID, nc.MSPID)
+	set(&cfg.CertPath, nc.Cert)
+	set(&cfg.KeyPath, nc.Key)
+	set(&cfg.WalletPath, nc.Wallet)
+	set(&cfg.IdentityLabel, nc.Identity)
+	set(&cfg.ChannelName, nc.Channel)
+	set(&cfg.ChaincodeName, nc.Chaincode)
+	if nc.TLSClientCert != "" || nc.TLSClientKey != "" {
+		cfg.TLSClientCert = &client.TLSClientCertificate{CertPath: nc.TLSClientCert, KeyPath: nc.TLSClientKey}
+	}
+}
+
+// configPath returns the path of the configuration file
+func configPath() (string, error) {
+	dir := os.Getenv(envHome)
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to find the configuration directory: %v", err)
+		}
+		dir = filepath.Join(home, ".fablog")
+	}
+	return filepath.Join(dir, configFile), nil
+}
+
+// loadConfig reads the configuration file, which is empty when there is none
+func loadConfig() (*config, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &config{Contexts: make(map[string]*netContext)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration: %v", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration %s: %v", path, err)
+	}
+	if cfg.Contexts == nil {
+		cfg.Contexts = make(map[string]*netContext)
+	}
+	return cfg, nil
+}
+
+// save writes the configuration file, replacing it in one step
+func (cfg *config) save() error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create configuration directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	temp := path + ".tmp"
+	if err := os.WriteFile(temp, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write configuration: %v", err)
+	}
+	if err := os.Rename(temp, path); err != nil {
+		os.Remove(temp)
+		return fmt.Errorf("failed to write configuration: %v", err)
+	}
+	return nil
+}
+
+// selectContext returns the context named name, FABLOG_CONTEXT or the current context, in that
+// order, or nil when none is selected
+func selectContext(name string) (*netContext, error) {
+	if name == "" {
+		name = os.Getenv(envContext)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = cfg.Current
+	}
+	if name == "" {
+		return nil, nil
+	}
+
+	nc, ok := cfg.Contexts[name]
+	if !ok {
+		return nil, fmt.Errorf("no context named %q; see fablog config list", name)
+	}
+	return nc, nil
+}
+
+// configCommands are the subcommands of fablog config
+var configCommands []*command
+
+func init() {
+	configCommands = []*command{
+		{name: "config set", args: "<name>", summary: "create a context, or change the settings given of an existing one", run: runConfigSet},
+		{name: "config use", args: "<name>", summary: "make a context the current one", run: runConfigUse},
+		{name: "config list", summary: "list the contexts, marking the current one", run: runConfigList},
+		{name: "config show", args: "[name]", summary: "print a context, the current one by default, as JSON", run: runConfigShow},
+		{name: "config delete", args: "<name>", summary: "delete a context", run: runConfigDelete},
+	}
+}
+
+func runConfig(cmd *command, args []string) error {
+	if len(args) > 0 {
+		for _, sub := range configCommands {
+			if sub.name == cmd.name+" "+args[0] {
+				return sub.run(sub, args[1:])
+			}
+		}
+	}
+
+	path, _ := configPath()
+	fmt.Fprintf(os.Stderr, "usage: fablog config <command> [flags] [arguments]\n\n%s, kept in %s\n\ncommands:\n", cmd.summary, path)
+	for _, sub := range configCommands {
+		fmt.Fprintf(os.Stderr, "  %-7s %s\n", sub.name[len(cmd.name)+1:], sub.summary)
+	}
+	return exitStatus(2)
+}
+
+// contextFields maps the flags of fablog config set to the fields of a context
+var contextFields = []struct {
+	flag  string
+	usage string
+	// path marks file paths, kept absolute so the context works from any directory
+	path  bool
+	field func(nc *netContext) *string
+}{
+	{"profile", "connection profile", true, func(nc *netContext) *string { return &nc.ConnectionProfile }},
+	{"org", "organization in the connection profile", false, func(nc *netContext) *string { return &nc.Org }},
+	{"peer", "host:port of the gateway peer", false, func(nc *netContext) *string { return &nc.PeerEndpoint }},
+	{"server-name", "TLS server name of the peer, such as peer0.org1.example.com", false, func(nc *netContext) *string { return &nc.ServerNameOverride }},
+	{"tls-ca", "peer TLS CA certificate", true, func(nc *netContext) *string { return &nc.TLSCACert }},
+	{"tls-cert", "client TLS certificate, for peers requiring mutual TLS", true, func(nc *netContext) *string { return &nc.TLSClientCert }},
+	{"tls-key", "client TLS private key", true, func(nc *netContext) *string { return &nc.TLSClientKey }},
+	{"msp-id", "MSP ID of the identity", false, func(nc *netContext) *string { return &nc.MSPID }},
+	{"cert", "certificate of the identity", true, func(nc *netContext) *string { return &nc.Cert }},
+	{"key", "private key of the identity, or a keystore directory holding one", true, func(nc *netContext) *string { return &nc.Key }},
+	{"wallet", "wallet to take the identity from instead of -cert and -key", true, func(nc *netContext) *string { return &nc.Wallet }},
+	{"identity", "label of the identity in the wallet", false, func(nc *netContext) *string { return &nc.Identity }},
+	{"channel", "channel name", false, func(nc *netContext) *string { return &nc.Channel }},
+	{"chaincode", "chaincode name", false, func(nc *netContext) *string { return &nc.Chaincode }},
+}
+
+func runConfigSet(cmd *command, args []string) error {
+	fs := cmd.flags()
+	var given netContext
+	for _, f := range contextFields {
+		fs.StringVar(f.field(&given), f.flag, "", f.usage+"; an empty value unsets it")
+	}
+	use := fs.Bool("use", false, "also make the context the current one")
+	if err := parseArgs(fs, args, 1); err != nil {
+		return err
+	}
+	name := fs.Arg(0)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	nc, ok := cfg.Contexts[name]
+	if !ok {
+		nc = &netContext{}
+		cfg.Contexts[name] = nc
+	}
+
+	// Only the flags given change the context
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for _, f := range contextFields {
+		if !set[f.flag] {
+			continue
+		}
+		value := *f.field(&given)
+		if f.path && value != "" {
+			if value, err = filepath.Abs(value); err != nil {
+				return err
+			}
+		}
+		*f.field(nc) = value
+	}
+
+	if *use || cfg.Current == "" {
+		cfg.Current = name
+	}
+	return cfg.save()
+}
+
+func runConfigUse(cmd *command, args []string) error {
+	fs := cmd.flags()
+	if err := parseArgs(fs, args, 1); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if _, ok := cfg.Contexts[fs.Arg(0)]; !ok {
+		return fmt.Errorf("no context named %q", fs.Arg(0))
+	}
+	cfg.Current = fs.Arg(0)
+	return cfg.save()
+}
+
+func runConfigList(cmd *command, args []string) error {
+	fs := cmd.flags()
+	out := outputFlags(fs, outputTable)
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if err := out.check(); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(cfg.Contexts))
+	for name := range cfg.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	type listedContext struct {
+		Name    string `json:"name"`
+		Current bool   `json:"current"`
+		*netContext
+	}
+	contexts := make([]listedContext, 0, len(names))
+	for _, name := range names {
+		contexts = append(contexts, listedContext{Name: name, Current: name == cfg.Current, netContext: cfg.Contexts[name]})
+	}
+
+	return out.print(os.Stdout, contexts, func(w io.Writer, wide bool) error {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		if wide {
+			fmt.Fprintln(tw, "CURRENT\tNAME\tPEER\tORG\tCHANNEL\tCHAINCODE\tMSP ID\tIDENTITY\tPROFILE")
+		} else {
+			fmt.Fprintln(tw, "CURRENT\tNAME\tPEER\tORG\tCHANNEL")
+		}
+		for _, c := range contexts {
+			current := ""
+			if c.Current {
+				current = "*"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s", current, c.Name, c.PeerEndpoint, c.Org, c.Channel)
+			if wide {
+				identity := c.Identity
+				if identity == "" {
+					identity = c.Cert
+				}
+				fmt.Fprintf(tw, "\t%s\t%s\t%s\t%s", c.Chaincode, c.MSPID, identity, c.ConnectionProfile)
+			}
+			fmt.Fprintln(tw)
+		}
+		return tw.Flush()
+	})
+}
+
+func runConfigShow(cmd *command, args []string) error {
+	fs := cmd.flags()
+	out := outputFlags(fs, outputJSON)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return exitStatus(2)
+	}
+	if err := out.check(); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	name := fs.Arg(0)
+	if name == "" {
+		name = cfg.Current
+	}
+	nc, ok := cfg.Contexts[name]
+	if !ok {
+		return fmt.Errorf("no context named %q", name)
+	}
+
+	return out.print(os.Stdout, map[string]*netContext{name: nc}, func(w io.Writer, wide bool) error {
+		// The settings the context has, named as the flags of fablog config set
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "NAME\t%s\n", name)
+		for _, f := range contextFields {
+			if value := *f.field(nc); value != "" {
+				fmt.Fprintf(tw, "%s\t%s\n", strings.ToUpper(f.flag), value)
+			}
+		}
+		return tw.Flush()
+	})
+}
+
+func runConfigDelete(cmd *command, args []string) error {
+	fs := cmd.flags()
+	if err := parseArgs(fs, args, 1); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	name := fs.Arg(0)
+	if _, ok := cfg.Contexts[name]; !ok {
+		return fmt.Errorf("no context named %q", name)
+	}
+	delete(cfg.Contexts, name)
+	if cfg.Current == name {
+		cfg.Current = ""
+	}
+	return cfg.save()
+}
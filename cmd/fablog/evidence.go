@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/evidence"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/report"
+)
+
+func runExportEvidence(cmd *command, args []string) error {
+	fs := cmd.flags()
+	conn := connectionFlags(fs, 0)
+	idsPath := fs.String("ids", "", "file listing the log IDs, one per line, or - for standard input, besides those given as arguments")
+	receipts := fs.String("receipts", "", "receipt file of the client that submitted the logs, locating their transactions without scanning blocks")
+	fromBlock := fs.Uint64("from-block", 0, "first block scanned for the transactions of logs without receipts")
+	out := fs.String("out", "", "zip file to write; required")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return fmt.Errorf("-out is required")
+	}
+
+	ids := fs.Args()
+	if *idsPath != "" {
+		listed, err := readIDs(*idsPath)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, listed...)
+	}
+	if len(ids) == 0 {
+		fs.Usage()
+		return exitStatus(2)
+	}
+
+	var options evidence.Options
+	if *receipts != "" {
+		// NewFileReceiptStore creates missing files, which would hold no receipts
+		if _, err := os.Stat(*receipts); err != nil {
+			return err
+		}
+		store, err := client.NewFileReceiptStore(*receipts)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		options.Receipts = store
+	}
+	options.FromBlock = *fromBlock
+	options.OnBlock = func(number uint64, height uint64) {
+		if number%1000 == 0 {
+			log.Printf("scanning block %d of %d", number, height)
+		}
+	}
+
+	c, ctx, cancel, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	defer cancel()
+
+	pkg, err := evidence.Collect(ctx, c, ids, options)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	id, sign := c.Signer()
+	if err := pkg.Write(f, id, sign); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	log.Printf("packaged %d logs with %d blocks of channel %s to %s", len(pkg.Manifest.Logs), len(pkg.Manifest.Blocks), pkg.Manifest.Channel, *out)
+	return nil
+}
+
+func runVerifyEvidence(cmd *command, args []string) error {
+	fs := cmd.flags()
+	caPath := fs.String("ca", "", "PEM file of the CA certificates the signer must chain to; any signer is accepted when empty")
+	if err := parseArgs(fs, args, 1); err != nil {
+		return err
+	}
+
+	var roots *x509.CertPool
+	if *caPath != "" {
+		caPEM, err := os.ReadFile(*caPath)
+		if err != nil {
+			return err
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(caPEM) {
+			return fmt.Errorf("no certificates found in %s", *caPath)
+		}
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	v, err := evidence.Verify(f, info.Size(), roots)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("signed:  by %s of %s at %s\n", v.Signer.Subject.CommonName, v.Signature.MSPID, v.Signature.SignedAt.Format(time.RFC3339))
+	fmt.Printf("channel: %s\n", v.Manifest.Channel)
+	for _, block := range v.Manifest.Blocks {
+		fmt.Printf("block:   %d, header hash %s\n", block.Number, block.HeaderHash)
+	}
+	for _, entry := range v.Manifest.Logs {
+		fmt.Printf("log:     %s, transaction %s in block %d, %s\n", entry.ID, entry.TransactionID, entry.BlockNumber, entry.ValidationCode)
+	}
+	fmt.Println("result:  PASS")
+	return nil
+}
+
+func runCustody(cmd *command, args []string) error {
+	fs := cmd.flags()
+	conn := connectionFlags(fs, 0)
+	logID := fs.String("log", "", "ID of the log to trace")
+	resource := fs.String("resource", "", "resource whose logs to trace, instead of -log")
+	fromBlock := fs.Uint64("from-block", 0, "first block read; steps committed before it are not covered")
+	format := fs.String("format", "html", "document format: html or json")
+	out := fs.String("out", "", "file to write, signed in <file>"+report.SignatureSuffix+"; required")
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if (*logID == "") == (*resource == "") {
+		return fmt.Errorf("one of -log and -resource is required")
+	}
+	if *format != "html" && *format != "json" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	if *out == "" {
+		return fmt.Errorf("-out is required")
+	}
+
+	c, ctx, cancel, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	defer cancel()
+
+	custody, err := evidence.CollectCustody(ctx, c, evidence.CustodyOptions{
+		LogID:     *logID,
+		Resource:  *resource,
+		FromBlock: *fromBlock,
+		OnBlock: func(number uint64, height uint64) {
+			if number%1000 == 0 {
+				log.Printf("reading block %d of %d", number, height)
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	var rendered bytes.Buffer
+	if *format == "json" {
+		encoder := json.NewEncoder(&rendered)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(custody)
+	} else {
+		err = evidence.RenderCustodyHTML(&rendered, custody)
+	}
+	if err != nil {
+		return err
+	}
+	id, sign := c.Signer()
+	signature, err := report.Sign(filepath.Base(*out), rendered.Bytes(), id, sign)
+	if err != nil {
+		return err
+	}
+	signatureJSON, err := json.MarshalIndent(signature, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out, rendered.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out+report.SignatureSuffix, signatureJSON, 0644); err != nil {
+		return err
+	}
+	log.Printf("wrote %d steps of %d logs from blocks %d to %d to %s", len(custody.Steps), len(custody.Logs), custody.FromBlock, custody.ToBlock, *out)
+	return nil
+}
+
+// readIDs reads the log IDs listed one per line in the file at path, or standard input for -
+func readIDs(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var ids []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if id := strings.TrimSpace(scanner.Text()); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids, scanner.Err()
+}
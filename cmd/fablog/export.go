@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/export"
+)
+
+// queryPageSize is the number of logs read per query page by commands reading every matching log
+const queryPageSize = 200
+
+// exportManifest describes an exported file, written next to it as <file>.manifest.json
+type exportManifest struct {
+	File      string           `json:"file"`
+	Format    export.Format    `json:"format"`
+	CreatedAt time.Time        `json:"createdAt"`
+	Filter    client.LogFilter `json:"filter"`
+	Severity  string           `json:"severity,omitempty"`
+	Tag       string           `json:"tag,omitempty"`
+	Records   int              `json:"records"`
+	// SHA256 is the hash of the file's contents
+	SHA256 string `json:"sha256"`
+}
+
+func runExport(cmd *command, args []string) error {
+	fs := cmd.flags()
+	conn := connectionFlags(fs, 0)
+	filters := queryFlags(fs, true)
+	format := fs.String("format", string(export.FormatNDJSON), "file format: csv, ndjson or parquet")
+	out := fs.String("out", "", "file to write; required")
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if *out == "" {
+		return fmt.Errorf("-out is required")
+	}
+
+	query, err := filters.query()
+	if err != nil {
+		return err
+	}
+	filter, _ := query.Filter()
+	if _, err := export.NewWriter(export.Format(*format), io.Discard, export.DefaultColumns); err != nil {
+		return err
+	}
+
+	c, ctx, cancel, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	defer cancel()
+
+	manifest := exportManifest{
+		File:      filepath.Base(*out),
+		Format:    export.Format(*format),
+		CreatedAt: time.Now().UTC(),
+		Filter:    filter,
+		Severity:  filters.severity,
+		Tag:       filters.tag,
+	}
+	progress := newProgress(os.Stderr)
+	source := &matchingSource{it: c.IterateLogs(filter, queryPageSize), query: query}
+	if manifest.Records, manifest.SHA256, err = exportFile(ctx, source, *out, manifest.Format, progress.update); err != nil {
+		progress.done()
+		return err
+	}
+	progress.done()
+
+	manifestPath, err := writeManifest(*out, manifest)
+	if err != nil {
+		return err
+	}
+	log.Printf("exported %d logs to %s, sha256 %s", manifest.Records, *out, manifest.SHA256)
+	fmt.Println(manifestPath)
+	return nil
+}
+
+// writeManifest writes manifest next to the exported file at path and returns its path
+func writeManifest(path string, manifest exportManifest) (string, error) {
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	manifestPath := path + ".manifest.json"
+	if err := os.WriteFile(manifestPath, append(manifestJSON, '\n'), 0600); err != nil {
+		return "", fmt.Errorf("failed to write manifest: %v", err)
+	}
+	return manifestPath, nil
+}
+
+// exportFile writes the logs of source to path in format, returning how many were written and the
+// SHA-256 of the file. The file is written under a temporary name and renamed once complete, so a
+// failed export leaves no partial file behind.
+func exportFile(ctx context.Context, source export.Source, path string, format export.Format, progress func(records int)) (int, string, error) {
+	dir, name := filepath.Split(path)
+	file, err := os.CreateTemp(dir, "."+name+".*.tmp")
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create export file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	digest := sha256.New()
+	writer, err := export.NewWriter(format, io.MultiWriter(file, digest), export.DefaultColumns)
+	if err != nil {
+		return 0, "", err
+	}
+
+	records := 0
+	for {
+		log, err := source.Next(ctx)
+		if errors.Is(err, client.ErrIteratorDone) {
+			break
+		}
+		if err != nil {
+			return records, "", fmt.Errorf("failed to read logs after %d records: %v", records, err)
+		}
+		if err := writer.Write(log); err != nil {
+			return records, "", fmt.Errorf("failed to write log %s: %v", log.ID, err)
+		}
+		records++
+		progress(records)
+	}
+
+	if err := writer.Close(); err != nil {
+		return records, "", fmt.Errorf("failed to write export file: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return records, "", fmt.Errorf("failed to write export file: %v", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return records, "", fmt.Errorf("failed to write export file: %v", err)
+	}
+	return records, fmt.Sprintf("%x", digest.Sum(nil)), nil
+}
+
+// matchingSource yields the logs of an iterator that match the client-side criteria of a query
+type matchingSource struct {
+	it    *client.LogIterator
+	query *client.LogQuery
+}
+
+func (s *matchingSource) Next(ctx context.Context) (*client.LogEvent, error) {
+	for {
+		log, err := s.it.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if s.query.Matches(log) {
+			return log, nil
+		}
+	}
+}
+
+// progressInterval is how often the progress line is redrawn
+const progressInterval = 200 * time.Millisecond
+
+// progress draws a progress line on a terminal. The number of matching logs is not known up front,
+// so it shows the records written and the rate rather than a percentage.
+type progress struct {
+	w       *os.File
+	enabled bool
+	start   time.Time
+	last    time.Time
+	records int
+}
+
+// newProgress returns a progress line drawn on w, or nothing when w is not a terminal
+func newProgress(w *os.File) *progress {
+	info, err := w.Stat()
+	enabled := err == nil && info.Mode()&os.ModeCharDevice != 0
+	return &progress{w: w, enabled: enabled, start: time.Now()}
+}
+
+// update records the number of records written so far
+func (p *progress) update(records int) {
+	p.records = records
+	if now := time.Now(); p.enabled && now.Sub(p.last) >= progressInterval {
+		p.last = now
+		p.draw(now)
+	}
+}
+
+// done draws the final count and ends the line
+func (p *progress) done() {
+	if p.enabled {
+		p.draw(time.Now())
+		fmt.Fprintln(p.w)
+	}
+}
+
+func (p *progress) draw(now time.Time) {
+	rate := float64(p.records) / now.Sub(p.start).Seconds()
+	fmt.Fprintf(p.w, "\r%d logs exported, %.0f/s  ", p.records, rate)
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+func runCreate(cmd *command, args []string) error {
+	fs := cmd.flags()
+	conn := connectionFlags(fs, defaultTimeout)
+	var event client.LogEvent
+	fs.StringVar(&event.ID, "id", "", "ID of the log; a UUIDv7 is generated when empty")
+	fs.StringVar(&event.UserID, "user", "", "user the log is about")
+	fs.StringVar(&event.Action, "action", "", "action the user took, such as LOGIN")
+	fs.StringVar(&event.Resource, "resource", "", "resource the action concerned")
+	fs.StringVar(&event.Description, "description", "", "free-form description")
+	fs.StringVar(&event.Metadata, "metadata", "", "JSON object of further details, such as {\"level\":\"WARN\"}")
+	chain := hashChainFlag(fs)
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	if event.ID == "" {
+		id, err := client.UUIDv7()(event)
+		if err != nil {
+			return err
+		}
+		event.ID = id
+	}
+
+	opts, closeChain, err := openHashChain(*chain)
+	if err != nil {
+		return err
+	}
+	defer closeChain()
+
+	c, ctx, cancel, err := conn.connect(opts...)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	defer cancel()
+
+	result, err := c.SubmitLog(ctx, event)
+	if err != nil {
+		return fmt.Errorf("failed to create log: %v", err)
+	}
+	log.Printf("transaction %s committed in block %d", result.TransactionID, result.BlockNumber)
+	fmt.Println(event.ID)
+	return nil
+}
+
+func runGet(cmd *command, args []string) error {
+	fs := cmd.flags()
+	conn := connectionFlags(fs, defaultTimeout)
+	out := outputFlags(fs, outputJSON)
+	if err := parseArgs(fs, args, 1); err != nil {
+		return err
+	}
+	if err := out.check(); err != nil {
+		return err
+	}
+
+	c, ctx, cancel, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	defer cancel()
+
+	event, err := c.ReadLog(ctx, fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to read log: %v", err)
+	}
+	return out.print(os.Stdout, event, func(w io.Writer, wide bool) error {
+		return printLogs(w, []*client.LogEvent{event}, wide)
+	})
+}
+
+func runExists(cmd *command, args []string) error {
+	fs := cmd.flags()
+	conn := connectionFlags(fs, defaultTimeout)
+	quiet := fs.Bool("q", false, "print nothing; only the exit status tells")
+	out := outputFlags(fs, outputTable)
+	if err := parseArgs(fs, args, 1); err != nil {
+		return err
+	}
+	if err := out.check(); err != nil {
+		return err
+	}
+
+	c, ctx, cancel, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	defer cancel()
+
+	exists, err := c.LogExists(ctx, fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to check log: %v", err)
+	}
+	if !*quiet {
+		result := struct {
+			ID     string `json:"id"`
+			Exists bool   `json:"exists"`
+		}{fs.Arg(0), exists}
+		err := out.print(os.Stdout, result, func(w io.Writer, wide bool) error {
+			_, err := fmt.Fprintln(w, exists)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if !exists {
+		return exitStatus(1)
+	}
+	return nil
+}
+
+func runQuery(cmd *command, args []string) error {
+	fs := cmd.flags()
+	conn := connectionFlags(fs, defaultTimeout)
+	filters := queryFlags(fs, true)
+	limit := fs.Int("limit", 100, "most logs to print; all of them when zero")
+	out := outputFlags(fs, outputTable)
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if err := out.check(); err != nil {
+		return err
+	}
+
+	query, err := filters.query()
+	if err != nil {
+		return err
+	}
+	query.Limit(*limit)
+
+	c, ctx, cancel, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	defer cancel()
+
+	logs, err := query.Run(ctx, c)
+	if err != nil {
+		return fmt.Errorf("failed to query logs: %v", err)
+	}
+	if logs == nil {
+		// An empty list rather than null
+		logs = []*client.LogEvent{}
+	}
+	return out.print(os.Stdout, logs, func(w io.Writer, wide bool) error {
+		return printLogs(w, logs, wide)
+	})
+}
+
+// filterFlags holds the flags selecting logs
+type filterFlags struct {
+	user, action, resource string
+	from, to               string
+	severity, tag          string
+}
+
+// queryFlags adds the flags selecting logs to fs, with -from and -to when timeRange is set
+func queryFlags(fs *flag.FlagSet, timeRange bool) *filterFlags {
+	f := &filterFlags{}
+	fs.StringVar(&f.user, "user", "", "only logs of this user")
+	fs.StringVar(&f.action, "action", "", "only logs of this action")
+	fs.StringVar(&f.resource, "resource", "", "only logs of this resource")
+	if timeRange {
+		fs.StringVar(&f.from, "from", "", "only logs at or after this RFC 3339 time")
+		fs.StringVar(&f.to, "to", "", "only logs at or before this RFC 3339 time")
+	}
+	fs.StringVar(&f.severity, "severity", "", "only logs at least this severe, such as WARN")
+	fs.StringVar(&f.tag, "tag", "", "only logs carrying this tag")
+	return f
+}
+
+// query returns the query the flags select
+func (f *filterFlags) query() (*client.LogQuery, error) {
+	query := client.Query().User(f.user).Action(f.action).Resource(f.resource)
+	if f.from != "" {
+		t, err := time.Parse(time.RFC3339, f.from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -from: %v", err)
+		}
+		query.Since(t)
+	}
+	if f.to != "" {
+		t, err := time.Parse(time.RFC3339, f.to)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -to: %v", err)
+		}
+		query.Until(t)
+	}
+	if f.severity != "" {
+		query.Severity(">=", f.severity)
+	}
+	if f.tag != "" {
+		query.Tag(f.tag)
+	}
+	if _, err := query.Filter(); err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+// printLogs writes logs as a table, one log per row, with the collection and metadata when wide
+// is set
+func printLogs(w io.Writer, logs []*client.LogEvent, wide bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if wide {
+		fmt.Fprintln(tw, "ID\tTIMESTAMP\tUSER\tACTION\tRESOURCE\tDESCRIPTION\tCOLLECTION\tMETADATA")
+	} else {
+		fmt.Fprintln(tw, "ID\tTIMESTAMP\tUSER\tACTION\tRESOURCE\tDESCRIPTION")
+	}
+	for _, event := range logs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s", event.ID, event.Timestamp, event.UserID, event.Action, event.Resource, event.Description)
+		if wide {
+			fmt.Fprintf(tw, "\t%s\t%s", event.Collection, event.Metadata)
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+func runBatch(cmd *command, args []string) error {
+	fs := cmd.flags()
+	conn := connectionFlags(fs, defaultTimeout)
+	file := fs.String("file", "-", "JSON array or NDJSON file of logs, or - for standard input")
+	size := fs.Int("size", client.DefaultMaxBatchSize, "most logs per transaction")
+	chain := hashChainFlag(fs)
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if *size < 1 {
+		return fmt.Errorf("invalid -size %d", *size)
+	}
+
+	in := os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	logs, err := readLogs(in)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", *file, err)
+	}
+	generate := client.UUIDv7()
+	for i := range logs {
+		if logs[i].ID == "" {
+			if logs[i].ID, err = generate(logs[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	opts, closeChain, err := openHashChain(*chain)
+	if err != nil {
+		return err
+	}
+	defer closeChain()
+
+	c, ctx, cancel, err := conn.connect(opts...)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	defer cancel()
+
+	for start := 0; start < len(logs); start += *size {
+		end := start + *size
+		if end > len(logs) {
+			end = len(logs)
+		}
+		result, err := c.SubmitLogsBatch(ctx, logs[start:end])
+		if err != nil {
+			// The batches before it are recorded, so say where to resume
+			return fmt.Errorf("failed to record logs %d to %d: %v", start+1, end, err)
+		}
+		log.Printf("recorded logs %d to %d in transaction %s, block %d", start+1, end, result.TransactionID, result.BlockNumber)
+	}
+	for _, event := range logs {
+		fmt.Println(event.ID)
+	}
+	return nil
+}
+
+// readLogs decodes a JSON array of logs or a stream of JSON logs, such as NDJSON
+func readLogs(r io.Reader) ([]client.LogEvent, error) {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if b == ' ' || b == '\t' || b == '\r' || b == '\n' {
+			continue
+		}
+		br.UnreadByte()
+		if b == '[' {
+			var logs []client.LogEvent
+			if err := json.NewDecoder(br).Decode(&logs); err != nil {
+				return nil, err
+			}
+			return logs, nil
+		}
+		break
+	}
+
+	var logs []client.LogEvent
+	decoder := json.NewDecoder(br)
+	for {
+		var event client.LogEvent
+		err := decoder.Decode(&event)
+		if errors.Is(err, io.EOF) {
+			return logs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("log %d: %v", len(logs)+1, err)
+		}
+		logs = append(logs, event)
+	}
+}
@@ -0,0 +1,226 @@
+// Command fablog records and queries the logs of the logging chaincode from a terminal. Like
+// cmd/api it connects to the Fabric Gateway described by a connection profile and the usual
+// environment variables (see client.LoadConfig).
+//
+// Usage:
+//
+//	fablog <command> [flags] [arguments]
+//
+// Run fablog help for the list of commands, and fablog <command> -h for their flags.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// command is a fablog subcommand
+type command struct {
+	name string
+	// args describes the arguments following the flags
+	args    string
+	summary string
+	run     func(cmd *command, args []string) error
+}
+
+// commands lists the subcommands in the order fablog help shows them
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{name: "create", summary: "record a log", run: runCreate},
+		{name: "get", args: "<id>", summary: "print a log as JSON", run: runGet},
+		{name: "exists", args: "<id>", summary: "report whether a log exists, exiting with status 1 when it does not", run: runExists},
+		{name: "query", summary: "list the logs matching a filter", run: runQuery},
+		{name: "tail", summary: "print the latest logs matching a filter, and with -follow those committed next", run: runTail},
+		{name: "export", summary: "write the logs matching a filter to a CSV, NDJSON or Parquet file with a manifest", run: runExport},
+		{name: "stats", summary: "count the logs matching a filter per action and per user", run: runStats},
+		{name: "ui", summary: "browse the logs matching a filter in a terminal UI, updated as they are committed", run: runUI},
+		{name: "verify", summary: "check a client's hash chain of submitted logs against the ledger", run: runVerify},
+		{name: "export-evidence", args: "[id...]", summary: "package logs with the blocks that committed them in a signed zip a third party can verify offline", run: runExportEvidence},
+		{name: "custody", summary: "write the signed chain of custody of a log or of the logs of a resource, with the transaction and block of each step", run: runCustody},
+		{name: "verify-evidence", args: "<package>", summary: "check an evidence package offline, without connecting to the network", run: runVerifyEvidence},
+		{name: "prune", summary: "delete the logs recorded before a date, for retention", run: runPrune},
+		{name: "archive", summary: "write the logs recorded before a date to a file, then delete them", run: runArchive},
+		{name: "policy", summary: "print the retention policy recorded on the ledger, or set it with -max-age-days", run: runPolicy},
+		{name: "admin", summary: "read and change the settings the chaincode records on the ledger, with dry runs and differences", run: runAdmin},
+		{name: "batch", summary: "record the logs of a JSON array or NDJSON file in batched transactions", run: runBatch},
+		{name: "replay", summary: "submit exported logs again, to another network or channel, keeping their original timestamps in metadata", run: runReplay},
+		{name: "migrate", summary: "move the logs earlier chaincode versions stored under bare keys into the LOG namespace", run: runMigrate},
+		{name: "config", summary: "manage named contexts of connection settings", run: runConfig},
+	}
+}
+
+// exitStatus ends fablog with a status and no message
+type exitStatus int
+
+func (s exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(s))
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("fablog: ")
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	name := os.Args[1]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		usage()
+		return
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		err := cmd.run(cmd, os.Args[2:])
+		var status exitStatus
+		if errors.As(err, &status) {
+			os.Exit(int(status))
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	log.Printf("unknown command %q", name)
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: fablog <command> [flags] [arguments]\n\ncommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+}
+
+// flags returns the flag set of cmd
+func (cmd *command) flags() *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: fablog %s [flags]", cmd.name)
+		if cmd.args != "" {
+			fmt.Fprintf(os.Stderr, " %s", cmd.args)
+		}
+		fmt.Fprintf(os.Stderr, "\n\n%s\n\nflags:\n", cmd.summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args into fs, accepting flags after the arguments as well as before them
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	// Parsing only a terminator leaves the arguments in fs
+	return fs.Parse(append([]string{"--"}, positional...))
+}
+
+// parseArgs parses args into fs and checks that n arguments remain
+func parseArgs(fs *flag.FlagSet, args []string, n int) error {
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != n {
+		fs.Usage()
+		return exitStatus(2)
+	}
+	return nil
+}
+
+// defaultTimeout is how long most commands may take
+const defaultTimeout = 30 * time.Second
+
+// connection holds the flags selecting the gateway a command connects to
+type connection struct {
+	context string
+	profile string
+	org     string
+	timeout time.Duration
+}
+
+// connectionFlags adds the connection flags to fs, with timeout as the default -timeout
+func connectionFlags(fs *flag.FlagSet, timeout time.Duration) *connection {
+	conn := &connection{}
+	fs.StringVar(&conn.context, "context", "", "context of fablog config to connect with; FABLOG_CONTEXT or the current context is used when empty")
+	fs.StringVar(&conn.profile, "profile", "", "connection profile; the context's or CONNECTION_PROFILE_PATH is used when empty")
+	fs.StringVar(&conn.org, "org", "", "organization in the connection profile; the context's or ORG is used when empty")
+	fs.DurationVar(&conn.timeout, "timeout", timeout, "how long the command may take; no limit when zero")
+	return conn
+}
+
+// config builds the client configuration. The context's settings take precedence over the
+// environment, and -profile and -org over the context's connection profile and organization.
+func (conn *connection) config() (client.Config, error) {
+	nc, err := selectContext(conn.context)
+	if err != nil {
+		return client.Config{}, err
+	}
+	profile, org := conn.profile, conn.org
+	if nc != nil {
+		if profile == "" {
+			profile = nc.ConnectionProfile
+		}
+		if org == "" {
+			org = nc.Org
+		}
+	}
+
+	cfg, err := client.LoadConfig(profile, org)
+	if err != nil {
+		return client.Config{}, fmt.Errorf("failed to load configuration: %v", err)
+	}
+	if nc != nil {
+		nc.apply(&cfg)
+	}
+	return cfg, nil
+}
+
+// connect connects to the gateway and returns a context ending on timeout or interrupt
+func (conn *connection) connect(opts ...client.Option) (*client.Client, context.Context, context.CancelFunc, error) {
+	cfg, err := conn.config()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	c, err := client.Connect(cfg, opts...)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to connect to the gateway: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	cancel := stop
+	if conn.timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, conn.timeout)
+		cancel = func() {
+			cancelTimeout()
+			stop()
+		}
+	}
+	return c, ctx, cancel, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// runMigrate moves the logs stored under bare keys by earlier versions of the chaincode into the
+// LOG namespace, a transaction of -batch-size logs at a time, until none are left
+func runMigrate(cmd *command, args []string) error {
+	fs := cmd.flags()
+	conn := connectionFlags(fs, 0)
+	batchSize := fs.Int("batch-size", 100, "most logs moved in one transaction")
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if *batchSize < 1 {
+		return fmt.Errorf("-batch-size must be at least 1")
+	}
+
+	c, ctx, cancel, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	defer cancel()
+
+	total := 0
+	for {
+		migrated, result, err := c.MigrateLegacyLogs(ctx, *batchSize)
+		if errors.Is(err, client.ErrUnauthorized) {
+			return fmt.Errorf("migrating requires an identity holding the logging.restore attribute: %v", err)
+		}
+		if err != nil {
+			// The transactions before it are committed, and running the command again resumes
+			return fmt.Errorf("failed to migrate logs after %d: %v", total, err)
+		}
+		total += migrated
+		if migrated > 0 {
+			log.Printf("migrated %d logs in transaction %s, block %d", migrated, result.TransactionID, result.BlockNumber)
+		}
+		// The chaincode moves fewer logs than asked only once it has read every bare key
+		if migrated < *batchSize {
+			break
+		}
+	}
+	fmt.Println(total)
+	return nil
+}
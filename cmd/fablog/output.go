@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Output formats of the commands reading logs
+const (
+	outputTable = "table"
+	// outputWide is the table with more columns
+	outputWide = "wide"
+	outputJSON = "json"
+	outputYAML = "yaml"
+)
+
+// output holds the flags choosing how a command prints what it read
+type output struct {
+	format   string
+	jsonpath string
+	template string
+
+	path jsonPath
+	tmpl *template.Template
+}
+
+// outputFlags adds -output, -o, -jsonpath and -template to fs, with format as the default -output
+func outputFlags(fs *flag.FlagSet, format string) *output {
+	o := &output{}
+	fs.StringVar(&o.format, "output", format, "output format: table, wide, json or yaml")
+	fs.StringVar(&o.format, "o", format, "shorthand for -output")
+	fs.StringVar(&o.jsonpath, "jsonpath", "", "print the values at this JSONPath of the JSON output, one per line, such as {[*].id}")
+	fs.StringVar(&o.template, "template", "", "print the JSON output through this Go template, such as '{{.userId}} {{.action}}'")
+	return o
+}
+
+// check validates the flags once parsed
+func (o *output) check() error {
+	switch o.format {
+	case outputTable, outputWide, outputJSON, outputYAML:
+	default:
+		return fmt.Errorf("invalid -output %q; use table, wide, json or yaml", o.format)
+	}
+	if o.jsonpath != "" && o.template != "" {
+		return fmt.Errorf("-jsonpath and -template cannot be used together")
+	}
+
+	var err error
+	if o.jsonpath != "" {
+		if o.path, err = parseJSONPath(o.jsonpath); err != nil {
+			return fmt.Errorf("invalid -jsonpath: %v", err)
+		}
+	}
+	if o.template != "" {
+		if o.tmpl, err = template.New("output").Parse(o.template); err != nil {
+			return fmt.Errorf("invalid -template: %v", err)
+		}
+	}
+	return nil
+}
+
+// print writes v in the chosen format. table writes the table form, with more columns when wide
+// is set.
+func (o *output) print(w io.Writer, v interface{}, table func(w io.Writer, wide bool) error) error {
+	return o.write(w, v, table, false)
+}
+
+// printItem writes one of a stream of values, as commands printing values as they arrive do: JSON
+// on one line each, and YAML as separate documents
+func (o *output) printItem(w io.Writer, v interface{}, table func(w io.Writer, wide bool) error) error {
+	return o.write(w, v, table, true)
+}
+
+func (o *output) write(w io.Writer, v interface{}, table func(w io.Writer, wide bool) error, stream bool) error {
+	switch {
+	case o.path != nil:
+		data, err := jsonValue(v)
+		if err != nil {
+			return err
+		}
+		for _, value := range o.path.eval(data) {
+			if s, ok := value.(string); ok {
+				fmt.Fprintln(w, s)
+				continue
+			}
+			line, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(w, string(line))
+		}
+		return nil
+
+	case o.tmpl != nil:
+		data, err := jsonValue(v)
+		if err != nil {
+			return err
+		}
+		var out strings.Builder
+		if err := o.tmpl.Execute(&out, data); err != nil {
+			return fmt.Errorf("failed to apply -template: %v", err)
+		}
+		// Templates need not end their output with a newline
+		if s := out.String(); s != "" && !strings.HasSuffix(s, "\n") {
+			out.WriteString("\n")
+		}
+		_, err = io.WriteString(w, out.String())
+		return err
+
+	case o.format == outputJSON:
+		encoder := json.NewEncoder(w)
+		if !stream {
+			encoder.SetIndent("", "  ")
+		}
+		return encoder.Encode(v)
+
+	case o.format == outputYAML:
+		// Through JSON, so keys are named as in the JSON output
+		data, err := jsonValue(v)
+		if err != nil {
+			return err
+		}
+		doc, err := yaml.Marshal(data)
+		if err != nil {
+			return err
+		}
+		if stream {
+			io.WriteString(w, "---\n")
+		}
+		_, err = w.Write(doc)
+		return err
+	}
+	return table(w, o.format == outputWide)
+}
+
+// jsonValue returns v as decoded from its JSON form: maps, slices, strings, numbers and booleans
+func jsonValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// pathStep is one step of a JSONPath: a member, an array index, or every member or element
+type pathStep struct {
+	field   string
+	index   int
+	isIndex bool
+	all     bool
+}
+
+// jsonPath is a parsed JSONPath
+type jsonPath []pathStep
+
+// parseJSONPath parses the JSONPath subset fablog supports: members as .name or ['name'], array
+// indexes as [0] or [-1] from the end, and every member or element as .* or [*]. The path may be
+// wrapped in braces, as kubectl writes it, and start with $.
+func parseJSONPath(expr string) (jsonPath, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "{") && strings.HasSuffix(expr, "}") {
+		expr = strings.TrimSpace(expr[1 : len(expr)-1])
+	}
+	expr = strings.TrimPrefix(expr, "$")
+
+	path := jsonPath{}
+	for expr != "" {
+		switch expr[0] {
+		case '.':
+			expr = expr[1:]
+			end := strings.IndexAny(expr, ".[")
+			if end < 0 {
+				end = len(expr)
+			}
+			name := expr[:end]
+			expr = expr[end:]
+			switch {
+			case name == "" && (expr == "" || expr[0] == '['):
+				// A dot alone, or before an index, is the whole value
+			case name == "":
+				return nil, fmt.Errorf("recursive descent (..) is not supported")
+			case name == "*":
+				path = append(path, pathStep{all: true})
+			default:
+				path = append(path, pathStep{field: name})
+			}
+
+		case '[':
+			end := strings.IndexByte(expr, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("missing ] in %q", expr)
+			}
+			inner := strings.TrimSpace(expr[1:end])
+			expr = expr[end+1:]
+			switch {
+			case inner == "*":
+				path = append(path, pathStep{all: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				path = append(path, pathStep{field: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index [%s]", inner)
+				}
+				path = append(path, pathStep{index: index, isIndex: true})
+			}
+
+		default:
+			return nil, fmt.Errorf("unexpected %q; steps start with . or [", expr)
+		}
+	}
+	return path, nil
+}
+
+// eval returns the values the path selects in value. Steps selecting nothing, such as a missing
+// member, yield no values rather than an error.
+func (path jsonPath) eval(value interface{}) []interface{} {
+	values := []interface{}{value}
+	for _, step := range path {
+		var next []interface{}
+		for _, value := range values {
+			switch value := value.(type) {
+			case map[string]interface{}:
+				if step.all {
+					keys := make([]string, 0, len(value))
+					for key := range value {
+						keys = append(keys, key)
+					}
+					sort.Strings(keys)
+					for _, key := range keys {
+						next = append(next, value[key])
+					}
+				} else if member, ok := value[step.field]; ok && !step.isIndex {
+					next = append(next, member)
+				}
+			case []interface{}:
+				if step.all {
+					next = append(next, value...)
+				} else if step.isIndex {
+					index := step.index
+					if index < 0 {
+						index += len(value)
+					}
+					if index >= 0 && index < len(value) {
+						next = append(next, value[index])
+					}
+				}
+			}
+		}
+		values = next
+	}
+	return values
+}
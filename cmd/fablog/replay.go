@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// replayKey is the metadata field in which replay keeps the original ID and timestamp of a log, as
+// the chaincode stamps replayed logs with the time they are committed
+const replayKey = "replayedFrom"
+
+// replayOrigin is the value of the replayKey metadata field
+type replayOrigin struct {
+	ID        string `json:"id"`
+	Timestamp string `json:"timestamp"`
+}
+
+func runReplay(cmd *command, args []string) error {
+	fs := cmd.flags()
+	conn := connectionFlags(fs, 0)
+	file := fs.String("file", "-", "exported JSON array or NDJSON file of logs to submit again, - for standard input")
+	size := fs.Int("size", client.DefaultMaxBatchSize, "most logs per transaction")
+	newIDs := fs.Bool("new-ids", false, "give the logs new IDs, for seeding a network more than once, rather than keep their own")
+	chain := hashChainFlag(fs)
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if *size < 1 {
+		return fmt.Errorf("invalid -size %d", *size)
+	}
+
+	r := io.Reader(os.Stdin)
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	exported, err := readLogs(r)
+	if err != nil {
+		return fmt.Errorf("failed to read logs: %v", err)
+	}
+
+	// The export holds only the public fields of private logs, so they cannot be replayed whole
+	var logs []client.LogEvent
+	private := 0
+	for i, event := range exported {
+		if event.Collection != "" {
+			private++
+			continue
+		}
+		replayed, err := replayLog(event, *newIDs)
+		if err != nil {
+			return fmt.Errorf("log %d: %v", i+1, err)
+		}
+		logs = append(logs, replayed)
+	}
+	if private > 0 {
+		log.Printf("skipping %d private logs, whose descriptions and metadata are not exported", private)
+	}
+
+	opts, closeChain, err := openHashChain(*chain)
+	if err != nil {
+		return err
+	}
+	defer closeChain()
+
+	c, ctx, cancel, err := conn.connect(opts...)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	defer cancel()
+
+	// Logs kept under their own IDs may have been replayed by an earlier run; a batch holding one
+	// would be rejected whole, so they are left out and an interrupted replay can be run again
+	if !*newIDs {
+		pending := logs[:0]
+		for _, event := range logs {
+			exists, err := c.LogExists(ctx, event.ID)
+			if err != nil {
+				return fmt.Errorf("failed to check log %s: %v", event.ID, err)
+			}
+			if !exists {
+				pending = append(pending, event)
+			}
+		}
+		if skipped := len(logs) - len(pending); skipped > 0 {
+			log.Printf("skipping %d logs already on the target", skipped)
+		}
+		logs = pending
+	}
+
+	for start := 0; start < len(logs); start += *size {
+		end := start + *size
+		if end > len(logs) {
+			end = len(logs)
+		}
+		result, err := c.SubmitLogsBatch(ctx, logs[start:end])
+		if err != nil {
+			return fmt.Errorf("failed to replay logs %d to %d: %v", start+1, end, err)
+		}
+		log.Printf("replayed logs %d to %d in transaction %s, block %d", start+1, end, result.TransactionID, result.BlockNumber)
+	}
+	for _, event := range logs {
+		fmt.Println(event.ID)
+	}
+	return nil
+}
+
+// replayLog returns the log to submit for an exported log: its fields, with its original ID and
+// timestamp added to its metadata, under a new ID when newID is set
+func replayLog(event client.LogEvent, newID bool) (client.LogEvent, error) {
+	metadata := map[string]interface{}{}
+	if event.Metadata != "" {
+		decoder := json.NewDecoder(bytes.NewReader([]byte(event.Metadata)))
+		// Numbers are kept exactly as written
+		decoder.UseNumber()
+		if err := decoder.Decode(&metadata); err != nil {
+			return event, fmt.Errorf("the metadata of log %s is not a JSON object: %v", event.ID, err)
+		}
+	}
+	// A log replayed again keeps its first origin
+	if _, ok := metadata[replayKey]; !ok {
+		metadata[replayKey] = replayOrigin{ID: event.ID, Timestamp: event.Timestamp}
+	}
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return event, err
+	}
+
+	replayed := client.LogEvent{
+		ID:          event.ID,
+		UserID:      event.UserID,
+		Action:      event.Action,
+		Resource:    event.Resource,
+		Description: event.Description,
+		Metadata:    string(metadataJSON),
+	}
+	if newID || replayed.ID == "" {
+		if replayed.ID, err = client.UUIDv7()(replayed); err != nil {
+			return event, err
+		}
+	}
+	return replayed, nil
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/export"
+)
+
+func runPrune(cmd *command, args []string) error {
+	return runRetention(cmd, args, false)
+}
+
+func runArchive(cmd *command, args []string) error {
+	return runRetention(cmd, args, true)
+}
+
+// runRetention deletes the logs recorded before a date, writing them to a file first when archive
+// is set
+func runRetention(cmd *command, args []string, archive bool) error {
+	fs := cmd.flags()
+	conn := connectionFlags(fs, 0)
+	before := fs.String("before", "", "delete the logs recorded before this date, as 2006-01-02 or an RFC 3339 time; required")
+	dryRun := fs.Bool("dry-run", false, "only count the logs that would be deleted")
+	yes := fs.Bool("yes", false, "delete without asking for confirmation")
+	size := fs.Int("size", client.DefaultMaxBatchSize, "most logs deleted per transaction")
+	var out, format *string
+	if archive {
+		out = fs.String("out", "", "file to write the logs to before deleting them; required")
+		format = fs.String("format", string(export.FormatNDJSON), "file format: csv, ndjson or parquet")
+	}
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if *before == "" {
+		return fmt.Errorf("-before is required")
+	}
+	cutoff, err := parseDate(*before)
+	if err != nil {
+		return fmt.Errorf("invalid -before: %v", err)
+	}
+	if *size < 1 {
+		return fmt.Errorf("invalid -size %d", *size)
+	}
+	if archive {
+		if *out == "" {
+			return fmt.Errorf("-out is required")
+		}
+		if _, err := export.NewWriter(export.Format(*format), io.Discard, export.DefaultColumns); err != nil {
+			return err
+		}
+	}
+
+	c, ctx, cancel, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	defer cancel()
+
+	query := client.Query().Until(cutoff)
+	filter, _ := query.Filter()
+	source := &retentionSource{
+		source: &matchingSource{it: c.IterateLogs(filter, queryPageSize), query: query},
+		cutoff: cutoff,
+	}
+
+	if *dryRun {
+		if err := drain(ctx, source); err != nil {
+			return err
+		}
+		fmt.Println(source.summary())
+		return nil
+	}
+
+	if archive {
+		manifest := exportManifest{
+			File:      filepath.Base(*out),
+			Format:    export.Format(*format),
+			CreatedAt: time.Now().UTC(),
+			Filter:    filter,
+		}
+		progress := newProgress(os.Stderr)
+		manifest.Records, manifest.SHA256, err = exportFile(ctx, source, *out, manifest.Format, progress.update)
+		progress.done()
+		if err != nil {
+			return err
+		}
+		if _, err := writeManifest(*out, manifest); err != nil {
+			return err
+		}
+		log.Printf("archived %d logs to %s, sha256 %s", manifest.Records, *out, manifest.SHA256)
+	} else if err := drain(ctx, source); err != nil {
+		return err
+	}
+
+	if len(source.ids) == 0 {
+		fmt.Println(source.summary())
+		return nil
+	}
+	if !*yes {
+		if err := confirm(fmt.Sprintf("delete %s from the world state?", source.summary())); err != nil {
+			return err
+		}
+	}
+
+	ids := source.ids
+	for start := 0; start < len(ids); start += *size {
+		end := start + *size
+		if end > len(ids) {
+			end = len(ids)
+		}
+		result, err := c.PruneLogs(ctx, cutoff, ids[start:end])
+		if errors.Is(err, client.ErrUnauthorized) {
+			return fmt.Errorf("pruning requires an identity holding the logging.retention attribute: %v", err)
+		}
+		if err != nil {
+			// The batches before it are deleted, and running the command again resumes
+			return fmt.Errorf("failed to prune logs %d to %d: %v", start+1, end, err)
+		}
+		log.Printf("pruned logs %d to %d in transaction %s, block %d", start+1, end, result.TransactionID, result.BlockNumber)
+	}
+	fmt.Println(len(ids))
+	return nil
+}
+
+// runPolicy prints the retention policy, or records a new one when -max-age-days is set
+func runPolicy(cmd *command, args []string) error {
+	fs := cmd.flags()
+	conn := connectionFlags(fs, defaultTimeout)
+	maxAgeDays := fs.Int("max-age-days", 0, "set the policy to keep logs for this many days")
+	archive := fs.Bool("archive", false, "with -max-age-days, ask for logs to be archived before they are pruned")
+	out := outputFlags(fs, outputTable)
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if err := out.check(); err != nil {
+		return err
+	}
+	if *maxAgeDays < 0 || (*archive && *maxAgeDays == 0) {
+		return fmt.Errorf("-max-age-days must be at least 1 to set the policy")
+	}
+
+	c, ctx, cancel, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	defer cancel()
+
+	if *maxAgeDays > 0 {
+		result, err := c.SetRetentionPolicy(ctx, client.RetentionPolicy{MaxAgeDays: *maxAgeDays, Archive: *archive})
+		if errors.Is(err, client.ErrUnauthorized) {
+			return fmt.Errorf("setting the policy requires an identity holding the logging.retention attribute: %v", err)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to set retention policy: %v", err)
+		}
+		log.Printf("set retention policy in transaction %s, block %d", result.TransactionID, result.BlockNumber)
+		return nil
+	}
+
+	policy, err := c.GetRetentionPolicy(ctx)
+	if errors.Is(err, client.ErrNoPolicy) {
+		return fmt.Errorf("no retention policy has been set; set one with -max-age-days")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read retention policy: %v", err)
+	}
+	return out.print(os.Stdout, policy, func(w io.Writer, wide bool) error {
+		archived := ""
+		if policy.Archive {
+			archived = ", archived before they are pruned"
+		}
+		_, err := fmt.Fprintf(w, "logs are kept %d days%s; set by %s at %s\n", policy.MaxAgeDays, archived, policy.UpdatedBy, policy.UpdatedAt)
+		return err
+	})
+}
+
+// retentionSource yields the logs of source recorded before cutoff and keeps their IDs. The
+// chaincode's time range is inclusive and compares text, so timestamps are checked again here.
+type retentionSource struct {
+	source export.Source
+	cutoff time.Time
+
+	ids     []string
+	private int
+	oldest  time.Time
+	newest  time.Time
+}
+
+func (s *retentionSource) Next(ctx context.Context) (*client.LogEvent, error) {
+	for {
+		log, err := s.source.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		timestamp, err := time.Parse(time.RFC3339, log.Timestamp)
+		if err != nil || !timestamp.Before(s.cutoff) {
+			continue
+		}
+
+		s.ids = append(s.ids, log.ID)
+		if log.Collection != "" {
+			s.private++
+		}
+		if s.oldest.IsZero() || timestamp.Before(s.oldest) {
+			s.oldest = timestamp
+		}
+		if timestamp.After(s.newest) {
+			s.newest = timestamp
+		}
+		return log, nil
+	}
+}
+
+// summary describes the logs yielded so far
+func (s *retentionSource) summary() string {
+	if len(s.ids) == 0 {
+		return fmt.Sprintf("no logs recorded before %s", s.cutoff.Format(time.RFC3339))
+	}
+	summary := fmt.Sprintf("%d logs recorded from %s to %s", len(s.ids), s.oldest.Format(time.RFC3339), s.newest.Format(time.RFC3339))
+	if s.private > 0 {
+		summary += fmt.Sprintf(", %d of them private", s.private)
+	}
+	return summary
+}
+
+// drain reads source to its end
+func drain(ctx context.Context, source export.Source) error {
+	for {
+		_, err := source.Next(ctx)
+		if errors.Is(err, client.ErrIteratorDone) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read logs: %v", err)
+		}
+	}
+}
+
+// parseDate parses a date, as midnight UTC, or an RFC 3339 time
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// confirm asks the user on the terminal to confirm question, failing unless they answer yes
+func confirm(question string) error {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return fmt.Errorf("not deleting without confirmation; pass -yes when not on a terminal")
+	}
+
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return fmt.Errorf("not deleting")
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// logStats summarizes a set of logs
+type logStats struct {
+	Total int `json:"total"`
+	// First and Last are the earliest and latest timestamps
+	First   string        `json:"first,omitempty"`
+	Last    string        `json:"last,omitempty"`
+	Actions []actionStats `json:"actions"`
+	Users   []userStats   `json:"users"`
+}
+
+type actionStats struct {
+	Action string `json:"action"`
+	Count  int    `json:"count"`
+	// Users is the number of distinct users who took the action
+	Users int `json:"users"`
+}
+
+type userStats struct {
+	UserID   string `json:"userId"`
+	Count    int    `json:"count"`
+	LastSeen string `json:"lastSeen"`
+}
+
+func runStats(cmd *command, args []string) error {
+	fs := cmd.flags()
+	conn := connectionFlags(fs, 0)
+	filters := queryFlags(fs, true)
+	top := fs.Int("top", 10, "most actions and users to list, the most frequent first; all of them when zero")
+	out := outputFlags(fs, outputTable)
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if err := out.check(); err != nil {
+		return err
+	}
+
+	query, err := filters.query()
+	if err != nil {
+		return err
+	}
+	filter, _ := query.Filter()
+
+	c, ctx, cancel, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	defer cancel()
+
+	stats := logStats{}
+	actions := make(map[string]*actionStats)
+	actionUsers := make(map[string]map[string]bool)
+	users := make(map[string]*userStats)
+	source := &matchingSource{it: c.IterateLogs(filter, queryPageSize), query: query}
+	for {
+		log, err := source.Next(ctx)
+		if errors.Is(err, client.ErrIteratorDone) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read logs after %d records: %v", stats.Total, err)
+		}
+
+		stats.Total++
+		if stats.First == "" || log.Timestamp < stats.First {
+			stats.First = log.Timestamp
+		}
+		if log.Timestamp > stats.Last {
+			stats.Last = log.Timestamp
+		}
+
+		action, ok := actions[log.Action]
+		if !ok {
+			action = &actionStats{Action: log.Action}
+			actions[log.Action] = action
+			actionUsers[log.Action] = make(map[string]bool)
+		}
+		action.Count++
+		if !actionUsers[log.Action][log.UserID] {
+			actionUsers[log.Action][log.UserID] = true
+			action.Users++
+		}
+
+		user, ok := users[log.UserID]
+		if !ok {
+			user = &userStats{UserID: log.UserID}
+			users[log.UserID] = user
+		}
+		user.Count++
+		if log.Timestamp > user.LastSeen {
+			user.LastSeen = log.Timestamp
+		}
+	}
+
+	for _, action := range actions {
+		stats.Actions = append(stats.Actions, *action)
+	}
+	sort.Slice(stats.Actions, func(i, j int) bool {
+		a, b := stats.Actions[i], stats.Actions[j]
+		return a.Count > b.Count || (a.Count == b.Count && a.Action < b.Action)
+	})
+	for _, user := range users {
+		stats.Users = append(stats.Users, *user)
+	}
+	sort.Slice(stats.Users, func(i, j int) bool {
+		a, b := stats.Users[i], stats.Users[j]
+		return a.Count > b.Count || (a.Count == b.Count && a.UserID < b.UserID)
+	})
+	if *top > 0 {
+		if len(stats.Actions) > *top {
+			stats.Actions = stats.Actions[:*top]
+		}
+		if len(stats.Users) > *top {
+			stats.Users = stats.Users[:*top]
+		}
+	}
+
+	return out.print(os.Stdout, stats, func(w io.Writer, wide bool) error {
+		return printStats(w, stats)
+	})
+}
+
+// printStats writes stats as a line of totals followed by tables of actions and users
+func printStats(w io.Writer, stats logStats) error {
+	fmt.Fprintf(w, "%d logs", stats.Total)
+	if stats.Total > 0 {
+		fmt.Fprintf(w, " from %s to %s", stats.First, stats.Last)
+	}
+	fmt.Fprintf(w, "\n\n")
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACTION\tCOUNT\tUSERS")
+	for _, action := range stats.Actions {
+		fmt.Fprintf(tw, "%s\t%d\t%d\n", action.Action, action.Count, action.Users)
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "USER\tCOUNT\tLAST SEEN")
+	for _, user := range stats.Users {
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", user.UserID, user.Count, user.LastSeen)
+	}
+	return tw.Flush()
+}
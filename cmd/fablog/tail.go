@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+func runTail(cmd *command, args []string) error {
+	fs := cmd.flags()
+	conn := connectionFlags(fs, defaultTimeout)
+	filters := queryFlags(fs, false)
+	n := fs.Int("n", 10, "how many of the latest logs to print first")
+	follow := fs.Bool("follow", false, "keep printing logs as they are committed, until interrupted")
+	sinceBlock := fs.Int64("since-block", -1, "with -follow, print the logs committed from this block on instead of the latest -n")
+	out := outputFlags(fs, outputTable)
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if err := out.check(); err != nil {
+		return err
+	}
+	if *sinceBlock >= 0 && !*follow {
+		return fmt.Errorf("-since-block requires -follow")
+	}
+	if *follow {
+		conn.timeout = 0
+	}
+
+	query, err := filters.query()
+	if err != nil {
+		return err
+	}
+	filter, _ := query.Filter()
+
+	c, ctx, cancel, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	defer cancel()
+
+	// Logs are printed as they arrive: JSON one per line, and YAML as separate documents
+	print := func(log *client.LogEvent) error {
+		return out.printItem(os.Stdout, log, func(w io.Writer, wide bool) error {
+			printLine(w, log, wide)
+			return nil
+		})
+	}
+
+	start := uint64(*sinceBlock)
+	printed := make(map[string]bool)
+	if *sinceBlock < 0 {
+		// Logs committed between reading the height and the query are printed once
+		if start, err = c.BlockHeight(ctx); err != nil {
+			return fmt.Errorf("failed to read block height: %v", err)
+		}
+		latest, err := latestLogs(ctx, c, query, *n)
+		if err != nil {
+			return err
+		}
+		for _, event := range latest {
+			if err := print(event); err != nil {
+				return err
+			}
+			printed[event.ID] = true
+		}
+		if !*follow {
+			return nil
+		}
+	}
+
+	listener := c.NewEventListener(func(event client.ContractEvent) error {
+		if printed[event.Log.ID] || !matchesFilter(filter, event.Log) || !query.Matches(&event.Log) {
+			return nil
+		}
+		return print(&event.Log)
+	}, client.FromBlock(start))
+	err = listener.Run(ctx)
+	if errors.Is(err, context.Canceled) {
+		// Interrupted
+		return nil
+	}
+	return err
+}
+
+// latestLogs returns the n most recent logs matching query, oldest first
+func latestLogs(ctx context.Context, c *client.Client, query *client.LogQuery, n int) ([]*client.LogEvent, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	logs, err := query.Run(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query logs: %v", err)
+	}
+
+	sortByTime(logs)
+	if len(logs) > n {
+		logs = logs[len(logs)-n:]
+	}
+	return logs, nil
+}
+
+// sortByTime sorts logs by timestamp, oldest first
+func sortByTime(logs []*client.LogEvent) {
+	sort.SliceStable(logs, func(i, j int) bool {
+		ti, erri := time.Parse(time.RFC3339Nano, logs[i].Timestamp)
+		tj, errj := time.Parse(time.RFC3339Nano, logs[j].Timestamp)
+		if erri != nil || errj != nil {
+			return logs[i].Timestamp < logs[j].Timestamp
+		}
+		return ti.Before(tj)
+	})
+}
+
+// matchesFilter applies the criteria of filter that chaincode events are not filtered by. Like
+// the chaincode, it compares the time range as text.
+func matchesFilter(filter client.LogFilter, log client.LogEvent) bool {
+	return (filter.UserID == "" || log.UserID == filter.UserID) &&
+		(filter.Action == "" || log.Action == filter.Action) &&
+		(filter.Resource == "" || log.Resource == filter.Resource) &&
+		(filter.StartTime == "" || log.Timestamp >= filter.StartTime) &&
+		(filter.EndTime == "" || log.Timestamp <= filter.EndTime)
+}
+
+// printLine writes log on one line, for output read as it arrives, with its collection and
+// metadata when wide is set
+func printLine(w io.Writer, log *client.LogEvent, wide bool) {
+	fields := []string{log.Timestamp, log.ID, log.UserID, log.Action, log.Resource}
+	if log.Description != "" {
+		fields = append(fields, log.Description)
+	}
+	if wide && log.Collection != "" {
+		fields = append(fields, "collection="+log.Collection)
+	}
+	if wide && log.Metadata != "" {
+		fields = append(fields, log.Metadata)
+	}
+	fmt.Fprintln(w, strings.Join(fields, "  "))
+}
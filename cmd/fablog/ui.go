@@ -0,0 +1,750 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/export"
+)
+
+// ANSI sequences drawing the terminal UI
+const (
+	enterScreen = "\x1b[?1049h\x1b[?25l"
+	leaveScreen = "\x1b[?25h\x1b[?1049l"
+	home        = "\x1b[H"
+	clearLine   = "\x1b[K"
+	reverse     = "\x1b[7m"
+	bold        = "\x1b[1m"
+	dim         = "\x1b[2m"
+	reset       = "\x1b[0m"
+)
+
+// uiMode is what the keys typed in the UI do
+type uiMode int
+
+const (
+	modeList uiMode = iota
+	// modeFilter edits the filter expression
+	modeFilter
+	// modeExport edits the path the selection is exported to
+	modeExport
+)
+
+func runUI(cmd *command, args []string) error {
+	fs := cmd.flags()
+	conn := connectionFlags(fs, 0)
+	filters := queryFlags(fs, true)
+	limit := fs.Int("limit", 500, "most logs listed; the oldest are dropped as new ones arrive")
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if *limit < 1 {
+		return fmt.Errorf("invalid -limit %d", *limit)
+	}
+	query, err := filters.query()
+	if err != nil {
+		return err
+	}
+
+	fd := int(os.Stdin.Fd())
+	width, height, err := terminalSize(fd)
+	if err != nil {
+		return fmt.Errorf("fablog ui needs a terminal: %v", err)
+	}
+
+	c, ctx, cancel, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	defer cancel()
+
+	start, err := c.BlockHeight(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read block height: %v", err)
+	}
+
+	restore, err := makeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("failed to set up the terminal: %v", err)
+	}
+	defer restore()
+
+	out := bufio.NewWriter(os.Stdout)
+	out.WriteString(enterScreen)
+	defer func() {
+		out.WriteString(reset + leaveScreen)
+		out.Flush()
+	}()
+
+	u := &ui{
+		c:        c,
+		out:      out,
+		fd:       fd,
+		width:    width,
+		height:   height,
+		filter:   filters.expr(),
+		query:    query,
+		limit:    *limit,
+		receipts: make(map[string]client.ContractEvent),
+		selected: make(map[string]*client.LogEvent),
+	}
+	return u.run(ctx, start)
+}
+
+// ui is the state of the terminal UI. It is only touched by the goroutine running it.
+type ui struct {
+	c      *client.Client
+	out    *bufio.Writer
+	fd     int
+	width  int
+	height int
+
+	filter string
+	query  *client.LogQuery
+	limit  int
+
+	// logs are the logs listed, newest first
+	logs []*client.LogEvent
+	// receipts holds the block and transaction of the logs delivered by events
+	receipts map[string]client.ContractEvent
+	selected map[string]*client.LogEvent
+	cursor   int
+	offset   int
+
+	// loading is the number of the query being run, which results of earlier queries are not
+	loading int
+	// arrived holds the logs delivered while a query runs, and while paused
+	arrived []*client.LogEvent
+	paused  bool
+
+	mode   uiMode
+	input  []rune
+	status string
+}
+
+// loaded is the result of a query run for the list
+type loaded struct {
+	generation int
+	logs       []*client.LogEvent
+	err        error
+}
+
+// run draws the UI and handles keys, query results and events until the user quits
+func (u *ui) run(ctx context.Context, start uint64) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	keys := make(chan string)
+	go readKeys(os.Stdin, keys)
+	resize := make(chan os.Signal, 1)
+	notifyResize(resize)
+
+	events := make(chan client.ContractEvent)
+	listenErr := make(chan error, 1)
+	listener := u.c.NewEventListener(func(event client.ContractEvent) error {
+		select {
+		case events <- event:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}, client.FromBlock(start))
+	go func() {
+		listenErr <- listener.Run(ctx)
+	}()
+
+	results := make(chan loaded, 1)
+	u.reload(ctx, results)
+
+	for {
+		u.draw()
+		select {
+		case key, ok := <-keys:
+			if !ok || !u.handleKey(ctx, key, results) {
+				return nil
+			}
+		case result := <-results:
+			u.loaded(result)
+		case event := <-events:
+			u.receipts[event.Log.ID] = event
+			log := event.Log
+			u.arrive(&log)
+		case err := <-listenErr:
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return fmt.Errorf("event listener stopped: %v", err)
+		case <-resize:
+			if width, height, err := terminalSize(u.fd); err == nil {
+				u.width, u.height = width, height
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// reload runs the current query for the list in the background
+func (u *ui) reload(ctx context.Context, results chan loaded) {
+	u.loading++
+	u.arrived = nil
+	generation, query, limit := u.loading, u.query, u.limit
+	go func() {
+		logs, err := latestLogs(ctx, u.c, query, limit)
+		select {
+		case results <- loaded{generation: generation, logs: logs, err: err}:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// loaded replaces the list with the result of the latest query, and the logs delivered meanwhile
+func (u *ui) loaded(result loaded) {
+	if result.generation != u.loading {
+		return
+	}
+	u.loading = 0
+	if result.err != nil {
+		u.status = result.err.Error()
+		return
+	}
+
+	u.logs = u.logs[:0]
+	for i := len(result.logs) - 1; i >= 0; i-- {
+		u.logs = append(u.logs, result.logs[i])
+	}
+	u.cursor, u.offset = 0, 0
+	if !u.paused {
+		u.insert(u.arrived)
+		u.arrived = nil
+	}
+}
+
+// arrive lists a log delivered by an event, unless it is held while loading or paused
+func (u *ui) arrive(log *client.LogEvent) {
+	filter, _ := u.query.Filter()
+	if !matchesFilter(filter, *log) || !u.query.Matches(log) {
+		return
+	}
+	if u.loading != 0 || u.paused {
+		u.arrived = append(u.arrived, log)
+		return
+	}
+	u.insert([]*client.LogEvent{log})
+}
+
+// insert adds logs delivered by events, oldest first, to the top of the list. The cursor stays on
+// the top log, following new ones, or on the log it is on.
+func (u *ui) insert(logs []*client.LogEvent) {
+	listed := make(map[string]bool, len(u.logs))
+	for _, log := range u.logs {
+		listed[log.ID] = true
+	}
+	for _, log := range logs {
+		if listed[log.ID] {
+			continue
+		}
+		listed[log.ID] = true
+		u.logs = append([]*client.LogEvent{log}, u.logs...)
+		if u.cursor > 0 {
+			u.cursor++
+		}
+	}
+	if len(u.logs) > u.limit {
+		u.logs = u.logs[:u.limit]
+	}
+	if u.cursor >= len(u.logs) && len(u.logs) > 0 {
+		u.cursor = len(u.logs) - 1
+	}
+}
+
+// handleKey acts on a key, returning false when the user quits
+func (u *ui) handleKey(ctx context.Context, key string, results chan loaded) bool {
+	if key == "ctrl-c" {
+		return false
+	}
+	u.status = ""
+
+	if u.mode != modeList {
+		switch key {
+		case "esc":
+			u.mode = modeList
+		case "enter":
+			mode := u.mode
+			u.mode = modeList
+			if mode == modeFilter {
+				u.applyFilter(ctx, string(u.input), results)
+			} else {
+				u.export(ctx, string(u.input))
+			}
+		case "backspace":
+			if len(u.input) > 0 {
+				u.input = u.input[:len(u.input)-1]
+			}
+		default:
+			if r, size := utf8.DecodeRuneInString(key); size == len(key) {
+				u.input = append(u.input, r)
+			}
+		}
+		return true
+	}
+
+	switch key {
+	case "q":
+		return false
+	case "up", "k":
+		u.move(-1)
+	case "down", "j":
+		u.move(1)
+	case "pgup":
+		u.move(-u.listHeight())
+	case "pgdown":
+		u.move(u.listHeight())
+	case "home", "g":
+		u.move(-len(u.logs))
+	case "end", "G":
+		u.move(len(u.logs))
+	case " ":
+		if len(u.logs) > 0 {
+			log := u.logs[u.cursor]
+			if u.selected[log.ID] != nil {
+				delete(u.selected, log.ID)
+			} else {
+				u.selected[log.ID] = log
+			}
+			u.move(1)
+		}
+	case "a":
+		if len(u.selected) > 0 {
+			u.selected = make(map[string]*client.LogEvent)
+		} else {
+			for _, log := range u.logs {
+				u.selected[log.ID] = log
+			}
+		}
+	case "/":
+		u.mode = modeFilter
+		u.input = []rune(u.filter)
+	case "e":
+		if len(u.logs) == 0 && len(u.selected) == 0 {
+			u.status = "nothing to export"
+			break
+		}
+		u.mode = modeExport
+		u.input = []rune("fablog-" + time.Now().Format("20060102-150405") + ".ndjson")
+	case "p":
+		u.paused = !u.paused
+		if !u.paused && u.loading == 0 {
+			u.insert(u.arrived)
+			u.arrived = nil
+		}
+	case "r":
+		u.reload(ctx, results)
+	}
+	return true
+}
+
+// move moves the cursor by n logs
+func (u *ui) move(n int) {
+	u.cursor += n
+	if u.cursor >= len(u.logs) {
+		u.cursor = len(u.logs) - 1
+	}
+	if u.cursor < 0 {
+		u.cursor = 0
+	}
+}
+
+// applyFilter lists the logs matching a filter expression
+func (u *ui) applyFilter(ctx context.Context, expr string, results chan loaded) {
+	filters, err := parseFilterExpr(expr)
+	if err != nil {
+		u.status = err.Error()
+		return
+	}
+	query, err := filters.query()
+	if err != nil {
+		u.status = err.Error()
+		return
+	}
+	u.filter, u.query = filters.expr(), query
+	u.reload(ctx, results)
+}
+
+// export writes the selected logs, or the log under the cursor when none is selected, to path. The
+// format follows the file's extension, NDJSON unless it is .csv or .parquet.
+func (u *ui) export(ctx context.Context, path string) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return
+	}
+
+	var logs []*client.LogEvent
+	for _, log := range u.selected {
+		logs = append(logs, log)
+	}
+	if len(logs) == 0 && len(u.logs) > 0 {
+		logs = append(logs, u.logs[u.cursor])
+	}
+	sortByTime(logs)
+
+	format := export.FormatNDJSON
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		format = export.FormatCSV
+	case ".parquet":
+		format = export.FormatParquet
+	}
+	filter, _ := u.query.Filter()
+	manifest := exportManifest{
+		File:      filepath.Base(path),
+		Format:    format,
+		CreatedAt: time.Now().UTC(),
+		Filter:    filter,
+	}
+	records, sum, err := exportFile(ctx, &sliceSource{logs: logs}, path, format, func(int) {})
+	if err != nil {
+		u.status = err.Error()
+		return
+	}
+	manifest.Records, manifest.SHA256 = records, sum
+	if _, err := writeManifest(path, manifest); err != nil {
+		u.status = err.Error()
+		return
+	}
+	u.status = fmt.Sprintf("exported %d logs to %s, sha256 %.12s", records, path, sum)
+}
+
+// sliceSource yields the logs of a slice
+type sliceSource struct {
+	logs []*client.LogEvent
+}
+
+func (s *sliceSource) Next(ctx context.Context) (*client.LogEvent, error) {
+	if len(s.logs) == 0 {
+		return nil, client.ErrIteratorDone
+	}
+	log := s.logs[0]
+	s.logs = s.logs[1:]
+	return log, nil
+}
+
+// listHeight is the number of rows listing logs
+func (u *ui) listHeight() int {
+	rows := u.height - 3
+	if u.height >= 12 {
+		rows -= u.detailHeight() + 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	return rows
+}
+
+// detailHeight is the number of rows of the detail pane
+func (u *ui) detailHeight() int {
+	return (u.height - 3) / 2
+}
+
+// draw redraws the whole screen
+func (u *ui) draw() {
+	var lines []string
+
+	state := "live"
+	switch {
+	case u.loading != 0:
+		state = "loading"
+	case u.paused:
+		state = fmt.Sprintf("paused, %d new", len(u.arrived))
+	}
+	filter := u.filter
+	if filter == "" {
+		filter = "none"
+	}
+	lines = append(lines, reverse+u.spread(" fablog  filter: "+filter, state+" ")+reset)
+
+	lines = append(lines, bold+u.fit("  "+logColumns("TIME", "USER", "ACTION", "RESOURCE", "DESCRIPTION"))+reset)
+	rows := u.listHeight()
+	if u.cursor < u.offset {
+		u.offset = u.cursor
+	}
+	if u.cursor >= u.offset+rows {
+		u.offset = u.cursor - rows + 1
+	}
+	for i := u.offset; i < u.offset+rows; i++ {
+		if i >= len(u.logs) {
+			lines = append(lines, "")
+			continue
+		}
+		log := u.logs[i]
+		mark := " "
+		if u.selected[log.ID] != nil {
+			mark = "*"
+		}
+		line := u.fit(mark + " " + logColumns(displayTime(log.Timestamp), log.UserID, log.Action, log.Resource, log.Description))
+		if i == u.cursor {
+			line = reverse + line + reset
+		}
+		lines = append(lines, line)
+	}
+
+	if u.height >= 12 {
+		title := " no log "
+		var detail []string
+		if len(u.logs) > 0 {
+			title = " " + u.logs[u.cursor].ID + " "
+			detail = u.detail(u.logs[u.cursor])
+		}
+		lines = append(lines, dim+u.fit("──"+title+strings.Repeat("─", u.width))+reset)
+		for i := 0; i < u.detailHeight(); i++ {
+			line := ""
+			if i < len(detail) {
+				line = detail[i]
+			}
+			lines = append(lines, u.fit(line))
+		}
+	}
+
+	counts := fmt.Sprintf("%d logs, %d selected ", len(u.logs), len(u.selected))
+	switch {
+	case u.mode == modeFilter:
+		lines = append(lines, u.fit("filter: "+string(u.input)+"_"))
+	case u.mode == modeExport:
+		lines = append(lines, u.fit(fmt.Sprintf("export %d logs to: %s_", u.exportCount(), string(u.input))))
+	case u.status != "":
+		lines = append(lines, u.spread(" "+clean(u.status), counts))
+	default:
+		lines = append(lines, dim+u.spread(" ↑↓ move  space select  a all  / filter  e export  p pause  r reload  q quit", counts)+reset)
+	}
+
+	u.out.WriteString(home)
+	for i, line := range lines {
+		if i >= u.height {
+			break
+		}
+		if i > 0 {
+			u.out.WriteString("\r\n")
+		}
+		u.out.WriteString(line + clearLine)
+	}
+	u.out.Flush()
+}
+
+// exportCount is the number of logs e exports
+func (u *ui) exportCount() int {
+	if len(u.selected) == 0 && len(u.logs) > 0 {
+		return 1
+	}
+	return len(u.selected)
+}
+
+// detail returns the lines of the detail pane for log: its fields, its metadata and the other
+// listed logs of its resource
+func (u *ui) detail(log *client.LogEvent) []string {
+	lines := []string{
+		"Time:        " + clean(log.Timestamp),
+		"User:        " + clean(log.UserID),
+		"Action:      " + clean(log.Action),
+		"Resource:    " + clean(log.Resource),
+	}
+	if log.Collection != "" {
+		lines = append(lines, "Collection:  "+clean(log.Collection))
+	}
+	if receipt, ok := u.receipts[log.ID]; ok {
+		lines = append(lines, fmt.Sprintf("Committed:   block %d, transaction %s", receipt.BlockNumber, receipt.TransactionID))
+	}
+	if log.Description != "" {
+		lines = append(lines, "Description: "+clean(log.Description))
+	}
+	if log.Metadata != "" {
+		lines = append(lines, "Metadata:")
+		var indented bytes.Buffer
+		if json.Indent(&indented, []byte(log.Metadata), "  ", "  ") == nil {
+			for _, line := range strings.Split(indented.String(), "\n") {
+				lines = append(lines, "  "+clean(line))
+			}
+		} else {
+			lines = append(lines, "  "+clean(log.Metadata))
+		}
+	}
+
+	var history []string
+	for _, other := range u.logs {
+		if other.Resource == log.Resource && other.ID != log.ID {
+			history = append(history, "  "+logColumns(displayTime(other.Timestamp), other.UserID, other.Action, "", other.Description))
+		}
+	}
+	if len(history) > 0 {
+		lines = append(lines, fmt.Sprintf("History of %s, %d other listed logs:", clean(log.Resource), len(history)))
+		lines = append(lines, history...)
+	}
+	return lines
+}
+
+// fit cuts or pads s to the width of the screen
+func (u *ui) fit(s string) string {
+	return runewidth.FillRight(runewidth.Truncate(s, u.width, "…"), u.width)
+}
+
+// spread fits left and right on one line of the screen, right aligned to its end
+func (u *ui) spread(left, right string) string {
+	space := u.width - runewidth.StringWidth(right)
+	if space < 0 {
+		return u.fit(right)
+	}
+	return runewidth.FillRight(runewidth.Truncate(left, space, "…"), space) + right
+}
+
+// logColumns lays out the fields of a log in the columns of the list
+func logColumns(time, user, action, resource, description string) string {
+	column := func(s string, width int) string {
+		return runewidth.FillRight(runewidth.Truncate(clean(s), width, "…"), width)
+	}
+	return column(time, 19) + "  " + column(user, 12) + "  " + column(action, 12) + "  " + column(resource, 20) + "  " + clean(description)
+}
+
+// displayTime formats an RFC 3339 timestamp in local time, leaving others as they are
+func displayTime(timestamp string) string {
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return timestamp
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+// clean replaces the control characters of s, which could move the cursor or change the terminal,
+// with spaces
+func clean(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// parseFilterExpr parses a filter expression of space separated key=value criteria, where the
+// keys are the names of the filter flags: user, action, resource, from, to, severity and tag
+func parseFilterExpr(expr string) (*filterFlags, error) {
+	f := &filterFlags{}
+	fields := map[string]*string{
+		"user":     &f.user,
+		"action":   &f.action,
+		"resource": &f.resource,
+		"from":     &f.from,
+		"to":       &f.to,
+		"severity": &f.severity,
+		"tag":      &f.tag,
+	}
+	for _, criterion := range strings.Fields(expr) {
+		key, value, ok := strings.Cut(criterion, "=")
+		field, known := fields[key]
+		if !ok || !known {
+			return nil, fmt.Errorf("invalid filter %q; use key=value with the keys user, action, resource, from, to, severity and tag", criterion)
+		}
+		*field = value
+	}
+	return f, nil
+}
+
+// expr returns the filter expression selecting the same logs as the flags
+func (f *filterFlags) expr() string {
+	var criteria []string
+	add := func(key, value string) {
+		if value != "" {
+			criteria = append(criteria, key+"="+value)
+		}
+	}
+	add("user", f.user)
+	add("action", f.action)
+	add("resource", f.resource)
+	add("from", f.from)
+	add("to", f.to)
+	add("severity", f.severity)
+	add("tag", f.tag)
+	return strings.Join(criteria, " ")
+}
+
+// escapeKeys names the escape sequences of the keys the UI handles
+var escapeKeys = []struct {
+	sequence string
+	key      string
+}{
+	{"\x1b[A", "up"}, {"\x1bOA", "up"},
+	{"\x1b[B", "down"}, {"\x1bOB", "down"},
+	{"\x1b[5~", "pgup"}, {"\x1b[6~", "pgdown"},
+	{"\x1b[H", "home"}, {"\x1bOH", "home"}, {"\x1b[1~", "home"},
+	{"\x1b[F", "end"}, {"\x1bOF", "end"}, {"\x1b[4~", "end"},
+}
+
+// readKeys sends the keys typed on r to keys, closing it when r ends
+func readKeys(r *os.File, keys chan<- string) {
+	defer close(keys)
+	buf := make([]byte, 256)
+	for {
+		n, err := r.Read(buf)
+		for _, key := range decodeKeys(buf[:n]) {
+			keys <- key
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// decodeKeys splits the bytes of one read from the terminal into keys: a character, or the name
+// of a key such as up or enter
+func decodeKeys(b []byte) []string {
+	var keys []string
+	for len(b) > 0 {
+		switch b[0] {
+		case 0x1b:
+			if len(b) == 1 {
+				return append(keys, "esc")
+			}
+			matched := false
+			for _, k := range escapeKeys {
+				if bytes.HasPrefix(b, []byte(k.sequence)) {
+					keys = append(keys, k.key)
+					b = b[len(k.sequence):]
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				// Sequences of other keys are ignored
+				return keys
+			}
+			continue
+		case '\r', '\n':
+			keys = append(keys, "enter")
+		case 0x7f, 0x08:
+			keys = append(keys, "backspace")
+		case 0x03:
+			keys = append(keys, "ctrl-c")
+		default:
+			r, size := utf8.DecodeRune(b)
+			if !unicode.IsControl(r) && r != utf8.RuneError {
+				keys = append(keys, string(r))
+			}
+			b = b[size:]
+			continue
+		}
+		b = b[1:]
+	}
+	return keys
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+func runVerify(cmd *command, args []string) error {
+	fs := cmd.flags()
+	conn := connectionFlags(fs, 0)
+	chain := fs.String("hash-chain", "", "hash chain file kept by the client that submitted the logs, such as with create -hash-chain; required")
+	from := fs.String("from", "", "only logs recorded in the chain at or after this RFC 3339 time")
+	to := fs.String("to", "", "only logs recorded in the chain at or before this RFC 3339 time")
+	out := outputFlags(fs, outputTable)
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if err := out.check(); err != nil {
+		return err
+	}
+	if *chain == "" {
+		return fmt.Errorf("-hash-chain is required")
+	}
+
+	var start, end time.Time
+	var err error
+	if *from != "" {
+		if start, err = time.Parse(time.RFC3339, *from); err != nil {
+			return fmt.Errorf("invalid -from: %v", err)
+		}
+	}
+	if *to != "" {
+		if end, err = time.Parse(time.RFC3339, *to); err != nil {
+			return fmt.Errorf("invalid -to: %v", err)
+		}
+	}
+
+	// NewVerifier creates missing files, which would verify as an empty chain
+	if _, err := os.Stat(*chain); err != nil {
+		return err
+	}
+	verifier, err := client.NewVerifier(*chain)
+	if err != nil {
+		return err
+	}
+	defer verifier.Close()
+
+	c, ctx, cancel, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	defer cancel()
+
+	divergences, checked, err := verifier.VerifyBetween(ctx, c, start, end)
+	if err != nil {
+		return fmt.Errorf("failed to verify logs after %d entries: %v", checked, err)
+	}
+	report := verifyReport{Checked: checked, Result: "PASS", Divergences: divergences}
+	report.Entries, report.Head = verifier.Head()
+	if len(divergences) > 0 {
+		report.Result = "FAIL"
+	} else {
+		report.Divergences = []client.Divergence{}
+	}
+	if err := out.print(os.Stdout, report, report.print); err != nil {
+		return err
+	}
+	if len(divergences) > 0 {
+		return exitStatus(1)
+	}
+	return nil
+}
+
+// verifyReport is the result of fablog verify
+type verifyReport struct {
+	Entries     uint64              `json:"entries"`
+	Head        string              `json:"head"`
+	Checked     int                 `json:"checked"`
+	Result      string              `json:"result"`
+	Divergences []client.Divergence `json:"divergences"`
+}
+
+// print writes the report, with every inconsistency rather than the first when wide is set
+func (r verifyReport) print(w io.Writer, wide bool) error {
+	fmt.Fprintf(w, "chain:   %d entries, head %s\n", r.Entries, r.Head)
+	fmt.Fprintf(w, "checked: %d entries\n", r.Checked)
+	if len(r.Divergences) == 0 {
+		fmt.Fprintln(w, "result:  PASS")
+		return nil
+	}
+	fmt.Fprintf(w, "result:  FAIL, %d inconsistencies\n", len(r.Divergences))
+	if !wide {
+		fmt.Fprintf(w, "first:   %s\n", r.Divergences[0])
+		return nil
+	}
+	for _, divergence := range r.Divergences {
+		fmt.Fprintf(w, "         %s\n", divergence)
+	}
+	return nil
+}
+
+// hashChainFlag adds the -hash-chain flag to fs
+func hashChainFlag(fs *flag.FlagSet) *string {
+	return fs.String("hash-chain", "", "file to append the hashes of the submitted logs to, for fablog verify")
+}
+
+// openHashChain returns the client options recording submitted logs in the hash chain at path,
+// none when path is empty, and a function closing the chain
+func openHashChain(path string) ([]client.Option, func(), error) {
+	if path == "" {
+		return nil, func() {}, nil
+	}
+	verifier, err := client.NewVerifier(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []client.Option{client.WithVerifier(verifier)}, func() { verifier.Close() }, nil
+}
@@ -0,0 +1,131 @@
+// Command fluent receives logs from Fluentd and Fluent Bit over the Fluent forward protocol and
+// records them on the ledger in batched transactions (see package fluent), so existing pipelines
+// add the ledger with one forward output.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/fluent"
+)
+
+func main() {
+	addr := flag.String("addr", envOr("FLUENT_ADDR", ":24224"), "address to receive forward connections on")
+	tlsCert := flag.String("tls-cert", os.Getenv("FLUENT_TLS_CERT"), "certificate to serve TLS with; plain TCP is served when empty")
+	tlsKey := flag.String("tls-key", os.Getenv("FLUENT_TLS_KEY"), "private key of the TLS certificate")
+	tlsClientCA := flag.String("tls-client-ca", os.Getenv("FLUENT_TLS_CLIENT_CA"), "CA certificates senders must present a certificate of; any sender is accepted when empty")
+	sharedKey := flag.String("shared-key", os.Getenv("FLUENT_SHARED_KEY"), "shared key senders must prove in the forward handshake; no handshake when empty")
+	hostname := flag.String("hostname", os.Getenv("FLUENT_HOSTNAME"), "server name sent in the handshake; the host name when empty")
+	userID := flag.String("user-id", os.Getenv("FLUENT_USER_ID"), "user recorded for records without a userId key")
+	action := flag.String("action", os.Getenv("FLUENT_ACTION"), "action recorded for records without an action key; LOG_<LEVEL> when empty")
+	messageKeys := flag.String("message-keys", envOr("FLUENT_MESSAGE_KEYS", strings.Join(fluent.DefaultMessageKeys, ",")), "comma-separated record keys the description is read from, in order")
+	maxMessageSize := flag.Int64("max-message-size", fluent.DefaultMaxMessageSize, "largest forward message read, in bytes")
+	batchSize := flag.Int("batch-size", client.DefaultMaxBatchSize, "most logs submitted per transaction")
+	flushInterval := flag.Duration("flush-interval", client.DefaultFlushInterval, "longest a log waits before its batch is submitted")
+	maxBuffered := flag.Int("max-buffered", 10000, "most logs waiting to be submitted before senders are held back")
+	deadLetters := flag.String("dead-letter-dir", os.Getenv("DEAD_LETTER_DIR"), "directory keeping the logs the chaincode rejects, for fablog or the REST API to resubmit")
+	metricsAddr := flag.String("metrics-addr", os.Getenv("METRICS_ADDR"), "address to serve Prometheus metrics on at /metrics; not served when empty")
+	profile := flag.String("profile", "", "connection profile; CONNECTION_PROFILE_PATH is used when empty")
+	org := flag.String("org", "", "organization in the connection profile; ORG is used when empty")
+	flag.Parse()
+
+	var tlsConfig *tls.Config
+	if *tlsCert != "" {
+		var err error
+		if tlsConfig, err = client.ServerTLSConfig(*tlsCert, *tlsKey, *tlsClientCA); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	cfg, err := client.LoadConfig(*profile, *org)
+	if err != nil {
+		log.Fatalf("failed to load configuration: %v", err)
+	}
+	var clientOptions []client.Option
+	if *deadLetters != "" {
+		queue, err := client.NewDeadLetterQueue(*deadLetters)
+		if err != nil {
+			log.Fatalf("failed to open dead-letter queue: %v", err)
+		}
+		clientOptions = append(clientOptions, client.WithDeadLetterQueue(queue))
+	}
+	c, err := client.Connect(cfg, clientOptions...)
+	if err != nil {
+		log.Fatalf("failed to connect to gateway: %v", err)
+	}
+	defer c.Close()
+
+	submitter := client.NewBatchingSubmitter(c, client.BatchOptions{
+		MaxBatchSize:  *batchSize,
+		FlushInterval: *flushInterval,
+		MaxBuffered:   *maxBuffered,
+		OnError: func(logs []client.LogEvent, err error) {
+			log.Printf("failed to submit %d logs: %v", len(logs), err)
+		},
+	})
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		submitter.Close(ctx)
+	}()
+
+	server, err := fluent.NewServer(fluent.Options{
+		Submitter:      submitter,
+		Flush:          submitter.Flush,
+		SharedKey:      *sharedKey,
+		Hostname:       *hostname,
+		UserID:         *userID,
+		Action:         *action,
+		MessageKeys:    strings.Split(*messageKeys, ","),
+		MaxMessageSize: *maxMessageSize,
+		OnError: func(err error) {
+			log.Print(err)
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *metricsAddr != "" {
+		metrics, err := client.ServeMetrics(*metricsAddr, nil)
+		if err != nil {
+			log.Fatalf("failed to serve metrics: %v", err)
+		}
+		defer metrics.Close()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatalf("failed to listen on %s: %v", *addr, err)
+	}
+	if tlsConfig != nil {
+		log.Printf("receiving forward messages over TLS on %s", listener.Addr())
+		err = server.ServeTLS(ctx, listener, tlsConfig)
+	} else {
+		log.Printf("receiving forward messages on %s", listener.Addr())
+		err = server.Serve(ctx, listener)
+	}
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,193 @@
+// Command hec serves a Splunk HTTP Event Collector compatible endpoint and records the events sent
+// to it on the ledger in batched transactions (see package splunk), so anything that already logs
+// to Splunk can log to the ledger by pointing its HEC URL and token here. With -forward-url it
+// also mirrors the logs committed on the ledger out to a real HEC.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/projection"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/splunk"
+)
+
+func main() {
+	addr := flag.String("addr", envOr("HEC_ADDR", ":8088"), "address to serve the collector on")
+	tlsCert := flag.String("tls-cert", os.Getenv("HEC_TLS_CERT"), "certificate to serve HTTPS with; plain HTTP is served when empty")
+	tlsKey := flag.String("tls-key", os.Getenv("HEC_TLS_KEY"), "private key of the TLS certificate")
+	tlsClientCA := flag.String("tls-client-ca", os.Getenv("HEC_TLS_CLIENT_CA"), "CA certificates senders must present a certificate of; any sender is accepted when empty")
+	action := flag.String("action", os.Getenv("HEC_ACTION"), "action recorded for events without an action key; LOG_<LEVEL> when empty")
+	messageKeys := flag.String("message-keys", envOr("HEC_MESSAGE_KEYS", strings.Join(splunk.DefaultMessageKeys, ",")), "comma-separated event keys the description is read from, in order")
+	maxBodySize := flag.Int64("max-body-size", splunk.DefaultMaxBodyBytes, "largest request body read, in bytes after decompression")
+	batchSize := flag.Int("batch-size", client.DefaultMaxBatchSize, "most logs submitted per transaction")
+	flushInterval := flag.Duration("flush-interval", client.DefaultFlushInterval, "longest a log waits before its batch is submitted")
+	maxBuffered := flag.Int("max-buffered", 10000, "most logs waiting to be submitted before senders are held back")
+	forwardURL := flag.String("forward-url", os.Getenv("SPLUNK_HEC_URL"), "Splunk HEC to mirror committed logs to, such as https://splunk.example.com:8088; SPLUNK_HEC_TOKEN holds its token. Not forwarded when empty")
+	forwardIndex := flag.String("forward-index", os.Getenv("SPLUNK_HEC_INDEX"), "index of the forwarded events; the token's default when empty")
+	forwardCheckpoint := flag.String("forward-checkpoint", envOr("SPLUNK_HEC_CHECKPOINT", "hec-forward.checkpoint"), "file recording the last forwarded transaction")
+	deadLetters := flag.String("dead-letter-dir", os.Getenv("DEAD_LETTER_DIR"), "directory keeping the logs the chaincode rejects, for fablog or the REST API to resubmit")
+	metricsAddr := flag.String("metrics-addr", os.Getenv("METRICS_ADDR"), "address to serve Prometheus metrics on at /metrics; not served when empty")
+	profile := flag.String("profile", "", "connection profile; CONNECTION_PROFILE_PATH is used when empty")
+	org := flag.String("org", "", "organization in the connection profile; ORG is used when empty")
+	flag.Parse()
+
+	// Tokens are only read from the environment, so they do not show in the process list
+	tokens, err := parseTokens(os.Getenv("HEC_TOKENS"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	var tlsConfig *tls.Config
+	if *tlsCert != "" {
+		if tlsConfig, err = client.ServerTLSConfig(*tlsCert, *tlsKey, *tlsClientCA); err != nil {
+			log.Fatal(err)
+		}
+	}
+	var sink *splunk.Sink
+	if *forwardURL != "" {
+		sink, err = splunk.NewSink(splunk.Config{
+			URL:   *forwardURL,
+			Token: os.Getenv("SPLUNK_HEC_TOKEN"),
+			Index: *forwardIndex,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	cfg, err := client.LoadConfig(*profile, *org)
+	if err != nil {
+		log.Fatalf("failed to load configuration: %v", err)
+	}
+	var clientOptions []client.Option
+	if *deadLetters != "" {
+		queue, err := client.NewDeadLetterQueue(*deadLetters)
+		if err != nil {
+			log.Fatalf("failed to open dead-letter queue: %v", err)
+		}
+		clientOptions = append(clientOptions, client.WithDeadLetterQueue(queue))
+	}
+	c, err := client.Connect(cfg, clientOptions...)
+	if err != nil {
+		log.Fatalf("failed to connect to gateway: %v", err)
+	}
+	defer c.Close()
+
+	submitter := client.NewBatchingSubmitter(c, client.BatchOptions{
+		MaxBatchSize:  *batchSize,
+		FlushInterval: *flushInterval,
+		MaxBuffered:   *maxBuffered,
+		OnError: func(logs []client.LogEvent, err error) {
+			log.Printf("failed to submit %d logs: %v", len(logs), err)
+		},
+	})
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		submitter.Close(ctx)
+	}()
+
+	server, err := splunk.NewServer(splunk.Options{
+		Submitter:    submitter,
+		Flush:        submitter.Flush,
+		Tokens:       tokens,
+		Action:       *action,
+		MessageKeys:  strings.Split(*messageKeys, ","),
+		MaxBodyBytes: *maxBodySize,
+		OnError: func(err error) {
+			log.Print(err)
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *metricsAddr != "" {
+		metrics, err := client.ServeMetrics(*metricsAddr, nil)
+		if err != nil {
+			log.Fatalf("failed to serve metrics: %v", err)
+		}
+		defer metrics.Close()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if sink != nil {
+		projector := projection.New(projection.NewEventSource(c, nil), sink, projection.Options{
+			Checkpointer: client.NewFileCheckpointer(*forwardCheckpoint),
+		})
+		go func() {
+			log.Printf("forwarding committed logs to %s", *forwardURL)
+			if err := projector.Run(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("forwarding stopped: %v", err)
+			}
+		}()
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatalf("failed to listen on %s: %v", *addr, err)
+	}
+	httpServer := &http.Server{
+		Handler:           server,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	if tlsConfig != nil {
+		log.Printf("receiving HEC events over HTTPS on %s", listener.Addr())
+		err = httpServer.ServeTLS(listener, "", "")
+	} else {
+		log.Printf("receiving HEC events on %s", listener.Addr())
+		err = httpServer.Serve(listener)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Print(err)
+	}
+}
+
+// parseTokens reads comma-separated name=token pairs
+func parseTokens(value string) (map[string]string, error) {
+	tokens := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, token, ok := strings.Cut(pair, "=")
+		name, token = strings.TrimSpace(name), strings.TrimSpace(token)
+		if !ok || name == "" || token == "" {
+			return nil, fmt.Errorf("invalid HEC token %q: want name=token", name)
+		}
+		tokens[token] = name
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("no HEC tokens configured: set HEC_TOKENS to comma-separated name=token pairs")
+	}
+	return tokens, nil
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,136 @@
+// Command kafka-bridge drains a Kafka topic onto the ledger (see kafka.Bridge): it consumes the
+// topic as a member of a consumer group, maps each message to a log with the templates of a
+// mapping file, and commits the group's offsets only once the logs are committed on the ledger.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	fabrickafka "github.com/isiddharthsingh/fabric-logging-system/pkg/kafka"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+func main() {
+	brokers := flag.String("brokers", envOr("KAFKA_BROKERS", "localhost:9092"), "comma-separated Kafka brokers")
+	topic := flag.String("topic", os.Getenv("KAFKA_TOPIC"), "topic to consume")
+	group := flag.String("group", envOr("KAFKA_GROUP_ID", "fabric-logging-bridge"), "consumer group whose offsets record the messages drained")
+	mappingPath := flag.String("mapping", os.Getenv("KAFKA_MAPPING"), "mapping file of the templates turning messages into logs; messages are read as JSON logs when empty")
+	useTLS := flag.Bool("tls", os.Getenv("KAFKA_TLS") == "true", "connect to the brokers over TLS")
+	tlsCA := flag.String("tls-ca", os.Getenv("KAFKA_TLS_CA"), "CA certificates of the brokers; the system pool is used when empty")
+	tlsCert := flag.String("tls-cert", os.Getenv("KAFKA_TLS_CERT"), "client certificate presented to the brokers")
+	tlsKey := flag.String("tls-key", os.Getenv("KAFKA_TLS_KEY"), "private key of the client certificate")
+	saslMechanism := flag.String("sasl-mechanism", os.Getenv("KAFKA_SASL_MECHANISM"), "SASL mechanism: plain, scram-sha-256 or scram-sha-512; none when empty")
+	saslUsername := flag.String("sasl-username", os.Getenv("KAFKA_SASL_USERNAME"), "SASL username")
+	batchSize := flag.Int("batch-size", fabrickafka.DefaultBatchSize, "most messages submitted per transaction")
+	flushInterval := flag.Duration("flush-interval", fabrickafka.DefaultFlushInterval, "longest a consumed message waits before its batch is submitted")
+	metricsAddr := flag.String("metrics-addr", os.Getenv("METRICS_ADDR"), "address to serve Prometheus metrics on at /metrics; not served when empty")
+	profile := flag.String("profile", "", "connection profile; CONNECTION_PROFILE_PATH is used when empty")
+	org := flag.String("org", "", "organization in the connection profile; ORG is used when empty")
+	flag.Parse()
+
+	if *topic == "" {
+		log.Fatal("no topic configured: set -topic or KAFKA_TOPIC")
+	}
+	var mapping *fabrickafka.Mapping
+	if *mappingPath != "" {
+		var err error
+		if mapping, err = fabrickafka.LoadMapping(*mappingPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	dialer := &kafka.Dialer{Timeout: 10 * time.Second, DualStack: true}
+	if *useTLS {
+		config, err := client.ClientTLSConfig(*tlsCA, *tlsCert, *tlsKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		dialer.TLS = config
+	}
+	if *saslMechanism != "" {
+		// The password is only read from the environment, so it does not show in the process list
+		mechanism, err := saslMechanismFor(*saslMechanism, *saslUsername, os.Getenv("KAFKA_SASL_PASSWORD"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		dialer.SASLMechanism = mechanism
+	}
+
+	cfg, err := client.LoadConfig(*profile, *org)
+	if err != nil {
+		log.Fatalf("failed to load configuration: %v", err)
+	}
+	c, err := client.Connect(cfg)
+	if err != nil {
+		log.Fatalf("failed to connect to gateway: %v", err)
+	}
+	defer c.Close()
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     strings.Split(*brokers, ","),
+		Topic:       *topic,
+		GroupID:     *group,
+		Dialer:      dialer,
+		StartOffset: kafka.FirstOffset,
+	})
+	bridge, err := fabrickafka.NewBridgeWithReader(c, reader, fabrickafka.BridgeOptions{
+		Mapping:       mapping,
+		BatchSize:     *batchSize,
+		FlushInterval: *flushInterval,
+		OnError: func(err error) {
+			log.Print(err)
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer bridge.Close()
+
+	if *metricsAddr != "" {
+		metrics, err := client.ServeMetrics(*metricsAddr, nil)
+		if err != nil {
+			log.Fatalf("failed to serve metrics: %v", err)
+		}
+		defer metrics.Close()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("draining topic %s as consumer group %s", *topic, *group)
+	if err := bridge.Run(ctx); err != nil {
+		log.Printf("bridge stopped: %v", err)
+	}
+}
+
+// saslMechanismFor returns the SASL mechanism of the given name
+func saslMechanismFor(name, username, password string) (sasl.Mechanism, error) {
+	switch strings.ToLower(name) {
+	case "plain":
+		return plain.Mechanism{Username: username, Password: password}, nil
+	case "scram-sha-256":
+		return scram.Mechanism(scram.SHA256, username, password)
+	case "scram-sha-512":
+		return scram.Mechanism(scram.SHA512, username, password)
+	}
+	return nil, fmt.Errorf("unknown SASL mechanism %q, expected plain, scram-sha-256 or scram-sha-512", name)
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
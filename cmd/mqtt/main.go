@@ -0,0 +1,136 @@
+// Command mqtt subscribes to the topics IoT devices publish on an MQTT broker and records their
+// messages on the ledger in batched transactions (see package mqtt), with the device as the user
+// and the topic as the resource.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/mqtt"
+)
+
+func main() {
+	broker := flag.String("broker", envOr("MQTT_BROKER", mqtt.DefaultBroker), "broker URL: tcp:// or mqtt://, or ssl://, tls:// or mqtts:// for TLS")
+	clientID := flag.String("client-id", os.Getenv("MQTT_CLIENT_ID"), "client ID of the persistent session; fablog-mqtt-<hostname> when empty")
+	username := flag.String("username", os.Getenv("MQTT_USERNAME"), "user name to authenticate as; MQTT_PASSWORD holds the password")
+	topics := flag.String("topics", os.Getenv("MQTT_TOPICS"), "comma-separated topic filters, such as devices/+/telemetry, recorded with the defaults")
+	topicsFile := flag.String("topics-file", os.Getenv("MQTT_TOPICS_FILE"), "file of the topic filters and how their messages are recorded")
+	tlsCA := flag.String("tls-ca", os.Getenv("MQTT_TLS_CA"), "CA certificates of the broker, enabling TLS; the system pool is used when empty")
+	tlsCert := flag.String("tls-cert", os.Getenv("MQTT_TLS_CERT"), "client certificate presented to the broker, enabling TLS")
+	tlsKey := flag.String("tls-key", os.Getenv("MQTT_TLS_KEY"), "private key of the client certificate")
+	keepAlive := flag.Duration("keepalive", mqtt.DefaultKeepAlive, "longest the client and broker go without a packet")
+	maxMessageSize := flag.Int("max-message-size", mqtt.DefaultMaxMessageSize, "largest payload recorded; larger ones are skipped")
+	batchSize := flag.Int("batch-size", client.DefaultMaxBatchSize, "most logs submitted per transaction")
+	flushInterval := flag.Duration("flush-interval", client.DefaultFlushInterval, "longest a message waits before its batch is submitted and the message acknowledged")
+	maxBuffered := flag.Int("max-buffered", 10000, "most logs waiting to be submitted before messages are held back")
+	deadLetters := flag.String("dead-letter-dir", os.Getenv("DEAD_LETTER_DIR"), "directory keeping the logs the chaincode rejects, for fablog or the REST API to resubmit")
+	metricsAddr := flag.String("metrics-addr", os.Getenv("METRICS_ADDR"), "address to serve Prometheus metrics on at /metrics; not served when empty")
+	profile := flag.String("profile", "", "connection profile; CONNECTION_PROFILE_PATH is used when empty")
+	org := flag.String("org", "", "organization in the connection profile; ORG is used when empty")
+	flag.Parse()
+
+	var subscriptions []mqtt.Topic
+	if *topicsFile != "" {
+		var err error
+		if subscriptions, err = mqtt.LoadTopics(*topicsFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, filter := range strings.Split(*topics, ",") {
+		if filter = strings.TrimSpace(filter); filter != "" {
+			subscriptions = append(subscriptions, mqtt.Topic{Filter: filter})
+		}
+	}
+	if len(subscriptions) == 0 {
+		log.Fatal("no topics configured: set -topics or -topics-file")
+	}
+	var tlsConfig *tls.Config
+	if *tlsCA != "" || *tlsCert != "" {
+		var err error
+		if tlsConfig, err = client.ClientTLSConfig(*tlsCA, *tlsCert, *tlsKey); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	cfg, err := client.LoadConfig(*profile, *org)
+	if err != nil {
+		log.Fatalf("failed to load configuration: %v", err)
+	}
+	var clientOptions []client.Option
+	if *deadLetters != "" {
+		queue, err := client.NewDeadLetterQueue(*deadLetters)
+		if err != nil {
+			log.Fatalf("failed to open dead-letter queue: %v", err)
+		}
+		clientOptions = append(clientOptions, client.WithDeadLetterQueue(queue))
+	}
+	c, err := client.Connect(cfg, clientOptions...)
+	if err != nil {
+		log.Fatalf("failed to connect to gateway: %v", err)
+	}
+	defer c.Close()
+
+	submitter := client.NewBatchingSubmitter(c, client.BatchOptions{
+		MaxBatchSize:  *batchSize,
+		FlushInterval: *flushInterval,
+		MaxBuffered:   *maxBuffered,
+		OnError: func(logs []client.LogEvent, err error) {
+			log.Printf("failed to submit %d logs: %v", len(logs), err)
+		},
+	})
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		submitter.Close(ctx)
+	}()
+
+	subscriber, err := mqtt.NewSubscriber(mqtt.Options{
+		Broker:         *broker,
+		ClientID:       *clientID,
+		Username:       *username,
+		Password:       os.Getenv("MQTT_PASSWORD"),
+		TLS:            tlsConfig,
+		KeepAlive:      *keepAlive,
+		Topics:         subscriptions,
+		Submitter:      submitter,
+		Flush:          submitter.Flush,
+		FlushInterval:  *flushInterval,
+		MaxMessageSize: *maxMessageSize,
+		OnError: func(err error) {
+			log.Print(err)
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *metricsAddr != "" {
+		metrics, err := client.ServeMetrics(*metricsAddr, nil)
+		if err != nil {
+			log.Fatalf("failed to serve metrics: %v", err)
+		}
+		defer metrics.Close()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("subscribing to %d topic filters on %s", len(subscriptions), *broker)
+	subscriber.Run(ctx)
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
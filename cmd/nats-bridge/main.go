@@ -0,0 +1,113 @@
+// Command nats-bridge drains a NATS JetStream stream onto the ledger (see nats.Bridge): it pulls
+// the stream's messages through a durable consumer, maps each subject to an action and resource
+// with the templates of a mapping file, and acknowledges messages only once their logs are
+// committed on the ledger.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/nats"
+)
+
+func main() {
+	url := flag.String("url", envOr("NATS_URL", nats.DefaultURL), "NATS server URL; tls:// requires TLS")
+	stream := flag.String("stream", os.Getenv("NATS_STREAM"), "JetStream stream to drain")
+	consumer := flag.String("consumer", envOr("NATS_CONSUMER", nats.DefaultConsumer), "durable consumer shared by the bridges draining the stream")
+	filterSubject := flag.String("filter-subject", os.Getenv("NATS_FILTER_SUBJECT"), "only drain the stream's subjects matching this pattern")
+	mappingPath := flag.String("mapping", os.Getenv("NATS_MAPPING"), "mapping file of the templates turning messages into logs by subject; the defaults are used when empty")
+	creds := flag.String("creds", os.Getenv("NATS_CREDS"), "credentials file holding a user JWT and NKey seed")
+	user := flag.String("user", os.Getenv("NATS_USER"), "user to authenticate as; NATS_PASSWORD holds the password")
+	tlsCA := flag.String("tls-ca", os.Getenv("NATS_TLS_CA"), "CA certificates of the server, enabling TLS; the system pool is used when empty")
+	tlsCert := flag.String("tls-cert", os.Getenv("NATS_TLS_CERT"), "client certificate presented to the server, enabling TLS")
+	tlsKey := flag.String("tls-key", os.Getenv("NATS_TLS_KEY"), "private key of the client certificate")
+	batchSize := flag.Int("batch-size", nats.DefaultBatchSize, "most messages submitted per transaction")
+	flushInterval := flag.Duration("flush-interval", nats.DefaultFlushInterval, "longest the bridge waits for a batch to fill")
+	ackWait := flag.Duration("ack-wait", nats.DefaultAckWait, "how long the server waits for an acknowledgement before delivering a message again")
+	metricsAddr := flag.String("metrics-addr", os.Getenv("METRICS_ADDR"), "address to serve Prometheus metrics on at /metrics; not served when empty")
+	profile := flag.String("profile", "", "connection profile; CONNECTION_PROFILE_PATH is used when empty")
+	org := flag.String("org", "", "organization in the connection profile; ORG is used when empty")
+	flag.Parse()
+
+	if *stream == "" {
+		log.Fatal("no stream configured: set -stream or NATS_STREAM")
+	}
+	var subjects []nats.SubjectMapping
+	if *mappingPath != "" {
+		var err error
+		if subjects, err = nats.LoadMappings(*mappingPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+	var tlsConfig *tls.Config
+	if *tlsCA != "" || *tlsCert != "" {
+		var err error
+		if tlsConfig, err = client.ClientTLSConfig(*tlsCA, *tlsCert, *tlsKey); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	cfg, err := client.LoadConfig(*profile, *org)
+	if err != nil {
+		log.Fatalf("failed to load configuration: %v", err)
+	}
+	c, err := client.Connect(cfg)
+	if err != nil {
+		log.Fatalf("failed to connect to gateway: %v", err)
+	}
+	defer c.Close()
+
+	// Secrets are only read from the environment, so they do not show in the process list
+	bridge, err := nats.NewBridge(c, nats.Options{
+		URL:           *url,
+		Stream:        *stream,
+		Consumer:      *consumer,
+		FilterSubject: *filterSubject,
+		Name:          "fabric-logging-nats-bridge",
+		User:          *user,
+		Password:      os.Getenv("NATS_PASSWORD"),
+		Token:         os.Getenv("NATS_TOKEN"),
+		Credentials:   *creds,
+		TLS:           tlsConfig,
+		Subjects:      subjects,
+		BatchSize:     *batchSize,
+		FlushInterval: *flushInterval,
+		AckWait:       *ackWait,
+		OnError: func(err error) {
+			log.Print(err)
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *metricsAddr != "" {
+		metrics, err := client.ServeMetrics(*metricsAddr, nil)
+		if err != nil {
+			log.Fatalf("failed to serve metrics: %v", err)
+		}
+		defer metrics.Close()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("draining stream %s as consumer %s", *stream, *consumer)
+	if err := bridge.Run(ctx); err != nil {
+		log.Printf("bridge stopped: %v", err)
+	}
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
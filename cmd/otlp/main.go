@@ -0,0 +1,205 @@
+// Command otlp receives logs over the OpenTelemetry protocol, on gRPC and HTTP, and records them
+// on the ledger in batched transactions (see package otlp), so any OpenTelemetry SDK or Collector
+// logs to the ledger by pointing its OTLP logs exporter here.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/otlp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+func main() {
+	grpcAddr := flag.String("grpc-addr", envOr("OTLP_GRPC_ADDR", ":4317"), "address to serve OTLP over gRPC on; not served when empty")
+	httpAddr := flag.String("http-addr", envOr("OTLP_HTTP_ADDR", ":4318"), "address to serve OTLP over HTTP on; not served when empty")
+	tlsCert := flag.String("tls-cert", os.Getenv("OTLP_TLS_CERT"), "certificate to serve TLS with; plain connections are served when empty")
+	tlsKey := flag.String("tls-key", os.Getenv("OTLP_TLS_KEY"), "private key of the TLS certificate")
+	tlsClientCA := flag.String("tls-client-ca", os.Getenv("OTLP_TLS_CLIENT_CA"), "CA certificates exporters must present a certificate of; any exporter is accepted when empty")
+	userID := flag.String("user-id", os.Getenv("OTLP_USER_ID"), "user recorded for records without a userId or enduser.id attribute; the service name when empty")
+	action := flag.String("action", os.Getenv("OTLP_ACTION"), "action recorded for records without an action attribute or event name; LOG_<LEVEL> when empty")
+	maxMessageSize := flag.Int("max-message-size", otlp.DefaultMaxMessageSize, "largest export request read, in bytes after decompression")
+	batchSize := flag.Int("batch-size", client.DefaultMaxBatchSize, "most logs submitted per transaction")
+	flushInterval := flag.Duration("flush-interval", client.DefaultFlushInterval, "longest a log waits before its batch is submitted")
+	maxBuffered := flag.Int("max-buffered", 10000, "most logs waiting to be submitted before exporters are held back")
+	deadLetters := flag.String("dead-letter-dir", os.Getenv("DEAD_LETTER_DIR"), "directory keeping the logs the chaincode rejects, for fablog or the REST API to resubmit")
+	metricsAddr := flag.String("metrics-addr", os.Getenv("METRICS_ADDR"), "address to serve Prometheus metrics on at /metrics; not served when empty")
+	profile := flag.String("profile", "", "connection profile; CONNECTION_PROFILE_PATH is used when empty")
+	org := flag.String("org", "", "organization in the connection profile; ORG is used when empty")
+	flag.Parse()
+
+	if *grpcAddr == "" && *httpAddr == "" {
+		log.Fatal("nothing to serve: set -grpc-addr or -http-addr")
+	}
+	// Tokens are only read from the environment, so they do not show in the process list
+	tokens, err := parseTokens(os.Getenv("OTLP_TOKENS"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	var tlsConfig *tls.Config
+	if *tlsCert != "" {
+		if tlsConfig, err = client.ServerTLSConfig(*tlsCert, *tlsKey, *tlsClientCA); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	cfg, err := client.LoadConfig(*profile, *org)
+	if err != nil {
+		log.Fatalf("failed to load configuration: %v", err)
+	}
+	var clientOptions []client.Option
+	if *deadLetters != "" {
+		queue, err := client.NewDeadLetterQueue(*deadLetters)
+		if err != nil {
+			log.Fatalf("failed to open dead-letter queue: %v", err)
+		}
+		clientOptions = append(clientOptions, client.WithDeadLetterQueue(queue))
+	}
+	c, err := client.Connect(cfg, clientOptions...)
+	if err != nil {
+		log.Fatalf("failed to connect to gateway: %v", err)
+	}
+	defer c.Close()
+
+	submitter := client.NewBatchingSubmitter(c, client.BatchOptions{
+		MaxBatchSize:  *batchSize,
+		FlushInterval: *flushInterval,
+		MaxBuffered:   *maxBuffered,
+		OnError: func(logs []client.LogEvent, err error) {
+			log.Printf("failed to submit %d logs: %v", len(logs), err)
+		},
+	})
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		submitter.Close(ctx)
+	}()
+
+	server, err := otlp.NewServer(otlp.Options{
+		Submitter:      submitter,
+		Flush:          submitter.Flush,
+		Tokens:         tokens,
+		UserID:         *userID,
+		Action:         *action,
+		MaxMessageSize: *maxMessageSize,
+		OnError: func(err error) {
+			log.Print(err)
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *metricsAddr != "" {
+		metrics, err := client.ServeMetrics(*metricsAddr, nil)
+		if err != nil {
+			log.Fatalf("failed to serve metrics: %v", err)
+		}
+		defer metrics.Close()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	served := make(chan error, 2)
+	var grpcServer *grpc.Server
+	if *grpcAddr != "" {
+		listener, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			log.Fatalf("failed to listen on %s: %v", *grpcAddr, err)
+		}
+		var grpcOptions []grpc.ServerOption
+		if tlsConfig != nil {
+			grpcOptions = append(grpcOptions, grpc.Creds(credentials.NewTLS(tlsConfig)))
+		}
+		grpcServer = server.NewGRPCServer(grpcOptions...)
+		go func() {
+			log.Printf("receiving OTLP logs over gRPC on %s", listener.Addr())
+			served <- grpcServer.Serve(listener)
+		}()
+	}
+	var httpServer *http.Server
+	if *httpAddr != "" {
+		listener, err := net.Listen("tcp", *httpAddr)
+		if err != nil {
+			log.Fatalf("failed to listen on %s: %v", *httpAddr, err)
+		}
+		httpServer = &http.Server{
+			Handler:           server,
+			TLSConfig:         tlsConfig,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			log.Printf("receiving OTLP logs over HTTP on %s", listener.Addr())
+			if tlsConfig != nil {
+				served <- httpServer.ServeTLS(listener, "", "")
+			} else {
+				served <- httpServer.Serve(listener)
+			}
+		}()
+	}
+
+	select {
+	case <-ctx.Done():
+	case err := <-served:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Print(err)
+		}
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if httpServer != nil {
+		httpServer.Shutdown(shutdownCtx)
+	}
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-shutdownCtx.Done():
+			grpcServer.Stop()
+		}
+	}
+}
+
+// parseTokens reads comma-separated name=token pairs
+func parseTokens(value string) (map[string]string, error) {
+	tokens := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, token, ok := strings.Cut(pair, "=")
+		name, token = strings.TrimSpace(name), strings.TrimSpace(token)
+		if !ok || name == "" || token == "" {
+			return nil, fmt.Errorf("invalid OTLP token %q: want name=token", name)
+		}
+		tokens[token] = name
+	}
+	return tokens, nil
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
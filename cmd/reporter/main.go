@@ -0,0 +1,197 @@
+// Command reporter publishes compliance reports of the logging chaincode on a cron schedule. Each
+// report covers the logs of one time window, is rendered as HTML and PDF, signed with the client's
+// Fabric identity, and emailed or written to object storage (see package report).
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/archive"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/report"
+)
+
+func main() {
+	schedule := flag.String("schedule", envOr("REPORT_SCHEDULE", "0 6 * * *"), "cron schedule of the reports, such as \"0 6 * * 1\" or @daily")
+	timezone := flag.String("timezone", envOr("REPORT_TIMEZONE", "UTC"), "time zone the schedule is read in, such as Europe/Berlin")
+	window := flag.Duration("window", 0, "span of log timestamps each report covers, ending at the scheduled time; the time since the previous scheduled time when zero")
+	formats := flag.String("formats", "html,pdf", "comma-separated report formats, html and pdf")
+	title := flag.String("title", "Compliance report", "title of the reports")
+	severity := flag.String("severity", report.DefaultSeverity, "least level of the logs listed as high severity")
+	maxEvents := flag.Int("max-events", report.DefaultMaxEvents, "most high-severity logs listed in a report")
+	hashChain := flag.String("hash-chain", os.Getenv("HASH_CHAIN_PATH"), "hash chain file of a client's submitted logs, whose entries in each window are checked against the ledger")
+	store := flag.String("store", os.Getenv("REPORT_STORE"), "where reports are written: s3://bucket/prefix, gs://bucket/prefix, azblob://container/prefix or a directory")
+	smtpAddr := flag.String("smtp-addr", os.Getenv("SMTP_ADDR"), "host:port of the SMTP server reports are emailed through")
+	smtpUser := flag.String("smtp-user", os.Getenv("SMTP_USERNAME"), "SMTP user name; the password is read from SMTP_PASSWORD")
+	mailFrom := flag.String("mail-from", os.Getenv("MAIL_FROM"), "sender address of report emails")
+	mailTo := flag.String("mail-to", os.Getenv("MAIL_TO"), "comma-separated recipients of report emails")
+	once := flag.Bool("once", false, "publish the report of the latest scheduled time that has passed and exit")
+	verify := flag.String("verify", "", "check a report file against its signature in <file>.sig.json and exit")
+	caCert := flag.String("ca-cert", "", "with -verify, PEM file of the CA certificates the signer must chain to")
+	profile := flag.String("profile", "", "connection profile; CONNECTION_PROFILE_PATH is used when empty")
+	org := flag.String("org", "", "organization in the connection profile; ORG is used when empty")
+	flag.Parse()
+
+	if *verify != "" {
+		if err := verifyReport(*verify, *caCert); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	sched, err := report.ParseSchedule(*schedule)
+	if err != nil {
+		log.Fatal(err)
+	}
+	location, err := time.LoadLocation(*timezone)
+	if err != nil {
+		log.Fatalf("invalid time zone %q: %v", *timezone, err)
+	}
+	var reportFormats []report.Format
+	for _, format := range strings.Split(*formats, ",") {
+		reportFormats = append(reportFormats, report.Format(strings.TrimSpace(format)))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var deliveries []report.Delivery
+	if *store != "" {
+		s, err := archive.OpenStore(ctx, *store)
+		if err != nil {
+			log.Fatal(err)
+		}
+		deliveries = append(deliveries, report.StoreDelivery{Store: s})
+	}
+	if *smtpAddr != "" {
+		if *mailFrom == "" || *mailTo == "" {
+			log.Fatal("-mail-from and -mail-to are required to email reports")
+		}
+		mail := report.MailDelivery{Addr: *smtpAddr, From: *mailFrom}
+		for _, to := range strings.Split(*mailTo, ",") {
+			mail.To = append(mail.To, strings.TrimSpace(to))
+		}
+		if *smtpUser != "" {
+			host, _, err := net.SplitHostPort(*smtpAddr)
+			if err != nil {
+				log.Fatalf("invalid SMTP address %q: %v", *smtpAddr, err)
+			}
+			mail.Auth = smtp.PlainAuth("", *smtpUser, os.Getenv("SMTP_PASSWORD"), host)
+		}
+		deliveries = append(deliveries, mail)
+	}
+	if len(deliveries) == 0 {
+		log.Fatal("no delivery configured: set -store, -smtp-addr or both")
+	}
+
+	cfg, err := client.LoadConfig(*profile, *org)
+	if err != nil {
+		log.Fatalf("failed to load configuration: %v", err)
+	}
+	c, err := client.Connect(cfg)
+	if err != nil {
+		log.Fatalf("failed to connect to gateway: %v", err)
+	}
+	defer c.Close()
+
+	generate := report.GenerateOptions{
+		Title:     *title,
+		Source:    cfg.ChannelName,
+		Severity:  *severity,
+		MaxEvents: *maxEvents,
+	}
+	if *hashChain != "" {
+		verifier, err := client.NewVerifier(*hashChain)
+		if err != nil {
+			log.Fatalf("failed to open hash chain: %v", err)
+		}
+		defer verifier.Close()
+		generate.Verifier = verifier
+	}
+
+	reporter, err := report.NewReporter(c, report.Options{
+		Schedule:   sched,
+		Location:   location,
+		Window:     *window,
+		Formats:    reportFormats,
+		Deliveries: deliveries,
+		Generate:   generate,
+		OnError: func(err error) {
+			log.Printf("failed to publish report: %v", err)
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *once {
+		files, err := reporter.PublishLatest(ctx)
+		if err != nil {
+			log.Fatalf("failed to publish report: %v", err)
+		}
+		for _, file := range files {
+			log.Printf("published %s", file.Name)
+		}
+		return
+	}
+
+	log.Printf("publishing reports on schedule %q in %s", *schedule, location)
+	if err := reporter.Run(ctx); err != nil && ctx.Err() == nil {
+		log.Fatal(err)
+	}
+}
+
+// verifyReport checks the report file at path against its detached signature and, when caPath
+// is set, the signer against the CA certificates in it
+func verifyReport(path string, caPath string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	signatureJSON, err := os.ReadFile(path + report.SignatureSuffix)
+	if err != nil {
+		return err
+	}
+	var signature report.Signature
+	if err := json.Unmarshal(signatureJSON, &signature); err != nil {
+		return fmt.Errorf("failed to parse signature: %v", err)
+	}
+
+	var roots *x509.CertPool
+	if caPath != "" {
+		caPEM, err := os.ReadFile(caPath)
+		if err != nil {
+			return err
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(caPEM) {
+			return fmt.Errorf("no certificates found in %s", caPath)
+		}
+	}
+
+	cert, err := report.Verify(data, &signature, roots)
+	if err != nil {
+		return err
+	}
+	log.Printf("%s was signed by %s of %s at %s", path, cert.Subject.CommonName, signature.MSPID, signature.SignedAt.Format(time.RFC3339))
+	return nil
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
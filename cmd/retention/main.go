@@ -0,0 +1,115 @@
+// Command retention applies the retention policy recorded on the ledger on a cron schedule,
+// pruning the logs past it in bounded batches and archiving them first when the policy asks for
+// it (see package retention). The identity it connects with must hold the logging.retention
+// attribute.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/archive"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/export"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/report"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/retention"
+)
+
+func main() {
+	schedule := flag.String("schedule", envOr("RETENTION_SCHEDULE", "@daily"), "cron schedule of the runs, such as \"30 2 * * *\" or @daily")
+	timezone := flag.String("timezone", envOr("RETENTION_TIMEZONE", "UTC"), "time zone the schedule is read in, such as Europe/Berlin")
+	batchSize := flag.Int("batch-size", retention.DefaultBatchSize, "most logs pruned per transaction")
+	maxBatches := flag.Int("max-batches", retention.DefaultMaxBatches, "most transactions per run; the rest is left to the next run")
+	dryRun := flag.Bool("dry-run", os.Getenv("RETENTION_DRY_RUN") == "true", "only report the logs past the policy, without archiving or pruning them")
+	store := flag.String("store", os.Getenv("RETENTION_STORE"), "where logs are archived when the policy asks for it: s3://bucket/prefix, gs://bucket/prefix, azblob://container/prefix or a directory")
+	format := flag.String("format", string(export.FormatNDJSON), "archive format: ndjson, gzip compressed, or parquet")
+	once := flag.Bool("once", false, "apply the policy once and exit")
+	metricsAddr := flag.String("metrics-addr", os.Getenv("METRICS_ADDR"), "address to serve Prometheus metrics on at /metrics; not served when empty")
+	profile := flag.String("profile", "", "connection profile; CONNECTION_PROFILE_PATH is used when empty")
+	org := flag.String("org", "", "organization in the connection profile; ORG is used when empty")
+	flag.Parse()
+
+	sched, err := report.ParseSchedule(*schedule)
+	if err != nil {
+		log.Fatal(err)
+	}
+	location, err := time.LoadLocation(*timezone)
+	if err != nil {
+		log.Fatalf("invalid time zone %q: %v", *timezone, err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	options := retention.Options{
+		Schedule:   sched,
+		Location:   location,
+		BatchSize:  *batchSize,
+		MaxBatches: *maxBatches,
+		DryRun:     *dryRun,
+		Format:     export.Format(*format),
+		OnResult: func(result *retention.Result) {
+			log.Print(result.Summary())
+		},
+		OnError: func(err error) {
+			log.Printf("failed to apply retention policy: %v", err)
+		},
+	}
+	if *store != "" {
+		options.Store, err = archive.OpenStore(ctx, *store)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	cfg, err := client.LoadConfig(*profile, *org)
+	if err != nil {
+		log.Fatalf("failed to load configuration: %v", err)
+	}
+	c, err := client.Connect(cfg)
+	if err != nil {
+		log.Fatalf("failed to connect to gateway: %v", err)
+	}
+	defer c.Close()
+
+	service, err := retention.NewService(c, options)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *once {
+		result, err := service.Apply(ctx)
+		if result != nil {
+			log.Print(result.Summary())
+		}
+		if err != nil {
+			log.Fatalf("failed to apply retention policy: %v", err)
+		}
+		return
+	}
+
+	if *metricsAddr != "" {
+		metrics, err := client.ServeMetrics(*metricsAddr, nil)
+		if err != nil {
+			log.Fatalf("failed to serve metrics: %v", err)
+		}
+		defer metrics.Close()
+	}
+
+	log.Printf("applying the retention policy on schedule %q in %s", *schedule, location)
+	if err := service.Run(ctx); err != nil && ctx.Err() == nil {
+		log.Fatal(err)
+	}
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
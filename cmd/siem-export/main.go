@@ -0,0 +1,96 @@
+// Command siem-export streams the logs committed on the ledger to a SIEM such as ArcSight or
+// QRadar (see package siem): it formats each log as a CEF or LEEF event, with the fields of an
+// optional mapping file, and sends it over syslog on TCP, TLS or UDP, checkpointing the last
+// transaction sent so a restart resumes after it.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/projection"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/siem"
+)
+
+func main() {
+	addr := flag.String("addr", os.Getenv("SIEM_ADDR"), "host and port of the SIEM's syslog listener")
+	network := flag.String("network", envOr("SIEM_NETWORK", siem.DefaultNetwork), "tcp or udp")
+	format := flag.String("format", envOr("SIEM_FORMAT", siem.FormatCEF), "event format: cef or leef")
+	mappingPath := flag.String("mapping", os.Getenv("SIEM_MAPPING"), "mapping file of the templates choosing the event fields; the defaults are used when empty")
+	framing := flag.String("framing", os.Getenv("SIEM_FRAMING"), "octet-counting or newline framing over TCP; octet-counting with TLS and newline otherwise when empty")
+	facility := flag.String("facility", envOr("SIEM_FACILITY", siem.DefaultFacility), "syslog facility of the messages, such as local4")
+	tlsCA := flag.String("tls-ca", os.Getenv("SIEM_TLS_CA"), "CA certificates of the SIEM, enabling TLS; the system pool is used when empty")
+	tlsCert := flag.String("tls-cert", os.Getenv("SIEM_TLS_CERT"), "client certificate presented to the SIEM, enabling TLS")
+	tlsKey := flag.String("tls-key", os.Getenv("SIEM_TLS_KEY"), "private key of the client certificate")
+	useTLS := flag.Bool("tls", os.Getenv("SIEM_TLS") == "true", "send over TLS, trusting the system pool unless -tls-ca is set")
+	checkpointPath := flag.String("checkpoint", envOr("SIEM_CHECKPOINT_PATH", "siem-export.checkpoint"), "file recording the last transaction sent, so a restart resumes after it")
+	profile := flag.String("profile", "", "connection profile; CONNECTION_PROFILE_PATH is used when empty")
+	org := flag.String("org", "", "organization in the connection profile; ORG is used when empty")
+	flag.Parse()
+
+	if *addr == "" {
+		log.Fatal("no SIEM address configured: set -addr or SIEM_ADDR")
+	}
+	var mapping siem.Mapping
+	if *mappingPath != "" {
+		var err error
+		if mapping, err = siem.LoadMapping(*mappingPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+	formatter, err := siem.NewFormatter(*format, mapping)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var tlsConfig *tls.Config
+	if *useTLS || *tlsCA != "" || *tlsCert != "" {
+		if tlsConfig, err = client.ClientTLSConfig(*tlsCA, *tlsCert, *tlsKey); err != nil {
+			log.Fatal(err)
+		}
+	}
+	sink, err := siem.NewSink(siem.Config{
+		Address:  *addr,
+		Network:  *network,
+		TLS:      tlsConfig,
+		Framing:  *framing,
+		Facility: *facility,
+	}, formatter)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer sink.Close()
+
+	cfg, err := client.LoadConfig(*profile, *org)
+	if err != nil {
+		log.Fatalf("failed to load configuration: %v", err)
+	}
+	c, err := client.Connect(cfg)
+	if err != nil {
+		log.Fatalf("failed to connect to gateway: %v", err)
+	}
+	defer c.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	projector := projection.New(projection.NewEventSource(c, nil), sink, projection.Options{
+		Checkpointer: client.NewFileCheckpointer(*checkpointPath),
+	})
+	log.Printf("exporting committed logs as %s events to %s over %s", *format, *addr, *network)
+	if err := projector.Run(ctx); err != nil && ctx.Err() == nil {
+		log.Printf("export stopped: %v", err)
+	}
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,75 @@
+// Command stats-exporter exposes statistics of the logs on the ledger as Prometheus gauges,
+// scanning the world state at every interval and following its commits (see package stats).
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/stats"
+)
+
+func main() {
+	metricsAddr := flag.String("metrics-addr", envOr("METRICS_ADDR", ":9464"), "address to serve Prometheus metrics on at /metrics")
+	interval := flag.Duration("interval", stats.DefaultInterval, "time between scans of the ledger, each reading every log")
+	pageSize := flag.Int("page-size", stats.DefaultPageSize, "logs read per query page")
+	alertSeverity := flag.String("alert-severity", envOr("STATS_ALERT_SEVERITY", stats.DefaultAlertSeverity), "least level of the logs counted as alerts")
+	maxActions := flag.Int("max-actions", stats.DefaultMaxActions, "most actions labelling logs_by_action; the logs of the others are counted as other")
+	checkpointPath := flag.String("checkpoint", os.Getenv("STATS_CHECKPOINT_PATH"), "file recording the latest commit, so a restart reports last_commit_block before the next one")
+	profile := flag.String("profile", "", "connection profile; CONNECTION_PROFILE_PATH is used when empty")
+	org := flag.String("org", "", "organization in the connection profile; ORG is used when empty")
+	flag.Parse()
+
+	cfg, err := client.LoadConfig(*profile, *org)
+	if err != nil {
+		log.Fatalf("failed to load configuration: %v", err)
+	}
+	c, err := client.Connect(cfg)
+	if err != nil {
+		log.Fatalf("failed to connect to gateway: %v", err)
+	}
+	defer c.Close()
+
+	options := stats.Options{
+		Interval:      *interval,
+		PageSize:      int32(*pageSize),
+		AlertSeverity: *alertSeverity,
+		MaxActions:    *maxActions,
+		OnError: func(err error) {
+			log.Printf("failed to scan the ledger: %v", err)
+		},
+	}
+	if *checkpointPath != "" {
+		options.Checkpointer = client.NewFileCheckpointer(*checkpointPath)
+	}
+	exporter, err := stats.NewExporter(c, options)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	metrics, err := client.ServeMetrics(*metricsAddr, nil)
+	if err != nil {
+		log.Fatalf("failed to serve metrics: %v", err)
+	}
+	defer metrics.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("exporting ledger statistics of channel %s every %s on %s", cfg.ChannelName, *interval, *metricsAddr)
+	if err := exporter.Run(ctx); err != nil && ctx.Err() == nil {
+		log.Fatal(err)
+	}
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
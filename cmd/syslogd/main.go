@@ -0,0 +1,158 @@
+// Command syslogd receives syslog messages over UDP, TCP and TLS and records them on the ledger in
+// batched transactions (see package syslog), so network devices and legacy systems feed the audit
+// trail without changes.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/syslog"
+)
+
+func main() {
+	udpAddr := flag.String("udp-addr", envOr("SYSLOG_UDP_ADDR", ":5514"), "address to receive syslog datagrams on; not served when empty")
+	tcpAddr := flag.String("tcp-addr", envOr("SYSLOG_TCP_ADDR", ":5514"), "address to receive syslog over TCP on; not served when empty")
+	tlsAddr := flag.String("tls-addr", os.Getenv("SYSLOG_TLS_ADDR"), "address to receive syslog over TLS on, usually :6514; not served when empty")
+	tlsCert := flag.String("tls-cert", os.Getenv("SYSLOG_TLS_CERT"), "certificate of the TLS listener")
+	tlsKey := flag.String("tls-key", os.Getenv("SYSLOG_TLS_KEY"), "private key of the TLS listener")
+	tlsClientCA := flag.String("tls-client-ca", os.Getenv("SYSLOG_TLS_CLIENT_CA"), "CA certificates TLS senders must present a certificate of; any sender is accepted when empty")
+	timezone := flag.String("timezone", envOr("SYSLOG_TIMEZONE", "Local"), "time zone of RFC 3164 timestamps, which carry none, such as UTC")
+	userID := flag.String("user-id", os.Getenv("SYSLOG_USER_ID"), "user recorded for every message; the message's app name when empty")
+	action := flag.String("action", os.Getenv("SYSLOG_ACTION"), "action recorded for every message; the message ID, or SYSLOG_<SEVERITY>, when empty")
+	maxMessageSize := flag.Int("max-message-size", syslog.DefaultMaxMessageSize, "largest message read from a TCP or TLS stream")
+	batchSize := flag.Int("batch-size", client.DefaultMaxBatchSize, "most logs submitted per transaction")
+	flushInterval := flag.Duration("flush-interval", client.DefaultFlushInterval, "longest a log waits before its batch is submitted")
+	maxBuffered := flag.Int("max-buffered", 10000, "most logs waiting to be submitted before streams are held back and datagrams dropped")
+	deadLetters := flag.String("dead-letter-dir", os.Getenv("DEAD_LETTER_DIR"), "directory keeping the logs the chaincode rejects, for fablog or the REST API to resubmit")
+	metricsAddr := flag.String("metrics-addr", os.Getenv("METRICS_ADDR"), "address to serve Prometheus metrics on at /metrics; not served when empty")
+	profile := flag.String("profile", "", "connection profile; CONNECTION_PROFILE_PATH is used when empty")
+	org := flag.String("org", "", "organization in the connection profile; ORG is used when empty")
+	flag.Parse()
+
+	if *udpAddr == "" && *tcpAddr == "" && *tlsAddr == "" {
+		log.Fatal("no listener configured: set -udp-addr, -tcp-addr or -tls-addr")
+	}
+	location, err := time.LoadLocation(*timezone)
+	if err != nil {
+		log.Fatalf("invalid time zone %q: %v", *timezone, err)
+	}
+	var tlsConfig *tls.Config
+	if *tlsAddr != "" {
+		if tlsConfig, err = client.ServerTLSConfig(*tlsCert, *tlsKey, *tlsClientCA); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	cfg, err := client.LoadConfig(*profile, *org)
+	if err != nil {
+		log.Fatalf("failed to load configuration: %v", err)
+	}
+	var clientOptions []client.Option
+	if *deadLetters != "" {
+		queue, err := client.NewDeadLetterQueue(*deadLetters)
+		if err != nil {
+			log.Fatalf("failed to open dead-letter queue: %v", err)
+		}
+		clientOptions = append(clientOptions, client.WithDeadLetterQueue(queue))
+	}
+	c, err := client.Connect(cfg, clientOptions...)
+	if err != nil {
+		log.Fatalf("failed to connect to gateway: %v", err)
+	}
+	defer c.Close()
+
+	submitter := client.NewBatchingSubmitter(c, client.BatchOptions{
+		MaxBatchSize:  *batchSize,
+		FlushInterval: *flushInterval,
+		MaxBuffered:   *maxBuffered,
+		OnError: func(logs []client.LogEvent, err error) {
+			log.Printf("failed to submit %d logs: %v", len(logs), err)
+		},
+	})
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		submitter.Close(ctx)
+	}()
+
+	server, err := syslog.NewServer(syslog.Options{
+		Submitter:      submitter,
+		UserID:         *userID,
+		Action:         *action,
+		Location:       location,
+		MaxMessageSize: *maxMessageSize,
+		OnError: func(err error) {
+			log.Print(err)
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *metricsAddr != "" {
+		metrics, err := client.ServeMetrics(*metricsAddr, nil)
+		if err != nil {
+			log.Fatalf("failed to serve metrics: %v", err)
+		}
+		defer metrics.Close()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Every listener is opened before any is served, so a busy port fails the start
+	var serves []func() error
+	if *udpAddr != "" {
+		conn, err := net.ListenPacket("udp", *udpAddr)
+		if err != nil {
+			log.Fatalf("failed to listen on %s: %v", *udpAddr, err)
+		}
+		serves = append(serves, func() error { return server.ServeUDP(ctx, conn) })
+		log.Printf("receiving syslog over UDP on %s", conn.LocalAddr())
+	}
+	if *tcpAddr != "" {
+		listener, err := net.Listen("tcp", *tcpAddr)
+		if err != nil {
+			log.Fatalf("failed to listen on %s: %v", *tcpAddr, err)
+		}
+		serves = append(serves, func() error { return server.ServeTCP(ctx, listener) })
+		log.Printf("receiving syslog over TCP on %s", listener.Addr())
+	}
+	if *tlsAddr != "" {
+		listener, err := net.Listen("tcp", *tlsAddr)
+		if err != nil {
+			log.Fatalf("failed to listen on %s: %v", *tlsAddr, err)
+		}
+		serves = append(serves, func() error { return server.ServeTLS(ctx, listener, tlsConfig) })
+		log.Printf("receiving syslog over TLS on %s", listener.Addr())
+	}
+
+	errs := make(chan error, len(serves))
+	for _, serve := range serves {
+		go func(serve func() error) {
+			errs <- serve()
+		}(serve)
+	}
+	for range serves {
+		if err := <-errs; err != nil {
+			log.Printf("syslog listener failed: %v", err)
+			stop()
+		}
+	}
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
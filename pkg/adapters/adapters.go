@@ -0,0 +1,78 @@
+// Package adapters forwards entries from third-party logging libraries to the Fabric logging
+// client, so services using them get ledger-backed audit logs without changing their logging calls.
+package adapters
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// Options configures which entries an adapter forwards and how they map to logs
+type Options struct {
+	// Marker, when set, restricts forwarding to entries with a field of that name set to true,
+	// such as "audit". The marker field itself is not recorded.
+	Marker string
+	// UserID and Resource are used for entries without userId and resource fields
+	UserID   string
+	Resource string
+}
+
+// forwarder converts entries to log/slog records and submits them through a client.SlogHandler,
+// so every adapter maps entries to logs the same way
+type forwarder struct {
+	handler *client.SlogHandler
+	marker  string
+}
+
+func newForwarder(submitter client.Submitter, options Options) *forwarder {
+	handler := client.NewSlogHandler(submitter, client.SlogHandlerOptions{
+		UserID:   options.UserID,
+		Resource: options.Resource,
+	})
+
+	return &forwarder{handler: handler, marker: options.Marker}
+}
+
+// forward submits one entry. level names the entry's level in its library, and becomes the
+// action LOG_<LEVEL> unless fields hold an action.
+func (f *forwarder) forward(ctx context.Context, t time.Time, level string, message string, fields map[string]interface{}) error {
+	if f.marker != "" {
+		if !marked(fields[f.marker]) {
+			return nil
+		}
+		delete(fields, f.marker)
+	}
+
+	record := slog.NewRecord(t, slog.LevelInfo, message, 0)
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		record.AddAttrs(slog.Any(key, fields[key]))
+	}
+	if _, ok := fields[client.SlogActionKey]; !ok {
+		record.AddAttrs(slog.String(client.SlogActionKey, "LOG_"+level))
+	}
+	record.AddAttrs(slog.String("level", level))
+
+	return f.handler.Handle(ctx, record)
+}
+
+// marked reports whether a marker field value means the entry should be forwarded
+func marked(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	default:
+		return false
+	}
+}
@@ -0,0 +1,46 @@
+package adapters
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// LogrusHook is a logrus.Hook forwarding entries to the Fabric logging client
+type LogrusHook struct {
+	forwarder *forwarder
+	levels    []logrus.Level
+}
+
+// NewLogrusHook returns a hook forwarding entries at the given levels through submitter,
+// or entries at Info and above when no levels are given. Add it with logger.AddHook.
+func NewLogrusHook(submitter client.Submitter, levels []logrus.Level, options Options) *LogrusHook {
+	if len(levels) == 0 {
+		levels = []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel, logrus.WarnLevel, logrus.InfoLevel}
+	}
+
+	return &LogrusHook{forwarder: newForwarder(submitter, options), levels: levels}
+}
+
+// Levels returns the levels the hook fires for
+func (h *LogrusHook) Levels() []logrus.Level {
+	return h.levels
+}
+
+// Fire forwards entry
+func (h *LogrusHook) Fire(entry *logrus.Entry) error {
+	fields := make(map[string]interface{}, len(entry.Data))
+	for key, value := range entry.Data {
+		fields[key] = value
+	}
+
+	ctx := entry.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return h.forwarder.forward(ctx, entry.Time, strings.ToUpper(entry.Level.String()), entry.Message, fields)
+}
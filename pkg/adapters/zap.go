@@ -0,0 +1,57 @@
+package adapters
+
+import (
+	"context"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// ZapCore is a zapcore.Core forwarding entries to the Fabric logging client. Combine it with
+// an existing core using zapcore.NewTee to keep regular output.
+type ZapCore struct {
+	zapcore.LevelEnabler
+	forwarder *forwarder
+	fields    []zapcore.Field
+}
+
+// NewZapCore returns a core forwarding entries enabled by level through submitter
+func NewZapCore(submitter client.Submitter, level zapcore.LevelEnabler, options Options) *ZapCore {
+	return &ZapCore{LevelEnabler: level, forwarder: newForwarder(submitter, options)}
+}
+
+// With returns a core that adds fields to every entry
+func (c *ZapCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(append([]zapcore.Field(nil), c.fields...), fields...)
+
+	return &clone
+}
+
+// Check adds the core to checked when entry's level is enabled
+func (c *ZapCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+
+	return checked
+}
+
+// Write forwards entry with the core's fields and fields
+func (c *ZapCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	encoder := zapcore.NewMapObjectEncoder()
+	for _, field := range c.fields {
+		field.AddTo(encoder)
+	}
+	for _, field := range fields {
+		field.AddTo(encoder)
+	}
+
+	return c.forwarder.forward(context.Background(), entry.Time, entry.Level.CapitalString(), entry.Message, encoder.Fields)
+}
+
+// Sync does nothing; buffering is left to the Submitter
+func (c *ZapCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,361 @@
+// Package alert routes notifications about high-severity logs, as they commit, to Slack,
+// PagerDuty, Opsgenie and email. Routes select logs by severity and other criteria, render the
+// notification from templates, suppress repeats of the same alert within a dedup window, and
+// escalate to further receivers while an alert keeps firing.
+package alert
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// Route defaults
+const (
+	DefaultSeverity    = "ERROR"
+	DefaultDedupWindow = 10 * time.Minute
+)
+
+// DefaultGroupBy are the log fields identifying an alert when a route sets none
+var DefaultGroupBy = []string{"action", "resource"}
+
+// Route selects logs and notifies its receivers about them
+type Route struct {
+	Name string
+	// Severity is the least level of the logs matched; DefaultSeverity is used when empty.
+	// Logs without a level never match.
+	Severity string
+	// Action, UserID, Resource and Tag restrict the logs matched when set
+	Action   string
+	UserID   string
+	Resource string
+	Tag      string
+	// Receivers name the notifiers told about every alert
+	Receivers []string
+	// GroupBy names the log fields, among userId, action and resource, whose values identify an
+	// alert; DefaultGroupBy is used when empty
+	GroupBy []string
+	// DedupWindow is how long further logs of a notified alert are counted instead of
+	// notified; DefaultDedupWindow is used when zero. An alert with no logs for a whole window
+	// ends, and its next log starts it again.
+	DedupWindow time.Duration
+	// Escalations notify further receivers while an alert keeps firing, in order of After
+	Escalations []Escalation
+	// Title and Body are text/template templates executed with the Notification; a default
+	// is used when empty
+	Title string
+	Body  string
+}
+
+// Escalation notifies Receivers once when an alert still fires After its first log
+type Escalation struct {
+	After     time.Duration
+	Receivers []string
+}
+
+// Notification is one message about an alert
+type Notification struct {
+	Route string
+	// Key identifies the alert, from the route name and the values of its GroupBy fields
+	Key      string
+	Severity string
+	// Level is Severity as an slog level, for notifiers mapping it to their own priorities
+	Level         slog.Level
+	Log           client.LogEvent
+	BlockNumber   uint64
+	TransactionID string
+	// Count is the number of logs of the alert since the previous notification, this one included
+	Count int
+	// FirstSeen is when the alert started
+	FirstSeen time.Time
+	// Escalation is 0 for notifications of the route's receivers, and n for those of its nth
+	// escalation
+	Escalation int
+	// Title and Body are rendered from the route's templates
+	Title string
+	Body  string
+}
+
+// Notifier delivers notifications to one receiver
+type Notifier interface {
+	// Name identifies the notifier in errors
+	Name() string
+	Notify(ctx context.Context, n *Notification) error
+}
+
+// Options configures a Router
+type Options struct {
+	Routes []Route
+	// Notifiers are the receivers routes name
+	Notifiers map[string]Notifier
+	// OnError is called with failed notifications, which are not retried
+	OnError func(error)
+}
+
+// Router matches committed logs against routes and sends the notifications they call for
+type Router struct {
+	routes    []*compiledRoute
+	notifiers map[string]Notifier
+	onError   func(error)
+
+	mu     sync.Mutex
+	alerts map[string]*alertState
+}
+
+// compiledRoute is a Route with its severity parsed and templates compiled
+type compiledRoute struct {
+	Route
+	level slog.Level
+	title *template.Template
+	body  *template.Template
+}
+
+// alertState tracks one firing alert
+type alertState struct {
+	// window is the dedup window of the alert's route
+	window       time.Duration
+	firstSeen    time.Time
+	lastSeen     time.Time
+	lastNotified time.Time
+	// suppressed counts the logs since the last notification
+	suppressed int
+	// escalated is the number of escalations notified
+	escalated int
+}
+
+// NewRouter checks the routes and returns a Router
+func NewRouter(options Options) (*Router, error) {
+	r := &Router{
+		notifiers: options.Notifiers,
+		onError:   options.OnError,
+		alerts:    make(map[string]*alertState),
+	}
+
+	names := make(map[string]bool)
+	for _, route := range options.Routes {
+		if route.Name == "" {
+			return nil, fmt.Errorf("a route has no name")
+		}
+		if names[route.Name] {
+			return nil, fmt.Errorf("route %q is defined twice", route.Name)
+		}
+		names[route.Name] = true
+
+		compiled, err := compileRoute(route)
+		if err != nil {
+			return nil, fmt.Errorf("invalid route %q: %v", route.Name, err)
+		}
+		if len(route.Receivers) == 0 {
+			return nil, fmt.Errorf("route %q has no receivers", route.Name)
+		}
+		receivers := append([]string(nil), route.Receivers...)
+		for _, escalation := range route.Escalations {
+			receivers = append(receivers, escalation.Receivers...)
+		}
+		for _, receiver := range receivers {
+			if _, ok := options.Notifiers[receiver]; !ok {
+				return nil, fmt.Errorf("route %q names unknown receiver %q", route.Name, receiver)
+			}
+		}
+		r.routes = append(r.routes, compiled)
+	}
+
+	return r, nil
+}
+
+func compileRoute(route Route) (*compiledRoute, error) {
+	if route.Severity == "" {
+		route.Severity = DefaultSeverity
+	}
+	level, ok := client.ParseSeverity(route.Severity)
+	if !ok {
+		return nil, fmt.Errorf("unknown severity %q", route.Severity)
+	}
+	if len(route.GroupBy) == 0 {
+		route.GroupBy = DefaultGroupBy
+	}
+	for _, field := range route.GroupBy {
+		if _, ok := groupValue(client.LogEvent{}, field); !ok {
+			return nil, fmt.Errorf("cannot group by %q: expected userId, action or resource", field)
+		}
+	}
+	if route.DedupWindow <= 0 {
+		route.DedupWindow = DefaultDedupWindow
+	}
+	for i := 1; i < len(route.Escalations); i++ {
+		if route.Escalations[i].After <= route.Escalations[i-1].After {
+			return nil, fmt.Errorf("escalations must be in increasing order of after")
+		}
+	}
+
+	if route.Title == "" {
+		route.Title = DefaultTitle
+	}
+	if route.Body == "" {
+		route.Body = DefaultBody
+	}
+	title, err := template.New("title").Option("missingkey=error").Parse(route.Title)
+	if err != nil {
+		return nil, fmt.Errorf("invalid title template: %v", err)
+	}
+	body, err := template.New("body").Option("missingkey=error").Parse(route.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid body template: %v", err)
+	}
+
+	return &compiledRoute{Route: route, level: level, title: title, body: body}, nil
+}
+
+// Handle matches a committed log against every route and sends the notifications due. It
+// returns once they are sent; failures are passed to OnError.
+func (r *Router) Handle(ctx context.Context, event client.ContractEvent) {
+	level, ok := client.LogSeverity(event.Log)
+	if !ok {
+		return
+	}
+
+	for _, route := range r.routes {
+		if !route.matches(event.Log, level) {
+			continue
+		}
+		for _, n := range r.fire(route, event, level) {
+			r.send(ctx, route, n)
+		}
+	}
+}
+
+// matches applies the route's criteria to log
+func (route *compiledRoute) matches(log client.LogEvent, level slog.Level) bool {
+	if level < route.level {
+		return false
+	}
+	if (route.Action != "" && log.Action != route.Action) ||
+		(route.UserID != "" && log.UserID != route.UserID) ||
+		(route.Resource != "" && log.Resource != route.Resource) {
+		return false
+	}
+	if route.Tag != "" {
+		for _, tag := range client.LogTags(log) {
+			if tag == route.Tag {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// fire records a log of the route's alert and returns the notifications it calls for: one
+// for the route's receivers when the alert is new or its dedup window has passed, and one for
+// each escalation that has come due
+func (r *Router) fire(route *compiledRoute, event client.ContractEvent, level slog.Level) []*Notification {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.expire(now)
+
+	key := route.Name
+	for _, field := range route.GroupBy {
+		value, _ := groupValue(event.Log, field)
+		key += "/" + value
+	}
+
+	state, ok := r.alerts[key]
+	if !ok {
+		state = &alertState{window: route.DedupWindow, firstSeen: now}
+		r.alerts[key] = state
+	}
+	state.lastSeen = now
+	state.suppressed++
+
+	notification := func(escalation int) *Notification {
+		return &Notification{
+			Route:         route.Name,
+			Key:           key,
+			Severity:      level.String(),
+			Level:         level,
+			Log:           event.Log,
+			BlockNumber:   event.BlockNumber,
+			TransactionID: event.TransactionID,
+			Count:         state.suppressed,
+			FirstSeen:     state.firstSeen,
+			Escalation:    escalation,
+		}
+	}
+
+	var notifications []*Notification
+	if state.lastNotified.IsZero() || now.Sub(state.lastNotified) >= route.DedupWindow {
+		notifications = append(notifications, notification(0))
+	}
+	for state.escalated < len(route.Escalations) && now.Sub(state.firstSeen) >= route.Escalations[state.escalated].After {
+		state.escalated++
+		notifications = append(notifications, notification(state.escalated))
+	}
+	if len(notifications) > 0 {
+		state.lastNotified = now
+		state.suppressed = 0
+	}
+	return notifications
+}
+
+// expire ends the alerts that have had no logs for a dedup window of their route
+func (r *Router) expire(now time.Time) {
+	for key, state := range r.alerts {
+		if now.Sub(state.lastSeen) >= state.window {
+			delete(r.alerts, key)
+		}
+	}
+}
+
+// send renders a notification and delivers it to the receivers of its escalation level
+func (r *Router) send(ctx context.Context, route *compiledRoute, n *Notification) {
+	var title, body strings.Builder
+	if err := route.title.Execute(&title, n); err != nil {
+		r.report(fmt.Errorf("failed to render title of route %q: %v", route.Name, err))
+		return
+	}
+	if err := route.body.Execute(&body, n); err != nil {
+		r.report(fmt.Errorf("failed to render body of route %q: %v", route.Name, err))
+		return
+	}
+	n.Title = strings.TrimSpace(title.String())
+	n.Body = strings.TrimSpace(body.String())
+
+	receivers := route.Receivers
+	if n.Escalation > 0 {
+		receivers = route.Escalations[n.Escalation-1].Receivers
+	}
+	for _, receiver := range receivers {
+		notifier := r.notifiers[receiver]
+		if err := notifier.Notify(ctx, n); err != nil {
+			r.report(fmt.Errorf("failed to notify %s of alert %s: %v", notifier.Name(), n.Key, err))
+		}
+	}
+}
+
+func (r *Router) report(err error) {
+	if r.onError != nil {
+		r.onError(err)
+	}
+}
+
+// groupValue returns the value of a GroupBy field of log
+func groupValue(log client.LogEvent, field string) (string, bool) {
+	switch field {
+	case "userId":
+		return log.UserID, true
+	case "action":
+		return log.Action, true
+	case "resource":
+		return log.Resource, true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,154 @@
+package alert
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config is an alert routing file, in YAML or JSON. Secrets, such as webhook URLs, routing
+// keys, API keys and passwords, may be given as ${NAME} to read them from the environment.
+type Config struct {
+	Receivers []ReceiverConfig `json:"receivers" yaml:"receivers"`
+	Routes    []RouteConfig    `json:"routes" yaml:"routes"`
+}
+
+// ReceiverConfig names one notifier; exactly one of its notifier fields is set
+type ReceiverConfig struct {
+	Name  string `json:"name" yaml:"name"`
+	Slack *struct {
+		WebhookURL string `json:"webhookURL" yaml:"webhookURL"`
+	} `json:"slack,omitempty" yaml:"slack,omitempty"`
+	PagerDuty *struct {
+		RoutingKey string `json:"routingKey" yaml:"routingKey"`
+		URL        string `json:"url,omitempty" yaml:"url,omitempty"`
+	} `json:"pagerDuty,omitempty" yaml:"pagerDuty,omitempty"`
+	Opsgenie *struct {
+		APIKey string `json:"apiKey" yaml:"apiKey"`
+		URL    string `json:"url,omitempty" yaml:"url,omitempty"`
+	} `json:"opsgenie,omitempty" yaml:"opsgenie,omitempty"`
+	Email *struct {
+		SMTPAddr string   `json:"smtpAddr" yaml:"smtpAddr"`
+		Username string   `json:"username,omitempty" yaml:"username,omitempty"`
+		Password string   `json:"password,omitempty" yaml:"password,omitempty"`
+		From     string   `json:"from" yaml:"from"`
+		To       []string `json:"to" yaml:"to"`
+	} `json:"email,omitempty" yaml:"email,omitempty"`
+}
+
+// RouteConfig is a Route in a Config, with durations such as "10m"
+type RouteConfig struct {
+	Name        string             `json:"name" yaml:"name"`
+	Severity    string             `json:"severity,omitempty" yaml:"severity,omitempty"`
+	Action      string             `json:"action,omitempty" yaml:"action,omitempty"`
+	UserID      string             `json:"userId,omitempty" yaml:"userId,omitempty"`
+	Resource    string             `json:"resource,omitempty" yaml:"resource,omitempty"`
+	Tag         string             `json:"tag,omitempty" yaml:"tag,omitempty"`
+	Receivers   []string           `json:"receivers" yaml:"receivers"`
+	GroupBy     []string           `json:"groupBy,omitempty" yaml:"groupBy,omitempty"`
+	DedupWindow string             `json:"dedupWindow,omitempty" yaml:"dedupWindow,omitempty"`
+	Escalations []EscalationConfig `json:"escalations,omitempty" yaml:"escalations,omitempty"`
+	Title       string             `json:"title,omitempty" yaml:"title,omitempty"`
+	Body        string             `json:"body,omitempty" yaml:"body,omitempty"`
+}
+
+// EscalationConfig is an Escalation in a Config
+type EscalationConfig struct {
+	After     string   `json:"after" yaml:"after"`
+	Receivers []string `json:"receivers" yaml:"receivers"`
+}
+
+// LoadConfig reads the alert routing file at path
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alert configuration: %v", err)
+	}
+
+	var cfg Config
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse alert configuration %s: %v", path, err)
+	}
+	return &cfg, nil
+}
+
+// Options returns the router options the configuration describes. Notifiers post with
+// httpClient, or http.DefaultClient when it is nil.
+func (cfg *Config) Options(httpClient *http.Client) (Options, error) {
+	options := Options{Notifiers: make(map[string]Notifier)}
+
+	for _, receiver := range cfg.Receivers {
+		if receiver.Name == "" {
+			return Options{}, fmt.Errorf("a receiver has no name")
+		}
+		if _, ok := options.Notifiers[receiver.Name]; ok {
+			return Options{}, fmt.Errorf("receiver %q is defined twice", receiver.Name)
+		}
+
+		var notifiers []Notifier
+		if s := receiver.Slack; s != nil {
+			notifiers = append(notifiers, &Slack{WebhookURL: os.ExpandEnv(s.WebhookURL), HTTPClient: httpClient})
+		}
+		if p := receiver.PagerDuty; p != nil {
+			notifiers = append(notifiers, &PagerDuty{RoutingKey: os.ExpandEnv(p.RoutingKey), URL: p.URL, HTTPClient: httpClient})
+		}
+		if o := receiver.Opsgenie; o != nil {
+			notifiers = append(notifiers, &Opsgenie{APIKey: os.ExpandEnv(o.APIKey), URL: o.URL, HTTPClient: httpClient})
+		}
+		if e := receiver.Email; e != nil {
+			if e.SMTPAddr == "" || e.From == "" || len(e.To) == 0 {
+				return Options{}, fmt.Errorf("email receiver %q needs smtpAddr, from and to", receiver.Name)
+			}
+			email := &Email{Addr: e.SMTPAddr, From: e.From, To: e.To}
+			if e.Username != "" {
+				host, _, err := net.SplitHostPort(e.SMTPAddr)
+				if err != nil {
+					return Options{}, fmt.Errorf("invalid SMTP address of receiver %q: %v", receiver.Name, err)
+				}
+				email.Auth = smtp.PlainAuth("", e.Username, os.ExpandEnv(e.Password), host)
+			}
+			notifiers = append(notifiers, email)
+		}
+		if len(notifiers) != 1 {
+			return Options{}, fmt.Errorf("receiver %q must set exactly one of slack, pagerDuty, opsgenie and email", receiver.Name)
+		}
+		options.Notifiers[receiver.Name] = notifiers[0]
+	}
+
+	for _, rc := range cfg.Routes {
+		route := Route{
+			Name:      rc.Name,
+			Severity:  rc.Severity,
+			Action:    rc.Action,
+			UserID:    rc.UserID,
+			Resource:  rc.Resource,
+			Tag:       rc.Tag,
+			Receivers: rc.Receivers,
+			GroupBy:   rc.GroupBy,
+			Title:     rc.Title,
+			Body:      rc.Body,
+		}
+		if rc.DedupWindow != "" {
+			window, err := time.ParseDuration(rc.DedupWindow)
+			if err != nil {
+				return Options{}, fmt.Errorf("invalid dedup window of route %q: %v", rc.Name, err)
+			}
+			route.DedupWindow = window
+		}
+		for _, ec := range rc.Escalations {
+			after, err := time.ParseDuration(ec.After)
+			if err != nil {
+				return Options{}, fmt.Errorf("invalid escalation of route %q: %v", rc.Name, err)
+			}
+			route.Escalations = append(route.Escalations, Escalation{After: after, Receivers: ec.Receivers})
+		}
+		options.Routes = append(options.Routes, route)
+	}
+
+	return options, nil
+}
@@ -0,0 +1,227 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// Default notification templates
+const (
+	DefaultTitle = `[{{.Severity}}] {{.Log.Action}} on {{.Log.Resource}}{{if .Escalation}} (escalation {{.Escalation}}){{end}}`
+	DefaultBody  = `{{.Log.Description}}
+
+User: {{.Log.UserID}}
+Time: {{.Log.Timestamp}}
+Log: {{.Log.ID}}
+Transaction: {{.TransactionID}} in block {{.BlockNumber}}
+{{- if gt .Count 1}}
+{{.Count}} logs of this alert since the last notification; firing since {{.FirstSeen.UTC.Format "2006-01-02 15:04:05 UTC"}}.
+{{- end}}`
+)
+
+// Service endpoints used when a notifier sets none
+const (
+	DefaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+	DefaultOpsgenieURL  = "https://api.opsgenie.com"
+)
+
+// Slack posts notifications to a Slack incoming webhook
+type Slack struct {
+	WebhookURL string
+	HTTPClient *http.Client
+}
+
+// Name returns "slack"
+func (s *Slack) Name() string {
+	return "slack"
+}
+
+// Notify posts the title in bold followed by the body
+func (s *Slack) Notify(ctx context.Context, n *Notification) error {
+	return postJSON(ctx, s.HTTPClient, s.WebhookURL, nil, map[string]string{
+		"text": fmt.Sprintf("*%s*\n%s", slackEscaper.Replace(n.Title), slackEscaper.Replace(n.Body)),
+	})
+}
+
+// slackEscaper escapes the characters Slack reads as markup
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// PagerDuty triggers incidents through the PagerDuty Events API v2. The alert key is the
+// incident's dedup key, so PagerDuty folds the notifications of one alert into one incident.
+type PagerDuty struct {
+	RoutingKey string
+	// URL is DefaultPagerDutyURL when empty
+	URL        string
+	HTTPClient *http.Client
+}
+
+// Name returns "pagerduty"
+func (p *PagerDuty) Name() string {
+	return "pagerduty"
+}
+
+// Notify sends a trigger event
+func (p *PagerDuty) Notify(ctx context.Context, n *Notification) error {
+	url := p.URL
+	if url == "" {
+		url = DefaultPagerDutyURL
+	}
+
+	severity := "info"
+	switch {
+	case n.Level >= slog.LevelError+4:
+		severity = "critical"
+	case n.Level >= slog.LevelError:
+		severity = "error"
+	case n.Level >= slog.LevelWarn:
+		severity = "warning"
+	}
+
+	return postJSON(ctx, p.HTTPClient, url, nil, map[string]interface{}{
+		"routing_key":  p.RoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    n.Key,
+		"payload": map[string]interface{}{
+			"summary":   client.Truncate(n.Title, 1024),
+			"source":    n.Log.Resource,
+			"severity":  severity,
+			"timestamp": n.Log.Timestamp,
+			"component": n.Log.Action,
+			"custom_details": map[string]interface{}{
+				"body":          n.Body,
+				"userId":        n.Log.UserID,
+				"logId":         n.Log.ID,
+				"transactionId": n.TransactionID,
+				"blockNumber":   n.BlockNumber,
+				"count":         n.Count,
+			},
+		},
+	})
+}
+
+// Opsgenie creates alerts through the Opsgenie Alert API. The alert key is the Opsgenie
+// alias, so Opsgenie counts the notifications of one open alert instead of opening more.
+type Opsgenie struct {
+	APIKey string
+	// URL is DefaultOpsgenieURL when empty; EU accounts use https://api.eu.opsgenie.com
+	URL        string
+	HTTPClient *http.Client
+}
+
+// Name returns "opsgenie"
+func (o *Opsgenie) Name() string {
+	return "opsgenie"
+}
+
+// Notify creates an alert
+func (o *Opsgenie) Notify(ctx context.Context, n *Notification) error {
+	url := o.URL
+	if url == "" {
+		url = DefaultOpsgenieURL
+	}
+
+	priority := "P4"
+	switch {
+	case n.Level >= slog.LevelError+4:
+		priority = "P1"
+	case n.Level >= slog.LevelError:
+		priority = "P2"
+	case n.Level >= slog.LevelWarn:
+		priority = "P3"
+	}
+
+	header := http.Header{"Authorization": {"GenieKey " + o.APIKey}}
+	return postJSON(ctx, o.HTTPClient, strings.TrimSuffix(url, "/")+"/v2/alerts", header, map[string]interface{}{
+		"message":     client.Truncate(n.Title, 130),
+		"alias":       client.Truncate(n.Key, 512),
+		"description": client.Truncate(n.Body, 15000),
+		"priority":    priority,
+		"source":      "fabric-logging-system",
+		"entity":      n.Log.Resource,
+		"details": map[string]string{
+			"userId":        n.Log.UserID,
+			"action":        n.Log.Action,
+			"logId":         n.Log.ID,
+			"transactionId": n.TransactionID,
+		},
+	})
+}
+
+// Email sends notifications as plain text messages through an SMTP server
+type Email struct {
+	// Addr is the host:port of the SMTP server, which must offer STARTTLS when Auth is set
+	Addr string
+	Auth smtp.Auth
+	From string
+	To   []string
+}
+
+// Name returns "email"
+func (e *Email) Name() string {
+	return "email"
+}
+
+// Notify sends a message with the title as its subject. The SMTP exchange cannot be cancelled
+// once started, so ctx only prevents it from starting.
+func (e *Email) Notify(ctx context.Context, n *Notification) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", e.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(e.To, ", "))
+	// Line breaks in the title would end the header
+	subject := strings.NewReplacer("\r", " ", "\n", " ").Replace(n.Title)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	message.WriteString(strings.ReplaceAll(n.Body, "\n", "\r\n"))
+	message.WriteString("\r\n")
+
+	return smtp.SendMail(e.Addr, e.Auth, e.From, e.To, message.Bytes())
+}
+
+// postJSON posts body as JSON to url and fails unless the response status is 2xx
+func postJSON(ctx context.Context, httpClient *http.Client, url string, header http.Header, body interface{}) error {
+	bodyJSON, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(bodyJSON))
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach %s: %v", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+
+	response, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s rejected notification: %s: %s", req.URL.Host, http.StatusText(resp.StatusCode), strings.TrimSpace(string(response)))
+	}
+	return nil
+}
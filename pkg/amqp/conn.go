@@ -0,0 +1,459 @@
+package amqp
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Connection settings
+const (
+	// handshakeTimeout bounds connecting, upgrading to TLS, authenticating and starting to consume
+	handshakeTimeout = 10 * time.Second
+	// defaultFrameMax is the frame size used when the server sets no limit
+	defaultFrameMax = 128 << 10
+	// consumerChannel is the channel the bridge consumes on
+	consumerChannel = 1
+)
+
+// delivery is a message delivered to the consumer
+type delivery struct {
+	tag         uint64
+	redelivered bool
+	exchange    string
+	routingKey  string
+	props       properties
+	body        []byte
+	// truncated is set on a message whose body exceeded the limit and was discarded
+	truncated bool
+}
+
+// conn is a connection consuming a queue on one channel, read by a goroutine that assembles
+// deliveries and answers the server's heartbeats
+type conn struct {
+	netConn   net.Conn
+	r         *bufio.Reader
+	frameMax  int
+	heartbeat time.Duration
+	maxBody   int
+
+	wmu sync.Mutex
+
+	mu       sync.Mutex
+	err      error
+	lastRead time.Time
+
+	deliveries chan delivery
+	closing    chan struct{}
+	closeOnce  sync.Once
+	done       chan struct{}
+}
+
+// dial connects to the server at options.URL and starts consuming options.Queue
+func dial(ctx context.Context, options Options) (*conn, error) {
+	u, err := url.Parse(options.URL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid AMQP URL %q", options.URL)
+	}
+	useTLS := options.TLS != nil
+	port := "5672"
+	switch u.Scheme {
+	case "amqp":
+	case "amqps":
+		useTLS, port = true, "5671"
+	default:
+		return nil, fmt.Errorf("unsupported AMQP scheme %q", u.Scheme)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	vhost := strings.TrimPrefix(u.Path, "/")
+	if vhost == "" {
+		vhost = "/"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, handshakeTimeout)
+	defer cancel()
+	var dialer net.Dialer
+	netConn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	deadline, _ := ctx.Deadline()
+	netConn.SetDeadline(deadline)
+
+	// Without a user, a client certificate authenticates the connection
+	mechanism, response := "PLAIN", "\x00guest\x00guest"
+	if u.User != nil {
+		password, _ := u.User.Password()
+		response = "\x00" + u.User.Username() + "\x00" + password
+	} else if useTLS && options.TLS != nil && len(options.TLS.Certificates) > 0 {
+		mechanism, response = "EXTERNAL", ""
+	}
+
+	if useTLS {
+		config := &tls.Config{MinVersion: tls.VersionTLS12}
+		if options.TLS != nil {
+			config = options.TLS.Clone()
+		}
+		if config.ServerName == "" {
+			config.ServerName = u.Hostname()
+		}
+		tlsConn := tls.Client(netConn, config)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			netConn.Close()
+			return nil, fmt.Errorf("TLS handshake failed: %v", err)
+		}
+		netConn = tlsConn
+	}
+
+	c := &conn{
+		netConn:    netConn,
+		r:          bufio.NewReader(netConn),
+		frameMax:   minFrameMax,
+		maxBody:    options.MaxMessageSize,
+		deliveries: make(chan delivery, options.Prefetch),
+		closing:    make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if err := c.handshake(vhost, mechanism, response, options); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	netConn.SetDeadline(time.Time{})
+
+	c.lastRead = time.Now()
+	go c.readLoop()
+	if c.heartbeat > 0 {
+		go c.heartbeats()
+	}
+	return c, nil
+}
+
+// handshake opens the connection, a channel and the consumer
+func (c *conn) handshake(vhost, mechanism, response string, options Options) error {
+	if err := c.write(protocolHeader); err != nil {
+		return err
+	}
+
+	r, err := c.expect(0, classConnection, methodConnectionStart)
+	if err != nil {
+		return err
+	}
+	r.octet() // version major
+	r.octet() // version minor
+	r.table() // server properties
+	mechanisms := strings.Fields(r.longstr())
+	if r.err != nil {
+		return r.err
+	}
+	supported := false
+	for _, m := range mechanisms {
+		supported = supported || m == mechanism
+	}
+	if !supported {
+		return fmt.Errorf("the server does not offer the %s mechanism, only %s", mechanism, strings.Join(mechanisms, " "))
+	}
+
+	clientProperties := map[string]interface{}{
+		"product":  "fabric-logging",
+		"platform": "Go",
+		"capabilities": map[string]interface{}{
+			"consumer_cancel_notify": true,
+		},
+	}
+	if options.Name != "" {
+		clientProperties["connection_name"] = options.Name
+	}
+	var args writer
+	args.table(clientProperties).shortstr(mechanism).longstr(response).shortstr("en_US")
+	if err := c.write(methodFrame(0, classConnection, methodConnectionStartOk, args.Bytes())); err != nil {
+		return err
+	}
+
+	if r, err = c.expect(0, classConnection, methodConnectionTune); err != nil {
+		return fmt.Errorf("failed to authenticate: %w", err)
+	}
+	channelMax := r.short()
+	frameMax := int(r.long())
+	heartbeat := r.short()
+	if r.err != nil {
+		return r.err
+	}
+	if frameMax == 0 {
+		frameMax = defaultFrameMax
+	}
+	c.frameMax = max(frameMax, minFrameMax)
+	c.heartbeat = time.Duration(heartbeat) * time.Second
+	args = writer{}
+	args.short(channelMax).long(uint32(c.frameMax)).short(heartbeat)
+	if err := c.write(methodFrame(0, classConnection, methodConnectionTuneOk, args.Bytes())); err != nil {
+		return err
+	}
+
+	args = writer{}
+	args.shortstr(vhost).shortstr("").octet(0)
+	if err := c.write(methodFrame(0, classConnection, methodConnectionOpen, args.Bytes())); err != nil {
+		return err
+	}
+	if _, err := c.expect(0, classConnection, methodConnectionOpenOk); err != nil {
+		return fmt.Errorf("failed to open virtual host %s: %w", vhost, err)
+	}
+
+	args = writer{}
+	args.shortstr("")
+	if err := c.write(methodFrame(consumerChannel, classChannel, methodChannelOpen, args.Bytes())); err != nil {
+		return err
+	}
+	if _, err := c.expect(consumerChannel, classChannel, methodChannelOpenOk); err != nil {
+		return err
+	}
+
+	args = writer{}
+	args.long(0).short(uint16(options.Prefetch)).octet(0)
+	if err := c.write(methodFrame(consumerChannel, classBasic, methodBasicQos, args.Bytes())); err != nil {
+		return err
+	}
+	if _, err := c.expect(consumerChannel, classBasic, methodBasicQosOk); err != nil {
+		return err
+	}
+
+	args = writer{}
+	args.short(0).shortstr(options.Queue).shortstr("").octet(0).table(nil)
+	if err := c.write(methodFrame(consumerChannel, classBasic, methodBasicConsume, args.Bytes())); err != nil {
+		return err
+	}
+	if _, err := c.expect(consumerChannel, classBasic, methodBasicConsumeOk); err != nil {
+		return fmt.Errorf("failed to consume queue %s: %w", options.Queue, err)
+	}
+	return nil
+}
+
+// expect reads frames until a method, skipping heartbeats, and fails unless it is the method
+// expected. A close of the connection or channel fails with the server's reason.
+func (c *conn) expect(channel, class, method uint16) (*reader, error) {
+	for {
+		f, err := readFrame(c.r, max(c.frameMax, minFrameMax))
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		if f.kind == frameHeartbeat {
+			continue
+		}
+		if f.kind != frameMethod {
+			return nil, fmt.Errorf("unexpected frame of type %d", f.kind)
+		}
+		r := &reader{p: f.payload}
+		gotClass, gotMethod := r.short(), r.short()
+		if r.err != nil {
+			return nil, r.err
+		}
+		if err := c.closed(f.channel, gotClass, gotMethod, r); err != nil {
+			return nil, err
+		}
+		if f.channel != channel || gotClass != class || gotMethod != method {
+			return nil, fmt.Errorf("unexpected method %d.%d", gotClass, gotMethod)
+		}
+		return r, nil
+	}
+}
+
+// closed answers a close of the connection or a channel, returning the server's reason, and
+// returns nil for any other method
+func (c *conn) closed(channel, class, method uint16, r *reader) error {
+	switch {
+	case class == classConnection && method == methodConnectionClose:
+		code, text := r.short(), r.shortstr()
+		c.write(methodFrame(0, classConnection, methodConnectionCloseOk, nil))
+		return fmt.Errorf("server closed the connection: %d %s", code, text)
+	case class == classChannel && method == methodChannelClose:
+		code, text := r.short(), r.shortstr()
+		c.write(methodFrame(channel, classChannel, methodChannelCloseOk, nil))
+		return fmt.Errorf("server closed the channel: %d %s", code, text)
+	}
+	return nil
+}
+
+// write sends encoded frames
+func (c *conn) write(p []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	_, err := c.netConn.Write(p)
+	return err
+}
+
+// readLoop reads frames until the connection fails or is closed
+func (c *conn) readLoop() {
+	err := c.read()
+
+	c.mu.Lock()
+	c.err = err
+	c.mu.Unlock()
+	close(c.done)
+	c.netConn.Close()
+}
+
+func (c *conn) read() error {
+	var current *delivery
+	var remaining uint64
+	for {
+		f, err := readFrame(c.r, c.frameMax)
+		if err != nil {
+			return err
+		}
+		c.mu.Lock()
+		c.lastRead = time.Now()
+		c.mu.Unlock()
+
+		switch f.kind {
+		case frameHeartbeat:
+
+		case frameMethod:
+			r := &reader{p: f.payload}
+			class, method := r.short(), r.short()
+			if err := c.closed(f.channel, class, method, r); err != nil {
+				return err
+			}
+			switch {
+			case class == classBasic && method == methodBasicDeliver:
+				r.shortstr() // consumer tag
+				current = &delivery{tag: r.longlong()}
+				current.redelivered = r.octet()&1 != 0
+				current.exchange = r.shortstr()
+				current.routingKey = r.shortstr()
+				if r.err != nil {
+					return r.err
+				}
+			case class == classBasic && method == methodBasicCancel:
+				return errors.New("the server cancelled the consumer, as when its queue is deleted")
+			}
+
+		case frameHeader:
+			if current == nil {
+				return errors.New("content header without a delivery")
+			}
+			size, props, err := readContentHeader(f.payload)
+			if err != nil {
+				return fmt.Errorf("invalid content header: %v", err)
+			}
+			current.props = props
+			remaining = size
+			if size > uint64(c.maxBody) {
+				current.truncated = true
+			} else {
+				current.body = make([]byte, 0, size)
+			}
+			if remaining == 0 {
+				c.deliver(*current)
+				current = nil
+			}
+
+		case frameBody:
+			if current == nil {
+				return errors.New("content body without a delivery")
+			}
+			if uint64(len(f.payload)) > remaining {
+				return errors.New("content body exceeds its size")
+			}
+			remaining -= uint64(len(f.payload))
+			if !current.truncated {
+				current.body = append(current.body, f.payload...)
+			}
+			if remaining == 0 {
+				c.deliver(*current)
+				current = nil
+			}
+		}
+	}
+}
+
+// deliver hands d to the consumer; the prefetch count bounds the deliveries not yet acknowledged
+func (c *conn) deliver(d delivery) {
+	select {
+	case c.deliveries <- d:
+	case <-c.closing:
+	}
+}
+
+// heartbeats sends a heartbeat every half interval, and closes the connection when the server
+// has not sent a frame for two intervals
+func (c *conn) heartbeats() {
+	ticker := time.NewTicker(c.heartbeat / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.mu.Lock()
+			silent := time.Since(c.lastRead)
+			c.mu.Unlock()
+			if silent > 2*c.heartbeat {
+				c.netConn.Close()
+				return
+			}
+			c.write(encodeFrame(frameHeartbeat, 0, nil))
+		}
+	}
+}
+
+// ack acknowledges the deliveries up to tag
+func (c *conn) ack(tag uint64) error {
+	var args writer
+	args.longlong(tag).octet(1)
+	return c.write(methodFrame(consumerChannel, classBasic, methodBasicAck, args.Bytes()))
+}
+
+// reject discards the delivery of tag, or its queue's dead-letter exchange receives it
+func (c *conn) reject(tag uint64) error {
+	var args writer
+	args.longlong(tag).octet(0)
+	return c.write(methodFrame(consumerChannel, classBasic, methodBasicReject, args.Bytes()))
+}
+
+// requeue returns the deliveries up to tag to their queue
+func (c *conn) requeue(tag uint64) error {
+	var args writer
+	args.longlong(tag).octet(1 | 2)
+	return c.write(methodFrame(consumerChannel, classBasic, methodBasicNack, args.Bytes()))
+}
+
+// closedErr returns why the connection closed
+func (c *conn) closedErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err == nil || errors.Is(c.err, net.ErrClosed) {
+		return errors.New("connection closed")
+	}
+	return fmt.Errorf("connection lost: %w", c.err)
+}
+
+// close closes the connection; deliveries not acknowledged return to their queue
+func (c *conn) close() error {
+	c.closeOnce.Do(func() { close(c.closing) })
+	var args writer
+	args.short(200).shortstr("closing").short(0).short(0)
+	c.write(methodFrame(0, classConnection, methodConnectionClose, args.Bytes()))
+	err := c.netConn.Close()
+	<-c.done
+	return err
+}
+
+// redactURL returns rawURL with its password masked, for reporting
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,400 @@
+package amqp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/simulator"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// server is the server side of a bridge's connection
+type server struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (s *server) send(frames ...[]byte) {
+	for _, f := range frames {
+		if _, err := s.conn.Write(f); err != nil {
+			s.t.Errorf("server failed to send: %v", err)
+		}
+	}
+}
+
+func (s *server) method(channel, class, method uint16, args *writer) {
+	var p []byte
+	if args != nil {
+		p = args.Bytes()
+	}
+	s.send(methodFrame(channel, class, method, p))
+}
+
+// expect reads the next frame from the client, failing the test unless it is the method
+// expected, and returns its arguments
+func (s *server) expect(channel, class, method uint16) *reader {
+	s.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	f, err := readFrame(s.r, 1<<20)
+	if err != nil {
+		s.t.Errorf("server expected method %d.%d, got error %v", class, method, err)
+		return &reader{err: err}
+	}
+	r := &reader{p: f.payload}
+	gotClass, gotMethod := r.short(), r.short()
+	if f.kind != frameMethod || f.channel != channel || gotClass != class || gotMethod != method {
+		s.t.Errorf("server expected method %d.%d on channel %d, got frame %d on %d: %d.%d", class, method, channel, f.kind, f.channel, gotClass, gotMethod)
+	}
+	return r
+}
+
+// offer reads the protocol header and offers mechanisms
+func (s *server) offer(mechanisms string) {
+	var header [8]byte
+	s.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadFull(s.r, header[:]); err != nil || !bytes.Equal(header[:], protocolHeader) {
+		s.t.Errorf("server got protocol header % x, %v", header, err)
+	}
+	var args writer
+	args.octet(0).octet(9).table(map[string]interface{}{"product": "RabbitMQ"}).longstr(mechanisms).longstr("en_US")
+	s.method(0, classConnection, methodConnectionStart, &args)
+}
+
+// start offers mechanisms and returns the client's StartOk
+func (s *server) start(mechanisms string) *reader {
+	s.offer(mechanisms)
+	return s.expect(0, classConnection, methodConnectionStartOk)
+}
+
+// accept plays the server's side of a successful handshake for queue
+func (s *server) accept(queue string) {
+	r := s.start("AMQPLAIN PLAIN")
+	r.table()
+	if mechanism, response := r.shortstr(), r.longstr(); mechanism != "PLAIN" || response != "\x00bridge\x00secret" {
+		s.t.Errorf("server got mechanism %s with %q", mechanism, response)
+	}
+
+	var args writer
+	args.short(2047).long(0).short(0)
+	s.method(0, classConnection, methodConnectionTune, &args)
+	r = s.expect(0, classConnection, methodConnectionTuneOk)
+	if channelMax, frameMax, heartbeat := r.short(), r.long(), r.short(); channelMax != 2047 || frameMax != defaultFrameMax || heartbeat != 0 {
+		s.t.Errorf("server got tune-ok %d %d %d", channelMax, frameMax, heartbeat)
+	}
+	r = s.expect(0, classConnection, methodConnectionOpen)
+	if vhost := r.shortstr(); vhost != "audit" {
+		s.t.Errorf("server got virtual host %q", vhost)
+	}
+	args = writer{}
+	s.method(0, classConnection, methodConnectionOpenOk, args.shortstr(""))
+
+	s.expect(consumerChannel, classChannel, methodChannelOpen)
+	args = writer{}
+	s.method(consumerChannel, classChannel, methodChannelOpenOk, args.longstr(""))
+
+	r = s.expect(consumerChannel, classBasic, methodBasicQos)
+	if size, count := r.long(), r.short(); size != 0 || count != 6 {
+		s.t.Errorf("server got qos %d %d", size, count)
+	}
+	s.method(consumerChannel, classBasic, methodBasicQosOk, nil)
+
+	r = s.expect(consumerChannel, classBasic, methodBasicConsume)
+	r.short()
+	if got := r.shortstr(); got != queue {
+		s.t.Errorf("server got consume of %q", got)
+	}
+	r.shortstr()
+	if flags := r.octet(); flags != 0 {
+		// no-ack unset: the bridge acknowledges messages itself
+		s.t.Errorf("server got consume flags %x", flags)
+	}
+	args = writer{}
+	s.method(consumerChannel, classBasic, methodBasicConsumeOk, args.shortstr("ctag"))
+}
+
+// deliver sends a message with messageID, its body split into frames of chunk bytes
+func (s *server) deliver(tag uint64, messageID string, body []byte, chunk int) {
+	var args writer
+	args.shortstr("ctag").longlong(tag).octet(0).shortstr("audit").shortstr("orders.created")
+	s.method(consumerChannel, classBasic, methodBasicDeliver, &args)
+
+	var header writer
+	header.short(classBasic).short(0).longlong(uint64(len(body))).short(1<<7 | 1<<3).shortstr(messageID).shortstr("billing")
+	s.send(encodeFrame(frameHeader, consumerChannel, header.Bytes()))
+	for len(body) > 0 {
+		n := min(chunk, len(body))
+		s.send(encodeFrame(frameBody, consumerChannel, body[:n]))
+		body = body[n:]
+	}
+}
+
+// expectSettled reads the method settling deliveries and returns its delivery tag and flags
+func (s *server) expectSettled(method uint16) (uint64, byte) {
+	r := s.expect(consumerChannel, classBasic, method)
+	return r.longlong(), r.octet()
+}
+
+// startServer accepts one connection and runs script on it, returning the URL to dial. The
+// test waits for the script to finish.
+func startServer(t *testing.T, script func(s *server)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Errorf("accept: %v", err)
+			return
+		}
+		defer conn.Close()
+		script(&server{t: t, conn: conn, r: bufio.NewReader(conn)})
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		<-done
+	})
+	return "amqp://bridge:secret@" + ln.Addr().String() + "/audit"
+}
+
+func newTestBridge(t *testing.T, url string, target client.LoggingClient, errs *[]error) *Bridge {
+	t.Helper()
+	var mu sync.Mutex
+	b, err := NewBridge(target, Options{
+		URL:            url,
+		Queue:          "audit-events",
+		BatchSize:      3,
+		FlushInterval:  time.Second,
+		MaxMessageSize: 16,
+		Retry:          client.RetryPolicy{MaxAttempts: 1},
+		Registerer:     prometheus.NewRegistry(),
+		OnError: func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			*errs = append(*errs, err)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestBridgeAcknowledgesCommittedMessages(t *testing.T) {
+	sim := simulator.New()
+	settled := make(chan string, 2)
+	url := startServer(t, func(s *server) {
+		s.accept("audit-events")
+		s.deliver(1, "m1", []byte("order 1 created"), 4)
+		s.send(encodeFrame(frameHeartbeat, 0, nil))
+		s.deliver(2, "m2", bytes.Repeat([]byte("x"), 17), 8)
+		s.deliver(3, "m3", nil, 1)
+
+		// The oversized message is rejected without being requeued, then one ack settles the rest
+		if tag, requeue := s.expectSettled(methodBasicReject); tag != 2 || requeue != 0 {
+			t.Errorf("rejected %d with requeue %d, want 2 without", tag, requeue)
+		}
+		settled <- "rejected"
+		if tag, multiple := s.expectSettled(methodBasicAck); tag != 3 || multiple != 1 {
+			t.Errorf("acknowledged %d with multiple %d, want 3 and following", tag, multiple)
+		}
+		settled <- "acknowledged"
+
+		r := s.expect(0, classConnection, methodConnectionClose)
+		if code := r.short(); code != 200 {
+			t.Errorf("closed with %d", code)
+		}
+	})
+	var errs []error
+	b := newTestBridge(t, url, sim, &errs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		_, err := b.consume(ctx)
+		result <- err
+	}()
+	for _, want := range []string{"rejected", "acknowledged"} {
+		select {
+		case got := <-settled:
+			if got != want {
+				t.Fatalf("%s, want %s", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("the messages were not %s", want)
+		}
+	}
+
+	for _, id := range []string{"amqp-m1", "amqp-m3"} {
+		log, err := sim.ReadLog(context.Background(), id)
+		if err != nil {
+			t.Fatalf("log %s was not committed before its ack: %v", id, err)
+		}
+		if log.Action != "ORDERS_CREATED" || log.UserID != "billing" || log.Resource != "audit/orders.created" {
+			t.Errorf("log %s = %+v", id, log)
+		}
+	}
+	if log, _ := sim.ReadLog(context.Background(), "amqp-m1"); log.Description != "order 1 created" {
+		t.Errorf("the body of m1 was reassembled as %q", log.Description)
+	}
+	if exists, _ := sim.LogExists(context.Background(), "amqp-m2"); exists {
+		t.Error("the oversized message was recorded")
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "rejected message 2") {
+		t.Errorf("errors = %v, want the rejected message reported", errs)
+	}
+
+	cancel()
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Errorf("consume = %v, want context.Canceled", err)
+	}
+}
+
+func TestBridgeRequeuesWhenSubmitFails(t *testing.T) {
+	sim := simulator.New()
+	sim.Close()
+	url := startServer(t, func(s *server) {
+		s.accept("audit-events")
+		s.deliver(5, "m5", []byte("a"), 16)
+		s.deliver(6, "m6", []byte("b"), 16)
+		s.deliver(7, "m7", []byte("c"), 16)
+		// multiple and requeue, so the messages go to the bridge that takes over
+		if tag, flags := s.expectSettled(methodBasicNack); tag != 7 || flags != 3 {
+			t.Errorf("nacked %d with flags %d, want 7 with 3", tag, flags)
+		}
+		s.expect(0, classConnection, methodConnectionClose)
+	})
+	var errs []error
+	b := newTestBridge(t, url, sim, &errs)
+
+	connected, err := b.consume(context.Background())
+	if !connected || !errors.Is(err, errSubmit) {
+		t.Fatalf("consume = %v, %v, want a submit error", connected, err)
+	}
+}
+
+func TestBridgeHandshakeFailures(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		script func(s *server)
+		err    string
+	}{
+		{"mechanism not offered", func(s *server) {
+			s.offer("AMQPLAIN EXTERNAL")
+		}, "the server does not offer the PLAIN mechanism, only AMQPLAIN EXTERNAL"},
+		{"access refused", func(s *server) {
+			s.start("PLAIN")
+			var args writer
+			args.short(403).shortstr("ACCESS_REFUSED - Login was refused").short(0).short(0)
+			s.method(0, classConnection, methodConnectionClose, &args)
+			s.expect(0, classConnection, methodConnectionCloseOk)
+		}, "failed to authenticate: server closed the connection: 403 ACCESS_REFUSED - Login was refused"},
+		{"queue not found", func(s *server) {
+			s.start("PLAIN")
+			var args writer
+			args.short(0).long(4096).short(0)
+			s.method(0, classConnection, methodConnectionTune, &args)
+			s.expect(0, classConnection, methodConnectionTuneOk)
+			s.expect(0, classConnection, methodConnectionOpen)
+			args = writer{}
+			s.method(0, classConnection, methodConnectionOpenOk, args.shortstr(""))
+			s.expect(consumerChannel, classChannel, methodChannelOpen)
+			args = writer{}
+			s.method(consumerChannel, classChannel, methodChannelOpenOk, args.longstr(""))
+			s.expect(consumerChannel, classBasic, methodBasicQos)
+			s.method(consumerChannel, classBasic, methodBasicQosOk, nil)
+			s.expect(consumerChannel, classBasic, methodBasicConsume)
+			args = writer{}
+			args.short(404).shortstr("NOT_FOUND - no queue 'audit-events'").short(60).short(20)
+			s.method(consumerChannel, classChannel, methodChannelClose, &args)
+			s.expect(consumerChannel, classChannel, methodChannelCloseOk)
+		}, "failed to consume queue audit-events: server closed the channel: 404 NOT_FOUND - no queue 'audit-events'"},
+		{"unexpected method", func(s *server) {
+			s.start("PLAIN")
+			s.method(0, classConnection, methodConnectionOpenOk, nil)
+		}, "failed to authenticate: unexpected method 10.41"},
+		{"closed during the handshake", func(s *server) {
+			s.start("PLAIN")
+		}, "failed to authenticate: unexpected EOF"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			url := startServer(t, tt.script)
+			var errs []error
+			b := newTestBridge(t, url, simulator.New(), &errs)
+
+			connected, err := b.consume(context.Background())
+			if connected || err == nil || err.Error() != tt.err {
+				t.Fatalf("consume = %v, %v, want %q", connected, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestBridgeConnectionLost(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		frames func() [][]byte
+		err    string
+	}{
+		{"consumer cancelled", func() [][]byte {
+			var args writer
+			args.shortstr("ctag").octet(0)
+			return [][]byte{methodFrame(consumerChannel, classBasic, methodBasicCancel, args.Bytes())}
+		}, "connection lost: the server cancelled the consumer, as when its queue is deleted"},
+		{"body without a delivery", func() [][]byte {
+			return [][]byte{encodeFrame(frameBody, consumerChannel, []byte("x"))}
+		}, "connection lost: content body without a delivery"},
+		{"header without a delivery", func() [][]byte {
+			return [][]byte{encodeFrame(frameHeader, consumerChannel, nil)}
+		}, "connection lost: content header without a delivery"},
+		{"body past its size", func() [][]byte {
+			var args writer
+			args.shortstr("ctag").longlong(1).octet(0).shortstr("").shortstr("q")
+			var header writer
+			header.short(classBasic).short(0).longlong(1).short(0)
+			return [][]byte{
+				methodFrame(consumerChannel, classBasic, methodBasicDeliver, args.Bytes()),
+				encodeFrame(frameHeader, consumerChannel, header.Bytes()),
+				encodeFrame(frameBody, consumerChannel, []byte("xy")),
+			}
+		}, "connection lost: content body exceeds its size"},
+		{"connection closed", func() [][]byte {
+			var args writer
+			args.short(320).shortstr("CONNECTION_FORCED - broker forced connection closure with reason 'shutdown'").short(0).short(0)
+			return [][]byte{methodFrame(0, classConnection, methodConnectionClose, args.Bytes())}
+		}, "connection lost: server closed the connection: 320 CONNECTION_FORCED - broker forced connection closure with reason 'shutdown'"},
+		{"oversized frame", func() [][]byte {
+			return [][]byte{encodeFrame(frameBody, consumerChannel, make([]byte, defaultFrameMax+1))}
+		}, "connection lost: frame of 131073 bytes exceeds 131072"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			url := startServer(t, func(s *server) {
+				s.accept("audit-events")
+				s.send(tt.frames()...)
+				// Read until the client closes the connection
+				s.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+				io.Copy(io.Discard, s.r)
+			})
+			var errs []error
+			b := newTestBridge(t, url, simulator.New(), &errs)
+
+			connected, err := b.consume(context.Background())
+			if !connected || err == nil || err.Error() != tt.err {
+				t.Fatalf("consume = %v, %v, want %q", connected, err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,384 @@
+package amqp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// The bridge speaks AMQP 0-9-1 itself. This file implements its framing and the encoding of
+// method arguments, content headers and field tables, as far as a consumer uses them.
+
+// protocolHeader opens an AMQP 0-9-1 connection
+var protocolHeader = []byte{'A', 'M', 'Q', 'P', 0, 0, 9, 1}
+
+// Frame types
+const (
+	frameMethod    = 1
+	frameHeader    = 2
+	frameBody      = 3
+	frameHeartbeat = 8
+	frameEnd       = 0xce
+)
+
+// Class and method IDs of the methods used
+const (
+	classConnection = 10
+	classChannel    = 20
+	classBasic      = 60
+
+	methodConnectionStart   = 10
+	methodConnectionStartOk = 11
+	methodConnectionTune    = 30
+	methodConnectionTuneOk  = 31
+	methodConnectionOpen    = 40
+	methodConnectionOpenOk  = 41
+	methodConnectionClose   = 50
+	methodConnectionCloseOk = 51
+
+	methodChannelOpen    = 10
+	methodChannelOpenOk  = 11
+	methodChannelClose   = 40
+	methodChannelCloseOk = 41
+
+	methodBasicQos       = 10
+	methodBasicQosOk     = 11
+	methodBasicConsume   = 20
+	methodBasicConsumeOk = 21
+	methodBasicCancel    = 30
+	methodBasicDeliver   = 60
+	methodBasicAck       = 80
+	methodBasicReject    = 90
+	methodBasicNack      = 120
+)
+
+// minFrameMax is the smallest frame size every peer accepts
+const minFrameMax = 4096
+
+// frame is a received frame
+type frame struct {
+	kind    byte
+	channel uint16
+	payload []byte
+}
+
+// readFrame reads a frame, refusing those larger than max
+func readFrame(r *bufio.Reader, max int) (frame, error) {
+	var header [7]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return frame{}, err
+	}
+	f := frame{kind: header[0], channel: binary.BigEndian.Uint16(header[1:3])}
+	size := binary.BigEndian.Uint32(header[3:7])
+	if int64(size) > int64(max) {
+		return frame{}, fmt.Errorf("frame of %d bytes exceeds %d", size, max)
+	}
+	f.payload = make([]byte, size+1)
+	if _, err := io.ReadFull(r, f.payload); err != nil {
+		return frame{}, unexpected(err)
+	}
+	if f.payload[size] != frameEnd {
+		return frame{}, errors.New("invalid frame end")
+	}
+	f.payload = f.payload[:size]
+	return f, nil
+}
+
+// unexpected reports the end of the input inside a frame as io.ErrUnexpectedEOF
+func unexpected(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// encodeFrame returns a frame of kind on channel
+func encodeFrame(kind byte, channel uint16, payload []byte) []byte {
+	p := make([]byte, 0, len(payload)+8)
+	p = append(p, kind)
+	p = binary.BigEndian.AppendUint16(p, channel)
+	p = binary.BigEndian.AppendUint32(p, uint32(len(payload)))
+	p = append(p, payload...)
+	return append(p, frameEnd)
+}
+
+// methodFrame returns the frame of a method whose arguments are args
+func methodFrame(channel, class, method uint16, args []byte) []byte {
+	payload := binary.BigEndian.AppendUint16(nil, class)
+	payload = binary.BigEndian.AppendUint16(payload, method)
+	return encodeFrame(frameMethod, channel, append(payload, args...))
+}
+
+// writer encodes method arguments
+type writer struct {
+	bytes.Buffer
+}
+
+func (w *writer) octet(b byte) *writer {
+	w.WriteByte(b)
+	return w
+}
+
+func (w *writer) short(n uint16) *writer {
+	binary.Write(w, binary.BigEndian, n)
+	return w
+}
+
+func (w *writer) long(n uint32) *writer {
+	binary.Write(w, binary.BigEndian, n)
+	return w
+}
+
+func (w *writer) longlong(n uint64) *writer {
+	binary.Write(w, binary.BigEndian, n)
+	return w
+}
+
+func (w *writer) shortstr(s string) *writer {
+	w.WriteByte(byte(len(s)))
+	w.WriteString(s)
+	return w
+}
+
+func (w *writer) longstr(s string) *writer {
+	w.long(uint32(len(s)))
+	w.WriteString(s)
+	return w
+}
+
+// table encodes a field table of strings, booleans and nested tables, with sorted keys
+func (w *writer) table(t map[string]interface{}) *writer {
+	var inner writer
+	keys := make([]string, 0, len(t))
+	for key := range t {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		inner.shortstr(key)
+		switch v := t[key].(type) {
+		case string:
+			inner.octet('S').longstr(v)
+		case bool:
+			inner.octet('t')
+			if v {
+				inner.octet(1)
+			} else {
+				inner.octet(0)
+			}
+		case map[string]interface{}:
+			inner.octet('F').table(v)
+		default:
+			inner.octet('V')
+		}
+	}
+	w.long(uint32(inner.Len()))
+	w.Write(inner.Bytes())
+	return w
+}
+
+// reader decodes method arguments, recording the first error
+type reader struct {
+	p   []byte
+	err error
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.p) {
+		r.err = errors.New("truncated method arguments")
+		return nil
+	}
+	p := r.p[:n]
+	r.p = r.p[n:]
+	return p
+}
+
+func (r *reader) octet() byte {
+	if p := r.next(1); p != nil {
+		return p[0]
+	}
+	return 0
+}
+
+func (r *reader) short() uint16 {
+	if p := r.next(2); p != nil {
+		return binary.BigEndian.Uint16(p)
+	}
+	return 0
+}
+
+func (r *reader) long() uint32 {
+	if p := r.next(4); p != nil {
+		return binary.BigEndian.Uint32(p)
+	}
+	return 0
+}
+
+func (r *reader) longlong() uint64 {
+	if p := r.next(8); p != nil {
+		return binary.BigEndian.Uint64(p)
+	}
+	return 0
+}
+
+func (r *reader) shortstr() string {
+	return string(r.next(int(r.octet())))
+}
+
+func (r *reader) longstr() string {
+	n := r.long()
+	if int64(n) > int64(len(r.p)) {
+		r.err = errors.New("truncated method arguments")
+		return ""
+	}
+	return string(r.next(int(n)))
+}
+
+// table decodes a field table
+func (r *reader) table() map[string]interface{} {
+	n := r.long()
+	if int64(n) > int64(len(r.p)) {
+		r.err = errors.New("truncated field table")
+		return nil
+	}
+	inner := &reader{p: r.next(int(n))}
+	t := make(map[string]interface{})
+	for len(inner.p) > 0 && inner.err == nil {
+		key := inner.shortstr()
+		t[key] = inner.value()
+	}
+	if inner.err != nil && r.err == nil {
+		r.err = inner.err
+	}
+	return t
+}
+
+// value decodes a field value, of the types RabbitMQ and the 0-9-1 errata define
+func (r *reader) value() interface{} {
+	switch kind := r.octet(); kind {
+	case 't':
+		return r.octet() != 0
+	case 'b':
+		return int64(int8(r.octet()))
+	case 'B':
+		return int64(r.octet())
+	case 's':
+		return int64(int16(r.short()))
+	case 'u':
+		return int64(r.short())
+	case 'I':
+		return int64(int32(r.long()))
+	case 'i':
+		return int64(r.long())
+	case 'l':
+		return int64(r.longlong())
+	case 'f':
+		return float64(math.Float32frombits(r.long()))
+	case 'd':
+		return math.Float64frombits(r.longlong())
+	case 'D':
+		scale := r.octet()
+		value := int32(r.long())
+		return float64(value) / math.Pow10(int(scale))
+	case 'S', 'x':
+		return r.longstr()
+	case 'A':
+		n := r.long()
+		if int64(n) > int64(len(r.p)) {
+			r.err = errors.New("truncated field array")
+			return nil
+		}
+		inner := &reader{p: r.next(int(n))}
+		var values []interface{}
+		for len(inner.p) > 0 && inner.err == nil {
+			values = append(values, inner.value())
+		}
+		if inner.err != nil && r.err == nil {
+			r.err = inner.err
+		}
+		return values
+	case 'T':
+		return time.Unix(int64(r.longlong()), 0).UTC()
+	case 'F':
+		return r.table()
+	case 'V':
+		return nil
+	default:
+		if r.err == nil {
+			r.err = fmt.Errorf("unknown field type %q", kind)
+		}
+		return nil
+	}
+}
+
+// properties are the basic properties of a message's content header
+type properties struct {
+	ContentType   string
+	Headers       map[string]interface{}
+	CorrelationID string
+	MessageID     string
+	Timestamp     time.Time
+	Type          string
+	UserID        string
+	AppID         string
+}
+
+// readContentHeader decodes a content header frame, returning the body size and properties
+func readContentHeader(payload []byte) (uint64, properties, error) {
+	r := &reader{p: payload}
+	r.short() // class
+	r.short() // weight
+	size := r.longlong()
+	flags := r.short()
+
+	var props properties
+	if flags&(1<<15) != 0 {
+		props.ContentType = r.shortstr()
+	}
+	if flags&(1<<14) != 0 {
+		r.shortstr() // content encoding
+	}
+	if flags&(1<<13) != 0 {
+		props.Headers = r.table()
+	}
+	if flags&(1<<12) != 0 {
+		r.octet() // delivery mode
+	}
+	if flags&(1<<11) != 0 {
+		r.octet() // priority
+	}
+	if flags&(1<<10) != 0 {
+		props.CorrelationID = r.shortstr()
+	}
+	if flags&(1<<9) != 0 {
+		r.shortstr() // reply to
+	}
+	if flags&(1<<8) != 0 {
+		r.shortstr() // expiration
+	}
+	if flags&(1<<7) != 0 {
+		props.MessageID = r.shortstr()
+	}
+	if flags&(1<<6) != 0 {
+		props.Timestamp = time.Unix(int64(r.longlong()), 0)
+	}
+	if flags&(1<<5) != 0 {
+		props.Type = r.shortstr()
+	}
+	if flags&(1<<4) != 0 {
+		props.UserID = r.shortstr()
+	}
+	if flags&(1<<3) != 0 {
+		props.AppID = r.shortstr()
+	}
+	return size, props, r.err
+}
@@ -0,0 +1,338 @@
+package amqp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func frameReader(p ...[]byte) *bufio.Reader {
+	return bufio.NewReader(bytes.NewReader(bytes.Join(p, nil)))
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		kind    byte
+		channel uint16
+		payload []byte
+	}{
+		{"heartbeat", frameHeartbeat, 0, nil},
+		{"method", frameMethod, 1, []byte{0, 60, 0, 80, 1, 2, 3}},
+		{"body on a high channel", frameBody, 0xffff, bytes.Repeat([]byte("x"), 300)},
+		{"body of the largest size", frameBody, 1, bytes.Repeat([]byte("y"), minFrameMax)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := encodeFrame(tt.kind, tt.channel, tt.payload)
+			if len(encoded) != 8+len(tt.payload) || encoded[len(encoded)-1] != frameEnd {
+				t.Fatalf("encoded frame % x", encoded[:min(len(encoded), 16)])
+			}
+
+			r := frameReader(encoded)
+			f, err := readFrame(r, minFrameMax)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.kind != tt.kind || f.channel != tt.channel || !bytes.Equal(f.payload, tt.payload) {
+				t.Errorf("got frame %d on %d with %d bytes", f.kind, f.channel, len(f.payload))
+			}
+			if _, err := r.ReadByte(); err != io.EOF {
+				t.Errorf("the frame was not read to its end")
+			}
+		})
+	}
+
+	if got, want := encodeFrame(frameHeartbeat, 0, nil), []byte{8, 0, 0, 0, 0, 0, 0, 0xce}; !bytes.Equal(got, want) {
+		t.Errorf("heartbeat = % x, want % x", got, want)
+	}
+}
+
+func TestReadFrameErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		input []byte
+		err   error
+		msg   string
+	}{
+		{"empty", nil, io.EOF, ""},
+		{"short header", []byte{1, 0, 0, 0}, io.ErrUnexpectedEOF, ""},
+		{"short payload", []byte{1, 0, 0, 0, 0, 0, 4, 'a', 'b'}, io.ErrUnexpectedEOF, ""},
+		{"missing frame end", []byte{1, 0, 0, 0, 0, 0, 2, 'a', 'b'}, io.ErrUnexpectedEOF, ""},
+		{"invalid frame end", []byte{1, 0, 0, 0, 0, 0, 1, 'a', 0xcd}, nil, "invalid frame end"},
+		{"oversized frame", []byte{3, 0, 1, 0, 0, 0x10, 0x01}, nil, "frame of 4097 bytes exceeds 4096"},
+		{"size past int32", []byte{3, 0, 1, 0xff, 0xff, 0xff, 0xff}, nil, "frame of 4294967295 bytes exceeds 4096"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readFrame(frameReader(tt.input), minFrameMax)
+			switch {
+			case err == nil:
+				t.Fatal("readFrame succeeded")
+			case tt.err != nil && !errors.Is(err, tt.err):
+				t.Fatalf("readFrame = %v, want %v", err, tt.err)
+			case tt.msg != "" && err.Error() != tt.msg:
+				t.Fatalf("readFrame = %v, want %s", err, tt.msg)
+			}
+		})
+	}
+}
+
+func TestMethodFrames(t *testing.T) {
+	tag := []byte{0, 0, 0, 0, 0, 0, 0x01, 0x02}
+	method := func(class, method uint16, args ...byte) []byte {
+		payload := binary.BigEndian.AppendUint16(nil, class)
+		payload = binary.BigEndian.AppendUint16(payload, method)
+		payload = append(payload, args...)
+		frame := []byte{frameMethod, 0, consumerChannel}
+		frame = binary.BigEndian.AppendUint32(frame, uint32(len(payload)))
+		return append(append(frame, payload...), frameEnd)
+	}
+
+	var args writer
+	args.longlong(0x0102).octet(1)
+	if got, want := methodFrame(consumerChannel, classBasic, methodBasicAck, args.Bytes()), method(60, 80, append(tag, 1)...); !bytes.Equal(got, want) {
+		t.Errorf("basic.ack = % x, want % x", got, want)
+	}
+
+	for _, tt := range []struct {
+		name string
+		send func(c *conn) error
+		want []byte
+	}{
+		// multiple
+		{"ack", func(c *conn) error { return c.ack(0x0102) }, method(60, 80, append(tag, 1)...)},
+		// not requeued
+		{"reject", func(c *conn) error { return c.reject(0x0102) }, method(60, 90, append(tag, 0)...)},
+		// multiple and requeued
+		{"requeue", func(c *conn) error { return c.requeue(0x0102) }, method(60, 120, append(tag, 3)...)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			netConn := &bufferConn{}
+			if err := tt.send(&conn{netConn: netConn}); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(netConn.buf.Bytes(), tt.want) {
+				t.Errorf("got % x, want % x", netConn.buf.Bytes(), tt.want)
+			}
+		})
+	}
+}
+
+func TestArgumentsRoundTrip(t *testing.T) {
+	var w writer
+	w.octet(7).short(0xbeef).long(0xdeadbeef).longlong(math.MaxUint64).shortstr("queue").longstr("\x00guest\x00guest").
+		table(map[string]interface{}{
+			"product": "fabric-logging",
+			"yes":     true,
+			"no":      false,
+			"nested":  map[string]interface{}{"consumer_cancel_notify": true},
+			"void":    nil,
+		}).table(nil)
+
+	r := &reader{p: w.Bytes()}
+	if got := r.octet(); got != 7 {
+		t.Errorf("octet = %d", got)
+	}
+	if got := r.short(); got != 0xbeef {
+		t.Errorf("short = %x", got)
+	}
+	if got := r.long(); got != 0xdeadbeef {
+		t.Errorf("long = %x", got)
+	}
+	if got := r.longlong(); got != math.MaxUint64 {
+		t.Errorf("longlong = %x", got)
+	}
+	if got := r.shortstr(); got != "queue" {
+		t.Errorf("shortstr = %q", got)
+	}
+	if got := r.longstr(); got != "\x00guest\x00guest" {
+		t.Errorf("longstr = %q", got)
+	}
+	want := map[string]interface{}{
+		"product": "fabric-logging",
+		"yes":     true,
+		"no":      false,
+		"nested":  map[string]interface{}{"consumer_cancel_notify": true},
+		"void":    nil,
+	}
+	if got := r.table(); !reflect.DeepEqual(got, want) {
+		t.Errorf("table = %v, want %v", got, want)
+	}
+	if got := r.table(); len(got) != 0 {
+		t.Errorf("empty table = %v", got)
+	}
+	if r.err != nil || len(r.p) != 0 {
+		t.Errorf("%d bytes left, error %v", len(r.p), r.err)
+	}
+
+	// Reading past the end records an error and returns zero values
+	if got := r.long(); got != 0 || r.err == nil || r.err.Error() != "truncated method arguments" {
+		t.Errorf("long past the end = %d, %v", got, r.err)
+	}
+}
+
+// field encodes a field table entry of kind with the value bytes
+func field(key string, kind byte, value ...byte) []byte {
+	return append(append(append([]byte{byte(len(key))}, key...), kind), value...)
+}
+
+func TestTableFieldTypes(t *testing.T) {
+	stamp := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	entries := [][]byte{
+		field("t", 't', 1),
+		field("b", 'b', 0xff),
+		field("B", 'B', 0xff),
+		field("s", 's', 0xff, 0xfe),
+		field("u", 'u', 0xff, 0xfe),
+		field("I", 'I', 0xff, 0xff, 0xff, 0xfe),
+		field("i", 'i', 0xff, 0xff, 0xff, 0xfe),
+		field("l", 'l', binary.BigEndian.AppendUint64(nil, uint64(1)<<40)...),
+		field("f", 'f', binary.BigEndian.AppendUint32(nil, math.Float32bits(1.5))...),
+		field("d", 'd', binary.BigEndian.AppendUint64(nil, math.Float64bits(-2.25))...),
+		field("D", 'D', 2, 0, 0, 0x30, 0x39),
+		field("S", 'S', 0, 0, 0, 2, 'h', 'i'),
+		field("x", 'x', 0, 0, 0, 2, 0xff, 0x00),
+		field("A", 'A', 0, 0, 0, 7, 'b', 1, 'S', 0, 0, 0, 0),
+		field("T", 'T', binary.BigEndian.AppendUint64(nil, uint64(stamp.Unix()))...),
+		field("F", 'F', 0, 0, 0, 3, 1, 'k', 'V'),
+		field("V", 'V'),
+	}
+
+	body := bytes.Join(entries, nil)
+	p := binary.BigEndian.AppendUint32(nil, uint32(len(body)))
+	r := &reader{p: append(p, body...)}
+
+	got := r.table()
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	want := map[string]interface{}{
+		"t": true,
+		"b": int64(-1),
+		"B": int64(255),
+		"s": int64(-2),
+		"u": int64(0xfffe),
+		"I": int64(-2),
+		"i": int64(0xfffffffe),
+		"l": int64(1) << 40,
+		"f": 1.5,
+		"d": -2.25,
+		"D": 123.45,
+		"S": "hi",
+		"x": "\xff\x00",
+		"A": []interface{}{int64(1), ""},
+		"T": stamp,
+		"F": map[string]interface{}{"k": nil},
+		"V": nil,
+	}
+	for key, value := range want {
+		if key == "D" {
+			if d, ok := got[key].(float64); !ok || math.Abs(d-123.45) > 1e-9 {
+				t.Errorf("%s = %v, want %v", key, got[key], value)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got[key], value) {
+			t.Errorf("%s = %#v, want %#v", key, got[key], value)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d fields, want %d", len(got), len(want))
+	}
+}
+
+func TestTableErrors(t *testing.T) {
+	table := func(body ...byte) []byte {
+		return append(binary.BigEndian.AppendUint32(nil, uint32(len(body))), body...)
+	}
+	for _, tt := range []struct {
+		name  string
+		input []byte
+		err   string
+	}{
+		{"size past the arguments", []byte{0, 0, 0, 9, 1, 'k'}, "truncated field table"},
+		{"unknown type", table(field("k", 'Z')...), `unknown field type 'Z'`},
+		{"truncated value", table(field("k", 'I', 0, 0)...), "truncated method arguments"},
+		{"truncated string", table(field("k", 'S', 0, 0, 0, 9, 'a')...), "truncated method arguments"},
+		{"array past the table", table(field("k", 'A', 0, 0, 0, 9)...), "truncated field array"},
+		{"truncated key", table(5, 'a'), "truncated method arguments"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &reader{p: tt.input}
+			r.table()
+			if r.err == nil || r.err.Error() != tt.err {
+				t.Fatalf("table(% x) = %v, want %s", tt.input, r.err, tt.err)
+			}
+		})
+	}
+}
+
+func TestReadContentHeader(t *testing.T) {
+	stamp := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var w writer
+	w.short(classBasic).short(0).longlong(1234).short(0xfff8).
+		shortstr("application/json").
+		shortstr("gzip").
+		table(map[string]interface{}{"tenant": "acme"}).
+		octet(2).
+		octet(5).
+		shortstr("corr-1").
+		shortstr("reply.to").
+		shortstr("60000").
+		shortstr("msg-1").
+		longlong(uint64(stamp.Unix())).
+		shortstr("order.created").
+		shortstr("alice").
+		shortstr("billing")
+
+	size, props, err := readContentHeader(w.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := properties{
+		ContentType:   "application/json",
+		Headers:       map[string]interface{}{"tenant": "acme"},
+		CorrelationID: "corr-1",
+		MessageID:     "msg-1",
+		Timestamp:     stamp,
+		Type:          "order.created",
+		UserID:        "alice",
+		AppID:         "billing",
+	}
+	if size != 1234 || !props.Timestamp.Equal(stamp) {
+		t.Errorf("size %d, timestamp %v", size, props.Timestamp)
+	}
+	props.Timestamp = stamp
+	if !reflect.DeepEqual(props, want) {
+		t.Errorf("got %+v, want %+v", props, want)
+	}
+
+	// Only the flagged properties are present
+	w = writer{}
+	w.short(classBasic).short(0).longlong(0).short(1 << 7).shortstr("only-id")
+	if _, props, err := readContentHeader(w.Bytes()); err != nil || !reflect.DeepEqual(props, properties{MessageID: "only-id"}) {
+		t.Errorf("got %+v, %v", props, err)
+	}
+
+	w = writer{}
+	w.short(classBasic).short(0).longlong(0).short(1 << 15).octet(10)
+	if _, _, err := readContentHeader(w.Bytes()); err == nil {
+		t.Error("a truncated content type was accepted")
+	}
+}
+
+// bufferConn is a connection recording what is written to it
+type bufferConn struct {
+	net.Conn
+	buf bytes.Buffer
+}
+
+func (c *bufferConn) Write(p []byte) (int, error) {
+	return c.buf.Write(p)
+}
@@ -0,0 +1,343 @@
+package amqp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"gopkg.in/yaml.v2"
+)
+
+// Rule maps the messages published to Exchange with a routing key matching RoutingKey to logs,
+// with an optional text/template per field executed on a Message. Without a template, the ID is
+// the message-id property, so a message delivered again maps to the log already recorded, or a
+// new UUID when the publisher sets none. The action is the routing key in upper case, such as
+// ORDERS_CREATED for orders.created, and the resource the exchange and routing key. The user is
+// the user-id property, which RabbitMQ checks against the publishing connection, or the userId
+// of a JSON message, or the app-id property, or "amqp"; the description is the description of
+// a JSON message, or the whole message when it is text, and the metadata the message's routing
+// and properties.
+type Rule struct {
+	// Exchange is the exchange the message was published to; any exchange matches when empty
+	// and the default exchange is named ""
+	Exchange string `json:"exchange,omitempty" yaml:"exchange,omitempty"`
+	// RoutingKey is a pattern as topic exchanges bind them, where * matches a word and # zero
+	// or more words; any routing key matches when empty
+	RoutingKey  string `json:"routingKey,omitempty" yaml:"routingKey,omitempty"`
+	ID          string `json:"id,omitempty" yaml:"id,omitempty"`
+	UserID      string `json:"userId,omitempty" yaml:"userId,omitempty"`
+	Action      string `json:"action,omitempty" yaml:"action,omitempty"`
+	Resource    string `json:"resource,omitempty" yaml:"resource,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Metadata must execute to a JSON object
+	Metadata string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+}
+
+// rulesConfig is a rules file
+type rulesConfig struct {
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+// LoadRules reads the rules of a rules file, in YAML or JSON
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules: %v", err)
+	}
+
+	var cfg rulesConfig
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse rules %s: %v", path, err)
+	}
+	return cfg.Rules, nil
+}
+
+// Message is the data a Rule's templates execute on
+type Message struct {
+	Exchange   string
+	RoutingKey string
+	// Words are the dot-separated words of the routing key
+	Words         []string
+	Queue         string
+	MessageID     string
+	CorrelationID string
+	AppID         string
+	UserID        string
+	Type          string
+	ContentType   string
+	// Timestamp is the timestamp property, zero when the publisher set none
+	Timestamp   time.Time
+	Headers     map[string]interface{}
+	Body        string
+	Redelivered bool
+	// JSON is the body decoded as JSON, nil when it is not JSON
+	JSON interface{}
+}
+
+// templateFuncs are the functions available to a Rule's templates besides the built-in ones
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	// default returns value, or fallback when value is empty
+	"default": func(fallback, value interface{}) interface{} {
+		if value == nil || value == "" {
+			return fallback
+		}
+		return value
+	},
+	// field returns the value at a dotted path of a decoded JSON object, or nil when there is
+	// none; unlike .JSON.a.b it does not fail when a message lacks the field
+	"field": func(v interface{}, path string) interface{} {
+		for _, key := range strings.Split(path, ".") {
+			object, ok := v.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			v = object[key]
+		}
+		return v
+	},
+}
+
+// mapper is a parsed Rule
+type mapper struct {
+	exchange                                            string
+	anyExchange                                         bool
+	pattern                                             []string
+	id, userID, action, resource, description, metadata *template.Template
+}
+
+func newMappers(rules []Rule) ([]*mapper, error) {
+	mappers := make([]*mapper, 0, len(rules))
+	for i, rule := range rules {
+		m := &mapper{exchange: rule.Exchange, anyExchange: rule.Exchange == ""}
+		if rule.RoutingKey != "" {
+			m.pattern = strings.Split(rule.RoutingKey, ".")
+		} else {
+			m.pattern = []string{"#"}
+		}
+		for _, field := range []struct {
+			name string
+			text string
+			t    **template.Template
+		}{
+			{"id", rule.ID, &m.id},
+			{"userId", rule.UserID, &m.userID},
+			{"action", rule.Action, &m.action},
+			{"resource", rule.Resource, &m.resource},
+			{"description", rule.Description, &m.description},
+			{"metadata", rule.Metadata, &m.metadata},
+		} {
+			if field.text == "" {
+				continue
+			}
+			t, err := template.New(field.name).Option("missingkey=error").Funcs(templateFuncs).Parse(field.text)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s template of rule %d: %v", field.name, i+1, err)
+			}
+			*field.t = t
+		}
+		mappers = append(mappers, m)
+	}
+	return mappers, nil
+}
+
+// matches reports whether a message published to exchange with the routing key words matches
+// the mapper
+func (m *mapper) matches(exchange string, words []string) bool {
+	return (m.anyExchange || m.exchange == exchange) && matchWords(m.pattern, words)
+}
+
+// matchWords matches routing key words against a pattern as a topic exchange does
+func matchWords(pattern, words []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "#" {
+			for i := 0; i <= len(words); i++ {
+				if matchWords(pattern[1:], words[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(words) == 0 || (pattern[0] != "*" && pattern[0] != words[0]) {
+			return false
+		}
+		pattern, words = pattern[1:], words[1:]
+	}
+	return len(words) == 0
+}
+
+// mapLog maps a delivery from queue to its log with the first mapper matching it, or with the
+// defaults when none does
+func mapLog(mappers []*mapper, queue string, d delivery) (client.LogEvent, error) {
+	data := Message{
+		Exchange:      d.exchange,
+		RoutingKey:    d.routingKey,
+		Words:         strings.Split(d.routingKey, "."),
+		Queue:         queue,
+		MessageID:     d.props.MessageID,
+		CorrelationID: d.props.CorrelationID,
+		AppID:         d.props.AppID,
+		UserID:        d.props.UserID,
+		Type:          d.props.Type,
+		ContentType:   d.props.ContentType,
+		Timestamp:     d.props.Timestamp,
+		Headers:       d.props.Headers,
+		Body:          string(d.body),
+		Redelivered:   d.redelivered,
+	}
+	if err := json.Unmarshal(d.body, &data.JSON); err != nil {
+		data.JSON = nil
+	}
+	object, _ := data.JSON.(map[string]interface{})
+
+	id, err := messageID(d)
+	if err != nil {
+		return client.LogEvent{}, err
+	}
+	timestamp := d.props.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	resource := d.routingKey
+	if d.exchange != "" {
+		resource = d.exchange + "/" + d.routingKey
+	}
+	log := client.LogEvent{
+		ID:        id,
+		UserID:    "amqp",
+		Action:    routingKeyAction(d.routingKey),
+		Resource:  client.Truncate(resource, client.MaxResourceLength),
+		Timestamp: timestamp.UTC().Format(time.RFC3339Nano),
+	}
+	if utf8.Valid(d.body) {
+		log.Description = client.Truncate(data.Body, client.MaxDescriptionLength)
+	}
+	switch userID, _ := object["userId"].(string); {
+	case d.props.UserID != "":
+		log.UserID = d.props.UserID
+	case userID != "":
+		log.UserID = userID
+	case d.props.AppID != "":
+		log.UserID = d.props.AppID
+	}
+	if description, ok := object["description"].(string); ok {
+		log.Description = client.Truncate(description, client.MaxDescriptionLength)
+	}
+
+	fields := map[string]interface{}{
+		"exchange":   d.exchange,
+		"routingKey": d.routingKey,
+	}
+	for key, value := range map[string]string{
+		"messageId":     d.props.MessageID,
+		"correlationId": d.props.CorrelationID,
+		"appId":         d.props.AppID,
+		"type":          d.props.Type,
+	} {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+	if len(d.props.Headers) > 0 {
+		fields["headers"] = jsonHeaders(d.props.Headers)
+	}
+	metadata, err := json.Marshal(fields)
+	if err != nil {
+		return client.LogEvent{}, err
+	}
+	log.Metadata = string(metadata)
+
+	var m *mapper
+	for _, candidate := range mappers {
+		if candidate.matches(d.exchange, data.Words) {
+			m = candidate
+			break
+		}
+	}
+	if m == nil {
+		return log, nil
+	}
+	for _, field := range []struct {
+		t     *template.Template
+		value *string
+	}{
+		{m.id, &log.ID},
+		{m.userID, &log.UserID},
+		{m.action, &log.Action},
+		{m.resource, &log.Resource},
+		{m.description, &log.Description},
+		{m.metadata, &log.Metadata},
+	} {
+		if field.t == nil {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := field.t.Execute(&buf, data); err != nil {
+			return client.LogEvent{}, fmt.Errorf("%w: %v", client.ErrInvalidLog, err)
+		}
+		*field.value = buf.String()
+	}
+	return log, nil
+}
+
+// messageID identifies a message by its message-id property, hashed when too long for a log ID,
+// or with a new UUID when it has none
+func messageID(d delivery) (string, error) {
+	if d.props.MessageID == "" {
+		return client.UUIDv7()(client.LogEvent{})
+	}
+	id := "amqp-" + d.props.MessageID
+	if len(id) <= client.MaxIDLength {
+		return id, nil
+	}
+	hashed, _ := client.ContentHash()(client.LogEvent{Resource: id})
+	return "amqp-" + hashed, nil
+}
+
+// jsonHeaders converts the values of headers JSON cannot hold as they are: binary strings
+// become base64
+func jsonHeaders(headers map[string]interface{}) map[string]interface{} {
+	converted := make(map[string]interface{}, len(headers))
+	for key, value := range headers {
+		switch v := value.(type) {
+		case string:
+			if !utf8.ValidString(v) {
+				value = base64.StdEncoding.EncodeToString([]byte(v))
+			}
+		case map[string]interface{}:
+			value = jsonHeaders(v)
+		}
+		converted[key] = value
+	}
+	return converted
+}
+
+// routingKeyAction returns the action of a routing key: its words in upper case, joined by
+// underscores, with the characters an action cannot hold replaced
+func routingKeyAction(routingKey string) string {
+	action := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, routingKey)
+	if action == "" || action[0] < 'A' || action[0] > 'Z' {
+		action = "AMQP_" + action
+	}
+	return client.Truncate(action, client.MaxActionLength)
+}
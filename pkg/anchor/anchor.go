@@ -0,0 +1,370 @@
+// Package anchor periodically publishes a digest of newly committed logs outside the
+// consortium, to OpenTimestamps or a public Ethereum chain, and keeps the receipts. Every
+// digest also covers all earlier ones, so rewriting any anchored log, even with the agreement
+// of every consortium member, no longer matches a digest fixed in public.
+package anchor
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// DefaultInterval is how often pending logs are anchored when Options.Interval is zero
+const DefaultInterval = time.Hour
+
+// Options configures an Anchorer
+type Options struct {
+	// Interval is the least time between anchors; DefaultInterval is used otherwise
+	Interval time.Duration
+	// StatePath holds the digest chain, the Merkle frontier of logs not yet anchored and the
+	// position of the last log added, so anchoring resumes exactly after a restart
+	StatePath string
+	// ReceiptsPath is the NDJSON file receipts are appended to
+	ReceiptsPath string
+	// Publishers receive every digest; at least one is required
+	Publishers []Publisher
+	// OnError is called with publication failures, which are retried at the next anchor attempt
+	OnError func(error)
+}
+
+// Receipt is the proof that a range of logs was anchored with one publisher
+type Receipt struct {
+	Sequence          uint64 `json:"sequence"`
+	FirstBlock        uint64 `json:"firstBlock"`
+	LastBlock         uint64 `json:"lastBlock"`
+	LastTransactionID string `json:"lastTransactionId"`
+	LogCount          uint64 `json:"logCount"`
+	// MerkleRoot covers the logs of this range; Digest is SHA-256(PreviousDigest || MerkleRoot)
+	MerkleRoot     string    `json:"merkleRoot"`
+	PreviousDigest string    `json:"previousDigest"`
+	Digest         string    `json:"digest"`
+	Publisher      string    `json:"publisher"`
+	Proof          []byte    `json:"proof"`
+	AnchoredAt     time.Time `json:"anchoredAt"`
+}
+
+// anchorState is the content of the state file
+type anchorState struct {
+	Sequence   uint64             `json:"sequence"`
+	Digest     []byte             `json:"digest"`
+	Pending    frontier           `json:"pending"`
+	FirstBlock uint64             `json:"firstBlock"`
+	Checkpoint *client.Checkpoint `json:"checkpoint,omitempty"`
+	LastAnchor time.Time          `json:"lastAnchor"`
+	// Sealed is an anchor whose digest is fixed but which some publishers have not yet accepted
+	Sealed *sealedAnchor `json:"sealed,omitempty"`
+}
+
+type sealedAnchor struct {
+	Receipt     Receipt  `json:"receipt"`
+	Unpublished []string `json:"unpublished"`
+}
+
+// Anchorer is a projection.Sink folding committed logs into a Merkle tree and anchoring its
+// root every Interval. Use its Checkpointer with the Projector, since the Anchorer records the
+// position of the last log it added together with the tree:
+//
+//	anchorer, err := anchor.NewAnchorer(options)
+//	projector := projection.New(source, anchorer, projection.Options{Checkpointer: anchorer.Checkpointer()})
+//	go anchorer.Run(ctx)
+//	err = projector.Run(ctx)
+type Anchorer struct {
+	options    Options
+	publishers map[string]Publisher
+
+	mu    sync.Mutex
+	state anchorState
+}
+
+// NewAnchorer loads the anchoring state, starting a new digest chain when there is none
+func NewAnchorer(options Options) (*Anchorer, error) {
+	if options.StatePath == "" || options.ReceiptsPath == "" {
+		return nil, fmt.Errorf("anchor state and receipts paths are required")
+	}
+	if len(options.Publishers) == 0 {
+		return nil, fmt.Errorf("no anchor publishers configured")
+	}
+	if options.Interval <= 0 {
+		options.Interval = DefaultInterval
+	}
+
+	a := &Anchorer{options: options, publishers: map[string]Publisher{}}
+	for _, publisher := range options.Publishers {
+		a.publishers[publisher.Name()] = publisher
+	}
+
+	stateJSON, err := os.ReadFile(options.StatePath)
+	if err == nil {
+		if err := json.Unmarshal(stateJSON, &a.state); err != nil {
+			return nil, fmt.Errorf("failed to parse anchor state: %v", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read anchor state: %v", err)
+	} else {
+		a.state.LastAnchor = time.Now().UTC()
+	}
+
+	return a, nil
+}
+
+// Write adds the logs carried by events to the pending tree, then anchors it if Interval has
+// passed. A batch whose last transaction was already added is ignored, so a retried Write
+// never counts a log twice.
+func (a *Anchorer) Write(ctx context.Context, events []client.ContractEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	last := events[len(events)-1]
+	if cp := a.state.Checkpoint; cp != nil && cp.BlockNumber == last.BlockNumber && cp.TransactionID == last.TransactionID {
+		return nil
+	}
+
+	state := a.state
+	state.Pending.Peaks = append([]peak(nil), a.state.Pending.Peaks...)
+	for _, event := range events {
+		// Anchors commit to the logs written; pruning one does not undo its anchor
+		if event.Pruned() {
+			continue
+		}
+		leaf, err := hashLeaf(event)
+		if err != nil {
+			return err
+		}
+		if state.Pending.Count == 0 {
+			state.FirstBlock = event.BlockNumber
+		}
+		state.Pending.add(leaf)
+	}
+	state.Checkpoint = &client.Checkpoint{BlockNumber: last.BlockNumber, TransactionID: last.TransactionID}
+
+	if err := a.save(state); err != nil {
+		return err
+	}
+
+	if time.Since(a.state.LastAnchor) >= a.options.Interval {
+		a.anchor(ctx)
+	}
+
+	return nil
+}
+
+// Run anchors pending logs every Interval until ctx is done, so logs are anchored even when
+// no further logs arrive. It returns ctx's error.
+func (a *Anchorer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(a.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			a.Anchor(ctx)
+		}
+	}
+}
+
+// Anchor seals the pending logs into a new anchor now, unless an earlier one is still
+// unpublished, and publishes any unpublished anchor
+func (a *Anchorer) Anchor(ctx context.Context) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.anchor(ctx)
+}
+
+func (a *Anchorer) anchor(ctx context.Context) {
+	if a.state.Sealed == nil && a.state.Pending.Count > 0 {
+		if err := a.seal(); err != nil {
+			a.report(err)
+			return
+		}
+	}
+	if a.state.Sealed != nil {
+		a.publish(ctx)
+	}
+}
+
+// seal fixes the digest of the pending logs and starts a new tree
+func (a *Anchorer) seal() error {
+	root := a.state.Pending.root()
+	digest := chainDigest(a.state.Digest, root)
+
+	state := a.state
+	state.Sequence++
+	state.Digest = digest
+	state.Pending = frontier{}
+	state.LastAnchor = time.Now().UTC()
+	state.Sealed = &sealedAnchor{
+		Receipt: Receipt{
+			Sequence:          state.Sequence,
+			FirstBlock:        a.state.FirstBlock,
+			LastBlock:         a.state.Checkpoint.BlockNumber,
+			LastTransactionID: a.state.Checkpoint.TransactionID,
+			LogCount:          a.state.Pending.Count,
+			MerkleRoot:        hex.EncodeToString(root),
+			PreviousDigest:    hex.EncodeToString(a.state.Digest),
+			Digest:            hex.EncodeToString(digest),
+		},
+	}
+	for _, publisher := range a.options.Publishers {
+		state.Sealed.Unpublished = append(state.Sealed.Unpublished, publisher.Name())
+	}
+
+	return a.save(state)
+}
+
+// publish sends the sealed digest to each publisher that has not yet accepted it, recording a
+// receipt for every one that does
+func (a *Anchorer) publish(ctx context.Context) {
+	sealed := a.state.Sealed
+	digest, err := hex.DecodeString(sealed.Receipt.Digest)
+	if err != nil {
+		a.report(err)
+		return
+	}
+
+	var unpublished []string
+	for _, name := range sealed.Unpublished {
+		publisher, ok := a.publishers[name]
+		if !ok {
+			// A publisher removed from the configuration is no longer waited for
+			continue
+		}
+
+		proof, err := publisher.Publish(ctx, digest)
+		if err == nil {
+			receipt := sealed.Receipt
+			receipt.Publisher = name
+			receipt.Proof = proof
+			receipt.AnchoredAt = time.Now().UTC()
+			err = a.appendReceipt(receipt)
+		}
+		if err != nil {
+			a.report(fmt.Errorf("failed to anchor digest %d with %s: %v", sealed.Receipt.Sequence, name, err))
+			unpublished = append(unpublished, name)
+		}
+	}
+
+	state := a.state
+	if len(unpublished) == 0 {
+		state.Sealed = nil
+	} else {
+		state.Sealed = &sealedAnchor{Receipt: sealed.Receipt, Unpublished: unpublished}
+	}
+	if err := a.save(state); err != nil {
+		a.report(err)
+	}
+}
+
+// Receipts returns every receipt recorded so far, oldest first
+func (a *Anchorer) Receipts() ([]Receipt, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	file, err := os.Open(a.options.ReceiptsPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open anchor receipts: %v", err)
+	}
+	defer file.Close()
+
+	var receipts []Receipt
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var receipt Receipt
+		if err := json.Unmarshal(scanner.Bytes(), &receipt); err != nil {
+			// A torn final line from a crash; the publication is retried from the state
+			continue
+		}
+		receipts = append(receipts, receipt)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read anchor receipts: %v", err)
+	}
+
+	return receipts, nil
+}
+
+// Checkpointer returns the position of the last log added to the Anchorer, for its Projector.
+// The position is saved with the tree by Write, so Save does nothing.
+func (a *Anchorer) Checkpointer() client.Checkpointer {
+	return anchorCheckpointer{a}
+}
+
+type anchorCheckpointer struct {
+	a *Anchorer
+}
+
+func (c anchorCheckpointer) Load() (*client.Checkpoint, error) {
+	c.a.mu.Lock()
+	defer c.a.mu.Unlock()
+
+	if c.a.state.Checkpoint == nil {
+		return nil, nil
+	}
+	checkpoint := *c.a.state.Checkpoint
+	return &checkpoint, nil
+}
+
+func (c anchorCheckpointer) Save(client.Checkpoint) error {
+	return nil
+}
+
+// save replaces the state file atomically and then adopts state
+func (a *Anchorer) save(state anchorState) error {
+	stateJSON, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(a.options.StatePath+".tmp", stateJSON, 0600); err != nil {
+		return fmt.Errorf("failed to write anchor state: %v", err)
+	}
+	if err := os.Rename(a.options.StatePath+".tmp", a.options.StatePath); err != nil {
+		return fmt.Errorf("failed to write anchor state: %v", err)
+	}
+
+	a.state = state
+	return nil
+}
+
+// appendReceipt appends receipt to the receipts file and syncs it
+func (a *Anchorer) appendReceipt(receipt Receipt) error {
+	receiptJSON, err := json.Marshal(receipt)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(a.options.ReceiptsPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open anchor receipts: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(receiptJSON, '\n')); err != nil {
+		return fmt.Errorf("failed to write anchor receipt: %v", err)
+	}
+	return file.Sync()
+}
+
+func (a *Anchorer) report(err error) {
+	if a.options.OnError != nil {
+		a.options.OnError(err)
+	}
+}
@@ -0,0 +1,98 @@
+package anchor
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// Domain separation prefixes, so a leaf can never be passed off as an inner node
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+// peak is the root of a perfect subtree of the leaves added to a frontier
+type peak struct {
+	Height int    `json:"height"`
+	Hash   []byte `json:"hash"`
+}
+
+// frontier builds a Merkle tree incrementally, holding only the roots of its perfect subtrees,
+// so its size grows with the logarithm of the number of leaves
+type frontier struct {
+	Peaks []peak `json:"peaks"`
+	Count uint64 `json:"count"`
+}
+
+// add appends a leaf, merging equal-height subtrees
+func (f *frontier) add(leaf []byte) {
+	current := peak{Height: 0, Hash: leaf}
+	for len(f.Peaks) > 0 && f.Peaks[len(f.Peaks)-1].Height == current.Height {
+		left := f.Peaks[len(f.Peaks)-1]
+		f.Peaks = f.Peaks[:len(f.Peaks)-1]
+		current = peak{Height: current.Height + 1, Hash: hashNode(left.Hash, current.Hash)}
+	}
+	f.Peaks = append(f.Peaks, current)
+	f.Count++
+}
+
+// root folds the peaks, smallest first, into a single root; it is nil for an empty frontier
+func (f *frontier) root() []byte {
+	if len(f.Peaks) == 0 {
+		return nil
+	}
+
+	root := f.Peaks[len(f.Peaks)-1].Hash
+	for i := len(f.Peaks) - 2; i >= 0; i-- {
+		root = hashNode(f.Peaks[i].Hash, root)
+	}
+	return root
+}
+
+// MerkleRoot returns the Merkle root over events in order, as computed by an Anchorer, so an
+// auditor holding the logs of an anchored range can recompute its root
+func MerkleRoot(events []client.ContractEvent) ([]byte, error) {
+	var f frontier
+	for _, event := range events {
+		leaf, err := hashLeaf(event)
+		if err != nil {
+			return nil, err
+		}
+		f.add(leaf)
+	}
+
+	return f.root(), nil
+}
+
+// hashLeaf hashes a log together with its ledger position
+func hashLeaf(event client.ContractEvent) ([]byte, error) {
+	leafJSON, err := json.Marshal(struct {
+		client.LogEvent
+		BlockNumber   uint64 `json:"blockNumber"`
+		TransactionID string `json:"transactionId"`
+	}{event.Log, event.BlockNumber, event.TransactionID})
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	h.Write([]byte{leafPrefix})
+	h.Write(leafJSON)
+	return h.Sum(nil), nil
+}
+
+func hashNode(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{nodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// chainDigest links an anchored root to every earlier anchor
+func chainDigest(previous, root []byte) []byte {
+	sum := sha256.Sum256(append(append([]byte{}, previous...), root...))
+	return sum[:]
+}
@@ -0,0 +1,149 @@
+package anchor
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultCalendarURL is a public OpenTimestamps calendar
+const DefaultCalendarURL = "https://a.pool.opentimestamps.org"
+
+// Publisher records a digest with an external timestamping service or public chain
+type Publisher interface {
+	// Name identifies the publisher in receipts
+	Name() string
+	// Publish submits the 32-byte digest and returns the proof the service issued for it
+	Publish(ctx context.Context, digest []byte) ([]byte, error)
+}
+
+// OpenTimestamps publishes digests to an OpenTimestamps calendar. The proof is the calendar's
+// pending timestamp, which the ots tool upgrades to a full Bitcoin attestation a few hours later.
+type OpenTimestamps struct {
+	CalendarURL string
+	HTTPClient  *http.Client
+}
+
+// Name returns "opentimestamps"
+func (o *OpenTimestamps) Name() string {
+	return "opentimestamps"
+}
+
+// Publish submits digest to the calendar's digest endpoint
+func (o *OpenTimestamps) Publish(ctx context.Context, digest []byte) ([]byte, error) {
+	calendar := o.CalendarURL
+	if calendar == "" {
+		calendar = DefaultCalendarURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(calendar, "/")+"/digest", bytes.NewReader(digest))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.opentimestamps.v1")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	proof, status, err := send(o.HTTPClient, req)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("calendar %s rejected digest: %s: %s", calendar, http.StatusText(status), proof)
+	}
+
+	return proof, nil
+}
+
+// Ethereum publishes digests as the data of a zero-value transaction, sent through a node's
+// JSON-RPC eth_sendTransaction with an account the node or its signer unlocks. The proof is
+// the transaction hash.
+type Ethereum struct {
+	RPCURL string
+	// From is the sending account and To the recipient, usually the sender itself
+	From       string
+	To         string
+	HTTPClient *http.Client
+}
+
+// Name returns "ethereum"
+func (e *Ethereum) Name() string {
+	return "ethereum"
+}
+
+// Publish sends the anchoring transaction and returns its hash once the node accepts it
+func (e *Ethereum) Publish(ctx context.Context, digest []byte) ([]byte, error) {
+	to := e.To
+	if to == "" {
+		to = e.From
+	}
+
+	requestJSON, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_sendTransaction",
+		"params": []map[string]string{{
+			"from":  e.From,
+			"to":    to,
+			"value": "0x0",
+			"data":  "0x" + hex.EncodeToString(digest),
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.RPCURL, bytes.NewReader(requestJSON))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	body, status, err := send(e.HTTPClient, req)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("ethereum node rejected anchor: %s: %s", http.StatusText(status), body)
+	}
+
+	var response struct {
+		Result string `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse ethereum response: %v", err)
+	}
+	if response.Error != nil {
+		return nil, fmt.Errorf("ethereum node rejected anchor: %s (%d)", response.Error.Message, response.Error.Code)
+	}
+
+	return []byte(response.Result), nil
+}
+
+// send performs req and returns the response body and status
+func send(httpClient *http.Client, req *http.Request) ([]byte, int, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to reach %s: %v", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read response from %s: %v", req.URL.Host, err)
+	}
+
+	return body, resp.StatusCode, nil
+}
@@ -0,0 +1,372 @@
+// Package archive periodically exports committed logs to object storage, one object per time
+// window, alongside a manifest of content hashes that can be anchored on the ledger.
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/export"
+)
+
+// Archiver defaults
+const (
+	DefaultWindow   = time.Hour
+	DefaultDelay    = 10 * time.Minute
+	DefaultPageSize = 100
+)
+
+// ManifestAction is the action of the log recording a manifest hash on the ledger
+const ManifestAction = "ARCHIVE_MANIFEST"
+
+// keyTimeFormat names windows in object keys
+const keyTimeFormat = "20060102T150405Z"
+
+// Options configures an Archiver
+type Options struct {
+	Store Store
+	// Format is export.FormatNDJSON, written gzip compressed, or export.FormatParquet, whose
+	// columns are zstd compressed. NDJSON is used when empty.
+	Format export.Format
+	// Columns maps logs to output fields; export.DefaultColumns is used when empty
+	Columns []export.Column
+	// Window is the span of log timestamps in each archive; DefaultWindow is used otherwise
+	Window time.Duration
+	// Delay holds a window back after it ends so late commits are included; DefaultDelay is used otherwise
+	Delay time.Duration
+	// StatePath records the end of the last archived window, so archiving resumes after a restart
+	StatePath string
+	// Start is where archiving begins when there is no state yet. When zero, the first window
+	// archived is the latest complete one.
+	Start time.Time
+	// Anchor records each manifest's SHA-256 on the ledger as an ARCHIVE_MANIFEST log
+	Anchor bool
+	// AnchorUserID is the user of anchor logs; "archiver" is used when empty
+	AnchorUserID string
+	// PageSize is the number of logs read per query page; DefaultPageSize is used otherwise
+	PageSize int32
+	// OnError is called by Run with errors that will be retried at the next interval
+	OnError func(error)
+}
+
+// Manifest describes one archived window
+type Manifest struct {
+	WindowStart time.Time        `json:"windowStart"`
+	WindowEnd   time.Time        `json:"windowEnd"`
+	Format      export.Format    `json:"format"`
+	Objects     []ManifestObject `json:"objects"`
+	CreatedAt   time.Time        `json:"createdAt"`
+}
+
+// ManifestObject describes one stored archive object
+type ManifestObject struct {
+	Key     string `json:"key"`
+	Records int    `json:"records"`
+	Size    int64  `json:"size"`
+	SHA256  string `json:"sha256"`
+}
+
+// archiveState is the content of the state file
+type archiveState struct {
+	WindowEnd time.Time `json:"windowEnd"`
+}
+
+// Archiver exports each window of committed logs to a Store once the window has closed
+type Archiver struct {
+	client  *client.Client
+	options Options
+	mu      sync.Mutex
+}
+
+// NewArchiver returns an Archiver reading logs through c
+func NewArchiver(c *client.Client, options Options) (*Archiver, error) {
+	if options.Store == nil {
+		return nil, fmt.Errorf("no archive store configured")
+	}
+	if options.StatePath == "" {
+		return nil, fmt.Errorf("no archive state path configured")
+	}
+	if options.Format == "" {
+		options.Format = export.FormatNDJSON
+	}
+	if options.Format != export.FormatNDJSON && options.Format != export.FormatParquet {
+		return nil, fmt.Errorf("unsupported archive format %q", options.Format)
+	}
+	if len(options.Columns) == 0 {
+		options.Columns = export.DefaultColumns
+	}
+	if options.Window <= 0 {
+		options.Window = DefaultWindow
+	}
+	if options.Delay <= 0 {
+		options.Delay = DefaultDelay
+	}
+	if options.AnchorUserID == "" {
+		options.AnchorUserID = "archiver"
+	}
+	if options.PageSize <= 0 {
+		options.PageSize = DefaultPageSize
+	}
+
+	return &Archiver{client: c, options: options}, nil
+}
+
+// Run archives pending windows every interval until ctx is done, and returns ctx's error
+func (a *Archiver) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := a.ArchivePending(ctx); err != nil && ctx.Err() == nil && a.options.OnError != nil {
+			a.options.OnError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ArchivePending archives every window that has closed since the last archived one, oldest
+// first, and returns their manifests. The state is saved after each window, so a failure
+// resumes at the window that failed.
+func (a *Archiver) ArchivePending(ctx context.Context) ([]*Manifest, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	start, err := a.nextWindow()
+	if err != nil {
+		return nil, err
+	}
+
+	var manifests []*Manifest
+	cutoff := time.Now().UTC().Add(-a.options.Delay)
+	for end := start.Add(a.options.Window); !end.After(cutoff); end = start.Add(a.options.Window) {
+		manifest, err := a.ArchiveWindow(ctx, start, end)
+		if err != nil {
+			return manifests, err
+		}
+		if err := a.saveState(end); err != nil {
+			return manifests, err
+		}
+
+		manifests = append(manifests, manifest)
+		start = end
+	}
+
+	return manifests, nil
+}
+
+// ArchiveWindow exports the logs with timestamps from start up to but excluding end, stores
+// the archive and its manifest, and anchors the manifest hash when configured. Archiving the
+// same window again replaces its objects.
+func (a *Archiver) ArchiveWindow(ctx context.Context, start, end time.Time) (*Manifest, error) {
+	start, end = start.UTC(), end.UTC()
+	base := fmt.Sprintf("%s/%s-%s", start.Format("2006/01/02"), start.Format(keyTimeFormat), end.Format(keyTimeFormat))
+
+	object, err := a.writeObject(ctx, base, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{
+		WindowStart: start,
+		WindowEnd:   end,
+		Format:      a.options.Format,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if object != nil {
+		manifest.Objects = append(manifest.Objects, *object)
+	}
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	manifestKey := base + ".manifest.json"
+	if err := a.options.Store.Put(ctx, manifestKey, bytes.NewReader(manifestJSON), int64(len(manifestJSON))); err != nil {
+		return nil, err
+	}
+
+	if a.options.Anchor {
+		if err := a.anchor(ctx, manifest, manifestKey, manifestJSON); err != nil {
+			return nil, err
+		}
+	}
+
+	return manifest, nil
+}
+
+// writeObject exports the window to a temporary file and stores it. It returns nil when the
+// window holds no logs.
+func (a *Archiver) writeObject(ctx context.Context, base string, start, end time.Time) (*ManifestObject, error) {
+	tmp, err := os.CreateTemp("", "archive-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hasher := sha256.New()
+	records, err := a.export(ctx, io.MultiWriter(tmp, hasher), start, end)
+	if err != nil {
+		return nil, err
+	}
+	if records == 0 {
+		return nil, nil
+	}
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	key := base + "." + string(a.options.Format)
+	if a.options.Format == export.FormatNDJSON {
+		key += ".gz"
+	}
+	if err := a.options.Store.Put(ctx, key, tmp, size); err != nil {
+		return nil, err
+	}
+
+	return &ManifestObject{Key: key, Records: records, Size: size, SHA256: hexSum(hasher)}, nil
+}
+
+// export writes the window's logs to w in the archive format and returns how many were written
+func (a *Archiver) export(ctx context.Context, w io.Writer, start, end time.Time) (int, error) {
+	var compressed *gzip.Writer
+	if a.options.Format == export.FormatNDJSON {
+		compressed = gzip.NewWriter(w)
+		w = compressed
+	}
+
+	writer, err := export.NewWriter(a.options.Format, w, a.options.Columns)
+	if err != nil {
+		return 0, err
+	}
+
+	// The chaincode's time range is inclusive at both ends and has second precision
+	filter := client.LogFilter{
+		StartTime: start.Format(time.RFC3339),
+		EndTime:   end.Add(-time.Second).Format(time.RFC3339),
+	}
+	it := a.client.IterateLogs(filter, a.options.PageSize)
+
+	records := 0
+	for {
+		log, err := it.Next(ctx)
+		if errors.Is(err, client.ErrIteratorDone) {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read logs for archive: %w", err)
+		}
+		if err := writer.Write(log); err != nil {
+			return 0, fmt.Errorf("failed to write log %s to archive: %v", log.ID, err)
+		}
+		records++
+	}
+
+	if err := writer.Close(); err != nil {
+		return 0, err
+	}
+	if compressed != nil {
+		if err := compressed.Close(); err != nil {
+			return 0, err
+		}
+	}
+
+	return records, nil
+}
+
+// anchor records the manifest hash on the ledger. The log ID is derived from the window, so
+// anchoring a window twice fails with client.ErrAlreadyExists rather than adding a second record.
+func (a *Archiver) anchor(ctx context.Context, manifest *Manifest, manifestKey string, manifestJSON []byte) error {
+	sum := sha256.Sum256(manifestJSON)
+	records := 0
+	for _, object := range manifest.Objects {
+		records += object.Records
+	}
+
+	metadata, err := json.Marshal(map[string]interface{}{
+		"sha256":      hex.EncodeToString(sum[:]),
+		"records":     records,
+		"windowStart": manifest.WindowStart,
+		"windowEnd":   manifest.WindowEnd,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = a.client.CreateLog(ctx, client.LogEvent{
+		ID:          "archive-" + manifest.WindowStart.Format(keyTimeFormat),
+		UserID:      a.options.AnchorUserID,
+		Action:      ManifestAction,
+		Resource:    manifestKey,
+		Description: fmt.Sprintf("Archive manifest for logs from %s to %s", manifest.WindowStart.Format(time.RFC3339), manifest.WindowEnd.Format(time.RFC3339)),
+		Metadata:    string(metadata),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to anchor manifest %s: %w", manifestKey, err)
+	}
+
+	return nil
+}
+
+// nextWindow returns the start of the next window to archive
+func (a *Archiver) nextWindow() (time.Time, error) {
+	stateJSON, err := os.ReadFile(a.options.StatePath)
+	if err == nil {
+		var state archiveState
+		if err := json.Unmarshal(stateJSON, &state); err != nil {
+			return time.Time{}, fmt.Errorf("failed to parse archive state: %v", err)
+		}
+		return state.WindowEnd, nil
+	}
+	if !os.IsNotExist(err) {
+		return time.Time{}, fmt.Errorf("failed to read archive state: %v", err)
+	}
+
+	if !a.options.Start.IsZero() {
+		return a.options.Start.UTC(), nil
+	}
+
+	// Start with the latest window that has already closed
+	closed := time.Now().UTC().Add(-a.options.Delay).Truncate(a.options.Window)
+	return closed.Add(-a.options.Window), nil
+}
+
+// saveState replaces the state file atomically
+func (a *Archiver) saveState(windowEnd time.Time) error {
+	stateJSON, err := json.Marshal(archiveState{WindowEnd: windowEnd})
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(a.options.StatePath+".tmp", stateJSON, 0600); err != nil {
+		return fmt.Errorf("failed to write archive state: %v", err)
+	}
+
+	return os.Rename(a.options.StatePath+".tmp", a.options.StatePath)
+}
+
+func hexSum(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,62 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// OpenStore returns the store named by location: s3://bucket/prefix, gs://bucket/prefix,
+// azblob://container/prefix, or a local directory. Cloud credentials come from the environment.
+func OpenStore(ctx context.Context, location string) (Store, error) {
+	scheme, rest, ok := strings.Cut(location, "://")
+	if !ok {
+		return NewDirStore(location), nil
+	}
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("no bucket in store %q", location)
+	}
+
+	switch scheme {
+	case "s3":
+		// AWS_ENDPOINT_URL points at an S3 compatible service instead of AWS
+		credentials := aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{
+				AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+				SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+				SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+				Source:          "environment",
+			}, nil
+		})
+		options := s3.Options{
+			Region:      os.Getenv("AWS_REGION"),
+			Credentials: aws.NewCredentialsCache(credentials),
+		}
+		if endpoint := os.Getenv("AWS_ENDPOINT_URL"); endpoint != "" {
+			options.BaseEndpoint = aws.String(endpoint)
+			options.UsePathStyle = true
+		}
+		return NewS3Store(s3.New(options), bucket, prefix), nil
+	case "gs":
+		c, err := storage.NewClient(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Cloud Storage client: %v", err)
+		}
+		return NewGCSStore(c, bucket, prefix), nil
+	case "azblob":
+		c, err := azblob.NewClientFromConnectionString(os.Getenv("AZURE_STORAGE_CONNECTION_STRING"), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Azure Blob Storage client: %v", err)
+		}
+		return NewAzureStore(c, bucket, prefix), nil
+	default:
+		return nil, fmt.Errorf("unsupported store %q: expected s3://, gs://, azblob:// or a directory", location)
+	}
+}
@@ -0,0 +1,53 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Store is the object storage archives are written to
+type Store interface {
+	// Put stores size bytes read from body under key, replacing any existing object
+	Put(ctx context.Context, key string, body io.Reader, size int64) error
+}
+
+// DirStore is a Store writing objects as files under a local directory, for tests and
+// for archives kept on mounted storage
+type DirStore struct {
+	dir string
+}
+
+// NewDirStore returns a DirStore rooted at dir
+func NewDirStore(dir string) *DirStore {
+	return &DirStore{dir: dir}
+}
+
+// Put writes the object to a temporary file and renames it into place
+func (s *DirStore) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create archive directory: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".object-*")
+	if err != nil {
+		return fmt.Errorf("failed to write object %s: %v", key, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, body)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write object %s: %v", key, err)
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
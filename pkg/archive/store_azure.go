@@ -0,0 +1,31 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+)
+
+// AzureStore is a Store writing objects as block blobs to an Azure Blob Storage container
+type AzureStore struct {
+	client    *azblob.Client
+	container string
+	prefix    string
+}
+
+// NewAzureStore returns a Store writing to container through client, with blob names under prefix
+func NewAzureStore(client *azblob.Client, container string, prefix string) *AzureStore {
+	return &AzureStore{client: client, container: container, prefix: prefix}
+}
+
+// Put uploads the object as a block blob
+func (s *AzureStore) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	if _, err := s.client.UploadStream(ctx, s.container, path.Join(s.prefix, key), body, nil); err != nil {
+		return fmt.Errorf("failed to upload %s to container %s: %v", key, s.container, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+
+	"cloud.google.com/go/storage"
+)
+
+// GCSStore is a Store writing objects to a Google Cloud Storage bucket
+type GCSStore struct {
+	client *storage.Client
+	bucket string
+	prefix string
+}
+
+// NewGCSStore returns a Store writing to bucket through client, with keys under prefix
+func NewGCSStore(client *storage.Client, bucket string, prefix string) *GCSStore {
+	return &GCSStore{client: client, bucket: bucket, prefix: prefix}
+}
+
+// Put streams the object to the bucket; it only becomes visible once fully written
+func (s *GCSStore) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	w := s.client.Bucket(s.bucket).Object(path.Join(s.prefix, key)).NewWriter(ctx)
+	if _, err := io.Copy(w, body); err != nil {
+		w.Close()
+		return fmt.Errorf("failed to upload %s to gs://%s: %v", key, s.bucket, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to upload %s to gs://%s: %v", key, s.bucket, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Store is a Store writing objects to an Amazon S3, or S3 compatible, bucket
+type S3Store struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+// NewS3Store returns a Store writing to bucket through client, with keys under prefix
+func NewS3Store(client *s3.Client, bucket string, prefix string) *S3Store {
+	return &S3Store{client: client, bucket: bucket, prefix: prefix}
+}
+
+// Put uploads the object in a single PutObject request
+func (s *S3Store) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(path.Join(s.prefix, key)),
+		Body:          body,
+		ContentLength: size,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload %s to s3://%s: %v", key, s.bucket, err)
+	}
+
+	return nil
+}
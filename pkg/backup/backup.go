@@ -0,0 +1,190 @@
+// Package backup writes the full log namespace to a signed archive and restores it into another
+// channel, keeping the original log IDs and timestamps, for disaster-recovery drills.
+//
+// An archive is a gzip compressed tar holding, in order, manifest.json, signature.json and
+// logs.ndjson. The manifest records the number of logs and the SHA-256 of logs.ndjson, and the
+// signature covers the manifest bytes, so a restore rejects archives that were altered or not
+// produced by a trusted identity.
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// Version is the archive format written by Backup
+const Version = 1
+
+// DefaultPageSize is the number of logs read per query page during a backup
+const DefaultPageSize = 200
+
+// Archive entry names
+const (
+	manifestEntry  = "manifest.json"
+	signatureEntry = "signature.json"
+	logsEntry      = "logs.ndjson"
+)
+
+// Manifest describes the contents of a backup archive
+type Manifest struct {
+	Version   int       `json:"version"`
+	Source    string    `json:"source,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	Records   int       `json:"records"`
+	// PrivateRecords counts the logs whose details live in a private data collection. Only their
+	// public part is backed up.
+	PrivateRecords int    `json:"privateRecords"`
+	SHA256         string `json:"sha256"`
+}
+
+// Options configures Backup
+type Options struct {
+	// Identity and Sign sign the manifest; both are required
+	Identity *client.Identity
+	Sign     client.Sign
+	// Source labels where the logs came from, such as the channel name
+	Source string
+	// PageSize is the number of logs read per query page; DefaultPageSize is used otherwise
+	PageSize int32
+}
+
+// Backup writes every log readable through c to w as a signed archive and returns its manifest.
+// Logs are written as the client returns them, so compressed metadata is stored decoded.
+func Backup(ctx context.Context, c *client.Client, w io.Writer, options Options) (*Manifest, error) {
+	if options.Identity == nil || options.Sign == nil {
+		return nil, fmt.Errorf("a signing identity is required to back up logs")
+	}
+	if options.PageSize <= 0 {
+		options.PageSize = DefaultPageSize
+	}
+
+	// The tar header of logs.ndjson needs its size, and the manifest its hash, so the logs are
+	// staged in a temporary file first
+	staged, err := os.CreateTemp("", "fabric-log-backup-*.ndjson")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging file: %v", err)
+	}
+	defer os.Remove(staged.Name())
+	defer staged.Close()
+
+	manifest := &Manifest{
+		Version:   Version,
+		Source:    options.Source,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	digest := sha256.New()
+	encoder := json.NewEncoder(io.MultiWriter(staged, digest))
+	it := c.IterateAllLogs(options.PageSize)
+	for {
+		log, err := it.Next(ctx)
+		if errors.Is(err, client.ErrIteratorDone) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read logs after %d records: %v", manifest.Records, err)
+		}
+		if err := encoder.Encode(log); err != nil {
+			return nil, fmt.Errorf("failed to stage log %s: %v", log.ID, err)
+		}
+		manifest.Records++
+		if log.Collection != "" {
+			manifest.PrivateRecords++
+		}
+	}
+	manifest.SHA256 = fmt.Sprintf("%x", digest.Sum(nil))
+
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := sign(manifestJSON, options.Identity, options.Sign)
+	if err != nil {
+		return nil, err
+	}
+	signatureJSON, err := json.Marshal(signature)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := staged.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := staged.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	if err := writeEntry(tw, manifestEntry, int64(len(manifestJSON)), manifest.CreatedAt, bytes.NewReader(manifestJSON)); err != nil {
+		return nil, err
+	}
+	if err := writeEntry(tw, signatureEntry, int64(len(signatureJSON)), manifest.CreatedAt, bytes.NewReader(signatureJSON)); err != nil {
+		return nil, err
+	}
+	if err := writeEntry(tw, logsEntry, size, manifest.CreatedAt, staged); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive: %v", err)
+	}
+
+	return manifest, nil
+}
+
+// BackupFile writes the archive to path, replacing it only once the backup is complete
+func BackupFile(ctx context.Context, c *client.Client, path string, options Options) (*Manifest, error) {
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup file: %v", err)
+	}
+
+	manifest, err := Backup(ctx, c, file, options)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write backup file: %v", closeErr)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("failed to replace backup file: %v", err)
+	}
+
+	return manifest, nil
+}
+
+func writeEntry(tw *tar.Writer, name string, size int64, modTime time.Time, body io.Reader) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    size,
+		ModTime: modTime,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s: %v", name, err)
+	}
+	if _, err := io.Copy(tw, body); err != nil {
+		return fmt.Errorf("failed to write %s: %v", name, err)
+	}
+
+	return nil
+}
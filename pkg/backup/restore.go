@@ -0,0 +1,250 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// DefaultBatchSize is the number of logs written per restore transaction
+const DefaultBatchSize = 100
+
+// Target is the channel a backup is restored into; *client.Client implements it
+type Target interface {
+	RestoreLogsBatch(ctx context.Context, logs []client.LogEvent) (*client.SubmitResult, error)
+}
+
+// RestoreOptions configures Restore
+type RestoreOptions struct {
+	// Roots are the CAs trusted to have issued the backup signer's certificate. When nil, only
+	// the signature itself is checked.
+	Roots *x509.CertPool
+	// BatchSize is the number of logs per transaction; DefaultBatchSize is used otherwise
+	BatchSize int
+	// Progress, if set, is called after each batch with the number of logs handled so far
+	Progress func(handled int)
+}
+
+// RestoreResult summarizes a restore
+type RestoreResult struct {
+	Manifest *Manifest
+	Signer   *x509.Certificate
+	// Restored counts the logs written; Existing those already on the target, so an interrupted
+	// restore can be run again; Private the private logs left out because their details were not backed up
+	Restored int
+	Existing int
+	Private  int
+}
+
+// Restore verifies the archive read from r and writes its logs to target in batches, keeping
+// their IDs and timestamps. Nothing is written unless the signature and content hash verify.
+func Restore(ctx context.Context, r io.Reader, target Target, options RestoreOptions) (*RestoreResult, error) {
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultBatchSize
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %v", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	manifestJSON, err := readEntry(tr, manifestEntry)
+	if err != nil {
+		return nil, err
+	}
+	signatureJSON, err := readEntry(tr, signatureEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	var signature Signature
+	if err := json.Unmarshal(signatureJSON, &signature); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", signatureEntry, err)
+	}
+	signer, err := verify(manifestJSON, &signature, options.Roots)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", manifestEntry, err)
+	}
+	if manifest.Version != Version {
+		return nil, fmt.Errorf("unsupported backup version %d", manifest.Version)
+	}
+
+	// The logs are only trusted once their hash matches the manifest, so they are staged before
+	// anything is written
+	staged, err := stageLogs(tr, &manifest)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(staged.Name())
+	defer staged.Close()
+
+	result := &RestoreResult{Manifest: &manifest, Signer: signer}
+	scanner := bufio.NewScanner(staged)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	batch := make([]client.LogEvent, 0, options.BatchSize)
+	for scanner.Scan() {
+		var log client.LogEvent
+		if err := json.Unmarshal(scanner.Bytes(), &log); err != nil {
+			return result, fmt.Errorf("failed to parse backed up log: %v", err)
+		}
+		if log.Collection != "" {
+			result.Private++
+			continue
+		}
+
+		batch = append(batch, log)
+		if len(batch) == options.BatchSize {
+			if err := restoreBatch(ctx, target, batch, result); err != nil {
+				return result, err
+			}
+			batch = batch[:0]
+			if options.Progress != nil {
+				options.Progress(result.Restored + result.Existing + result.Private)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return result, fmt.Errorf("failed to read staged logs: %v", err)
+	}
+	if len(batch) > 0 {
+		if err := restoreBatch(ctx, target, batch, result); err != nil {
+			return result, err
+		}
+		if options.Progress != nil {
+			options.Progress(result.Restored + result.Existing + result.Private)
+		}
+	}
+
+	return result, nil
+}
+
+// RestoreFile restores the archive at path into target
+func RestoreFile(ctx context.Context, path string, target Target, options RestoreOptions) (*RestoreResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup file: %v", err)
+	}
+	defer file.Close()
+
+	return Restore(ctx, file, target, options)
+}
+
+// restoreBatch writes batch in one transaction. A batch is all or nothing, so when some of its
+// logs already exist the logs are written one by one and the existing ones counted.
+func restoreBatch(ctx context.Context, target Target, batch []client.LogEvent, result *RestoreResult) error {
+	_, err := target.RestoreLogsBatch(ctx, batch)
+	if err == nil {
+		result.Restored += len(batch)
+		return nil
+	}
+	if !errors.Is(err, client.ErrAlreadyExists) {
+		return fmt.Errorf("failed to restore batch starting at log %s: %w", batch[0].ID, err)
+	}
+
+	for _, log := range batch {
+		_, err := target.RestoreLogsBatch(ctx, []client.LogEvent{log})
+		switch {
+		case err == nil:
+			result.Restored++
+		case errors.Is(err, client.ErrAlreadyExists):
+			result.Existing++
+		default:
+			return fmt.Errorf("failed to restore log %s: %w", log.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// stageLogs copies logs.ndjson to a temporary file, checking its hash and record count against manifest
+func stageLogs(tr *tar.Reader, manifest *Manifest) (*os.File, error) {
+	if err := nextEntry(tr, logsEntry); err != nil {
+		return nil, err
+	}
+
+	staged, err := os.CreateTemp("", "fabric-log-restore-*.ndjson")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging file: %v", err)
+	}
+	fail := func(err error) (*os.File, error) {
+		staged.Close()
+		os.Remove(staged.Name())
+		return nil, err
+	}
+
+	digest := sha256.New()
+	lines := &lineCounter{}
+	if _, err := io.Copy(io.MultiWriter(staged, digest, lines), tr); err != nil {
+		return fail(fmt.Errorf("failed to read %s: %v", logsEntry, err))
+	}
+	if sum := fmt.Sprintf("%x", digest.Sum(nil)); sum != manifest.SHA256 {
+		return fail(fmt.Errorf("%w: %s has SHA-256 %s, the manifest records %s", ErrInvalidSignature, logsEntry, sum, manifest.SHA256))
+	}
+	if lines.n != manifest.Records {
+		return fail(fmt.Errorf("%s holds %d logs, the manifest records %d", logsEntry, lines.n, manifest.Records))
+	}
+
+	if _, err := staged.Seek(0, io.SeekStart); err != nil {
+		return fail(err)
+	}
+
+	return staged, nil
+}
+
+// readEntry reads the next archive entry, which must be called name
+func readEntry(tr *tar.Reader, name string) ([]byte, error) {
+	if err := nextEntry(tr, name); err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(tr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", name, err)
+	}
+
+	return data, nil
+}
+
+func nextEntry(tr *tar.Reader, name string) error {
+	header, err := tr.Next()
+	if err != nil {
+		return fmt.Errorf("failed to read %s from archive: %v", name, err)
+	}
+	if header.Name != name {
+		return fmt.Errorf("unexpected archive entry %s, expected %s", header.Name, name)
+	}
+
+	return nil
+}
+
+// lineCounter counts the newline-terminated lines written to it
+type lineCounter struct {
+	n int
+}
+
+func (c *lineCounter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if b == '\n' {
+			c.n++
+		}
+	}
+
+	return len(p), nil
+}
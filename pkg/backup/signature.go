@@ -0,0 +1,70 @@
+package backup
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// ErrInvalidSignature is returned when an archive's manifest signature does not verify
+var ErrInvalidSignature = errors.New("invalid backup signature")
+
+// Signature is an ECDSA signature over the SHA-256 of the manifest, with the signer's identity
+type Signature struct {
+	MSPID       string `json:"mspId"`
+	Certificate string `json:"certificate"`
+	Signature   []byte `json:"signature"`
+}
+
+func sign(manifestJSON []byte, id *client.Identity, signFn client.Sign) (*Signature, error) {
+	digest := sha256.Sum256(manifestJSON)
+	signature, err := signFn(digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign manifest: %v", err)
+	}
+
+	return &Signature{
+		MSPID:       id.MSPID,
+		Certificate: string(id.Certificate),
+		Signature:   signature,
+	}, nil
+}
+
+// verify checks signature over manifestJSON and, when roots is not nil, that the signing
+// certificate chains to one of roots
+func verify(manifestJSON []byte, signature *Signature, roots *x509.CertPool) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(signature.Certificate))
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM certificate", ErrInvalidSignature)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to parse certificate: %v", ErrInvalidSignature, err)
+	}
+
+	key, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported public key type %T", ErrInvalidSignature, cert.PublicKey)
+	}
+	digest := sha256.Sum256(manifestJSON)
+	if !ecdsa.VerifyASN1(key, digest[:], signature.Signature) {
+		return nil, fmt.Errorf("%w: manifest signature does not match", ErrInvalidSignature)
+	}
+
+	if roots != nil {
+		_, err := cert.Verify(x509.VerifyOptions{
+			Roots:     roots,
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%w: untrusted signer: %v", ErrInvalidSignature, err)
+		}
+	}
+
+	return cert, nil
+}
@@ -0,0 +1,285 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Default thresholds used by BatchingSubmitter
+const (
+	DefaultMaxBatchSize  = 100
+	DefaultFlushInterval = 2 * time.Second
+)
+
+// ErrSubmitterClosed is returned when logs are added to a closed BatchingSubmitter
+var ErrSubmitterClosed = errors.New("batching submitter is closed")
+
+// BatchOptions configures a BatchingSubmitter
+type BatchOptions struct {
+	// MaxBatchSize is the number of buffered logs that triggers a flush
+	MaxBatchSize int
+	// FlushInterval is the longest a log waits in the buffer before being flushed
+	FlushInterval time.Duration
+	// OnError is called with the logs that could not be submitted: those rejected by the
+	// chaincode, and those of a background flush that fails. They are logged with slog when nil.
+	OnError func(logs []LogEvent, err error)
+	// RateLimiter, if set, paces the submitted batches at one token per log
+	RateLimiter *RateLimiter
+	// MaxBuffered, when positive, is the most logs held in the buffer. CreateLog refuses logs
+	// beyond it with ErrBackpressure, so producers learn that submissions are falling behind.
+	MaxBuffered int
+}
+
+// BatchingSubmitter buffers CreateLog calls and submits them as CreateLogsBatch
+// transactions once MaxBatchSize logs are buffered or FlushInterval elapses. A batch is
+// committed whole or not at all, so when the chaincode rejects one because some of its logs are
+// invalid or already recorded, its logs are submitted one by one and only those rejected are
+// reported to OnError.
+type BatchingSubmitter struct {
+	client  LoggingClient
+	options BatchOptions
+
+	mu     sync.Mutex
+	buffer []LogEvent
+	closed bool
+
+	// flushMu serializes submissions so batches are committed in the order they were buffered
+	flushMu sync.Mutex
+
+	// ctx is canceled by Close to abort a background flush still in progress
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	full chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewBatchingSubmitter starts a BatchingSubmitter that submits through client
+func NewBatchingSubmitter(client LoggingClient, options BatchOptions) *BatchingSubmitter {
+	if options.MaxBatchSize <= 0 {
+		options.MaxBatchSize = DefaultMaxBatchSize
+	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = DefaultFlushInterval
+	}
+	if options.OnError == nil {
+		options.OnError = func(logs []LogEvent, err error) {
+			slog.Error("failed to submit logs", "count", len(logs), "error", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &BatchingSubmitter{
+		client:  client,
+		options: options,
+		ctx:     ctx,
+		cancel:  cancel,
+		full:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+
+	b.wg.Add(1)
+	go b.run()
+
+	return b
+}
+
+// CreateLog buffers log for the next batch. It never blocks on the network, so ctx is only
+// checked for cancellation.
+func (b *BatchingSubmitter) CreateLog(ctx context.Context, log LogEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrSubmitterClosed
+	}
+	if b.options.MaxBuffered > 0 && len(b.buffer) >= b.options.MaxBuffered {
+		return ErrBackpressure
+	}
+
+	b.buffer = append(b.buffer, log)
+	if len(b.buffer) >= b.options.MaxBatchSize {
+		select {
+		case b.full <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Len returns the number of logs buffered for the next batch
+func (b *BatchingSubmitter) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.buffer)
+}
+
+// Flush submits everything currently buffered and waits for it to be committed. Logs the
+// chaincode rejects are reported to OnError. The logs of a batch that fails otherwise go back to
+// the front of the buffer, so a later Flush retries them and Close reports them to OnError.
+func (b *BatchingSubmitter) Flush(ctx context.Context) error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	for {
+		batch := b.take()
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := b.options.RateLimiter.wait(ctx, len(batch)); err != nil {
+			b.requeue(batch)
+			return err
+		}
+		if remaining, err := b.submit(ctx, batch); err != nil {
+			b.requeue(remaining)
+			return err
+		}
+	}
+}
+
+// Close stops the background flusher and submits any remaining logs. When ctx is done before
+// that completes, any background flush still running is canceled and the remaining logs are
+// reported to OnError.
+func (b *BatchingSubmitter) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.mu.Unlock()
+
+	close(b.done)
+	defer b.cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		b.cancel()
+		<-stopped
+	}
+
+	err := b.Flush(ctx)
+	if err != nil {
+		for remaining := b.take(); len(remaining) > 0; remaining = b.take() {
+			b.options.OnError(remaining, err)
+		}
+	}
+
+	return err
+}
+
+func (b *BatchingSubmitter) run() {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(b.options.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+		case <-b.full:
+		}
+
+		b.flushInBackground()
+	}
+}
+
+// flushInBackground submits buffered batches, reporting failures to OnError
+func (b *BatchingSubmitter) flushInBackground() {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	for {
+		batch := b.take()
+		if len(batch) == 0 {
+			return
+		}
+
+		if err := b.options.RateLimiter.wait(b.ctx, len(batch)); err != nil {
+			// Closing; the batch goes back to the buffer for Close to flush
+			b.requeue(batch)
+			return
+		}
+		if remaining, err := b.submit(b.ctx, batch); err != nil {
+			b.options.OnError(remaining, err)
+		}
+	}
+}
+
+// submit submits batch, reporting the logs the chaincode rejects to OnError. When a failure may
+// succeed later, it returns the logs not yet submitted with that failure.
+func (b *BatchingSubmitter) submit(ctx context.Context, batch []LogEvent) ([]LogEvent, error) {
+	err := b.client.CreateLogsBatch(ctx, batch)
+	if !isRejected(err) {
+		if err != nil {
+			return batch, err
+		}
+		return nil, nil
+	}
+	if len(batch) == 1 {
+		b.options.OnError(batch, err)
+		return nil, nil
+	}
+
+	// The batch is all or nothing, so submit its logs one by one to find those at fault
+	for i, log := range batch {
+		err := b.client.CreateLog(ctx, log)
+		if isRejected(err) {
+			b.options.OnError([]LogEvent{log}, err)
+			continue
+		}
+		if err != nil {
+			return batch[i:], err
+		}
+	}
+	return nil, nil
+}
+
+// isRejected reports whether err is the chaincode refusing logs, which submitting them again cannot change
+func isRejected(err error) bool {
+	return errors.Is(err, ErrInvalidLog) || errors.Is(err, ErrAlreadyExists)
+}
+
+// requeue puts a batch that was taken but not submitted back at the front of the buffer
+func (b *BatchingSubmitter) requeue(batch []LogEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buffer = append(batch, b.buffer...)
+}
+
+// take removes and returns up to MaxBatchSize logs from the buffer
+func (b *BatchingSubmitter) take() []LogEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := len(b.buffer)
+	if n > b.options.MaxBatchSize {
+		n = b.options.MaxBatchSize
+	}
+
+	batch := make([]LogEvent, n)
+	copy(batch, b.buffer[:n])
+	b.buffer = b.buffer[n:]
+
+	return batch
+}
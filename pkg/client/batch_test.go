@@ -0,0 +1,159 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ledger is a LoggingClient that rejects batches as the chaincode does: whole, when any log is
+// invalid or already recorded
+type ledger struct {
+	LoggingClient
+
+	mu       sync.Mutex
+	ids      map[string]bool
+	batches  int
+	singles  int
+	failNext error
+}
+
+func (l *ledger) check(log LogEvent) error {
+	if log.Action == "" {
+		return fmt.Errorf("%w: the log %s has no action", ErrInvalidLog, log.ID)
+	}
+	if l.ids[log.ID] {
+		return fmt.Errorf("%w: the log %s already exists", ErrAlreadyExists, log.ID)
+	}
+	return nil
+}
+
+func (l *ledger) CreateLogsBatch(_ context.Context, logs []LogEvent) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.batches++
+	if err := l.failNext; err != nil {
+		l.failNext = nil
+		return err
+	}
+	for _, log := range logs {
+		if err := l.check(log); err != nil {
+			return err
+		}
+	}
+	for _, log := range logs {
+		l.ids[log.ID] = true
+	}
+	return nil
+}
+
+func (l *ledger) CreateLog(_ context.Context, log LogEvent) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.singles++
+	if err := l.check(log); err != nil {
+		return err
+	}
+	l.ids[log.ID] = true
+	return nil
+}
+
+// failures collects the logs reported to OnError
+type failures struct {
+	mu   sync.Mutex
+	logs map[string]error
+}
+
+func (f *failures) record(logs []LogEvent, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, log := range logs {
+		f.logs[log.ID] = err
+	}
+}
+
+func testLog(id string) LogEvent {
+	return LogEvent{ID: id, UserID: "u1", Action: "LOGIN", Resource: "app"}
+}
+
+func TestBatchingSubmitterIsolatesRejectedLogs(t *testing.T) {
+	target := &ledger{ids: map[string]bool{"dup": true}}
+	failed := &failures{logs: map[string]error{}}
+	b := NewBatchingSubmitter(target, BatchOptions{MaxBatchSize: 10, FlushInterval: time.Hour, OnError: failed.record})
+	defer b.Close(context.Background())
+
+	invalid := testLog("invalid")
+	invalid.Action = ""
+	for _, log := range []LogEvent{testLog("a"), testLog("dup"), invalid, testLog("b")} {
+		if err := b.CreateLog(context.Background(), log); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush = %v, want the valid logs submitted", err)
+	}
+
+	if !target.ids["a"] || !target.ids["b"] || target.ids["invalid"] {
+		t.Errorf("recorded %v, want a and b", target.ids)
+	}
+	if len(failed.logs) != 2 || !errors.Is(failed.logs["dup"], ErrAlreadyExists) || !errors.Is(failed.logs["invalid"], ErrInvalidLog) {
+		t.Errorf("reported %v, want dup and invalid", failed.logs)
+	}
+	if b.Len() != 0 {
+		t.Errorf("%d logs left in the buffer", b.Len())
+	}
+}
+
+func TestBatchingSubmitterRequeuesTransientFailures(t *testing.T) {
+	target := &ledger{ids: map[string]bool{}, failNext: &TransientError{Err: errors.New("peer unavailable")}}
+	failed := &failures{logs: map[string]error{}}
+	b := NewBatchingSubmitter(target, BatchOptions{MaxBatchSize: 10, FlushInterval: time.Hour, OnError: failed.record})
+	defer b.Close(context.Background())
+
+	for _, id := range []string{"a", "b"} {
+		if err := b.CreateLog(context.Background(), testLog(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Nothing is known to be wrong with the logs, so they are neither split up nor reported
+	if err := b.Flush(context.Background()); err == nil {
+		t.Fatal("Flush succeeded, want the transient failure")
+	}
+	if b.Len() != 2 || target.singles != 0 || len(failed.logs) != 0 {
+		t.Errorf("buffered %d, submitted %d singly, reported %v; want both logs requeued", b.Len(), target.singles, failed.logs)
+	}
+
+	if err := b.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !target.ids["a"] || !target.ids["b"] || target.batches != 2 {
+		t.Errorf("recorded %v in %d batches, want a and b on the second", target.ids, target.batches)
+	}
+}
+
+func TestBatchingSubmitterReportsBackgroundFailures(t *testing.T) {
+	target := &ledger{ids: map[string]bool{"dup": true}}
+	failed := &failures{logs: map[string]error{}}
+	b := NewBatchingSubmitter(target, BatchOptions{MaxBatchSize: 2, FlushInterval: time.Hour, OnError: failed.record})
+
+	for _, id := range []string{"a", "dup"} {
+		if err := b.CreateLog(context.Background(), testLog(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !target.ids["a"] {
+		t.Error("the valid log was dropped with the batch")
+	}
+	if len(failed.logs) != 1 || !errors.Is(failed.logs["dup"], ErrAlreadyExists) {
+		t.Errorf("reported %v, want dup", failed.logs)
+	}
+}
@@ -0,0 +1,217 @@
+package client
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Log cache defaults
+const (
+	DefaultCacheSize        = 10000
+	DefaultCacheTTL         = 5 * time.Minute
+	DefaultCacheNegativeTTL = 5 * time.Second
+)
+
+// CacheOptions configures a LogCache
+type CacheOptions struct {
+	// Size is the most entries held, least recently used evicted first; DefaultCacheSize is used otherwise
+	Size int
+	// TTL is how long a log read from the ledger is served from the cache; DefaultCacheTTL is used otherwise
+	TTL time.Duration
+	// NegativeTTL is how long a missing log is remembered as missing; DefaultCacheNegativeTTL is used otherwise
+	NegativeTTL time.Duration
+}
+
+// LogCache is an LRU cache with expiry for ReadLog and LogExists lookups. Logs are never
+// changed once written, so a cached log stays valid until it is pruned, and the knowledge that
+// a log does not exist goes stale once it is written. Feed the cache the chaincode events with
+// c.NewEventListener(cache.HandleEvent, IncludePruned()).Run(ctx) to forget such entries as
+// soon as any client writes or prunes the log; the client's own submissions are forgotten
+// without a listener.
+type LogCache struct {
+	options CacheOptions
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+	hits    uint64
+	misses  uint64
+}
+
+type cacheEntry struct {
+	id      string
+	log     *LogEvent // nil when only existence is known
+	exists  bool
+	expires time.Time
+}
+
+// NewLogCache returns an empty cache
+func NewLogCache(options CacheOptions) *LogCache {
+	if options.Size <= 0 {
+		options.Size = DefaultCacheSize
+	}
+	if options.TTL <= 0 {
+		options.TTL = DefaultCacheTTL
+	}
+	if options.NegativeTTL <= 0 {
+		options.NegativeTTL = DefaultCacheNegativeTTL
+	}
+
+	return &LogCache{
+		options: options,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+// WithCache serves ReadLog and LogExists from cache, reading through to the ledger on a miss
+func WithCache(cache *LogCache) Option {
+	return func(c *Client) {
+		c.cache = cache
+	}
+}
+
+// Invalidate forgets the given log IDs
+func (lc *LogCache) Invalidate(ids ...string) {
+	if lc == nil {
+		return
+	}
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	for _, id := range ids {
+		if element, ok := lc.entries[id]; ok {
+			lc.order.Remove(element)
+			delete(lc.entries, id)
+		}
+	}
+}
+
+// Purge forgets every entry
+func (lc *LogCache) Purge() {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	lc.entries = map[string]*list.Element{}
+	lc.order.Init()
+}
+
+// Len returns the number of entries held, including expired ones not yet evicted
+func (lc *LogCache) Len() int {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	return lc.order.Len()
+}
+
+// Stats returns the number of lookups served from the cache and read through to the ledger
+func (lc *LogCache) Stats() (hits uint64, misses uint64) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	return lc.hits, lc.misses
+}
+
+// HandleEvent is an EventHandler forgetting the logs written or pruned by each event
+func (lc *LogCache) HandleEvent(event ContractEvent) error {
+	lc.Invalidate(event.Log.ID)
+	return nil
+}
+
+// get returns the live entry for id
+func (lc *LogCache) get(id string) (cacheEntry, bool) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	element, ok := lc.entries[id]
+	if !ok {
+		lc.misses++
+		return cacheEntry{}, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		lc.order.Remove(element)
+		delete(lc.entries, id)
+		lc.misses++
+		return cacheEntry{}, false
+	}
+
+	lc.order.MoveToFront(element)
+	lc.hits++
+	return *entry, true
+}
+
+// put stores what is known about id; log is nil when only existence is known
+func (lc *LogCache) put(id string, log *LogEvent, exists bool) {
+	ttl := lc.options.TTL
+	if !exists {
+		ttl = lc.options.NegativeTTL
+	}
+	entry := &cacheEntry{id: id, log: log, exists: exists, expires: time.Now().Add(ttl)}
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if element, ok := lc.entries[id]; ok {
+		// Keep a cached log when only its existence is learned again
+		if previous := element.Value.(*cacheEntry); exists && log == nil && previous.log != nil {
+			entry.log = previous.log
+		}
+		element.Value = entry
+		lc.order.MoveToFront(element)
+		return
+	}
+
+	lc.entries[id] = lc.order.PushFront(entry)
+	for lc.order.Len() > lc.options.Size {
+		oldest := lc.order.Back()
+		lc.order.Remove(oldest)
+		delete(lc.entries, oldest.Value.(*cacheEntry).id)
+	}
+}
+
+// cachedReadLog serves ReadLog from the cache, reading through with read on a miss
+func (lc *LogCache) cachedReadLog(ctx context.Context, id string, read func(context.Context, string) (*LogEvent, error)) (*LogEvent, error) {
+	if entry, ok := lc.get(id); ok {
+		if !entry.exists {
+			return nil, fmt.Errorf("%w: %s", ErrLogNotFound, id)
+		}
+		if entry.log != nil {
+			log := *entry.log
+			return &log, nil
+		}
+	}
+
+	log, err := read(ctx, id)
+	if errors.Is(err, ErrLogNotFound) {
+		lc.put(id, nil, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cached := *log
+	lc.put(id, &cached, true)
+	return log, nil
+}
+
+// cachedLogExists serves LogExists from the cache, reading through with exists on a miss
+func (lc *LogCache) cachedLogExists(ctx context.Context, id string, exists func(context.Context, string) (bool, error)) (bool, error) {
+	if entry, ok := lc.get(id); ok {
+		return entry.exists, nil
+	}
+
+	found, err := exists(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	lc.put(id, nil, found)
+	return found, nil
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Checkpoint is the position of the last event a consumer finished processing
+type Checkpoint struct {
+	BlockNumber   uint64 `json:"blockNumber"`
+	TransactionID string `json:"transactionId"`
+}
+
+// Checkpointer persists a consumer's checkpoint, so an EventListener resumes after the last
+// processed event when it is restarted
+type Checkpointer interface {
+	// Load returns the saved checkpoint, or nil when none has been saved
+	Load() (*Checkpoint, error)
+	// Save records that every event up to and including checkpoint has been processed
+	Save(checkpoint Checkpoint) error
+}
+
+// WithCheckpointer resumes the listener from checkpointer's saved position, overriding FromBlock
+// and AfterTransaction, and saves the position after each transaction's logs are handled
+func WithCheckpointer(checkpointer Checkpointer) ListenOption {
+	return func(l *EventListener) {
+		l.checkpointer = checkpointer
+	}
+}
+
+// FileCheckpointer keeps a checkpoint in a JSON file
+type FileCheckpointer struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileCheckpointer returns a Checkpointer for the file at path, which is created on the first Save
+func NewFileCheckpointer(path string) *FileCheckpointer {
+	return &FileCheckpointer{path: path}
+}
+
+// Load reads the checkpoint file
+func (f *FileCheckpointer) Load() (*Checkpoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	checkpointJSON, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %v", err)
+	}
+
+	return parseCheckpoint(checkpointJSON)
+}
+
+// Save replaces the checkpoint file atomically
+func (f *FileCheckpointer) Save(checkpoint Checkpoint) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	checkpointJSON, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(f.path+".tmp", checkpointJSON, 0600); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %v", err)
+	}
+
+	return os.Rename(f.path+".tmp", f.path)
+}
+
+func parseCheckpoint(checkpointJSON []byte) (*Checkpoint, error) {
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(checkpointJSON, &checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint: %v", err)
+	}
+
+	return &checkpoint, nil
+}
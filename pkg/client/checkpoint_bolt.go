@@ -0,0 +1,67 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// checkpointBucket is the bolt bucket holding checkpoints, keyed by consumer name
+var checkpointBucket = []byte("checkpoints")
+
+// BoltCheckpointer keeps checkpoints in a bolt database, so several consumers can share one file
+type BoltCheckpointer struct {
+	db  *bolt.DB
+	key []byte
+}
+
+// NewBoltCheckpointer returns a Checkpointer storing the checkpoint of the named consumer in db.
+// The caller owns db and closes it.
+func NewBoltCheckpointer(db *bolt.DB, consumer string) (*BoltCheckpointer, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(checkpointBucket)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint bucket: %v", err)
+	}
+
+	return &BoltCheckpointer{db: db, key: []byte(consumer)}, nil
+}
+
+// Load reads the consumer's checkpoint
+func (b *BoltCheckpointer) Load() (*Checkpoint, error) {
+	var checkpointJSON []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		if value := tx.Bucket(checkpointBucket).Get(b.key); value != nil {
+			checkpointJSON = append([]byte(nil), value...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %v", err)
+	}
+	if checkpointJSON == nil {
+		return nil, nil
+	}
+
+	return parseCheckpoint(checkpointJSON)
+}
+
+// Save writes the consumer's checkpoint
+func (b *BoltCheckpointer) Save(checkpoint Checkpoint) error {
+	checkpointJSON, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(checkpointBucket).Put(b.key, checkpointJSON)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint: %v", err)
+	}
+
+	return nil
+}
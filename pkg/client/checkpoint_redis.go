@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisCheckpointTimeout bounds each Redis call made by a RedisCheckpointer
+const redisCheckpointTimeout = 5 * time.Second
+
+// RedisCheckpointer keeps a checkpoint under a Redis key, so consumers running on several
+// hosts can hand over processing without losing their position
+type RedisCheckpointer struct {
+	client redis.UniversalClient
+	key    string
+}
+
+// NewRedisCheckpointer returns a Checkpointer storing the checkpoint under key. The caller owns client and closes it.
+func NewRedisCheckpointer(client redis.UniversalClient, key string) *RedisCheckpointer {
+	return &RedisCheckpointer{client: client, key: key}
+}
+
+// Load reads the checkpoint key
+func (r *RedisCheckpointer) Load() (*Checkpoint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisCheckpointTimeout)
+	defer cancel()
+
+	checkpointJSON, err := r.client.Get(ctx, r.key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %v", err)
+	}
+
+	return parseCheckpoint(checkpointJSON)
+}
+
+// Save writes the checkpoint key
+func (r *RedisCheckpointer) Save(checkpoint Checkpoint) error {
+	checkpointJSON, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisCheckpointTimeout)
+	defer cancel()
+
+	if err := r.client.Set(ctx, r.key, checkpointJSON, 0).Err(); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %v", err)
+	}
+
+	return nil
+}
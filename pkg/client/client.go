@@ -0,0 +1,558 @@
+// Package client provides a typed Go client for the logging chaincode, talking to
+// a Fabric Gateway peer over gRPC.
+//
+// The client implements the Gateway service's protocol itself, on the messages of
+// fabric-protos-go, rather than wrapping the fabric-gateway SDK, which the module does not
+// depend on. It therefore owns what the SDK would otherwise provide: building proposals and
+// deriving transaction IDs from the nonce and creator (transaction.go), signing proposals,
+// transaction envelopes and commit status requests, the Evaluate, Endorse, Submit,
+// CommitStatus and ChaincodeEvents calls, and reading the error details gateway peers attach
+// to gRPC statuses (errors.go). Changes to the Gateway protocol must be followed here.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go/gateway"
+	"google.golang.org/grpc"
+)
+
+// Default channel and chaincode names, matching the backend defaults
+const (
+	DefaultChannelName   = "logchannel"
+	DefaultChaincodeName = "logging-chaincode"
+)
+
+// LoggingClient is the typed interface to the logging chaincode
+type LoggingClient interface {
+	CreateLog(ctx context.Context, log LogEvent) error
+	CreateLogsBatch(ctx context.Context, logs []LogEvent) error
+	ReadLog(ctx context.Context, id string) (*LogEvent, error)
+	LogExists(ctx context.Context, id string) (bool, error)
+	GetAllLogs(ctx context.Context) ([]*LogEvent, error)
+	QueryLogs(ctx context.Context, filter LogFilter) ([]*LogEvent, error)
+	Close() error
+}
+
+// Config describes how to reach the Fabric Gateway peers and which identity to use
+type Config struct {
+	// PeerEndpoint is the host:port of the gateway peer
+	PeerEndpoint string
+	// ServerNameOverride overrides the TLS server name, e.g. peer0.org1.example.com
+	ServerNameOverride string
+	// TLSCACertPath is the peer TLS CA certificate; TLS is disabled when empty
+	TLSCACertPath string
+	// Peers lists further gateway peers; calls are load balanced across all peers and fail over when one is unreachable
+	Peers []PeerConfig
+	// TLSClientCert is presented to peers that require mutual TLS
+	TLSClientCert *TLSClientCertificate
+	// GRPC tunes the connections to every peer; a peer's own GRPC options take precedence
+	GRPC GRPCOptions
+
+	MSPID    string
+	CertPath string
+	// KeyPath is the private key file, or a keystore directory holding a single key
+	KeyPath string
+
+	// WalletPath and IdentityLabel select the identity from a wallet instead of CertPath and KeyPath
+	WalletPath    string
+	IdentityLabel string
+
+	// HSM signs with a key held in a PKCS#11 token instead of KeyPath; CertPath still names the certificate
+	HSM *HSMOptions
+
+	ChannelName   string
+	ChaincodeName string
+}
+
+// PeerConfig describes a single gateway peer
+type PeerConfig struct {
+	Endpoint           string
+	ServerNameOverride string
+	// TLSCACert is the PEM encoded peer TLS CA certificate, used in preference to TLSCACertPath
+	TLSCACert     []byte
+	TLSCACertPath string
+	// GRPC overrides the options set in Config for this peer
+	GRPC *GRPCOptions
+}
+
+// Client is a LoggingClient backed by Fabric Gateway connections
+type Client struct {
+	conns     []*grpc.ClientConn
+	closeSign func() error
+	contract  *contract
+	finality  Finality
+
+	collectionSelector CollectionSelector
+	validators         []Validator
+	processors         []Processor
+	receipts           ReceiptStore
+	deadLetters        *DeadLetterQueue
+	cache              *LogCache
+	verifier           *Verifier
+	limiter            *RateLimiter
+	idGenerator        IDGenerator
+}
+
+// Connect dials the gateway peers described by cfg and loads its signing identity
+func Connect(cfg Config, opts ...Option) (*Client, error) {
+	id, sign, closeSign, err := loadCredentials(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	peers := cfg.Peers
+	if cfg.PeerEndpoint != "" {
+		peers = append([]PeerConfig{{
+			Endpoint:           cfg.PeerEndpoint,
+			ServerNameOverride: cfg.ServerNameOverride,
+			TLSCACertPath:      cfg.TLSCACertPath,
+		}}, peers...)
+	}
+	if len(peers) == 0 {
+		if closeSign != nil {
+			closeSign()
+		}
+		return nil, fmt.Errorf("no gateway peer endpoint configured")
+	}
+
+	var conns []*grpc.ClientConn
+	var clients []gateway.GatewayClient
+	for _, peer := range peers {
+		conn, err := dialPeer(peer, cfg.TLSClientCert, cfg.GRPC)
+		if err != nil {
+			closeAll(conns)
+			if closeSign != nil {
+				closeSign()
+			}
+			return nil, err
+		}
+		conns = append(conns, conn)
+		clients = append(clients, gateway.NewGatewayClient(conn))
+	}
+
+	var gw gateway.GatewayClient = clients[0]
+	if len(clients) > 1 {
+		gw = newGatewayPool(clients)
+	}
+
+	c := newClient(gw, id, sign, cfg.ChannelName, cfg.ChaincodeName, opts)
+	c.conns = conns
+	c.closeSign = closeSign
+
+	return c, nil
+}
+
+// New returns a Client using an existing gRPC connection. The connection is not
+// closed by Close; use Connect for a client that owns its connection.
+func New(conn *grpc.ClientConn, id *Identity, sign Sign, channelName string, chaincodeName string, opts ...Option) *Client {
+	return newClient(gateway.NewGatewayClient(conn), id, sign, channelName, chaincodeName, opts)
+}
+
+func newClient(gw gateway.GatewayClient, id *Identity, sign Sign, channelName string, chaincodeName string, opts []Option) *Client {
+	if channelName == "" {
+		channelName = DefaultChannelName
+	}
+	if chaincodeName == "" {
+		chaincodeName = DefaultChaincodeName
+	}
+
+	c := &Client{
+		contract: &contract{
+			gateway:       gw,
+			id:            id,
+			sign:          sign,
+			channelName:   channelName,
+			chaincodeName: chaincodeName,
+			retry:         DefaultRetryPolicy,
+			tracer:        defaultTracer(),
+		},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Close releases the gRPC connections and signing resources the client owns
+func (c *Client) Close() error {
+	err := closeAll(c.conns)
+	if c.closeSign != nil {
+		if signErr := c.closeSign(); err == nil {
+			err = signErr
+		}
+	}
+
+	return err
+}
+
+// Signer returns the identity the client transacts as and the Sign it signs with, so other
+// artifacts, such as reports, can be signed by the same identity
+func (c *Client) Signer() (*Identity, Sign) {
+	return c.contract.id, c.contract.sign
+}
+
+// CreateLog submits a new log to the ledger and waits for the client's default finality.
+// The log ID is used as the idempotency key, so retries never record the log twice.
+func (c *Client) CreateLog(ctx context.Context, log LogEvent) error {
+	_, err := c.SubmitLog(ctx, log)
+	if errors.Is(err, ErrSampledOut) {
+		return nil
+	}
+	return err
+}
+
+// SubmitLog is CreateLog with per-call options, returning the transaction ID and, once
+// committed, the block number of the submitted log
+func (c *Client) SubmitLog(ctx context.Context, log LogEvent, opts ...SubmitOption) (*SubmitResult, error) {
+	options := c.submitOptions("", opts)
+	prepared, err := c.prepare(ctx, log)
+	if err != nil {
+		c.deadLetter(err, log)
+		return nil, err
+	}
+	log = prepared[0]
+	if err := c.limiter.wait(ctx, 1); err != nil {
+		return nil, err
+	}
+
+	options.idempotencyKey = log.ID
+	result, err := c.contract.submitWithOptions(ctx, options, "CreateLog", log.ID, log.UserID, log.Action, log.Resource, log.Description, log.Metadata)
+	if err != nil {
+		c.deadLetter(err, log)
+		return nil, err
+	}
+
+	return result, errors.Join(c.recordReceipts(result, log), c.verifier.Record(log))
+}
+
+// CreateLogsBatch submits several new logs in a single transaction and waits for the client's default finality.
+// The batch's log IDs form its idempotency key.
+func (c *Client) CreateLogsBatch(ctx context.Context, logs []LogEvent) error {
+	_, err := c.SubmitLogsBatch(ctx, logs)
+	if errors.Is(err, ErrSampledOut) {
+		return nil
+	}
+	return err
+}
+
+// SubmitLogsBatch is CreateLogsBatch with per-call options. Logs dropped by sampling are left out of the batch.
+func (c *Client) SubmitLogsBatch(ctx context.Context, logs []LogEvent, opts ...SubmitOption) (*SubmitResult, error) {
+	options := c.submitOptions("", opts)
+	submitted := logs
+	logs, err := c.prepare(ctx, logs...)
+	if err != nil {
+		c.deadLetter(err, submitted...)
+		return nil, err
+	}
+
+	return c.submitPreparedBatch(ctx, options, logs)
+}
+
+// submitPreparedBatch submits logs that have already been through prepare as one CreateLogsBatch transaction
+func (c *Client) submitPreparedBatch(ctx context.Context, options submitOptions, logs []LogEvent) (*SubmitResult, error) {
+	if err := c.limiter.wait(ctx, len(logs)); err != nil {
+		return nil, err
+	}
+
+	logsJSON, err := json.Marshal(logs)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(logs))
+	for i, log := range logs {
+		ids[i] = log.ID
+	}
+
+	options.idempotencyKey = strings.Join(ids, "\x00")
+	result, err := c.contract.submitWithOptions(ctx, options, "CreateLogsBatch", string(logsJSON))
+	if err != nil {
+		c.deadLetter(err, logs...)
+		return nil, err
+	}
+
+	return result, errors.Join(c.recordReceipts(result, logs...), c.verifier.Record(logs...))
+}
+
+// RestoreLogsBatch writes logs recovered from a backup in a single transaction, keeping their IDs
+// and timestamps. The logs bypass the processing pipeline so they are stored exactly as backed up;
+// the chaincode only accepts restores from identities holding the logging.restore attribute.
+func (c *Client) RestoreLogsBatch(ctx context.Context, logs []LogEvent) (*SubmitResult, error) {
+	if err := c.limiter.wait(ctx, len(logs)); err != nil {
+		return nil, err
+	}
+
+	logsJSON, err := json.Marshal(logs)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(logs))
+	for i, log := range logs {
+		ids[i] = log.ID
+	}
+
+	result, err := c.contract.submitWithOptions(ctx, c.submitOptions(strings.Join(ids, "\x00"), nil), "RestoreLogsBatch", string(logsJSON))
+	if err != nil {
+		return nil, err
+	}
+
+	return result, c.recordReceipts(result, logs...)
+}
+
+// PruneLogs deletes the logs with the given IDs from the world state in a single transaction, for
+// retention. The chaincode only accepts prunes from identities holding the logging.retention
+// attribute, skips logs already deleted, and deletes nothing if any log was recorded at or after
+// before. The blocks that recorded the logs keep them.
+func (c *Client) PruneLogs(ctx context.Context, before time.Time, ids []string) (*SubmitResult, error) {
+	idsJSON, err := json.Marshal(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := before.UTC().Format(time.RFC3339)
+	result, err := c.contract.submitWithOptions(ctx, c.submitOptions(strings.Join(ids, "\x00"), nil), "PruneLogs", cutoff, string(idsJSON))
+	if err != nil {
+		return nil, err
+	}
+
+	c.cache.Invalidate(ids...)
+	return result, nil
+}
+
+// MigrateLegacyLogs moves up to limit logs stored under their bare ID by versions of the
+// chaincode before the LOG namespace to their namespaced key, where reads and queries find them,
+// and returns how many it moved; call it until it returns 0. The chaincode only accepts
+// migrations from identities holding the logging.restore attribute.
+func (c *Client) MigrateLegacyLogs(ctx context.Context, limit int) (int, *SubmitResult, error) {
+	result, err := c.contract.submitWithOptions(ctx, c.submitOptions("", nil), "MigrateLegacyLogs", strconv.Itoa(limit))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	migrated, err := strconv.Atoi(string(result.payload))
+	if err != nil {
+		return 0, result, fmt.Errorf("failed to parse the number of migrated logs: %v", err)
+	}
+	return migrated, result, nil
+}
+
+// ReadLog returns the log with given id
+func (c *Client) ReadLog(ctx context.Context, id string) (*LogEvent, error) {
+	if c.cache != nil {
+		return c.cache.cachedReadLog(ctx, id, c.readLog)
+	}
+	return c.readLog(ctx, id)
+}
+
+func (c *Client) readLog(ctx context.Context, id string) (*LogEvent, error) {
+	result, err := c.contract.evaluate(ctx, "ReadLog", id)
+	if err != nil {
+		return nil, err
+	}
+
+	var log LogEvent
+	if err := json.Unmarshal(result, &log); err != nil {
+		return nil, fmt.Errorf("failed to parse log %s: %v", id, err)
+	}
+	if err := decodeLog(&log); err != nil {
+		return nil, err
+	}
+
+	return &log, nil
+}
+
+// LogExists returns true when a log with given id exists on the ledger
+func (c *Client) LogExists(ctx context.Context, id string) (bool, error) {
+	if c.cache != nil {
+		return c.cache.cachedLogExists(ctx, id, c.logExists)
+	}
+	return c.logExists(ctx, id)
+}
+
+func (c *Client) logExists(ctx context.Context, id string) (bool, error) {
+	result, err := c.contract.evaluate(ctx, "LogExists", id)
+	if err != nil {
+		return false, err
+	}
+
+	var exists bool
+	if err := json.Unmarshal(result, &exists); err != nil {
+		return false, fmt.Errorf("failed to parse LogExists result: %v", err)
+	}
+
+	return exists, nil
+}
+
+// GetAllLogs returns every log on the ledger
+func (c *Client) GetAllLogs(ctx context.Context) ([]*LogEvent, error) {
+	result, err := c.contract.evaluate(ctx, "GetAllLogs")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseLogs(result)
+}
+
+// QueryLogs returns the logs matching filter
+func (c *Client) QueryLogs(ctx context.Context, filter LogFilter) ([]*LogEvent, error) {
+	filterJSON, err := json.Marshal(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.contract.evaluate(ctx, "QueryLogs", string(filterJSON))
+	if err != nil {
+		return nil, err
+	}
+
+	return parseLogs(result)
+}
+
+// GetAllLogsPage returns one page of at most pageSize logs, starting at bookmark
+func (c *Client) GetAllLogsPage(ctx context.Context, pageSize int32, bookmark string) (*LogPage, error) {
+	result, err := c.contract.evaluate(ctx, "GetAllLogsWithPagination", strconv.FormatInt(int64(pageSize), 10), bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseLogPage(result)
+}
+
+// QueryLogsPage returns one page of at most pageSize logs matching filter, starting at bookmark
+func (c *Client) QueryLogsPage(ctx context.Context, filter LogFilter, pageSize int32, bookmark string) (*LogPage, error) {
+	filterJSON, err := json.Marshal(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.contract.evaluate(ctx, "QueryLogsWithPagination", string(filterJSON), strconv.FormatInt(int64(pageSize), 10), bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseLogPage(result)
+}
+
+// IterateAllLogs returns an iterator over every log, fetched pageSize logs at a time
+func (c *Client) IterateAllLogs(pageSize int32) *LogIterator {
+	return newLogIterator(pageSize, func(ctx context.Context, bookmark string) (*LogPage, error) {
+		return c.GetAllLogsPage(ctx, pageSize, bookmark)
+	})
+}
+
+// IterateLogs returns an iterator over the logs matching filter, fetched pageSize logs at a time
+func (c *Client) IterateLogs(filter LogFilter, pageSize int32) *LogIterator {
+	return newLogIterator(pageSize, func(ctx context.Context, bookmark string) (*LogPage, error) {
+		return c.QueryLogsPage(ctx, filter, pageSize, bookmark)
+	})
+}
+
+// parseLogs decodes a chaincode log list, which is empty rather than "[]" when nothing matched
+func parseLogs(result []byte) ([]*LogEvent, error) {
+	var logs []*LogEvent
+	if len(result) == 0 {
+		return logs, nil
+	}
+
+	if err := json.Unmarshal(result, &logs); err != nil {
+		return nil, fmt.Errorf("failed to parse logs: %v", err)
+	}
+	for _, log := range logs {
+		if err := decodeLog(log); err != nil {
+			return nil, err
+		}
+	}
+
+	return logs, nil
+}
+
+// submitOptions applies per-call options over the client defaults
+func (c *Client) submitOptions(idempotencyKey string, opts []SubmitOption) submitOptions {
+	options := submitOptions{
+		idempotencyKey: idempotencyKey,
+		finality:       c.finality,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return options
+}
+
+func parseLogPage(result []byte) (*LogPage, error) {
+	var page LogPage
+	if err := json.Unmarshal(result, &page); err != nil {
+		return nil, fmt.Errorf("failed to parse log page: %v", err)
+	}
+	for _, log := range page.Records {
+		if err := decodeLog(log); err != nil {
+			return nil, err
+		}
+	}
+
+	return &page, nil
+}
+
+// loadCredentials loads the signing identity from the wallet, if configured, or from CertPath
+// and either the HSM or KeyPath. The returned close function, if any, releases the signer.
+func loadCredentials(cfg Config) (*Identity, Sign, func() error, error) {
+	if cfg.WalletPath != "" {
+		wallet, err := NewFileSystemWallet(cfg.WalletPath)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		x509Identity, err := wallet.Get(cfg.IdentityLabel)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		sign, err := x509Identity.Sign()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		return x509Identity.Identity(), sign, nil, nil
+	}
+
+	id, err := LoadIdentity(cfg.MSPID, cfg.CertPath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if cfg.HSM != nil {
+		sign, closeSign, err := NewHSMSign(*cfg.HSM, id)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		return id, sign, closeSign, nil
+	}
+
+	sign, err := LoadSign(cfg.KeyPath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return id, sign, nil, nil
+}
+
+func closeAll(conns []*grpc.ClientConn) error {
+	var firstErr error
+	for _, conn := range conns {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
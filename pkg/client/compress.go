@@ -0,0 +1,177 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compressed metadata is stored as {"$encoding": "<encoding>", "$data": "<base64 payload>"}.
+// The chaincode accepts this envelope in place of a metadata object.
+const (
+	metadataEncodingField = "$encoding"
+	metadataDataField     = "$data"
+)
+
+// Encoding is a compression scheme for log metadata
+type Encoding string
+
+// Supported metadata encodings
+const (
+	EncodingGzip Encoding = "gzip"
+	EncodingZstd Encoding = "zstd"
+)
+
+// DefaultCompressionThreshold is the metadata size, in bytes, above which a Compressor compresses
+const DefaultCompressionThreshold = 1024
+
+// Compressor compresses large log metadata before submission, reducing transaction and state size
+type Compressor struct {
+	encoding  Encoding
+	threshold int
+}
+
+// NewCompressor returns a Compressor using encoding for metadata larger than threshold bytes,
+// or DefaultCompressionThreshold when threshold is not positive
+func NewCompressor(encoding Encoding, threshold int) (*Compressor, error) {
+	if encoding != EncodingGzip && encoding != EncodingZstd {
+		return nil, fmt.Errorf("unsupported metadata encoding %s", encoding)
+	}
+	if threshold <= 0 {
+		threshold = DefaultCompressionThreshold
+	}
+
+	return &Compressor{encoding: encoding, threshold: threshold}, nil
+}
+
+// Process is a Processor, so a Compressor is installed with WithProcessor(compressor.Process).
+// It should be the last processor, so the others see uncompressed metadata. Metadata is left
+// uncompressed when compressing would not make it smaller.
+func (c *Compressor) Process(log LogEvent) (LogEvent, error) {
+	if len(log.Metadata) <= c.threshold {
+		return log, nil
+	}
+
+	compressed, err := compress(c.encoding, []byte(log.Metadata))
+	if err != nil {
+		return log, fmt.Errorf("failed to compress metadata of log %s: %v", log.ID, err)
+	}
+
+	envelope, err := json.Marshal(map[string]string{
+		metadataEncodingField: string(c.encoding),
+		metadataDataField:     base64.StdEncoding.EncodeToString(compressed),
+	})
+	if err != nil {
+		return log, err
+	}
+	if len(envelope) < len(log.Metadata) {
+		log.Metadata = string(envelope)
+	}
+
+	return log, nil
+}
+
+// DecodeMetadata returns metadata as submitted, decompressing it when it is a compressed envelope.
+// The client applies it to every log it reads.
+func DecodeMetadata(metadata string) (string, error) {
+	encoding, data, ok := metadataEnvelope(metadata)
+	if !ok {
+		return metadata, nil
+	}
+
+	compressed, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode %s payload: %v", metadataDataField, err)
+	}
+
+	decompressed, err := decompress(encoding, compressed)
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress %s metadata: %v", encoding, err)
+	}
+
+	return string(decompressed), nil
+}
+
+// decodeLog replaces compressed metadata of a log read from the ledger with the original
+func decodeLog(log *LogEvent) error {
+	metadata, err := DecodeMetadata(log.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to read metadata of log %s: %v", log.ID, err)
+	}
+	log.Metadata = metadata
+
+	return nil
+}
+
+// metadataEnvelope returns the encoding and payload of compressed metadata
+func metadataEnvelope(metadata string) (Encoding, string, bool) {
+	if metadata == "" {
+		return "", "", false
+	}
+
+	var envelope map[string]interface{}
+	if err := json.Unmarshal([]byte(metadata), &envelope); err != nil {
+		return "", "", false
+	}
+
+	encoding, ok := envelope[metadataEncodingField].(string)
+	if !ok {
+		return "", "", false
+	}
+	data, _ := envelope[metadataDataField].(string)
+
+	return Encoding(encoding), data, true
+}
+
+func compress(encoding Encoding, data []byte) ([]byte, error) {
+	switch encoding {
+	case EncodingGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case EncodingZstd:
+		w, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer w.Close()
+		return w.EncodeAll(data, nil), nil
+	default:
+		return nil, fmt.Errorf("unsupported metadata encoding %s", encoding)
+	}
+}
+
+func decompress(encoding Encoding, data []byte) ([]byte, error) {
+	switch encoding {
+	case EncodingGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(io.LimitReader(r, maxDecompressedMetadata))
+	case EncodingZstd:
+		r, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxDecompressedMetadata))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return r.DecodeAll(data, nil)
+	default:
+		return nil, fmt.Errorf("unsupported metadata encoding %s", encoding)
+	}
+}
+
+// maxDecompressedMetadata bounds the memory used to decompress metadata read from the ledger
+const maxDecompressedMetadata = 16 << 20
@@ -0,0 +1,267 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// deadLetterFileExtension is the file extension of dead-letter entries
+const deadLetterFileExtension = ".json"
+
+// ErrDeadLetterNotFound is returned when the dead-letter queue has no entry for a log
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// DeadLetter is a log whose submission failed permanently, with the reason it failed
+type DeadLetter struct {
+	Log      LogEvent  `json:"log"`
+	Reason   string    `json:"reason"`
+	Attempts int       `json:"attempts"`
+	FailedAt time.Time `json:"failedAt"`
+}
+
+// DeadLetterQueue is a directory of logs that could not be submitted, one file per log ID.
+// Entries stay until they are resubmitted successfully or removed, so they can be inspected
+// and fixed first.
+type DeadLetterQueue struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewDeadLetterQueue opens the dead-letter queue in dir, creating the directory if needed
+func NewDeadLetterQueue(dir string) (*DeadLetterQueue, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create dead-letter directory: %v", err)
+	}
+
+	return &DeadLetterQueue{dir: dir}, nil
+}
+
+// Put records that log failed with err. A log already in the queue has its reason replaced
+// and its attempt count incremented.
+func (q *DeadLetterQueue) Put(log LogEvent, err error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	letter := DeadLetter{Log: log, Reason: err.Error(), FailedAt: time.Now().UTC()}
+	if existing, err := q.get(log.ID); err == nil {
+		letter.Attempts = existing.Attempts
+	}
+	letter.Attempts++
+
+	return q.write(letter)
+}
+
+// OnError records each of logs with err. It matches BatchOptions.OnError, so a queue collects
+// the failures of a BatchingSubmitter with OnError: queue.OnError.
+func (q *DeadLetterQueue) OnError(logs []LogEvent, err error) {
+	for _, log := range logs {
+		q.Put(log, err)
+	}
+}
+
+// Get returns the entry for the log with given id
+func (q *DeadLetterQueue) Get(logID string) (*DeadLetter, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.get(logID)
+}
+
+// List returns every entry, oldest failure first
+func (q *DeadLetterQueue) List() ([]*DeadLetter, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var letters []*DeadLetter
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != deadLetterFileExtension {
+			continue
+		}
+		letter, err := q.read(filepath.Join(q.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, letter)
+	}
+	sort.Slice(letters, func(i, j int) bool { return letters[i].FailedAt.Before(letters[j].FailedAt) })
+
+	return letters, nil
+}
+
+// Len returns the number of entries in the queue, or 0 when the directory cannot be read
+func (q *DeadLetterQueue) Len() int {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return 0
+	}
+
+	n := 0
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == deadLetterFileExtension {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Update replaces the log held for log.ID, so a log rejected for its content can be fixed before it is resubmitted
+func (q *DeadLetterQueue) Update(log LogEvent) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	letter, err := q.get(log.ID)
+	if err != nil {
+		return err
+	}
+	letter.Log = log
+
+	return q.write(*letter)
+}
+
+// Remove deletes the entry for the log with given id
+func (q *DeadLetterQueue) Remove(logID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	err := os.Remove(q.path(logID))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrDeadLetterNotFound, logID)
+	}
+
+	return err
+}
+
+// logSubmitter is implemented by clients taking per-submission options, such as Client
+type logSubmitter interface {
+	SubmitLog(ctx context.Context, log LogEvent, opts ...SubmitOption) (*SubmitResult, error)
+}
+
+// Resubmit submits the log with given id through client, removing it from the queue on success
+// and recording the new failure otherwise. Clients taking submit options submit it as a
+// Resubmission whose generation is the entry's attempt count, so a log whose transaction was
+// invalidated, as by an endorsement policy failure, is not refused as a duplicate.
+func (q *DeadLetterQueue) Resubmit(ctx context.Context, client LoggingClient, logID string) error {
+	letter, err := q.Get(logID)
+	if err != nil {
+		return err
+	}
+
+	if s, ok := client.(logSubmitter); ok {
+		_, err = s.SubmitLog(ctx, letter.Log, Resubmission(letter.Attempts))
+		if errors.Is(err, ErrSampledOut) {
+			err = nil
+		}
+	} else {
+		err = client.CreateLog(ctx, letter.Log)
+	}
+	if err != nil {
+		// A Client dead-lettering into this queue has already recorded the failure
+		if c, ok := client.(*Client); ok && c.deadLetters == q && shouldDeadLetter(err) {
+			return fmt.Errorf("failed to resubmit log %s: %w", logID, err)
+		}
+		if putErr := q.Put(letter.Log, err); putErr != nil {
+			return fmt.Errorf("failed to resubmit log %s: %w (and failed to record it: %v)", logID, err, putErr)
+		}
+		return fmt.Errorf("failed to resubmit log %s: %w", logID, err)
+	}
+
+	return q.Remove(logID)
+}
+
+// ResubmitAll resubmits every entry and returns how many succeeded, stopping at the first failure
+func (q *DeadLetterQueue) ResubmitAll(ctx context.Context, client LoggingClient) (int, error) {
+	letters, err := q.List()
+	if err != nil {
+		return 0, err
+	}
+
+	for i, letter := range letters {
+		if err := q.Resubmit(ctx, client, letter.Log.ID); err != nil {
+			return i, err
+		}
+	}
+
+	return len(letters), nil
+}
+
+func (q *DeadLetterQueue) get(logID string) (*DeadLetter, error) {
+	letter, err := q.read(q.path(logID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrDeadLetterNotFound, logID)
+	}
+
+	return letter, err
+}
+
+func (q *DeadLetterQueue) read(path string) (*DeadLetter, error) {
+	letterJSON, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var letter DeadLetter
+	if err := json.Unmarshal(letterJSON, &letter); err != nil {
+		return nil, fmt.Errorf("failed to parse dead letter %s: %v", path, err)
+	}
+
+	return &letter, nil
+}
+
+// write stores letter atomically, so a crash never leaves a truncated entry
+func (q *DeadLetterQueue) write(letter DeadLetter) error {
+	letterJSON, err := json.MarshalIndent(letter, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := q.path(letter.Log.ID)
+	if err := os.WriteFile(path+".tmp", letterJSON, 0600); err != nil {
+		return fmt.Errorf("failed to write dead letter: %v", err)
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+// path names entries by the encoded log ID, since log IDs may contain path separators
+func (q *DeadLetterQueue) path(logID string) string {
+	name := base64.RawURLEncoding.EncodeToString([]byte(logID))
+	return filepath.Join(q.dir, name+deadLetterFileExtension)
+}
+
+// deadLetter records logs that failed permanently in the client's dead-letter queue
+func (c *Client) deadLetter(err error, logs ...LogEvent) {
+	if c.deadLetters == nil || !shouldDeadLetter(err) {
+		return
+	}
+
+	c.deadLetters.OnError(logs, err)
+}
+
+// shouldDeadLetter reports whether a client dead-letters a submission failing with err.
+// Failures reaching no peer or orderer, or cut short by the caller's context, are left to the
+// caller, since they are expected to succeed later, and logs rejected for containing PII are not
+// written to disk.
+func shouldDeadLetter(err error) bool {
+	switch {
+	case errors.Is(err, ErrSampledOut), errors.Is(err, ErrPIIDetected):
+		return false
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	default:
+		return !isUnreachable(err)
+	}
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go/gateway"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestResubmitRecordsFailureOnce(t *testing.T) {
+	rejected, err := status.New(codes.Aborted, "failed to endorse transaction, see attached details for more info").WithDetails(&gateway.ErrorDetail{
+		MspId:   "Org1MSP",
+		Message: "chaincode response 500, [INVALID_LOG] the log l1 has an invalid action",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := NewDeadLetterQueue(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := &endorser{err: rejected.Err()}
+	sign := func([]byte) ([]byte, error) { return []byte("signature"), nil }
+	c := newClient(gw, &Identity{MSPID: "Org1MSP"}, sign, "", "", []Option{WithDeadLetterQueue(q)})
+
+	log := LogEvent{ID: "l1", UserID: "u1", Action: "LOGIN", Resource: "app"}
+	if err := q.Put(log, errors.New("first failure")); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Resubmit(context.Background(), c, "l1"); !errors.Is(err, ErrInvalidLog) {
+		t.Fatalf("Resubmit = %v, want %v", err, ErrInvalidLog)
+	}
+
+	letter, err := q.Get("l1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The client dead-letters the failure itself; the queue must not count it again
+	if letter.Attempts != 2 {
+		t.Errorf("%d attempts recorded, want 2", letter.Attempts)
+	}
+}
+
+func TestDeadLetterSkipsCanceledSubmissions(t *testing.T) {
+	q, err := NewDeadLetterQueue(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{deadLetters: q}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	for _, err := range []error{context.Canceled, ctx.Err(), errors.New("[INVALID_LOG] bad action")} {
+		c.deadLetter(err, LogEvent{ID: err.Error()})
+	}
+
+	if q.Len() != 1 {
+		t.Errorf("%d dead letters, want only the rejected log", q.Len())
+	}
+}
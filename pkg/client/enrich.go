@@ -0,0 +1,117 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// enrichmentKey is the metadata field holding the context stamped by an Enricher
+const enrichmentKey = "context"
+
+// kubernetesNamespaceFile is where the service account namespace is mounted inside a pod
+const kubernetesNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// EnrichOptions configures an Enricher
+type EnrichOptions struct {
+	// AppName and AppVersion identify the application producing the logs
+	AppName    string
+	AppVersion string
+	// Fields are stamped in addition to the detected host, process and pod context
+	Fields map[string]string
+	// DisableDetection stamps only AppName, AppVersion and Fields
+	DisableDetection bool
+}
+
+// Enricher stamps each log's metadata with the deployment context it was produced in,
+// so forensic context is recorded consistently across applications
+type Enricher struct {
+	fields map[string]string
+}
+
+// NewEnricher returns an Enricher for the current process. Host, process and pod context is
+// detected once, when the Enricher is created.
+func NewEnricher(options EnrichOptions) *Enricher {
+	fields := map[string]string{}
+	if !options.DisableDetection {
+		for k, v := range DetectContext() {
+			fields[k] = v
+		}
+	}
+	if options.AppName != "" {
+		fields["app"] = options.AppName
+	}
+	if options.AppVersion != "" {
+		fields["appVersion"] = options.AppVersion
+	}
+	for k, v := range options.Fields {
+		fields[k] = v
+	}
+
+	return &Enricher{fields: fields}
+}
+
+// Process is a Processor, so an Enricher is installed with WithProcessor(enricher.Process).
+// The context is added under the metadata field "context"; a context already present is kept.
+func (e *Enricher) Process(log LogEvent) (LogEvent, error) {
+	metadata := map[string]interface{}{}
+	if log.Metadata != "" {
+		if err := json.Unmarshal([]byte(log.Metadata), &metadata); err != nil {
+			return log, fmt.Errorf("%w: the metadata of log %s must be a JSON object", ErrInvalidLog, log.ID)
+		}
+	}
+	if _, ok := metadata[enrichmentKey]; ok {
+		return log, nil
+	}
+
+	metadata[enrichmentKey] = e.fields
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return log, err
+	}
+	log.Metadata = string(metadataJSON)
+
+	return log, nil
+}
+
+// DetectContext returns the host, process and, when running in Kubernetes, pod context of the
+// current process. Pod, namespace, node and container names are read from the POD_NAME,
+// POD_NAMESPACE, NODE_NAME and CONTAINER_NAME variables, which the downward API is expected to set.
+func DetectContext() map[string]string {
+	fields := map[string]string{
+		"pid":     strconv.Itoa(os.Getpid()),
+		"process": filepath.Base(os.Args[0]),
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		fields["hostname"] = hostname
+	}
+
+	env := map[string]string{
+		"POD_NAME":       "pod",
+		"POD_NAMESPACE":  "namespace",
+		"NODE_NAME":      "node",
+		"CONTAINER_NAME": "container",
+	}
+	for name, field := range env {
+		if value := os.Getenv(name); value != "" {
+			fields[field] = value
+		}
+	}
+
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		// Inside a pod the hostname is the pod name unless it was overridden
+		if _, ok := fields["pod"]; !ok {
+			fields["pod"] = fields["hostname"]
+		}
+		if _, ok := fields["namespace"]; !ok {
+			if namespace, err := os.ReadFile(kubernetesNamespaceFile); err == nil {
+				fields["namespace"] = strings.TrimSpace(string(namespace))
+			}
+		}
+	}
+
+	return fields
+}
@@ -0,0 +1,174 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-protos-go/gateway"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Errors reported by the chaincode or the network, matched with errors.Is. ErrInvalidLog is also
+// returned for logs rejected by the chaincode's validation.
+var (
+	ErrLogNotFound   = errors.New("log not found")
+	ErrAlreadyExists = errors.New("log already exists")
+	ErrUnauthorized  = errors.New("not authorized")
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	ErrNoPolicy      = errors.New("no retention policy")
+)
+
+// chaincodeErrorCodes maps the "[CODE]" prefixes of chaincode errors to client errors
+var chaincodeErrorCodes = map[string]error{
+	"[LOG_NOT_FOUND]":      ErrLogNotFound,
+	"[LOG_ALREADY_EXISTS]": ErrAlreadyExists,
+	"[INVALID_LOG]":        ErrInvalidLog,
+	"[ACCESS_DENIED]":      ErrUnauthorized,
+	"[POLICY_NOT_FOUND]":   ErrNoPolicy,
+}
+
+// TransientError wraps a failure that may succeed if the call is retried later, such as an
+// unreachable peer or an exhausted quota. Match it with errors.As.
+type TransientError struct {
+	Err error
+}
+
+func (e *TransientError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransientError) Unwrap() error {
+	return e.Err
+}
+
+// GRPCStatus exposes the gRPC status of the failed call
+func (e *TransientError) GRPCStatus() *status.Status {
+	st, _ := status.FromError(e.Err)
+	return st
+}
+
+// CommitError reports a transaction that was ordered but failed validation when committed
+type CommitError struct {
+	TransactionID string
+	Code          peer.TxValidationCode
+}
+
+func (e *CommitError) Error() string {
+	return fmt.Sprintf("transaction %s failed to commit with status code %d (%s)", e.TransactionID, int32(e.Code), e.Code)
+}
+
+// Is reports ErrUnauthorized for transactions rejected by the endorsement policy or for their creator
+func (e *CommitError) Is(target error) bool {
+	if target != ErrUnauthorized {
+		return false
+	}
+
+	switch e.Code {
+	case peer.TxValidationCode_ENDORSEMENT_POLICY_FAILURE, peer.TxValidationCode_BAD_CREATOR_SIGNATURE, peer.TxValidationCode_CHAINCODE_VERSION_CONFLICT:
+		return true
+	default:
+		return false
+	}
+}
+
+func commitError(txID string, code peer.TxValidationCode) error {
+	return &CommitError{TransactionID: txID, Code: code}
+}
+
+// transactionError is a failed gateway call that keeps the underlying gRPC status reachable
+type transactionError struct {
+	message string
+	cause   error
+	// kind is the exported error the failure is classified as, if any
+	kind error
+}
+
+func (e *transactionError) Error() string {
+	return e.message
+}
+
+func (e *transactionError) Unwrap() error {
+	return e.cause
+}
+
+// Is matches the error the failure was classified as
+func (e *transactionError) Is(target error) bool {
+	return e.kind != nil && target == e.kind
+}
+
+// GRPCStatus exposes the gRPC status of the failed call
+func (e *transactionError) GRPCStatus() *status.Status {
+	st, _ := status.FromError(e.cause)
+	return st
+}
+
+// gatewayError wraps a gRPC error from the gateway, including any per-peer error details it carries,
+// and classifies it by the chaincode error code or gRPC status
+func gatewayError(stage string, fn string, err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return &transactionError{message: fmt.Sprintf("failed to %s transaction %s: %v", stage, fn, err), cause: err}
+	}
+
+	messages := []string{st.Message()}
+	var details []string
+	for _, detail := range st.Details() {
+		if d, ok := detail.(*gateway.ErrorDetail); ok {
+			details = append(details, fmt.Sprintf("%s (%s): %s", d.Address, d.MspId, d.Message))
+			messages = append(messages, d.Message)
+		}
+	}
+
+	message := fmt.Sprintf("failed to %s transaction %s: %s", stage, fn, st.Message())
+	if len(details) > 0 {
+		message = fmt.Sprintf("%s [%s]", message, strings.Join(details, "; "))
+	}
+
+	cause := err
+	if isRetryable(err) {
+		cause = &TransientError{Err: err}
+	}
+
+	return &transactionError{message: message, cause: cause, kind: classify(st.Code(), messages)}
+}
+
+// classify returns the exported error matching a chaincode error code in messages, or else the gRPC code
+func classify(code codes.Code, messages []string) error {
+	for _, message := range messages {
+		for prefix, kind := range chaincodeErrorCodes {
+			if strings.Contains(message, prefix) {
+				return kind
+			}
+		}
+		if strings.Contains(message, "access denied") || strings.Contains(message, "creator org unknown") {
+			return ErrUnauthorized
+		}
+	}
+
+	switch code {
+	case codes.PermissionDenied, codes.Unauthenticated:
+		return ErrUnauthorized
+	case codes.ResourceExhausted:
+		return ErrQuotaExceeded
+	default:
+		return nil
+	}
+}
+
+// isUnreachable reports whether err means the gateway, or the peers and orderers behind it, could not be reached
+func isUnreachable(err error) bool {
+	var se interface{ GRPCStatus() *status.Status }
+	if !errors.As(err, &se) {
+		return false
+	}
+
+	switch se.GRPCStatus().Code() {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,280 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/gateway"
+	"github.com/hyperledger/fabric-protos-go/orderer"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Chaincode events emitted by the logging chaincode when logs are written or pruned. A batch emits
+// a single LogsCreatedEvent carrying every log in the transaction, and PruneLogs a single
+// LogsPrunedEvent carrying the tombstones of the logs it deleted.
+const (
+	LogCreatedEvent  = "LogCreated"
+	LogsCreatedEvent = "LogsCreated"
+	LogsPrunedEvent  = "LogsPruned"
+)
+
+// ContractEvent is a log delivered by an EventListener, with the ledger position it was written at.
+// For a pruned log, EventName is LogsPrunedEvent and Log holds only the ID and Timestamp of the
+// deleted log.
+type ContractEvent struct {
+	BlockNumber   uint64
+	TransactionID string
+	EventName     string
+	Log           LogEvent
+}
+
+// Pruned reports whether the event is the deletion of a log by PruneLogs rather than its creation
+func (e ContractEvent) Pruned() bool {
+	return e.EventName == LogsPrunedEvent
+}
+
+// EventHandler processes one delivered log. Returning an error stops the listener.
+type EventHandler func(event ContractEvent) error
+
+// TransactionHandler processes the logs written by one transaction together. Returning an error stops the listener.
+type TransactionHandler func(events []ContractEvent) error
+
+// ListenOption configures an EventListener
+type ListenOption func(*EventListener)
+
+// FromBlock starts listening at the given block, replaying every log written since.
+// Without it, only logs committed after the listener starts are delivered.
+func FromBlock(blockNumber uint64) ListenOption {
+	return func(l *EventListener) {
+		l.startBlock = &blockNumber
+	}
+}
+
+// AfterTransaction skips events in the start block up to and including the given transaction,
+// so a consumer can resume exactly after the last event it processed
+func AfterTransaction(txID string) ListenOption {
+	return func(l *EventListener) {
+		l.afterTxID = txID
+	}
+}
+
+// IncludePruned also delivers an event for each log deleted by PruneLogs, for consumers keeping a
+// copy of the logs that must delete them in turn. Without it, only written logs are delivered.
+func IncludePruned() ListenOption {
+	return func(l *EventListener) {
+		l.pruned = true
+	}
+}
+
+// EventListener delivers the logs written to the ledger, in commit order, from the chaincode
+// events of the logging chaincode. It reconnects after transient failures, resuming after the
+// last delivered event, so no event is missed or delivered twice within a run.
+type EventListener struct {
+	contract     *contract
+	handler      EventHandler
+	txHandler    TransactionHandler
+	checkpointer Checkpointer
+
+	startBlock *uint64
+	afterTxID  string
+	pruned     bool
+}
+
+// NewEventListener returns a listener passing each log to handler. Call Run to start it.
+func (c *Client) NewEventListener(handler EventHandler, opts ...ListenOption) *EventListener {
+	l := &EventListener{contract: c.contract, handler: handler}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// NewTransactionListener returns a listener passing the logs of each transaction to handler
+// in one call, for consumers that apply a transaction atomically. Call Run to start it.
+func (c *Client) NewTransactionListener(handler TransactionHandler, opts ...ListenOption) *EventListener {
+	l := &EventListener{contract: c.contract, txHandler: handler}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// Run delivers events until ctx is done or the handler fails
+func (l *EventListener) Run(ctx context.Context) error {
+	if l.checkpointer != nil {
+		checkpoint, err := l.checkpointer.Load()
+		if err != nil {
+			return err
+		}
+		if checkpoint != nil {
+			l.startBlock = &checkpoint.BlockNumber
+			l.afterTxID = checkpoint.TransactionID
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		delivered, err := l.listen(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if delivered {
+			attempt = 1
+		}
+		if err != nil && !errors.Is(err, io.EOF) && !isRetryable(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(l.contract.retry.Backoff(attempt)):
+		}
+	}
+}
+
+// listen streams events until the stream fails, reporting whether any event was delivered
+func (l *EventListener) listen(ctx context.Context) (bool, error) {
+	request, err := l.request()
+	if err != nil {
+		return false, err
+	}
+
+	signature, err := l.contract.signMessage(request)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := l.contract.gateway.ChaincodeEvents(ctx, &gateway.SignedChaincodeEventsRequest{
+		Request:   request,
+		Signature: signature,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	delivered := false
+	for {
+		response, err := stream.Recv()
+		if err != nil {
+			return delivered, err
+		}
+
+		for _, event := range response.GetEvents() {
+			if err := l.deliver(response.GetBlockNumber(), event); err != nil {
+				return delivered, &handlerError{err}
+			}
+			delivered = true
+
+			// Resume after this event if the stream has to be reopened
+			blockNumber := response.GetBlockNumber()
+			l.startBlock = &blockNumber
+			l.afterTxID = event.GetTxId()
+
+			if l.checkpointer != nil {
+				err := l.checkpointer.Save(Checkpoint{BlockNumber: blockNumber, TransactionID: event.GetTxId()})
+				if err != nil {
+					return delivered, &handlerError{err}
+				}
+			}
+		}
+	}
+}
+
+// deliver passes the logs carried by a chaincode event to the handler
+func (l *EventListener) deliver(blockNumber uint64, event *peer.ChaincodeEvent) error {
+	var logs []LogEvent
+	switch event.GetEventName() {
+	case LogCreatedEvent:
+		var log LogEvent
+		if err := json.Unmarshal(event.GetPayload(), &log); err != nil {
+			return fmt.Errorf("failed to parse %s event of transaction %s: %v", event.GetEventName(), event.GetTxId(), err)
+		}
+		logs = []LogEvent{log}
+	case LogsCreatedEvent:
+		if err := json.Unmarshal(event.GetPayload(), &logs); err != nil {
+			return fmt.Errorf("failed to parse %s event of transaction %s: %v", event.GetEventName(), event.GetTxId(), err)
+		}
+	case LogsPrunedEvent:
+		if !l.pruned {
+			return nil
+		}
+		var tombstones []PrunedLog
+		if err := json.Unmarshal(event.GetPayload(), &tombstones); err != nil {
+			return fmt.Errorf("failed to parse %s event of transaction %s: %v", event.GetEventName(), event.GetTxId(), err)
+		}
+		for _, tombstone := range tombstones {
+			logs = append(logs, LogEvent{ID: tombstone.ID, Timestamp: tombstone.Timestamp})
+		}
+	default:
+		return nil
+	}
+
+	events := make([]ContractEvent, len(logs))
+	for i, log := range logs {
+		if err := decodeLog(&log); err != nil {
+			return err
+		}
+
+		events[i] = ContractEvent{
+			BlockNumber:   blockNumber,
+			TransactionID: event.GetTxId(),
+			EventName:     event.GetEventName(),
+			Log:           log,
+		}
+	}
+
+	if l.txHandler != nil {
+		return l.txHandler(events)
+	}
+	for _, event := range events {
+		if err := l.handler(event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// request builds the serialized chaincode events request from the current resume position
+func (l *EventListener) request() ([]byte, error) {
+	creator, err := l.contract.id.serialize()
+	if err != nil {
+		return nil, err
+	}
+
+	start := &orderer.SeekPosition{Type: &orderer.SeekPosition_NextCommit{NextCommit: &orderer.SeekNextCommit{}}}
+	if l.startBlock != nil {
+		start = &orderer.SeekPosition{Type: &orderer.SeekPosition_Specified{Specified: &orderer.SeekSpecified{Number: *l.startBlock}}}
+	}
+
+	return proto.Marshal(&gateway.ChaincodeEventsRequest{
+		ChannelId:          l.contract.channelName,
+		ChaincodeId:        l.contract.chaincodeName,
+		Identity:           creator,
+		StartPosition:      start,
+		AfterTransactionId: l.afterTxID,
+	})
+}
+
+// handlerError marks a failure handling or checkpointing an event, which stops the listener rather than reconnecting
+type handlerError struct {
+	err error
+}
+
+func (e *handlerError) Error() string {
+	return e.err.Error()
+}
+
+func (e *handlerError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+func TestDeliverPrunedLogs(t *testing.T) {
+	event := &peer.ChaincodeEvent{
+		TxId:      "tx1",
+		EventName: LogsPrunedEvent,
+		Payload: []byte(`[{"docType":"PRUNED","id":"l1","timestamp":"2024-01-01T00:00:00Z","prunedAt":"2025-01-01T00:00:00Z","prunedBy":"Org1MSP","before":"2024-06-01T00:00:00Z"},
+			{"docType":"PRUNED","id":"l2","timestamp":"2024-02-01T00:00:00Z","prunedAt":"2025-01-01T00:00:00Z","prunedBy":"Org1MSP","before":"2024-06-01T00:00:00Z"}]`),
+	}
+
+	for _, tt := range []struct {
+		name string
+		opts []ListenOption
+		want []string
+	}{
+		// Consumers of new logs must not mistake a deletion for one
+		{"by default", nil, nil},
+		{"included", []ListenOption{IncludePruned()}, []string{"l1", "l2"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []ContractEvent
+			c := &Client{contract: &contract{}}
+			l := c.NewEventListener(func(event ContractEvent) error {
+				got = append(got, event)
+				return nil
+			}, tt.opts...)
+
+			if err := l.deliver(9, event); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("delivered %+v, want %v", got, tt.want)
+			}
+			for i, event := range got {
+				if event.Log.ID != tt.want[i] || !event.Pruned() || event.BlockNumber != 9 || event.TransactionID != "tx1" {
+					t.Errorf("delivered %+v, want the pruning of %s", event, tt.want[i])
+				}
+			}
+		})
+	}
+}
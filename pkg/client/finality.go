@@ -0,0 +1,70 @@
+package client
+
+// Finality selects how far a submitted transaction must progress before the call returns
+type Finality int
+
+const (
+	// FinalityCommitted waits until the transaction is committed and fails unless it was validated (the default)
+	FinalityCommitted Finality = iota
+	// FinalityOrdered returns once the orderer has accepted the transaction
+	FinalityOrdered
+	// FinalityNone returns as soon as the transaction is endorsed and sends it to the orderer
+	// in the background; ordering and validation failures are not reported
+	FinalityNone
+)
+
+// String returns the name of the finality level
+func (f Finality) String() string {
+	switch f {
+	case FinalityCommitted:
+		return "committed"
+	case FinalityOrdered:
+		return "ordered"
+	case FinalityNone:
+		return "none"
+	default:
+		return "unknown"
+	}
+}
+
+// SubmitResult describes a submitted transaction
+type SubmitResult struct {
+	TransactionID string
+	// Finality is the level the transaction was known to have reached when the call returned
+	Finality Finality
+	// BlockNumber and Status are only set once the transaction is committed
+	BlockNumber uint64
+	Status      string
+
+	payload []byte
+}
+
+// SubmitOption configures a single submission
+type SubmitOption func(*submitOptions)
+
+type submitOptions struct {
+	idempotencyKey string
+	finality       Finality
+	transient      map[string][]byte
+	// attempt counts the resubmissions after read conflicts
+	attempt int
+	// resubmission is the generation of a resubmission of logs whose earlier transaction was invalidated
+	resubmission int
+}
+
+// Resubmission marks a submission as the generation-th resubmission of logs whose earlier
+// transaction was invalidated, such as logs taken from a dead-letter queue. Fabric keeps the
+// transaction IDs of invalidated transactions, so the ID derived from the log IDs alone would be
+// refused as a duplicate; each generation derives one of its own.
+func Resubmission(generation int) SubmitOption {
+	return func(o *submitOptions) {
+		o.resubmission = generation
+	}
+}
+
+// WaitFor sets the finality a submission waits for, overriding the client default
+func WaitFor(finality Finality) SubmitOption {
+	return func(o *submitOptions) {
+		o.finality = finality
+	}
+}
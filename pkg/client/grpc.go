@@ -0,0 +1,190 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+)
+
+// DefaultMaxMessageSize is the largest gRPC message sent or received when GRPCOptions leaves the
+// limit unset. It matches the peer's own default of 100 MiB; gRPC's 4 MiB default is too small
+// for large query pages.
+const DefaultMaxMessageSize = 100 * 1024 * 1024
+
+// GRPCOptions tunes the gRPC connections to gateway peers. Zero values keep the defaults.
+type GRPCOptions struct {
+	// KeepaliveTime is how long a connection is idle before the client pings the peer; keepalive
+	// pings are not sent when zero. Peers reject pings more frequent than their permitted minimum,
+	// 60 seconds by default.
+	KeepaliveTime time.Duration
+	// KeepaliveTimeout is how long the client waits for a ping acknowledgement before closing the connection
+	KeepaliveTimeout time.Duration
+	// KeepalivePermitWithoutStream sends pings even when no call is in progress
+	KeepalivePermitWithoutStream bool
+
+	// MaxRecvMessageSize and MaxSendMessageSize limit message sizes in bytes. DefaultMaxMessageSize
+	// is used when zero, and a negative size removes the limit.
+	MaxRecvMessageSize int
+	MaxSendMessageSize int
+
+	// DialTimeout, when set, makes Connect wait up to that long for each peer to become reachable
+	// and fail otherwise. By default connections are established lazily on the first call.
+	DialTimeout time.Duration
+}
+
+// merge returns o with unset fields taken from defaults
+func (o GRPCOptions) merge(defaults GRPCOptions) GRPCOptions {
+	if o.KeepaliveTime == 0 {
+		o.KeepaliveTime = defaults.KeepaliveTime
+	}
+	if o.KeepaliveTimeout == 0 {
+		o.KeepaliveTimeout = defaults.KeepaliveTimeout
+	}
+	if !o.KeepalivePermitWithoutStream {
+		o.KeepalivePermitWithoutStream = defaults.KeepalivePermitWithoutStream
+	}
+	if o.MaxRecvMessageSize == 0 {
+		o.MaxRecvMessageSize = defaults.MaxRecvMessageSize
+	}
+	if o.MaxSendMessageSize == 0 {
+		o.MaxSendMessageSize = defaults.MaxSendMessageSize
+	}
+	if o.DialTimeout == 0 {
+		o.DialTimeout = defaults.DialTimeout
+	}
+
+	return o
+}
+
+// dialOptions converts o into gRPC dial options
+func (o GRPCOptions) dialOptions() []grpc.DialOption {
+	opts := []grpc.DialOption{
+		grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(messageSize(o.MaxRecvMessageSize)),
+			grpc.MaxCallSendMsgSize(messageSize(o.MaxSendMessageSize)),
+		),
+	}
+	if o.KeepaliveTime > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                o.KeepaliveTime,
+			Timeout:             o.KeepaliveTimeout,
+			PermitWithoutStream: o.KeepalivePermitWithoutStream,
+		}))
+	}
+
+	return opts
+}
+
+func messageSize(size int) int {
+	switch {
+	case size == 0:
+		return DefaultMaxMessageSize
+	case size < 0:
+		return math.MaxInt32
+	default:
+		return size
+	}
+}
+
+// TLSClientCertificate is the client certificate presented to peers requiring mutual TLS
+type TLSClientCertificate struct {
+	// CertPEM and KeyPEM are used in preference to CertPath and KeyPath
+	CertPEM  []byte
+	KeyPEM   []byte
+	CertPath string
+	KeyPath  string
+}
+
+func (c *TLSClientCertificate) load() (tls.Certificate, error) {
+	certPEM, keyPEM := c.CertPEM, c.KeyPEM
+	var err error
+	if len(certPEM) == 0 {
+		if certPEM, err = os.ReadFile(c.CertPath); err != nil {
+			return tls.Certificate{}, fmt.Errorf("failed to read TLS client certificate: %v", err)
+		}
+	}
+	if len(keyPEM) == 0 {
+		if keyPEM, err = os.ReadFile(c.KeyPath); err != nil {
+			return tls.Certificate{}, fmt.Errorf("failed to read TLS client key: %v", err)
+		}
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to load TLS client certificate: %v", err)
+	}
+
+	return cert, nil
+}
+
+func dialPeer(peer PeerConfig, clientCert *TLSClientCertificate, defaults GRPCOptions) (*grpc.ClientConn, error) {
+	transportCredentials, err := loadTransportCredentials(peer, clientCert)
+	if err != nil {
+		return nil, err
+	}
+
+	options := defaults
+	if peer.GRPC != nil {
+		options = peer.GRPC.merge(defaults)
+	}
+	dialOptions := append(options.dialOptions(), grpc.WithTransportCredentials(transportCredentials))
+
+	ctx := context.Background()
+	if options.DialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.DialTimeout)
+		defer cancel()
+		dialOptions = append(dialOptions, grpc.WithBlock())
+	}
+
+	conn, err := grpc.DialContext(ctx, peer.Endpoint, dialOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gRPC connection to %s: %v", peer.Endpoint, err)
+	}
+
+	return conn, nil
+}
+
+func loadTransportCredentials(peer PeerConfig, clientCert *TLSClientCertificate) (credentials.TransportCredentials, error) {
+	caPEM := peer.TLSCACert
+	if len(caPEM) == 0 {
+		if peer.TLSCACertPath == "" {
+			return insecure.NewCredentials(), nil
+		}
+
+		var err error
+		caPEM, err = os.ReadFile(peer.TLSCACertPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS CA certificate: %v", err)
+		}
+	}
+
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no TLS CA certificates found for peer %s", peer.Endpoint)
+	}
+
+	config := &tls.Config{
+		RootCAs:    certPool,
+		ServerName: peer.ServerNameOverride,
+		MinVersion: tls.VersionTLS12,
+	}
+	if clientCert != nil {
+		cert, err := clientCert.load()
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return credentials.NewTLS(config), nil
+}
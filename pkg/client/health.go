@@ -0,0 +1,39 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/connectivity"
+)
+
+// Ping evaluates the chaincode's Ping function, checking that a gateway peer answers and the
+// chaincode is running on the channel
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.contract.evaluate(ctx, "Ping")
+	return err
+}
+
+// CheckConnections returns an error when none of the gateway peer connections the client owns is
+// usable. Idle connections are asked to connect and count as usable. Clients that own no
+// connections, such as those returned by New, always pass.
+func (c *Client) CheckConnections() error {
+	var failed []string
+	for _, conn := range c.conns {
+		switch state := conn.GetState(); state {
+		case connectivity.Ready, connectivity.Connecting:
+			return nil
+		case connectivity.Idle:
+			conn.Connect()
+			return nil
+		default:
+			failed = append(failed, fmt.Sprintf("%s is %s", conn.Target(), strings.ToLower(state.String())))
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("no gateway peer is reachable: %s", strings.Join(failed, ", "))
+}
@@ -0,0 +1,15 @@
+package client
+
+// HSMOptions locates a private key held in a PKCS#11 token such as SoftHSM, Luna or CloudHSM.
+// PKCS#11 signing requires building with the pkcs11 build tag and cgo enabled.
+type HSMOptions struct {
+	// Library is the path of the vendor PKCS#11 module, e.g. /usr/lib/softhsm/libsofthsm2.so
+	Library string
+	// Label is the label of the token holding the key
+	Label string
+	// Pin is the user PIN of the token
+	Pin string
+	// Identifier is the CKA_ID of the private key. When empty, the subject key identifier of
+	// the identity certificate is used, matching keys generated by the Fabric PKCS#11 BCCSP.
+	Identifier []byte
+}
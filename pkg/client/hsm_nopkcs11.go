@@ -0,0 +1,10 @@
+//go:build !pkcs11
+
+package client
+
+import "fmt"
+
+// NewHSMSign returns a Sign backed by a PKCS#11 token. This build does not include PKCS#11 support.
+func NewHSMSign(options HSMOptions, id *Identity) (Sign, func() error, error) {
+	return nil, nil, fmt.Errorf("PKCS#11 signing is not available: rebuild with -tags pkcs11")
+}
@@ -0,0 +1,163 @@
+//go:build pkcs11
+
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+// NewHSMSign returns a Sign backed by the ECDSA private key of id held in a PKCS#11 token,
+// together with a function that logs out and releases the token session
+func NewHSMSign(options HSMOptions, id *Identity) (Sign, func() error, error) {
+	curve, ski, err := certificateKeyInfo(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	identifier := options.Identifier
+	if len(identifier) == 0 {
+		identifier = ski
+	}
+
+	ctx := pkcs11.New(options.Library)
+	if ctx == nil {
+		return nil, nil, fmt.Errorf("failed to load PKCS#11 library %s", options.Library)
+	}
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, nil, fmt.Errorf("failed to initialize PKCS#11 library: %v", err)
+	}
+
+	session, err := openTokenSession(ctx, options.Label, options.Pin)
+	if err != nil {
+		ctx.Finalize()
+		ctx.Destroy()
+		return nil, nil, err
+	}
+
+	closeSession := func() error {
+		ctx.Logout(session)
+		ctx.CloseSession(session)
+		ctx.Finalize()
+		ctx.Destroy()
+		return nil
+	}
+
+	key, err := findPrivateKey(ctx, session, identifier)
+	if err != nil {
+		closeSession()
+		return nil, nil, err
+	}
+
+	// PKCS#11 sessions must not be used concurrently
+	var mu sync.Mutex
+	sign := func(digest []byte) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err := ctx.SignInit(session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)}, key); err != nil {
+			return nil, fmt.Errorf("failed to initialize PKCS#11 signing: %v", err)
+		}
+
+		signature, err := ctx.Sign(session, digest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign with PKCS#11 key: %v", err)
+		}
+
+		// PKCS#11 returns r || s; Fabric expects a low-S ASN.1 signature
+		half := len(signature) / 2
+		r := new(big.Int).SetBytes(signature[:half])
+		s := new(big.Int).SetBytes(signature[half:])
+
+		halfOrder := new(big.Int).Rsh(curve.Params().N, 1)
+		if s.Cmp(halfOrder) > 0 {
+			s.Sub(curve.Params().N, s)
+		}
+
+		return asn1.Marshal(struct{ R, S *big.Int }{r, s})
+	}
+
+	return sign, closeSession, nil
+}
+
+func openTokenSession(ctx *pkcs11.Ctx, label string, pin string) (pkcs11.SessionHandle, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list PKCS#11 slots: %v", err)
+	}
+
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil || info.Label != label {
+			continue
+		}
+
+		session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+		if err != nil {
+			return 0, fmt.Errorf("failed to open session on token %s: %v", label, err)
+		}
+
+		if err := ctx.Login(session, pkcs11.CKU_USER, pin); err != nil {
+			ctx.CloseSession(session)
+			return 0, fmt.Errorf("failed to log in to token %s: %v", label, err)
+		}
+
+		return session, nil
+	}
+
+	return 0, fmt.Errorf("no PKCS#11 token with label %s", label)
+}
+
+func findPrivateKey(ctx *pkcs11.Ctx, session pkcs11.SessionHandle, identifier []byte) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, identifier),
+	}
+	if err := ctx.FindObjectsInit(session, template); err != nil {
+		return 0, fmt.Errorf("failed to search PKCS#11 token: %v", err)
+	}
+	defer ctx.FindObjectsFinal(session)
+
+	objects, _, err := ctx.FindObjects(session, 1)
+	if err != nil {
+		return 0, fmt.Errorf("failed to search PKCS#11 token: %v", err)
+	}
+	if len(objects) == 0 {
+		return 0, fmt.Errorf("no private key with id %x in PKCS#11 token", identifier)
+	}
+
+	return objects[0], nil
+}
+
+// certificateKeyInfo returns the curve of the certificate's public key and its subject key
+// identifier as computed by the Fabric BCCSP (SHA-256 of the uncompressed public point)
+func certificateKeyInfo(id *Identity) (elliptic.Curve, []byte, error) {
+	block, _ := pem.Decode(id.Certificate)
+	if block == nil {
+		return nil, nil, fmt.Errorf("no PEM data found in identity certificate")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse identity certificate: %v", err)
+	}
+
+	publicKey, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported public key type %T", cert.PublicKey)
+	}
+
+	ski := sha256.Sum256(elliptic.Marshal(publicKey.Curve, publicKey.X, publicKey.Y))
+
+	return publicKey.Curve, ski[:], nil
+}
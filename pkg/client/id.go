@@ -0,0 +1,157 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// IDGenerator returns the ID of a log submitted without one
+type IDGenerator func(log LogEvent) (string, error)
+
+// crockfordAlphabet is the base 32 alphabet of ULIDs
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// Snowflake IDs hold a millisecond timestamp since SnowflakeEpoch, a node ID and a sequence
+// number within the millisecond
+const (
+	snowflakeNodeBits     = 10
+	snowflakeSequenceBits = 12
+	MaxSnowflakeNodeID    = 1<<snowflakeNodeBits - 1
+)
+
+// SnowflakeEpoch is the time snowflake IDs count milliseconds from
+var SnowflakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// UUIDv7 generates RFC 9562 version 7 UUIDs, which begin with a millisecond timestamp and so sort
+// by creation time to the millisecond. It is the default generator.
+func UUIDv7() IDGenerator {
+	return func(LogEvent) (string, error) {
+		var id [16]byte
+		if _, err := rand.Read(id[6:]); err != nil {
+			return "", fmt.Errorf("failed to generate UUID: %v", err)
+		}
+		putMillis(id[:6], time.Now())
+		id[6] = id[6]&0x0f | 0x70
+		id[8] = id[8]&0x3f | 0x80
+
+		s := hex.EncodeToString(id[:])
+		return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:], nil
+	}
+}
+
+// ULID generates ULIDs: a millisecond timestamp and 80 random bits in 26 characters of Crockford
+// base 32, sorting by creation time to the millisecond
+func ULID() IDGenerator {
+	return func(LogEvent) (string, error) {
+		var id [16]byte
+		if _, err := rand.Read(id[6:]); err != nil {
+			return "", fmt.Errorf("failed to generate ULID: %v", err)
+		}
+		putMillis(id[:6], time.Now())
+
+		// 128 bits are encoded as 26 groups of 5 bits, the first holding only 3
+		hi := binary.BigEndian.Uint64(id[:8])
+		lo := binary.BigEndian.Uint64(id[8:])
+		var s [26]byte
+		for i := 25; i >= 0; i-- {
+			s[i] = crockfordAlphabet[lo&0x1f]
+			lo = lo>>5 | hi<<59
+			hi >>= 5
+		}
+		return string(s[:]), nil
+	}
+}
+
+// Snowflake generates decimal snowflake IDs unique across up to 1024 nodes without coordination,
+// as long as every node has its own nodeID. IDs from one node increase strictly; across nodes
+// they sort by time to the millisecond. A node generates at most 4096 IDs per millisecond and
+// waits for the next millisecond beyond that.
+func Snowflake(nodeID int) (IDGenerator, error) {
+	if nodeID < 0 || nodeID > MaxSnowflakeNodeID {
+		return nil, fmt.Errorf("snowflake node ID %d is outside 0 to %d", nodeID, MaxSnowflakeNodeID)
+	}
+
+	var mu sync.Mutex
+	var last, sequence int64
+	return func(LogEvent) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		now := time.Since(SnowflakeEpoch).Milliseconds()
+		if now < last {
+			// The clock went backwards; keep counting from the last millisecond used
+			now = last
+		}
+		if now == last {
+			sequence = (sequence + 1) & (1<<snowflakeSequenceBits - 1)
+			if sequence == 0 {
+				for now <= last {
+					time.Sleep(time.Millisecond)
+					now = time.Since(SnowflakeEpoch).Milliseconds()
+				}
+			}
+		} else {
+			sequence = 0
+		}
+		last = now
+
+		id := now<<(snowflakeNodeBits+snowflakeSequenceBits) | int64(nodeID)<<snowflakeSequenceBits | sequence
+		return strconv.FormatInt(id, 10), nil
+	}, nil
+}
+
+// ContentHash derives the ID from a SHA-256 hash of the log's fields, so the same event always
+// gets the same ID and submitting it twice fails with ErrAlreadyExists instead of recording a
+// duplicate. Events that must be recorded separately have to differ in some field, for example
+// a timestamp set by the producer.
+func ContentHash() IDGenerator {
+	return func(log LogEvent) (string, error) {
+		hash := sha256.New()
+		for _, field := range []string{log.UserID, log.Action, log.Resource, log.Timestamp, log.Description, log.Metadata} {
+			// Length prefixes keep field boundaries unambiguous
+			var length [8]byte
+			binary.BigEndian.PutUint64(length[:], uint64(len(field)))
+			hash.Write(length[:])
+			hash.Write([]byte(field))
+		}
+		return hex.EncodeToString(hash.Sum(nil)), nil
+	}
+}
+
+// putMillis writes the Unix time of t in milliseconds to the 6 bytes of b, big-endian
+func putMillis(b []byte, t time.Time) {
+	ms := uint64(t.UnixMilli())
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+}
+
+// CreateLogAutoID submits log with an ID from the client's IDGenerator when it has none, and
+// returns the ID it was recorded under
+func (c *Client) CreateLogAutoID(ctx context.Context, log LogEvent) (string, error) {
+	if log.ID == "" {
+		generate := c.idGenerator
+		if generate == nil {
+			generate = UUIDv7()
+		}
+
+		id, err := generate(log)
+		if err != nil {
+			return "", err
+		}
+		log.ID = id
+	}
+
+	if err := c.CreateLog(ctx, log); err != nil {
+		return "", err
+	}
+	return log.ID, nil
+}
@@ -0,0 +1,126 @@
+package client
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/msp"
+)
+
+// Identity is the X.509 identity used to sign proposals and transactions
+type Identity struct {
+	MSPID       string
+	Certificate []byte // PEM encoded
+}
+
+// Sign signs a SHA-256 message digest and returns the signature
+type Sign func(digest []byte) ([]byte, error)
+
+// LoadIdentity reads a PEM encoded certificate and returns the identity for the given MSP
+func LoadIdentity(mspID string, certPath string) (*Identity, error) {
+	certPEM, err := readPEMFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate: %v", err)
+	}
+
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in certificate %s", certPath)
+	}
+	if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+		return nil, fmt.Errorf("failed to parse certificate %s: %v", certPath, err)
+	}
+
+	return &Identity{MSPID: mspID, Certificate: certPEM}, nil
+}
+
+// LoadSign reads a PEM encoded private key and returns a Sign using it.
+// keyPath may name the key file or a keystore directory holding a single key.
+func LoadSign(keyPath string) (Sign, error) {
+	keyPEM, err := readPEMFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %v", err)
+	}
+
+	key, err := parsePrivateKey(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %v", keyPath, err)
+	}
+
+	return NewPrivateKeySign(key)
+}
+
+// NewPrivateKeySign returns a Sign for the given ECDSA private key
+func NewPrivateKeySign(key crypto.PrivateKey) (Sign, error) {
+	ecdsaKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+
+	return func(digest []byte) ([]byte, error) {
+		r, s, err := ecdsa.Sign(rand.Reader, ecdsaKey, digest)
+		if err != nil {
+			return nil, err
+		}
+
+		// Fabric only accepts low-S signatures
+		halfOrder := new(big.Int).Rsh(ecdsaKey.Params().N, 1)
+		if s.Cmp(halfOrder) > 0 {
+			s.Sub(ecdsaKey.Params().N, s)
+		}
+
+		return asn1.Marshal(struct{ R, S *big.Int }{r, s})
+	}, nil
+}
+
+// serialize returns the serialized identity embedded in proposal and request headers
+func (id *Identity) serialize() ([]byte, error) {
+	return proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   id.MSPID,
+		IdBytes: id.Certificate,
+	})
+}
+
+// readPEMFile reads path, or the only file inside path when it is a directory
+func readPEMFile(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return os.ReadFile(path)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) != 1 {
+		return nil, fmt.Errorf("expected a single file in %s, found %d", path, len(entries))
+	}
+
+	return os.ReadFile(filepath.Join(path, entries[0].Name()))
+}
+
+func parsePrivateKey(keyPEM []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err == nil {
+		return key, nil
+	}
+
+	return x509.ParseECPrivateKey(block.Bytes)
+}
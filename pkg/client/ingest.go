@@ -0,0 +1,177 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// IngestOptions configures IngestAll
+type IngestOptions struct {
+	// BatchSize is the most logs submitted in one CreateLogsBatch transaction; DefaultMaxBatchSize is used otherwise
+	BatchSize int
+	// Linger is the longest a partial batch waits for more logs; DefaultFlushInterval is used otherwise
+	Linger time.Duration
+	// Concurrency is the number of batches submitted at once. With the default of 1, batches
+	// commit in the order their logs were received.
+	Concurrency int
+	// SubmitOptions apply to every batch
+	SubmitOptions []SubmitOption
+}
+
+// IngestResult reports the outcome for one log passed to IngestAll
+type IngestResult struct {
+	Log LogEvent
+	// TransactionID and BlockNumber identify the batch transaction the log was submitted in
+	TransactionID string
+	BlockNumber   uint64
+	// Err is set when the log was not recorded, including ErrSampledOut for logs dropped by sampling
+	Err error
+}
+
+// ingestBatch is a chunk of logs and, once handled, its outcome. A batch with err set before
+// submission holds a single log rejected while it was prepared.
+type ingestBatch struct {
+	seq    int
+	logs   []LogEvent
+	result *SubmitResult
+	err    error
+}
+
+// IngestAll consumes logs until the channel is closed, submitting them in CreateLogsBatch
+// transactions of up to BatchSize logs, and reports one IngestResult per log. Results are
+// delivered in the order the logs were received, whatever the Concurrency. The results
+// channel is closed once every log has been reported; when ctx is done, logs not yet
+// submitted are reported with ctx's error. The caller must drain the results channel.
+func (c *Client) IngestAll(ctx context.Context, logs <-chan LogEvent, options IngestOptions) <-chan IngestResult {
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultMaxBatchSize
+	}
+	if options.Linger <= 0 {
+		options.Linger = DefaultFlushInterval
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = 1
+	}
+
+	batches := make(chan *ingestBatch)
+	handled := make(chan *ingestBatch)
+	results := make(chan IngestResult)
+
+	go c.chunk(ctx, logs, options, batches)
+
+	var workers sync.WaitGroup
+	for i := 0; i < options.Concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for batch := range batches {
+				if batch.err == nil {
+					if err := ctx.Err(); err != nil {
+						batch.err = err
+					} else {
+						submitOptions := c.submitOptions("", options.SubmitOptions)
+						batch.result, batch.err = c.submitPreparedBatch(ctx, submitOptions, batch.logs)
+					}
+				}
+				handled <- batch
+			}
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(handled)
+	}()
+
+	go report(handled, results)
+
+	return results
+}
+
+// chunk prepares each log and groups the accepted ones into batches, closing batches when done
+func (c *Client) chunk(ctx context.Context, logs <-chan LogEvent, options IngestOptions, batches chan<- *ingestBatch) {
+	defer close(batches)
+
+	seq := 0
+	var pending []LogEvent
+	flush := func() {
+		if len(pending) > 0 {
+			batches <- &ingestBatch{seq: seq, logs: pending}
+			seq++
+			pending = nil
+		}
+	}
+
+	timer := time.NewTimer(options.Linger)
+	defer timer.Stop()
+
+	for {
+		select {
+		case log, ok := <-logs:
+			if !ok {
+				flush()
+				return
+			}
+
+			prepared, err := c.prepare(ctx, log)
+			if err != nil {
+				c.deadLetter(err, log)
+				// Keep the rejected log in sequence with the batches around it
+				flush()
+				batches <- &ingestBatch{seq: seq, logs: []LogEvent{log}, err: err}
+				seq++
+				continue
+			}
+
+			if len(pending) == 0 {
+				timer.Reset(options.Linger)
+			}
+			pending = append(pending, prepared[0])
+			if len(pending) >= options.BatchSize {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		case <-ctx.Done():
+			// Report everything still buffered or queued as canceled
+			if len(pending) > 0 {
+				batches <- &ingestBatch{seq: seq, logs: pending, err: ctx.Err()}
+				seq++
+				pending = nil
+			}
+			for log := range logs {
+				batches <- &ingestBatch{seq: seq, logs: []LogEvent{log}, err: ctx.Err()}
+				seq++
+			}
+			return
+		}
+	}
+}
+
+// report emits one result per log, releasing batches in sequence order
+func report(handled <-chan *ingestBatch, results chan<- IngestResult) {
+	defer close(results)
+
+	next := 0
+	waiting := map[int]*ingestBatch{}
+	for batch := range handled {
+		waiting[batch.seq] = batch
+		for {
+			ready, ok := waiting[next]
+			if !ok {
+				break
+			}
+			delete(waiting, next)
+			next++
+
+			for _, log := range ready.logs {
+				result := IngestResult{Log: log, Err: ready.err}
+				if ready.result != nil {
+					result.TransactionID = ready.result.TransactionID
+					result.BlockNumber = ready.result.BlockNumber
+				}
+				results <- result
+			}
+		}
+	}
+}
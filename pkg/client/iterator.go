@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrIteratorDone is returned by LogIterator.Next when no logs remain
+var ErrIteratorDone = errors.New("no more logs in iterator")
+
+// pageFetcher retrieves the page of logs starting at bookmark
+type pageFetcher func(ctx context.Context, bookmark string) (*LogPage, error)
+
+// LogIterator walks a paginated log query, following bookmarks as each page is consumed
+type LogIterator struct {
+	fetch    pageFetcher
+	pageSize int32
+	buffer   []*LogEvent
+	bookmark string
+	lastPage bool
+	err      error
+}
+
+func newLogIterator(pageSize int32, fetch pageFetcher) *LogIterator {
+	return &LogIterator{
+		fetch:    fetch,
+		pageSize: pageSize,
+	}
+}
+
+// Next returns the next log, fetching the following page when the current one is exhausted.
+// It returns ErrIteratorDone once every log has been returned. A page fetch interrupted by ctx
+// can be retried by calling Next again.
+func (it *LogIterator) Next(ctx context.Context) (*LogEvent, error) {
+	for len(it.buffer) == 0 {
+		if it.err != nil {
+			return nil, it.err
+		}
+		if it.lastPage {
+			return nil, ErrIteratorDone
+		}
+		if err := it.fetchPage(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	log := it.buffer[0]
+	it.buffer = it.buffer[1:]
+
+	return log, nil
+}
+
+// All drains the iterator and returns every remaining log
+func (it *LogIterator) All(ctx context.Context) ([]*LogEvent, error) {
+	var logs []*LogEvent
+	for {
+		log, err := it.Next(ctx)
+		if errors.Is(err, ErrIteratorDone) {
+			return logs, nil
+		}
+		if err != nil {
+			return logs, err
+		}
+		logs = append(logs, log)
+	}
+}
+
+// Bookmark returns the bookmark of the next page to be fetched, so an iteration can be resumed later
+func (it *LogIterator) Bookmark() string {
+	return it.bookmark
+}
+
+func (it *LogIterator) fetchPage(ctx context.Context) error {
+	page, err := it.fetch(ctx, it.bookmark)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		it.err = err
+		return err
+	}
+
+	it.buffer = page.Records
+	// A short page, or a bookmark that did not move, means the query is exhausted
+	if page.FetchedRecordsCount < it.pageSize || page.Bookmark == "" || page.Bookmark == it.bookmark {
+		it.lastPage = true
+	}
+	it.bookmark = page.Bookmark
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+)
+
+// qsccName is Fabric's system chaincode answering queries about the ledger itself
+const qsccName = "qscc"
+
+// BlockHeight returns the number of blocks on the channel's ledger as the gateway peer knows it.
+// It grows with every commit, so results read at the same height are still current.
+func (c *Client) BlockHeight(ctx context.Context) (uint64, error) {
+	qscc := *c.contract
+	qscc.chaincodeName = qsccName
+	result, err := qscc.evaluate(ctx, "GetChainInfo", c.contract.channelName)
+	if err != nil {
+		return 0, err
+	}
+
+	var info common.BlockchainInfo
+	if err := proto.Unmarshal(result, &info); err != nil {
+		return 0, fmt.Errorf("failed to parse chain info: %v", err)
+	}
+
+	return info.GetHeight(), nil
+}
+
+// BlockByNumber returns the block of the channel's ledger with the given number, as the gateway
+// peer stores it, with its transactions and their validation codes in its metadata
+func (c *Client) BlockByNumber(ctx context.Context, number uint64) (*common.Block, error) {
+	return c.block(ctx, "GetBlockByNumber", strconv.FormatUint(number, 10))
+}
+
+// BlockByTxID returns the block of the channel's ledger holding the transaction with the given ID
+func (c *Client) BlockByTxID(ctx context.Context, txID string) (*common.Block, error) {
+	return c.block(ctx, "GetBlockByTxID", txID)
+}
+
+func (c *Client) block(ctx context.Context, fn string, arg string) (*common.Block, error) {
+	qscc := *c.contract
+	qscc.chaincodeName = qsccName
+	result, err := qscc.evaluate(ctx, fn, c.contract.channelName, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	var block common.Block
+	if err := proto.Unmarshal(result, &block); err != nil {
+		return nil, fmt.Errorf("failed to parse block: %v", err)
+	}
+
+	return &block, nil
+}
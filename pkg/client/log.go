@@ -0,0 +1,31 @@
+package client
+
+// LogEvent represents a user event log as stored by the logging chaincode
+type LogEvent struct {
+	ID          string `json:"id"`
+	UserID      string `json:"userId"`
+	Action      string `json:"action"`
+	Resource    string `json:"resource"`
+	Timestamp   string `json:"timestamp"`
+	Description string `json:"description"`
+	Metadata    string `json:"metadata,omitempty"`
+	// Collection names the private data collection holding Description and Metadata of a private log
+	Collection string `json:"collection,omitempty"`
+}
+
+// LogFilter holds the structured criteria accepted by the chaincode QueryLogs transaction.
+// Empty fields are ignored; StartTime and EndTime are RFC 3339 timestamps.
+type LogFilter struct {
+	UserID    string `json:"userId,omitempty"`
+	Action    string `json:"action,omitempty"`
+	Resource  string `json:"resource,omitempty"`
+	StartTime string `json:"startTime,omitempty"`
+	EndTime   string `json:"endTime,omitempty"`
+}
+
+// LogPage is one page of a paginated log query
+type LogPage struct {
+	Records             []*LogEvent `json:"records"`
+	FetchedRecordsCount int32       `json:"fetchedRecordsCount"`
+	Bookmark            string      `json:"bookmark"`
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsNamespace prefixes every metric exported by the client
+const metricsNamespace = "fabric_logging"
+
+// Metrics holds the Prometheus instruments of a Client. Counters and histograms are labelled
+// with the chaincode transaction function.
+type Metrics struct {
+	registerer prometheus.Registerer
+
+	submitted      *prometheus.CounterVec
+	committed      *prometheus.CounterVec
+	failed         *prometheus.CounterVec
+	endorseLatency *prometheus.HistogramVec
+	commitLatency  *prometheus.HistogramVec
+}
+
+// NewMetrics creates the client metrics and registers them with registerer,
+// or prometheus.DefaultRegisterer when it is nil
+func NewMetrics(registerer prometheus.Registerer) (*Metrics, error) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	m := &Metrics{
+		registerer: registerer,
+		submitted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "transactions_submitted_total",
+			Help:      "Transactions submitted, including those that later failed.",
+		}, []string{"function"}),
+		committed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "transactions_committed_total",
+			Help:      "Transactions confirmed as committed and valid.",
+		}, []string{"function"}),
+		failed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "transactions_failed_total",
+			Help:      "Transactions that failed endorsement, ordering or validation.",
+		}, []string{"function"}),
+		endorseLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "endorse_duration_seconds",
+			Help:      "Time to endorse a transaction, including retries.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"function"}),
+		commitLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "commit_duration_seconds",
+			Help:      "Time from sending an endorsed transaction to the orderer until it is committed.",
+			Buckets:   []float64{.1, .25, .5, 1, 2, 4, 8, 16, 32, 64},
+		}, []string{"function"}),
+	}
+
+	for _, collector := range []prometheus.Collector{m.submitted, m.committed, m.failed, m.endorseLatency, m.commitLatency} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// WatchQueue exports the depth of a submission queue, such as BatchingSubmitter.Len or
+// SpoolingSubmitter.Len, as the queue_depth gauge labelled with name
+func (m *Metrics) WatchQueue(name string, depth func() int) error {
+	return m.registerer.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   metricsNamespace,
+		Name:        "queue_depth",
+		Help:        "Logs waiting in a client-side submission queue.",
+		ConstLabels: prometheus.Labels{"queue": name},
+	}, func() float64 { return float64(depth()) }))
+}
+
+// WatchDeadLetterQueue exports the number of entries in queue as the dead_letters gauge
+func (m *Metrics) WatchDeadLetterQueue(queue *DeadLetterQueue) error {
+	return m.registerer.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "dead_letters",
+		Help:      "Logs held in the dead-letter queue.",
+	}, func() float64 { return float64(queue.Len()) }))
+}
+
+// ServeMetrics serves gatherer, or prometheus.DefaultGatherer when it is nil, on addr at
+// /metrics in the background. Close the returned server to stop it.
+func ServeMetrics(addr string, gatherer prometheus.Gatherer) (*http.Server, error) {
+	if gatherer == nil {
+		gatherer = prometheus.DefaultGatherer
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			listener.Close()
+		}
+	}()
+
+	return server, nil
+}
+
+// The observe methods are safe to call on a nil *Metrics, which records nothing
+
+func (m *Metrics) observeSubmitted(fn string) {
+	if m != nil {
+		m.submitted.WithLabelValues(fn).Inc()
+	}
+}
+
+func (m *Metrics) observeResult(fn string, result *SubmitResult, err error) {
+	if m == nil {
+		return
+	}
+	if err != nil {
+		m.failed.WithLabelValues(fn).Inc()
+	} else if result.Finality == FinalityCommitted {
+		m.committed.WithLabelValues(fn).Inc()
+	}
+}
+
+func (m *Metrics) observeEndorse(fn string, d time.Duration) {
+	if m != nil {
+		m.endorseLatency.WithLabelValues(fn).Observe(d.Seconds())
+	}
+}
+
+func (m *Metrics) observeCommit(fn string, d time.Duration) {
+	if m != nil {
+		m.commitLatency.WithLabelValues(fn).Observe(d.Seconds())
+	}
+}
@@ -0,0 +1,238 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrUnknownChannel is returned when a log is routed to a channel the client is not configured with
+var ErrUnknownChannel = errors.New("unknown channel")
+
+// ChannelSelector chooses the channel a log is written to; an empty name selects the default channel
+type ChannelSelector func(log LogEvent) string
+
+// MultiChannelClient is a LoggingClient spread over several channels, for example one per
+// business unit. Writes are routed by a ChannelSelector and reads fan out to every channel,
+// with query results merged in timestamp order.
+type MultiChannelClient struct {
+	base     *Client
+	channels []string
+	clients  map[string]*Client
+	selector ChannelSelector
+}
+
+var _ LoggingClient = (*MultiChannelClient)(nil)
+
+// NewMultiChannelClient returns a client writing to and reading from channels over base's
+// connections and identity. The first channel is the default. When selector is nil every log
+// goes to the default channel. Closing the returned client closes base.
+func NewMultiChannelClient(base *Client, channels []string, selector ChannelSelector) (*MultiChannelClient, error) {
+	if len(channels) == 0 {
+		return nil, fmt.Errorf("no channels configured")
+	}
+
+	m := &MultiChannelClient{
+		base:     base,
+		clients:  map[string]*Client{},
+		selector: selector,
+	}
+	for _, channel := range channels {
+		if _, ok := m.clients[channel]; ok {
+			return nil, fmt.Errorf("channel %s configured more than once", channel)
+		}
+		m.channels = append(m.channels, channel)
+		m.clients[channel] = base.ForChannel(channel)
+	}
+
+	return m, nil
+}
+
+// ForChannel returns a client for another channel sharing c's connections, identity and options.
+// The returned client does not own the connections; closing it has no effect. It does not use
+// c's LogCache, since the same log ID may name different logs on different channels.
+func (c *Client) ForChannel(channelName string) *Client {
+	contract := *c.contract
+	contract.channelName = channelName
+
+	channel := *c
+	channel.contract = &contract
+	channel.conns = nil
+	channel.closeSign = nil
+	channel.cache = nil
+
+	return &channel
+}
+
+// Channels returns the configured channel names, the default first
+func (m *MultiChannelClient) Channels() []string {
+	return append([]string(nil), m.channels...)
+}
+
+// Channel returns the client for one channel, or nil when the channel is not configured
+func (m *MultiChannelClient) Channel(name string) *Client {
+	return m.clients[name]
+}
+
+// route returns the client for the channel selected for log
+func (m *MultiChannelClient) route(log LogEvent) (*Client, error) {
+	channel := m.channels[0]
+	if m.selector != nil {
+		if selected := m.selector(log); selected != "" {
+			channel = selected
+		}
+	}
+
+	c, ok := m.clients[channel]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s selected for log %s", ErrUnknownChannel, channel, log.ID)
+	}
+
+	return c, nil
+}
+
+// CreateLog submits log to its selected channel
+func (m *MultiChannelClient) CreateLog(ctx context.Context, log LogEvent) error {
+	c, err := m.route(log)
+	if err != nil {
+		return err
+	}
+
+	return c.CreateLog(ctx, log)
+}
+
+// CreateLogsBatch submits one batch transaction per selected channel. Each channel's batch is
+// atomic, but the batches are not: when one fails, the others may still have been recorded.
+func (m *MultiChannelClient) CreateLogsBatch(ctx context.Context, logs []LogEvent) error {
+	batches := map[*Client][]LogEvent{}
+	var order []*Client
+	for _, log := range logs {
+		c, err := m.route(log)
+		if err != nil {
+			return err
+		}
+		if _, ok := batches[c]; !ok {
+			order = append(order, c)
+		}
+		batches[c] = append(batches[c], log)
+	}
+
+	for _, c := range order {
+		if err := c.CreateLogsBatch(ctx, batches[c]); err != nil {
+			return fmt.Errorf("channel %s: %w", c.contract.channelName, err)
+		}
+	}
+
+	return nil
+}
+
+// ReadLog returns the log with given id from whichever channel holds it, checking channels in order
+func (m *MultiChannelClient) ReadLog(ctx context.Context, id string) (*LogEvent, error) {
+	for _, channel := range m.channels {
+		log, err := m.clients[channel].ReadLog(ctx, id)
+		if errors.Is(err, ErrLogNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("channel %s: %w", channel, err)
+		}
+		return log, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s on any channel", ErrLogNotFound, id)
+}
+
+// LogExists returns true when a log with given id exists on any channel
+func (m *MultiChannelClient) LogExists(ctx context.Context, id string) (bool, error) {
+	for _, channel := range m.channels {
+		exists, err := m.clients[channel].LogExists(ctx, id)
+		if err != nil {
+			return false, fmt.Errorf("channel %s: %w", channel, err)
+		}
+		if exists {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// GetAllLogs returns every log on every channel, sorted by timestamp
+func (m *MultiChannelClient) GetAllLogs(ctx context.Context) ([]*LogEvent, error) {
+	return m.fanOut(ctx, func(ctx context.Context, c *Client) ([]*LogEvent, error) {
+		return c.GetAllLogs(ctx)
+	})
+}
+
+// QueryLogs queries every channel concurrently and returns the matching logs sorted by timestamp
+func (m *MultiChannelClient) QueryLogs(ctx context.Context, filter LogFilter) ([]*LogEvent, error) {
+	return m.fanOut(ctx, func(ctx context.Context, c *Client) ([]*LogEvent, error) {
+		return c.QueryLogs(ctx, filter)
+	})
+}
+
+// Close releases the base client's connections
+func (m *MultiChannelClient) Close() error {
+	return m.base.Close()
+}
+
+// fanOut runs query on every channel at once and merges the results. The first failure
+// cancels the remaining queries and is returned.
+func (m *MultiChannelClient) fanOut(ctx context.Context, query func(context.Context, *Client) ([]*LogEvent, error)) ([]*LogEvent, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([][]*LogEvent, len(m.channels))
+	errs := make([]error, len(m.channels))
+	var wg sync.WaitGroup
+	for i, channel := range m.channels {
+		wg.Add(1)
+		go func(i int, channel string) {
+			defer wg.Done()
+			results[i], errs[i] = query(ctx, m.clients[channel])
+			if errs[i] != nil {
+				cancel()
+			}
+		}(i, channel)
+	}
+	wg.Wait()
+
+	// Report the failure that caused the cancellation rather than the queries it canceled
+	var firstErr error
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		err = fmt.Errorf("channel %s: %w", m.channels[i], err)
+		if firstErr == nil || errors.Is(firstErr, context.Canceled) {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	var merged []*LogEvent
+	for _, logs := range results {
+		merged = append(merged, logs...)
+	}
+	sortByTimestamp(merged)
+
+	return merged, nil
+}
+
+// sortByTimestamp orders logs oldest first. Timestamps are compared as times when they parse
+// as RFC 3339, so logs written in different time zones interleave correctly.
+func sortByTimestamp(logs []*LogEvent) {
+	sort.SliceStable(logs, func(i, j int) bool {
+		ti, errI := time.Parse(time.RFC3339Nano, logs[i].Timestamp)
+		tj, errJ := time.Parse(time.RFC3339Nano, logs[j].Timestamp)
+		if errI != nil || errJ != nil {
+			return logs[i].Timestamp < logs[j].Timestamp
+		}
+		return ti.Before(tj)
+	})
+}
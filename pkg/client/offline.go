@@ -0,0 +1,260 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/gateway"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// The offline signing workflow lets a client without access to the private key submit
+// transactions. Each stage produces an unsigned message whose Bytes can be carried to an
+// external signer, which signs its Digest; the matching NewSigned* call reassembles it:
+//
+//	proposal, _ := c.NewCreateLogProposal(log)           // sign proposal.Digest()
+//	proposal, _ = c.NewSignedProposal(proposal.Bytes(), proposalSignature)
+//	tx, _ := c.Endorse(proposal)                          // sign tx.Digest()
+//	tx, _ = c.NewSignedTransaction(tx.Bytes(), txSignature)
+//	commit, _ := c.Submit(tx)                             // sign commit.Digest()
+//	commit, _ = c.NewSignedCommit(commit.Bytes(), commitSignature)
+//	result, _ := c.CommitStatus(commit)
+//
+// A client used only this way can be created with New and a nil Sign.
+
+// Proposal is a transaction proposal, signed or awaiting an offline signature
+type Proposal struct {
+	TransactionID string
+	bytes         []byte
+	signature     []byte
+}
+
+// Bytes returns the serialized proposal
+func (p *Proposal) Bytes() []byte {
+	return p.bytes
+}
+
+// Digest returns the SHA-256 digest of the proposal that must be signed
+func (p *Proposal) Digest() []byte {
+	digest := sha256.Sum256(p.bytes)
+	return digest[:]
+}
+
+// Transaction is an endorsed transaction, signed or awaiting an offline signature
+type Transaction struct {
+	TransactionID string
+	envelope      *common.Envelope
+	result        []byte
+}
+
+// Bytes returns the serialized transaction
+func (t *Transaction) Bytes() ([]byte, error) {
+	return proto.Marshal(t.envelope)
+}
+
+// Digest returns the SHA-256 digest of the transaction that must be signed
+func (t *Transaction) Digest() []byte {
+	digest := sha256.Sum256(t.envelope.GetPayload())
+	return digest[:]
+}
+
+// Result returns the chaincode response returned by the endorsing peers
+func (t *Transaction) Result() []byte {
+	return t.result
+}
+
+// Commit is a commit status request for a submitted transaction, signed or awaiting an offline signature
+type Commit struct {
+	TransactionID string
+	bytes         []byte
+	signature     []byte
+}
+
+// Bytes returns the serialized commit status request
+func (c *Commit) Bytes() []byte {
+	return c.bytes
+}
+
+// Digest returns the SHA-256 digest of the commit status request that must be signed
+func (c *Commit) Digest() []byte {
+	digest := sha256.Sum256(c.bytes)
+	return digest[:]
+}
+
+// NewProposal builds an unsigned proposal invoking the chaincode function fn with args
+func (c *Client) NewProposal(fn string, args ...string) (*Proposal, error) {
+	txID, proposalBytes, err := c.contract.buildProposal(fn, args, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Proposal{TransactionID: txID, bytes: proposalBytes}, nil
+}
+
+// NewCreateLogProposal builds an unsigned CreateLog proposal, using the log ID as its idempotency key
+func (c *Client) NewCreateLogProposal(log LogEvent) (*Proposal, error) {
+	nonce := idempotencyNonce(c.contract.chaincodeName, "CreateLog", log.ID)
+	args := []string{log.ID, log.UserID, log.Action, log.Resource, log.Description, log.Metadata}
+
+	txID, proposalBytes, err := c.contract.buildProposal("CreateLog", args, nonce, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Proposal{TransactionID: txID, bytes: proposalBytes}, nil
+}
+
+// NewSignedProposal reassembles a proposal from its serialized form and an offline signature
+func (c *Client) NewSignedProposal(proposalBytes []byte, signature []byte) (*Proposal, error) {
+	proposal := &peer.Proposal{}
+	if err := proto.Unmarshal(proposalBytes, proposal); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal proposal: %v", err)
+	}
+
+	header := &common.Header{}
+	if err := proto.Unmarshal(proposal.GetHeader(), header); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal proposal header: %v", err)
+	}
+
+	channelHeader := &common.ChannelHeader{}
+	if err := proto.Unmarshal(header.GetChannelHeader(), channelHeader); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal channel header: %v", err)
+	}
+
+	return &Proposal{
+		TransactionID: channelHeader.GetTxId(),
+		bytes:         proposalBytes,
+		signature:     signature,
+	}, nil
+}
+
+// Endorse sends a signed proposal for endorsement and returns the unsigned transaction
+func (c *Client) Endorse(ctx context.Context, p *Proposal) (*Transaction, error) {
+	if len(p.signature) == 0 {
+		return nil, fmt.Errorf("proposal %s is not signed", p.TransactionID)
+	}
+
+	signed := &proposal{
+		txID: p.TransactionID,
+		signed: &peer.SignedProposal{
+			ProposalBytes: p.bytes,
+			Signature:     p.signature,
+		},
+	}
+
+	var envelope *common.Envelope
+	err := c.contract.retry.do(ctx, func() (err error) {
+		envelope, err = c.contract.endorse(ctx, signed)
+		return err
+	})
+	if err != nil {
+		return nil, gatewayError("endorse", p.TransactionID, err)
+	}
+
+	result, err := transactionResult(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Transaction{TransactionID: p.TransactionID, envelope: envelope, result: result}, nil
+}
+
+// NewSignedTransaction reassembles a transaction from its serialized form and an offline signature
+func (c *Client) NewSignedTransaction(transactionBytes []byte, signature []byte) (*Transaction, error) {
+	envelope := &common.Envelope{}
+	if err := proto.Unmarshal(transactionBytes, envelope); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal transaction: %v", err)
+	}
+	envelope.Signature = signature
+
+	payload := &common.Payload{}
+	if err := proto.Unmarshal(envelope.GetPayload(), payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal transaction payload: %v", err)
+	}
+
+	channelHeader := &common.ChannelHeader{}
+	if err := proto.Unmarshal(payload.GetHeader().GetChannelHeader(), channelHeader); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal channel header: %v", err)
+	}
+
+	result, err := transactionResult(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Transaction{TransactionID: channelHeader.GetTxId(), envelope: envelope, result: result}, nil
+}
+
+// Submit sends a signed transaction to the orderer and returns the unsigned commit status request
+func (c *Client) Submit(ctx context.Context, tx *Transaction) (*Commit, error) {
+	if len(tx.envelope.GetSignature()) == 0 {
+		return nil, fmt.Errorf("transaction %s is not signed", tx.TransactionID)
+	}
+
+	err := c.contract.retry.do(ctx, func() error {
+		ctx, cancel := context.WithTimeout(ctx, defaultSubmitTimeout)
+		defer cancel()
+
+		_, err := c.contract.gateway.Submit(ctx, &gateway.SubmitRequest{
+			TransactionId:       tx.TransactionID,
+			ChannelId:           c.contract.channelName,
+			PreparedTransaction: tx.envelope,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, gatewayError("submit", tx.TransactionID, err)
+	}
+
+	request, err := c.contract.commitStatusRequest(tx.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Commit{TransactionID: tx.TransactionID, bytes: request}, nil
+}
+
+// NewSignedCommit reassembles a commit status request from its serialized form and an offline signature
+func (c *Client) NewSignedCommit(commitBytes []byte, signature []byte) (*Commit, error) {
+	request := &gateway.CommitStatusRequest{}
+	if err := proto.Unmarshal(commitBytes, request); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal commit status request: %v", err)
+	}
+
+	return &Commit{
+		TransactionID: request.GetTransactionId(),
+		bytes:         commitBytes,
+		signature:     signature,
+	}, nil
+}
+
+// CommitStatus waits for the transaction to commit and fails unless it was valid
+func (c *Client) CommitStatus(ctx context.Context, commit *Commit) (*SubmitResult, error) {
+	if len(commit.signature) == 0 {
+		return nil, fmt.Errorf("commit status request for %s is not signed", commit.TransactionID)
+	}
+
+	var response *gateway.CommitStatusResponse
+	err := c.contract.retry.do(ctx, func() (err error) {
+		response, err = c.contract.signedCommitStatus(ctx, commit.bytes, commit.signature)
+		return err
+	})
+	if err != nil {
+		return nil, gatewayError("commit status", commit.TransactionID, err)
+	}
+
+	result := &SubmitResult{
+		TransactionID: commit.TransactionID,
+		Finality:      FinalityCommitted,
+		BlockNumber:   response.BlockNumber,
+		Status:        response.Result.String(),
+	}
+	if response.Result != peer.TxValidationCode_VALID {
+		return result, commitError(commit.TransactionID, response.Result)
+	}
+
+	return result, nil
+}
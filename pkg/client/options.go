@@ -0,0 +1,97 @@
+package client
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithRetryPolicy sets how transient gateway failures are retried. DefaultRetryPolicy is used otherwise.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.contract.retry = policy
+	}
+}
+
+// WithConflictRetries rebuilds and resubmits a transaction up to retries times when it fails
+// validation with an MVCC or phantom read conflict, waiting between attempts as the retry policy
+// does. Only submissions waiting for FinalityCommitted see the validation code. Retries keep the
+// submission idempotent: each has a transaction ID derived from the log IDs and the attempt.
+func WithConflictRetries(retries int) Option {
+	return func(c *Client) {
+		c.contract.conflictRetries = retries
+	}
+}
+
+// WithFinality sets the default finality that submissions wait for. FinalityCommitted is used otherwise.
+func WithFinality(finality Finality) Option {
+	return func(c *Client) {
+		c.finality = finality
+	}
+}
+
+// WithEndorsingOrganizations restricts endorsement of submitted transactions to peers of the given MSP IDs.
+// By default the gateway chooses endorsers that satisfy the chaincode endorsement policy.
+func WithEndorsingOrganizations(mspIDs ...string) Option {
+	return func(c *Client) {
+		c.contract.endorsingOrgs = mspIDs
+	}
+}
+
+// WithPrivateCollection sets the policy choosing the private data collection each private log is
+// written to. By default private logs go to the implicit collection of the client's organization.
+func WithPrivateCollection(selector CollectionSelector) Option {
+	return func(c *Client) {
+		c.collectionSelector = selector
+	}
+}
+
+// WithValidator adds a check run against every log before it is submitted, after the chaincode's own rules
+func WithValidator(validator Validator) Option {
+	return func(c *Client) {
+		c.validators = append(c.validators, validator)
+	}
+}
+
+// WithProcessor adds a stage run against every log before validation and submission.
+// Processors run in the order they are added.
+func WithProcessor(processor Processor) Option {
+	return func(c *Client) {
+		c.processors = append(c.processors, processor)
+	}
+}
+
+// WithReceiptStore records a receipt of every successful submission in store
+func WithReceiptStore(store ReceiptStore) Option {
+	return func(c *Client) {
+		c.receipts = store
+	}
+}
+
+// WithDeadLetterQueue records logs whose submission fails permanently, for example by failing
+// validation or the endorsement policy, in queue. Private logs are never written to the queue.
+func WithDeadLetterQueue(queue *DeadLetterQueue) Option {
+	return func(c *Client) {
+		c.deadLetters = queue
+	}
+}
+
+// WithRateLimiter holds every submission until limiter grants a token per log, so the client
+// never submits faster than the limiter's rate. Callers block while they wait, which passes
+// backpressure on to them.
+func WithRateLimiter(limiter *RateLimiter) Option {
+	return func(c *Client) {
+		c.limiter = limiter
+	}
+}
+
+// WithIDGenerator sets how CreateLogAutoID assigns IDs to logs submitted without one. UUIDv7 is used otherwise.
+func WithIDGenerator(generator IDGenerator) Option {
+	return func(c *Client) {
+		c.idGenerator = generator
+	}
+}
+
+// WithMetrics records submission counts and latencies in metrics
+func WithMetrics(metrics *Metrics) Option {
+	return func(c *Client) {
+		c.contract.metrics = metrics
+	}
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"errors"
+)
+
+// Processor transforms a log before it is validated and submitted. Returning an error stops the
+// submission, so nothing reaches the ledger.
+type Processor func(log LogEvent) (LogEvent, error)
+
+// prepare correlates each log with the trace in ctx, runs the client's processors over it in
+// order and validates the results. Logs dropped by sampling are left out; ErrSampledOut is
+// returned when every log is dropped.
+func (c *Client) prepare(ctx context.Context, logs ...LogEvent) ([]LogEvent, error) {
+	prepared := make([]LogEvent, 0, len(logs))
+	for _, log := range logs {
+		log, err := c.process(correlate(ctx, log))
+		if errors.Is(err, ErrSampledOut) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		prepared = append(prepared, log)
+	}
+
+	if len(prepared) == 0 && len(logs) > 0 {
+		return nil, ErrSampledOut
+	}
+
+	if err := c.validate(prepared...); err != nil {
+		return nil, err
+	}
+
+	return prepared, nil
+}
+
+func (c *Client) process(log LogEvent) (LogEvent, error) {
+	for _, process := range c.processors {
+		var err error
+		if log, err = process(log); err != nil {
+			return log, err
+		}
+	}
+
+	return log, nil
+}
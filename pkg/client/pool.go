@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go/gateway"
+	"google.golang.org/grpc"
+)
+
+// Health tracking defaults for a gatewayPool
+const (
+	defaultPeerCooldown    = 10 * time.Second
+	defaultMaxPeerCooldown = 2 * time.Minute
+)
+
+// gatewayPool spreads gateway calls across several gateway peers. Calls go round-robin to
+// the healthy peers; a peer that cannot be reached is skipped for a cooldown that doubles
+// with each consecutive failure, and the call fails over to the next peer. Endorsing peers
+// themselves are still selected by each gateway peer through service discovery.
+type gatewayPool struct {
+	mu    sync.Mutex
+	peers []*pooledPeer
+	next  int
+}
+
+type pooledPeer struct {
+	client         gateway.GatewayClient
+	failures       int
+	unhealthyUntil time.Time
+}
+
+var _ gateway.GatewayClient = (*gatewayPool)(nil)
+
+func newGatewayPool(clients []gateway.GatewayClient) *gatewayPool {
+	pool := &gatewayPool{}
+	for _, client := range clients {
+		pool.peers = append(pool.peers, &pooledPeer{client: client})
+	}
+
+	return pool
+}
+
+// candidates returns every peer in the order they should be tried: healthy peers starting
+// from the round-robin position, then unhealthy peers soonest-recovering first
+func (p *gatewayPool) candidates() []*pooledPeer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var healthy, unhealthy []*pooledPeer
+	for i := range p.peers {
+		peer := p.peers[(p.next+i)%len(p.peers)]
+		if now.Before(peer.unhealthyUntil) {
+			unhealthy = append(unhealthy, peer)
+		} else {
+			healthy = append(healthy, peer)
+		}
+	}
+	p.next = (p.next + 1) % len(p.peers)
+
+	sort.SliceStable(unhealthy, func(i, j int) bool {
+		return unhealthy[i].unhealthyUntil.Before(unhealthy[j].unhealthyUntil)
+	})
+
+	return append(healthy, unhealthy...)
+}
+
+func (p *gatewayPool) markHealthy(peer *pooledPeer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	peer.failures = 0
+	peer.unhealthyUntil = time.Time{}
+}
+
+func (p *gatewayPool) markUnhealthy(peer *pooledPeer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cooldown := defaultPeerCooldown << peer.failures
+	if cooldown > defaultMaxPeerCooldown || cooldown <= 0 {
+		cooldown = defaultMaxPeerCooldown
+	}
+	peer.failures++
+	peer.unhealthyUntil = time.Now().Add(cooldown)
+}
+
+// invoke calls fn against each candidate peer until one can be reached
+func (p *gatewayPool) invoke(fn func(gateway.GatewayClient) error) error {
+	var err error
+	for _, peer := range p.candidates() {
+		err = fn(peer.client)
+		if err == nil || !isUnreachable(err) {
+			p.markHealthy(peer)
+			return err
+		}
+		p.markUnhealthy(peer)
+	}
+
+	return err
+}
+
+func (p *gatewayPool) Endorse(ctx context.Context, in *gateway.EndorseRequest, opts ...grpc.CallOption) (*gateway.EndorseResponse, error) {
+	var response *gateway.EndorseResponse
+	err := p.invoke(func(client gateway.GatewayClient) (err error) {
+		response, err = client.Endorse(ctx, in, opts...)
+		return err
+	})
+	return response, err
+}
+
+func (p *gatewayPool) Submit(ctx context.Context, in *gateway.SubmitRequest, opts ...grpc.CallOption) (*gateway.SubmitResponse, error) {
+	var response *gateway.SubmitResponse
+	err := p.invoke(func(client gateway.GatewayClient) (err error) {
+		response, err = client.Submit(ctx, in, opts...)
+		return err
+	})
+	return response, err
+}
+
+func (p *gatewayPool) CommitStatus(ctx context.Context, in *gateway.SignedCommitStatusRequest, opts ...grpc.CallOption) (*gateway.CommitStatusResponse, error) {
+	var response *gateway.CommitStatusResponse
+	err := p.invoke(func(client gateway.GatewayClient) (err error) {
+		response, err = client.CommitStatus(ctx, in, opts...)
+		return err
+	})
+	return response, err
+}
+
+func (p *gatewayPool) Evaluate(ctx context.Context, in *gateway.EvaluateRequest, opts ...grpc.CallOption) (*gateway.EvaluateResponse, error) {
+	var response *gateway.EvaluateResponse
+	err := p.invoke(func(client gateway.GatewayClient) (err error) {
+		response, err = client.Evaluate(ctx, in, opts...)
+		return err
+	})
+	return response, err
+}
+
+func (p *gatewayPool) ChaincodeEvents(ctx context.Context, in *gateway.SignedChaincodeEventsRequest, opts ...grpc.CallOption) (gateway.Gateway_ChaincodeEventsClient, error) {
+	var stream gateway.Gateway_ChaincodeEventsClient
+	err := p.invoke(func(client gateway.GatewayClient) (err error) {
+		stream, err = client.ChaincodeEvents(ctx, in, opts...)
+		return err
+	})
+	return stream, err
+}
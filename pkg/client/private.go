@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// privateLogTransientKey is the transient map entry the chaincode reads private log fields from
+const privateLogTransientKey = "privateLog"
+
+// CollectionSelector chooses the private data collection a private log is written to
+type CollectionSelector func(log LogEvent) string
+
+// ImplicitCollection returns the name of an organization's implicit private data collection,
+// which exists on every channel without any collection configuration
+func ImplicitCollection(mspID string) string {
+	return "_implicit_org_" + mspID
+}
+
+// CreatePrivateLog submits a log whose Description and Metadata are stored only in a private
+// data collection. Those fields travel in the proposal's transient data, so they are never
+// part of the public proposal payload or the ordered transaction.
+func (c *Client) CreatePrivateLog(ctx context.Context, log LogEvent, opts ...SubmitOption) (*SubmitResult, error) {
+	options := c.submitOptions("", opts)
+	prepared, err := c.prepare(ctx, log)
+	if err != nil {
+		return nil, err
+	}
+	log = prepared[0]
+	if err := c.limiter.wait(ctx, 1); err != nil {
+		return nil, err
+	}
+
+	collection := c.privateCollection(log)
+	if collection == "" {
+		return nil, fmt.Errorf("no private data collection selected for log %s", log.ID)
+	}
+
+	detailsJSON, err := json.Marshal(struct {
+		Description string `json:"description"`
+		Metadata    string `json:"metadata,omitempty"`
+	}{log.Description, log.Metadata})
+	if err != nil {
+		return nil, err
+	}
+
+	options.idempotencyKey = log.ID
+	options.transient = map[string][]byte{privateLogTransientKey: detailsJSON}
+
+	result, err := c.contract.submitWithOptions(ctx, options, "CreatePrivateLog", collection, log.ID, log.UserID, log.Action, log.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, c.recordReceipts(result, log)
+}
+
+// ReadPrivateLog returns the log with given id including the private fields held in its
+// collection. The gateway peer's organization must be a member of that collection.
+func (c *Client) ReadPrivateLog(ctx context.Context, id string) (*LogEvent, error) {
+	log, err := c.ReadLog(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if log.Collection == "" {
+		return log, nil
+	}
+
+	result, err := c.contract.evaluate(ctx, "ReadPrivateLog", log.Collection, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var details struct {
+		Description string `json:"description"`
+		Metadata    string `json:"metadata"`
+	}
+	if err := json.Unmarshal(result, &details); err != nil {
+		return nil, fmt.Errorf("failed to parse private details of log %s: %v", id, err)
+	}
+
+	log.Description = details.Description
+	log.Metadata = details.Metadata
+	if err := decodeLog(log); err != nil {
+		return nil, err
+	}
+
+	return log, nil
+}
+
+func (c *Client) privateCollection(log LogEvent) string {
+	if c.collectionSelector != nil {
+		return c.collectionSelector(log)
+	}
+
+	return ImplicitCollection(c.contract.id.MSPID)
+}
@@ -0,0 +1,247 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ConnectionProfile is the subset of a Fabric common connection profile used by the client
+type ConnectionProfile struct {
+	Name   string `json:"name" yaml:"name"`
+	Client struct {
+		Organization string `json:"organization" yaml:"organization"`
+		// TLSCerts holds the client certificate presented to peers requiring mutual TLS
+		TLSCerts struct {
+			Client struct {
+				Key  ProfilePEM `json:"key" yaml:"key"`
+				Cert ProfilePEM `json:"cert" yaml:"cert"`
+			} `json:"client" yaml:"client"`
+		} `json:"tlsCerts" yaml:"tlsCerts"`
+	} `json:"client" yaml:"client"`
+	Organizations map[string]ProfileOrganization `json:"organizations" yaml:"organizations"`
+	Peers         map[string]ProfilePeer         `json:"peers" yaml:"peers"`
+}
+
+// ProfileOrganization is an organizations entry of a connection profile
+type ProfileOrganization struct {
+	MSPID string   `json:"mspid" yaml:"mspid"`
+	Peers []string `json:"peers" yaml:"peers"`
+}
+
+// ProfilePEM is PEM data given inline or by file path
+type ProfilePEM struct {
+	PEM  string `json:"pem" yaml:"pem"`
+	Path string `json:"path" yaml:"path"`
+}
+
+// ProfilePeer is a peers entry of a connection profile
+type ProfilePeer struct {
+	URL         string     `json:"url" yaml:"url"`
+	TLSCACerts  ProfilePEM `json:"tlsCACerts" yaml:"tlsCACerts"`
+	GRPCOptions struct {
+		SSLTargetNameOverride string `json:"ssl-target-name-override" yaml:"ssl-target-name-override"`
+		HostnameOverride      string `json:"hostnameOverride" yaml:"hostnameOverride"`
+		// The remaining options use the names and millisecond units of the Node.js SDK
+		KeepaliveTimeMS             int64       `json:"grpc.keepalive_time_ms" yaml:"grpc.keepalive_time_ms"`
+		KeepaliveTimeoutMS          int64       `json:"grpc.keepalive_timeout_ms" yaml:"grpc.keepalive_timeout_ms"`
+		KeepalivePermitWithoutCalls interface{} `json:"grpc.keepalive_permit_without_calls" yaml:"grpc.keepalive_permit_without_calls"`
+		MaxReceiveMessageLength     int         `json:"grpc.max_receive_message_length" yaml:"grpc.max_receive_message_length"`
+		MaxSendMessageLength        int         `json:"grpc.max_send_message_length" yaml:"grpc.max_send_message_length"`
+		WaitForReadyTimeoutMS       int64       `json:"grpc-wait-for-ready-timeout" yaml:"grpc-wait-for-ready-timeout"`
+	} `json:"grpcOptions" yaml:"grpcOptions"`
+}
+
+// Environment variables that override connection profile settings. The names match those read by the backend.
+const (
+	EnvConnectionProfilePath = "CONNECTION_PROFILE_PATH"
+	EnvOrganization          = "ORG"
+	EnvMSPID                 = "ORG_MSP"
+	EnvChannelName           = "CHANNEL_NAME"
+	EnvChaincodeName         = "CHAINCODE_NAME"
+	EnvPeerEndpoint          = "PEER_ENDPOINT"
+	EnvPeerHostAlias         = "PEER_HOST_ALIAS"
+	EnvTLSCertPath           = "TLS_CERT_PATH"
+	EnvCertPath              = "CERT_PATH"
+	EnvKeyPath               = "KEY_PATH"
+	EnvWalletPath            = "WALLET_PATH"
+	EnvIdentityLabel         = "IDENTITY_LABEL"
+)
+
+// LoadConnectionProfile reads a YAML or JSON connection profile
+func LoadConnectionProfile(path string) (*ConnectionProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read connection profile: %v", err)
+	}
+
+	var profile ConnectionProfile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &profile)
+	default:
+		err = json.Unmarshal(data, &profile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse connection profile %s: %v", path, err)
+	}
+
+	return &profile, nil
+}
+
+// Config returns the client configuration for org's section of the profile, or for the
+// profile's client organization when org is empty. Identity paths are not part of a
+// connection profile and must be filled in separately.
+func (p *ConnectionProfile) Config(org string) (Config, error) {
+	if org == "" {
+		org = p.Client.Organization
+	}
+
+	organization, ok := p.Organizations[org]
+	if !ok {
+		return Config{}, fmt.Errorf("organization %s not found in connection profile", org)
+	}
+
+	cfg := Config{MSPID: organization.MSPID}
+	for _, name := range organization.Peers {
+		peer, ok := p.Peers[name]
+		if !ok {
+			return Config{}, fmt.Errorf("peer %s of organization %s not found in connection profile", name, org)
+		}
+
+		peerConfig, err := peer.config(name)
+		if err != nil {
+			return Config{}, err
+		}
+		cfg.Peers = append(cfg.Peers, peerConfig)
+	}
+
+	tlsClient := p.Client.TLSCerts.Client
+	if tlsClient.Cert.PEM != "" || tlsClient.Cert.Path != "" {
+		cfg.TLSClientCert = &TLSClientCertificate{
+			CertPEM:  []byte(tlsClient.Cert.PEM),
+			KeyPEM:   []byte(tlsClient.Key.PEM),
+			CertPath: tlsClient.Cert.Path,
+			KeyPath:  tlsClient.Key.Path,
+		}
+	}
+
+	return cfg, nil
+}
+
+// OrganizationNames returns the names of the organizations defined in the profile
+func (p *ConnectionProfile) OrganizationNames() []string {
+	var names []string
+	for name := range p.Organizations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (p ProfilePeer) config(name string) (PeerConfig, error) {
+	endpoint, err := url.Parse(p.URL)
+	if err != nil || endpoint.Host == "" {
+		return PeerConfig{}, fmt.Errorf("invalid url %q for peer %s", p.URL, name)
+	}
+
+	peer := PeerConfig{
+		Endpoint:           endpoint.Host,
+		ServerNameOverride: p.GRPCOptions.SSLTargetNameOverride,
+	}
+	if peer.ServerNameOverride == "" {
+		peer.ServerNameOverride = p.GRPCOptions.HostnameOverride
+	}
+
+	if endpoint.Scheme == "grpcs" {
+		peer.TLSCACert = []byte(p.TLSCACerts.PEM)
+		peer.TLSCACertPath = p.TLSCACerts.Path
+	}
+
+	options := p.GRPCOptions
+	grpcOptions := GRPCOptions{
+		KeepaliveTime:                time.Duration(options.KeepaliveTimeMS) * time.Millisecond,
+		KeepaliveTimeout:             time.Duration(options.KeepaliveTimeoutMS) * time.Millisecond,
+		KeepalivePermitWithoutStream: truthy(options.KeepalivePermitWithoutCalls),
+		MaxRecvMessageSize:           options.MaxReceiveMessageLength,
+		MaxSendMessageSize:           options.MaxSendMessageLength,
+		DialTimeout:                  time.Duration(options.WaitForReadyTimeoutMS) * time.Millisecond,
+	}
+	if grpcOptions != (GRPCOptions{}) {
+		peer.GRPC = &grpcOptions
+	}
+
+	return peer, nil
+}
+
+// ApplyEnv overrides cfg with any of the Env* variables that are set
+func ApplyEnv(cfg *Config) {
+	override := func(field *string, name string) {
+		if value, ok := os.LookupEnv(name); ok && value != "" {
+			*field = value
+		}
+	}
+
+	override(&cfg.MSPID, EnvMSPID)
+	override(&cfg.ChannelName, EnvChannelName)
+	override(&cfg.ChaincodeName, EnvChaincodeName)
+	override(&cfg.PeerEndpoint, EnvPeerEndpoint)
+	override(&cfg.ServerNameOverride, EnvPeerHostAlias)
+	override(&cfg.TLSCACertPath, EnvTLSCertPath)
+	override(&cfg.CertPath, EnvCertPath)
+	override(&cfg.KeyPath, EnvKeyPath)
+	override(&cfg.WalletPath, EnvWalletPath)
+	override(&cfg.IdentityLabel, EnvIdentityLabel)
+}
+
+// LoadConfig builds a Config from the connection profile at profilePath for org, then applies
+// environment overrides. When profilePath is empty CONNECTION_PROFILE_PATH is used, and when
+// neither is set the Config comes from the environment alone. When org is empty ORG is used,
+// falling back to the profile's client organization.
+func LoadConfig(profilePath string, org string) (Config, error) {
+	if profilePath == "" {
+		profilePath = os.Getenv(EnvConnectionProfilePath)
+	}
+	if org == "" {
+		org = os.Getenv(EnvOrganization)
+	}
+
+	var cfg Config
+	if profilePath != "" {
+		profile, err := LoadConnectionProfile(profilePath)
+		if err != nil {
+			return Config{}, err
+		}
+
+		cfg, err = profile.Config(org)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+
+	ApplyEnv(&cfg)
+
+	return cfg, nil
+}
+
+// truthy reports whether a profile flag is set, given as a boolean or, as the Node.js SDK writes it, as 1
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case int:
+		return v != 0
+	case float64:
+		return v != 0
+	default:
+		return false
+	}
+}
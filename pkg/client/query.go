@@ -0,0 +1,267 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// ErrInvalidQuery is returned when a LogQuery cannot be compiled
+var ErrInvalidQuery = errors.New("invalid log query")
+
+// defaultQueryPageSize is the page size used by LogQuery.Run
+const defaultQueryPageSize = 100
+
+// severityKey is the metadata key holding a log's severity, as recorded by SlogHandler and the adapters
+const severityKey = "level"
+
+// tagsKey is the metadata key holding a log's tags, an array of strings
+const tagsKey = "tags"
+
+// LogQuery builds a LogFilter fluently:
+//
+//	logs, err := client.Query().User("u1").Action("DELETE").Between(t1, t2).Limit(100).Run(ctx, c)
+//
+// The chaincode has no severity or tag fields, so Severity and Tag are matched on the client
+// against the metadata level recorded by SlogHandler and the metadata tags; logs without them
+// never match.
+type LogQuery struct {
+	filter     LogFilter
+	severityOp string
+	severity   slog.Level
+	tag        string
+	limit      int
+	err        error
+}
+
+// Query returns an empty query matching every log
+func Query() *LogQuery {
+	return &LogQuery{}
+}
+
+// User matches logs recorded for userID
+func (q *LogQuery) User(userID string) *LogQuery {
+	q.filter.UserID = userID
+	return q
+}
+
+// Action matches logs with the given action
+func (q *LogQuery) Action(action string) *LogQuery {
+	q.filter.Action = action
+	return q
+}
+
+// Resource matches logs about resource
+func (q *LogQuery) Resource(resource string) *LogQuery {
+	q.filter.Resource = resource
+	return q
+}
+
+// Since matches logs recorded at or after t
+func (q *LogQuery) Since(t time.Time) *LogQuery {
+	q.filter.StartTime = t.UTC().Format(time.RFC3339)
+	return q
+}
+
+// Until matches logs recorded at or before t
+func (q *LogQuery) Until(t time.Time) *LogQuery {
+	q.filter.EndTime = t.UTC().Format(time.RFC3339)
+	return q
+}
+
+// Between matches logs recorded from start to end inclusive
+func (q *LogQuery) Between(start, end time.Time) *LogQuery {
+	if end.Before(start) {
+		q.fail("end %s is before start %s", end, start)
+	}
+	return q.Since(start).Until(end)
+}
+
+// Severity matches logs whose metadata level compares to level with op, one of
+// ==, !=, <, <=, > or >=. Levels are slog names such as DEBUG, INFO, WARN and ERROR;
+// TRACE, WARNING, DPANIC, PANIC and FATAL from other logging libraries are also understood.
+func (q *LogQuery) Severity(op string, level string) *LogQuery {
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		q.fail("unsupported severity operator %q", op)
+		return q
+	}
+
+	parsed, ok := ParseSeverity(level)
+	if !ok {
+		q.fail("unknown severity %q", level)
+		return q
+	}
+
+	q.severityOp = op
+	q.severity = parsed
+	return q
+}
+
+// Tag matches logs whose metadata tags include tag
+func (q *LogQuery) Tag(tag string) *LogQuery {
+	q.tag = tag
+	return q
+}
+
+// Limit returns at most n logs from Run; zero means no limit
+func (q *LogQuery) Limit(n int) *LogQuery {
+	if n < 0 {
+		q.fail("negative limit %d", n)
+		return q
+	}
+	q.limit = n
+	return q
+}
+
+// Filter compiles the query to the chaincode's structured filter. Severity, Tag and Limit are
+// not part of the filter; Run applies them, and Matches applies Severity and Tag.
+func (q *LogQuery) Filter() (LogFilter, error) {
+	if q.err != nil {
+		return LogFilter{}, q.err
+	}
+	return q.filter, nil
+}
+
+// Run executes the query against c, following pages until the limit is reached
+func (q *LogQuery) Run(ctx context.Context, c *Client) ([]*LogEvent, error) {
+	filter, err := q.Filter()
+	if err != nil {
+		return nil, err
+	}
+
+	pageSize := int32(defaultQueryPageSize)
+	if q.limit > 0 && q.limit < defaultQueryPageSize && !q.FiltersOnClient() {
+		pageSize = int32(q.limit)
+	}
+
+	var logs []*LogEvent
+	it := c.IterateLogs(filter, pageSize)
+	for q.limit == 0 || len(logs) < q.limit {
+		log, err := it.Next(ctx)
+		if errors.Is(err, ErrIteratorDone) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if q.Matches(log) {
+			logs = append(logs, log)
+		}
+	}
+
+	return logs, nil
+}
+
+// FiltersOnClient reports whether the query has criteria Filter leaves out, so pages of the
+// chaincode's results may hold logs Matches rejects
+func (q *LogQuery) FiltersOnClient() bool {
+	return q.severityOp != "" || q.tag != ""
+}
+
+// Matches applies the criteria Filter leaves out, Severity and Tag, to log
+func (q *LogQuery) Matches(log *LogEvent) bool {
+	if q.tag != "" && !hasTag(*log, q.tag) {
+		return false
+	}
+	if q.severityOp == "" {
+		return true
+	}
+
+	level, ok := LogSeverity(*log)
+	if !ok {
+		return false
+	}
+
+	switch q.severityOp {
+	case "==":
+		return level == q.severity
+	case "!=":
+		return level != q.severity
+	case "<":
+		return level < q.severity
+	case "<=":
+		return level <= q.severity
+	case ">":
+		return level > q.severity
+	default:
+		return level >= q.severity
+	}
+}
+
+// fail records the first error found while building the query
+func (q *LogQuery) fail(format string, args ...interface{}) {
+	if q.err == nil {
+		q.err = fmt.Errorf("%w: %s", ErrInvalidQuery, fmt.Sprintf(format, args...))
+	}
+}
+
+// extraSeverities places level names used by logrus, zap, syslog and the Windows event log on
+// the slog scale
+var extraSeverities = map[string]slog.Level{
+	"TRACE":       slog.LevelDebug - 4,
+	"VERBOSE":     slog.LevelDebug,
+	"INFORMATION": slog.LevelInfo,
+	"WARNING":     slog.LevelWarn,
+	"ERR":         slog.LevelError,
+	"DPANIC":      slog.LevelError + 2,
+	"CRIT":        slog.LevelError + 4,
+	"CRITICAL":    slog.LevelError + 4,
+	"PANIC":       slog.LevelError + 4,
+	"FATAL":       slog.LevelError + 4,
+}
+
+// LogSeverity returns the level recorded in log's metadata by SlogHandler or a logging adapter
+func LogSeverity(log LogEvent) (slog.Level, bool) {
+	var metadata map[string]interface{}
+	if err := json.Unmarshal([]byte(log.Metadata), &metadata); err != nil {
+		return 0, false
+	}
+	name, _ := metadata[severityKey].(string)
+	return ParseSeverity(name)
+}
+
+// LogTags returns the tags recorded in log's metadata
+func LogTags(log LogEvent) []string {
+	var metadata map[string]interface{}
+	if err := json.Unmarshal([]byte(log.Metadata), &metadata); err != nil {
+		return nil
+	}
+	values, _ := metadata[tagsKey].([]interface{})
+
+	var tags []string
+	for _, value := range values {
+		if tag, ok := value.(string); ok {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func hasTag(log LogEvent, tag string) bool {
+	for _, t := range LogTags(log) {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseSeverity parses a level name such as "WARN" or "INFO+2", in any case, including the
+// names of other logging systems such as TRACE, WARNING, CRITICAL and FATAL
+func ParseSeverity(name string) (slog.Level, bool) {
+	if level, ok := extraSeverities[strings.ToUpper(name)]; ok {
+		return level, true
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, false
+	}
+	return level, true
+}
@@ -0,0 +1,129 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrBackpressure is returned to producers when a submitter cannot accept more logs because
+// submissions are being held back, for example by a RateLimiter. The log was not accepted and
+// the producer should slow down or retry later.
+var ErrBackpressure = errors.New("submission backpressure: too many logs waiting")
+
+// RateLimiter is a token bucket limiting how many logs per second are submitted, so one
+// application cannot saturate an ordering service shared with other workloads. A batch takes
+// one token per log.
+type RateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a limiter allowing eventsPerSecond logs per second on average and
+// bursts of up to burst logs. The bucket starts full; with a rate of zero it is never refilled.
+func NewRateLimiter(eventsPerSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &RateLimiter{
+		rate:   eventsPerSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Allow takes n tokens if they are available now and reports whether it did
+func (l *RateLimiter) Allow(n int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(time.Now())
+	if l.tokens < float64(n) {
+		return false
+	}
+	l.tokens -= float64(n)
+	return true
+}
+
+// Wait blocks until n tokens have been taken or ctx is done. A batch larger than the burst
+// borrows against future tokens, so it waits as long as the rate requires rather than forever.
+func (l *RateLimiter) Wait(ctx context.Context, n int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.refill(time.Now())
+	l.tokens -= float64(n)
+	deficit := -l.tokens
+	l.mu.Unlock()
+
+	if deficit <= 0 {
+		return nil
+	}
+
+	var expired <-chan time.Time
+	if l.rate > 0 {
+		timer := time.NewTimer(time.Duration(deficit / l.rate * float64(time.Second)))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case <-expired:
+		return nil
+	case <-ctx.Done():
+		// The tokens were never used, so they go back to the bucket
+		l.mu.Lock()
+		l.tokens += float64(n)
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Delay returns how long a caller would currently wait for n tokens, or math.MaxInt64 when the
+// limiter has no rate to refill them
+func (l *RateLimiter) Delay(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(time.Now())
+	deficit := float64(n) - l.tokens
+	switch {
+	case deficit <= 0:
+		return 0
+	case l.rate <= 0:
+		return math.MaxInt64
+	default:
+		return time.Duration(deficit / l.rate * float64(time.Second))
+	}
+}
+
+// refill adds the tokens earned since the last refill. The caller holds mu.
+func (l *RateLimiter) refill(now time.Time) {
+	elapsed := now.Sub(l.last).Seconds()
+	l.last = now
+	if elapsed <= 0 {
+		return
+	}
+
+	l.tokens += elapsed * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+}
+
+// wait is RateLimiter.Wait for a limiter that may be nil, which never waits
+func (l *RateLimiter) wait(ctx context.Context, n int) error {
+	if l == nil {
+		return nil
+	}
+	return l.Wait(ctx, n)
+}
@@ -0,0 +1,167 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrReceiptNotFound is returned when no receipt is stored for a log
+var ErrReceiptNotFound = errors.New("receipt not found")
+
+// Receipt records where a submitted log landed on the ledger, so an application can later
+// reference the on-chain proof of any event it logged
+type Receipt struct {
+	LogID         string    `json:"logId"`
+	TransactionID string    `json:"transactionId"`
+	Finality      string    `json:"finality"`
+	BlockNumber   uint64    `json:"blockNumber,omitempty"`
+	Status        string    `json:"status,omitempty"`
+	SubmittedAt   time.Time `json:"submittedAt"`
+}
+
+// ReceiptStore persists receipts of submitted logs. The client does not close its store.
+type ReceiptStore interface {
+	Put(receipts ...Receipt) error
+	Get(logID string) (*Receipt, error)
+}
+
+// FileReceiptStore is a ReceiptStore backed by an append-only NDJSON file. Receipts are indexed
+// in memory when the store is opened; a later receipt for a log replaces an earlier one.
+type FileReceiptStore struct {
+	mu       sync.RWMutex
+	file     *os.File
+	receipts map[string]Receipt
+}
+
+// NewFileReceiptStore opens or creates the receipt file at path
+func NewFileReceiptStore(path string) (*FileReceiptStore, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open receipt store: %v", err)
+	}
+
+	receipts := map[string]Receipt{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var receipt Receipt
+		if err := json.Unmarshal(scanner.Bytes(), &receipt); err != nil {
+			// A torn final line from a crash; the receipt is rewritten on the next submission
+			continue
+		}
+		receipts[receipt.LogID] = receipt
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read receipt store: %v", err)
+	}
+
+	return &FileReceiptStore{file: file, receipts: receipts}, nil
+}
+
+// Put appends receipts to the file and syncs it
+func (s *FileReceiptStore) Put(receipts ...Receipt) error {
+	var lines []byte
+	for _, receipt := range receipts {
+		receiptJSON, err := json.Marshal(receipt)
+		if err != nil {
+			return err
+		}
+		lines = append(append(lines, receiptJSON...), '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.file.Write(lines); err != nil {
+		return fmt.Errorf("failed to write receipt: %v", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync receipt store: %v", err)
+	}
+	for _, receipt := range receipts {
+		s.receipts[receipt.LogID] = receipt
+	}
+
+	return nil
+}
+
+// Get returns the receipt for the log with given id
+func (s *FileReceiptStore) Get(logID string) (*Receipt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	receipt, ok := s.receipts[logID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrReceiptNotFound, logID)
+	}
+
+	return &receipt, nil
+}
+
+// List returns every stored receipt ordered by log ID
+func (s *FileReceiptStore) List() []Receipt {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	receipts := make([]Receipt, 0, len(s.receipts))
+	for _, receipt := range s.receipts {
+		receipts = append(receipts, receipt)
+	}
+	sort.Slice(receipts, func(i, j int) bool { return receipts[i].LogID < receipts[j].LogID })
+
+	return receipts
+}
+
+// Close closes the receipt file
+func (s *FileReceiptStore) Close() error {
+	return s.file.Close()
+}
+
+// Receipt returns the stored receipt for the log with given id. The client must have been
+// created with WithReceiptStore.
+func (c *Client) Receipt(logID string) (*Receipt, error) {
+	if c.receipts == nil {
+		return nil, fmt.Errorf("no receipt store configured")
+	}
+
+	return c.receipts.Get(logID)
+}
+
+// recordReceipts stores a receipt of result for each of the submitted logs. The logs are already
+// on their way to the ledger, so a failure is reported alongside the result rather than instead of it.
+// Any cached knowledge that the logs do not exist is forgotten first.
+func (c *Client) recordReceipts(result *SubmitResult, logs ...LogEvent) error {
+	for _, log := range logs {
+		c.cache.Invalidate(log.ID)
+	}
+
+	if c.receipts == nil {
+		return nil
+	}
+
+	submittedAt := time.Now().UTC()
+	receipts := make([]Receipt, len(logs))
+	for i, log := range logs {
+		receipts[i] = Receipt{
+			LogID:         log.ID,
+			TransactionID: result.TransactionID,
+			Finality:      result.Finality.String(),
+			BlockNumber:   result.BlockNumber,
+			Status:        result.Status,
+			SubmittedAt:   submittedAt,
+		}
+	}
+
+	if err := c.receipts.Put(receipts...); err != nil {
+		return fmt.Errorf("transaction %s was submitted but its receipt was not stored: %v", result.TransactionID, err)
+	}
+
+	return nil
+}
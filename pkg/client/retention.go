@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// RetentionPolicy is how long logs are kept, as recorded on the ledger for retention services
+type RetentionPolicy struct {
+	// MaxAgeDays is the number of days logs are kept
+	MaxAgeDays int `json:"maxAgeDays"`
+	// Archive asks for logs to be archived before they are pruned
+	Archive bool `json:"archive"`
+	// UpdatedAt and UpdatedBy, the MSP ID of the identity that set the policy, are recorded by
+	// the chaincode
+	UpdatedAt string `json:"updatedAt,omitempty"`
+	UpdatedBy string `json:"updatedBy,omitempty"`
+}
+
+// Cutoff returns the time before which logs are past the policy at now: midnight UTC,
+// MaxAgeDays days before the day of now
+func (p *RetentionPolicy) Cutoff(now time.Time) time.Time {
+	return now.UTC().Truncate(24*time.Hour).AddDate(0, 0, -p.MaxAgeDays)
+}
+
+// GetRetentionPolicy returns the retention policy recorded on the ledger. It fails with
+// ErrNoPolicy when none has been set.
+func (c *Client) GetRetentionPolicy(ctx context.Context) (*RetentionPolicy, error) {
+	result, err := c.contract.evaluate(ctx, "GetRetentionPolicy")
+	if err != nil {
+		return nil, err
+	}
+
+	var policy RetentionPolicy
+	if err := json.Unmarshal(result, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse retention policy: %v", err)
+	}
+
+	return &policy, nil
+}
+
+// SetRetentionPolicy records policy on the ledger, replacing the previous one. The chaincode
+// only accepts policies from identities holding the logging.retention attribute, and fills in
+// UpdatedAt and UpdatedBy.
+func (c *Client) SetRetentionPolicy(ctx context.Context, policy RetentionPolicy) (*SubmitResult, error) {
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.contract.submitWithOptions(ctx, c.submitOptions("", nil), "SetRetentionPolicy", string(policyJSON))
+}
+
+// CheckRetentionPolicy runs SetRetentionPolicy on a peer without submitting the transaction, so
+// the chaincode validates policy and authorizes the caller as it would, but records nothing
+func (c *Client) CheckRetentionPolicy(ctx context.Context, policy RetentionPolicy) error {
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.contract.evaluate(ctx, "SetRetentionPolicy", string(policyJSON))
+	return err
+}
+
+// SettingChange is a field of an on-chain setting that a change sets to another value
+type SettingChange struct {
+	Field string `json:"field"`
+	// From is nil when the setting has never been set
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Changes returns the fields p changes of current, which is nil when no policy has been set.
+// UpdatedAt and UpdatedBy, recorded by the chaincode, are not compared.
+func (p *RetentionPolicy) Changes(current *RetentionPolicy) []SettingChange {
+	changes := []SettingChange{}
+	if current == nil {
+		return append(changes,
+			SettingChange{Field: "maxAgeDays", To: p.MaxAgeDays},
+			SettingChange{Field: "archive", To: p.Archive})
+	}
+	if current.MaxAgeDays != p.MaxAgeDays {
+		changes = append(changes, SettingChange{Field: "maxAgeDays", From: current.MaxAgeDays, To: p.MaxAgeDays})
+	}
+	if current.Archive != p.Archive {
+		changes = append(changes, SettingChange{Field: "archive", From: current.Archive, To: p.Archive})
+	}
+	return changes
+}
+
+// PrunedLog is the tombstone the chaincode keeps for a log deleted by PruneLogs
+type PrunedLog struct {
+	ID string `json:"id"`
+	// Timestamp is the time the log was recorded
+	Timestamp string `json:"timestamp"`
+	// PrunedAt and PrunedBy, the MSP ID of the identity that pruned the log, are recorded by the
+	// chaincode
+	PrunedAt string `json:"prunedAt"`
+	PrunedBy string `json:"prunedBy"`
+	// Before is the cutoff the log was pruned under
+	Before string `json:"before"`
+}
+
+// PrunedLog returns the tombstone of the log with given id. It fails with ErrLogNotFound when
+// the log was never pruned, or was pruned by a chaincode version that kept no tombstones.
+func (c *Client) PrunedLog(ctx context.Context, id string) (*PrunedLog, error) {
+	result, err := c.contract.evaluate(ctx, "GetPrunedLog", id)
+	if err != nil {
+		return nil, err
+	}
+
+	var tombstone PrunedLog
+	if err := json.Unmarshal(result, &tombstone); err != nil {
+		return nil, fmt.Errorf("failed to parse tombstone of log %s: %v", id, err)
+	}
+
+	return &tombstone, nil
+}
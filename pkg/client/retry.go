@@ -0,0 +1,125 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go/gateway"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy controls how gateway calls that fail with a transient error are retried
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first; values below 1 mean a single attempt
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries
+	MaxBackoff time.Duration
+	// Multiplier grows the delay after each retry
+	Multiplier float64
+	// Jitter randomizes each delay by up to this fraction of it, in [0, 1]
+	Jitter float64
+}
+
+// DefaultRetryPolicy retries transient failures with exponential backoff and jitter
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 250 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// NoRetry makes a single attempt at every call
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// do calls fn until it succeeds, fails with a non-retryable error, runs out of attempts or ctx is done
+func (p RetryPolicy) do(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || !isRetryable(err) || attempt >= p.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+
+		if !sleep(ctx, p.Backoff(attempt)) {
+			return err
+		}
+	}
+}
+
+// sleep waits for d and reports whether it did, or returns false as soon as ctx is done
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Backoff returns the delay to wait after the given failed attempt
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(delay)
+}
+
+// isReadConflict reports whether err is a transaction invalidated at commit because a key or
+// range it read was changed by a transaction committed before it
+func isReadConflict(err error) bool {
+	var commitErr *CommitError
+	if !errors.As(err, &commitErr) {
+		return false
+	}
+	return commitErr.Code == peer.TxValidationCode_MVCC_READ_CONFLICT || commitErr.Code == peer.TxValidationCode_PHANTOM_READ_CONFLICT
+}
+
+// isRetryable reports whether err is a transient gRPC failure worth retrying
+func isRetryable(err error) bool {
+	st, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+
+	switch st.Code() {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+		return true
+	case codes.Aborted:
+		// The gateway also reports every failed endorsement as Aborted, with the endorsers'
+		// errors as details. A chaincode rejecting the proposal does the same again.
+		return !hasErrorDetails(st)
+	default:
+		return false
+	}
+}
+
+// hasErrorDetails reports whether st carries the error of at least one peer
+func hasErrorDetails(st *status.Status) bool {
+	for _, detail := range st.Details() {
+		if _, ok := detail.(*gateway.ErrorDetail); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go/gateway"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// endorser is a gateway whose every Endorse call fails with err
+type endorser struct {
+	gateway.GatewayClient
+	err   error
+	calls atomic.Int32
+}
+
+func (e *endorser) Endorse(context.Context, *gateway.EndorseRequest, ...grpc.CallOption) (*gateway.EndorseResponse, error) {
+	e.calls.Add(1)
+	return nil, e.err
+}
+
+func newEndorserClient(err error) (*Client, *endorser) {
+	gw := &endorser{err: err}
+	sign := func([]byte) ([]byte, error) { return []byte("signature"), nil }
+	retry := RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond}
+	return newClient(gw, &Identity{MSPID: "Org1MSP"}, sign, "", "", []Option{WithRetryPolicy(retry)}), gw
+}
+
+func TestEndorsementRetries(t *testing.T) {
+	rejected, err := status.New(codes.Aborted, "failed to endorse transaction, see attached details for more info").WithDetails(&gateway.ErrorDetail{
+		Address: "peer0.org1.example.com:7051",
+		MspId:   "Org1MSP",
+		Message: "chaincode response 500, [LOG_ALREADY_EXISTS] the log l1 already exists",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name      string
+		err       error
+		calls     int32
+		kind      error
+		transient bool
+	}{
+		// A chaincode rejection is final: endorsing it again fails the same way
+		{"chaincode error", rejected.Err(), 1, ErrAlreadyExists, false},
+		{"aborted without details", status.Error(codes.Aborted, "endorsement responses do not match"), 5, nil, true},
+		{"unavailable", status.Error(codes.Unavailable, "connection refused"), 5, nil, true},
+		{"invalid argument", status.Error(codes.InvalidArgument, "bad proposal"), 1, nil, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c, gw := newEndorserClient(tt.err)
+
+			err := c.CreateLog(context.Background(), LogEvent{ID: "l1", UserID: "u1", Action: "LOGIN", Resource: "app"})
+			if got := gw.calls.Load(); got != tt.calls {
+				t.Errorf("endorsed %d times, want %d", got, tt.calls)
+			}
+			if tt.kind != nil && !errors.Is(err, tt.kind) {
+				t.Errorf("CreateLog = %v, want %v", err, tt.kind)
+			}
+			var transient *TransientError
+			if errors.As(err, &transient) != tt.transient {
+				t.Errorf("CreateLog = %v, transient %v, want %v", err, !tt.transient, tt.transient)
+			}
+		})
+	}
+}
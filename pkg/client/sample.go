@@ -0,0 +1,79 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"math"
+)
+
+// ErrSampledOut is returned by a Processor, and by SubmitLog, when a log is dropped by sampling.
+// CreateLog and CreateLogsBatch treat sampled out logs as successfully handled.
+var ErrSampledOut = errors.New("log dropped by sampling")
+
+// correlationIDKey is the metadata field used to keep or drop related logs together
+const correlationIDKey = "correlationId"
+
+// Sampler records only a fraction of logs per action, to control ledger growth from high-volume actions
+type Sampler struct {
+	defaultRate float64
+	rates       map[string]float64
+	key         func(LogEvent) string
+}
+
+// NewSampler returns a Sampler keeping the given fraction, between 0 and 1, of logs for each action in rates
+// and defaultRate of logs for any other action. For example, a default rate of 1 with rates {"VISIT": 0.01}
+// records 1% of VISIT logs and every other log.
+func NewSampler(defaultRate float64, rates map[string]float64) *Sampler {
+	return &Sampler{defaultRate: defaultRate, rates: rates, key: CorrelationID}
+}
+
+// WithKey sets the function returning the sampling key of a log. Logs with the same key are kept or dropped
+// together wherever their actions' rates allow it. CorrelationID is used by default.
+func (s *Sampler) WithKey(key func(LogEvent) string) *Sampler {
+	s.key = key
+	return s
+}
+
+// Process is a Processor, so a Sampler is installed with WithProcessor(sampler.Process)
+func (s *Sampler) Process(log LogEvent) (LogEvent, error) {
+	rate, ok := s.rates[log.Action]
+	if !ok {
+		rate = s.defaultRate
+	}
+
+	if !sampled(s.key(log), rate) {
+		return log, ErrSampledOut
+	}
+
+	return log, nil
+}
+
+// CorrelationID returns the correlationId field of a log's metadata, falling back to the log ID
+func CorrelationID(log LogEvent) string {
+	if log.Metadata != "" {
+		var metadata map[string]interface{}
+		if err := json.Unmarshal([]byte(log.Metadata), &metadata); err == nil {
+			if id, ok := metadata[correlationIDKey].(string); ok && id != "" {
+				return id
+			}
+		}
+	}
+
+	return log.ID
+}
+
+// sampled maps key to a fixed point in [0, 1) and keeps it when that point falls below rate.
+// Because the point depends only on the key, a key kept at one rate is kept at every higher rate.
+func sampled(key string, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return float64(h.Sum64())/math.MaxUint64 < rate
+}
@@ -0,0 +1,140 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrPIIDetected is wrapped by the error returned when a Scrubber rejects a log
+var ErrPIIDetected = errors.New("personally identifiable information detected")
+
+// ScrubAction is what a Scrubber does with a log containing PII
+type ScrubAction int
+
+const (
+	// ScrubMask replaces each match with a [REDACTED:<detector>] marker
+	ScrubMask ScrubAction = iota
+	// ScrubReject fails the submission
+	ScrubReject
+)
+
+// Detector finds one kind of PII
+type Detector struct {
+	Name    string
+	Pattern *regexp.Regexp
+	// Verify, when set, confirms a pattern match to weed out false positives
+	Verify func(match string) bool
+}
+
+// Built-in detectors
+var (
+	EmailDetector = Detector{
+		Name:    "email",
+		Pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	}
+	CreditCardDetector = Detector{
+		Name:    "credit-card",
+		Pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		Verify:  luhnValid,
+	}
+	SSNDetector = Detector{
+		Name:    "ssn",
+		Pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
+	}
+)
+
+// DefaultDetectors are used by a Scrubber created without detectors
+var DefaultDetectors = []Detector{EmailDetector, CreditCardDetector, SSNDetector}
+
+// RegexDetector returns a Detector for a custom pattern
+func RegexDetector(name string, pattern string) (Detector, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Detector{}, fmt.Errorf("failed to compile detector %s: %v", name, err)
+	}
+
+	return Detector{Name: name, Pattern: re}, nil
+}
+
+// Scrubber masks or rejects PII in the free-form fields of a log: Resource, Description and Metadata.
+// ID, UserID and Action are identifiers and are left untouched.
+type Scrubber struct {
+	action    ScrubAction
+	detectors []Detector
+}
+
+// NewScrubber returns a Scrubber applying action to matches of detectors, or DefaultDetectors when none are given
+func NewScrubber(action ScrubAction, detectors ...Detector) *Scrubber {
+	if len(detectors) == 0 {
+		detectors = DefaultDetectors
+	}
+
+	return &Scrubber{action: action, detectors: detectors}
+}
+
+// Process is a Processor, so a Scrubber is installed with WithProcessor(scrubber.Process)
+func (s *Scrubber) Process(log LogEvent) (LogEvent, error) {
+	fields := []struct {
+		name  string
+		value *string
+	}{
+		{"resource", &log.Resource},
+		{"description", &log.Description},
+		{"metadata", &log.Metadata},
+	}
+
+	for _, field := range fields {
+		scrubbed, detector := s.scrub(*field.value)
+		if detector == "" {
+			continue
+		}
+		if s.action == ScrubReject {
+			return log, fmt.Errorf("%w: the %s of log %s matches the %s detector", ErrPIIDetected, field.name, log.ID, detector)
+		}
+		*field.value = scrubbed
+	}
+
+	return log, nil
+}
+
+// scrub masks every detected match in value and returns the name of the first detector that matched
+func (s *Scrubber) scrub(value string) (string, string) {
+	first := ""
+	for _, detector := range s.detectors {
+		detector := detector
+		value = detector.Pattern.ReplaceAllStringFunc(value, func(match string) string {
+			if detector.Verify != nil && !detector.Verify(match) {
+				return match
+			}
+			if first == "" {
+				first = detector.Name
+			}
+			return "[REDACTED:" + detector.Name + "]"
+		})
+	}
+
+	return value, first
+}
+
+// luhnValid reports whether the digits of number pass the Luhn checksum used by card numbers
+func luhnValid(number string) bool {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(number)
+
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+
+	return sum%10 == 0
+}
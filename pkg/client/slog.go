@@ -0,0 +1,231 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Submitter accepts logs for submission. Client, BatchingSubmitter and SpoolingSubmitter all implement it.
+type Submitter interface {
+	CreateLog(ctx context.Context, log LogEvent) error
+}
+
+// Attribute keys the SlogHandler maps to LogEvent fields instead of metadata
+const (
+	SlogIDKey       = "id"
+	SlogUserIDKey   = "userId"
+	SlogActionKey   = "action"
+	SlogResourceKey = "resource"
+)
+
+// SlogHandlerOptions configures a SlogHandler
+type SlogHandlerOptions struct {
+	// Level is the minimum level submitted; slog.LevelInfo is used when nil
+	Level slog.Leveler
+	// UserID and Resource are used for records without userId and resource attributes,
+	// typically the service account and name of the application
+	UserID   string
+	Resource string
+}
+
+// SlogHandler is a log/slog Handler that ships records to the ledger through a Submitter,
+// usually a BatchingSubmitter so logging never waits on a transaction. The record message
+// becomes the description; top-level userId, action, resource and id attributes fill the log fields
+// and every other attribute, with the level and time, is recorded as metadata. Records without
+// an action attribute are recorded with the action LOG_<LEVEL>.
+type SlogHandler struct {
+	submitter Submitter
+	options   SlogHandlerOptions
+
+	attrs  []groupedAttr
+	groups []string
+}
+
+// groupedAttr is an attribute added with WithAttrs, remembering the groups open at the time
+type groupedAttr struct {
+	groups []string
+	attr   slog.Attr
+}
+
+// NewSlogHandler returns a SlogHandler submitting through submitter
+func NewSlogHandler(submitter Submitter, options SlogHandlerOptions) *SlogHandler {
+	if options.Level == nil {
+		options.Level = slog.LevelInfo
+	}
+
+	return &SlogHandler{submitter: submitter, options: options}
+}
+
+// Enabled reports whether records at level are submitted
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.options.Level.Level()
+}
+
+// Handle converts the record to a LogEvent and submits it
+func (h *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	log := LogEvent{
+		UserID:      h.options.UserID,
+		Action:      "LOG_" + record.Level.String(),
+		Resource:    h.options.Resource,
+		Description: record.Message,
+	}
+
+	metadata := map[string]interface{}{
+		"level": record.Level.String(),
+	}
+	if !record.Time.IsZero() {
+		metadata["time"] = record.Time.UTC().Format(time.RFC3339Nano)
+	}
+
+	for _, a := range h.attrs {
+		h.addAttr(&log, metadata, a.groups, a.attr)
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		h.addAttr(&log, metadata, h.groups, attr)
+		return true
+	})
+
+	if log.ID == "" {
+		log.ID = newLogID()
+	}
+	log.Action = actionName(log.Action)
+
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	log.Metadata = string(metadataJSON)
+
+	return h.submitter.CreateLog(ctx, log)
+}
+
+// WithAttrs returns a handler that adds attrs to every record
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append([]groupedAttr(nil), h.attrs...)
+	for _, attr := range attrs {
+		clone.attrs = append(clone.attrs, groupedAttr{groups: h.groups, attr: attr})
+	}
+
+	return &clone
+}
+
+// WithGroup returns a handler that nests subsequent attributes under name in the metadata
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	clone := *h
+	clone.groups = append(append([]string(nil), h.groups...), name)
+
+	return &clone
+}
+
+// addAttr records attr in the log fields when it is a top-level field key, or in metadata under groups
+func (h *SlogHandler) addAttr(log *LogEvent, metadata map[string]interface{}, groups []string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+
+	if len(groups) == 0 && attr.Value.Kind() == slog.KindString {
+		switch attr.Key {
+		case SlogIDKey:
+			log.ID = attr.Value.String()
+			return
+		case SlogUserIDKey:
+			log.UserID = attr.Value.String()
+			return
+		case SlogActionKey:
+			log.Action = attr.Value.String()
+			return
+		case SlogResourceKey:
+			log.Resource = attr.Value.String()
+			return
+		}
+	}
+
+	target := metadata
+	for _, group := range groups {
+		nested, ok := target[group].(map[string]interface{})
+		if !ok {
+			nested = map[string]interface{}{}
+			target[group] = nested
+		}
+		target = nested
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key == "" {
+			// Inline groups add their attributes at the current level
+			for _, member := range attr.Value.Group() {
+				h.addAttr(log, metadata, groups, member)
+			}
+			return
+		}
+		for _, member := range attr.Value.Group() {
+			h.addAttr(log, metadata, append(append([]string(nil), groups...), attr.Key), member)
+		}
+		return
+	}
+
+	target[attr.Key] = slogValue(attr.Value)
+}
+
+// slogValue converts a resolved, non-group value to a JSON friendly value
+func slogValue(value slog.Value) interface{} {
+	switch value.Kind() {
+	case slog.KindTime:
+		return value.Time().UTC().Format(time.RFC3339Nano)
+	case slog.KindDuration:
+		return value.Duration().String()
+	case slog.KindAny:
+		if err, ok := value.Any().(error); ok {
+			return err.Error()
+		}
+		return value.Any()
+	default:
+		return value.Any()
+	}
+}
+
+// actionName turns a level or free-form action into an action accepted by the chaincode,
+// such as "LOG_WARN" or "USER_LOGIN"
+func actionName(action string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		default:
+			return '_'
+		}
+	}, action)
+
+	if name == "" || name[0] < 'A' || name[0] > 'Z' {
+		name = "LOG_" + name
+	}
+	if len(name) > MaxActionLength {
+		name = name[:MaxActionLength]
+	}
+
+	return name
+}
+
+// newLogID returns a random 128-bit log ID
+func newLogID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		// crypto/rand only fails when the platform has no entropy source
+		panic(err)
+	}
+
+	return hex.EncodeToString(id)
+}
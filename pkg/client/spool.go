@@ -0,0 +1,304 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSpoolRetryInterval is how long a SpoolingSubmitter waits before retrying while the network is unreachable
+const DefaultSpoolRetryInterval = 5 * time.Second
+
+// SpoolOptions configures a SpoolingSubmitter
+type SpoolOptions struct {
+	// Path is the spool file; its read position is kept alongside it in Path + ".offset"
+	Path string
+	// RetryInterval is the wait between attempts while peers or orderers are unreachable
+	RetryInterval time.Duration
+	// OnError is called for logs rejected with a non-transient error; they are dropped from the spool
+	OnError func(log LogEvent, err error)
+	// RateLimiter, if set, paces submissions from the spool; logs wait on disk meanwhile
+	RateLimiter *RateLimiter
+}
+
+// SpoolingSubmitter writes every log to a local spool file before submitting it in the
+// background, so callers never block on the network and no log is lost while peers or
+// orderers are unreachable. Spooled logs are submitted strictly in the order they were
+// added, including those left over from a previous process.
+type SpoolingSubmitter struct {
+	client  LoggingClient
+	options SpoolOptions
+
+	mu     sync.Mutex
+	file   *os.File
+	offset int64
+	closed bool
+
+	// ctx is canceled by Close to abort a submission in progress; the log stays spooled
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	added chan struct{}
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// NewSpoolingSubmitter opens or creates the spool at options.Path and starts draining it through client
+func NewSpoolingSubmitter(client LoggingClient, options SpoolOptions) (*SpoolingSubmitter, error) {
+	if options.Path == "" {
+		return nil, fmt.Errorf("spool path is required")
+	}
+	if options.RetryInterval <= 0 {
+		options.RetryInterval = DefaultSpoolRetryInterval
+	}
+
+	file, err := os.OpenFile(options.Path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool: %v", err)
+	}
+
+	offset, err := readSpoolOffset(options.Path + ".offset")
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// A spool shorter than its offset, as when it was truncated by hand, is read from the start
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if offset > info.Size() {
+		offset = 0
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &SpoolingSubmitter{
+		client:  client,
+		options: options,
+		file:    file,
+		offset:  offset,
+		ctx:     ctx,
+		cancel:  cancel,
+		added:   make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.run()
+
+	return s, nil
+}
+
+// CreateLog durably appends log to the spool; it is submitted once connectivity allows.
+// It never blocks on the network, so ctx is only checked for cancellation.
+func (s *SpoolingSubmitter) CreateLog(ctx context.Context, log LogEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	logJSON, err := json.Marshal(log)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrSubmitterClosed
+	}
+
+	if _, err := s.file.Write(append(logJSON, '\n')); err != nil {
+		return fmt.Errorf("failed to write to spool: %v", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync spool: %v", err)
+	}
+
+	select {
+	case s.added <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Len returns the number of logs in the spool not yet submitted. It reads the pending part of the spool file.
+func (s *SpoolingSubmitter) Len() int {
+	s.mu.Lock()
+	offset := s.offset
+	s.mu.Unlock()
+
+	n := 0
+	reader := bufio.NewReader(io.NewSectionReader(s.file, offset, 1<<62))
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return n
+		}
+		if strings.TrimSpace(line) != "" {
+			n++
+		}
+	}
+}
+
+// Close stops draining the spool, canceling any submission in progress. Logs not yet
+// submitted stay on disk for the next process.
+func (s *SpoolingSubmitter) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	close(s.done)
+	s.cancel()
+	s.wg.Wait()
+
+	return s.file.Close()
+}
+
+func (s *SpoolingSubmitter) run() {
+	defer s.wg.Done()
+
+	for {
+		log, next, err := s.next()
+		if err != nil {
+			// Nothing complete to submit yet; wait for more logs
+			select {
+			case <-s.done:
+				return
+			case <-s.added:
+			}
+			continue
+		}
+
+		if err := s.options.RateLimiter.wait(s.ctx, 1); err != nil {
+			// Closed while waiting; the log stays spooled
+			return
+		}
+
+		err = s.client.CreateLog(s.ctx, *log)
+		if s.ctx.Err() != nil {
+			// Closed mid-submission; the log is resubmitted, idempotently, by the next process
+			return
+		}
+		if err != nil && isUnreachable(err) {
+			select {
+			case <-s.done:
+				return
+			case <-time.After(s.options.RetryInterval):
+			}
+			continue
+		}
+		if err != nil && s.options.OnError != nil {
+			s.options.OnError(*log, err)
+		}
+
+		if err := s.advance(next); err != nil && s.options.OnError != nil {
+			s.options.OnError(*log, err)
+		}
+	}
+}
+
+// next reads the log at the current spool offset and returns it with the offset that follows it
+func (s *SpoolingSubmitter) next() (*LogEvent, int64, error) {
+	s.mu.Lock()
+	offset := s.offset
+	s.mu.Unlock()
+
+	reader := bufio.NewReader(io.NewSectionReader(s.file, offset, 1<<62))
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// Partial lines are only possible for a write still in progress
+			return nil, 0, err
+		}
+		offset += int64(len(line))
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		var log LogEvent
+		if err := json.Unmarshal([]byte(line), &log); err != nil {
+			if s.options.OnError != nil {
+				s.options.OnError(LogEvent{}, fmt.Errorf("skipping corrupt spool record: %v", err))
+			}
+			continue
+		}
+
+		return &log, offset, nil
+	}
+}
+
+// advance records that everything before offset has been submitted, truncating the spool once
+// fully drained. The offset is reset before the spool is truncated, so a crash in between leaves
+// the drained logs to be resubmitted, and refused as duplicates, rather than a stale offset into
+// a truncated spool.
+func (s *SpoolingSubmitter) advance(offset int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+	if offset < info.Size() {
+		s.offset = offset
+		return writeSpoolOffset(s.options.Path+".offset", offset)
+	}
+
+	if err := writeSpoolOffset(s.options.Path+".offset", 0); err != nil {
+		return err
+	}
+	s.offset = 0
+	if err := s.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate spool: %v", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync spool: %v", err)
+	}
+
+	return nil
+}
+
+func readSpoolOffset(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read spool offset: %v", err)
+	}
+
+	offset, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid spool offset in %s: %v", path, err)
+	}
+
+	return offset, nil
+}
+
+// writeSpoolOffset replaces the offset file atomically so a crash never leaves it half written
+func writeSpoolOffset(path string, offset int64) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(offset, 10)), 0600); err != nil {
+		return fmt.Errorf("failed to write spool offset: %v", err)
+	}
+
+	return os.Rename(tmp, path)
+}
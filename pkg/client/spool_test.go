@@ -0,0 +1,110 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func (l *ledger) has(id string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ids[id]
+}
+
+// waitFor polls cond until it holds, failing the test after a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+func TestSpoolingSubmitterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spool")
+	target := &ledger{ids: map[string]bool{}}
+
+	s, err := NewSpoolingSubmitter(target, SpoolOptions{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b"} {
+		if err := s.CreateLog(context.Background(), testLog(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, "the spool to drain", func() bool { return target.has("b") && s.Len() == 0 })
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A drained spool is empty with its offset reset, so a restart submits only new logs
+	if info, err := os.Stat(path); err != nil || info.Size() != 0 {
+		t.Fatalf("drained spool: %v, %v", info, err)
+	}
+	if offset, err := readSpoolOffset(path + ".offset"); err != nil || offset != 0 {
+		t.Fatalf("offset of the drained spool = %d, %v", offset, err)
+	}
+
+	restarted := &ledger{ids: map[string]bool{}}
+	s, err = NewSpoolingSubmitter(restarted, SpoolOptions{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.CreateLog(context.Background(), testLog("c")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "c", func() bool { return restarted.has("c") })
+	if restarted.has("a") || restarted.has("b") {
+		t.Errorf("the restart resubmitted drained logs: %v", restarted.ids)
+	}
+}
+
+func TestSpoolingSubmitterRecoversInterruptedTruncation(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		spooled []string
+		offset  int64
+	}{
+		// advance resets the offset before truncating, so a crash between the two leaves this
+		{"offset reset", []string{"a", "b"}, 0},
+		// and never this, unless the spool is truncated by hand
+		{"spool truncated", nil, 1000},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "spool")
+			var spool []byte
+			for _, id := range tt.spooled {
+				logJSON, _ := json.Marshal(testLog(id))
+				spool = append(append(spool, logJSON...), '\n')
+			}
+			if err := os.WriteFile(path, spool, 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path+".offset", []byte(strconv.FormatInt(tt.offset, 10)), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			// The spooled logs were already submitted before the crash
+			target := &ledger{ids: map[string]bool{"a": true, "b": true}}
+			s, err := NewSpoolingSubmitter(target, SpoolOptions{Path: path})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			if err := s.CreateLog(context.Background(), testLog("c")); err != nil {
+				t.Fatal(err)
+			}
+			// Nothing spooled after the restart is skipped or left behind
+			waitFor(t, "the spool to drain", func() bool { return target.has("c") && s.Len() == 0 })
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// LogStream delivers the logs of a paginated query over a channel. Pages are fetched in the
+// background while the consumer reads, at most prefetch pages ahead of it, so arbitrarily large
+// results can be processed without holding them in memory.
+type LogStream struct {
+	// C receives each log in query order and is closed when the query is exhausted, fails or is canceled
+	C <-chan *LogEvent
+
+	it     *LogIterator
+	cancel context.CancelFunc
+	done   chan struct{}
+	mu     sync.Mutex
+	err    error
+}
+
+// Stream starts fetching the iterator's pages in the background, keeping up to prefetch pages
+// buffered ahead of the consumer; prefetch is at least 1. The iterator must not be used directly
+// while it is streamed.
+func (it *LogIterator) Stream(ctx context.Context, prefetch int) *LogStream {
+	if prefetch < 1 {
+		prefetch = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	logs := make(chan *LogEvent, prefetch*int(it.pageSize))
+	s := &LogStream{
+		C:      logs,
+		it:     it,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	go s.run(ctx, logs)
+
+	return s
+}
+
+// StreamAllLogs streams every log, fetched pageSize logs at a time with up to prefetch pages buffered
+func (c *Client) StreamAllLogs(ctx context.Context, pageSize int32, prefetch int) *LogStream {
+	return c.IterateAllLogs(pageSize).Stream(ctx, prefetch)
+}
+
+// StreamLogs streams the logs matching filter, fetched pageSize logs at a time with up to prefetch pages buffered
+func (c *Client) StreamLogs(ctx context.Context, filter LogFilter, pageSize int32, prefetch int) *LogStream {
+	return c.IterateLogs(filter, pageSize).Stream(ctx, prefetch)
+}
+
+func (s *LogStream) run(ctx context.Context, logs chan<- *LogEvent) {
+	defer close(s.done)
+	defer close(logs)
+
+	for {
+		log, err := s.it.Next(ctx)
+		if errors.Is(err, ErrIteratorDone) {
+			return
+		}
+		if err != nil {
+			s.setErr(err)
+			return
+		}
+
+		select {
+		case logs <- log:
+		case <-ctx.Done():
+			s.setErr(ctx.Err())
+			return
+		}
+	}
+}
+
+// Err returns the error that ended the stream, or nil when every log was delivered.
+// It is only meaningful once C has been closed.
+func (s *LogStream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close stops the background fetch and waits for it to finish. Logs already buffered in C are
+// discarded; Err reports context.Canceled if the stream had not finished.
+func (s *LogStream) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+// Bookmark waits for the stream to end, after C is drained or Close is called, and returns the
+// bookmark of the page after the last one fetched. Logs of that page left unread in C are skipped
+// when a query is resumed from it.
+func (s *LogStream) Bookmark() string {
+	<-s.done
+	return s.it.Bookmark()
+}
+
+func (s *LogStream) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
@@ -0,0 +1,349 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+
+	"go.mozilla.org/pkcs7"
+)
+
+// TimestampTokenKey is the metadata field holding a log's RFC 3161 timestamp token, base64
+// encoded. LogEvent mirrors the chaincode's fields, so the token travels in metadata.
+const TimestampTokenKey = "timestampToken"
+
+// DefaultTimestampTimeout bounds each request to the time-stamping authority
+const DefaultTimestampTimeout = 10 * time.Second
+
+// ErrNoTimestamp is returned when a log carries no timestamp token
+var ErrNoTimestamp = errors.New("log has no timestamp token")
+
+var oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+
+// PKIStatus values that grant a timestamp
+const (
+	pkiStatusGranted         = 0
+	pkiStatusGrantedWithMods = 1
+)
+
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	ReqPolicy      asn1.ObjectIdentifier `asn1:"optional"`
+	Nonce          *big.Int              `asn1:"optional"`
+	CertReq        bool                  `asn1:"optional"`
+}
+
+type pkiStatusInfo struct {
+	Status       int
+	StatusString []string       `asn1:"optional,utf8"`
+	FailInfo     asn1.BitString `asn1:"optional"`
+}
+
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+// tstInfo holds the TSTInfo fields used here
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time
+	Nonce          *big.Int
+}
+
+// Timestamper obtains an RFC 3161 timestamp token from a time-stamping authority for each
+// log's content hash, for jurisdictions that require qualified timestamps
+type Timestamper struct {
+	url     string
+	client  *http.Client
+	policy  asn1.ObjectIdentifier
+	timeout time.Duration
+}
+
+// NewTimestamper returns a Timestamper requesting tokens from the TSA at url. httpClient may be
+// nil; policy, when set, requests a specific TSA policy OID.
+func NewTimestamper(url string, httpClient *http.Client, policy asn1.ObjectIdentifier) *Timestamper {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Timestamper{url: url, client: httpClient, policy: policy, timeout: DefaultTimestampTimeout}
+}
+
+// Process is a Processor, so a Timestamper is installed with WithProcessor(timestamper.Process).
+// It must run before a Compressor, since the token is added to the uncompressed metadata.
+func (t *Timestamper) Process(log LogEvent) (LogEvent, error) {
+	digest, err := TimestampDigest(log)
+	if err != nil {
+		return log, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+	defer cancel()
+
+	token, err := t.Timestamp(ctx, digest)
+	if err != nil {
+		return log, fmt.Errorf("failed to timestamp log %s: %w", log.ID, err)
+	}
+
+	metadata, err := metadataFields(log.Metadata)
+	if err != nil {
+		return log, fmt.Errorf("failed to timestamp log %s: %v", log.ID, err)
+	}
+	metadata[TimestampTokenKey] = base64.StdEncoding.EncodeToString(token)
+
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return log, err
+	}
+	log.Metadata = string(metadataJSON)
+
+	return log, nil
+}
+
+// Timestamp requests a token for a SHA-256 digest and returns the DER encoded token
+func (t *Timestamper) Timestamp(ctx context.Context, digest []byte) ([]byte, error) {
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := asn1.Marshal(timeStampReq{
+		Version: 1,
+		MessageImprint: messageImprint{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+			HashedMessage: digest,
+		},
+		ReqPolicy: t.policy,
+		Nonce:     nonce,
+		CertReq:   true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(request))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/timestamp-query")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach time-stamping authority: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read time-stamping response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("time-stamping authority returned %s", resp.Status)
+	}
+
+	var response timeStampResp
+	if _, err := asn1.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse time-stamping response: %v", err)
+	}
+	if response.Status.Status != pkiStatusGranted && response.Status.Status != pkiStatusGrantedWithMods {
+		return nil, fmt.Errorf("time-stamping authority refused the request with status %d %v", response.Status.Status, response.Status.StatusString)
+	}
+
+	token := response.TimeStampToken.FullBytes
+	info, err := parseTimestampToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(info.MessageImprint.HashedMessage, digest) {
+		return nil, fmt.Errorf("time-stamping authority stamped a different digest")
+	}
+	if info.Nonce == nil || info.Nonce.Cmp(nonce) != 0 {
+		return nil, fmt.Errorf("time-stamping response does not match the request nonce")
+	}
+
+	return token, nil
+}
+
+// TimestampToken returns the DER encoded timestamp token carried by log
+func TimestampToken(log LogEvent) ([]byte, error) {
+	metadata, err := metadataFields(log.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, ok := metadata[TimestampTokenKey].(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoTimestamp, log.ID)
+	}
+
+	return base64.StdEncoding.DecodeString(encoded)
+}
+
+// VerifyTimestamp checks that log's timestamp token is signed by a TSA certificate chaining to
+// roots, that the certificate is valid for time stamping at the stamped time and that the token
+// covers the log's current content. It returns the time the TSA vouches for.
+func VerifyTimestamp(log LogEvent, roots *x509.CertPool) (time.Time, error) {
+	token, err := TimestampToken(log)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	p7, err := pkcs7.Parse(token)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse timestamp token of log %s: %v", log.ID, err)
+	}
+	info, err := parseTSTInfo(p7.Content)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if err := p7.VerifyWithChainAtTime(roots, info.GenTime); err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp signature on log %s: %v", log.ID, err)
+	}
+	signer := p7.GetOnlySigner()
+	if signer == nil || !hasExtKeyUsage(signer, x509.ExtKeyUsageTimeStamping) {
+		return time.Time{}, fmt.Errorf("timestamp of log %s is not signed by a time-stamping certificate", log.ID)
+	}
+
+	digest, err := TimestampDigest(log)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !info.MessageImprint.HashAlgorithm.Algorithm.Equal(oidSHA256) || !bytes.Equal(info.MessageImprint.HashedMessage, digest) {
+		return time.Time{}, fmt.Errorf("timestamp of log %s does not cover its content", log.ID)
+	}
+
+	return info.GenTime, nil
+}
+
+// TimestampDigest returns the SHA-256 content hash that is timestamped: the log's fields with
+// its metadata normalized and any timestamp token removed. The ledger assigns the log's own
+// Timestamp, so it is not covered.
+func TimestampDigest(log LogEvent) ([]byte, error) {
+	metadata := ""
+	if log.Metadata != "" {
+		fields, err := metadataFields(log.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash log %s: %v", log.ID, err)
+		}
+		delete(fields, TimestampTokenKey)
+		if len(fields) > 0 {
+			normalized, err := json.Marshal(fields)
+			if err != nil {
+				return nil, err
+			}
+			metadata = string(normalized)
+		}
+	}
+
+	content, err := json.Marshal(struct {
+		ID          string `json:"id"`
+		UserID      string `json:"userId"`
+		Action      string `json:"action"`
+		Resource    string `json:"resource"`
+		Description string `json:"description"`
+		Metadata    string `json:"metadata"`
+	}{log.ID, log.UserID, log.Action, log.Resource, log.Description, metadata})
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(content)
+	return sum[:], nil
+}
+
+// parseTimestampToken returns the TSTInfo of a DER encoded token
+func parseTimestampToken(token []byte) (*tstInfo, error) {
+	p7, err := pkcs7.Parse(token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse timestamp token: %v", err)
+	}
+	return parseTSTInfo(p7.Content)
+}
+
+// parseTSTInfo decodes TSTInfo field by field, since several of its trailing fields are optional
+func parseTSTInfo(content []byte) (*tstInfo, error) {
+	var sequence asn1.RawValue
+	if _, err := asn1.Unmarshal(content, &sequence); err != nil {
+		return nil, fmt.Errorf("failed to parse timestamp info: %v", err)
+	}
+
+	var info tstInfo
+	rest := sequence.Bytes
+	fields := []interface{}{&info.Version, &info.Policy, &info.MessageImprint, &info.SerialNumber}
+	for _, field := range fields {
+		var err error
+		if rest, err = asn1.Unmarshal(rest, field); err != nil {
+			return nil, fmt.Errorf("failed to parse timestamp info: %v", err)
+		}
+	}
+	var err error
+	if rest, err = asn1.UnmarshalWithParams(rest, &info.GenTime, "generalized"); err != nil {
+		return nil, fmt.Errorf("failed to parse timestamp info: %v", err)
+	}
+
+	// accuracy (SEQUENCE) and ordering (BOOLEAN) may precede the nonce (INTEGER)
+	for len(rest) > 0 {
+		var field asn1.RawValue
+		next, err := asn1.Unmarshal(rest, &field)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse timestamp info: %v", err)
+		}
+		if field.Class == asn1.ClassUniversal && field.Tag == asn1.TagInteger {
+			info.Nonce = new(big.Int)
+			if _, err := asn1.Unmarshal(rest, &info.Nonce); err != nil {
+				return nil, fmt.Errorf("failed to parse timestamp nonce: %v", err)
+			}
+			break
+		}
+		rest = next
+	}
+
+	return &info, nil
+}
+
+func hasExtKeyUsage(cert *x509.Certificate, usage x509.ExtKeyUsage) bool {
+	for _, u := range cert.ExtKeyUsage {
+		if u == usage {
+			return true
+		}
+	}
+	return false
+}
+
+// metadataFields parses metadata into its fields, keeping numbers exactly as written
+func metadataFields(metadata string) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if metadata == "" {
+		return fields, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(metadata)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, fmt.Errorf("metadata is not a JSON object: %v", err)
+	}
+
+	return fields, nil
+}
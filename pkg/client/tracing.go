@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the client's spans
+const tracerName = "github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+
+// Span attribute keys
+const (
+	channelAttribute       = attribute.Key("fabric.channel")
+	chaincodeAttribute     = attribute.Key("fabric.chaincode")
+	functionAttribute      = attribute.Key("fabric.function")
+	transactionIDAttribute = attribute.Key("fabric.transaction_id")
+	blockNumberAttribute   = attribute.Key("fabric.block_number")
+	finalityAttribute      = attribute.Key("fabric.finality")
+)
+
+// WithTracerProvider sets the OpenTelemetry tracer provider used for submission spans.
+// The global provider is used otherwise.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *Client) {
+		c.contract.tracer = provider.Tracer(tracerName)
+	}
+}
+
+// defaultTracer returns the tracer of the global provider
+func defaultTracer() trace.Tracer {
+	return otel.GetTracerProvider().Tracer(tracerName)
+}
+
+// startSpan starts a child span of ctx for one phase of a submission
+func (c *contract) startSpan(ctx context.Context, name string, fn string) (context.Context, trace.Span) {
+	return c.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		channelAttribute.String(c.channelName),
+		chaincodeAttribute.String(c.chaincodeName),
+		functionAttribute.String(fn),
+	))
+}
+
+// endSpan records err, if any, on span and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// correlate sets the correlationId metadata field of log to the trace ID of the span in ctx,
+// unless the log already has one, so the log can be tied back to the trace that produced it
+func correlate(ctx context.Context, log LogEvent) LogEvent {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
+		return log
+	}
+
+	metadata := map[string]interface{}{}
+	if log.Metadata != "" {
+		if err := json.Unmarshal([]byte(log.Metadata), &metadata); err != nil {
+			// Left for validation to reject
+			return log
+		}
+	}
+	if _, ok := metadata[correlationIDKey]; ok {
+		return log
+	}
+
+	metadata[correlationIDKey] = spanContext.TraceID().String()
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return log
+	}
+	log.Metadata = string(metadataJSON)
+
+	return log
+}
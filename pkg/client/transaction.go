@@ -0,0 +1,477 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/gateway"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Default timeouts applied to each stage of a Fabric Gateway call
+const (
+	defaultEvaluateTimeout     = 5 * time.Second
+	defaultEndorseTimeout      = 15 * time.Second
+	defaultSubmitTimeout       = 5 * time.Second
+	defaultCommitStatusTimeout = 1 * time.Minute
+)
+
+// contract invokes transactions of a single chaincode on a single channel through a Fabric Gateway peer
+type contract struct {
+	gateway       gateway.GatewayClient
+	id            *Identity
+	sign          Sign
+	channelName   string
+	chaincodeName string
+	retry         RetryPolicy
+	endorsingOrgs []string
+	metrics       *Metrics
+	tracer        trace.Tracer
+
+	// conflictRetries is how many times a transaction invalidated by a read conflict is rebuilt and resubmitted
+	conflictRetries int
+}
+
+// proposal is a signed transaction proposal ready to be sent to the gateway
+type proposal struct {
+	txID   string
+	signed *peer.SignedProposal
+}
+
+// evaluate runs a transaction function on a gateway peer without submitting it to the orderer
+func (c *contract) evaluate(ctx context.Context, fn string, args ...string) ([]byte, error) {
+	p, err := c.newProposal(fn, args, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response *gateway.EvaluateResponse
+	err = c.retry.do(ctx, func() error {
+		ctx, cancel := context.WithTimeout(ctx, defaultEvaluateTimeout)
+		defer cancel()
+
+		response, err = c.gateway.Evaluate(ctx, &gateway.EvaluateRequest{
+			TransactionId:       p.txID,
+			ChannelId:           c.channelName,
+			ProposedTransaction: p.signed,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, gatewayError("evaluate", fn, err)
+	}
+
+	return response.GetResult().GetPayload(), nil
+}
+
+// submitWithOptions endorses a transaction function, submits it to the orderer and waits
+// for it to reach the requested finality.
+//
+// When an idempotency key is given the transaction ID is derived from it rather than from
+// a random nonce. Every attempt to submit the same key produces the same transaction ID,
+// which Fabric records at most once, so a resubmission after an ambiguous failure cannot
+// commit the transaction twice.
+func (c *contract) submitWithOptions(ctx context.Context, opts submitOptions, fn string, args ...string) (*SubmitResult, error) {
+	ctx, span := c.startSpan(ctx, "fabric.submit", fn)
+	span.SetAttributes(finalityAttribute.String(opts.finality.String()))
+
+	c.metrics.observeSubmitted(fn)
+	result, err := c.submit(ctx, opts, fn, args...)
+	var conflict *CommitError
+	for opts.attempt < c.conflictRetries && errors.As(err, &conflict) && isReadConflict(conflict) {
+		// The transaction definitely did not commit. Endorsing it again reads the current state.
+		span.AddEvent("read conflict", trace.WithAttributes(transactionIDAttribute.String(conflict.TransactionID)))
+		opts.attempt++
+		if !sleep(ctx, c.retry.Backoff(opts.attempt)) {
+			break
+		}
+		result, err = c.submit(ctx, opts, fn, args...)
+	}
+	c.metrics.observeResult(fn, result, err)
+
+	if result != nil {
+		span.SetAttributes(transactionIDAttribute.String(result.TransactionID))
+		if result.BlockNumber != 0 {
+			span.SetAttributes(blockNumberAttribute.Int64(int64(result.BlockNumber)))
+		}
+	}
+	endSpan(span, err)
+
+	return result, err
+}
+
+// submit implements submitWithOptions, tracing each phase of the transaction as a child of ctx
+func (c *contract) submit(ctx context.Context, opts submitOptions, fn string, args ...string) (*SubmitResult, error) {
+	var nonce []byte
+	if opts.idempotencyKey != "" {
+		// Each conflict retry has a transaction ID of its own, derived from the key all the same
+		key := opts.idempotencyKey
+		if opts.resubmission > 0 {
+			key += "\x00resubmission\x00" + strconv.Itoa(opts.resubmission)
+		}
+		if opts.attempt > 0 {
+			key += "\x00" + strconv.Itoa(opts.attempt)
+		}
+		nonce = idempotencyNonce(c.chaincodeName, fn, key)
+	}
+
+	_, span := c.startSpan(ctx, "fabric.proposal", fn)
+	p, err := c.newProposal(fn, args, nonce, opts.transient)
+	endSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SubmitResult{TransactionID: p.txID, Finality: opts.finality}
+
+	var envelope *common.Envelope
+	endorseCtx, span := c.startSpan(ctx, "fabric.endorse", fn)
+	endorseStart := time.Now()
+	err = c.retry.do(endorseCtx, func() error {
+		envelope, err = c.endorse(endorseCtx, p)
+		return err
+	})
+	c.metrics.observeEndorse(fn, time.Since(endorseStart))
+	endSpan(span, err)
+	if isDuplicateTransaction(err) {
+		// An earlier attempt with this idempotency key already reached the ledger
+		result.Finality = FinalityCommitted
+		return result, c.traceCommitted(ctx, fn, result)
+	}
+	if err != nil {
+		return nil, gatewayError("endorse", fn, err)
+	}
+
+	result.payload, err = transactionResult(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope.Signature, err = c.signMessage(envelope.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resubmitting the same signed envelope is safe: the orderer may receive it twice,
+	// but peers invalidate any copy after the first as a duplicate transaction ID
+	send := func(ctx context.Context) error {
+		orderCtx, span := c.startSpan(ctx, "fabric.order", fn)
+		err := c.retry.do(orderCtx, func() error {
+			ctx, cancel := context.WithTimeout(orderCtx, defaultSubmitTimeout)
+			defer cancel()
+
+			_, err := c.gateway.Submit(ctx, &gateway.SubmitRequest{
+				TransactionId:       p.txID,
+				ChannelId:           c.channelName,
+				PreparedTransaction: envelope,
+			})
+			return err
+		})
+		endSpan(span, err)
+		return err
+	}
+
+	if opts.finality == FinalityNone {
+		// The caller does not wait for ordering, so its cancellation must not abort it
+		go send(context.WithoutCancel(ctx))
+		return result, nil
+	}
+
+	commitStart := time.Now()
+	if err := send(ctx); err != nil {
+		return nil, gatewayError("submit", fn, err)
+	}
+	if opts.finality == FinalityOrdered {
+		return result, nil
+	}
+
+	if err := c.traceCommitted(ctx, fn, result); err != nil {
+		return nil, err
+	}
+	c.metrics.observeCommit(fn, time.Since(commitStart))
+
+	return result, nil
+}
+
+// traceCommitted is checkCommitted within a commit span
+func (c *contract) traceCommitted(ctx context.Context, fn string, result *SubmitResult) error {
+	ctx, span := c.startSpan(ctx, "fabric.commit", fn)
+	err := c.checkCommitted(ctx, fn, result)
+	endSpan(span, err)
+
+	return err
+}
+
+// checkCommitted waits for the transaction to commit, records its block and validation code
+// in result, and fails unless it was valid
+func (c *contract) checkCommitted(ctx context.Context, fn string, result *SubmitResult) error {
+	var commit *gateway.CommitStatusResponse
+	err := c.retry.do(ctx, func() error {
+		var err error
+		commit, err = c.commitStatus(ctx, result.TransactionID)
+		return err
+	})
+	if err != nil {
+		return gatewayError("commit status", fn, err)
+	}
+
+	result.BlockNumber = commit.BlockNumber
+	result.Status = commit.Result.String()
+	if commit.Result != peer.TxValidationCode_VALID {
+		return commitError(result.TransactionID, commit.Result)
+	}
+
+	return nil
+}
+
+func (c *contract) endorse(ctx context.Context, p *proposal) (*common.Envelope, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultEndorseTimeout)
+	defer cancel()
+
+	response, err := c.gateway.Endorse(ctx, &gateway.EndorseRequest{
+		TransactionId:          p.txID,
+		ChannelId:              c.channelName,
+		ProposedTransaction:    p.signed,
+		EndorsingOrganizations: c.endorsingOrgs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return response.PreparedTransaction, nil
+}
+
+func (c *contract) commitStatus(ctx context.Context, txID string) (*gateway.CommitStatusResponse, error) {
+	request, err := c.commitStatusRequest(txID)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := c.signMessage(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.signedCommitStatus(ctx, request, signature)
+}
+
+// commitStatusRequest builds the serialized, unsigned commit status request for txID
+func (c *contract) commitStatusRequest(txID string) ([]byte, error) {
+	creator, err := c.id.serialize()
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(&gateway.CommitStatusRequest{
+		TransactionId: txID,
+		ChannelId:     c.channelName,
+		Identity:      creator,
+	})
+}
+
+func (c *contract) signedCommitStatus(ctx context.Context, request []byte, signature []byte) (*gateway.CommitStatusResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultCommitStatusTimeout)
+	defer cancel()
+
+	return c.gateway.CommitStatus(ctx, &gateway.SignedCommitStatusRequest{
+		Request:   request,
+		Signature: signature,
+	})
+}
+
+// newProposal builds and signs a chaincode invocation proposal for fn with the given arguments
+// and transient data. A random nonce is generated when nonce is nil.
+func (c *contract) newProposal(fn string, args []string, nonce []byte, transient map[string][]byte) (*proposal, error) {
+	txID, proposalBytes, err := c.buildProposal(fn, args, nonce, transient)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := c.signMessage(proposalBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &proposal{
+		txID: txID,
+		signed: &peer.SignedProposal{
+			ProposalBytes: proposalBytes,
+			Signature:     signature,
+		},
+	}, nil
+}
+
+// buildProposal returns the transaction ID and serialized, unsigned proposal for fn with the given
+// arguments. Transient data is sent to the endorsing peers but never included in the transaction.
+func (c *contract) buildProposal(fn string, args []string, nonce []byte, transient map[string][]byte) (string, []byte, error) {
+	creator, err := c.id.serialize()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to serialize identity: %v", err)
+	}
+
+	if nonce == nil {
+		nonce = make([]byte, 24)
+		if _, err := rand.Read(nonce); err != nil {
+			return "", nil, fmt.Errorf("failed to generate nonce: %v", err)
+		}
+	}
+
+	txIDHash := sha256.Sum256(append(nonce, creator...))
+	txID := hex.EncodeToString(txIDHash[:])
+
+	extension, err := proto.Marshal(&peer.ChaincodeHeaderExtension{
+		ChaincodeId: &peer.ChaincodeID{Name: c.chaincodeName},
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	channelHeader, err := proto.Marshal(&common.ChannelHeader{
+		Type:      int32(common.HeaderType_ENDORSER_TRANSACTION),
+		ChannelId: c.channelName,
+		TxId:      txID,
+		Timestamp: timestamppb.Now(),
+		Extension: extension,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	signatureHeader, err := proto.Marshal(&common.SignatureHeader{
+		Creator: creator,
+		Nonce:   nonce,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	header, err := proto.Marshal(&common.Header{
+		ChannelHeader:   channelHeader,
+		SignatureHeader: signatureHeader,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	input := [][]byte{[]byte(fn)}
+	for _, arg := range args {
+		input = append(input, []byte(arg))
+	}
+
+	invocationSpec, err := proto.Marshal(&peer.ChaincodeInvocationSpec{
+		ChaincodeSpec: &peer.ChaincodeSpec{
+			ChaincodeId: &peer.ChaincodeID{Name: c.chaincodeName},
+			Input:       &peer.ChaincodeInput{Args: input},
+		},
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	payload, err := proto.Marshal(&peer.ChaincodeProposalPayload{
+		Input:        invocationSpec,
+		TransientMap: transient,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	proposalBytes, err := proto.Marshal(&peer.Proposal{
+		Header:  header,
+		Payload: payload,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	return txID, proposalBytes, nil
+}
+
+// idempotencyNonce derives a deterministic proposal nonce from an idempotency key
+func idempotencyNonce(chaincodeName string, fn string, idempotencyKey string) []byte {
+	digest := sha256.Sum256([]byte(chaincodeName + "\x00" + fn + "\x00" + idempotencyKey))
+	return digest[:24]
+}
+
+// isDuplicateTransaction reports whether the endorser rejected a proposal because its
+// transaction ID is already on the ledger
+func isDuplicateTransaction(err error) bool {
+	if err == nil {
+		return false
+	}
+	if strings.Contains(err.Error(), "duplicate transaction found") {
+		return true
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	for _, detail := range st.Details() {
+		if d, ok := detail.(*gateway.ErrorDetail); ok && strings.Contains(d.Message, "duplicate transaction found") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// signMessage signs the SHA-256 digest of message with the client's signing implementation
+func (c *contract) signMessage(message []byte) ([]byte, error) {
+	if c.sign == nil {
+		return nil, fmt.Errorf("client has no signing implementation; use the offline signing workflow")
+	}
+
+	digest := sha256.Sum256(message)
+	signature, err := c.sign(digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign message: %v", err)
+	}
+
+	return signature, nil
+}
+
+// transactionResult extracts the chaincode response payload from an endorsed transaction envelope
+func transactionResult(envelope *common.Envelope) ([]byte, error) {
+	payload := &common.Payload{}
+	if err := proto.Unmarshal(envelope.GetPayload(), payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal transaction payload: %v", err)
+	}
+
+	transaction := &peer.Transaction{}
+	if err := proto.Unmarshal(payload.GetData(), transaction); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal transaction: %v", err)
+	}
+	if len(transaction.GetActions()) == 0 {
+		return nil, fmt.Errorf("transaction contains no actions")
+	}
+
+	actionPayload := &peer.ChaincodeActionPayload{}
+	if err := proto.Unmarshal(transaction.GetActions()[0].GetPayload(), actionPayload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal chaincode action payload: %v", err)
+	}
+
+	responsePayload := &peer.ProposalResponsePayload{}
+	if err := proto.Unmarshal(actionPayload.GetAction().GetProposalResponsePayload(), responsePayload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal proposal response payload: %v", err)
+	}
+
+	chaincodeAction := &peer.ChaincodeAction{}
+	if err := proto.Unmarshal(responsePayload.GetExtension(), chaincodeAction); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal chaincode action: %v", err)
+	}
+
+	return chaincodeAction.GetResponse().GetPayload(), nil
+}
@@ -0,0 +1,187 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// IdentityResolver returns the Fabric identity and signer of an application user. It returns an
+// error wrapping ErrIdentityNotFound when the user has no identity of their own.
+type IdentityResolver func(userID string) (*Identity, Sign, error)
+
+// WalletResolver resolves each user to the wallet identity labelled with their user ID
+func WalletResolver(wallet *Wallet) IdentityResolver {
+	return func(userID string) (*Identity, Sign, error) {
+		entry, err := wallet.Get(userID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		sign, err := entry.Sign()
+		if err != nil {
+			return nil, nil, fmt.Errorf("identity %s: %v", userID, err)
+		}
+
+		return entry.Identity(), sign, nil
+	}
+}
+
+// UserIdentityClient is a LoggingClient submitting each log signed by the Fabric identity of
+// the user it records, so the transaction creator on the ledger is the actual actor rather than
+// a shared service identity. Reads use the base client's identity.
+type UserIdentityClient struct {
+	base    *Client
+	resolve IdentityResolver
+
+	mu      sync.Mutex
+	clients map[string]*Client
+}
+
+var _ LoggingClient = (*UserIdentityClient)(nil)
+
+// NewUserIdentityClient returns a client signing each log with the identity resolve returns for
+// its UserID, over base's connections and options. Logs of users without an identity are
+// rejected; a resolver may return base's identity for them instead. Closing the returned client
+// closes base.
+func NewUserIdentityClient(base *Client, resolve IdentityResolver) *UserIdentityClient {
+	return &UserIdentityClient{
+		base:    base,
+		resolve: resolve,
+		clients: map[string]*Client{},
+	}
+}
+
+// ForIdentity returns a client signing with another identity and sharing c's connections,
+// channel and options. The returned client does not own the connections; closing it has no effect.
+func (c *Client) ForIdentity(id *Identity, sign Sign) *Client {
+	contract := *c.contract
+	contract.id = id
+	contract.sign = sign
+
+	identity := *c
+	identity.contract = &contract
+	identity.conns = nil
+	identity.closeSign = nil
+
+	return &identity
+}
+
+// ForUser returns the client signing with userID's identity, resolving it on first use, for
+// calls such as SubmitLog or CreatePrivateLog that LoggingClient does not cover
+func (u *UserIdentityClient) ForUser(userID string) (*Client, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if c, ok := u.clients[userID]; ok {
+		return c, nil
+	}
+
+	id, sign, err := u.resolve(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve identity of user %s: %w", userID, err)
+	}
+
+	c := u.base.ForIdentity(id, sign)
+	u.clients[userID] = c
+
+	return c, nil
+}
+
+// Forget drops the cached identity of userID, so the next submission resolves it again, for
+// example after the user's certificate was renewed or revoked
+func (u *UserIdentityClient) Forget(userID string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	delete(u.clients, userID)
+}
+
+// CreateLog submits log signed by the identity of its user
+func (u *UserIdentityClient) CreateLog(ctx context.Context, log LogEvent) error {
+	c, err := u.ForUser(log.UserID)
+	if err != nil {
+		return err
+	}
+
+	return c.CreateLog(ctx, log)
+}
+
+// CreateLogsBatch submits one batch transaction per user, each signed by that user's identity.
+// Each user's batch is atomic, but the batches are not: when one fails, the others may still
+// have been recorded.
+func (u *UserIdentityClient) CreateLogsBatch(ctx context.Context, logs []LogEvent) error {
+	// Every identity is resolved before anything is submitted
+	clients := map[string]*Client{}
+	batches := map[string][]LogEvent{}
+	var order []string
+	for _, log := range logs {
+		if _, ok := clients[log.UserID]; !ok {
+			c, err := u.ForUser(log.UserID)
+			if err != nil {
+				return err
+			}
+			clients[log.UserID] = c
+			order = append(order, log.UserID)
+		}
+		batches[log.UserID] = append(batches[log.UserID], log)
+	}
+
+	for _, userID := range order {
+		if err := clients[userID].CreateLogsBatch(ctx, batches[userID]); err != nil {
+			return fmt.Errorf("user %s: %w", userID, err)
+		}
+	}
+
+	return nil
+}
+
+// ReadLog returns the log with given id
+func (u *UserIdentityClient) ReadLog(ctx context.Context, id string) (*LogEvent, error) {
+	return u.base.ReadLog(ctx, id)
+}
+
+// LogExists returns true when a log with given id exists
+func (u *UserIdentityClient) LogExists(ctx context.Context, id string) (bool, error) {
+	return u.base.LogExists(ctx, id)
+}
+
+// GetAllLogs returns every log
+func (u *UserIdentityClient) GetAllLogs(ctx context.Context) ([]*LogEvent, error) {
+	return u.base.GetAllLogs(ctx)
+}
+
+// QueryLogs returns the logs matching filter
+func (u *UserIdentityClient) QueryLogs(ctx context.Context, filter LogFilter) ([]*LogEvent, error) {
+	return u.base.QueryLogs(ctx, filter)
+}
+
+// QueryLogsPage returns one page of the logs matching filter, starting at bookmark
+func (u *UserIdentityClient) QueryLogsPage(ctx context.Context, filter LogFilter, pageSize int32, bookmark string) (*LogPage, error) {
+	return u.base.QueryLogsPage(ctx, filter, pageSize, bookmark)
+}
+
+// NewEventListener returns a listener of the base client passing each log to handler
+func (u *UserIdentityClient) NewEventListener(handler EventHandler, opts ...ListenOption) *EventListener {
+	return u.base.NewEventListener(handler, opts...)
+}
+
+// BlockHeight returns the height of the channel's ledger as the base client's peer knows it
+func (u *UserIdentityClient) BlockHeight(ctx context.Context) (uint64, error) {
+	return u.base.BlockHeight(ctx)
+}
+
+// Ping evaluates the chaincode's Ping function with the base client
+func (u *UserIdentityClient) Ping(ctx context.Context) error {
+	return u.base.Ping(ctx)
+}
+
+// CheckConnections checks the base client's connections
+func (u *UserIdentityClient) CheckConnections() error {
+	return u.base.CheckConnections()
+}
+
+// Close releases the base client's connections
+func (u *UserIdentityClient) Close() error {
+	return u.base.Close()
+}
@@ -0,0 +1,153 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+)
+
+// Maximum field lengths accepted for a log. These mirror the rules enforced by the chaincode.
+const (
+	MaxIDLength          = 128
+	MaxUserIDLength      = 128
+	MaxActionLength      = 64
+	MaxResourceLength    = 512
+	MaxDescriptionLength = 4096
+	MaxMetadataLength    = 16384
+)
+
+// Truncate shortens s to at most n bytes without splitting a character, so adapters can fit a
+// field received from another system within its maximum length
+func Truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// ErrInvalidLog is wrapped by every error returned when a log fails validation
+var ErrInvalidLog = errors.New("invalid log")
+
+// actionPattern restricts actions to upper-case identifiers such as LOGIN or API_CALL
+var actionPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// metadataEncodings are the compression schemes the chaincode accepts for metadata
+var metadataEncodings = map[string]bool{string(EncodingGzip): true, string(EncodingZstd): true}
+
+// Validator checks a log before it is submitted
+type Validator func(log LogEvent) error
+
+// ValidateLog applies the chaincode's rules for a log locally, so a log that would be
+// rejected fails without an endorsement round trip
+func ValidateLog(log LogEvent) error {
+	required := []struct {
+		name  string
+		value string
+	}{
+		{"id", log.ID},
+		{"userId", log.UserID},
+		{"action", log.Action},
+		{"resource", log.Resource},
+	}
+	for _, field := range required {
+		if field.value == "" {
+			return fmt.Errorf("%w: the log %s is missing the required field %s", ErrInvalidLog, log.ID, field.name)
+		}
+	}
+
+	limits := []struct {
+		name   string
+		value  string
+		length int
+	}{
+		{"id", log.ID, MaxIDLength},
+		{"userId", log.UserID, MaxUserIDLength},
+		{"action", log.Action, MaxActionLength},
+		{"resource", log.Resource, MaxResourceLength},
+		{"description", log.Description, MaxDescriptionLength},
+		{"metadata", log.Metadata, MaxMetadataLength},
+	}
+	for _, field := range limits {
+		if len(field.value) > field.length {
+			return fmt.Errorf("%w: the %s of log %s exceeds %d characters", ErrInvalidLog, field.name, log.ID, field.length)
+		}
+	}
+
+	if !actionPattern.MatchString(log.Action) {
+		return fmt.Errorf("%w: the action %s of log %s must be upper-case letters, digits and underscores", ErrInvalidLog, log.Action, log.ID)
+	}
+
+	if log.Metadata != "" {
+		var metadata map[string]interface{}
+		if err := json.Unmarshal([]byte(log.Metadata), &metadata); err != nil {
+			return fmt.Errorf("%w: the metadata of log %s must be a JSON object", ErrInvalidLog, log.ID)
+		}
+		if err := validateMetadataEncoding(metadata); err != nil {
+			return fmt.Errorf("%w: the metadata of log %s is invalid: %v", ErrInvalidLog, log.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// validateMetadataEncoding checks the envelope of compressed metadata as the chaincode does,
+// without decompressing the payload
+func validateMetadataEncoding(metadata map[string]interface{}) error {
+	encoding, ok := metadata[metadataEncodingField]
+	if !ok {
+		return nil
+	}
+
+	name, ok := encoding.(string)
+	if !ok || !metadataEncodings[name] {
+		return fmt.Errorf("unsupported encoding %v", encoding)
+	}
+
+	data, ok := metadata[metadataDataField].(string)
+	if !ok {
+		return fmt.Errorf("missing %s payload", metadataDataField)
+	}
+	if _, err := base64.StdEncoding.DecodeString(data); err != nil {
+		return fmt.Errorf("%s payload is not base64: %v", metadataDataField, err)
+	}
+
+	return nil
+}
+
+// AllowedActions returns a Validator accepting only the given actions, for deployments that
+// want a stricter vocabulary than the chaincode enforces
+func AllowedActions(actions ...string) Validator {
+	allowed := make(map[string]bool, len(actions))
+	for _, action := range actions {
+		allowed[action] = true
+	}
+
+	return func(log LogEvent) error {
+		if !allowed[log.Action] {
+			return fmt.Errorf("%w: the action %s of log %s is not allowed", ErrInvalidLog, log.Action, log.ID)
+		}
+		return nil
+	}
+}
+
+// validate runs ValidateLog and then any additional validators configured on the client
+func (c *Client) validate(logs ...LogEvent) error {
+	for _, log := range logs {
+		if err := ValidateLog(log); err != nil {
+			return err
+		}
+		for _, validator := range c.validators {
+			if err := validator(log); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
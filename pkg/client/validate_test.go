@@ -0,0 +1,161 @@
+package client
+
+import (
+	"errors"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// chaincodeSource is the contract whose rules ValidateLog mirrors
+const chaincodeSource = "../../chaincode/logging/logging.go"
+
+// TestValidationMatchesChaincode fails when the limits, action pattern or metadata envelope here drift from the
+// chaincode's, which would let the client accept logs the contract rejects or the reverse
+func TestValidationMatchesChaincode(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), chaincodeSource, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]ast.Expr{}
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || (gen.Tok != token.CONST && gen.Tok != token.VAR) {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			spec := spec.(*ast.ValueSpec)
+			for i, name := range spec.Names {
+				if i < len(spec.Values) {
+					values[name.Name] = spec.Values[i]
+				}
+			}
+		}
+	}
+
+	for _, tt := range []struct {
+		name string
+		want int
+	}{
+		{"maxIDLength", MaxIDLength},
+		{"maxUserIDLength", MaxUserIDLength},
+		{"maxActionLength", MaxActionLength},
+		{"maxResourceLength", MaxResourceLength},
+		{"maxDescriptionLength", MaxDescriptionLength},
+		{"maxMetadataLength", MaxMetadataLength},
+	} {
+		lit, ok := values[tt.name].(*ast.BasicLit)
+		if !ok || lit.Kind != token.INT {
+			t.Errorf("the chaincode does not declare %s as an integer literal", tt.name)
+			continue
+		}
+		if got, _ := constant.Int64Val(constant.MakeFromLiteral(lit.Value, lit.Kind, 0)); got != int64(tt.want) {
+			t.Errorf("the chaincode's %s is %d, the client's is %d", tt.name, got, tt.want)
+		}
+	}
+
+	// var actionPattern = regexp.MustCompile(`...`)
+	call, ok := values["actionPattern"].(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		t.Fatal("the chaincode does not declare actionPattern with regexp.MustCompile")
+	}
+	lit, ok := call.Args[0].(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		t.Fatal("the chaincode's actionPattern is not a string literal")
+	}
+	pattern, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pattern != actionPattern.String() {
+		t.Errorf("the chaincode's actionPattern is %s, the client's is %s", pattern, actionPattern)
+	}
+
+	for _, tt := range []struct {
+		name string
+		want string
+	}{
+		{"metadataEncodingField", metadataEncodingField},
+		{"metadataDataField", metadataDataField},
+	} {
+		lit, ok := values[tt.name].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			t.Errorf("the chaincode does not declare %s as a string literal", tt.name)
+			continue
+		}
+		if got, _ := strconv.Unquote(lit.Value); got != tt.want {
+			t.Errorf("the chaincode's %s is %s, the client's is %s", tt.name, got, tt.want)
+		}
+	}
+
+	// var metadataEncodings = map[string]bool{"gzip": true, ...}
+	encodings, ok := values["metadataEncodings"].(*ast.CompositeLit)
+	if !ok {
+		t.Fatal("the chaincode does not declare metadataEncodings as a map literal")
+	}
+	var chaincodeEncodings, clientEncodings []string
+	for _, elt := range encodings.Elts {
+		if key, ok := elt.(*ast.KeyValueExpr).Key.(*ast.BasicLit); ok {
+			name, _ := strconv.Unquote(key.Value)
+			chaincodeEncodings = append(chaincodeEncodings, name)
+		}
+	}
+	for name := range metadataEncodings {
+		clientEncodings = append(clientEncodings, name)
+	}
+	sort.Strings(chaincodeEncodings)
+	sort.Strings(clientEncodings)
+	if strings.Join(chaincodeEncodings, ",") != strings.Join(clientEncodings, ",") {
+		t.Errorf("the chaincode accepts the encodings %v, the client %v", chaincodeEncodings, clientEncodings)
+	}
+
+	// The chaincode must still require a base64 payload, as the client does
+	var decodes bool
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != "validateMetadataEncoding" {
+			continue
+		}
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok && sel.Sel.Name == "DecodeString" {
+				if inner, ok := sel.X.(*ast.SelectorExpr); ok && inner.Sel.Name == "StdEncoding" {
+					decodes = true
+				}
+			}
+			return true
+		})
+	}
+	if !decodes {
+		t.Error("the chaincode's validateMetadataEncoding no longer decodes the payload with base64.StdEncoding")
+	}
+}
+
+func TestValidateMetadataEncoding(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		metadata string
+		valid    bool
+	}{
+		{"plain", `{"ip":"10.0.0.1"}`, true},
+		{"compressed", `{"$encoding":"gzip","$data":"H4sIAAAAAAAA/wEAAP//AAAAAAAAAAA="}`, true},
+		{"unsupported encoding", `{"$encoding":"brotli","$data":""}`, false},
+		{"missing payload", `{"$encoding":"zstd"}`, false},
+		{"payload not a string", `{"$encoding":"gzip","$data":42}`, false},
+		{"payload not base64", `{"$encoding":"gzip","$data":"not base64!"}`, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateLog(LogEvent{ID: "l1", UserID: "u1", Action: "LOGIN", Resource: "app", Metadata: tt.metadata})
+			if tt.valid && err != nil {
+				t.Errorf("ValidateLog = %v, want the metadata accepted", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidLog) {
+				t.Errorf("ValidateLog = %v, want %v", err, ErrInvalidLog)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Kinds of Divergence found by a Verifier
+const (
+	// DivergenceMissing means a submitted log is not on the ledger
+	DivergenceMissing = "missing"
+	// DivergenceMismatch means the ledger holds different content for a submitted log
+	DivergenceMismatch = "mismatch"
+	// DivergenceChain means the local hash chain itself has been altered
+	DivergenceChain = "chain"
+)
+
+// pruneReader is implemented by clients that can tell a pruned log from a missing one
+type pruneReader interface {
+	PrunedLog(ctx context.Context, id string) (*PrunedLog, error)
+}
+
+// Divergence is a disagreement between what the client submitted and what can be verified
+type Divergence struct {
+	Kind     string `json:"kind"`
+	Sequence uint64 `json:"sequence"`
+	LogID    string `json:"logId"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+func (d Divergence) String() string {
+	return fmt.Sprintf("%s divergence at entry %d for log %s: expected %s, got %s", d.Kind, d.Sequence, d.LogID, d.Expected, d.Actual)
+}
+
+// hashChainEntry is one line of a Verifier's hash chain file
+type hashChainEntry struct {
+	Sequence   uint64    `json:"sequence"`
+	LogID      string    `json:"logId"`
+	LogHash    string    `json:"logHash"`
+	Chain      string    `json:"chain"`
+	RecordedAt time.Time `json:"recordedAt"`
+}
+
+// Verifier keeps a local hash chain of every log the client submitted, in an append-only
+// NDJSON file, and cross-checks it against the ledger. Each entry's chain hash covers the
+// previous entry's, so editing or removing a line of the file is detected as well as a log
+// that is missing from, or different on, the ledger. Private logs are not recorded, since
+// their private fields cannot be read back from the public ledger.
+type Verifier struct {
+	mu      sync.Mutex
+	file    *os.File
+	entries []hashChainEntry
+	head    string
+}
+
+// NewVerifier opens or creates the hash chain file at path
+func NewVerifier(path string) (*Verifier, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open hash chain: %v", err)
+	}
+
+	v := &Verifier{file: file}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry hashChainEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// A torn final line from a crash; the log was submitted but never recorded
+			continue
+		}
+		v.entries = append(v.entries, entry)
+		v.head = entry.Chain
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read hash chain: %v", err)
+	}
+
+	return v, nil
+}
+
+// WithVerifier records every log submitted through the client in verifier's hash chain
+func WithVerifier(verifier *Verifier) Option {
+	return func(c *Client) {
+		c.verifier = verifier
+	}
+}
+
+// Record appends the given logs, as submitted, to the hash chain and syncs the file
+func (v *Verifier) Record(logs ...LogEvent) error {
+	if v == nil {
+		return nil
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var lines []byte
+	head := v.head
+	var entries []hashChainEntry
+	recordedAt := time.Now().UTC()
+	for _, log := range logs {
+		logHash, err := hashLog(log)
+		if err != nil {
+			return err
+		}
+		entry := hashChainEntry{
+			Sequence:   uint64(len(v.entries) + len(entries)),
+			LogID:      log.ID,
+			LogHash:    logHash,
+			Chain:      chainHash(head, logHash),
+			RecordedAt: recordedAt,
+		}
+		entryJSON, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		lines = append(append(lines, entryJSON...), '\n')
+		entries = append(entries, entry)
+		head = entry.Chain
+	}
+
+	if _, err := v.file.Write(lines); err != nil {
+		return fmt.Errorf("failed to record log hashes: %v", err)
+	}
+	if err := v.file.Sync(); err != nil {
+		return fmt.Errorf("failed to record log hashes: %v", err)
+	}
+	v.entries = append(v.entries, entries...)
+	v.head = head
+
+	return nil
+}
+
+// Head returns the number of recorded logs and the chain hash covering all of them, which can
+// be published or stored elsewhere to detect the file being rewritten wholesale
+func (v *Verifier) Head() (uint64, string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return uint64(len(v.entries)), v.head
+}
+
+// Verify checks the hash chain and reads every recorded log back from the ledger through
+// client, returning each divergence found. An error means the check could not be completed.
+func (v *Verifier) Verify(ctx context.Context, client LoggingClient) ([]Divergence, error) {
+	divergences, _, err := v.VerifyBetween(ctx, client, time.Time{}, time.Time{})
+	return divergences, err
+}
+
+// VerifyBetween is Verify restricted to the logs recorded between start and end, inclusive; a
+// zero start or end leaves that side open. It also returns how many entries were checked.
+//
+// A log absent from the ledger is only a divergence when it was not pruned: when client can read
+// the tombstones PruneLogs leaves, as Client can, logs deleted by retention are checked off
+// without a divergence.
+func (v *Verifier) VerifyBetween(ctx context.Context, client LoggingClient, start, end time.Time) ([]Divergence, int, error) {
+	v.mu.Lock()
+	entries := append([]hashChainEntry(nil), v.entries...)
+	v.mu.Unlock()
+
+	var divergences []Divergence
+	checked := 0
+	previous := ""
+	for _, entry := range entries {
+		expected := chainHash(previous, entry.LogHash)
+		previous = entry.Chain
+		if (!start.IsZero() && entry.RecordedAt.Before(start)) || (!end.IsZero() && entry.RecordedAt.After(end)) {
+			continue
+		}
+		checked++
+		if entry.Chain != expected {
+			divergences = append(divergences, Divergence{Kind: DivergenceChain, Sequence: entry.Sequence, LogID: entry.LogID, Expected: expected, Actual: entry.Chain})
+		}
+
+		log, err := client.ReadLog(ctx, entry.LogID)
+		if errors.Is(err, ErrLogNotFound) {
+			pruned, err := isPruned(ctx, client, entry.LogID)
+			if err != nil {
+				return divergences, checked, fmt.Errorf("failed to read tombstone of log %s: %w", entry.LogID, err)
+			}
+			if pruned {
+				continue
+			}
+			divergences = append(divergences, Divergence{Kind: DivergenceMissing, Sequence: entry.Sequence, LogID: entry.LogID, Expected: entry.LogHash})
+			continue
+		}
+		if err != nil {
+			return divergences, checked, fmt.Errorf("failed to read log %s: %w", entry.LogID, err)
+		}
+
+		actual, err := hashLog(*log)
+		if err != nil {
+			return divergences, checked, err
+		}
+		if actual != entry.LogHash {
+			divergences = append(divergences, Divergence{Kind: DivergenceMismatch, Sequence: entry.Sequence, LogID: entry.LogID, Expected: entry.LogHash, Actual: actual})
+		}
+	}
+
+	return divergences, checked, nil
+}
+
+// isPruned reports whether client holds a tombstone for the log with given id
+func isPruned(ctx context.Context, client LoggingClient, id string) (bool, error) {
+	reader, ok := client.(pruneReader)
+	if !ok {
+		return false, nil
+	}
+
+	_, err := reader.PrunedLog(ctx, id)
+	if errors.Is(err, ErrLogNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Run verifies the chain every interval until ctx is done, calling alert for each divergence.
+// Verification errors are retried at the next interval; Run returns ctx's error.
+func (v *Verifier) Run(ctx context.Context, client LoggingClient, interval time.Duration, alert func(Divergence)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		divergences, _ := v.Verify(ctx, client)
+		for _, divergence := range divergences {
+			alert(divergence)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close closes the hash chain file
+func (v *Verifier) Close() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.file.Close()
+}
+
+// hashLog returns the SHA-256 of the fields a client submits, with metadata decoded so a log
+// compressed on submission hashes the same as the log read back. The timestamp is left out
+// since the chaincode assigns it.
+func hashLog(log LogEvent) (string, error) {
+	if err := decodeLog(&log); err != nil {
+		return "", err
+	}
+
+	fieldsJSON, err := json.Marshal(struct {
+		ID          string `json:"id"`
+		UserID      string `json:"userId"`
+		Action      string `json:"action"`
+		Resource    string `json:"resource"`
+		Description string `json:"description"`
+		Metadata    string `json:"metadata"`
+	}{log.ID, log.UserID, log.Action, log.Resource, log.Description, log.Metadata})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(fieldsJSON)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// chainHash links logHash to the chain ending at previous
+func chainHash(previous string, logHash string) string {
+	sum := sha256.Sum256([]byte(previous + logHash))
+	return hex.EncodeToString(sum[:])
+}
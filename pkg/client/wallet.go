@@ -0,0 +1,209 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// walletFileExtension is the file extension of wallet entries, as used by the fabric-network
+// file system wallet, so the backend and Go clients can share a wallet directory
+const walletFileExtension = ".id"
+
+// ErrIdentityNotFound is returned when a wallet has no identity with the requested label
+var ErrIdentityNotFound = errors.New("identity not found in wallet")
+
+// X509Identity is a wallet entry holding an X.509 certificate and its private key
+type X509Identity struct {
+	Credentials struct {
+		Certificate string `json:"certificate"`
+		PrivateKey  string `json:"privateKey"`
+	} `json:"credentials"`
+	MSPID   string `json:"mspId"`
+	Type    string `json:"type"`
+	Version int    `json:"version"`
+}
+
+// NewX509Identity returns a wallet entry for a PEM encoded certificate and private key
+func NewX509Identity(mspID string, certPEM []byte, keyPEM []byte) *X509Identity {
+	id := &X509Identity{
+		MSPID:   mspID,
+		Type:    "X.509",
+		Version: 1,
+	}
+	id.Credentials.Certificate = string(certPEM)
+	id.Credentials.PrivateKey = string(keyPEM)
+
+	return id
+}
+
+// Identity returns the signing identity of the wallet entry
+func (x *X509Identity) Identity() *Identity {
+	return &Identity{MSPID: x.MSPID, Certificate: []byte(x.Credentials.Certificate)}
+}
+
+// Sign returns a Sign using the wallet entry's private key
+func (x *X509Identity) Sign() (Sign, error) {
+	key, err := parsePrivateKey([]byte(x.Credentials.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %v", err)
+	}
+
+	return NewPrivateKeySign(key)
+}
+
+// Wallet is a directory of identities, one file per label
+type Wallet struct {
+	dir string
+}
+
+// NewFileSystemWallet opens the wallet in dir, creating the directory if needed
+func NewFileSystemWallet(dir string) (*Wallet, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create wallet directory: %v", err)
+	}
+
+	return &Wallet{dir: dir}, nil
+}
+
+// Put stores id under label, replacing any existing identity with that label
+func (w *Wallet) Put(label string, id *X509Identity) error {
+	path, err := w.path(label)
+	if err != nil {
+		return err
+	}
+
+	idJSON, err := json.Marshal(id)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, idJSON, 0600)
+}
+
+// Get returns the identity stored under label
+func (w *Wallet) Get(label string) (*X509Identity, error) {
+	path, err := w.path(label)
+	if err != nil {
+		return nil, err
+	}
+
+	idJSON, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrIdentityNotFound, label)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var id X509Identity
+	if err := json.Unmarshal(idJSON, &id); err != nil {
+		return nil, fmt.Errorf("failed to parse identity %s: %v", label, err)
+	}
+	if id.Type != "" && id.Type != "X.509" {
+		return nil, fmt.Errorf("identity %s has unsupported type %s", label, id.Type)
+	}
+
+	return &id, nil
+}
+
+// Exists returns true when the wallet holds an identity with label
+func (w *Wallet) Exists(label string) (bool, error) {
+	path, err := w.path(label)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// Remove deletes the identity stored under label
+func (w *Wallet) Remove(label string) error {
+	path, err := w.path(label)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrIdentityNotFound, label)
+	}
+
+	return err
+}
+
+// List returns the labels of every identity in the wallet
+func (w *Wallet) List() ([]string, error) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var labels []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != walletFileExtension {
+			continue
+		}
+		labels = append(labels, strings.TrimSuffix(entry.Name(), walletFileExtension))
+	}
+	sort.Strings(labels)
+
+	return labels, nil
+}
+
+// Import reads a certificate and private key, as found in an MSP directory's signcerts and
+// keystore, and stores them under label. Either path may be a directory holding a single file.
+func (w *Wallet) Import(label string, mspID string, certPath string, keyPath string) error {
+	if _, err := LoadIdentity(mspID, certPath); err != nil {
+		return err
+	}
+
+	certPEM, err := readPEMFile(certPath)
+	if err != nil {
+		return fmt.Errorf("failed to read certificate: %v", err)
+	}
+
+	keyPEM, err := readPEMFile(keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read private key: %v", err)
+	}
+	if _, err := parsePrivateKey(keyPEM); err != nil {
+		return fmt.Errorf("failed to parse private key %s: %v", keyPath, err)
+	}
+
+	return w.Put(label, NewX509Identity(mspID, certPEM, keyPEM))
+}
+
+// Export writes the certificate and private key stored under label to PEM files
+func (w *Wallet) Export(label string, certPath string, keyPath string) error {
+	id, err := w.Get(label)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(certPath, []byte(id.Credentials.Certificate), 0644); err != nil {
+		return fmt.Errorf("failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyPath, []byte(id.Credentials.PrivateKey), 0600); err != nil {
+		return fmt.Errorf("failed to write private key: %v", err)
+	}
+
+	return nil
+}
+
+func (w *Wallet) path(label string) (string, error) {
+	if label == "" || label == "." || label == ".." || strings.ContainsAny(label, `/\`) {
+		return "", fmt.Errorf("invalid identity label %q", label)
+	}
+
+	return filepath.Join(w.dir, label+walletFileExtension), nil
+}
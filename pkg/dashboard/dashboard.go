@@ -0,0 +1,43 @@
+// Package dashboard serves a small web dashboard of the logging chaincode: activity charts, the
+// latest logs as they commit, a drill-down into each user's activity and the high-severity logs.
+//
+// The dashboard is a static page embedded in the binary. It reads everything through the REST API
+// of package rest, which Handler mounts under /api: GET /logs for the charts and views, and the
+// Server-Sent Events of GET /logs/stream for live updates. Callers authenticate with the bearer
+// token or API key they enter in the page, which the page keeps in the browser's local storage.
+package dashboard
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// static holds the page and its script and stylesheet
+//
+//go:embed static
+var static embed.FS
+
+// contentSecurityPolicy only lets the page load its own script and stylesheet and call its own API
+const contentSecurityPolicy = "default-src 'none'; script-src 'self'; style-src 'self'; img-src 'self' data:; connect-src 'self'; base-uri 'none'; form-action 'none'; frame-ancestors 'none'"
+
+// Handler returns a handler serving the dashboard at / and api, such as a rest.Server or a proxy
+// to one, at /api/
+func Handler(api http.Handler) http.Handler {
+	assets, err := fs.Sub(static, "static")
+	if err != nil {
+		// The embedded directory always exists
+		panic(err)
+	}
+	files := http.FileServer(http.FS(assets))
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/", http.StripPrefix("/api", api))
+	mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", contentSecurityPolicy)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		files.ServeHTTP(w, r)
+	}))
+	return mux
+}
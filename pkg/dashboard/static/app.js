@@ -0,0 +1,377 @@
+"use strict";
+
+// Logs read per request, the most GET /logs returns
+const PAGE_LIMIT = 1000;
+// Most logs read for the charts, so a busy window cannot stall the page
+const MAX_LOGS = 20000;
+// Most alerts and drill-down logs listed
+const MAX_LISTED = 200;
+// Rows of the recent events table
+const RECENT_ROWS = 50;
+// Entries of the action and user charts
+const TOP_ENTRIES = 10;
+// Alerts are the logs at least this severe
+const ALERT_LEVEL = "ERROR";
+// Bars of the activity charts per window
+const BUCKETS = { 3600: 60, 86400: 48, 604800: 56, 2592000: 30 };
+// API keys are told from bearer tokens by their prefix, as the API does
+const API_KEY_PREFIX = "fl_";
+
+// Level names on the scale of Go's log/slog, as the client compares them
+const LEVELS = { TRACE: -8, DEBUG: -4, INFO: 0, WARN: 4, WARNING: 4, ERROR: 8, DPANIC: 10, PANIC: 12, FATAL: 12 };
+
+// NONE labels the logs without a value in the action and user charts
+const NONE = "(none)";
+
+const state = {
+  token: localStorage.getItem("dashboard.token") || "",
+  window: 86400,
+  logs: [],
+  alerts: [],
+  user: null,
+  stream: null,
+};
+
+const $ = (id) => document.getElementById(id);
+
+// levelOf returns the severity recorded in the metadata of log, or null
+function levelOf(log) {
+  const level = metadataOf(log).level;
+  if (typeof level !== "string") {
+    return null;
+  }
+  const match = /^([A-Z]+)([+-]\d+)?$/.exec(level.toUpperCase());
+  if (!match || !(match[1] in LEVELS)) {
+    return null;
+  }
+  return LEVELS[match[1]] + (match[2] ? parseInt(match[2], 10) : 0);
+}
+
+function metadataOf(log) {
+  try {
+    const metadata = JSON.parse(log.metadata || "{}");
+    return metadata && typeof metadata === "object" ? metadata : {};
+  } catch {
+    return {};
+  }
+}
+
+function isAlert(log) {
+  const level = levelOf(log);
+  return level !== null && level >= LEVELS[ALERT_LEVEL];
+}
+
+function timeOf(log) {
+  return Date.parse(log.timestamp);
+}
+
+function formatTime(ms) {
+  return new Date(ms).toLocaleString();
+}
+
+// rfc3339 formats a time as the API expects, without fractional seconds
+function rfc3339(ms) {
+  return new Date(ms).toISOString().replace(/\.\d+Z$/, "Z");
+}
+
+function setStatus(text, error) {
+  $("status").textContent = text;
+  $("status").classList.toggle("error", Boolean(error));
+}
+
+function authHeaders() {
+  if (!state.token) {
+    return {};
+  }
+  if (state.token.startsWith(API_KEY_PREFIX)) {
+    return { "X-API-Key": state.token };
+  }
+  return { Authorization: "Bearer " + state.token };
+}
+
+// nextCursor returns the cursor of the next page from a Link header, or null on the last page
+function nextCursor(link) {
+  for (const part of (link || "").split(",")) {
+    const match = /<([^>]*)>\s*;\s*rel="next"/.exec(part);
+    if (match) {
+      return new URL(match[1], location.href).searchParams.get("cursor");
+    }
+  }
+  return null;
+}
+
+// fetchLogs reads the logs matching params a page at a time, up to max of them
+async function fetchLogs(params, max) {
+  const logs = [];
+  let cursor = null;
+  do {
+    const query = new URLSearchParams(params);
+    query.set("limit", String(Math.min(PAGE_LIMIT, max - logs.length)));
+    if (cursor) {
+      query.set("cursor", cursor);
+    }
+    const response = await fetch("api/logs?" + query, { headers: authHeaders() });
+    if (!response.ok) {
+      let message = response.statusText;
+      try {
+        message = (await response.json()).error || message;
+      } catch {
+        // The status text is used
+      }
+      throw new Error(`GET /logs failed with ${response.status}: ${message}`);
+    }
+    logs.push(...(await response.json()));
+    cursor = nextCursor(response.headers.get("Link"));
+  } while (cursor && logs.length < max);
+  return { logs, truncated: Boolean(cursor) };
+}
+
+// windowStart returns the start of the selected window
+function windowStart() {
+  return Date.now() - state.window * 1000;
+}
+
+async function refresh() {
+  setStatus("Loading…");
+  const from = rfc3339(windowStart());
+  try {
+    const [all, alerts] = await Promise.all([
+      fetchLogs({ from }, MAX_LOGS),
+      fetchLogs({ from, severity_gte: ALERT_LEVEL }, MAX_LISTED),
+    ]);
+    state.logs = all.logs.sort((a, b) => timeOf(a) - timeOf(b));
+    state.alerts = alerts.logs.sort((a, b) => timeOf(b) - timeOf(a));
+    renderAll();
+    setStatus(all.truncated ? `Showing the first ${MAX_LOGS} logs of the window` : `Updated ${new Date().toLocaleTimeString()}`);
+  } catch (err) {
+    setStatus(err.message, true);
+  }
+  if (state.user) {
+    showUser(state.user);
+  }
+}
+
+function renderAll() {
+  const logs = state.logs.filter((log) => timeOf(log) >= windowStart());
+  $("activity-summary").textContent = summary(logs);
+  drawActivity($("activity-chart"), logs);
+  renderBars($("actions"), countBy(logs, (log) => log.action), TOP_ENTRIES);
+  renderBars($("users"), countBy(logs, (log) => log.userId), TOP_ENTRIES, showUser);
+  renderRows($("alerts"), state.alerts.slice(0, MAX_LISTED), (log) => [
+    formatTime(timeOf(log)), metadataOf(log).level || "", log.userId, log.action, log.resource, log.description,
+  ]);
+  $("alert-count").textContent = state.alerts.length ? String(state.alerts.length) : "";
+  renderRows($("recent"), logs.slice(-RECENT_ROWS).reverse(), (log) => [
+    formatTime(timeOf(log)), log.userId, log.action, log.resource, log.description,
+  ]);
+}
+
+function summary(logs) {
+  const users = new Set(logs.map((log) => log.userId));
+  const alerts = logs.filter(isAlert).length;
+  return `${logs.length} logs by ${users.size} users, ${alerts} at ${ALERT_LEVEL} or above`;
+}
+
+// countBy counts logs per key, the most frequent first
+function countBy(logs, key) {
+  const counts = new Map();
+  for (const log of logs) {
+    const k = key(log) || NONE;
+    counts.set(k, (counts.get(k) || 0) + 1);
+  }
+  return [...counts].sort((a, b) => b[1] - a[1] || a[0].localeCompare(b[0]));
+}
+
+function renderBars(list, counts, top, onClick) {
+  list.replaceChildren();
+  const max = counts.length ? counts[0][1] : 0;
+  for (const [label, count] of counts.slice(0, top)) {
+    const item = document.createElement("li");
+    const name = document.createElement("span");
+    name.className = "label";
+    name.textContent = label;
+    name.title = label;
+    const bar = document.createElement("span");
+    bar.className = "bar";
+    bar.style.width = `${(100 * count) / max}%`;
+    const value = document.createElement("span");
+    value.className = "value";
+    value.textContent = String(count);
+    item.append(name, bar, value);
+    if (onClick && label !== NONE) {
+      item.addEventListener("click", () => onClick(label));
+    }
+    list.append(item);
+  }
+}
+
+function renderRows(body, logs, cells) {
+  body.replaceChildren(...logs.map((log) => row(log, cells)));
+}
+
+function row(log, cells) {
+  const tr = document.createElement("tr");
+  for (const value of cells(log)) {
+    const td = document.createElement("td");
+    td.textContent = value || "";
+    tr.append(td);
+  }
+  tr.addEventListener("click", () => showDetail(log));
+  return tr;
+}
+
+// drawActivity draws the number of logs of each bucket of the window as bars
+function drawActivity(canvas, logs) {
+  const buckets = BUCKETS[state.window] || 60;
+  const size = (state.window * 1000) / buckets;
+  const start = windowStart();
+  const counts = new Array(buckets).fill(0);
+  const alerts = new Array(buckets).fill(0);
+  for (const log of logs) {
+    const i = Math.floor((timeOf(log) - start) / size);
+    if (i >= 0 && i < buckets) {
+      counts[i]++;
+      if (isAlert(log)) {
+        alerts[i]++;
+      }
+    }
+  }
+
+  // The height attribute is the height in CSS pixels; the canvas is scaled for the display
+  if (!canvas.dataset.height) {
+    canvas.dataset.height = canvas.height;
+    canvas.style.height = canvas.height + "px";
+  }
+  const ratio = window.devicePixelRatio || 1;
+  const width = canvas.clientWidth;
+  const height = Number(canvas.dataset.height);
+  canvas.width = width * ratio;
+  canvas.height = height * ratio;
+  const ctx = canvas.getContext("2d");
+  ctx.scale(ratio, ratio);
+  ctx.clearRect(0, 0, width, height);
+
+  const styles = getComputedStyle(document.documentElement);
+  const axis = 16;
+  const max = Math.max(1, ...counts);
+  const step = width / buckets;
+  for (let i = 0; i < buckets; i++) {
+    const barHeight = ((height - axis) * counts[i]) / max;
+    const alertHeight = ((height - axis) * alerts[i]) / max;
+    ctx.fillStyle = styles.getPropertyValue("--accent");
+    ctx.fillRect(i * step + 1, height - axis - barHeight, Math.max(1, step - 2), barHeight);
+    ctx.fillStyle = styles.getPropertyValue("--alert");
+    ctx.fillRect(i * step + 1, height - axis - alertHeight, Math.max(1, step - 2), alertHeight);
+  }
+
+  ctx.fillStyle = styles.getPropertyValue("--muted");
+  ctx.font = "11px system-ui, sans-serif";
+  ctx.textBaseline = "bottom";
+  ctx.fillText(formatTime(start), 0, height);
+  const end = "now, most per bar " + max;
+  ctx.fillText(end, width - ctx.measureText(end).width, height);
+}
+
+async function showUser(user) {
+  state.user = user;
+  $("user-panel").hidden = false;
+  $("user-name").textContent = user;
+  try {
+    const { logs, truncated } = await fetchLogs({ user, from: rfc3339(windowStart()) }, MAX_LOGS);
+    logs.sort((a, b) => timeOf(a) - timeOf(b));
+    if (state.user !== user) {
+      return;
+    }
+    let text = summary(logs).replace(/ by \d+ users/, "");
+    if (logs.length) {
+      text += `, last seen ${formatTime(timeOf(logs[logs.length - 1]))}`;
+    }
+    if (truncated) {
+      text += ` (the first ${MAX_LOGS})`;
+    }
+    $("user-summary").textContent = text;
+    drawActivity($("user-chart"), logs);
+    renderBars($("user-actions"), countBy(logs, (log) => log.action), TOP_ENTRIES);
+    renderRows($("user-logs"), logs.slice(-MAX_LISTED).reverse(), (log) => [
+      formatTime(timeOf(log)), log.action, log.resource, log.description,
+    ]);
+  } catch (err) {
+    setStatus(err.message, true);
+  }
+}
+
+function showDetail(log) {
+  const detail = { ...log };
+  if (log.metadata) {
+    detail.metadata = metadataOf(log);
+  }
+  $("detail-body").textContent = JSON.stringify(detail, null, 2);
+  $("detail").showModal();
+}
+
+// follow streams the logs committed from now on, adding them to the views as they arrive
+function follow() {
+  if (state.stream) {
+    state.stream.close();
+  }
+  const query = new URLSearchParams();
+  // EventSource cannot set headers, so the stream takes the credentials as a parameter
+  if (state.token) {
+    query.set("access_token", state.token);
+  }
+  const stream = new EventSource("api/logs/stream?" + query);
+  state.stream = stream;
+  stream.addEventListener("open", () => {
+    $("live").textContent = "live";
+    $("live").classList.add("on");
+  });
+  stream.addEventListener("error", () => {
+    // The browser reconnects, resuming after the last event received
+    $("live").textContent = "reconnecting";
+    $("live").classList.remove("on");
+  });
+  stream.addEventListener("log", (event) => {
+    const log = JSON.parse(event.data);
+    state.logs.push(log);
+    if (state.logs.length > MAX_LOGS) {
+      state.logs.shift();
+    }
+    if (isAlert(log)) {
+      state.alerts.unshift(log);
+      state.alerts.length = Math.min(state.alerts.length, MAX_LISTED);
+    }
+    renderAll();
+    $("recent").firstChild?.classList.add("new");
+    if (state.user === log.userId) {
+      showUser(log.userId);
+    }
+  });
+}
+
+function init() {
+  $("token").value = state.token;
+  $("settings").addEventListener("submit", (event) => {
+    event.preventDefault();
+    state.token = $("token").value.trim();
+    state.window = parseInt($("window").value, 10);
+    if (state.token) {
+      localStorage.setItem("dashboard.token", state.token);
+    } else {
+      localStorage.removeItem("dashboard.token");
+    }
+    refresh();
+    follow();
+  });
+  $("user-close").addEventListener("click", () => {
+    state.user = null;
+    $("user-panel").hidden = true;
+  });
+  $("detail-close").addEventListener("click", () => $("detail").close());
+  window.addEventListener("resize", renderAll);
+
+  state.window = parseInt($("window").value, 10);
+  refresh();
+  follow();
+}
+
+init();
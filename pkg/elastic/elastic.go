@@ -0,0 +1,225 @@
+// Package elastic maintains an Elasticsearch or OpenSearch index of the logs on the ledger,
+// so operators can search them with Kibana or OpenSearch Dashboards. The ledger remains the
+// source of truth: the index is a projection of chaincode events and can be rebuilt at any time.
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// DefaultIndex is the index logs are written to when Config.Index is empty
+const DefaultIndex = "fabric-logs"
+
+// Config describes the search cluster and index
+type Config struct {
+	// URL is the cluster endpoint, e.g. https://localhost:9200
+	URL   string
+	Index string
+	// Username and Password enable basic authentication
+	Username string
+	Password string
+	// APIKey enables Elasticsearch API key authentication, in its encoded form
+	APIKey string
+	// HTTPClient sends requests; http.DefaultClient is used when nil
+	HTTPClient *http.Client
+}
+
+// Document is the indexed form of a log, with its ledger position
+type Document struct {
+	client.LogEvent
+	BlockNumber   uint64 `json:"blockNumber"`
+	TransactionID string `json:"transactionId"`
+}
+
+// indexMapping keeps identifiers exact-match and the timestamp sortable, while leaving
+// descriptions and metadata full-text searchable
+var indexMapping = map[string]interface{}{
+	"mappings": map[string]interface{}{
+		"properties": map[string]interface{}{
+			"id":            map[string]string{"type": "keyword"},
+			"userId":        map[string]string{"type": "keyword"},
+			"action":        map[string]string{"type": "keyword"},
+			"resource":      map[string]string{"type": "keyword"},
+			"timestamp":     map[string]string{"type": "date"},
+			"description":   map[string]string{"type": "text"},
+			"metadata":      map[string]string{"type": "text"},
+			"collection":    map[string]string{"type": "keyword"},
+			"blockNumber":   map[string]string{"type": "long"},
+			"transactionId": map[string]string{"type": "keyword"},
+		},
+	},
+}
+
+// Sink is a projection.Sink keeping an index up to date with the logs on the ledger. Each log
+// is indexed under its ID, so events replayed after a restart rewrite the same documents rather
+// than duplicating them. Logs deleted by PruneLogs are deleted from the index in turn. Run it
+// with a projection.Projector:
+//
+//	sink, err := elastic.NewSink(ctx, elastic.Config{URL: "http://localhost:9200"})
+//	projector := projection.New(projection.NewEventSource(c, nil), sink, projection.Options{Checkpointer: checkpointer})
+//	err = projector.Run(ctx)
+type Sink struct {
+	config Config
+	http   *http.Client
+}
+
+// NewSink returns a Sink writing to the configured cluster, creating the index if needed
+func NewSink(ctx context.Context, config Config) (*Sink, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("no search cluster URL configured")
+	}
+	if config.Index == "" {
+		config.Index = DefaultIndex
+	}
+
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	s := &Sink{config: config, http: httpClient}
+	if err := s.EnsureIndex(ctx); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// EnsureIndex creates the index with its mapping unless it already exists
+func (s *Sink) EnsureIndex(ctx context.Context) error {
+	mapping, err := json.Marshal(indexMapping)
+	if err != nil {
+		return err
+	}
+
+	status, body, err := s.do(ctx, http.MethodPut, "/"+url.PathEscape(s.config.Index), mapping)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusBadRequest && strings.Contains(string(body), "resource_already_exists_exception") {
+		return nil
+	}
+	if status >= 300 {
+		return fmt.Errorf("failed to create index %s: %s: %s", s.config.Index, http.StatusText(status), body)
+	}
+
+	return nil
+}
+
+// Write indexes the logs carried by events with one bulk request, replacing any documents
+// with the same log IDs, and deletes the documents of pruned logs
+func (s *Sink) Write(ctx context.Context, events []client.ContractEvent) error {
+	var body bytes.Buffer
+	for _, event := range events {
+		if event.Pruned() {
+			action, err := json.Marshal(map[string]interface{}{
+				"delete": map[string]string{"_index": s.config.Index, "_id": event.Log.ID},
+			})
+			if err != nil {
+				return err
+			}
+			body.Write(action)
+			body.WriteByte('\n')
+			continue
+		}
+
+		action, err := json.Marshal(map[string]interface{}{
+			"index": map[string]string{"_index": s.config.Index, "_id": event.Log.ID},
+		})
+		if err != nil {
+			return err
+		}
+		doc, err := json.Marshal(Document{LogEvent: event.Log, BlockNumber: event.BlockNumber, TransactionID: event.TransactionID})
+		if err != nil {
+			return err
+		}
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(doc)
+		body.WriteByte('\n')
+	}
+
+	status, respBody, err := s.do(ctx, http.MethodPost, "/_bulk", body.Bytes())
+	if err != nil {
+		return err
+	}
+	if status >= 300 {
+		return fmt.Errorf("failed to index logs: %s: %s", http.StatusText(status), respBody)
+	}
+
+	return bulkError(respBody)
+}
+
+// bulkError returns the first item failure reported in a bulk response. Deleting a document
+// that is already gone, as when a batch is written again, is not a failure.
+func bulkError(respBody []byte) error {
+	var resp struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID     string          `json:"_id"`
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return fmt.Errorf("failed to parse bulk response: %v", err)
+	}
+	if !resp.Errors {
+		return nil
+	}
+
+	missing := false
+	for _, item := range resp.Items {
+		for action, result := range item {
+			if action == "delete" && result.Status == http.StatusNotFound {
+				missing = true
+				continue
+			}
+			if result.Status >= 300 {
+				return fmt.Errorf("failed to %s log %s: %s", action, result.ID, result.Error)
+			}
+		}
+	}
+	if missing {
+		return nil
+	}
+
+	return fmt.Errorf("bulk request reported errors")
+}
+
+// do sends a JSON request and returns the response status and body. Failed requests are
+// retried by the Projector, which writes the whole batch again.
+func (s *Sink) do(ctx context.Context, method string, path string, body []byte) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(s.config.URL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.config.APIKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+s.config.APIKey)
+	} else if s.config.Username != "" {
+		req.SetBasicAuth(s.config.Username, s.config.Password)
+	}
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to reach search cluster: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read search cluster response: %v", err)
+	}
+
+	return resp.StatusCode, respBody, nil
+}
@@ -0,0 +1,252 @@
+package evidence
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/hyperledger/fabric-protos-go/peer"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// transaction is an endorser transaction of a block, with the logs its chaincode events carry
+// and the world state keys it writes
+type transaction struct {
+	index          int
+	txID           string
+	channel        string
+	timestamp      time.Time
+	validationCode peer.TxValidationCode
+	// creatorMSPID and creator are the MSP and PEM certificate of the identity that submitted it
+	creatorMSPID string
+	creator      []byte
+	// function is the chaincode function invoked
+	function  string
+	eventName string
+	logs      []client.LogEvent
+	writes    []write
+}
+
+// write is a world state key set or deleted by a transaction
+type write struct {
+	namespace string
+	key       string
+	value     []byte
+	delete    bool
+}
+
+// blockTransactions returns the endorser transactions of block, in block order
+func blockTransactions(block *common.Block) ([]transaction, error) {
+	var filter []byte
+	if metadata := block.GetMetadata().GetMetadata(); len(metadata) > int(common.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+		filter = metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	}
+
+	var transactions []transaction
+	for i, data := range block.GetData().GetData() {
+		tx, err := parseTransaction(data)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d of block %d: %v", i, block.GetHeader().GetNumber(), err)
+		}
+		if tx == nil {
+			continue
+		}
+		tx.index = i
+		// A block without validation codes has not been validated, so none of its transactions is
+		tx.validationCode = peer.TxValidationCode_NOT_VALIDATED
+		if i < len(filter) {
+			tx.validationCode = peer.TxValidationCode(filter[i])
+		}
+		transactions = append(transactions, *tx)
+	}
+	return transactions, nil
+}
+
+// parseTransaction reads an envelope of a block, returning nil for envelopes other than
+// endorser transactions, such as configuration updates
+func parseTransaction(data []byte) (*transaction, error) {
+	var envelope common.Envelope
+	if err := proto.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to parse envelope: %v", err)
+	}
+	var payload common.Payload
+	if err := proto.Unmarshal(envelope.GetPayload(), &payload); err != nil {
+		return nil, fmt.Errorf("failed to parse payload: %v", err)
+	}
+	var channelHeader common.ChannelHeader
+	if err := proto.Unmarshal(payload.GetHeader().GetChannelHeader(), &channelHeader); err != nil {
+		return nil, fmt.Errorf("failed to parse channel header: %v", err)
+	}
+	if channelHeader.GetType() != int32(common.HeaderType_ENDORSER_TRANSACTION) {
+		return nil, nil
+	}
+
+	tx := &transaction{txID: channelHeader.GetTxId(), channel: channelHeader.GetChannelId()}
+	if timestamp := channelHeader.GetTimestamp(); timestamp != nil {
+		tx.timestamp = time.Unix(timestamp.GetSeconds(), int64(timestamp.GetNanos())).UTC()
+	}
+	var signatureHeader common.SignatureHeader
+	if err := proto.Unmarshal(payload.GetHeader().GetSignatureHeader(), &signatureHeader); err != nil {
+		return nil, fmt.Errorf("failed to parse signature header: %v", err)
+	}
+	var creator msp.SerializedIdentity
+	if err := proto.Unmarshal(signatureHeader.GetCreator(), &creator); err != nil {
+		return nil, fmt.Errorf("failed to parse creator: %v", err)
+	}
+	tx.creatorMSPID, tx.creator = creator.GetMspid(), creator.GetIdBytes()
+
+	var endorserTx peer.Transaction
+	if err := proto.Unmarshal(payload.GetData(), &endorserTx); err != nil {
+		return nil, fmt.Errorf("failed to parse transaction: %v", err)
+	}
+	for _, action := range endorserTx.GetActions() {
+		var actionPayload peer.ChaincodeActionPayload
+		if err := proto.Unmarshal(action.GetPayload(), &actionPayload); err != nil {
+			return nil, fmt.Errorf("failed to parse chaincode action payload: %v", err)
+		}
+		function, err := invokedFunction(actionPayload.GetChaincodeProposalPayload())
+		if err != nil {
+			return nil, err
+		}
+		if tx.function == "" {
+			tx.function = function
+		}
+		var responsePayload peer.ProposalResponsePayload
+		if err := proto.Unmarshal(actionPayload.GetAction().GetProposalResponsePayload(), &responsePayload); err != nil {
+			return nil, fmt.Errorf("failed to parse proposal response payload: %v", err)
+		}
+		var chaincodeAction peer.ChaincodeAction
+		if err := proto.Unmarshal(responsePayload.GetExtension(), &chaincodeAction); err != nil {
+			return nil, fmt.Errorf("failed to parse chaincode action: %v", err)
+		}
+		writes, err := actionWrites(chaincodeAction.GetResults())
+		if err != nil {
+			return nil, err
+		}
+		tx.writes = append(tx.writes, writes...)
+		if len(chaincodeAction.GetEvents()) == 0 {
+			continue
+		}
+		var event peer.ChaincodeEvent
+		if err := proto.Unmarshal(chaincodeAction.GetEvents(), &event); err != nil {
+			return nil, fmt.Errorf("failed to parse chaincode event: %v", err)
+		}
+		logs, err := eventLogs(&event)
+		if err != nil {
+			return nil, err
+		}
+		if logs != nil {
+			tx.eventName = event.GetEventName()
+			tx.logs = append(tx.logs, logs...)
+		}
+	}
+	return tx, nil
+}
+
+// invokedFunction returns the chaincode function a proposal payload invokes, without the
+// contract name contractapi accepts as a "<contract>:" prefix
+func invokedFunction(data []byte) (string, error) {
+	var proposalPayload peer.ChaincodeProposalPayload
+	if err := proto.Unmarshal(data, &proposalPayload); err != nil {
+		return "", fmt.Errorf("failed to parse chaincode proposal payload: %v", err)
+	}
+	var spec peer.ChaincodeInvocationSpec
+	if err := proto.Unmarshal(proposalPayload.GetInput(), &spec); err != nil {
+		return "", fmt.Errorf("failed to parse chaincode invocation: %v", err)
+	}
+	args := spec.GetChaincodeSpec().GetInput().GetArgs()
+	if len(args) == 0 {
+		return "", nil
+	}
+	function := string(args[0])
+	return function[strings.LastIndex(function, ":")+1:], nil
+}
+
+// actionWrites returns the public world state writes of the read-write set of a chaincode action
+func actionWrites(data []byte) ([]write, error) {
+	var txRWSet rwset.TxReadWriteSet
+	if err := proto.Unmarshal(data, &txRWSet); err != nil {
+		return nil, fmt.Errorf("failed to parse read-write set: %v", err)
+	}
+	var writes []write
+	for _, ns := range txRWSet.GetNsRwset() {
+		var kvRWSet kvrwset.KVRWSet
+		if err := proto.Unmarshal(ns.GetRwset(), &kvRWSet); err != nil {
+			return nil, fmt.Errorf("failed to parse read-write set of %s: %v", ns.GetNamespace(), err)
+		}
+		for _, w := range kvRWSet.GetWrites() {
+			writes = append(writes, write{namespace: ns.GetNamespace(), key: w.GetKey(), value: w.GetValue(), delete: w.GetIsDelete()})
+		}
+	}
+	return writes, nil
+}
+
+// eventLogs returns the logs carried by an event of the logging chaincode, or nil for other events
+func eventLogs(event *peer.ChaincodeEvent) ([]client.LogEvent, error) {
+	var logs []client.LogEvent
+	switch event.GetEventName() {
+	case client.LogCreatedEvent:
+		var log client.LogEvent
+		if err := json.Unmarshal(event.GetPayload(), &log); err != nil {
+			return nil, fmt.Errorf("failed to parse %s event: %v", event.GetEventName(), err)
+		}
+		logs = []client.LogEvent{log}
+	case client.LogsCreatedEvent:
+		if err := json.Unmarshal(event.GetPayload(), &logs); err != nil {
+			return nil, fmt.Errorf("failed to parse %s event: %v", event.GetEventName(), err)
+		}
+	default:
+		return nil, nil
+	}
+
+	for i := range logs {
+		metadata, err := client.DecodeMetadata(logs[i].Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metadata of log %s: %v", logs[i].ID, err)
+		}
+		logs[i].Metadata = metadata
+	}
+	return logs, nil
+}
+
+// carries reports whether tx carries a log equal to log, as ReadLog returns it
+func (tx *transaction) carries(log *client.LogEvent) bool {
+	for _, l := range tx.logs {
+		if l == *log {
+			return true
+		}
+	}
+	return false
+}
+
+// dataHash returns the hash of a block's data its header records
+func dataHash(block *common.Block) []byte {
+	digest := sha256.Sum256(bytes.Join(block.GetData().GetData(), nil))
+	return digest[:]
+}
+
+// headerHash returns the hash of a block's header, which the next block's header records as its
+// previous hash
+func headerHash(header *common.BlockHeader) ([]byte, error) {
+	encoded, err := asn1.Marshal(struct {
+		Number       *big.Int
+		PreviousHash []byte
+		DataHash     []byte
+	}{new(big.Int).SetUint64(header.GetNumber()), header.GetPreviousHash(), header.GetDataHash()})
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(encoded)
+	return digest[:], nil
+}
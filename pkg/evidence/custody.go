@@ -0,0 +1,258 @@
+package evidence
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go/peer"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// Kinds of custody steps. The chaincode writes a log once, when it is created or restored from
+// an archive, and deletes it when it is pruned; the retention policy decides when pruning is due.
+const (
+	StepCreated         = "created"
+	StepRestored        = "restored"
+	StepWritten         = "written"
+	StepPruned          = "pruned"
+	StepRetentionPolicy = "retention policy set"
+)
+
+// World state keys of the logging chaincode, composite keys of an object type and one attribute
+const (
+	logKeyPrefix       = "\x00LOG\x00"
+	retentionPolicyKey = "\x00CONFIG\x00retention\x00"
+)
+
+// custodyPageSize is the page size of the query for the current logs of a resource
+const custodyPageSize = 500
+
+// CustodyOptions configures CollectCustody. Exactly one of LogID and Resource must be set.
+type CustodyOptions struct {
+	// LogID selects the custody of one log
+	LogID string
+	// Resource selects the custody of every log of a resource
+	Resource string
+	// FromBlock is the first block read; steps committed before it are not covered
+	FromBlock uint64
+	// OnBlock, when set, is called with the number of each block read and the ledger's height
+	OnBlock func(number uint64, height uint64)
+}
+
+// Custody is the chain of custody of a log or of the logs of a resource: every transaction that
+// wrote or deleted them, or changed the retention policy once they existed, in commit order
+type Custody struct {
+	Channel     string    `json:"channel"`
+	LogID       string    `json:"logId,omitempty"`
+	Resource    string    `json:"resource,omitempty"`
+	GeneratedAt time.Time `json:"generatedAt"`
+	// FromBlock and ToBlock are the first and last blocks read
+	FromBlock uint64 `json:"fromBlock"`
+	ToBlock   uint64 `json:"toBlock"`
+	// Logs lists the IDs of the logs the steps concern
+	Logs  []string      `json:"logs"`
+	Steps []CustodyStep `json:"steps"`
+}
+
+// CustodyStep is one transaction of a chain of custody, with the references that locate it on
+// the ledger
+type CustodyStep struct {
+	// Time is the transaction's timestamp, as its submitter's clock set it
+	Time     time.Time `json:"time"`
+	Kind     string    `json:"kind"`
+	Function string    `json:"function"`
+	// Logs lists the IDs of the logs of the custody the transaction wrote or deleted
+	Logs []string `json:"logs,omitempty"`
+	// Applied reports whether the transaction was valid, so its writes took effect; invalid
+	// transactions are kept as attempts
+	Applied          bool   `json:"applied"`
+	ValidationCode   string `json:"validationCode"`
+	TransactionID    string `json:"transactionId"`
+	BlockNumber      uint64 `json:"blockNumber"`
+	TransactionIndex int    `json:"transactionIndex"`
+	// BlockHash is the hex SHA-256 of the block header, as the next block records it
+	BlockHash string `json:"blockHash"`
+	MSPID     string `json:"mspId"`
+	// Submitter is the common name of the submitter's certificate
+	Submitter string `json:"submitter"`
+}
+
+// CollectCustody reads the blocks from Options.FromBlock to the ledger's height, one query per
+// block, and records every endorser transaction that wrote or deleted the subject's logs. Logs
+// join the custody of a resource when a transaction writes them with that resource, or when the
+// world state holds them with it, so logs created before FromBlock and pruned since are missed.
+func CollectCustody(ctx context.Context, c *client.Client, options CustodyOptions) (*Custody, error) {
+	if (options.LogID == "") == (options.Resource == "") {
+		return nil, fmt.Errorf("one of a log ID and a resource is required")
+	}
+
+	custody := &Custody{
+		LogID:       options.LogID,
+		Resource:    options.Resource,
+		GeneratedAt: time.Now().UTC(),
+		FromBlock:   options.FromBlock,
+	}
+	tracked := map[string]bool{}
+	if options.LogID != "" {
+		tracked[options.LogID] = true
+	} else {
+		logs := c.IterateLogs(client.LogFilter{Resource: options.Resource}, custodyPageSize)
+		for {
+			log, err := logs.Next(ctx)
+			if errors.Is(err, client.ErrIteratorDone) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to query the logs of resource %s: %w", options.Resource, err)
+			}
+			tracked[log.ID] = true
+		}
+	}
+
+	height, err := c.BlockHeight(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the ledger height: %w", err)
+	}
+	if options.FromBlock >= height {
+		return nil, fmt.Errorf("the ledger has %d blocks, none from block %d", height, options.FromBlock)
+	}
+	custody.ToBlock = height - 1
+
+	involved := map[string]bool{}
+	for number := options.FromBlock; number < height; number++ {
+		if options.OnBlock != nil {
+			options.OnBlock(number, height)
+		}
+		block, err := c.BlockByNumber(ctx, number)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read block %d: %w", number, err)
+		}
+		transactions, err := blockTransactions(block)
+		if err != nil {
+			return nil, err
+		}
+
+		var blockHash string
+		for _, tx := range transactions {
+			step := custodyStep(&tx, options.Resource, tracked, len(involved) > 0)
+			if step == nil {
+				continue
+			}
+			if blockHash == "" {
+				hash, err := headerHash(block.GetHeader())
+				if err != nil {
+					return nil, fmt.Errorf("failed to hash the header of block %d: %v", number, err)
+				}
+				blockHash = hex.EncodeToString(hash)
+			}
+			step.BlockNumber = number
+			step.BlockHash = blockHash
+			for _, id := range step.Logs {
+				involved[id] = true
+			}
+			custody.Channel = tx.channel
+			custody.Steps = append(custody.Steps, *step)
+		}
+	}
+
+	if len(involved) == 0 {
+		subject := "log " + options.LogID
+		if options.Resource != "" {
+			subject = "the logs of resource " + options.Resource
+		}
+		return nil, fmt.Errorf("no transaction from block %d wrote %s", options.FromBlock, subject)
+	}
+	for id := range involved {
+		custody.Logs = append(custody.Logs, id)
+	}
+	sort.Strings(custody.Logs)
+	return custody, nil
+}
+
+// custodyStep returns the step of tx when it writes or deletes a tracked log, or a log of
+// resource when set, tracking the logs of resource that valid transactions write. Retention
+// policy changes are steps once started, when an earlier transaction wrote a tracked log.
+func custodyStep(tx *transaction, resource string, tracked map[string]bool, started bool) *CustodyStep {
+	valid := tx.validationCode == peer.TxValidationCode_VALID
+	var logs []string
+	var deleted, policy bool
+	for _, w := range tx.writes {
+		if w.key == retentionPolicyKey {
+			policy = true
+			continue
+		}
+		if !strings.HasPrefix(w.key, logKeyPrefix) || !strings.HasSuffix(w.key, "\x00") {
+			continue
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(w.key, logKeyPrefix), "\x00")
+		if !tracked[id] && resource != "" && !w.delete && ofResource(w.value, resource) {
+			if !valid {
+				// An attempt to write the log, which never joined the resource
+				logs = append(logs, id)
+				continue
+			}
+			tracked[id] = true
+		}
+		if tracked[id] {
+			logs = append(logs, id)
+			deleted = deleted || w.delete
+		}
+	}
+
+	var kind string
+	switch {
+	case deleted:
+		kind = StepPruned
+	case len(logs) > 0 && tx.function == "RestoreLogsBatch":
+		kind = StepRestored
+	case len(logs) > 0 && (tx.function == "CreateLog" || tx.function == "CreateLogsBatch" || tx.function == "CreatePrivateLog" || tx.function == "InitLedger"):
+		kind = StepCreated
+	case len(logs) > 0:
+		kind = StepWritten
+	case policy && started:
+		kind = StepRetentionPolicy
+	default:
+		return nil
+	}
+
+	return &CustodyStep{
+		Time:             tx.timestamp,
+		Kind:             kind,
+		Function:         tx.function,
+		Logs:             logs,
+		Applied:          valid,
+		ValidationCode:   tx.validationCode.String(),
+		TransactionID:    tx.txID,
+		TransactionIndex: tx.index,
+		MSPID:            tx.creatorMSPID,
+		Submitter:        commonName(tx.creator),
+	}
+}
+
+// ofResource reports whether value is a log of resource, as the chaincode stores it
+func ofResource(value []byte, resource string) bool {
+	var log client.LogEvent
+	return json.Unmarshal(value, &log) == nil && log.Resource == resource
+}
+
+// commonName returns the subject common name of a PEM certificate, or "" when it cannot be parsed
+func commonName(certPEM []byte) string {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return ""
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return ""
+	}
+	return cert.Subject.CommonName
+}
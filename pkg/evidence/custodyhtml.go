@@ -0,0 +1,58 @@
+package evidence
+
+import (
+	"html/template"
+	"io"
+	"time"
+)
+
+// custodyTemplate lays a chain of custody out as a single page with inline styles, like the
+// compliance reports of package report
+var custodyTemplate = template.Must(template.New("custody").Funcs(template.FuncMap{
+	"time": func(t time.Time) string { return t.Format("2006-01-02 15:04:05 UTC") },
+}).Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Chain of custody of {{if .LogID}}log {{.LogID}}{{else}}resource {{.Resource}}{{end}}</title>
+<style>
+body { font-family: system-ui, -apple-system, "Segoe UI", sans-serif; font-size: 14px; color: #1f2328; margin: 2rem; }
+h1 { font-size: 1.5rem; margin: 0 0 0.25rem; }
+h2 { font-size: 1.1rem; margin: 2rem 0 0.5rem; border-bottom: 1px solid #d0d7de; padding-bottom: 0.25rem; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 0.25rem 0.5rem; border-bottom: 1px solid #eaeef2; vertical-align: top; }
+td.number, th.number { text-align: right; }
+.muted { color: #656d76; }
+.mono { font-family: ui-monospace, SFMono-Regular, Menlo, monospace; font-size: 12px; word-break: break-all; }
+.alert { color: #cf222e; font-weight: 600; }
+</style>
+</head>
+<body>
+<h1>Chain of custody of {{if .LogID}}log {{.LogID}}{{else}}resource {{.Resource}}{{end}}</h1>
+<p class="muted">Channel {{.Channel}}, blocks {{.FromBlock}} to {{.ToBlock}}. Generated {{time .GeneratedAt}}.</p>
+
+<h2>Logs</h2>
+<p class="mono">{{range $i, $id := .Logs}}{{if $i}}, {{end}}{{$id}}{{end}}</p>
+
+<h2>Steps</h2>
+<p class="muted">In commit order. Times are the transactions' timestamps, set by their submitters.</p>
+<table>
+<tr><th>Time</th><th>Step</th><th>Submitter</th><th>Logs</th><th>On-chain reference</th></tr>
+{{- range .Steps}}
+<tr>
+<td>{{time .Time}}</td>
+<td>{{.Kind}}<br><span class="muted">{{.Function}}</span>{{if not .Applied}}<br><span class="alert">not applied: {{.ValidationCode}}</span>{{end}}</td>
+<td>{{.Submitter}}<br><span class="muted">{{.MSPID}}</span></td>
+<td class="mono">{{range $i, $id := .Logs}}{{if $i}}, {{end}}{{$id}}{{end}}</td>
+<td class="mono">block {{.BlockNumber}}, transaction {{.TransactionIndex}}<br>tx {{.TransactionID}}<br>header {{.BlockHash}}</td>
+</tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// RenderCustodyHTML writes custody as a standalone HTML document
+func RenderCustodyHTML(w io.Writer, custody *Custody) error {
+	return custodyTemplate.Execute(w, custody)
+}
@@ -0,0 +1,294 @@
+// Package evidence bundles logs with the proof that the ledger committed them: each log as the
+// ledger holds it, and the blocks of the transactions that wrote it, with their headers and
+// validation codes, read through the peer's ledger queries (qscc). A manifest lists the logs and
+// blocks with the hash of every file, and is signed with the exporter's Fabric identity, so a
+// third party can check the package offline, without access to the network. CollectCustody
+// reads the same blocks to trace the chain of custody of a log or resource.
+package evidence
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/peer"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/report"
+)
+
+// Names of the manifest and its detached signature in a package
+const (
+	ManifestName  = "manifest.json"
+	SignatureName = ManifestName + report.SignatureSuffix
+)
+
+// FormatVersion is the version of the package layout written by Write
+const FormatVersion = 1
+
+// Options configures Collect
+type Options struct {
+	// Receipts, when set, locates the transactions of the logs it holds receipts for, sparing
+	// the scan of the ledger's blocks
+	Receipts client.ReceiptStore
+	// FromBlock is the first block scanned for the transactions of logs without receipts
+	FromBlock uint64
+	// OnBlock, when set, is called with the number of each block scanned and the ledger's height
+	OnBlock func(number uint64, height uint64)
+}
+
+// Manifest describes the content of a package
+type Manifest struct {
+	Version   int          `json:"version"`
+	Channel   string       `json:"channel"`
+	CreatedAt time.Time    `json:"createdAt"`
+	Logs      []LogEntry   `json:"logs"`
+	Blocks    []BlockEntry `json:"blocks"`
+	// Files maps the name of every other file of the package to the hex SHA-256 of its content
+	Files map[string]string `json:"files"`
+}
+
+// LogEntry locates a log and the transaction that wrote it in a package
+type LogEntry struct {
+	ID   string `json:"id"`
+	File string `json:"file"`
+	// TransactionID and BlockNumber name the transaction that wrote the log, and
+	// TransactionIndex its position among the transactions of the block
+	TransactionID    string `json:"transactionId"`
+	BlockNumber      uint64 `json:"blockNumber"`
+	TransactionIndex int    `json:"transactionIndex"`
+	ValidationCode   string `json:"validationCode"`
+	EventName        string `json:"eventName"`
+}
+
+// BlockEntry describes a block of a package. The block file is the block as the peer stores
+// it, a serialized common.Block holding the transactions, their validation codes and the
+// orderer's signatures.
+type BlockEntry struct {
+	Number uint64 `json:"number"`
+	File   string `json:"file"`
+	// HeaderHash is the hex SHA-256 of the block header, which the next block records as its
+	// previous hash, and which can be compared with any copy of the ledger
+	HeaderHash   string `json:"headerHash"`
+	PreviousHash string `json:"previousHash"`
+	DataHash     string `json:"dataHash"`
+}
+
+// Package is the evidence collected for a set of logs
+type Package struct {
+	Manifest Manifest
+	logs     map[string]*client.LogEvent
+	blocks   map[uint64]*common.Block
+}
+
+// Collect reads the logs with the given IDs and the blocks of the valid transactions that
+// wrote them. A transaction is located by its receipt when Options.Receipts holds one, and
+// otherwise by scanning the blocks from Options.FromBlock, which reads a block per query; it
+// must carry the log exactly as the ledger holds it now.
+func Collect(ctx context.Context, c *client.Client, ids []string, options Options) (*Package, error) {
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no log IDs given")
+	}
+
+	p := &Package{
+		Manifest: Manifest{Version: FormatVersion, CreatedAt: time.Now().UTC()},
+		logs:     map[string]*client.LogEvent{},
+		blocks:   map[uint64]*common.Block{},
+	}
+	for _, id := range ids {
+		if p.logs[id] != nil {
+			continue
+		}
+		log, err := c.ReadLog(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read log %s: %w", id, err)
+		}
+		p.logs[id] = log
+	}
+
+	pending := map[string]bool{}
+	for id := range p.logs {
+		pending[id] = true
+	}
+
+	if options.Receipts != nil {
+		for id := range pending {
+			receipt, err := options.Receipts.Get(id)
+			if errors.Is(err, client.ErrReceiptNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			block, err := c.BlockByTxID(ctx, receipt.TransactionID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the block of transaction %s: %w", receipt.TransactionID, err)
+			}
+			if err := p.match(block, pending); err != nil {
+				return nil, err
+			}
+			if pending[id] {
+				return nil, fmt.Errorf("transaction %s of the receipt of log %s is not valid or does not carry the log as the ledger holds it", receipt.TransactionID, id)
+			}
+		}
+	}
+
+	if len(pending) > 0 {
+		height, err := c.BlockHeight(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the ledger height: %w", err)
+		}
+		for number := options.FromBlock; number < height && len(pending) > 0; number++ {
+			if options.OnBlock != nil {
+				options.OnBlock(number, height)
+			}
+			block, err := c.BlockByNumber(ctx, number)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read block %d: %w", number, err)
+			}
+			if err := p.match(block, pending); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for id := range pending {
+		return nil, fmt.Errorf("no valid transaction from block %d carries log %s as the ledger holds it", options.FromBlock, id)
+	}
+
+	sort.Slice(p.Manifest.Logs, func(i, j int) bool { return p.Manifest.Logs[i].ID < p.Manifest.Logs[j].ID })
+	sort.Slice(p.Manifest.Blocks, func(i, j int) bool { return p.Manifest.Blocks[i].Number < p.Manifest.Blocks[j].Number })
+	return p, nil
+}
+
+// match records the valid transactions of block carrying pending logs, and keeps the block
+// when any does
+func (p *Package) match(block *common.Block, pending map[string]bool) error {
+	transactions, err := blockTransactions(block)
+	if err != nil {
+		return err
+	}
+
+	number := block.GetHeader().GetNumber()
+	for _, tx := range transactions {
+		if tx.validationCode != peer.TxValidationCode_VALID {
+			continue
+		}
+		for _, l := range tx.logs {
+			if !pending[l.ID] || !tx.carries(p.logs[l.ID]) {
+				continue
+			}
+			delete(pending, l.ID)
+			p.Manifest.Channel = tx.channel
+			p.Manifest.Logs = append(p.Manifest.Logs, LogEntry{
+				ID:               l.ID,
+				File:             "logs/" + url.PathEscape(l.ID) + ".json",
+				TransactionID:    tx.txID,
+				BlockNumber:      number,
+				TransactionIndex: tx.index,
+				ValidationCode:   tx.validationCode.String(),
+				EventName:        tx.eventName,
+			})
+			if p.blocks[number] == nil {
+				if err := p.addBlock(block); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Package) addBlock(block *common.Block) error {
+	header := block.GetHeader()
+	hash, err := headerHash(header)
+	if err != nil {
+		return fmt.Errorf("failed to hash the header of block %d: %v", header.GetNumber(), err)
+	}
+	p.blocks[header.GetNumber()] = block
+	p.Manifest.Blocks = append(p.Manifest.Blocks, BlockEntry{
+		Number:       header.GetNumber(),
+		File:         "blocks/" + strconv.FormatUint(header.GetNumber(), 10) + ".block",
+		HeaderHash:   hex.EncodeToString(hash),
+		PreviousHash: hex.EncodeToString(header.GetPreviousHash()),
+		DataHash:     hex.EncodeToString(header.GetDataHash()),
+	})
+	return nil
+}
+
+// Write writes the package to w as a zip archive of the logs as JSON, the blocks, the manifest
+// and its signature by id
+func (p *Package) Write(w io.Writer, id *client.Identity, sign client.Sign) error {
+	files := map[string][]byte{}
+	for _, entry := range p.Manifest.Logs {
+		data, err := json.MarshalIndent(p.logs[entry.ID], "", "  ")
+		if err != nil {
+			return err
+		}
+		files[entry.File] = data
+	}
+	for _, entry := range p.Manifest.Blocks {
+		data, err := proto.Marshal(p.blocks[entry.Number])
+		if err != nil {
+			return fmt.Errorf("failed to serialize block %d: %v", entry.Number, err)
+		}
+		files[entry.File] = data
+	}
+
+	manifest := p.Manifest
+	manifest.Files = make(map[string]string, len(files))
+	for name, data := range files {
+		digest := sha256.Sum256(data)
+		manifest.Files[name] = hex.EncodeToString(digest[:])
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	signature, err := report.Sign(ManifestName, manifestJSON, id, sign)
+	if err != nil {
+		return err
+	}
+	signatureJSON, err := json.MarshalIndent(signature, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	archive := zip.NewWriter(w)
+	add := func(name string, data []byte) error {
+		fw, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: manifest.CreatedAt})
+		if err != nil {
+			return err
+		}
+		_, err = fw.Write(data)
+		return err
+	}
+	if err := add(ManifestName, manifestJSON); err != nil {
+		return err
+	}
+	if err := add(SignatureName, signatureJSON); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := add(name, files[name]); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
@@ -0,0 +1,177 @@
+package evidence
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/peer"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/report"
+)
+
+// ErrInvalidEvidence is returned when a package does not prove what its manifest claims
+var ErrInvalidEvidence = errors.New("invalid evidence package")
+
+// Verification is the result of checking a package
+type Verification struct {
+	Manifest  Manifest
+	Signature report.Signature
+	// Signer is the certificate the manifest was signed with
+	Signer *x509.Certificate
+}
+
+// Verify checks a package offline: the manifest's signature and, when roots is not nil, that
+// its signer chains to one of roots; the hash of every file; that each block's data matches the
+// data hash of its header, and its header the header hash of the manifest; and that each log
+// was written, exactly as packaged, by a transaction the block marks valid. Compare the header
+// hashes with a copy of the channel's ledger, or check the orderer signatures of the blocks, to
+// tie the blocks to the channel.
+func Verify(r io.ReaderAt, size int64, roots *x509.CertPool) (*Verification, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEvidence, err)
+	}
+	files := map[string][]byte{}
+	for _, f := range archive.File {
+		if _, ok := files[f.Name]; ok {
+			return nil, fmt.Errorf("%w: %s appears twice", ErrInvalidEvidence, f.Name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidEvidence, f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidEvidence, f.Name, err)
+		}
+		files[f.Name] = data
+	}
+
+	manifestJSON, signatureJSON := files[ManifestName], files[SignatureName]
+	if manifestJSON == nil || signatureJSON == nil {
+		return nil, fmt.Errorf("%w: no %s or %s", ErrInvalidEvidence, ManifestName, SignatureName)
+	}
+	v := &Verification{}
+	if err := json.Unmarshal(signatureJSON, &v.Signature); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse signature: %v", ErrInvalidEvidence, err)
+	}
+	if v.Signer, err = report.Verify(manifestJSON, &v.Signature, roots); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEvidence, err)
+	}
+	if err := json.Unmarshal(manifestJSON, &v.Manifest); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse manifest: %v", ErrInvalidEvidence, err)
+	}
+	if v.Manifest.Version != FormatVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidEvidence, v.Manifest.Version)
+	}
+
+	for name, data := range files {
+		if name == ManifestName || name == SignatureName {
+			continue
+		}
+		want, ok := v.Manifest.Files[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is not listed in the manifest", ErrInvalidEvidence, name)
+		}
+		digest := sha256.Sum256(data)
+		if hex.EncodeToString(digest[:]) != want {
+			return nil, fmt.Errorf("%w: %s does not match its hash", ErrInvalidEvidence, name)
+		}
+	}
+	for name := range v.Manifest.Files {
+		if files[name] == nil {
+			return nil, fmt.Errorf("%w: %s is missing", ErrInvalidEvidence, name)
+		}
+	}
+
+	blocks := map[uint64]*common.Block{}
+	for _, entry := range v.Manifest.Blocks {
+		block, err := verifyBlock(entry, files[entry.File])
+		if err != nil {
+			return nil, fmt.Errorf("%w: block %d: %v", ErrInvalidEvidence, entry.Number, err)
+		}
+		blocks[entry.Number] = block
+	}
+	for _, entry := range v.Manifest.Logs {
+		if err := verifyLog(entry, files[entry.File], blocks[entry.BlockNumber]); err != nil {
+			return nil, fmt.Errorf("%w: log %s: %v", ErrInvalidEvidence, entry.ID, err)
+		}
+	}
+
+	return v, nil
+}
+
+// verifyBlock parses a block file and checks its hashes against its entry
+func verifyBlock(entry BlockEntry, data []byte) (*common.Block, error) {
+	if data == nil {
+		return nil, fmt.Errorf("no file %s", entry.File)
+	}
+	var block common.Block
+	if err := proto.Unmarshal(data, &block); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", entry.File, err)
+	}
+
+	header := block.GetHeader()
+	if header.GetNumber() != entry.Number {
+		return nil, fmt.Errorf("%s holds block %d", entry.File, header.GetNumber())
+	}
+	if !bytes.Equal(dataHash(&block), header.GetDataHash()) {
+		return nil, fmt.Errorf("its data does not match the data hash of its header")
+	}
+	hash, err := headerHash(header)
+	if err != nil {
+		return nil, err
+	}
+	if hex.EncodeToString(hash) != entry.HeaderHash {
+		return nil, fmt.Errorf("its header does not match the header hash %s", entry.HeaderHash)
+	}
+	return &block, nil
+}
+
+// verifyLog checks that the transaction of entry is valid in block and carries the log of data
+func verifyLog(entry LogEntry, data []byte, block *common.Block) error {
+	if data == nil {
+		return fmt.Errorf("no file %s", entry.File)
+	}
+	var log client.LogEvent
+	if err := json.Unmarshal(data, &log); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", entry.File, err)
+	}
+	if log.ID != entry.ID {
+		return fmt.Errorf("%s holds log %s", entry.File, log.ID)
+	}
+	if block == nil {
+		return fmt.Errorf("block %d is not in the package", entry.BlockNumber)
+	}
+
+	transactions, err := blockTransactions(block)
+	if err != nil {
+		return err
+	}
+	for _, tx := range transactions {
+		if tx.index != entry.TransactionIndex {
+			continue
+		}
+		switch {
+		case tx.txID != entry.TransactionID:
+			return fmt.Errorf("transaction %d of block %d is %s, not %s", tx.index, entry.BlockNumber, tx.txID, entry.TransactionID)
+		case tx.validationCode != peer.TxValidationCode_VALID:
+			return fmt.Errorf("transaction %s is marked %s", tx.txID, tx.validationCode)
+		case !tx.carries(&log):
+			return fmt.Errorf("transaction %s does not carry the log as packaged", tx.txID)
+		}
+		return nil
+	}
+	return fmt.Errorf("block %d has no endorser transaction %d", entry.BlockNumber, entry.TransactionIndex)
+}
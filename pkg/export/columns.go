@@ -0,0 +1,81 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// Column maps a log to one output field
+type Column struct {
+	Name  string
+	Value func(log *client.LogEvent) string
+}
+
+// DefaultColumns exports every log field under its JSON name
+var DefaultColumns = []Column{
+	{Name: "id", Value: func(log *client.LogEvent) string { return log.ID }},
+	{Name: "userId", Value: func(log *client.LogEvent) string { return log.UserID }},
+	{Name: "action", Value: func(log *client.LogEvent) string { return log.Action }},
+	{Name: "resource", Value: func(log *client.LogEvent) string { return log.Resource }},
+	{Name: "timestamp", Value: func(log *client.LogEvent) string { return log.Timestamp }},
+	{Name: "description", Value: func(log *client.LogEvent) string { return log.Description }},
+	{Name: "metadata", Value: func(log *client.LogEvent) string { return log.Metadata }},
+}
+
+// MetadataColumn exports the value of a top-level metadata key. Strings are written as they
+// are and other values as JSON; the field is empty when the key or metadata is missing.
+func MetadataColumn(name string, key string) Column {
+	return Column{Name: name, Value: func(log *client.LogEvent) string {
+		var metadata map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(log.Metadata), &metadata); err != nil {
+			return ""
+		}
+
+		raw, ok := metadata[key]
+		if !ok {
+			return ""
+		}
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			return s
+		}
+		return string(raw)
+	}}
+}
+
+// SelectColumns returns the default columns with the given names, in that order
+func SelectColumns(names ...string) ([]Column, error) {
+	columns := make([]Column, 0, len(names))
+	for _, name := range names {
+		column, ok := defaultColumn(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		columns = append(columns, column)
+	}
+
+	return columns, nil
+}
+
+func defaultColumn(name string) (Column, bool) {
+	for _, column := range DefaultColumns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	return Column{}, false
+}
+
+// validateColumns rejects duplicate column names, which no format can represent
+func validateColumns(columns []Column) error {
+	seen := map[string]bool{}
+	for _, column := range columns {
+		if seen[column.Name] {
+			return fmt.Errorf("duplicate column %q", column.Name)
+		}
+		seen[column.Name] = true
+	}
+	return nil
+}
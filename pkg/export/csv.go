@@ -0,0 +1,44 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// CSVWriter writes logs as CSV with a header row of column names
+type CSVWriter struct {
+	w       *csv.Writer
+	columns []Column
+	record  []string
+}
+
+// NewCSVWriter returns a CSVWriter and writes the header row
+func NewCSVWriter(w io.Writer, columns []Column) (*CSVWriter, error) {
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.Name
+	}
+
+	cw := &CSVWriter{w: csv.NewWriter(w), columns: columns, record: make([]string, len(columns))}
+	if err := cw.w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return cw, nil
+}
+
+// Write appends one row
+func (cw *CSVWriter) Write(log *client.LogEvent) error {
+	for i, column := range cw.columns {
+		cw.record[i] = column.Value(log)
+	}
+	return cw.w.Write(cw.record)
+}
+
+// Close flushes buffered rows
+func (cw *CSVWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
@@ -0,0 +1,231 @@
+// Package export streams logs from the ledger into CSV, NDJSON or Parquet files for
+// compliance extracts and offline analysis.
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// Format is an export file format
+type Format string
+
+// Supported export formats
+const (
+	FormatCSV     Format = "csv"
+	FormatNDJSON  Format = "ndjson"
+	FormatParquet Format = "parquet"
+)
+
+// DefaultProgressInterval is how many records are written between progress callbacks
+const DefaultProgressInterval = 1000
+
+// Source yields logs one at a time until it returns client.ErrIteratorDone, as a
+// client.LogIterator does
+type Source interface {
+	Next(ctx context.Context) (*client.LogEvent, error)
+}
+
+// Writer encodes logs into one output file
+type Writer interface {
+	Write(log *client.LogEvent) error
+	// Close flushes buffered output; it does not close the underlying io.Writer
+	Close() error
+}
+
+// NewWriter returns a Writer encoding logs in format to w
+func NewWriter(format Format, w io.Writer, columns []Column) (Writer, error) {
+	if err := validateColumns(columns); err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatCSV:
+		return NewCSVWriter(w, columns)
+	case FormatNDJSON:
+		return NewNDJSONWriter(w, columns), nil
+	case FormatParquet:
+		return NewParquetWriter(w, columns), nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// Progress reports how far an export has got
+type Progress struct {
+	Records int
+	// Files lists the files completed so far
+	Files []string
+	// Done is set on the final callback, after the last file is complete
+	Done bool
+}
+
+// Options configures Export
+type Options struct {
+	Format Format
+	// Columns maps logs to output fields; DefaultColumns is used when empty
+	Columns []Column
+	// Directory receives the output files, named Prefix-00001.<format> and so on
+	Directory string
+	Prefix    string
+	// MaxRecordsPerFile starts a new file after that many records; zero writes a single file
+	MaxRecordsPerFile int
+	// Progress, when set, is called every ProgressInterval records and whenever a file is completed
+	Progress         func(Progress)
+	ProgressInterval int
+}
+
+// Export drains source into files in options.Directory and returns their paths. Each file is
+// written to a temporary name and renamed once complete, so a failed or canceled export never
+// leaves a partial file behind under a final name.
+func Export(ctx context.Context, source Source, options Options) ([]string, error) {
+	if len(options.Columns) == 0 {
+		options.Columns = DefaultColumns
+	}
+	if options.Prefix == "" {
+		options.Prefix = "logs"
+	}
+	if options.ProgressInterval <= 0 {
+		options.ProgressInterval = DefaultProgressInterval
+	}
+	if _, err := NewWriter(options.Format, io.Discard, options.Columns); err != nil {
+		return nil, err
+	}
+
+	e := &exporter{options: options}
+	defer e.abort()
+
+	for {
+		log, err := source.Next(ctx)
+		if errors.Is(err, client.ErrIteratorDone) {
+			break
+		}
+		if err != nil {
+			return e.progress.Files, err
+		}
+
+		if err := e.write(log); err != nil {
+			return e.progress.Files, err
+		}
+	}
+
+	if err := e.finish(); err != nil {
+		return e.progress.Files, err
+	}
+	e.progress.Done = true
+	e.report()
+
+	return e.progress.Files, nil
+}
+
+// exporter tracks the file being written by Export
+type exporter struct {
+	options  Options
+	progress Progress
+
+	file     *os.File
+	writer   Writer
+	records  int
+	path     string
+	tempPath string
+}
+
+func (e *exporter) write(log *client.LogEvent) error {
+	if e.file == nil {
+		if err := e.open(); err != nil {
+			return err
+		}
+	}
+
+	if err := e.writer.Write(log); err != nil {
+		return fmt.Errorf("failed to write log %s: %v", log.ID, err)
+	}
+	e.records++
+	e.progress.Records++
+
+	if e.options.MaxRecordsPerFile > 0 && e.records >= e.options.MaxRecordsPerFile {
+		return e.finish()
+	}
+	if e.progress.Records%e.options.ProgressInterval == 0 {
+		e.report()
+	}
+
+	return nil
+}
+
+func (e *exporter) open() error {
+	name := fmt.Sprintf("%s-%05d.%s", e.options.Prefix, len(e.progress.Files)+1, e.options.Format)
+	e.path = filepath.Join(e.options.Directory, name)
+	e.tempPath = filepath.Join(e.options.Directory, "."+name+".tmp")
+
+	file, err := os.OpenFile(e.tempPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %v", err)
+	}
+
+	writer, err := NewWriter(e.options.Format, file, e.options.Columns)
+	if err != nil {
+		file.Close()
+		os.Remove(e.tempPath)
+		return err
+	}
+
+	e.file = file
+	e.writer = writer
+	e.records = 0
+
+	return nil
+}
+
+// finish completes the current file, if any, and moves it to its final name
+func (e *exporter) finish() error {
+	if e.file == nil {
+		return nil
+	}
+
+	err := e.writer.Close()
+	if err == nil {
+		err = e.file.Sync()
+	}
+	if closeErr := e.file.Close(); err == nil {
+		err = closeErr
+	}
+	e.file = nil
+	if err != nil {
+		os.Remove(e.tempPath)
+		return fmt.Errorf("failed to write export file: %v", err)
+	}
+
+	if err := os.Rename(e.tempPath, e.path); err != nil {
+		os.Remove(e.tempPath)
+		return fmt.Errorf("failed to write export file: %v", err)
+	}
+
+	e.progress.Files = append(e.progress.Files, e.path)
+	e.report()
+
+	return nil
+}
+
+// abort discards an unfinished file
+func (e *exporter) abort() {
+	if e.file != nil {
+		e.file.Close()
+		os.Remove(e.tempPath)
+		e.file = nil
+	}
+}
+
+func (e *exporter) report() {
+	if e.options.Progress != nil {
+		progress := e.progress
+		progress.Files = append([]string(nil), e.progress.Files...)
+		e.options.Progress(progress)
+	}
+}
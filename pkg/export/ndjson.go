@@ -0,0 +1,53 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// NDJSONWriter writes each log as a JSON object on its own line, with keys in column order
+type NDJSONWriter struct {
+	w       *bufio.Writer
+	columns []Column
+	line    bytes.Buffer
+}
+
+// NewNDJSONWriter returns an NDJSONWriter
+func NewNDJSONWriter(w io.Writer, columns []Column) *NDJSONWriter {
+	return &NDJSONWriter{w: bufio.NewWriter(w), columns: columns}
+}
+
+// Write appends one line
+func (nw *NDJSONWriter) Write(log *client.LogEvent) error {
+	nw.line.Reset()
+	nw.line.WriteByte('{')
+	for i, column := range nw.columns {
+		if i > 0 {
+			nw.line.WriteByte(',')
+		}
+		name, err := json.Marshal(column.Name)
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(column.Value(log))
+		if err != nil {
+			return err
+		}
+		nw.line.Write(name)
+		nw.line.WriteByte(':')
+		nw.line.Write(value)
+	}
+	nw.line.WriteString("}\n")
+
+	_, err := nw.w.Write(nw.line.Bytes())
+	return err
+}
+
+// Close flushes buffered lines
+func (nw *NDJSONWriter) Close() error {
+	return nw.w.Flush()
+}
@@ -0,0 +1,57 @@
+package export
+
+import (
+	"io"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/parquet-go/parquet-go"
+)
+
+// ParquetWriter writes logs as a zstd compressed Parquet file with one string column per Column
+type ParquetWriter struct {
+	w       *parquet.Writer
+	columns []Column
+	// indexes holds the Parquet column index of each Column; the schema orders columns by name
+	indexes []int
+	row     parquet.Row
+}
+
+// NewParquetWriter returns a ParquetWriter
+func NewParquetWriter(w io.Writer, columns []Column) *ParquetWriter {
+	group := parquet.Group{}
+	for _, column := range columns {
+		group[column.Name] = parquet.String()
+	}
+	schema := parquet.NewSchema("log", group)
+
+	position := map[string]int{}
+	for i, field := range schema.Fields() {
+		position[field.Name()] = i
+	}
+	indexes := make([]int, len(columns))
+	for i, column := range columns {
+		indexes[i] = position[column.Name]
+	}
+
+	return &ParquetWriter{
+		w:       parquet.NewWriter(w, schema, parquet.Compression(&parquet.Zstd)),
+		columns: columns,
+		indexes: indexes,
+		row:     make(parquet.Row, len(columns)),
+	}
+}
+
+// Write appends one row
+func (pw *ParquetWriter) Write(log *client.LogEvent) error {
+	for i, column := range pw.columns {
+		index := pw.indexes[i]
+		pw.row[index] = parquet.ByteArrayValue([]byte(column.Value(log))).Level(0, 0, index)
+	}
+	_, err := pw.w.WriteRows([]parquet.Row{pw.row})
+	return err
+}
+
+// Close writes the buffered row groups and the file footer
+func (pw *ParquetWriter) Close() error {
+	return pw.w.Close()
+}
@@ -0,0 +1,282 @@
+package export
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/parquet-go/parquet-go"
+)
+
+// setField stores value in the log field exported under name by DefaultColumns and reports
+// whether name is such a column. Other columns cannot be mapped back to a log and are ignored.
+func setField(log *client.LogEvent, name string, value string) bool {
+	switch name {
+	case "id":
+		log.ID = value
+	case "userId":
+		log.UserID = value
+	case "action":
+		log.Action = value
+	case "resource":
+		log.Resource = value
+	case "timestamp":
+		log.Timestamp = value
+	case "description":
+		log.Description = value
+	case "metadata":
+		log.Metadata = value
+	case "collection":
+		log.Collection = value
+	default:
+		return false
+	}
+	return true
+}
+
+// NDJSONReader reads logs back from NDJSON written by NDJSONWriter, or from any stream of
+// JSON encoded client.LogEvent objects
+type NDJSONReader struct {
+	decoder *json.Decoder
+}
+
+// NewNDJSONReader returns an NDJSONReader
+func NewNDJSONReader(r io.Reader) *NDJSONReader {
+	return &NDJSONReader{decoder: json.NewDecoder(bufio.NewReader(r))}
+}
+
+// Next returns the next log, or client.ErrIteratorDone at the end of the input
+func (nr *NDJSONReader) Next(ctx context.Context) (*client.LogEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var fields map[string]string
+	if err := nr.decoder.Decode(&fields); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, client.ErrIteratorDone
+		}
+		return nil, fmt.Errorf("failed to parse NDJSON record: %v", err)
+	}
+
+	log := &client.LogEvent{}
+	for name, value := range fields {
+		setField(log, name, value)
+	}
+	return log, nil
+}
+
+// CSVReader reads logs back from CSV written by CSVWriter
+type CSVReader struct {
+	r      *csv.Reader
+	header []string
+}
+
+// NewCSVReader returns a CSVReader and reads the header row
+func NewCSVReader(r io.Reader) (*CSVReader, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %v", err)
+	}
+	cr.ReuseRecord = true
+
+	return &CSVReader{r: cr, header: header}, nil
+}
+
+// Next returns the next log, or client.ErrIteratorDone at the end of the input
+func (cr *CSVReader) Next(ctx context.Context) (*client.LogEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	record, err := cr.r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, client.ErrIteratorDone
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSV record: %v", err)
+	}
+
+	log := &client.LogEvent{}
+	for i, value := range record {
+		setField(log, cr.header[i], value)
+	}
+	return log, nil
+}
+
+// ParquetReader reads logs back from a file written by ParquetWriter
+type ParquetReader struct {
+	r     *parquet.Reader
+	names []string
+	rows  []parquet.Row
+}
+
+// NewParquetReader returns a ParquetReader for the size bytes of r
+func NewParquetReader(r io.ReaderAt, size int64) (*ParquetReader, error) {
+	file, err := parquet.OpenFile(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Parquet file: %v", err)
+	}
+
+	fields := file.Schema().Fields()
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = field.Name()
+	}
+
+	return &ParquetReader{r: parquet.NewReader(file), names: names, rows: make([]parquet.Row, 1)}, nil
+}
+
+// Next returns the next log, or client.ErrIteratorDone at the end of the input
+func (pr *ParquetReader) Next(ctx context.Context) (*client.LogEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	n, err := pr.r.ReadRows(pr.rows)
+	if n == 0 {
+		if err == nil || errors.Is(err, io.EOF) {
+			return nil, client.ErrIteratorDone
+		}
+		return nil, fmt.Errorf("failed to read Parquet row: %v", err)
+	}
+
+	log := &client.LogEvent{}
+	for _, value := range pr.rows[0] {
+		if !value.IsNull() {
+			setField(log, pr.names[value.Column()], string(value.ByteArray()))
+		}
+	}
+	return log, nil
+}
+
+// Close releases the reader
+func (pr *ParquetReader) Close() error {
+	return pr.r.Close()
+}
+
+// FileSource reads the logs of one export file
+type FileSource struct {
+	Source
+	closers []io.Closer
+}
+
+// OpenFile opens an export file, choosing the reader from its extension: .csv, .ndjson or
+// .parquet, optionally followed by .gz for gzip compressed files such as archived windows
+func OpenFile(path string) (*FileSource, error) {
+	name := strings.ToLower(filepath.Base(path))
+	compressed := strings.HasSuffix(name, ".gz")
+	name = strings.TrimSuffix(name, ".gz")
+	format := Format(strings.TrimPrefix(filepath.Ext(name), "."))
+	if format == FormatParquet && compressed {
+		return nil, fmt.Errorf("gzip compressed Parquet files are not supported: %s", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open export file: %v", err)
+	}
+	fs := &FileSource{closers: []io.Closer{file}}
+
+	var r io.Reader = file
+	if compressed {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			fs.Close()
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		fs.closers = append(fs.closers, gz)
+		r = gz
+	}
+
+	switch format {
+	case FormatCSV:
+		fs.Source, err = NewCSVReader(r)
+	case FormatNDJSON:
+		fs.Source = NewNDJSONReader(r)
+	case FormatParquet:
+		fs.Source, err = openParquet(file)
+	default:
+		err = fmt.Errorf("unsupported export format %q", format)
+	}
+	if err != nil {
+		fs.Close()
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	return fs, nil
+}
+
+func openParquet(file *os.File) (*ParquetReader, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return NewParquetReader(file, info.Size())
+}
+
+// Close closes the file
+func (fs *FileSource) Close() error {
+	var errs []error
+	for i := len(fs.closers) - 1; i >= 0; i-- {
+		errs = append(errs, fs.closers[i].Close())
+	}
+	return errors.Join(errs...)
+}
+
+// FileSet reads the logs of several export files in turn, such as the numbered files of one
+// export, opening each with OpenFile only once the previous one is exhausted
+type FileSet struct {
+	paths   []string
+	current *FileSource
+}
+
+// NewFileSet returns a FileSet reading paths in order
+func NewFileSet(paths ...string) *FileSet {
+	return &FileSet{paths: paths}
+}
+
+// Next returns the next log, or client.ErrIteratorDone after the last file
+func (set *FileSet) Next(ctx context.Context) (*client.LogEvent, error) {
+	for {
+		if set.current == nil {
+			if len(set.paths) == 0 {
+				return nil, client.ErrIteratorDone
+			}
+			current, err := OpenFile(set.paths[0])
+			if err != nil {
+				return nil, err
+			}
+			set.current = current
+			set.paths = set.paths[1:]
+		}
+
+		log, err := set.current.Next(ctx)
+		if !errors.Is(err, client.ErrIteratorDone) {
+			return log, err
+		}
+		if err := set.Close(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Close closes the file being read
+func (set *FileSet) Close() error {
+	if set.current == nil {
+		return nil
+	}
+	err := set.current.Close()
+	set.current = nil
+	return err
+}
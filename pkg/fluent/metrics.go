@@ -0,0 +1,52 @@
+package fluent
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricsNamespace prefixes every metric exported by the server, as it does the client's
+const metricsNamespace = "fabric_logging"
+
+// metrics holds the Prometheus instruments of a Server
+type metrics struct {
+	records      *prometheus.CounterVec
+	authFailures prometheus.Counter
+	connections  prometheus.Gauge
+}
+
+func newMetrics(registerer prometheus.Registerer) (*metrics, error) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	m := &metrics{
+		records: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "fluent",
+			Name:      "records_total",
+			Help:      "Records received over the forward protocol, by result: submitted or failed.",
+		}, []string{"result"}),
+		authFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "fluent",
+			Name:      "auth_failures_total",
+			Help:      "Connections that failed the shared key handshake.",
+		}),
+		connections: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "fluent",
+			Name:      "connections",
+			Help:      "Open connections of forward senders.",
+		}),
+	}
+
+	for _, collector := range []prometheus.Collector{m.records, m.authFailures, m.connections} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, fmt.Errorf("failed to register fluent metrics: %v", err)
+		}
+	}
+
+	return m, nil
+}
@@ -0,0 +1,332 @@
+package fluent
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// The forward protocol is MessagePack throughout. This file implements the part of the format
+// it uses: decoding any value, with the EventTime extension as a time.Time, and encoding the
+// handshake and ack responses.
+
+// eventTimeExt is the MessagePack extension type of an EventTime
+const eventTimeExt = 0
+
+// maxDepth bounds the nesting of arrays and maps, which are decoded recursively
+const maxDepth = 64
+
+// maxPrealloc bounds the elements allocated ahead of decoding them
+const maxPrealloc = 1024
+
+// errTooLarge is returned when a value exceeds the bytes a decoder may read
+var errTooLarge = errors.New("message too large")
+
+// decoder reads MessagePack values, failing once more than limit bytes have been read since
+// the last reset
+type decoder struct {
+	r     io.Reader
+	buf   [8]byte
+	limit int64
+	read  int64
+	depth int
+}
+
+func newDecoder(r io.Reader, limit int64) *decoder {
+	return &decoder{r: r, limit: limit}
+}
+
+// reset starts counting the bytes of the next value
+func (d *decoder) reset() {
+	d.read = 0
+}
+
+func (d *decoder) readFull(p []byte) error {
+	if d.read+int64(len(p)) > d.limit {
+		return errTooLarge
+	}
+	n, err := io.ReadFull(d.r, p)
+	d.read += int64(n)
+	return err
+}
+
+func (d *decoder) bytes(n int) ([]byte, error) {
+	if int64(n) > d.limit-d.read {
+		return nil, errTooLarge
+	}
+	p := make([]byte, n)
+	return p, d.readFull(p)
+}
+
+func (d *decoder) uint(size int) (uint64, error) {
+	p := d.buf[:size]
+	if err := d.readFull(p); err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(p[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(p)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(p)), nil
+	default:
+		return binary.BigEndian.Uint64(p), nil
+	}
+}
+
+// length reads a length of size bytes, refusing lengths the remaining limit cannot hold
+func (d *decoder) length(size int) (int, error) {
+	n, err := d.uint(size)
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(d.limit-d.read) {
+		return 0, errTooLarge
+	}
+	return int(n), nil
+}
+
+// next reads the next value, returning io.EOF only when the input ends before it
+func (d *decoder) next() (interface{}, error) {
+	start := d.read
+	value, err := d.decode()
+	if errors.Is(err, io.EOF) && d.read > start {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return value, err
+}
+
+// decode reads a value. Maps become map[string]interface{}, with keys that are not
+// strings formatted; str values become strings and bin values []byte.
+func (d *decoder) decode() (interface{}, error) {
+	if err := d.readFull(d.buf[:1]); err != nil {
+		return nil, err
+	}
+	b := d.buf[0]
+
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xf0 == 0x80:
+		return d.decodeMap(int(b & 0x0f))
+	case b&0xf0 == 0x90:
+		return d.decodeArray(int(b & 0x0f))
+	case b&0xe0 == 0xa0:
+		p, err := d.bytes(int(b & 0x1f))
+		return string(p), err
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6, 0xd9, 0xda, 0xdb:
+		size := map[byte]int{0xc4: 1, 0xc5: 2, 0xc6: 4, 0xd9: 1, 0xda: 2, 0xdb: 4}[b]
+		n, err := d.length(size)
+		if err != nil {
+			return nil, err
+		}
+		p, err := d.bytes(n)
+		if b >= 0xd9 {
+			return string(p), err
+		}
+		return p, err
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.length(map[byte]int{0xc7: 1, 0xc8: 2, 0xc9: 4}[b])
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeExt(n)
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.decodeExt(map[byte]int{0xd4: 1, 0xd5: 2, 0xd6: 4, 0xd7: 8, 0xd8: 16}[b])
+	case 0xca:
+		n, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := d.uint(8)
+		return math.Float64frombits(n), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := d.uint(map[byte]int{0xcc: 1, 0xcd: 2, 0xce: 4, 0xcf: 8}[b])
+		if err != nil {
+			return nil, err
+		}
+		if n > math.MaxInt64 {
+			return n, nil
+		}
+		return int64(n), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := map[byte]int{0xd0: 1, 0xd1: 2, 0xd2: 4, 0xd3: 8}[b]
+		n, err := d.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		shift := 64 - 8*size
+		return int64(n<<shift) >> shift, nil
+	case 0xdc, 0xdd:
+		n, err := d.length(map[byte]int{0xdc: 2, 0xdd: 4}[b])
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(n)
+	case 0xde, 0xdf:
+		n, err := d.length(map[byte]int{0xde: 2, 0xdf: 4}[b])
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(n)
+	}
+	return nil, fmt.Errorf("invalid MessagePack type 0x%02x", b)
+}
+
+func (d *decoder) decodeArray(n int) ([]interface{}, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+
+	// n is only trusted as far as the bytes read bear it out
+	values := make([]interface{}, 0, min(n, maxPrealloc))
+	for i := 0; i < n; i++ {
+		value, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func (d *decoder) decodeMap(n int) (map[string]interface{}, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+
+	values := make(map[string]interface{}, min(n, maxPrealloc))
+	for i := 0; i < n; i++ {
+		key, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		switch k := key.(type) {
+		case string:
+			values[k] = value
+		case []byte:
+			values[string(k)] = value
+		default:
+			values[fmt.Sprint(k)] = value
+		}
+	}
+	return values, nil
+}
+
+func (d *decoder) enter() error {
+	if d.depth >= maxDepth {
+		return fmt.Errorf("values nested more than %d deep", maxDepth)
+	}
+	d.depth++
+	return nil
+}
+
+func (d *decoder) leave() {
+	d.depth--
+}
+
+// decodeExt reads the type and n data bytes of an extension. EventTimes become time.Time;
+// other extensions are kept as their data.
+func (d *decoder) decodeExt(n int) (interface{}, error) {
+	if err := d.readFull(d.buf[:1]); err != nil {
+		return nil, err
+	}
+	ext := int8(d.buf[0])
+	p, err := d.bytes(n)
+	if err != nil {
+		return nil, err
+	}
+	if ext == eventTimeExt && n == 8 {
+		return time.Unix(int64(binary.BigEndian.Uint32(p[:4])), int64(binary.BigEndian.Uint32(p[4:]))), nil
+	}
+	return p, nil
+}
+
+// encode appends the MessagePack encoding of v, which may be nil, a bool, an int, a string,
+// a []byte, a []interface{} or a map[string]interface{}, whose keys are written sorted
+func encode(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case int:
+		buf.WriteByte(0xd3)
+		binary.Write(buf, binary.BigEndian, int64(v))
+	case string:
+		writeHeader(buf, len(v), 0xa0, 31, 0xd9, 0xda, 0xdb)
+		buf.WriteString(v)
+	case []byte:
+		writeHeader(buf, len(v), 0, -1, 0xc4, 0xc5, 0xc6)
+		buf.Write(v)
+	case []interface{}:
+		writeHeader(buf, len(v), 0x90, 15, 0, 0xdc, 0xdd)
+		for _, value := range v {
+			if err := encode(buf, value); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		writeHeader(buf, len(v), 0x80, 15, 0, 0xde, 0xdf)
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := encode(buf, key); err != nil {
+				return err
+			}
+			if err := encode(buf, v[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cannot encode %T as MessagePack", v)
+	}
+	return nil
+}
+
+// writeHeader writes the type and length of a value of n bytes or elements, in its fixed form
+// when n is at most fixMax and otherwise in the 8, 16 or 32 bit form; a zero code has no such form
+func writeHeader(buf *bytes.Buffer, n int, fix byte, fixMax int, code8, code16, code32 byte) {
+	switch {
+	case n <= fixMax:
+		buf.WriteByte(fix | byte(n))
+	case n <= math.MaxUint8 && code8 != 0:
+		buf.WriteByte(code8)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(code16)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(code32)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
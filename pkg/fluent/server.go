@@ -0,0 +1,499 @@
+// Package fluent receives logs from Fluentd and Fluent Bit over the Fluent forward protocol,
+// so existing pipelines add the ledger as one more forward output. It accepts the Message,
+// Forward, PackedForward and CompressedPackedForward modes over TCP or TLS, authenticates
+// senders by shared key when one is configured, and acknowledges chunks once their records are
+// submitted.
+package fluent
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultMaxMessageSize is the largest forward message read by default, above the chunk size
+// of Fluent Bit and Fluentd
+const DefaultMaxMessageSize = 32 << 20
+
+// handshakeTimeout bounds the shared key handshake of a connection, and maxHandshakeSize its PING
+const (
+	handshakeTimeout = 10 * time.Second
+	maxHandshakeSize = 64 << 10
+)
+
+// backpressureWait is how long a connection waits before retrying a record the submitter pushed back
+const backpressureWait = 100 * time.Millisecond
+
+// Record keys read for the fields of a log. The id, userId, action and resource keys fill the
+// log's fields, the first message key found becomes its description and the level key its
+// severity; the tag and every other key are kept in the metadata.
+const (
+	LevelKey = "level"
+	TagKey   = "tag"
+)
+
+// DefaultMessageKeys are the record keys the description is read from, in order: message as
+// structured loggers write it, log as Fluent Bit's tail and Docker inputs do, and msg
+var DefaultMessageKeys = []string{"message", "log", "msg"}
+
+// Options configures a Server
+type Options struct {
+	// Submitter receives the logs, usually a BatchingSubmitter
+	Submitter client.Submitter
+	// Flush submits every log handed to Submitter, such as BatchingSubmitter.Flush. Chunks
+	// whose sender asks for an ack are only acknowledged once flushed, so the sender keeps
+	// them until they are committed; without Flush they are acknowledged once handed over.
+	Flush func(ctx context.Context) error
+	// SharedKey, when set, is required of senders in the handshake of the forward protocol
+	SharedKey string
+	// Hostname is the server's name in the handshake; os.Hostname is used when empty
+	Hostname string
+	// UserID is used for records without a userId key
+	UserID string
+	// Action is used for records without an action key; LOG_<LEVEL> is recorded otherwise
+	Action string
+	// MessageKeys are the record keys the description is read from; DefaultMessageKeys is used when empty
+	MessageKeys []string
+	// MaxMessageSize is the largest forward message read; DefaultMaxMessageSize is used when zero
+	MaxMessageSize int64
+	// Registerer receives the fabric_logging_fluent_* metrics; prometheus.DefaultRegisterer is used when nil
+	Registerer prometheus.Registerer
+	// OnError is called with the records the Submitter rejected and the connections that failed
+	OnError func(error)
+}
+
+// Server receives forward messages and submits their records as logs. Each record's tag is
+// its log's resource unless the record has a resource key.
+type Server struct {
+	options Options
+	handler *client.SlogHandler
+	metrics *metrics
+}
+
+// NewServer returns a Server submitting through options.Submitter
+func NewServer(options Options) (*Server, error) {
+	if options.Submitter == nil {
+		return nil, fmt.Errorf("no submitter configured")
+	}
+	if options.Hostname == "" {
+		options.Hostname, _ = os.Hostname()
+	}
+	if len(options.MessageKeys) == 0 {
+		options.MessageKeys = DefaultMessageKeys
+	}
+	if options.MaxMessageSize <= 0 {
+		options.MaxMessageSize = DefaultMaxMessageSize
+	}
+
+	m, err := newMetrics(options.Registerer)
+	if err != nil {
+		return nil, err
+	}
+	handler := client.NewSlogHandler(options.Submitter, client.SlogHandlerOptions{
+		Level:  slog.LevelDebug - 4,
+		UserID: options.UserID,
+	})
+	return &Server{options: options, handler: handler, metrics: m}, nil
+}
+
+// Serve accepts connections on listener until ctx is done, then closes listener and the
+// connections. While the submitter pushes back, a connection is not read from, so TCP flow
+// control holds its sender back.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	stop := context.AfterFunc(ctx, func() { listener.Close() })
+	defer stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.serveConn(ctx, conn); err != nil && ctx.Err() == nil {
+				s.report(fmt.Errorf("closed connection from %s: %w", conn.RemoteAddr(), err))
+			}
+		}()
+	}
+}
+
+// ServeTLS serves listener as Serve does, over TLS with config
+func (s *Server) ServeTLS(ctx context.Context, listener net.Listener, config *tls.Config) error {
+	return s.Serve(ctx, tls.NewListener(listener, config))
+}
+
+// serveConn authenticates the sender of a connection and handles its messages until it ends
+// or ctx is done
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) error {
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	defer conn.Close()
+
+	s.metrics.connections.Inc()
+	defer s.metrics.connections.Dec()
+
+	d := newDecoder(bufio.NewReader(conn), s.options.MaxMessageSize)
+	if s.options.SharedKey != "" {
+		// Senders are held to a small PING until they are authenticated
+		conn.SetDeadline(time.Now().Add(handshakeTimeout))
+		d.limit = maxHandshakeSize
+		if err := s.handshake(conn, d); err != nil {
+			s.metrics.authFailures.Inc()
+			return err
+		}
+		d.limit = s.options.MaxMessageSize
+		conn.SetDeadline(time.Time{})
+	}
+
+	for {
+		d.reset()
+		message, err := d.next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		chunk, err := s.handle(ctx, message, conn.RemoteAddr())
+		if err != nil {
+			return err
+		}
+		if chunk == "" {
+			continue
+		}
+		if s.options.Flush != nil {
+			if err := s.options.Flush(ctx); err != nil {
+				// Without an ack the sender sends the chunk again
+				return fmt.Errorf("failed to flush chunk %s: %w", chunk, err)
+			}
+		}
+		if err := write(conn, map[string]interface{}{"ack": chunk}); err != nil {
+			return err
+		}
+	}
+}
+
+// handshake sends HELO and checks the PING answering it against the shared key, replying PONG
+func (s *Server) handshake(conn net.Conn, d *decoder) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	helo := []interface{}{"HELO", map[string]interface{}{"nonce": nonce, "auth": "", "keepalive": true}}
+	if err := write(conn, helo); err != nil {
+		return err
+	}
+
+	value, err := d.next()
+	if err != nil {
+		return fmt.Errorf("failed to read PING: %w", err)
+	}
+	ping, ok := value.([]interface{})
+	if !ok || len(ping) < 4 || text(ping[0]) != "PING" {
+		return fmt.Errorf("expected PING")
+	}
+	hostname, salt, digest := text(ping[1]), text(ping[2]), text(ping[3])
+
+	if hostname == s.options.Hostname {
+		s.pong(conn, false, "the sender has the server's hostname", salt, nonce)
+		return fmt.Errorf("sender %s has the server's hostname", hostname)
+	}
+	expected := sharedKeyDigest(salt, hostname, nonce, s.options.SharedKey)
+	if subtle.ConstantTimeCompare([]byte(digest), []byte(expected)) != 1 {
+		s.pong(conn, false, "shared key mismatch", salt, nonce)
+		return fmt.Errorf("sender %s failed shared key authentication", hostname)
+	}
+	return s.pong(conn, true, "", salt, nonce)
+}
+
+func (s *Server) pong(conn net.Conn, ok bool, reason string, salt string, nonce []byte) error {
+	digest := ""
+	if ok {
+		digest = sharedKeyDigest(salt, s.options.Hostname, nonce, s.options.SharedKey)
+	}
+	return write(conn, []interface{}{"PONG", ok, reason, s.options.Hostname, digest})
+}
+
+// sharedKeyDigest is the hex SHA-512 digest of salt, hostname, nonce and key that proves
+// knowledge of the shared key
+func sharedKeyDigest(salt string, hostname string, nonce []byte, key string) string {
+	h := sha512.New()
+	h.Write([]byte(salt))
+	h.Write([]byte(hostname))
+	h.Write(nonce)
+	h.Write([]byte(key))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// handle submits the records of a message and returns the chunk ID to acknowledge, if any.
+// Malformed messages are errors, which end the connection; records the submitter rejects are
+// reported and skipped, as sending them again would not help.
+func (s *Server) handle(ctx context.Context, message interface{}, sender net.Addr) (string, error) {
+	parts, ok := message.([]interface{})
+	if !ok || len(parts) < 2 || len(parts) > 4 {
+		return "", fmt.Errorf("a forward message must be an array of 2 to 4 elements")
+	}
+	tag := text(parts[0])
+	if tag == "" {
+		return "", fmt.Errorf("a forward message must start with a tag")
+	}
+
+	var events [][]interface{}
+	var option interface{}
+	switch entries := parts[1].(type) {
+	case []interface{}:
+		// Forward mode: [tag, [[time, record], ...], option]
+		for _, entry := range entries {
+			event, ok := entry.([]interface{})
+			if !ok || len(event) != 2 {
+				return "", fmt.Errorf("a forward entry must be an array of time and record")
+			}
+			events = append(events, event)
+		}
+		if len(parts) > 2 {
+			option = parts[2]
+		}
+	case string, []byte:
+		// PackedForward mode: [tag, entries packed one after another, option]
+		if len(parts) > 2 {
+			option = parts[2]
+		}
+		packed, err := s.unpack([]byte(text(entries)), option)
+		if err != nil {
+			return "", err
+		}
+		events = packed
+	default:
+		// Message mode: [tag, time, record, option]
+		if len(parts) < 3 {
+			return "", fmt.Errorf("a message must have a time and a record")
+		}
+		events = [][]interface{}{parts[1:3]}
+		if len(parts) > 3 {
+			option = parts[3]
+		}
+	}
+
+	for _, event := range events {
+		t, ok := eventTime(event[0])
+		if !ok {
+			return "", fmt.Errorf("invalid event time %v", event[0])
+		}
+		record, ok := event[1].(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("an event record must be a map")
+		}
+		if err := s.submit(ctx, tag, t, record, sender); err != nil {
+			return "", err
+		}
+	}
+
+	options, _ := option.(map[string]interface{})
+	return text(options["chunk"]), nil
+}
+
+// unpack decodes the entries of a PackedForward message, gunzipping them when the option
+// says they are compressed
+func (s *Server) unpack(packed []byte, option interface{}) ([][]interface{}, error) {
+	options, _ := option.(map[string]interface{})
+	var r io.Reader = bytes.NewReader(packed)
+	switch compressed := text(options["compressed"]); compressed {
+	case "", "text":
+	case "gzip":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid compressed entries: %v", err)
+		}
+		defer gz.Close()
+		r = gz
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", compressed)
+	}
+
+	// The decompressed entries are bounded like a message, so a small bomb cannot expand unbounded
+	d := newDecoder(r, s.options.MaxMessageSize)
+	var events [][]interface{}
+	for {
+		value, err := d.next()
+		if errors.Is(err, io.EOF) {
+			return events, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid packed entries: %v", err)
+		}
+		event, ok := value.([]interface{})
+		if !ok || len(event) != 2 {
+			return nil, fmt.Errorf("a packed entry must be an array of time and record")
+		}
+		events = append(events, event)
+	}
+}
+
+// submit hands the log of a record to the submitter, waiting while it pushes back
+func (s *Server) submit(ctx context.Context, tag string, t time.Time, record map[string]interface{}, sender net.Addr) error {
+	r := s.record(tag, t, record)
+	for {
+		err := s.handler.Handle(ctx, r)
+		switch {
+		case err == nil:
+			s.metrics.records.WithLabelValues("submitted").Inc()
+		case errors.Is(err, client.ErrBackpressure):
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backpressureWait):
+			}
+			continue
+		default:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			s.metrics.records.WithLabelValues("failed").Inc()
+			s.report(fmt.Errorf("failed to submit a record of %s from %s: %w", tag, sender, err))
+		}
+		return nil
+	}
+}
+
+// record builds the slog record of a forwarded record
+func (s *Server) record(tag string, t time.Time, record map[string]interface{}) slog.Record {
+	fields := make(map[string]interface{}, len(record)+1)
+	for key, value := range record {
+		fields[key] = plain(value)
+	}
+
+	message := ""
+	for _, key := range s.options.MessageKeys {
+		if value, ok := fields[key]; ok {
+			message = strings.TrimRight(fmt.Sprint(value), "\r\n")
+			delete(fields, key)
+			break
+		}
+	}
+
+	level := slog.LevelInfo
+	if value, ok := fields[LevelKey]; ok {
+		if parsed, ok := client.ParseSeverity(fmt.Sprint(value)); ok {
+			level = parsed
+			delete(fields, LevelKey)
+		}
+	}
+
+	for _, key := range []string{client.SlogIDKey, client.SlogUserIDKey, client.SlogActionKey, client.SlogResourceKey} {
+		if value, ok := fields[key]; ok {
+			fields[key] = fmt.Sprint(value)
+		}
+	}
+	if _, ok := fields[client.SlogActionKey]; !ok && s.options.Action != "" {
+		fields[client.SlogActionKey] = s.options.Action
+	}
+	if _, ok := fields[client.SlogResourceKey]; !ok {
+		fields[client.SlogResourceKey] = tag
+	}
+	if _, ok := fields[TagKey]; !ok {
+		fields[TagKey] = tag
+	}
+
+	r := slog.NewRecord(t, level, client.Truncate(message, client.MaxDescriptionLength), 0)
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		r.AddAttrs(slog.Any(key, fields[key]))
+	}
+	return r
+}
+
+func (s *Server) report(err error) {
+	if s.options.OnError != nil {
+		s.options.OnError(err)
+	}
+}
+
+// write sends a MessagePack value
+func write(conn net.Conn, v interface{}) error {
+	var buf bytes.Buffer
+	if err := encode(&buf, v); err != nil {
+		return err
+	}
+	_, err := conn.Write(buf.Bytes())
+	return err
+}
+
+// eventTime reads an event time, given as an EventTime or as whole or fractional Unix seconds
+func eventTime(v interface{}) (time.Time, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, true
+	case int64:
+		return time.Unix(v, 0), true
+	case uint64:
+		return time.Unix(int64(v), 0), true
+	case float64:
+		sec := int64(v)
+		return time.Unix(sec, int64((v-float64(sec))*1e9)), true
+	}
+	return time.Time{}, false
+}
+
+// text returns a str or bin value as a string, and "" for any other value
+func text(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return ""
+}
+
+// plain converts the bin values in a decoded value to strings, as older Fluentd versions send
+// strings as raw bytes, so they are recorded as text rather than base64
+func plain(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = plain(v[i])
+		}
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = plain(v[key])
+		}
+	}
+	return v
+}
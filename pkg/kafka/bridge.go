@@ -0,0 +1,216 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/segmentio/kafka-go"
+)
+
+// Bridge defaults
+const (
+	DefaultBatchSize     = client.DefaultMaxBatchSize
+	DefaultFlushInterval = client.DefaultFlushInterval
+)
+
+// commitTimeout bounds the commit of offsets whose logs were submitted as the bridge stops
+const commitTimeout = 10 * time.Second
+
+// BridgeOptions configures a Bridge
+type BridgeOptions struct {
+	// Mapping maps messages to logs; when nil, messages are read as the JSON logs a Sink
+	// publishes, so one network's logs can be drained into another's
+	Mapping *Mapping
+	// BatchSize is the most messages submitted in one transaction; DefaultBatchSize is used otherwise
+	BatchSize int
+	// FlushInterval is the longest a consumed message waits to be submitted; DefaultFlushInterval
+	// is used otherwise
+	FlushInterval time.Duration
+	// Retry controls how failed submissions are retried; client.DefaultRetryPolicy is used when zero
+	Retry client.RetryPolicy
+	// Registerer receives the fabric_logging_kafka_bridge_* metrics; prometheus.DefaultRegisterer is used when nil
+	Registerer prometheus.Registerer
+	// OnError is called with the messages skipped because they do not map to a valid log
+	OnError func(error)
+}
+
+// Bridge consumes a topic as a member of a consumer group and records its messages on the
+// ledger. Offsets are committed only once the logs of the messages before them are committed
+// on the ledger, so a message is delivered at least once; as a message always maps to the same
+// log ID, one delivered again is recognised as already recorded.
+type Bridge struct {
+	target  client.LoggingClient
+	reader  *kafka.Reader
+	mapper  *mapper
+	options BridgeOptions
+	metrics *metrics
+}
+
+// NewBridge returns a Bridge consuming topic on the given brokers as a member of groupID and
+// submitting to target. A group without committed offsets starts at the oldest message.
+func NewBridge(target client.LoggingClient, brokers []string, topic, groupID string, options BridgeOptions) (*Bridge, error) {
+	return NewBridgeWithReader(target, kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     brokers,
+		Topic:       topic,
+		GroupID:     groupID,
+		StartOffset: kafka.FirstOffset,
+	}), options)
+}
+
+// NewBridgeWithReader returns a Bridge consuming through a configured reader, for TLS, SASL or
+// other transport settings. The reader must have a GroupID, and commit synchronously, which it
+// does when its CommitInterval is zero.
+func NewBridgeWithReader(target client.LoggingClient, reader *kafka.Reader, options BridgeOptions) (*Bridge, error) {
+	if reader.Config().GroupID == "" {
+		return nil, fmt.Errorf("the reader has no consumer group")
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultBatchSize
+	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = DefaultFlushInterval
+	}
+	if options.Retry.MaxAttempts == 0 {
+		options.Retry = client.DefaultRetryPolicy
+	}
+
+	m, err := newMapper(options.Mapping)
+	if err != nil {
+		return nil, err
+	}
+	metrics, err := newMetrics(options.Registerer)
+	if err != nil {
+		return nil, err
+	}
+	return &Bridge{target: target, reader: reader, mapper: m, options: options, metrics: metrics}, nil
+}
+
+// Run consumes messages until ctx is done, returning nil then, or until a batch cannot be
+// submitted within the retry policy. Messages consumed but not committed are delivered again
+// to the group's next consumer.
+func (b *Bridge) Run(ctx context.Context) error {
+	for {
+		messages, err := b.fetch(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to consume messages: %v", err)
+		}
+
+		if err := b.submit(ctx, b.logs(messages)); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		// The logs are on the ledger, so their offsets are committed even as the bridge stops
+		commitCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), commitTimeout)
+		err = b.reader.CommitMessages(commitCtx, messages...)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to commit offsets: %v", err)
+		}
+	}
+}
+
+// Close leaves the consumer group and closes the reader
+func (b *Bridge) Close() error {
+	return b.reader.Close()
+}
+
+// fetch waits for a message and returns it with those that follow it within the flush
+// interval, up to the batch size
+func (b *Bridge) fetch(ctx context.Context) ([]kafka.Message, error) {
+	first, err := b.reader.FetchMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	messages := []kafka.Message{first}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, b.options.FlushInterval)
+	defer cancel()
+	for len(messages) < b.options.BatchSize {
+		msg, err := b.reader.FetchMessage(fetchCtx)
+		if errors.Is(err, context.DeadlineExceeded) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// logs maps messages to logs, skipping and reporting those that do not map to a valid log, as
+// consuming them again would not help
+func (b *Bridge) logs(messages []kafka.Message) []client.LogEvent {
+	logs := make([]client.LogEvent, 0, len(messages))
+	for _, msg := range messages {
+		log, err := b.mapper.log(msg)
+		if err == nil {
+			err = client.ValidateLog(log)
+		}
+		if err != nil {
+			b.metrics.messages.WithLabelValues("invalid").Inc()
+			b.report(fmt.Errorf("skipped message %d of %s/%d: %w", msg.Offset, msg.Topic, msg.Partition, err))
+			continue
+		}
+		logs = append(logs, log)
+	}
+	return logs
+}
+
+// submit records logs in one transaction, retrying failures with backoff
+func (b *Bridge) submit(ctx context.Context, logs []client.LogEvent) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := b.create(ctx, logs)
+		if err == nil {
+			b.metrics.messages.WithLabelValues("submitted").Add(float64(len(logs)))
+			return nil
+		}
+		b.metrics.failures.Inc()
+		if attempt >= b.options.Retry.MaxAttempts || ctx.Err() != nil {
+			return fmt.Errorf("failed to submit %d logs: %w", len(logs), err)
+		}
+
+		timer := time.NewTimer(b.options.Retry.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (b *Bridge) create(ctx context.Context, logs []client.LogEvent) error {
+	err := b.target.CreateLogsBatch(ctx, logs)
+	if !errors.Is(err, client.ErrAlreadyExists) {
+		return err
+	}
+
+	// Some messages were delivered before; the batch is all or nothing, so submit them one by one
+	for _, log := range logs {
+		if err := b.target.CreateLog(ctx, log); err != nil && !errors.Is(err, client.ErrAlreadyExists) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Bridge) report(err error) {
+	if b.options.OnError != nil {
+		b.options.OnError(err)
+	}
+}
@@ -0,0 +1,203 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/segmentio/kafka-go"
+	"gopkg.in/yaml.v2"
+)
+
+// Mapping turns consumed messages into logs with a text/template per field, executed on a
+// Message. The ID defaults to the message's topic, partition and offset, so a message
+// consumed again maps to the log already recorded; the timestamp defaults to the message's
+// time and the metadata to its position in the topic. The other fields are required.
+type Mapping struct {
+	ID          string `json:"id,omitempty" yaml:"id,omitempty"`
+	UserID      string `json:"userId" yaml:"userId"`
+	Action      string `json:"action" yaml:"action"`
+	Resource    string `json:"resource" yaml:"resource"`
+	Timestamp   string `json:"timestamp,omitempty" yaml:"timestamp,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Metadata must execute to a JSON object
+	Metadata string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+}
+
+// LoadMapping reads a Mapping file, in YAML or JSON
+func LoadMapping(path string) (*Mapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mapping: %v", err)
+	}
+
+	var mapping Mapping
+	if err := yaml.UnmarshalStrict(data, &mapping); err != nil {
+		return nil, fmt.Errorf("failed to parse mapping %s: %v", path, err)
+	}
+	return &mapping, nil
+}
+
+// Message is the data a Mapping's templates execute on
+type Message struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       string
+	Value     string
+	Headers   map[string]string
+	Time      time.Time
+	// JSON is the value decoded as JSON, nil when it is not JSON
+	JSON interface{}
+}
+
+// templateFuncs are the functions available to a Mapping's templates besides the built-in ones
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	// default returns value, or fallback when value is empty
+	"default": func(fallback, value interface{}) interface{} {
+		if value == nil || value == "" {
+			return fallback
+		}
+		return value
+	},
+	// field returns the value at a dotted path of a decoded JSON object, or nil when there is
+	// none; unlike .JSON.a.b it does not fail when a message lacks the field
+	"field": func(v interface{}, path string) interface{} {
+		for _, key := range strings.Split(path, ".") {
+			object, ok := v.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			v = object[key]
+		}
+		return v
+	},
+}
+
+// mapper is a parsed Mapping; a nil mapper reads messages as the JSON logs a Sink publishes
+type mapper struct {
+	id, userID, action, resource, timestamp, description, metadata *template.Template
+}
+
+func newMapper(mapping *Mapping) (*mapper, error) {
+	if mapping == nil {
+		return nil, nil
+	}
+
+	m := &mapper{}
+	for _, field := range []struct {
+		name     string
+		text     string
+		required bool
+		t        **template.Template
+	}{
+		{"id", mapping.ID, false, &m.id},
+		{"userId", mapping.UserID, true, &m.userID},
+		{"action", mapping.Action, true, &m.action},
+		{"resource", mapping.Resource, true, &m.resource},
+		{"timestamp", mapping.Timestamp, false, &m.timestamp},
+		{"description", mapping.Description, false, &m.description},
+		{"metadata", mapping.Metadata, false, &m.metadata},
+	} {
+		if field.text == "" {
+			if field.required {
+				return nil, fmt.Errorf("the mapping has no %s template", field.name)
+			}
+			continue
+		}
+		t, err := template.New(field.name).Option("missingkey=error").Funcs(templateFuncs).Parse(field.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s template: %v", field.name, err)
+		}
+		*field.t = t
+	}
+	return m, nil
+}
+
+// log maps a consumed message to the log it records
+func (m *mapper) log(msg kafka.Message) (client.LogEvent, error) {
+	if m == nil {
+		var log client.LogEvent
+		if err := json.Unmarshal(msg.Value, &log); err != nil {
+			return client.LogEvent{}, fmt.Errorf("%w: the message is not a JSON log: %v", client.ErrInvalidLog, err)
+		}
+		if log.ID == "" {
+			log.ID = messageID(msg)
+		}
+		return log, nil
+	}
+
+	data := Message{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       string(msg.Key),
+		Value:     string(msg.Value),
+		Headers:   make(map[string]string, len(msg.Headers)),
+		Time:      msg.Time,
+	}
+	for _, header := range msg.Headers {
+		data.Headers[header.Key] = string(header.Value)
+	}
+	if err := json.Unmarshal(msg.Value, &data.JSON); err != nil {
+		data.JSON = nil
+	}
+
+	log := client.LogEvent{
+		ID:        messageID(msg),
+		Timestamp: msg.Time.UTC().Format(time.RFC3339Nano),
+	}
+	metadata, err := json.Marshal(map[string]interface{}{
+		"topic":     msg.Topic,
+		"partition": msg.Partition,
+		"offset":    msg.Offset,
+	})
+	if err != nil {
+		return client.LogEvent{}, err
+	}
+	log.Metadata = string(metadata)
+
+	for _, field := range []struct {
+		t     *template.Template
+		value *string
+	}{
+		{m.id, &log.ID},
+		{m.userID, &log.UserID},
+		{m.action, &log.Action},
+		{m.resource, &log.Resource},
+		{m.timestamp, &log.Timestamp},
+		{m.description, &log.Description},
+		{m.metadata, &log.Metadata},
+	} {
+		if field.t == nil {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := field.t.Execute(&buf, data); err != nil {
+			return client.LogEvent{}, fmt.Errorf("%w: %v", client.ErrInvalidLog, err)
+		}
+		*field.value = buf.String()
+	}
+	return log, nil
+}
+
+// messageID identifies a message by its position, hashed when too long for a log ID
+func messageID(msg kafka.Message) string {
+	id := fmt.Sprintf("kafka-%s-%d-%d", msg.Topic, msg.Partition, msg.Offset)
+	if len(id) <= client.MaxIDLength {
+		return id
+	}
+	hashed, _ := client.ContentHash()(client.LogEvent{Resource: id})
+	return "kafka-" + hashed
+}
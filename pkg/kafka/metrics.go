@@ -0,0 +1,45 @@
+package kafka
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricsNamespace prefixes every metric exported by the bridge, as it does the client's
+const metricsNamespace = "fabric_logging"
+
+// metrics holds the Prometheus instruments of a Bridge
+type metrics struct {
+	messages *prometheus.CounterVec
+	failures prometheus.Counter
+}
+
+func newMetrics(registerer prometheus.Registerer) (*metrics, error) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	m := &metrics{
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "kafka_bridge",
+			Name:      "messages_total",
+			Help:      "Kafka messages consumed, by result: submitted or invalid.",
+		}, []string{"result"}),
+		failures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "kafka_bridge",
+			Name:      "submit_failures_total",
+			Help:      "Batches of consumed messages that failed to be submitted, including those retried.",
+		}),
+	}
+
+	for _, collector := range []prometheus.Collector{m.messages, m.failures} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, fmt.Errorf("failed to register Kafka bridge metrics: %v", err)
+		}
+	}
+
+	return m, nil
+}
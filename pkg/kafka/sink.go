@@ -0,0 +1,77 @@
+// Package kafka connects the logging chaincode with Apache Kafka topics.
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/segmentio/kafka-go"
+)
+
+// Message headers carrying a log's ledger position
+const (
+	BlockNumberHeader   = "fabric-block-number"
+	TransactionIDHeader = "fabric-transaction-id"
+)
+
+// Sink is a projection.Sink publishing each log as a JSON message keyed by its ID. Messages
+// replayed after a restart are published again; consumers should deduplicate by key, which a
+// log-compacted topic does for them. A pruned log is published as a tombstone, a message with
+// its ID and no value, so compaction removes the log from the topic too.
+type Sink struct {
+	writer *kafka.Writer
+}
+
+// NewSink returns a Sink publishing to topic on the given brokers. Messages are written with
+// acknowledgement from all in-sync replicas, and keyed by log ID so a log always lands in the
+// same partition.
+func NewSink(brokers []string, topic string) *Sink {
+	return NewSinkWithWriter(&kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+	})
+}
+
+// NewSinkWithWriter returns a Sink publishing through a configured writer, for TLS, SASL or
+// other transport settings
+func NewSinkWithWriter(writer *kafka.Writer) *Sink {
+	return &Sink{writer: writer}
+}
+
+// Write publishes the logs carried by events in one request
+func (s *Sink) Write(ctx context.Context, events []client.ContractEvent) error {
+	messages := make([]kafka.Message, len(events))
+	for i, event := range events {
+		var value []byte
+		if !event.Pruned() {
+			var err error
+			if value, err = json.Marshal(event.Log); err != nil {
+				return err
+			}
+		}
+		messages[i] = kafka.Message{
+			Key:   []byte(event.Log.ID),
+			Value: value,
+			Headers: []kafka.Header{
+				{Key: BlockNumberHeader, Value: []byte(strconv.FormatUint(event.BlockNumber, 10))},
+				{Key: TransactionIDHeader, Value: []byte(event.TransactionID)},
+			},
+		}
+	}
+
+	if err := s.writer.WriteMessages(ctx, messages...); err != nil {
+		return fmt.Errorf("failed to publish logs: %v", err)
+	}
+
+	return nil
+}
+
+// Close flushes pending messages and closes the writer
+func (s *Sink) Close() error {
+	return s.writer.Close()
+}
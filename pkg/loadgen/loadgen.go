@@ -0,0 +1,320 @@
+// Package loadgen drives configurable CreateLog and CreateLogsBatch workloads against a network
+// and reports throughput, endorsement and commit latency percentiles and MVCC conflict rates,
+// for capacity planning.
+//
+// Transactions go through the client's offline signing workflow so the endorsement and the
+// ordering-to-commit stages can be timed separately.
+package loadgen
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// Workload defaults
+const (
+	DefaultConcurrency = 4
+	DefaultDuration    = time.Minute
+	DefaultPayloadSize = 256
+	DefaultUsers       = 100
+	DefaultAction      = "LOADGEN"
+)
+
+// Identity is one signing identity transactions are submitted as. Transactions are spread
+// round-robin across identities, so several show how the network scales with client count.
+type Identity struct {
+	Client *client.Client
+	Sign   client.Sign
+}
+
+// Workload describes the load to generate
+type Workload struct {
+	// BatchSize is the number of logs per transaction. One or less submits CreateLog transactions,
+	// more submits CreateLogsBatch.
+	BatchSize int
+	// Rate is the target number of transactions started per second. Zero runs closed-loop, each
+	// worker starting its next transaction as soon as the previous one finishes.
+	Rate float64
+	// Duration bounds the run; DefaultDuration is used when both it and Transactions are zero
+	Duration time.Duration
+	// Transactions, when positive, stops the run after that many transactions
+	Transactions int
+	// Concurrency is the most transactions in flight; DefaultConcurrency is used otherwise
+	Concurrency int
+	// PayloadSize is the length of each log description; DefaultPayloadSize is used otherwise
+	PayloadSize int
+	// MetadataSize, when positive, adds a metadata object carrying a string of that length
+	MetadataSize int
+	// Users is the number of distinct user IDs logs are spread across; DefaultUsers is used otherwise
+	Users int
+	// Action is the action of generated logs; DefaultAction is used when empty
+	Action string
+	// RunID prefixes generated log IDs so runs never collide; a random one is used when empty
+	RunID string
+}
+
+// Run generates workload against identities until the duration or transaction count is reached
+// or ctx is done, and returns the report. Failed transactions are counted, not returned.
+func Run(ctx context.Context, identities []Identity, workload Workload) (*Report, error) {
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("at least one identity is required")
+	}
+	for i, identity := range identities {
+		if identity.Client == nil || identity.Sign == nil {
+			return nil, fmt.Errorf("identity %d needs a client and a sign function", i)
+		}
+	}
+	workload, err := workload.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	if workload.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, workload.Duration)
+		defer cancel()
+	}
+
+	g := &generator{
+		identities: identities,
+		workload:   workload,
+		payload:    strings.Repeat("x", workload.PayloadSize),
+		recorder:   newRecorder(),
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workload.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for seq := range jobs {
+				g.transaction(ctx, seq)
+			}
+		}()
+	}
+
+	start := time.Now()
+	g.schedule(ctx, jobs)
+	close(jobs)
+	wg.Wait()
+
+	return g.recorder.report(workload, len(identities), time.Since(start)), nil
+}
+
+func (w Workload) withDefaults() (Workload, error) {
+	if w.Duration < 0 || w.Transactions < 0 || w.Rate < 0 {
+		return w, fmt.Errorf("duration, transactions and rate must not be negative")
+	}
+	if w.BatchSize < 1 {
+		w.BatchSize = 1
+	}
+	if w.Duration == 0 && w.Transactions == 0 {
+		w.Duration = DefaultDuration
+	}
+	if w.Concurrency <= 0 {
+		w.Concurrency = DefaultConcurrency
+	}
+	if w.PayloadSize <= 0 {
+		w.PayloadSize = DefaultPayloadSize
+	}
+	if w.Users <= 0 {
+		w.Users = DefaultUsers
+	}
+	if w.Action == "" {
+		w.Action = DefaultAction
+	}
+	if w.RunID == "" {
+		suffix := make([]byte, 4)
+		if _, err := rand.Read(suffix); err != nil {
+			return w, err
+		}
+		w.RunID = "loadgen-" + hex.EncodeToString(suffix)
+	}
+
+	return w, nil
+}
+
+type generator struct {
+	identities []Identity
+	workload   Workload
+	payload    string
+	recorder   *recorder
+}
+
+// schedule hands out transaction sequence numbers, paced at the workload rate if one is set,
+// until the run is over
+func (g *generator) schedule(ctx context.Context, jobs chan<- int) {
+	var tick <-chan time.Time
+	if g.workload.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / g.workload.Rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for seq := 0; g.workload.Transactions == 0 || seq < g.workload.Transactions; seq++ {
+		if tick != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick:
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case jobs <- seq:
+		default:
+			// Every worker is busy, so the target rate cannot be met; the missed start is
+			// recorded and, when closed-loop, the send simply waits for a free worker
+			if tick != nil {
+				g.recorder.dropped()
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case jobs <- seq:
+			}
+		}
+	}
+}
+
+// transaction submits transaction seq and records its outcome
+func (g *generator) transaction(ctx context.Context, seq int) {
+	identity := g.identities[seq%len(g.identities)]
+	logs := g.logs(seq)
+
+	fn := "CreateLog"
+	var args []string
+	if len(logs) == 1 {
+		log := logs[0]
+		args = []string{log.ID, log.UserID, log.Action, log.Resource, log.Description, log.Metadata}
+	} else {
+		fn = "CreateLogsBatch"
+		logsJSON, err := json.Marshal(logs)
+		if err != nil {
+			g.recorder.failed(len(logs), err)
+			return
+		}
+		args = []string{string(logsJSON)}
+	}
+
+	sample, err := submit(ctx, identity, fn, args)
+	if err != nil {
+		// Transactions cut short by the end of the run are not failures of the network
+		if ctx.Err() != nil {
+			return
+		}
+		g.recorder.failed(len(logs), err)
+		return
+	}
+	g.recorder.succeeded(len(logs), sample)
+}
+
+func (g *generator) logs(seq int) []client.LogEvent {
+	logs := make([]client.LogEvent, g.workload.BatchSize)
+	for i := range logs {
+		n := seq*g.workload.BatchSize + i
+		log := client.LogEvent{
+			ID:          fmt.Sprintf("%s-%d", g.workload.RunID, n),
+			UserID:      fmt.Sprintf("%s-user-%d", g.workload.RunID, n%g.workload.Users),
+			Action:      g.workload.Action,
+			Resource:    "/loadgen",
+			Description: g.payload,
+		}
+		if g.workload.MetadataSize > 0 {
+			metadata, _ := json.Marshal(map[string]string{"padding": strings.Repeat("x", g.workload.MetadataSize)})
+			log.Metadata = string(metadata)
+		}
+		logs[i] = log
+	}
+	return logs
+}
+
+// sample is the timing of one successful transaction
+type sample struct {
+	endorse time.Duration
+	commit  time.Duration
+	total   time.Duration
+}
+
+// submit runs fn through endorsement, ordering and commit, timing each stage
+func submit(ctx context.Context, identity Identity, fn string, args []string) (sample, error) {
+	var s sample
+	start := time.Now()
+
+	proposal, err := identity.Client.NewProposal(fn, args...)
+	if err != nil {
+		return s, err
+	}
+	signature, err := identity.Sign(proposal.Digest())
+	if err != nil {
+		return s, fmt.Errorf("failed to sign proposal: %v", err)
+	}
+	proposal, err = identity.Client.NewSignedProposal(proposal.Bytes(), signature)
+	if err != nil {
+		return s, err
+	}
+
+	endorseStart := time.Now()
+	tx, err := identity.Client.Endorse(ctx, proposal)
+	if err != nil {
+		return s, err
+	}
+	s.endorse = time.Since(endorseStart)
+
+	txBytes, err := tx.Bytes()
+	if err != nil {
+		return s, err
+	}
+	signature, err = identity.Sign(tx.Digest())
+	if err != nil {
+		return s, fmt.Errorf("failed to sign transaction: %v", err)
+	}
+	tx, err = identity.Client.NewSignedTransaction(txBytes, signature)
+	if err != nil {
+		return s, err
+	}
+
+	commitStart := time.Now()
+	commit, err := identity.Client.Submit(ctx, tx)
+	if err != nil {
+		return s, err
+	}
+	signature, err = identity.Sign(commit.Digest())
+	if err != nil {
+		return s, fmt.Errorf("failed to sign commit status request: %v", err)
+	}
+	commit, err = identity.Client.NewSignedCommit(commit.Bytes(), signature)
+	if err != nil {
+		return s, err
+	}
+	if _, err := identity.Client.CommitStatus(ctx, commit); err != nil {
+		return s, err
+	}
+	s.commit = time.Since(commitStart)
+	s.total = time.Since(start)
+
+	return s, nil
+}
+
+// isConflict reports whether err is a transaction invalidated by a concurrent write to a key it read
+func isConflict(err error) bool {
+	var commitErr *client.CommitError
+	if !errors.As(err, &commitErr) {
+		return false
+	}
+	return commitErr.Code == peer.TxValidationCode_MVCC_READ_CONFLICT || commitErr.Code == peer.TxValidationCode_PHANTOM_READ_CONFLICT
+}
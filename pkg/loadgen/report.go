@@ -0,0 +1,230 @@
+package loadgen
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// Latency summarizes the durations of one transaction stage
+type Latency struct {
+	Mean time.Duration `json:"mean"`
+	P50  time.Duration `json:"p50"`
+	P90  time.Duration `json:"p90"`
+	P95  time.Duration `json:"p95"`
+	P99  time.Duration `json:"p99"`
+	Max  time.Duration `json:"max"`
+}
+
+// Report is the outcome of a load run
+type Report struct {
+	Workload   Workload      `json:"workload"`
+	Identities int           `json:"identities"`
+	Elapsed    time.Duration `json:"elapsed"`
+
+	Transactions int `json:"transactions"`
+	Succeeded    int `json:"succeeded"`
+	Failed       int `json:"failed"`
+	// Conflicts counts transactions invalidated with MVCC or phantom read conflicts
+	Conflicts    int     `json:"conflicts"`
+	ConflictRate float64 `json:"conflictRate"`
+	// Dropped counts transaction starts skipped because every worker was busy at the target rate
+	Dropped int `json:"dropped"`
+	// Logs counts the logs in successful transactions
+	Logs int `json:"logs"`
+
+	TransactionsPerSecond float64 `json:"transactionsPerSecond"`
+	LogsPerSecond         float64 `json:"logsPerSecond"`
+
+	// Endorse is the time to endorse a proposal, Commit the time from sending the transaction
+	// to the orderer until its commit is confirmed, and Total the whole transaction including signing
+	Endorse Latency `json:"endorse"`
+	Commit  Latency `json:"commit"`
+	Total   Latency `json:"total"`
+
+	// Errors counts failed transactions by kind
+	Errors map[string]int `json:"errors,omitempty"`
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteText writes the report as a human readable table
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "identities\t%d\n", r.Identities)
+	fmt.Fprintf(tw, "batch size\t%d\n", r.Workload.BatchSize)
+	fmt.Fprintf(tw, "elapsed\t%s\n", r.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(tw, "transactions\t%d (%d succeeded, %d failed, %d dropped)\n", r.Transactions, r.Succeeded, r.Failed, r.Dropped)
+	fmt.Fprintf(tw, "throughput\t%.1f tx/s, %.1f logs/s\n", r.TransactionsPerSecond, r.LogsPerSecond)
+	fmt.Fprintf(tw, "MVCC conflicts\t%d (%.2f%%)\n", r.Conflicts, r.ConflictRate*100)
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "stage\tmean\tp50\tp90\tp95\tp99\tmax")
+	for _, stage := range []struct {
+		name    string
+		latency Latency
+	}{{"endorse", r.Endorse}, {"commit", r.Commit}, {"total", r.Total}} {
+		l := stage.latency
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", stage.name, round(l.Mean), round(l.P50), round(l.P90), round(l.P95), round(l.P99), round(l.Max))
+	}
+
+	if len(r.Errors) > 0 {
+		fmt.Fprintln(tw)
+		kinds := make([]string, 0, len(r.Errors))
+		for kind := range r.Errors {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		for _, kind := range kinds {
+			fmt.Fprintf(tw, "error %s\t%d\n", kind, r.Errors[kind])
+		}
+	}
+
+	return tw.Flush()
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(100 * time.Microsecond)
+}
+
+// recorder collects the outcomes of concurrent transactions
+type recorder struct {
+	mu        sync.Mutex
+	samples   []sample
+	logs      int
+	failures  int
+	conflicts int
+	drops     int
+	errors    map[string]int
+}
+
+func newRecorder() *recorder {
+	return &recorder{errors: map[string]int{}}
+}
+
+func (r *recorder) succeeded(logs int, s sample) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.samples = append(r.samples, s)
+	r.logs += logs
+}
+
+func (r *recorder) failed(logs int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures++
+	if isConflict(err) {
+		r.conflicts++
+	}
+	r.errors[errorKind(err)]++
+}
+
+func (r *recorder) dropped() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.drops++
+}
+
+func (r *recorder) report(workload Workload, identities int, elapsed time.Duration) *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := &Report{
+		Workload:     workload,
+		Identities:   identities,
+		Elapsed:      elapsed,
+		Transactions: len(r.samples) + r.failures,
+		Succeeded:    len(r.samples),
+		Failed:       r.failures,
+		Conflicts:    r.conflicts,
+		Dropped:      r.drops,
+		Logs:         r.logs,
+		Endorse:      latency(r.samples, func(s sample) time.Duration { return s.endorse }),
+		Commit:       latency(r.samples, func(s sample) time.Duration { return s.commit }),
+		Total:        latency(r.samples, func(s sample) time.Duration { return s.total }),
+	}
+	if report.Transactions > 0 {
+		report.ConflictRate = float64(r.conflicts) / float64(report.Transactions)
+	}
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		report.TransactionsPerSecond = float64(report.Succeeded) / seconds
+		report.LogsPerSecond = float64(report.Logs) / seconds
+	}
+	if len(r.errors) > 0 {
+		report.Errors = make(map[string]int, len(r.errors))
+		for kind, n := range r.errors {
+			report.Errors[kind] = n
+		}
+	}
+
+	return report
+}
+
+// latency summarizes the stage durations of samples using the nearest-rank percentile
+func latency(samples []sample, stage func(sample) time.Duration) Latency {
+	if len(samples) == 0 {
+		return Latency{}
+	}
+
+	durations := make([]time.Duration, len(samples))
+	var sum time.Duration
+	for i, s := range samples {
+		durations[i] = stage(s)
+		sum += durations[i]
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	percentile := func(p float64) time.Duration {
+		rank := int(p*float64(len(durations))+0.5) - 1
+		if rank < 0 {
+			rank = 0
+		}
+		if rank >= len(durations) {
+			rank = len(durations) - 1
+		}
+		return durations[rank]
+	}
+
+	return Latency{
+		Mean: sum / time.Duration(len(durations)),
+		P50:  percentile(0.50),
+		P90:  percentile(0.90),
+		P95:  percentile(0.95),
+		P99:  percentile(0.99),
+		Max:  durations[len(durations)-1],
+	}
+}
+
+// errorKind names the class of a failed transaction for the report
+func errorKind(err error) string {
+	var commitErr *client.CommitError
+	var transient *client.TransientError
+	switch {
+	case errors.As(err, &commitErr):
+		return strings.ToLower(commitErr.Code.String())
+	case errors.Is(err, client.ErrAlreadyExists):
+		return "already_exists"
+	case errors.Is(err, client.ErrInvalidLog):
+		return "invalid_log"
+	case errors.Is(err, client.ErrUnauthorized):
+		return "unauthorized"
+	case errors.Is(err, client.ErrQuotaExceeded):
+		return "quota_exceeded"
+	case errors.As(err, &transient):
+		return "unavailable"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,180 @@
+package lumberjack
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Frame types of the lumberjack v2 protocol
+const (
+	protocolVersion  = '2'
+	frameWindow      = 'W'
+	frameJSON        = 'J'
+	frameData        = 'D'
+	frameCompressed  = 'C'
+	frameAck         = 'A'
+	maxFrameNestings = 4
+)
+
+// frame is a window or data frame; compressed frames are read through
+type frame struct {
+	kind   byte
+	window uint32
+	seq    uint32
+	event  map[string]interface{}
+}
+
+// frameReader reads the frames of a connection, reading the frames inside compressed frames
+// as if they had been sent one by one
+type frameReader struct {
+	r     *bufio.Reader
+	max   int
+	depth int
+	// inner reads the frames of the compressed frame being read
+	inner *frameReader
+}
+
+func newFrameReader(r io.Reader, max int) *frameReader {
+	return &frameReader{r: bufio.NewReader(r), max: max}
+}
+
+// next returns the next window or data frame, or io.EOF when the input ends between frames
+func (f *frameReader) next() (frame, error) {
+	if f.inner != nil {
+		fr, err := f.inner.next()
+		if !errors.Is(err, io.EOF) {
+			return fr, err
+		}
+		f.inner = nil
+	}
+
+	var header [2]byte
+	if _, err := io.ReadFull(f.r, header[:]); err != nil {
+		return frame{}, err
+	}
+	if header[0] != protocolVersion {
+		return frame{}, fmt.Errorf("unsupported lumberjack version %q", header[0])
+	}
+
+	switch header[1] {
+	case frameWindow:
+		window, err := f.uint32()
+		return frame{kind: frameWindow, window: window}, err
+
+	case frameJSON:
+		seq, err := f.uint32()
+		if err != nil {
+			return frame{}, err
+		}
+		payload, err := f.payload()
+		if err != nil {
+			return frame{}, err
+		}
+		var event map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(payload))
+		decoder.UseNumber()
+		if err := decoder.Decode(&event); err != nil || event == nil {
+			return frame{}, fmt.Errorf("event %d is not a JSON object", seq)
+		}
+		return frame{kind: frameJSON, seq: seq, event: event}, nil
+
+	case frameData:
+		return f.data()
+
+	case frameCompressed:
+		if f.depth >= maxFrameNestings {
+			return frame{}, fmt.Errorf("compressed frames nested more than %d deep", maxFrameNestings)
+		}
+		payload, err := f.payload()
+		if err != nil {
+			return frame{}, err
+		}
+		z, err := zlib.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return frame{}, fmt.Errorf("invalid compressed frame: %v", err)
+		}
+		defer z.Close()
+		// Decompressed frames are bounded like any payload, so a small bomb cannot expand unbounded
+		data, err := io.ReadAll(io.LimitReader(z, int64(f.max)+1))
+		if err != nil {
+			return frame{}, fmt.Errorf("invalid compressed frame: %v", err)
+		}
+		if len(data) > f.max {
+			return frame{}, fmt.Errorf("compressed frame expands beyond %d bytes", f.max)
+		}
+		f.inner = &frameReader{r: bufio.NewReader(bytes.NewReader(data)), max: f.max, depth: f.depth + 1}
+		return f.next()
+	}
+	return frame{}, fmt.Errorf("unknown frame type %q", header[1])
+}
+
+// data reads a data frame of key-value pairs, as sent by older lumberjack clients
+func (f *frameReader) data() (frame, error) {
+	seq, err := f.uint32()
+	if err != nil {
+		return frame{}, err
+	}
+	pairs, err := f.uint32()
+	if err != nil {
+		return frame{}, err
+	}
+
+	event := make(map[string]interface{})
+	for i := uint32(0); i < pairs; i++ {
+		key, err := f.payload()
+		if err != nil {
+			return frame{}, err
+		}
+		value, err := f.payload()
+		if err != nil {
+			return frame{}, err
+		}
+		event[string(key)] = string(value)
+	}
+	return frame{kind: frameData, seq: seq, event: event}, nil
+}
+
+func (f *frameReader) uint32() (uint32, error) {
+	var p [4]byte
+	if _, err := io.ReadFull(f.r, p[:]); err != nil {
+		return 0, unexpected(err)
+	}
+	return binary.BigEndian.Uint32(p[:]), nil
+}
+
+// payload reads a length and as many bytes
+func (f *frameReader) payload() ([]byte, error) {
+	n, err := f.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if int64(n) > int64(f.max) {
+		return nil, fmt.Errorf("payload of %d bytes exceeds %d", n, f.max)
+	}
+	p := make([]byte, n)
+	if _, err := io.ReadFull(f.r, p); err != nil {
+		return nil, unexpected(err)
+	}
+	return p, nil
+}
+
+// unexpected reports the end of the input inside a frame as io.ErrUnexpectedEOF
+func unexpected(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// ack encodes the ack of the frames up to seq
+func ack(seq uint32) []byte {
+	p := []byte{protocolVersion, frameAck, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(p[2:], seq)
+	return p
+}
@@ -0,0 +1,45 @@
+package lumberjack
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricsNamespace prefixes every metric exported by the server, as it does the client's
+const metricsNamespace = "fabric_logging"
+
+// metrics holds the Prometheus instruments of a Server
+type metrics struct {
+	events      *prometheus.CounterVec
+	connections prometheus.Gauge
+}
+
+func newMetrics(registerer prometheus.Registerer) (*metrics, error) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	m := &metrics{
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "lumberjack",
+			Name:      "events_total",
+			Help:      "Beats events received, by result: submitted, invalid or failed.",
+		}, []string{"result"}),
+		connections: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "lumberjack",
+			Name:      "connections",
+			Help:      "Open connections of Beats.",
+		}),
+	}
+
+	for _, collector := range []prometheus.Collector{m.events, m.connections} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, fmt.Errorf("failed to register lumberjack metrics: %v", err)
+		}
+	}
+
+	return m, nil
+}
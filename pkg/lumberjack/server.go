@@ -0,0 +1,374 @@
+// Package lumberjack receives events from Filebeat, Winlogbeat and the other Beats over the
+// lumberjack v2 protocol their Logstash output speaks, so an Elastic agent fleet ships straight
+// to the ledger. A sender announces a window of events and waits for the ack of its last event
+// before sending the next window; the server acknowledges a window only once its events are
+// committed, so the window is the sender's back-pressure, and keeps the sender waiting with
+// keepalive acks meanwhile.
+package lumberjack
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Server defaults
+const (
+	// DefaultMaxPayloadSize is the largest event, or compressed window once expanded, read by default
+	DefaultMaxPayloadSize = 32 << 20
+	// DefaultKeepaliveInterval is how often a sender waiting for its ack is sent a keepalive by
+	// default, well within the 30 second timeout of the Beats' Logstash output
+	DefaultKeepaliveInterval = 5 * time.Second
+)
+
+// backpressureWait is how long a connection waits before retrying an event the submitter pushed back
+const backpressureWait = 100 * time.Millisecond
+
+// Options configures a Server
+type Options struct {
+	// Submitter receives the logs, usually a BatchingSubmitter
+	Submitter client.Submitter
+	// Flush submits every log handed to Submitter, such as BatchingSubmitter.Flush. Windows are
+	// only acknowledged once flushed, so senders keep their events until they are committed;
+	// without Flush they are acknowledged once handed over.
+	Flush func(ctx context.Context) error
+	// UserID is used for events without user.name; the Beat's type, such as filebeat, is used
+	// when empty
+	UserID string
+	// Action is used for events without event.action; LOG_<LEVEL> is recorded otherwise
+	Action string
+	// MaxPayloadSize is the largest event, or compressed window once expanded, read;
+	// DefaultMaxPayloadSize is used when zero
+	MaxPayloadSize int
+	// KeepaliveInterval is how often a sender waiting for its ack is sent a keepalive;
+	// DefaultKeepaliveInterval is used when zero
+	KeepaliveInterval time.Duration
+	// Registerer receives the fabric_logging_lumberjack_* metrics; prometheus.DefaultRegisterer is used when nil
+	Registerer prometheus.Registerer
+	// OnError is called with the events the Submitter rejected and the connections that failed
+	OnError func(error)
+}
+
+// Server receives Beats events and submits them as logs. An event's message becomes the
+// description, @timestamp the time and log.level the severity; user.name, event.action and
+// host.name fill the user, action and resource, and @metadata._id, when a Beat sets it, the ID.
+// Every other field is kept in the metadata.
+type Server struct {
+	options Options
+	handler *client.SlogHandler
+	metrics *metrics
+}
+
+// validating rejects logs the chaincode would reject before they are batched, as one invalid log
+// fails every log submitted with it
+type validating struct {
+	client.Submitter
+}
+
+func (v validating) CreateLog(ctx context.Context, log client.LogEvent) error {
+	if err := client.ValidateLog(log); err != nil {
+		return err
+	}
+	return v.Submitter.CreateLog(ctx, log)
+}
+
+// NewServer returns a Server submitting through options.Submitter
+func NewServer(options Options) (*Server, error) {
+	if options.Submitter == nil {
+		return nil, fmt.Errorf("no submitter configured")
+	}
+	if options.MaxPayloadSize <= 0 {
+		options.MaxPayloadSize = DefaultMaxPayloadSize
+	}
+	if options.KeepaliveInterval <= 0 {
+		options.KeepaliveInterval = DefaultKeepaliveInterval
+	}
+
+	m, err := newMetrics(options.Registerer)
+	if err != nil {
+		return nil, err
+	}
+	handler := client.NewSlogHandler(validating{options.Submitter}, client.SlogHandlerOptions{
+		Level:  slog.LevelDebug - 4,
+		UserID: options.UserID,
+	})
+	return &Server{options: options, handler: handler, metrics: m}, nil
+}
+
+// Serve accepts connections on listener until ctx is done, then closes listener and the
+// connections
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	stop := context.AfterFunc(ctx, func() { listener.Close() })
+	defer stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.serveConn(ctx, conn); err != nil && ctx.Err() == nil {
+				s.report(fmt.Errorf("closed connection from %s: %w", conn.RemoteAddr(), err))
+			}
+		}()
+	}
+}
+
+// ServeTLS serves listener as Serve does, over TLS with config
+func (s *Server) ServeTLS(ctx context.Context, listener net.Listener, config *tls.Config) error {
+	return s.Serve(ctx, tls.NewListener(listener, config))
+}
+
+// conn is a sender's connection, whose acks are written by the reading goroutine and the keepalive
+type conn struct {
+	net.Conn
+	mu sync.Mutex
+	// waiting is set while the sender waits for the ack of a window
+	waiting bool
+}
+
+func (c *conn) ack(seq uint32) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err := c.Write(ack(seq))
+	return err
+}
+
+func (c *conn) setWaiting(waiting bool) {
+	c.mu.Lock()
+	c.waiting = waiting
+	c.mu.Unlock()
+}
+
+// keepalive sends an ack of sequence 0, which acknowledges nothing, while the sender waits, so
+// it does not time out while its window is committed
+func (c *conn) keepalive(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.mu.Lock()
+			if c.waiting {
+				c.Write(ack(0))
+			}
+			c.mu.Unlock()
+		}
+	}
+}
+
+// serveConn reads the windows of a connection until it ends or ctx is done
+func (s *Server) serveConn(ctx context.Context, netConn net.Conn) error {
+	stop := context.AfterFunc(ctx, func() { netConn.Close() })
+	defer stop()
+	defer netConn.Close()
+
+	s.metrics.connections.Inc()
+	defer s.metrics.connections.Dec()
+
+	c := &conn{Conn: netConn}
+	keepaliveCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go c.keepalive(keepaliveCtx, s.options.KeepaliveInterval)
+
+	frames := newFrameReader(netConn, s.options.MaxPayloadSize)
+	var window, received, last uint32
+	for {
+		fr, err := frames.next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if fr.kind == frameWindow {
+			window, received = fr.window, 0
+			continue
+		}
+		if window == 0 {
+			return fmt.Errorf("event %d sent outside a window", fr.seq)
+		}
+
+		c.setWaiting(true)
+		if err := s.submit(ctx, fr.event, netConn.RemoteAddr()); err != nil {
+			return err
+		}
+		received++
+		last = fr.seq
+		if received < window {
+			continue
+		}
+
+		if s.options.Flush != nil {
+			if err := s.options.Flush(ctx); err != nil {
+				// Without an ack the sender sends the window again
+				return fmt.Errorf("failed to flush window: %w", err)
+			}
+		}
+		if err := c.ack(last); err != nil {
+			return err
+		}
+		c.setWaiting(false)
+		window = 0
+	}
+}
+
+// submit hands the log of an event to the submitter, waiting while it pushes back. Events the
+// submitter rejects are reported and skipped, as sending them again would not help.
+func (s *Server) submit(ctx context.Context, event map[string]interface{}, sender net.Addr) error {
+	r := s.record(event)
+	for {
+		err := s.handler.Handle(ctx, r)
+		switch {
+		case err == nil:
+			s.metrics.events.WithLabelValues("submitted").Inc()
+		case errors.Is(err, client.ErrBackpressure):
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backpressureWait):
+			}
+			continue
+		case errors.Is(err, client.ErrInvalidLog):
+			s.metrics.events.WithLabelValues("invalid").Inc()
+			s.report(fmt.Errorf("skipped an event from %s: %w", sender, err))
+		default:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			s.metrics.events.WithLabelValues("failed").Inc()
+			s.report(fmt.Errorf("failed to submit an event from %s: %w", sender, err))
+		}
+		return nil
+	}
+}
+
+// record builds the slog record of an event
+func (s *Server) record(event map[string]interface{}) slog.Record {
+	fields := make(map[string]interface{}, len(event))
+	for key, value := range event {
+		fields[key] = value
+	}
+
+	t := time.Now()
+	if value, ok := fields["@timestamp"].(string); ok {
+		if parsed, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			t = parsed
+			delete(fields, "@timestamp")
+		}
+	}
+
+	message := ""
+	for _, key := range []string{"message", "line"} {
+		if value, ok := fields[key]; ok {
+			message = fmt.Sprint(value)
+			delete(fields, key)
+			break
+		}
+	}
+
+	level := slog.LevelInfo
+	if value, ok := lookup(fields, "log.level").(string); ok {
+		if parsed, ok := client.ParseSeverity(value); ok {
+			level = parsed
+		}
+	}
+
+	// The Beat's own metadata describes the shipping, not the event, so only the Beat is kept
+	if meta, ok := fields["@metadata"].(map[string]interface{}); ok {
+		delete(fields, "@metadata")
+		if id, ok := meta["_id"].(string); ok && id != "" {
+			fields[client.SlogIDKey] = id
+		}
+		if beat, ok := meta["beat"].(string); ok {
+			fields["beat"] = beat
+		}
+	}
+
+	for key, path := range map[string][]string{
+		client.SlogUserIDKey:   {"user.name"},
+		client.SlogActionKey:   {"event.action"},
+		client.SlogResourceKey: {"host.name", "host.hostname", "agent.hostname"},
+	} {
+		delete(fields, key)
+		for _, p := range path {
+			if value, ok := lookup(fields, p).(string); ok && value != "" {
+				fields[key] = value
+				break
+			}
+		}
+	}
+	if _, ok := fields[client.SlogUserIDKey]; !ok && s.options.UserID == "" {
+		fields[client.SlogUserIDKey] = "beats"
+		if beat, ok := lookup(fields, "agent.type").(string); ok && beat != "" {
+			fields[client.SlogUserIDKey] = beat
+		}
+	}
+	if _, ok := fields[client.SlogActionKey]; !ok && s.options.Action != "" {
+		fields[client.SlogActionKey] = s.options.Action
+	}
+	if _, ok := fields[client.SlogResourceKey]; !ok {
+		fields[client.SlogResourceKey] = "beats"
+	}
+
+	r := slog.NewRecord(t, level, client.Truncate(message, client.MaxDescriptionLength), 0)
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		r.AddAttrs(slog.Any(key, fields[key]))
+	}
+	return r
+}
+
+func (s *Server) report(err error) {
+	if s.options.OnError != nil {
+		s.options.OnError(err)
+	}
+}
+
+// lookup returns the field at a dotted ECS path such as log.level, whether the event nests it
+// as {"log": {"level": ...}} or holds it under the dotted key
+func lookup(fields map[string]interface{}, path string) interface{} {
+	if value, ok := fields[path]; ok {
+		return value
+	}
+	head, rest, nested := strings.Cut(path, ".")
+	if !nested {
+		return nil
+	}
+	inner, ok := fields[head].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return lookup(inner, rest)
+}
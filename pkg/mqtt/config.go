@@ -0,0 +1,27 @@
+package mqtt
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v2"
+)
+
+// topicsConfig is a topics file
+type topicsConfig struct {
+	Topics []Topic `json:"topics" yaml:"topics"`
+}
+
+// LoadTopics reads the topics of a topics file, in YAML or JSON
+func LoadTopics(path string) ([]Topic, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read topics: %v", err)
+	}
+
+	var cfg topicsConfig
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse topics %s: %v", path, err)
+	}
+	return cfg.Topics, nil
+}
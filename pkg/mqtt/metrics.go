@@ -0,0 +1,45 @@
+package mqtt
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricsNamespace prefixes every metric exported by the subscriber, as it does the client's
+const metricsNamespace = "fabric_logging"
+
+// metrics holds the Prometheus instruments of a Subscriber
+type metrics struct {
+	messages        *prometheus.CounterVec
+	sessionFailures prometheus.Counter
+}
+
+func newMetrics(registerer prometheus.Registerer) (*metrics, error) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	m := &metrics{
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "mqtt",
+			Name:      "messages_total",
+			Help:      "MQTT messages received, by result: submitted, invalid or failed.",
+		}, []string{"result"}),
+		sessionFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "mqtt",
+			Name:      "session_failures_total",
+			Help:      "Sessions with the MQTT broker that failed or were lost.",
+		}),
+	}
+
+	for _, collector := range []prometheus.Collector{m.messages, m.sessionFailures} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, fmt.Errorf("failed to register MQTT metrics: %v", err)
+		}
+	}
+
+	return m, nil
+}
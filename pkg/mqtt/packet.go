@@ -0,0 +1,208 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The subscriber speaks MQTT 3.1.1 itself. This file implements the packets a subscriber uses:
+// CONNECT and CONNACK, SUBSCRIBE and SUBACK, PUBLISH and PUBACK, the pings and DISCONNECT.
+
+// Packet types, in the high nibble of the first byte
+const (
+	packetConnect    = 1
+	packetConnack    = 2
+	packetPublish    = 3
+	packetPuback     = 4
+	packetSubscribe  = 8
+	packetSuback     = 9
+	packetPingreq    = 12
+	packetPingresp   = 13
+	packetDisconnect = 14
+)
+
+// subackFailure is the SUBACK code of a refused subscription
+const subackFailure = 0x80
+
+// connackReasons describes the CONNACK return codes refusing a connection
+var connackReasons = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// packet is a received packet; a PUBLISH has its fields read
+type packet struct {
+	kind  byte
+	flags byte
+	body  []byte
+
+	topic    string
+	packetID uint16
+	qos      byte
+	payload  []byte
+	// truncated is set on a PUBLISH whose payload exceeded the limit and was discarded
+	truncated bool
+}
+
+// readPacket reads a packet, discarding the payload of a PUBLISH larger than max
+func readPacket(r *bufio.Reader, max int) (*packet, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	length, err := readLength(r)
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	p := &packet{kind: first >> 4, flags: first & 0x0f}
+
+	if p.kind != packetPublish {
+		if length > 1<<16 {
+			return nil, fmt.Errorf("packet of type %d of %d bytes exceeds the limit", p.kind, length)
+		}
+		p.body = make([]byte, length)
+		if _, err := io.ReadFull(r, p.body); err != nil {
+			return nil, unexpected(err)
+		}
+		return p, nil
+	}
+
+	p.qos = (p.flags >> 1) & 0x03
+	var header [2]byte
+	if length < 2 {
+		return nil, fmt.Errorf("invalid PUBLISH packet")
+	}
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, unexpected(err)
+	}
+	topicLength := int(binary.BigEndian.Uint16(header[:]))
+	rest := length - 2 - topicLength
+	if p.qos > 0 {
+		rest -= 2
+	}
+	if rest < 0 {
+		return nil, fmt.Errorf("invalid PUBLISH packet")
+	}
+	topic := make([]byte, topicLength)
+	if _, err := io.ReadFull(r, topic); err != nil {
+		return nil, unexpected(err)
+	}
+	p.topic = string(topic)
+	if p.qos > 0 {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, unexpected(err)
+		}
+		p.packetID = binary.BigEndian.Uint16(header[:])
+	}
+
+	if rest > max {
+		// The message is acknowledged as it would otherwise be sent again on every connection
+		p.truncated = true
+		if _, err := io.CopyN(io.Discard, r, int64(rest)); err != nil {
+			return nil, unexpected(err)
+		}
+		return p, nil
+	}
+	p.payload = make([]byte, rest)
+	if _, err := io.ReadFull(r, p.payload); err != nil {
+		return nil, unexpected(err)
+	}
+	return p, nil
+}
+
+// readLength reads the variable-length remaining length of a packet
+func readLength(r *bufio.Reader) (int, error) {
+	var length, shift int
+	for i := 0; i < 4; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		length |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return length, nil
+		}
+		shift += 7
+	}
+	return 0, errors.New("invalid remaining length")
+}
+
+// unexpected reports the end of the input inside a packet as io.ErrUnexpectedEOF
+func unexpected(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// encodePacket returns a packet of kind with flags and body
+func encodePacket(kind, flags byte, body []byte) []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, len(body)+5))
+	buf.WriteByte(kind<<4 | flags)
+	n := len(body)
+	for {
+		b := byte(n & 0x7f)
+		n >>= 7
+		if n > 0 {
+			b |= 0x80
+		}
+		buf.WriteByte(b)
+		if n == 0 {
+			break
+		}
+	}
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+// appendString appends a length-prefixed string
+func appendString(p []byte, s string) []byte {
+	p = binary.BigEndian.AppendUint16(p, uint16(len(s)))
+	return append(p, s...)
+}
+
+// connectPacket encodes a CONNECT keeping the session of clientID, so QoS 1 messages not yet
+// acknowledged are sent again after a reconnection
+func connectPacket(clientID, username, password string, keepAlive uint16) []byte {
+	body := appendString(nil, "MQTT")
+	body = append(body, 4)
+	var flags byte
+	if username != "" {
+		flags |= 0x80
+	}
+	if password != "" {
+		flags |= 0x40
+	}
+	body = append(body, flags)
+	body = binary.BigEndian.AppendUint16(body, keepAlive)
+	body = appendString(body, clientID)
+	if username != "" {
+		body = appendString(body, username)
+	}
+	if password != "" {
+		body = appendString(body, password)
+	}
+	return encodePacket(packetConnect, 0, body)
+}
+
+// subscribePacket encodes a SUBSCRIBE of filters, each at qos
+func subscribePacket(packetID uint16, filters []string, qos []byte) []byte {
+	body := binary.BigEndian.AppendUint16(nil, packetID)
+	for i, filter := range filters {
+		body = appendString(body, filter)
+		body = append(body, qos[i])
+	}
+	return encodePacket(packetSubscribe, 0x02, body)
+}
+
+// pubackPacket encodes the PUBACK of a QoS 1 PUBLISH
+func pubackPacket(packetID uint16) []byte {
+	return encodePacket(packetPuback, 0, binary.BigEndian.AppendUint16(nil, packetID))
+}
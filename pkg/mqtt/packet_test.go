@@ -0,0 +1,233 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// publishPacket encodes a PUBLISH, as a broker sends it
+func publishPacket(topic string, qos byte, packetID uint16, retain bool, payload []byte) []byte {
+	body := appendString(nil, topic)
+	if qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, packetID)
+	}
+	body = append(body, payload...)
+	flags := qos << 1
+	if retain {
+		flags |= 0x01
+	}
+	return encodePacket(packetPublish, flags, body)
+}
+
+func reader(p ...[]byte) *bufio.Reader {
+	return bufio.NewReader(bytes.NewReader(bytes.Join(p, nil)))
+}
+
+// The encodings of the boundaries of each length of the remaining length, from the MQTT 3.1.1
+// specification
+var remainingLengths = []struct {
+	length  int
+	encoded []byte
+}{
+	{0, []byte{0x00}},
+	{1, []byte{0x01}},
+	{127, []byte{0x7f}},
+	{128, []byte{0x80, 0x01}},
+	{321, []byte{0xc1, 0x02}},
+	{16383, []byte{0xff, 0x7f}},
+	{16384, []byte{0x80, 0x80, 0x01}},
+	{2097151, []byte{0xff, 0xff, 0x7f}},
+	{2097152, []byte{0x80, 0x80, 0x80, 0x01}},
+	{268435455, []byte{0xff, 0xff, 0xff, 0x7f}},
+}
+
+func TestEncodeRemainingLength(t *testing.T) {
+	for _, tt := range remainingLengths {
+		if tt.length > 1<<22 {
+			// Too large to allocate a body for; decoded by TestReadRemainingLength
+			continue
+		}
+		p := encodePacket(packetPublish, 0, make([]byte, tt.length))
+		if got := p[1 : 1+len(tt.encoded)]; !bytes.Equal(got, tt.encoded) {
+			t.Errorf("length %d encoded as % x, want % x", tt.length, got, tt.encoded)
+		}
+		if len(p) != 1+len(tt.encoded)+tt.length {
+			t.Errorf("packet of length %d has %d bytes, want %d", tt.length, len(p), 1+len(tt.encoded)+tt.length)
+		}
+	}
+}
+
+func TestReadRemainingLength(t *testing.T) {
+	for _, tt := range remainingLengths {
+		r := reader(tt.encoded, []byte{0xaa})
+		length, err := readLength(r)
+		if err != nil || length != tt.length {
+			t.Errorf("readLength(% x) = %d, %v, want %d", tt.encoded, length, err, tt.length)
+		}
+		// The length ends at its last byte
+		if next, _ := r.ReadByte(); next != 0xaa {
+			t.Errorf("readLength(% x) read past the length", tt.encoded)
+		}
+	}
+
+	if _, err := readLength(reader([]byte{0xff, 0xff, 0xff, 0xff, 0x01})); err == nil || err.Error() != "invalid remaining length" {
+		t.Errorf("a five-byte length = %v, want invalid remaining length", err)
+	}
+	if _, err := readLength(reader([]byte{0x80, 0x80})); !errors.Is(err, io.EOF) {
+		t.Errorf("a truncated length = %v, want EOF", err)
+	}
+}
+
+func TestPacketRoundTrip(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		packet []byte
+		want   packet
+	}{
+		{"CONNACK", encodePacket(packetConnack, 0, []byte{0, 0}), packet{kind: packetConnack, body: []byte{0, 0}}},
+		{"SUBACK", encodePacket(packetSuback, 0, []byte{0, 1, 1, subackFailure}), packet{kind: packetSuback, body: []byte{0, 1, 1, subackFailure}}},
+		{"PINGRESP", encodePacket(packetPingresp, 0, nil), packet{kind: packetPingresp, body: []byte{}}},
+		{"PUBACK", pubackPacket(0xbeef), packet{kind: packetPuback, body: []byte{0xbe, 0xef}}},
+		{"SUBSCRIBE flags", subscribePacket(1, []string{"a"}, []byte{1}), packet{kind: packetSubscribe, flags: 0x02, body: []byte{0, 1, 0, 1, 'a', 1}}},
+		{"PUBLISH QoS 0", publishPacket("devices/d1/telemetry", 0, 0, false, []byte("hello")),
+			packet{kind: packetPublish, topic: "devices/d1/telemetry", payload: []byte("hello")}},
+		{"PUBLISH QoS 1", publishPacket("devices/d1/telemetry", 1, 42, false, []byte(`{"message":"hi"}`)),
+			packet{kind: packetPublish, flags: 0x02, qos: 1, packetID: 42, topic: "devices/d1/telemetry", payload: []byte(`{"message":"hi"}`)}},
+		{"PUBLISH retained", publishPacket("t", 1, 1, true, nil),
+			packet{kind: packetPublish, flags: 0x03, qos: 1, packetID: 1, topic: "t", payload: []byte{}}},
+		{"PUBLISH with a two-byte length", publishPacket("t", 1, 65535, false, bytes.Repeat([]byte("x"), 300)),
+			packet{kind: packetPublish, flags: 0x02, qos: 1, packetID: 65535, topic: "t", payload: bytes.Repeat([]byte("x"), 300)}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := reader(tt.packet)
+			p, err := readPacket(r, DefaultMaxMessageSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.kind != tt.want.kind || p.flags != tt.want.flags || p.qos != tt.want.qos || p.packetID != tt.want.packetID ||
+				p.topic != tt.want.topic || !bytes.Equal(p.payload, tt.want.payload) || !bytes.Equal(p.body, tt.want.body) || p.truncated {
+				t.Errorf("got %+v, want %+v", *p, tt.want)
+			}
+			if _, err := r.ReadByte(); err != io.EOF {
+				t.Errorf("the packet was not read to its end")
+			}
+		})
+	}
+}
+
+func TestReadPacketDiscardsLargePayloads(t *testing.T) {
+	r := reader(
+		publishPacket("big", 1, 7, false, bytes.Repeat([]byte("x"), 100)),
+		publishPacket("small", 1, 8, false, []byte("ok")),
+	)
+
+	p, err := readPacket(r, 99)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.truncated || p.payload != nil || p.packetID != 7 || p.topic != "big" {
+		t.Errorf("got %+v, want the truncated PUBLISH 7 without its payload", *p)
+	}
+	// The packet that follows is read whole
+	p, err = readPacket(r, 99)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.truncated || string(p.payload) != "ok" || p.packetID != 8 {
+		t.Errorf("got %+v, want PUBLISH 8", *p)
+	}
+}
+
+func TestReadPacketErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		input []byte
+		err   string
+	}{
+		{"empty", nil, "EOF"},
+		{"no length", []byte{packetConnack << 4}, "unexpected EOF"},
+		{"invalid length", []byte{packetConnack << 4, 0xff, 0xff, 0xff, 0xff}, "invalid remaining length"},
+		{"short body", []byte{packetConnack << 4, 2, 0}, "unexpected EOF"},
+		{"oversized packet", []byte{packetSuback << 4, 0x81, 0x80, 0x04}, "packet of type 9 of 65537 bytes exceeds the limit"},
+		{"PUBLISH without topic length", []byte{packetPublish << 4, 1, 0}, "invalid PUBLISH packet"},
+		{"PUBLISH topic past its end", []byte{packetPublish << 4, 4, 0, 5, 'a', 'b'}, "invalid PUBLISH packet"},
+		{"PUBLISH QoS 1 without packet ID", []byte{packetPublish<<4 | 0x02, 3, 0, 1, 'a'}, "invalid PUBLISH packet"},
+		{"PUBLISH truncated topic", []byte{packetPublish << 4, 5, 0, 3, 'a'}, "unexpected EOF"},
+		{"PUBLISH truncated payload", []byte{packetPublish << 4, 6, 0, 1, 'a', 'x'}, "unexpected EOF"},
+		{"PUBLISH truncated discarded payload", []byte{packetPublish << 4, 0x80, 0x01, 0, 1, 'a', 'x'}, "unexpected EOF"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readPacket(reader(tt.input), 64)
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("readPacket(% x) = %v, want %s", tt.input, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestConnectPacket(t *testing.T) {
+	for _, tt := range []struct {
+		name               string
+		username, password string
+		want               []byte
+	}{
+		{"anonymous", "", "", []byte{
+			packetConnect << 4, 16,
+			0, 4, 'M', 'Q', 'T', 'T', 4,
+			// No clean session, so the broker keeps the session and its unacknowledged messages
+			0x00,
+			0, 30,
+			0, 4, 'd', 'e', 'v', '1',
+		}},
+		{"credentials", "user", "pw", []byte{
+			packetConnect << 4, 26,
+			0, 4, 'M', 'Q', 'T', 'T', 4,
+			0xc0,
+			0, 30,
+			0, 4, 'd', 'e', 'v', '1',
+			0, 4, 'u', 's', 'e', 'r',
+			0, 2, 'p', 'w',
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := connectPacket("dev1", tt.username, tt.password, 30); !bytes.Equal(got, tt.want) {
+				t.Errorf("got % x, want % x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubscribePacket(t *testing.T) {
+	got := subscribePacket(0x0102, []string{"devices/+/telemetry", "audit/#"}, []byte{1, 0})
+	want := []byte{packetSubscribe<<4 | 0x02, 34, 0x01, 0x02}
+	want = append(append(want, 0, 19), "devices/+/telemetry"...)
+	want = append(want, 1)
+	want = append(append(want, 0, 7), "audit/#"...)
+	want = append(want, 0)
+	if !bytes.Equal(got, want) {
+		t.Errorf("got % x, want % x", got, want)
+	}
+}
+
+func TestCheckSuback(t *testing.T) {
+	filters := []string{"a/+", "b/#"}
+	for _, tt := range []struct {
+		body []byte
+		err  string
+	}{
+		{[]byte{0, 1, 1, 1}, ""},
+		{[]byte{0, 1, 0, 1}, ""},
+		{[]byte{0, 1, 1, subackFailure}, "broker refused the subscription to b/#"},
+		{[]byte{0}, "invalid SUBACK"},
+	} {
+		err := checkSuback(&packet{kind: packetSuback, body: tt.body}, filters)
+		if (tt.err == "" && err != nil) || (tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err))) {
+			t.Errorf("checkSuback(% x) = %v, want %q", tt.body, err, tt.err)
+		}
+	}
+}
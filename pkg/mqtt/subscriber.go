@@ -0,0 +1,640 @@
+// Package mqtt records the messages IoT devices publish over MQTT on the ledger, for provenance
+// of device telemetry and audit events. A Subscriber keeps a persistent session with the broker
+// and subscribes to its topics at QoS 1, acknowledging messages only once their logs are
+// submitted, so the broker sends again the messages of a session that ends before then.
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Subscriber defaults
+const (
+	DefaultBroker         = "tcp://localhost:1883"
+	DefaultKeepAlive      = 30 * time.Second
+	DefaultMaxMessageSize = 1 << 20
+	DefaultMaxPending     = 1000
+	DefaultFlushInterval  = client.DefaultFlushInterval
+)
+
+// Timings of a session
+const (
+	// connectTimeout bounds connecting, upgrading to TLS and the CONNACK
+	connectTimeout = 10 * time.Second
+	// idleFlush is how long without a message before the pending ones are flushed, as the broker
+	// stops sending once as many messages as it allows in flight await their PUBACK
+	idleFlush = 100 * time.Millisecond
+	// backpressureWait is how long a session waits before retrying a message the submitter pushed back
+	backpressureWait = 100 * time.Millisecond
+	// shutdownTimeout bounds the flush of the pending messages as the subscriber stops
+	shutdownTimeout = 10 * time.Second
+)
+
+// Payload keys read for the fields of a log. The first message key found becomes the
+// description, the level key the severity and the time key the time; every other key is
+// kept in the metadata.
+const (
+	LevelKey = "level"
+	TimeKey  = "timestamp"
+)
+
+// MessageKeys are the payload keys the description is read from, in order
+var MessageKeys = []string{"message", "msg"}
+
+// Topic is a topic filter subscribed to and how its messages are recorded
+type Topic struct {
+	// Filter is the topic filter, where + matches a level and # the levels that follow, such as
+	// devices/+/telemetry
+	Filter string `json:"filter" yaml:"filter"`
+	// DeviceIDField is the key of a JSON payload holding the device ID, recorded as the user.
+	// The topic level the filter's first + matches is used when empty or missing, and the
+	// payload's userId, or "mqtt", when the filter has no +.
+	DeviceIDField string `json:"deviceIdField,omitempty" yaml:"deviceIdField,omitempty"`
+	// Action is recorded for every message; the topic's last level in upper case, such as
+	// TELEMETRY, is used when empty
+	Action string `json:"action,omitempty" yaml:"action,omitempty"`
+}
+
+// Options configures a Subscriber
+type Options struct {
+	// Broker is the broker's URL: tcp:// or mqtt:// for plain TCP, on port 1883 by default, and
+	// ssl://, tls:// or mqtts:// for TLS, on port 8883. DefaultBroker is used when empty.
+	Broker string
+	// ClientID identifies the session the broker keeps; fablog-mqtt-<hostname> is used when empty
+	ClientID string
+	// Username and Password authenticate the client
+	Username string
+	Password string
+	// TLS configures TLS, such as the client certificate, and enables it whatever the scheme
+	TLS *tls.Config
+	// KeepAlive is the longest the client and broker go without a packet; DefaultKeepAlive is
+	// used when zero
+	KeepAlive time.Duration
+	// Topics are subscribed to at QoS 1; a message is recorded as the first topic matching it
+	// describes
+	Topics []Topic
+
+	// Submitter receives the logs, usually a BatchingSubmitter
+	Submitter client.Submitter
+	// Flush submits every log handed to Submitter, such as BatchingSubmitter.Flush. Messages are
+	// only acknowledged once flushed; without Flush they are acknowledged once handed over.
+	Flush func(ctx context.Context) error
+	// MaxPending is the most messages awaiting their acknowledgement before they are flushed;
+	// DefaultMaxPending is used when zero
+	MaxPending int
+	// FlushInterval is the longest a message awaits its acknowledgement; DefaultFlushInterval
+	// is used when zero
+	FlushInterval time.Duration
+	// MaxMessageSize is the largest payload recorded; larger ones are reported and skipped.
+	// DefaultMaxMessageSize is used when zero.
+	MaxMessageSize int
+	// Retry controls how lost sessions are made again; client.DefaultRetryPolicy is used when zero
+	Retry client.RetryPolicy
+	// Registerer receives the fabric_logging_mqtt_* metrics; prometheus.DefaultRegisterer is used when nil
+	Registerer prometheus.Registerer
+	// OnError is called with the messages that could not be recorded and the sessions that failed
+	OnError func(error)
+}
+
+// Subscriber records the messages of MQTT topics as logs. A JSON object payload's keys are kept
+// in the metadata, besides its message, level and timestamp; any other payload becomes the
+// description, or is kept base64-encoded in the metadata when it is not text. The device ID is
+// recorded as the user and the topic as the resource.
+type Subscriber struct {
+	options Options
+	handler *client.SlogHandler
+	metrics *metrics
+}
+
+// NewSubscriber returns a Subscriber submitting through options.Submitter
+func NewSubscriber(options Options) (*Subscriber, error) {
+	if options.Submitter == nil {
+		return nil, fmt.Errorf("no submitter configured")
+	}
+	if len(options.Topics) == 0 {
+		return nil, fmt.Errorf("no topics configured")
+	}
+	for _, topic := range options.Topics {
+		if err := validFilter(topic.Filter); err != nil {
+			return nil, err
+		}
+	}
+	if options.Broker == "" {
+		options.Broker = DefaultBroker
+	}
+	if options.ClientID == "" {
+		hostname, _ := os.Hostname()
+		options.ClientID = "fablog-mqtt-" + hostname
+	}
+	if options.KeepAlive <= 0 {
+		options.KeepAlive = DefaultKeepAlive
+	}
+	if options.MaxPending <= 0 {
+		options.MaxPending = DefaultMaxPending
+	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = DefaultFlushInterval
+	}
+	if options.MaxMessageSize <= 0 {
+		options.MaxMessageSize = DefaultMaxMessageSize
+	}
+	if options.Retry.MaxAttempts == 0 {
+		options.Retry = client.DefaultRetryPolicy
+	}
+
+	m, err := newMetrics(options.Registerer)
+	if err != nil {
+		return nil, err
+	}
+	handler := client.NewSlogHandler(options.Submitter, client.SlogHandlerOptions{
+		Level: slog.LevelDebug - 4,
+	})
+	return &Subscriber{options: options, handler: handler, metrics: m}, nil
+}
+
+// Run keeps a session with the broker until ctx is done, making it again with backoff when it
+// fails
+func (s *Subscriber) Run(ctx context.Context) error {
+	for attempt := 1; ; attempt++ {
+		connected, err := s.session(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if connected {
+			attempt = 1
+		}
+		s.metrics.sessionFailures.Inc()
+		s.report(fmt.Errorf("MQTT session with %s failed: %w", s.options.Broker, err))
+
+		timer := time.NewTimer(s.options.Retry.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// conn is a broker connection, written by the session and its keepalive
+type conn struct {
+	net.Conn
+	mu sync.Mutex
+}
+
+func (c *conn) write(p []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err := c.Write(p)
+	return err
+}
+
+// dial connects to the broker and waits for it to accept the session
+func (s *Subscriber) dial(ctx context.Context) (*conn, *bufio.Reader, error) {
+	u, err := url.Parse(s.options.Broker)
+	if err != nil || u.Host == "" {
+		return nil, nil, fmt.Errorf("invalid broker URL %q", s.options.Broker)
+	}
+	useTLS := s.options.TLS != nil
+	port := "1883"
+	switch u.Scheme {
+	case "tcp", "mqtt":
+	case "ssl", "tls", "mqtts":
+		useTLS, port = true, "8883"
+	default:
+		return nil, nil, fmt.Errorf("unsupported broker scheme %q", u.Scheme)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+	var dialer net.Dialer
+	netConn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, nil, err
+	}
+	deadline, _ := ctx.Deadline()
+	netConn.SetDeadline(deadline)
+	if useTLS {
+		config := &tls.Config{MinVersion: tls.VersionTLS12}
+		if s.options.TLS != nil {
+			config = s.options.TLS.Clone()
+		}
+		if config.ServerName == "" {
+			config.ServerName = u.Hostname()
+		}
+		tlsConn := tls.Client(netConn, config)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			netConn.Close()
+			return nil, nil, fmt.Errorf("TLS handshake failed: %v", err)
+		}
+		netConn = tlsConn
+	}
+
+	c := &conn{Conn: netConn}
+	r := bufio.NewReader(netConn)
+	keepAlive := uint16(min(s.options.KeepAlive/time.Second, 65535))
+	if err := c.write(connectPacket(s.options.ClientID, s.options.Username, s.options.Password, keepAlive)); err != nil {
+		netConn.Close()
+		return nil, nil, err
+	}
+	p, err := readPacket(r, s.options.MaxMessageSize)
+	if err != nil {
+		netConn.Close()
+		return nil, nil, fmt.Errorf("failed to read CONNACK: %w", err)
+	}
+	if p.kind != packetConnack || len(p.body) != 2 {
+		netConn.Close()
+		return nil, nil, fmt.Errorf("expected CONNACK, got packet type %d", p.kind)
+	}
+	if code := p.body[1]; code != 0 {
+		netConn.Close()
+		if reason, ok := connackReasons[code]; ok {
+			return nil, nil, fmt.Errorf("broker refused the connection: %s", reason)
+		}
+		return nil, nil, fmt.Errorf("broker refused the connection with code %d", code)
+	}
+	netConn.SetDeadline(time.Time{})
+	return c, r, nil
+}
+
+// session subscribes to the topics and records their messages until the connection fails or
+// ctx is done, reporting whether the broker accepted the session
+func (s *Subscriber) session(ctx context.Context) (bool, error) {
+	c, r, err := s.dial(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer c.Close()
+
+	filters := make([]string, len(s.options.Topics))
+	qos := make([]byte, len(s.options.Topics))
+	for i, topic := range s.options.Topics {
+		filters[i], qos[i] = topic.Filter, 1
+	}
+	if err := c.write(subscribePacket(1, filters, qos)); err != nil {
+		return true, err
+	}
+
+	sessionCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Packets are read by a goroutine of their own, so the session can flush on a timer
+	packets := make(chan *packet)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			p, err := readPacket(r, s.options.MaxMessageSize)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case packets <- p:
+			case <-sessionCtx.Done():
+				return
+			}
+		}
+	}()
+
+	var lastRead time.Time
+	var lastReadMu sync.Mutex
+	touch := func() {
+		lastReadMu.Lock()
+		lastRead = time.Now()
+		lastReadMu.Unlock()
+	}
+	touch()
+	go func() {
+		ticker := time.NewTicker(s.options.KeepAlive / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-sessionCtx.Done():
+				return
+			case <-ticker.C:
+				lastReadMu.Lock()
+				silent := time.Since(lastRead)
+				lastReadMu.Unlock()
+				if silent > s.options.KeepAlive*3/2 {
+					// Closing the connection ends the reading goroutine, and with it the session
+					c.Close()
+					return
+				}
+				c.write(encodePacket(packetPingreq, 0, nil))
+			}
+		}
+	}()
+
+	var pending []uint16
+	idle := time.NewTimer(idleFlush)
+	idle.Stop()
+	deadline := time.NewTimer(s.options.FlushInterval)
+	deadline.Stop()
+	flush := func(ctx context.Context) error {
+		idle.Stop()
+		deadline.Stop()
+		if len(pending) == 0 {
+			return nil
+		}
+		if s.options.Flush != nil {
+			if err := s.options.Flush(ctx); err != nil {
+				// Without a PUBACK the broker sends the messages again in the next session
+				return fmt.Errorf("failed to flush messages: %w", err)
+			}
+		}
+		for _, id := range pending {
+			if err := c.write(pubackPacket(id)); err != nil {
+				return err
+			}
+		}
+		pending = pending[:0]
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// The messages already submitted are acknowledged, sparing their resending
+			shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+			defer cancel()
+			if flush(shutdownCtx) == nil {
+				c.write(encodePacket(packetDisconnect, 0, nil))
+			}
+			return true, ctx.Err()
+
+		case err := <-readErr:
+			if errors.Is(err, net.ErrClosed) {
+				err = errors.New("broker stopped responding")
+			}
+			return true, err
+
+		case <-idle.C:
+			if err := flush(ctx); err != nil {
+				return true, err
+			}
+
+		case <-deadline.C:
+			if err := flush(ctx); err != nil {
+				return true, err
+			}
+
+		case p := <-packets:
+			touch()
+			switch p.kind {
+			case packetSuback:
+				if err := checkSuback(p, filters); err != nil {
+					return true, err
+				}
+			case packetPublish:
+				if err := s.receive(ctx, p); err != nil {
+					return true, err
+				}
+				if p.qos == 0 {
+					continue
+				}
+				pending = append(pending, p.packetID)
+				if len(pending) == 1 {
+					deadline.Reset(s.options.FlushInterval)
+				}
+				if len(pending) >= s.options.MaxPending {
+					if err := flush(ctx); err != nil {
+						return true, err
+					}
+					continue
+				}
+				idle.Reset(idleFlush)
+			}
+		}
+	}
+}
+
+// checkSuback fails when the broker refused a subscription
+func checkSuback(p *packet, filters []string) error {
+	if len(p.body) < 2 {
+		return fmt.Errorf("invalid SUBACK")
+	}
+	for i, code := range p.body[2:] {
+		if code == subackFailure && i < len(filters) {
+			return fmt.Errorf("broker refused the subscription to %s", filters[i])
+		}
+	}
+	return nil
+}
+
+// receive hands the log of a message to the submitter, waiting while it pushes back. Messages
+// that cannot be recorded are reported and skipped, as receiving them again would not help.
+func (s *Subscriber) receive(ctx context.Context, p *packet) error {
+	if p.truncated {
+		s.metrics.messages.WithLabelValues("invalid").Inc()
+		s.report(fmt.Errorf("skipped a message on %s larger than %d bytes", p.topic, s.options.MaxMessageSize))
+		return nil
+	}
+	topic, ok := s.topic(p.topic)
+	if !ok {
+		// A message of a subscription the broker kept from an earlier configuration
+		s.metrics.messages.WithLabelValues("invalid").Inc()
+		s.report(fmt.Errorf("skipped a message on %s, which no topic filter matches", p.topic))
+		return nil
+	}
+
+	r := s.record(topic, p)
+	for {
+		err := s.handler.Handle(ctx, r)
+		switch {
+		case err == nil:
+			s.metrics.messages.WithLabelValues("submitted").Inc()
+		case errors.Is(err, client.ErrBackpressure):
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backpressureWait):
+			}
+			continue
+		default:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			s.metrics.messages.WithLabelValues("failed").Inc()
+			s.report(fmt.Errorf("failed to submit a message on %s: %w", p.topic, err))
+		}
+		return nil
+	}
+}
+
+// topic returns the first topic whose filter matches name
+func (s *Subscriber) topic(name string) (Topic, bool) {
+	levels := strings.Split(name, "/")
+	for _, topic := range s.options.Topics {
+		if _, ok := match(strings.Split(topic.Filter, "/"), levels); ok {
+			return topic, true
+		}
+	}
+	return Topic{}, false
+}
+
+// record builds the slog record of a message
+func (s *Subscriber) record(topic Topic, p *packet) slog.Record {
+	fields := make(map[string]interface{})
+	message := ""
+	var object map[string]interface{}
+	if err := json.Unmarshal(p.payload, &object); err == nil && object != nil {
+		for key, value := range object {
+			fields[key] = value
+		}
+		for _, key := range MessageKeys {
+			if value, ok := fields[key]; ok {
+				message = fmt.Sprint(value)
+				delete(fields, key)
+				break
+			}
+		}
+	} else if utf8.Valid(p.payload) {
+		message = string(p.payload)
+	} else {
+		fields["payload"] = base64.StdEncoding.EncodeToString(p.payload)
+	}
+
+	t := time.Now()
+	if value, ok := fields[TimeKey].(string); ok {
+		if parsed, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			t = parsed
+			delete(fields, TimeKey)
+		}
+	}
+	level := slog.LevelInfo
+	if value, ok := fields[LevelKey].(string); ok {
+		if parsed, ok := client.ParseSeverity(strings.ToUpper(value)); ok {
+			level = parsed
+			delete(fields, LevelKey)
+		}
+	}
+
+	levels := strings.Split(p.topic, "/")
+	deviceID, _ := match(strings.Split(topic.Filter, "/"), levels)
+	if topic.DeviceIDField != "" {
+		if value, ok := fields[topic.DeviceIDField]; ok && value != nil && value != "" {
+			deviceID = fmt.Sprint(value)
+		}
+	}
+	for _, key := range []string{client.SlogIDKey, client.SlogUserIDKey, client.SlogActionKey, client.SlogResourceKey} {
+		if value, ok := fields[key]; ok {
+			fields[key] = fmt.Sprint(value)
+		}
+	}
+	if deviceID != "" {
+		fields[client.SlogUserIDKey] = client.Truncate(deviceID, client.MaxUserIDLength)
+	} else if _, ok := fields[client.SlogUserIDKey]; !ok {
+		fields[client.SlogUserIDKey] = "mqtt"
+	}
+	fields[client.SlogResourceKey] = client.Truncate(p.topic, client.MaxResourceLength)
+	if topic.Action != "" {
+		fields[client.SlogActionKey] = topic.Action
+	} else if _, ok := fields[client.SlogActionKey]; !ok {
+		fields[client.SlogActionKey] = levelAction(levels[len(levels)-1])
+	}
+	if p.flags&0x01 != 0 {
+		fields["retained"] = true
+	}
+
+	r := slog.NewRecord(t, level, client.Truncate(message, client.MaxDescriptionLength), 0)
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		r.AddAttrs(slog.Any(key, fields[key]))
+	}
+	return r
+}
+
+func (s *Subscriber) report(err error) {
+	if s.options.OnError != nil {
+		s.options.OnError(err)
+	}
+}
+
+// match reports whether topic levels match filter levels, returning the level the filter's
+// first + matched
+func match(filter, levels []string) (string, bool) {
+	wildcard := ""
+	for i, f := range filter {
+		if f == "#" {
+			return wildcard, true
+		}
+		if i >= len(levels) {
+			return "", false
+		}
+		if f == "+" {
+			if wildcard == "" {
+				wildcard = levels[i]
+			}
+			continue
+		}
+		if f != levels[i] {
+			return "", false
+		}
+	}
+	return wildcard, len(filter) == len(levels)
+}
+
+// validFilter checks a topic filter, whose # may only be its last level
+func validFilter(filter string) error {
+	if filter == "" {
+		return fmt.Errorf("a topic has no filter")
+	}
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		if strings.Contains(level, "#") && (level != "#" || i != len(levels)-1) {
+			return fmt.Errorf("invalid topic filter %s: # must be the last level", filter)
+		}
+		if strings.Contains(level, "+") && level != "+" {
+			return fmt.Errorf("invalid topic filter %s: + must be a whole level", filter)
+		}
+	}
+	if len(filter) > 65535 {
+		return fmt.Errorf("invalid topic filter %s: too long", filter)
+	}
+	return nil
+}
+
+// levelAction returns the action of a topic level: the level in upper case, with the
+// characters an action cannot hold replaced
+func levelAction(level string) string {
+	action := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, level)
+	if action == "" || action[0] < 'A' || action[0] > 'Z' {
+		action = "MQTT_" + action
+	}
+	return client.Truncate(action, client.MaxActionLength)
+}
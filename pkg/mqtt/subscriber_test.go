@@ -0,0 +1,256 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// recorder is a submitter keeping the logs it receives, and how many had been received at each flush
+type recorder struct {
+	mu       sync.Mutex
+	logs     []client.LogEvent
+	flushed  []int
+	flushErr error
+}
+
+func (r *recorder) CreateLog(_ context.Context, log client.LogEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logs = append(r.logs, log)
+	return nil
+}
+
+func (r *recorder) Flush(context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.flushed = append(r.flushed, len(r.logs))
+	return r.flushErr
+}
+
+func (r *recorder) state() ([]client.LogEvent, []int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]client.LogEvent(nil), r.logs...), append([]int(nil), r.flushed...)
+}
+
+// broker is the broker side of a subscriber's connection
+type broker struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (b *broker) send(packets ...[]byte) {
+	for _, p := range packets {
+		if _, err := b.conn.Write(p); err != nil {
+			b.t.Errorf("broker failed to send: %v", err)
+		}
+	}
+}
+
+// read reads the next packet from the subscriber, failing the test unless it is of kind
+func (b *broker) read(kind byte) *packet {
+	b.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	p, err := readPacket(b.r, 1<<16)
+	if err != nil {
+		b.t.Errorf("broker expected packet type %d, got error %v", kind, err)
+		return nil
+	}
+	if p.kind != kind {
+		b.t.Errorf("broker expected packet type %d, got %d", kind, p.kind)
+		return nil
+	}
+	return p
+}
+
+// accept plays the broker's side of connecting and subscribing
+func (b *broker) accept() {
+	b.read(packetConnect)
+	b.send(encodePacket(packetConnack, 0, []byte{0, 0}))
+	if p := b.read(packetSubscribe); p != nil {
+		b.send(encodePacket(packetSuback, 0, append(p.body[:2:2], 1)))
+	}
+}
+
+// startBroker accepts one connection and runs script on it, returning the broker's URL. The
+// test waits for the script to finish.
+func startBroker(t *testing.T, script func(b *broker)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Errorf("accept: %v", err)
+			return
+		}
+		defer conn.Close()
+		script(&broker{t: t, conn: conn, r: bufio.NewReader(conn)})
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		<-done
+	})
+	return "tcp://" + ln.Addr().String()
+}
+
+func newTestSubscriber(t *testing.T, url string, submitter *recorder, errs *[]error) *Subscriber {
+	t.Helper()
+	var mu sync.Mutex
+	s, err := NewSubscriber(Options{
+		Broker:         url,
+		ClientID:       "test",
+		Topics:         []Topic{{Filter: "devices/+/telemetry"}},
+		Submitter:      submitter,
+		Flush:          submitter.Flush,
+		MaxMessageSize: 64,
+		Registerer:     prometheus.NewRegistry(),
+		OnError: func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			*errs = append(*errs, err)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSessionAcknowledgesQoS1AfterFlush(t *testing.T) {
+	submitter := &recorder{}
+	acked := make(chan []uint16, 1)
+	url := startBroker(t, func(b *broker) {
+		b.accept()
+		b.send(
+			publishPacket("devices/d1/telemetry", 1, 7, false, []byte(`{"message":"hi","level":"warn"}`)),
+			publishPacket("devices/d2/telemetry", 0, 0, false, []byte("qos 0")),
+			// Too large to record, but acknowledged as it would otherwise be sent again
+			publishPacket("devices/d3/telemetry", 1, 8, false, bytes.Repeat([]byte("x"), 65)),
+			publishPacket("devices/d4/telemetry", 1, 9, false, []byte("third")),
+		)
+		var ids []uint16
+		for len(ids) < 3 {
+			p := b.read(packetPuback)
+			if p == nil {
+				break
+			}
+			ids = append(ids, binary.BigEndian.Uint16(p.body))
+		}
+		acked <- ids
+		b.read(packetDisconnect)
+	})
+	var errs []error
+	s := newTestSubscriber(t, url, submitter, &errs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		_, err := s.session(ctx)
+		result <- err
+	}()
+
+	select {
+	case ids := <-acked:
+		if len(ids) != 3 || ids[0] != 7 || ids[1] != 8 || ids[2] != 9 {
+			t.Errorf("acknowledged %v, want [7 8 9]", ids)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the messages were not acknowledged")
+	}
+	logs, flushed := submitter.state()
+	// Every message was handed over before the flush that preceded the PUBACKs
+	if len(flushed) == 0 || flushed[0] != 3 {
+		t.Errorf("flushed after %v logs, want a flush after all 3", flushed)
+	}
+	if len(logs) != 3 || logs[0].UserID != "d1" || logs[0].Description != "hi" || logs[1].Description != "qos 0" || logs[2].UserID != "d4" {
+		t.Errorf("logs = %+v", logs)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "larger than 64 bytes") {
+		t.Errorf("errors = %v, want the oversized message reported", errs)
+	}
+
+	cancel()
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Errorf("session = %v, want context.Canceled", err)
+	}
+}
+
+func TestSessionWithholdsAcksWhenFlushFails(t *testing.T) {
+	submitter := &recorder{flushErr: errors.New("peer unavailable")}
+	url := startBroker(t, func(b *broker) {
+		b.accept()
+		b.send(publishPacket("devices/d1/telemetry", 1, 7, false, []byte("hi")))
+		// The session ends without a PUBACK, so the broker sends the message again
+		b.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if p, err := readPacket(b.r, 1<<16); err != io.EOF {
+			t.Errorf("broker got %+v, %v, want the connection closed", p, err)
+		}
+	})
+	var errs []error
+	s := newTestSubscriber(t, url, submitter, &errs)
+
+	_, err := s.session(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "failed to flush messages: peer unavailable") {
+		t.Fatalf("session = %v, want the flush error", err)
+	}
+}
+
+func TestSessionRefused(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		script    func(b *broker)
+		connected bool
+		err       string
+	}{
+		{"bad credentials", func(b *broker) {
+			b.read(packetConnect)
+			b.send(encodePacket(packetConnack, 0, []byte{0, 4}))
+		}, false, "broker refused the connection: bad user name or password"},
+		{"unknown code", func(b *broker) {
+			b.read(packetConnect)
+			b.send(encodePacket(packetConnack, 0, []byte{0, 42}))
+		}, false, "broker refused the connection with code 42"},
+		{"no CONNACK", func(b *broker) {
+			b.read(packetConnect)
+			b.send(encodePacket(packetPingresp, 0, nil))
+		}, false, "expected CONNACK, got packet type 13"},
+		{"closed before CONNACK", func(b *broker) {
+			b.read(packetConnect)
+		}, false, "failed to read CONNACK: EOF"},
+		{"subscription refused", func(b *broker) {
+			b.read(packetConnect)
+			b.send(encodePacket(packetConnack, 0, []byte{0, 0}))
+			b.read(packetSubscribe)
+			b.send(encodePacket(packetSuback, 0, []byte{0, 1, subackFailure}))
+		}, true, "broker refused the subscription to devices/+/telemetry"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			url := startBroker(t, tt.script)
+			var errs []error
+			s := newTestSubscriber(t, url, &recorder{}, &errs)
+
+			connected, err := s.session(context.Background())
+			if connected != tt.connected || err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("session = %v, %v, want %v, an error containing %q", connected, err, tt.connected, tt.err)
+			}
+		})
+	}
+}
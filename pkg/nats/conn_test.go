@@ -0,0 +1,396 @@
+package nats
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// peer is the server side of a connection to a scripted server
+type peer struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// send writes protocol lines, each terminated by CRLF
+func (p *peer) send(lines ...string) {
+	for _, line := range lines {
+		if _, err := io.WriteString(p.conn, line+"\r\n"); err != nil {
+			p.t.Errorf("server failed to send %q: %v", line, err)
+		}
+	}
+}
+
+// expect reads a line from the client and checks it starts with prefix, returning the rest
+func (p *peer) expect(prefix string) string {
+	p.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := p.r.ReadString('\n')
+	if err != nil {
+		p.t.Errorf("server expected %q, got error %v", prefix, err)
+		return ""
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if !strings.HasPrefix(line, prefix) {
+		p.t.Errorf("server expected %q, got %q", prefix, line)
+		return ""
+	}
+	return strings.TrimPrefix(line, prefix)
+}
+
+// handshake plays the server's side of a successful handshake, returning the client's CONNECT
+func (p *peer) handshake(info string) connectOptions {
+	p.send("INFO " + info)
+	var connect connectOptions
+	if err := json.Unmarshal([]byte(p.expect("CONNECT ")), &connect); err != nil {
+		p.t.Errorf("invalid CONNECT: %v", err)
+	}
+	p.expect("PING")
+	p.send("PONG")
+	return connect
+}
+
+// scriptedServer accepts one connection and runs script on it, returning the URL to dial.
+// The test waits for the script to finish.
+func scriptedServer(t *testing.T, script func(p *peer)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Errorf("accept: %v", err)
+			return
+		}
+		defer conn.Close()
+		script(&peer{t: t, conn: conn, r: bufio.NewReader(conn)})
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		<-done
+	})
+	return "nats://" + ln.Addr().String()
+}
+
+func dialTest(t *testing.T, url string, options Options) *conn {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := dial(ctx, url, options)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { c.close() })
+	return c
+}
+
+func TestDialAuthenticates(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		userURL string
+		options Options
+		check   func(connect connectOptions) error
+	}{
+		{"user in URL", "alice:secret@", Options{Name: "bridge"}, func(connect connectOptions) error {
+			if connect.User != "alice" || connect.Pass != "secret" || connect.AuthToken != "" || connect.Name != "bridge" {
+				return fmt.Errorf("user %q, pass %q, token %q, name %q", connect.User, connect.Pass, connect.AuthToken, connect.Name)
+			}
+			return nil
+		}},
+		{"token in URL", "s3cr3t@", Options{User: "ignored"}, func(connect connectOptions) error {
+			if connect.AuthToken != "s3cr3t" {
+				return fmt.Errorf("token %q", connect.AuthToken)
+			}
+			return nil
+		}},
+		{"options", "", Options{User: "bob", Password: "pw"}, func(connect connectOptions) error {
+			if connect.User != "bob" || connect.Pass != "pw" {
+				return fmt.Errorf("user %q, pass %q", connect.User, connect.Pass)
+			}
+			return nil
+		}},
+		{"protocol", "", Options{}, func(connect connectOptions) error {
+			if connect.Verbose || connect.Protocol != 1 || !connect.Headers || !connect.NoResponders || connect.TLSRequired {
+				return fmt.Errorf("unexpected CONNECT %+v", connect)
+			}
+			return nil
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			url := scriptedServer(t, func(p *peer) {
+				connect := p.handshake(`{"max_payload":1048576}`)
+				if err := tt.check(connect); err != nil {
+					t.Error(err)
+				}
+			})
+			dialTest(t, strings.Replace(url, "nats://", "nats://"+tt.userURL, 1), tt.options)
+		})
+	}
+}
+
+func TestDialSignsNonce(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	// A user seed carries the seed prefix and the user prefix across its first two bytes
+	encodedSeed := encodeNKey(prefixSeed|prefixUser>>5, append([]byte{(prefixUser & 0x1f) << 3}, seed...))
+	creds := filepath.Join(t.TempDir(), "user.creds")
+	err := os.WriteFile(creds, []byte(fmt.Sprintf(`-----BEGIN NATS USER JWT-----
+eyJhbGciOiJlZDI1NTE5In0.e30.c2ln
+------END NATS USER JWT------
+
+-----BEGIN USER NKEY SEED-----
+%s
+------END USER NKEY SEED------
+`, encodedSeed)), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	url := scriptedServer(t, func(p *peer) {
+		connect := p.handshake(`{"nonce":"abc123"}`)
+		if connect.JWT != "eyJhbGciOiJlZDI1NTE5In0.e30.c2ln" {
+			t.Errorf("jwt = %q", connect.JWT)
+		}
+		key := ed25519.NewKeyFromSeed(seed)
+		if want := encodeNKey(prefixUser, key.Public().(ed25519.PublicKey)); connect.NKey != want {
+			t.Errorf("nkey = %q, want %q", connect.NKey, want)
+		}
+		signature, err := base64.RawURLEncoding.DecodeString(connect.Sig)
+		if err != nil || !ed25519.Verify(key.Public().(ed25519.PublicKey), []byte("abc123"), signature) {
+			t.Errorf("sig %q does not sign the nonce: %v", connect.Sig, err)
+		}
+	})
+	dialTest(t, url, Options{Credentials: creds})
+}
+
+func TestDialHandshake(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		script func(p *peer)
+		err    string
+	}{
+		{"ok and ping before pong", func(p *peer) {
+			p.send(`INFO {}`)
+			p.expect("CONNECT ")
+			p.expect("PING")
+			p.send("+OK", "PING")
+			p.expect("PONG")
+			p.send("PONG")
+		}, ""},
+		{"lower case operations", func(p *peer) {
+			p.send(`info {}`)
+			p.expect("CONNECT ")
+			p.expect("PING")
+			p.send("pong")
+		}, ""},
+		{"authorization violation", func(p *peer) {
+			p.send(`INFO {}`)
+			p.expect("CONNECT ")
+			p.expect("PING")
+			p.send("-ERR 'Authorization Violation'")
+		}, "server refused the connection: Authorization Violation"},
+		{"no info", func(p *peer) {
+			p.send("PING")
+		}, `unexpected "PING" from server`},
+		{"invalid info", func(p *peer) {
+			p.send("INFO {not json")
+		}, "invalid server info"},
+		{"closed before info", func(p *peer) {}, "failed to read server info: EOF"},
+		{"closed inside info", func(p *peer) {
+			io.WriteString(p.conn, "INFO {")
+		}, "failed to read server info: unexpected EOF"},
+		{"closed before pong", func(p *peer) {
+			p.send(`INFO {}`)
+			p.expect("CONNECT ")
+			p.expect("PING")
+		}, "failed to authenticate: EOF"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			url := scriptedServer(t, tt.script)
+			c, err := dial(context.Background(), url, Options{})
+			if c != nil {
+				c.close()
+			}
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("dial: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("dial = %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestConnAnswersPings(t *testing.T) {
+	answered := make(chan struct{})
+	url := scriptedServer(t, func(p *peer) {
+		p.handshake(`{}`)
+		for i := 0; i < 3; i++ {
+			p.send("PING")
+			p.expect("PONG")
+		}
+		close(answered)
+		p.expect("PING")
+		p.send("+OK", "INFO {}", "PONG")
+	})
+	c := dialTest(t, url, Options{})
+
+	select {
+	case <-answered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the server's pings were not answered")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.flush(ctx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+}
+
+func TestConnPublishSubscribe(t *testing.T) {
+	url := scriptedServer(t, func(p *peer) {
+		p.handshake(`{"headers":true}`)
+		sid := p.expect("SUB logs.> ")
+		p.expect("PUB logs.in _INBOX.x 5")
+		p.expect("hello")
+		p.expect("PUB logs.in 0")
+		p.expect("")
+		p.send(
+			"MSG logs.in "+sid+" 5",
+			"hello",
+			"MSG logs.in "+sid+" _INBOX.reply 0",
+			"",
+			"HMSG _INBOX.x "+sid+" 38 38",
+			"NATS/1.0 408 Request Timeout\r\nA: b\r\n\r\n",
+			"MSG logs.other 99 3",
+			"abc",
+			"HMSG logs.in "+sid+" 18 22",
+			"NATS/1.0\r\nK: v\r\n\r\ndata",
+		)
+		p.expect("UNSUB " + sid)
+	})
+	c := dialTest(t, url, Options{})
+
+	sid, messages, err := c.subscribe("logs.>", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.publish("logs.in", "_INBOX.x", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.publish("logs.in", "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []msg{
+		{subject: "logs.in", data: []byte("hello")},
+		{subject: "logs.in", reply: "_INBOX.reply", data: []byte{}},
+		{subject: "_INBOX.x", status: "408 Request Timeout", header: map[string]string{"A": "b"}, data: []byte{}},
+		// The message of the unknown subscription 99 is dropped
+		{subject: "logs.in", header: map[string]string{"K": "v"}, data: []byte("data")},
+	} {
+		select {
+		case m := <-messages:
+			if m.subject != want.subject || m.reply != want.reply || m.status != want.status || string(m.data) != string(want.data) || fmt.Sprint(m.header) != fmt.Sprint(want.header) {
+				t.Errorf("got %+v, want %+v", *m, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no message delivered, want %+v", want)
+		}
+	}
+	if err := c.unsubscribe(sid); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConnPermissionsViolation(t *testing.T) {
+	url := scriptedServer(t, func(p *peer) {
+		p.handshake(`{}`)
+		p.send(`-ERR 'Permissions Violation for Publish to "logs.secret"'`)
+		p.expect("PING")
+		p.send("PONG")
+	})
+	c := dialTest(t, url, Options{})
+
+	// The error leaves the connection open
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.flush(ctx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+}
+
+func TestConnLost(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		send []string
+		err  string
+	}{
+		{"server error", []string{"-ERR 'Stale Connection'"}, "connection lost: server error: Stale Connection"},
+		{"unknown operation", []string{"BOGUS"}, `connection lost: unknown operation "BOGUS" from server`},
+		{"invalid message", []string{"MSG logs.in 1"}, `connection lost: invalid message arguments "logs.in 1"`},
+		{"invalid subscription", []string{"MSG logs.in x 1"}, `connection lost: invalid subscription "x"`},
+		{"oversized message", []string{fmt.Sprintf("MSG logs.in 1 %d", maxPayload+1)}, "connection lost: invalid message size"},
+		{"header larger than message", []string{"HMSG logs.in 1 10 5"}, `connection lost: invalid header size "10"`},
+		{"truncated payload", []string{"MSG logs.in 1 10", "abc"}, "connection lost: unexpected EOF"},
+		{"closed by the server", nil, "connection lost: EOF"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			url := scriptedServer(t, func(p *peer) {
+				p.handshake(`{}`)
+				p.send(tt.send...)
+			})
+			c := dialTest(t, url, Options{})
+
+			select {
+			case <-c.done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("the connection was not closed")
+			}
+			if err := c.closedErr(); !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("closedErr = %v, want an error containing %q", err, tt.err)
+			}
+			if err := c.flush(context.Background()); err == nil {
+				t.Fatal("flush succeeded on a lost connection")
+			}
+		})
+	}
+}
+
+func TestConnClose(t *testing.T) {
+	url := scriptedServer(t, func(p *peer) {
+		p.handshake(`{}`)
+		p.r.ReadString('\n')
+	})
+	c := dialTest(t, url, Options{})
+
+	if err := c.close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := c.closedErr(); err.Error() != "connection closed" {
+		t.Fatalf("closedErr = %v, want connection closed", err)
+	}
+	if err := c.flush(context.Background()); err == nil {
+		t.Fatal("flush succeeded after close")
+	}
+}
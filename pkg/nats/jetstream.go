@@ -0,0 +1,194 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JetStream is driven through requests on the $JS.API subjects. The bridge creates its durable
+// pull consumer, asks it for batches of messages, and acknowledges each message by publishing
+// to the reply subject it was delivered with.
+
+// Acknowledgements of a JetStream message
+var (
+	ackAck        = []byte("+ACK")
+	ackNak        = []byte("-NAK")
+	ackInProgress = []byte("+WIP")
+	ackTerm       = []byte("+TERM")
+)
+
+// requestTimeout bounds JetStream API requests
+const requestTimeout = 10 * time.Second
+
+// consumerConfig is the configuration of the bridge's durable consumer
+type consumerConfig struct {
+	Durable       string `json:"durable_name"`
+	DeliverPolicy string `json:"deliver_policy"`
+	AckPolicy     string `json:"ack_policy"`
+	AckWait       int64  `json:"ack_wait"`
+	MaxAckPending int    `json:"max_ack_pending,omitempty"`
+	FilterSubject string `json:"filter_subject,omitempty"`
+}
+
+// apiError is the error of a JetStream API response
+type apiError struct {
+	Code        int    `json:"code"`
+	ErrCode     int    `json:"err_code"`
+	Description string `json:"description"`
+}
+
+// jsMsg is a message of a stream with its position
+type jsMsg struct {
+	*msg
+	stream   string
+	sequence uint64
+	time     time.Time
+}
+
+// request sends data to subject and waits for the reply
+func (c *conn) request(ctx context.Context, subject string, data []byte) (*msg, error) {
+	inbox := newInbox()
+	sid, replies, err := c.subscribe(inbox, 1)
+	if err != nil {
+		return nil, err
+	}
+	defer c.unsubscribe(sid)
+
+	if err := c.publish(subject, inbox, data); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	select {
+	case m := <-replies:
+		if strings.HasPrefix(m.status, "503") {
+			return nil, fmt.Errorf("no responders for %s: is JetStream enabled?", subject)
+		}
+		return m, nil
+	case <-c.done:
+		return nil, c.closedErr()
+	case <-ctx.Done():
+		return nil, fmt.Errorf("no reply to %s: %w", subject, ctx.Err())
+	}
+}
+
+// ensureConsumer creates the durable consumer of stream, or finds it already created with the
+// same configuration
+func (c *conn) ensureConsumer(ctx context.Context, stream string, config consumerConfig) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"stream_name": stream,
+		"config":      config,
+	})
+	if err != nil {
+		return err
+	}
+
+	reply, err := c.request(ctx, fmt.Sprintf("$JS.API.CONSUMER.DURABLE.CREATE.%s.%s", stream, config.Durable), payload)
+	if err != nil {
+		return fmt.Errorf("failed to create consumer %s: %w", config.Durable, err)
+	}
+	var response struct {
+		Error *apiError `json:"error"`
+	}
+	if err := json.Unmarshal(reply.data, &response); err != nil {
+		return fmt.Errorf("invalid reply creating consumer %s: %v", config.Durable, err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("failed to create consumer %s on stream %s: %s", config.Durable, stream, response.Error.Description)
+	}
+	return nil
+}
+
+// fetch asks the consumer for up to batch messages and returns those delivered before the
+// request expires
+func (c *conn) fetch(ctx context.Context, stream, consumer string, batch int, expires time.Duration) ([]jsMsg, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"batch":   batch,
+		"expires": expires.Nanoseconds(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Each request has an inbox of its own, so messages of an expired request arriving late are
+	// dropped, to be delivered again once their ack wait passes, rather than mistaken for these
+	inbox := newInbox()
+	sid, replies, err := c.subscribe(inbox, batch+1)
+	if err != nil {
+		return nil, err
+	}
+	defer c.unsubscribe(sid)
+	if err := c.publish(fmt.Sprintf("$JS.API.CONSUMER.MSG.NEXT.%s.%s", stream, consumer), inbox, payload); err != nil {
+		return nil, err
+	}
+
+	// The server ends a request with a status once it expires; the timer only covers a lost one
+	timer := time.NewTimer(expires + time.Second)
+	defer timer.Stop()
+	var messages []jsMsg
+	for len(messages) < batch {
+		select {
+		case m := <-replies:
+			switch code, _, _ := strings.Cut(m.status, " "); code {
+			case "":
+			case "404", "408":
+				return messages, nil
+			case "100":
+				// Heartbeats are only sent when asked for
+				continue
+			default:
+				return messages, fmt.Errorf("consumer %s: %s", consumer, m.status)
+			}
+			jm, err := newJSMsg(m)
+			if err != nil {
+				return messages, err
+			}
+			messages = append(messages, jm)
+		case <-timer.C:
+			return messages, nil
+		case <-c.done:
+			return messages, c.closedErr()
+		case <-ctx.Done():
+			return messages, ctx.Err()
+		}
+	}
+	return messages, nil
+}
+
+// newJSMsg reads the position of a message from its reply subject, which is
+// $JS.ACK.<stream>.<consumer>.<delivered>.<stream seq>.<consumer seq>.<time>.<pending>, or
+// has a domain and account hash after $JS.ACK on servers since 2.9
+func newJSMsg(m *msg) (jsMsg, error) {
+	tokens := strings.Split(m.reply, ".")
+	if len(tokens) < 9 || tokens[0] != "$JS" || tokens[1] != "ACK" {
+		return jsMsg{}, fmt.Errorf("message on %s is not a JetStream message", m.subject)
+	}
+	if len(tokens) >= 12 {
+		tokens = append(tokens[:2], tokens[4:]...)
+	}
+
+	sequence, err := strconv.ParseUint(tokens[5], 10, 64)
+	if err != nil {
+		return jsMsg{}, fmt.Errorf("invalid JetStream reply subject %s", m.reply)
+	}
+	nanos, err := strconv.ParseInt(tokens[7], 10, 64)
+	if err != nil {
+		return jsMsg{}, fmt.Errorf("invalid JetStream reply subject %s", m.reply)
+	}
+	return jsMsg{msg: m, stream: tokens[2], sequence: sequence, time: time.Unix(0, nanos)}, nil
+}
+
+// ack acknowledges messages with kind, such as ackAck, and waits for the server to have
+// received the acknowledgements
+func (c *conn) ack(ctx context.Context, messages []jsMsg, kind []byte) error {
+	for _, m := range messages {
+		if err := c.publish(m.reply, "", kind); err != nil {
+			return err
+		}
+	}
+	return c.flush(ctx)
+}
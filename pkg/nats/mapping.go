@@ -0,0 +1,251 @@
+package nats
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"gopkg.in/yaml.v2"
+)
+
+// SubjectMapping maps the messages of the subjects matching Subject to logs, with an optional
+// text/template per field executed on a Message. Without a template, the ID is the stream and
+// sequence of the message, so a message delivered again maps to the log already recorded; the
+// action is the subject in upper case, such as AUDIT_ORDERS_CREATED for audit.orders.created,
+// and the resource the subject itself. The user is the userId of a JSON message, or "nats",
+// the description its description, or the whole message, and the metadata its position in
+// the stream.
+type SubjectMapping struct {
+	// Subject is a subject pattern, where * matches a token and > the tokens that follow
+	Subject     string `json:"subject" yaml:"subject"`
+	ID          string `json:"id,omitempty" yaml:"id,omitempty"`
+	UserID      string `json:"userId,omitempty" yaml:"userId,omitempty"`
+	Action      string `json:"action,omitempty" yaml:"action,omitempty"`
+	Resource    string `json:"resource,omitempty" yaml:"resource,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Metadata must execute to a JSON object
+	Metadata string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+}
+
+// mappingConfig is a mapping file
+type mappingConfig struct {
+	Subjects []SubjectMapping `json:"subjects" yaml:"subjects"`
+}
+
+// LoadMappings reads the subject mappings of a mapping file, in YAML or JSON
+func LoadMappings(path string) ([]SubjectMapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mapping: %v", err)
+	}
+
+	var cfg mappingConfig
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse mapping %s: %v", path, err)
+	}
+	return cfg.Subjects, nil
+}
+
+// Message is the data a SubjectMapping's templates execute on
+type Message struct {
+	Subject string
+	// Tokens are the dot-separated tokens of the subject
+	Tokens   []string
+	Stream   string
+	Sequence uint64
+	Data     string
+	Headers  map[string]string
+	Time     time.Time
+	// JSON is the data decoded as JSON, nil when it is not JSON
+	JSON interface{}
+}
+
+// templateFuncs are the functions available to a SubjectMapping's templates besides the
+// built-in ones
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	// default returns value, or fallback when value is empty
+	"default": func(fallback, value interface{}) interface{} {
+		if value == nil || value == "" {
+			return fallback
+		}
+		return value
+	},
+	// field returns the value at a dotted path of a decoded JSON object, or nil when there is
+	// none; unlike .JSON.a.b it does not fail when a message lacks the field
+	"field": func(v interface{}, path string) interface{} {
+		for _, key := range strings.Split(path, ".") {
+			object, ok := v.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			v = object[key]
+		}
+		return v
+	},
+}
+
+// mapper is a parsed SubjectMapping
+type mapper struct {
+	pattern                                             []string
+	id, userID, action, resource, description, metadata *template.Template
+}
+
+func newMappers(mappings []SubjectMapping) ([]*mapper, error) {
+	mappers := make([]*mapper, 0, len(mappings))
+	for _, mapping := range mappings {
+		if mapping.Subject == "" {
+			return nil, fmt.Errorf("a subject mapping has no subject")
+		}
+		m := &mapper{pattern: strings.Split(mapping.Subject, ".")}
+		for _, field := range []struct {
+			name string
+			text string
+			t    **template.Template
+		}{
+			{"id", mapping.ID, &m.id},
+			{"userId", mapping.UserID, &m.userID},
+			{"action", mapping.Action, &m.action},
+			{"resource", mapping.Resource, &m.resource},
+			{"description", mapping.Description, &m.description},
+			{"metadata", mapping.Metadata, &m.metadata},
+		} {
+			if field.text == "" {
+				continue
+			}
+			t, err := template.New(field.name).Option("missingkey=error").Funcs(templateFuncs).Parse(field.text)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s template of subject %s: %v", field.name, mapping.Subject, err)
+			}
+			*field.t = t
+		}
+		mappers = append(mappers, m)
+	}
+	return mappers, nil
+}
+
+// matches reports whether the subject tokens match the mapper's pattern
+func (m *mapper) matches(tokens []string) bool {
+	for i, p := range m.pattern {
+		if p == ">" {
+			return len(tokens) > i
+		}
+		if i >= len(tokens) || (p != "*" && p != tokens[i]) {
+			return false
+		}
+	}
+	return len(tokens) == len(m.pattern)
+}
+
+// mapLog maps a message to its log with the first mapper matching its subject, or with the
+// defaults when none does
+func mapLog(mappers []*mapper, jm jsMsg) (client.LogEvent, error) {
+	data := Message{
+		Subject:  jm.subject,
+		Tokens:   strings.Split(jm.subject, "."),
+		Stream:   jm.stream,
+		Sequence: jm.sequence,
+		Data:     string(jm.data),
+		Headers:  jm.header,
+		Time:     jm.time,
+	}
+	if err := json.Unmarshal(jm.data, &data.JSON); err != nil {
+		data.JSON = nil
+	}
+	object, _ := data.JSON.(map[string]interface{})
+
+	log := client.LogEvent{
+		ID:          messageID(jm),
+		UserID:      "nats",
+		Action:      subjectAction(jm.subject),
+		Resource:    client.Truncate(jm.subject, client.MaxResourceLength),
+		Timestamp:   jm.time.UTC().Format(time.RFC3339Nano),
+		Description: client.Truncate(data.Data, client.MaxDescriptionLength),
+	}
+	if userID, ok := object["userId"].(string); ok && userID != "" {
+		log.UserID = userID
+	}
+	if description, ok := object["description"].(string); ok {
+		log.Description = client.Truncate(description, client.MaxDescriptionLength)
+	}
+	metadata, err := json.Marshal(map[string]interface{}{
+		"subject":  jm.subject,
+		"stream":   jm.stream,
+		"sequence": jm.sequence,
+	})
+	if err != nil {
+		return client.LogEvent{}, err
+	}
+	log.Metadata = string(metadata)
+
+	var m *mapper
+	for _, candidate := range mappers {
+		if candidate.matches(data.Tokens) {
+			m = candidate
+			break
+		}
+	}
+	if m == nil {
+		return log, nil
+	}
+	for _, field := range []struct {
+		t     *template.Template
+		value *string
+	}{
+		{m.id, &log.ID},
+		{m.userID, &log.UserID},
+		{m.action, &log.Action},
+		{m.resource, &log.Resource},
+		{m.description, &log.Description},
+		{m.metadata, &log.Metadata},
+	} {
+		if field.t == nil {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := field.t.Execute(&buf, data); err != nil {
+			return client.LogEvent{}, fmt.Errorf("%w: %v", client.ErrInvalidLog, err)
+		}
+		*field.value = buf.String()
+	}
+	return log, nil
+}
+
+// messageID identifies a message by its position, hashed when too long for a log ID
+func messageID(jm jsMsg) string {
+	id := "nats-" + jm.stream + "-" + strconv.FormatUint(jm.sequence, 10)
+	if len(id) <= client.MaxIDLength {
+		return id
+	}
+	hashed, _ := client.ContentHash()(client.LogEvent{Resource: id})
+	return "nats-" + hashed
+}
+
+// subjectAction returns the action of a subject: its tokens in upper case, joined by
+// underscores, with the characters an action cannot hold replaced
+func subjectAction(subject string) string {
+	action := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, subject)
+	if action == "" || action[0] < 'A' || action[0] > 'Z' {
+		action = "NATS_" + action
+	}
+	return client.Truncate(action, client.MaxActionLength)
+}
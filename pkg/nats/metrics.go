@@ -0,0 +1,52 @@
+package nats
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricsNamespace prefixes every metric exported by the bridge, as it does the client's
+const metricsNamespace = "fabric_logging"
+
+// metrics holds the Prometheus instruments of a Bridge
+type metrics struct {
+	messages           *prometheus.CounterVec
+	submitFailures     prometheus.Counter
+	connectionFailures prometheus.Counter
+}
+
+func newMetrics(registerer prometheus.Registerer) (*metrics, error) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	m := &metrics{
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "nats_bridge",
+			Name:      "messages_total",
+			Help:      "JetStream messages consumed, by result: submitted or invalid.",
+		}, []string{"result"}),
+		submitFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "nats_bridge",
+			Name:      "submit_failures_total",
+			Help:      "Batches of consumed messages that failed to be submitted, including those retried.",
+		}),
+		connectionFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "nats_bridge",
+			Name:      "connection_failures_total",
+			Help:      "Connections to the NATS server that failed or were lost.",
+		}),
+	}
+
+	for _, collector := range []prometheus.Collector{m.messages, m.submitFailures, m.connectionFailures} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, fmt.Errorf("failed to register NATS bridge metrics: %v", err)
+		}
+	}
+
+	return m, nil
+}
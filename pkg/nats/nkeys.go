@@ -0,0 +1,100 @@
+package nats
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// NATS decentralized authentication signs the server's nonce with the user's NKey, an ed25519
+// key, and presents the user's JWT. Both come from the .creds file nsc and NGS issue. This file
+// decodes NKey seeds and encodes the public keys the server checks signatures with.
+
+// NKey prefix bytes
+const (
+	prefixSeed = 18 << 3
+	prefixUser = 20 << 3
+)
+
+// nkeyEncoding encodes NKeys, which carry no padding
+var nkeyEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// credsBlock matches the armored blocks of a .creds file, capturing their contents
+var credsBlock = regexp.MustCompile(`(?s)-{3,}[^\n]*BEGIN[^\n]*-{3,}\s*\n(.+?)\n\s*-{3,}[^\n]*END[^\n]*-{3,}`)
+
+// loadCredentials reads the user JWT and NKey seed of a .creds file
+func loadCredentials(path string) (string, []byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read credentials: %v", err)
+	}
+	blocks := credsBlock.FindAllSubmatch(data, -1)
+	if len(blocks) < 2 {
+		return "", nil, fmt.Errorf("no user JWT and NKey seed found in %s", path)
+	}
+	return string(bytes.TrimSpace(blocks[0][1])), bytes.TrimSpace(blocks[1][1]), nil
+}
+
+// signNonce signs nonce with the user seed, returning the user's public NKey and the
+// signature, in the forms CONNECT expects
+func signNonce(seed []byte, nonce string) (string, string, error) {
+	raw, err := decodeNKey(seed)
+	if err != nil {
+		return "", "", err
+	}
+	if raw[0]&0xf8 != prefixSeed || len(raw) != 2+ed25519.SeedSize {
+		return "", "", fmt.Errorf("the credentials hold no NKey seed")
+	}
+	if kind := (raw[0]&0x07)<<5 | (raw[1]&0xf8)>>3; kind != prefixUser {
+		return "", "", fmt.Errorf("the credentials hold no user NKey seed")
+	}
+
+	key := ed25519.NewKeyFromSeed(raw[2:])
+	public := encodeNKey(prefixUser, key.Public().(ed25519.PublicKey))
+	signature := ed25519.Sign(key, []byte(nonce))
+	return public, base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// decodeNKey decodes an encoded NKey and checks its checksum, returning its prefix and key bytes
+func decodeNKey(encoded []byte) ([]byte, error) {
+	raw := make([]byte, nkeyEncoding.DecodedLen(len(encoded)))
+	n, err := nkeyEncoding.Decode(raw, encoded)
+	if err != nil || n < 4 {
+		return nil, fmt.Errorf("invalid NKey")
+	}
+	raw = raw[:n]
+
+	body, checksum := raw[:n-2], binary.LittleEndian.Uint16(raw[n-2:])
+	if crc16(body) != checksum {
+		return nil, fmt.Errorf("invalid NKey checksum")
+	}
+	return body, nil
+}
+
+// encodeNKey encodes a key with its prefix byte and checksum
+func encodeNKey(prefix byte, key []byte) string {
+	raw := append([]byte{prefix}, key...)
+	raw = binary.LittleEndian.AppendUint16(raw, crc16(raw))
+	return nkeyEncoding.EncodeToString(raw)
+}
+
+// crc16 is the CRC-16/XMODEM checksum of NKeys
+func crc16(p []byte) uint16 {
+	var crc uint16
+	for _, b := range p {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
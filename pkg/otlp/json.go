@@ -0,0 +1,175 @@
+package otlp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// The OTLP/JSON encoding of an export request: the protobuf JSON mapping with lowerCamelCase
+// names, except that trace and span IDs are hex rather than base64. 64-bit integers may be
+// numbers or strings, which json.Number accepts both of.
+
+type jsonRequest struct {
+	ResourceLogs []struct {
+		Resource struct {
+			Attributes []jsonKeyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeLogs []struct {
+			Scope struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"scope"`
+			LogRecords []struct {
+				TimeUnixNano         json.Number    `json:"timeUnixNano"`
+				ObservedTimeUnixNano json.Number    `json:"observedTimeUnixNano"`
+				SeverityNumber       json.Number    `json:"severityNumber"`
+				SeverityText         string         `json:"severityText"`
+				Body                 *jsonAnyValue  `json:"body"`
+				Attributes           []jsonKeyValue `json:"attributes"`
+				TraceID              string         `json:"traceId"`
+				SpanID               string         `json:"spanId"`
+				EventName            string         `json:"eventName"`
+			} `json:"logRecords"`
+		} `json:"scopeLogs"`
+	} `json:"resourceLogs"`
+}
+
+type jsonKeyValue struct {
+	Key   string        `json:"key"`
+	Value *jsonAnyValue `json:"value"`
+}
+
+type jsonAnyValue struct {
+	StringValue *string      `json:"stringValue"`
+	BoolValue   *bool        `json:"boolValue"`
+	IntValue    *json.Number `json:"intValue"`
+	DoubleValue *json.Number `json:"doubleValue"`
+	ArrayValue  *struct {
+		Values []*jsonAnyValue `json:"values"`
+	} `json:"arrayValue"`
+	KvlistValue *struct {
+		Values []jsonKeyValue `json:"values"`
+	} `json:"kvlistValue"`
+	// BytesValue is base64, as the protobuf JSON mapping encodes bytes
+	BytesValue *string `json:"bytesValue"`
+}
+
+// decodeJSONRequest reads the records of an ExportLogsServiceRequest in OTLP/JSON
+func decodeJSONRequest(data []byte) ([]record, error) {
+	var request jsonRequest
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&request); err != nil {
+		return nil, fmt.Errorf("%w: %v", errMalformed, err)
+	}
+
+	var records []record
+	for _, resourceLogs := range request.ResourceLogs {
+		resource, err := jsonAttributes(resourceLogs.Resource.Attributes)
+		if err != nil {
+			return nil, err
+		}
+		for _, scopeLogs := range resourceLogs.ScopeLogs {
+			s := scope{name: scopeLogs.Scope.Name, version: scopeLogs.Scope.Version}
+			for _, logRecord := range scopeLogs.LogRecords {
+				r := record{
+					resource:     resource,
+					scope:        s,
+					severityText: logRecord.SeverityText,
+					eventName:    logRecord.EventName,
+				}
+				if r.timeUnixNano, err = jsonUint(logRecord.TimeUnixNano); err != nil {
+					return nil, err
+				}
+				if r.observedTimeUnixNano, err = jsonUint(logRecord.ObservedTimeUnixNano); err != nil {
+					return nil, err
+				}
+				severity, err := jsonUint(logRecord.SeverityNumber)
+				if err != nil {
+					return nil, err
+				}
+				r.severityNumber = int(severity)
+				if r.body, err = logRecord.Body.value(); err != nil {
+					return nil, err
+				}
+				if r.attributes, err = jsonAttributes(logRecord.Attributes); err != nil {
+					return nil, err
+				}
+				if r.traceID, err = hex.DecodeString(logRecord.TraceID); err != nil {
+					return nil, fmt.Errorf("%w: invalid traceId %q", errMalformed, logRecord.TraceID)
+				}
+				if r.spanID, err = hex.DecodeString(logRecord.SpanID); err != nil {
+					return nil, fmt.Errorf("%w: invalid spanId %q", errMalformed, logRecord.SpanID)
+				}
+				records = append(records, r)
+			}
+		}
+	}
+	return records, nil
+}
+
+func jsonAttributes(keyValues []jsonKeyValue) (map[string]interface{}, error) {
+	attributes := make(map[string]interface{}, len(keyValues))
+	for _, kv := range keyValues {
+		value, err := kv.Value.value()
+		if err != nil {
+			return nil, err
+		}
+		attributes[kv.Key] = value
+	}
+	return attributes, nil
+}
+
+// value returns the value v holds, as decodeAnyValue does for protobuf
+func (v *jsonAnyValue) value() (interface{}, error) {
+	switch {
+	case v == nil:
+		return nil, nil
+	case v.StringValue != nil:
+		return *v.StringValue, nil
+	case v.BoolValue != nil:
+		return *v.BoolValue, nil
+	case v.IntValue != nil:
+		i, err := strconv.ParseInt(v.IntValue.String(), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid intValue %q", errMalformed, v.IntValue.String())
+		}
+		return i, nil
+	case v.DoubleValue != nil:
+		f, err := strconv.ParseFloat(v.DoubleValue.String(), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid doubleValue %q", errMalformed, v.DoubleValue.String())
+		}
+		return f, nil
+	case v.ArrayValue != nil:
+		values := make([]interface{}, 0, len(v.ArrayValue.Values))
+		for _, element := range v.ArrayValue.Values {
+			value, err := element.value()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	case v.KvlistValue != nil:
+		return jsonAttributes(v.KvlistValue.Values)
+	case v.BytesValue != nil:
+		return *v.BytesValue, nil
+	}
+	return nil, nil
+}
+
+// jsonUint reads an unsigned 64-bit integer, 0 when absent
+func jsonUint(n json.Number) (uint64, error) {
+	if n == "" {
+		return 0, nil
+	}
+	value, err := strconv.ParseUint(n.String(), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid integer %q", errMalformed, n.String())
+	}
+	return value, nil
+}
@@ -0,0 +1,45 @@
+package otlp
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricsNamespace prefixes every metric exported by the server, as it does the client's
+const metricsNamespace = "fabric_logging"
+
+// metrics holds the Prometheus instruments of a Server
+type metrics struct {
+	records      *prometheus.CounterVec
+	authFailures prometheus.Counter
+}
+
+func newMetrics(registerer prometheus.Registerer) (*metrics, error) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	m := &metrics{
+		records: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "otlp",
+			Name:      "records_total",
+			Help:      "Log records received over OTLP, by result: submitted or rejected.",
+		}, []string{"result"}),
+		authFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "otlp",
+			Name:      "auth_failures_total",
+			Help:      "Exports without a valid bearer token.",
+		}),
+	}
+
+	for _, collector := range []prometheus.Collector{m.records, m.authFailures} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, fmt.Errorf("failed to register otlp metrics: %v", err)
+		}
+	}
+
+	return m, nil
+}
@@ -0,0 +1,280 @@
+package otlp
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The logs of an export request, as the messages of opentelemetry/proto/logs/v1/logs.proto and
+// opentelemetry/proto/common/v1/common.proto define them. The request is decoded field by
+// field, so the receiver needs no generated code; fields it does not read are skipped.
+
+// record is a LogRecord with the resource and scope that produced it
+type record struct {
+	resource map[string]interface{}
+	scope    scope
+
+	timeUnixNano         uint64
+	observedTimeUnixNano uint64
+	severityNumber       int
+	severityText         string
+	// body is the decoded AnyValue: a string, bool, int64, float64, []interface{} or
+	// map[string]interface{}, with bytes in base64, or nil when the record has none
+	body       interface{}
+	attributes map[string]interface{}
+	traceID    []byte
+	spanID     []byte
+	eventName  string
+}
+
+// scope is an InstrumentationScope
+type scope struct {
+	name    string
+	version string
+}
+
+// errMalformed marks requests that are not valid protobuf
+var errMalformed = errors.New("malformed OTLP request")
+
+// decodeRequest reads the records of an ExportLogsServiceRequest
+func decodeRequest(data []byte) ([]record, error) {
+	var records []record
+	err := fields(data, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+		if num == 1 && typ == protowire.BytesType {
+			return decodeResourceLogs(value, &records)
+		}
+		return nil
+	})
+	return records, err
+}
+
+// decodeResourceLogs appends the records of a ResourceLogs
+func decodeResourceLogs(data []byte, records *[]record) error {
+	resource := map[string]interface{}{}
+	var scopeLogs [][]byte
+	err := fields(data, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			// Resource: attributes = 1
+			return fields(value, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+				if num == 1 && typ == protowire.BytesType {
+					return decodeKeyValue(value, resource)
+				}
+				return nil
+			})
+		case num == 2 && typ == protowire.BytesType:
+			// The resource may follow its scopes on the wire, so they are read once it is known
+			scopeLogs = append(scopeLogs, value)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, data := range scopeLogs {
+		if err := decodeScopeLogs(data, resource, records); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeScopeLogs appends the records of a ScopeLogs
+func decodeScopeLogs(data []byte, resource map[string]interface{}, records *[]record) error {
+	var s scope
+	var logRecords [][]byte
+	err := fields(data, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			// InstrumentationScope: name = 1, version = 2
+			return fields(value, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+				switch {
+				case num == 1 && typ == protowire.BytesType:
+					s.name = string(value)
+				case num == 2 && typ == protowire.BytesType:
+					s.version = string(value)
+				}
+				return nil
+			})
+		case num == 2 && typ == protowire.BytesType:
+			logRecords = append(logRecords, value)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, data := range logRecords {
+		r := record{resource: resource, scope: s, attributes: map[string]interface{}{}}
+		if err := decodeLogRecord(data, &r); err != nil {
+			return err
+		}
+		*records = append(*records, r)
+	}
+	return nil
+}
+
+// decodeLogRecord reads a LogRecord into r
+func decodeLogRecord(data []byte, r *record) error {
+	return fields(data, func(num protowire.Number, typ protowire.Type, value []byte, scalar uint64) error {
+		switch {
+		case num == 1 && typ == protowire.Fixed64Type:
+			r.timeUnixNano = scalar
+		case num == 2 && typ == protowire.VarintType:
+			r.severityNumber = int(int32(scalar))
+		case num == 3 && typ == protowire.BytesType:
+			r.severityText = string(value)
+		case num == 5 && typ == protowire.BytesType:
+			body, err := decodeAnyValue(value)
+			if err != nil {
+				return err
+			}
+			r.body = body
+		case num == 6 && typ == protowire.BytesType:
+			return decodeKeyValue(value, r.attributes)
+		case num == 9 && typ == protowire.BytesType:
+			r.traceID = append([]byte(nil), value...)
+		case num == 10 && typ == protowire.BytesType:
+			r.spanID = append([]byte(nil), value...)
+		case num == 11 && typ == protowire.Fixed64Type:
+			r.observedTimeUnixNano = scalar
+		case num == 12 && typ == protowire.BytesType:
+			r.eventName = string(value)
+		}
+		return nil
+	})
+}
+
+// decodeKeyValue adds the key and value of a KeyValue to attributes
+func decodeKeyValue(data []byte, attributes map[string]interface{}) error {
+	var key string
+	var value interface{}
+	err := fields(data, func(num protowire.Number, typ protowire.Type, field []byte, _ uint64) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			key = string(field)
+		case num == 2 && typ == protowire.BytesType:
+			var err error
+			value, err = decodeAnyValue(field)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	attributes[key] = value
+	return nil
+}
+
+// decodeAnyValue reads the value an AnyValue holds
+func decodeAnyValue(data []byte) (interface{}, error) {
+	var value interface{}
+	err := fields(data, func(num protowire.Number, typ protowire.Type, field []byte, scalar uint64) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			value = string(field)
+		case num == 2 && typ == protowire.VarintType:
+			value = scalar != 0
+		case num == 3 && typ == protowire.VarintType:
+			value = int64(scalar)
+		case num == 4 && typ == protowire.Fixed64Type:
+			value = math.Float64frombits(scalar)
+		case num == 5 && typ == protowire.BytesType:
+			// ArrayValue: values = 1
+			values := []interface{}{}
+			err := fields(field, func(num protowire.Number, typ protowire.Type, field []byte, _ uint64) error {
+				if num == 1 && typ == protowire.BytesType {
+					v, err := decodeAnyValue(field)
+					values = append(values, v)
+					return err
+				}
+				return nil
+			})
+			value = values
+			return err
+		case num == 6 && typ == protowire.BytesType:
+			// KeyValueList: values = 1
+			values := map[string]interface{}{}
+			err := fields(field, func(num protowire.Number, typ protowire.Type, field []byte, _ uint64) error {
+				if num == 1 && typ == protowire.BytesType {
+					return decodeKeyValue(field, values)
+				}
+				return nil
+			})
+			value = values
+			return err
+		case num == 7 && typ == protowire.BytesType:
+			value = base64.StdEncoding.EncodeToString(field)
+		}
+		return nil
+	})
+	return value, err
+}
+
+// fields calls fn with each field of a message: its bytes for the length-delimited types and
+// its value for the scalar ones
+func fields(data []byte, fn func(num protowire.Number, typ protowire.Type, value []byte, scalar uint64) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return fmt.Errorf("%w: %v", errMalformed, protowire.ParseError(n))
+		}
+		data = data[n:]
+
+		var value []byte
+		var scalar uint64
+		switch typ {
+		case protowire.VarintType:
+			scalar, n = protowire.ConsumeVarint(data)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(data)
+			scalar = uint64(v)
+		case protowire.Fixed64Type:
+			scalar, n = protowire.ConsumeFixed64(data)
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(data)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return fmt.Errorf("%w: %v", errMalformed, protowire.ParseError(n))
+		}
+		data = data[n:]
+		if err := fn(num, typ, value, scalar); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeResponse returns an ExportLogsServiceResponse, with partial_success set when records
+// were rejected
+func encodeResponse(rejected int64, message string) []byte {
+	if rejected == 0 {
+		return []byte{}
+	}
+	var partial []byte
+	partial = protowire.AppendTag(partial, 1, protowire.VarintType)
+	partial = protowire.AppendVarint(partial, uint64(rejected))
+	partial = protowire.AppendTag(partial, 2, protowire.BytesType)
+	partial = protowire.AppendString(partial, message)
+
+	var response []byte
+	response = protowire.AppendTag(response, 1, protowire.BytesType)
+	return protowire.AppendBytes(response, partial)
+}
+
+// encodeStatus returns a google.rpc.Status, the body of OTLP/HTTP errors
+func encodeStatus(code int, message string) []byte {
+	var status []byte
+	status = protowire.AppendTag(status, 1, protowire.VarintType)
+	status = protowire.AppendVarint(status, uint64(code))
+	status = protowire.AppendTag(status, 2, protowire.BytesType)
+	return protowire.AppendString(status, message)
+}
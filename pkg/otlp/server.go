@@ -0,0 +1,505 @@
+// Package otlp receives logs over the OpenTelemetry protocol (OTLP) and records them on the
+// ledger, so any OpenTelemetry SDK or Collector sends its logs here by pointing its OTLP logs
+// exporter at the receiver. The logs service is served over gRPC and over HTTP at /v1/logs, in
+// protobuf and JSON, with gzip compression. Each LogRecord becomes a log with its severity, body,
+// attributes, trace and span IDs and the attributes of the resource that emitted it.
+package otlp
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	_ "google.golang.org/grpc/encoding/gzip" // OTLP exporters compress with gzip by default
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultMaxMessageSize is the largest export request read by default, after decompression, as
+// gRPC limits messages by default
+const DefaultMaxMessageSize = 4 << 20
+
+// LogsPath is the path of the logs service over HTTP
+const LogsPath = "/v1/logs"
+
+// backpressureWait is how long a request waits before retrying a record the submitter pushed back
+const backpressureWait = 100 * time.Millisecond
+
+// Attribute keys read for the fields of a log. The first user key found is the user, and the
+// service.name resource attribute the resource of records without a resource attribute.
+const (
+	// RecordUIDKey is the semantic convention for a log record's unique ID, used as the log ID
+	RecordUIDKey   = "log.record.uid"
+	ServiceNameKey = "service.name"
+)
+
+// userKeys are the attributes read for the user, in order
+var userKeys = []string{client.SlogUserIDKey, "enduser.id"}
+
+// severityLevels places the ranges of OTLP severity numbers, from TRACE at 1 to FATAL at 21, on
+// the slog scale
+var severityLevels = []struct {
+	number int
+	level  slog.Level
+}{
+	{21, slog.LevelError + 4},
+	{17, slog.LevelError},
+	{13, slog.LevelWarn},
+	{9, slog.LevelInfo},
+	{5, slog.LevelDebug},
+	{1, slog.LevelDebug - 4},
+}
+
+// Options configures a Server
+type Options struct {
+	// Submitter receives the logs, usually a BatchingSubmitter
+	Submitter client.Submitter
+	// Flush submits every log handed to Submitter, such as BatchingSubmitter.Flush. An export is
+	// only answered with success once flushed, so the exporter retries its logs until they are
+	// committed; without Flush it is answered once its logs are handed over.
+	Flush func(ctx context.Context) error
+	// Tokens maps each bearer token accepted to its name, sent by exporters as the authorization
+	// header; every caller is accepted when empty
+	Tokens map[string]string
+	// UserID is used for records without a userId or enduser.id attribute; the service.name of
+	// their resource, or the name of their token, is used when empty
+	UserID string
+	// Action is used for records without an action attribute or event name; LOG_<LEVEL> is
+	// recorded otherwise
+	Action string
+	// MaxMessageSize is the largest export request read, after decompression;
+	// DefaultMaxMessageSize is used when zero
+	MaxMessageSize int
+	// Registerer receives the fabric_logging_otlp_* metrics; prometheus.DefaultRegisterer is used when nil
+	Registerer prometheus.Registerer
+	// OnError is called with the records the Submitter rejected
+	OnError func(error)
+}
+
+// Server serves the OTLP logs service over gRPC and HTTP
+type Server struct {
+	options Options
+	handler *client.SlogHandler
+	metrics *metrics
+}
+
+// exportError is a failed export, with the gRPC code it is answered with
+type exportError struct {
+	code codes.Code
+	err  error
+}
+
+func (e *exportError) Error() string {
+	return e.err.Error()
+}
+
+// NewServer returns a Server submitting through options.Submitter
+func NewServer(options Options) (*Server, error) {
+	if options.Submitter == nil {
+		return nil, fmt.Errorf("no submitter configured")
+	}
+	if options.MaxMessageSize <= 0 {
+		options.MaxMessageSize = DefaultMaxMessageSize
+	}
+
+	m, err := newMetrics(options.Registerer)
+	if err != nil {
+		return nil, err
+	}
+	handler := client.NewSlogHandler(options.Submitter, client.SlogHandlerOptions{
+		Level: slog.LevelDebug - 4,
+	})
+	return &Server{options: options, handler: handler, metrics: m}, nil
+}
+
+// NewGRPCServer returns a grpc.Server serving the logs service, with the server options given.
+// It reads requests without generated code, so it serves no other service.
+func (s *Server) NewGRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append([]grpc.ServerOption{
+		grpc.ForceServerCodec(rawCodec{}),
+		grpc.MaxRecvMsgSize(s.options.MaxMessageSize),
+	}, opts...)
+	server := grpc.NewServer(opts...)
+	server.RegisterService(&logsServiceDesc, s)
+	return server
+}
+
+// logsService is implemented by the Server registered with logsServiceDesc
+type logsService interface {
+	exportGRPC(ctx context.Context, request []byte) ([]byte, error)
+}
+
+// logsServiceDesc describes opentelemetry.proto.collector.logs.v1.LogsService
+var logsServiceDesc = grpc.ServiceDesc{
+	ServiceName: "opentelemetry.proto.collector.logs.v1.LogsService",
+	HandlerType: (*logsService)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Export",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			var request []byte
+			if err := dec(&request); err != nil {
+				return nil, err
+			}
+			handle := func(ctx context.Context, request interface{}) (interface{}, error) {
+				return srv.(logsService).exportGRPC(ctx, request.([]byte))
+			}
+			if interceptor == nil {
+				return handle(ctx, request)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/opentelemetry.proto.collector.logs.v1.LogsService/Export"}
+			return interceptor(ctx, request, info, handle)
+		},
+	}},
+	Metadata: "opentelemetry/proto/collector/logs/v1/logs_service.proto",
+}
+
+// rawCodec hands messages over as their encoded bytes, which the server decodes itself
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	data, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("cannot marshal %T", v)
+	}
+	return data, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	p, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("cannot unmarshal into %T", v)
+	}
+	*p = append([]byte(nil), data...)
+	return nil
+}
+
+// Name is the codec of the content subtype exporters send, so the codec replaces the proto one
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+func (s *Server) exportGRPC(ctx context.Context, request []byte) ([]byte, error) {
+	var authorization string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			authorization = values[0]
+		}
+	}
+	name, ok := s.authenticate(authorization)
+	if !ok {
+		s.metrics.authFailures.Inc()
+		return nil, status.Error(codes.Unauthenticated, "invalid or missing bearer token")
+	}
+
+	records, err := decodeRequest(request)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	rejected, err := s.export(ctx, name, records)
+	if err != nil {
+		var failed *exportError
+		if errors.As(err, &failed) {
+			return nil, status.Error(failed.code, failed.err.Error())
+		}
+		return nil, status.FromContextError(err).Err()
+	}
+	return encodeResponse(rejected, rejectedMessage(rejected)), nil
+}
+
+// ServeHTTP serves the logs service at LogsPath, in protobuf or JSON as the request's content
+// type gives it
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	asJSON := mediaType == "application/json"
+	switch {
+	case r.URL.Path != LogsPath:
+		writeStatus(w, asJSON, http.StatusNotFound, codes.NotFound, "not found")
+		return
+	case r.Method != http.MethodPost:
+		w.Header().Set("Allow", http.MethodPost)
+		writeStatus(w, asJSON, http.StatusMethodNotAllowed, codes.Unimplemented, "method not allowed")
+		return
+	case !asJSON && mediaType != "application/x-protobuf":
+		writeStatus(w, asJSON, http.StatusUnsupportedMediaType, codes.InvalidArgument, "content type must be application/x-protobuf or application/json")
+		return
+	}
+
+	name, ok := s.authenticate(r.Header.Get("Authorization"))
+	if !ok {
+		s.metrics.authFailures.Inc()
+		writeStatus(w, asJSON, http.StatusUnauthorized, codes.Unauthenticated, "invalid or missing bearer token")
+		return
+	}
+
+	body, err := s.body(w, r)
+	if err != nil {
+		writeStatus(w, asJSON, http.StatusBadRequest, codes.InvalidArgument, err.Error())
+		return
+	}
+	var records []record
+	if asJSON {
+		records, err = decodeJSONRequest(body)
+	} else {
+		records, err = decodeRequest(body)
+	}
+	if err != nil {
+		writeStatus(w, asJSON, http.StatusBadRequest, codes.InvalidArgument, err.Error())
+		return
+	}
+
+	rejected, err := s.export(r.Context(), name, records)
+	if err != nil {
+		// Exporters retry the requests answered unavailable
+		writeStatus(w, asJSON, http.StatusServiceUnavailable, codes.Unavailable, err.Error())
+		return
+	}
+	if asJSON {
+		response := map[string]interface{}{}
+		if rejected > 0 {
+			response["partialSuccess"] = map[string]interface{}{
+				"rejectedLogRecords": fmt.Sprint(rejected),
+				"errorMessage":       rejectedMessage(rejected),
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Write(encodeResponse(rejected, rejectedMessage(rejected)))
+}
+
+// authenticate returns the name of the bearer token of an authorization header, or reports
+// that it is not accepted; every caller is accepted without tokens
+func (s *Server) authenticate(authorization string) (string, bool) {
+	if len(s.options.Tokens) == 0 {
+		return "", true
+	}
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok {
+		return "", false
+	}
+	for candidate, name := range s.options.Tokens {
+		if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(candidate)) == 1 {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// body reads the request body, decompressing it when it is gzipped, up to the size limit
+func (s *Server) body(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	limit := int64(s.options.MaxMessageSize)
+	var reader io.Reader = http.MaxBytesReader(w, r.Body, limit)
+	if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	body, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("the request exceeds %d bytes", limit)
+	}
+	return body, nil
+}
+
+// export submits records, then flushes them, returning how many the submitter rejected
+func (s *Server) export(ctx context.Context, name string, records []record) (int64, error) {
+	var rejected int64
+	for _, r := range records {
+		ok, err := s.submit(ctx, s.record(name, r))
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			rejected++
+		}
+	}
+	if s.options.Flush != nil {
+		if err := s.options.Flush(ctx); err != nil {
+			s.report(fmt.Errorf("failed to flush the logs of an OTLP export: %w", err))
+			return 0, &exportError{code: codes.Unavailable, err: fmt.Errorf("failed to commit logs: %v", err)}
+		}
+	}
+	return rejected, nil
+}
+
+// submit hands the log of a record to the submitter, waiting while it pushes back, and reports
+// whether it was accepted. Records the submitter rejects are reported and skipped, as sending
+// them again would not help.
+func (s *Server) submit(ctx context.Context, r slog.Record) (bool, error) {
+	for {
+		err := s.handler.Handle(ctx, r)
+		switch {
+		case err == nil:
+			s.metrics.records.WithLabelValues("submitted").Inc()
+			return true, nil
+		case errors.Is(err, client.ErrBackpressure):
+			select {
+			case <-ctx.Done():
+				return false, ctx.Err()
+			case <-time.After(backpressureWait):
+			}
+		default:
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
+			s.metrics.records.WithLabelValues("rejected").Inc()
+			s.report(fmt.Errorf("failed to submit an OTLP log record %q: %w", r.Message, err))
+			return false, nil
+		}
+	}
+}
+
+// record builds the slog record of an OTLP log record
+func (s *Server) record(name string, r record) slog.Record {
+	fields := map[string]interface{}{}
+	for key, value := range r.attributes {
+		fields[key] = value
+	}
+	if id, ok := fields[RecordUIDKey]; ok {
+		fields[client.SlogIDKey] = fmt.Sprint(id)
+		delete(fields, RecordUIDKey)
+	}
+	for _, key := range []string{client.SlogIDKey, client.SlogUserIDKey, client.SlogActionKey, client.SlogResourceKey} {
+		if value, ok := fields[key]; ok {
+			fields[key] = fmt.Sprint(value)
+		}
+	}
+
+	service, _ := r.resource[ServiceNameKey].(string)
+	user := ""
+	for _, key := range userKeys {
+		if value, ok := fields[key]; ok {
+			user = fmt.Sprint(value)
+			delete(fields, key)
+			break
+		}
+	}
+	for _, fallback := range []string{s.options.UserID, service, name, "otlp"} {
+		if user == "" {
+			user = fallback
+		}
+	}
+	fields[client.SlogUserIDKey] = user
+	if _, ok := fields[client.SlogActionKey]; !ok {
+		if r.eventName != "" {
+			fields[client.SlogActionKey] = r.eventName
+		} else if s.options.Action != "" {
+			fields[client.SlogActionKey] = s.options.Action
+		}
+	}
+	if _, ok := fields[client.SlogResourceKey]; !ok {
+		for _, resource := range []string{service, r.scope.name, "otlp"} {
+			if resource != "" {
+				fields[client.SlogResourceKey] = resource
+				break
+			}
+		}
+	}
+
+	if len(r.traceID) > 0 {
+		fields["trace_id"] = hex.EncodeToString(r.traceID)
+	}
+	if len(r.spanID) > 0 {
+		fields["span_id"] = hex.EncodeToString(r.spanID)
+	}
+	if r.eventName != "" {
+		fields["event_name"] = r.eventName
+	}
+	if len(r.resource) > 0 {
+		fields["otel.resource"] = r.resource
+	}
+	if r.scope.name != "" {
+		fields["otel.scope"] = map[string]interface{}{"name": r.scope.name, "version": r.scope.version}
+	}
+
+	message := ""
+	switch body := r.body.(type) {
+	case nil:
+	case string:
+		message = strings.TrimRight(body, "\r\n")
+	default:
+		data, _ := json.Marshal(body)
+		message = string(data)
+	}
+
+	t := time.Now()
+	if r.timeUnixNano != 0 {
+		t = time.Unix(0, int64(r.timeUnixNano))
+	} else if r.observedTimeUnixNano != 0 {
+		t = time.Unix(0, int64(r.observedTimeUnixNano))
+	}
+
+	record := slog.NewRecord(t, severity(r), client.Truncate(message, client.MaxDescriptionLength), 0)
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		record.AddAttrs(slog.Any(key, fields[key]))
+	}
+	return record
+}
+
+// severity returns the level of a record, by its severity text when it names one and by its
+// severity number otherwise, and INFO when it has neither
+func severity(r record) slog.Level {
+	if level, ok := client.ParseSeverity(r.severityText); ok && r.severityText != "" {
+		return level
+	}
+	for _, s := range severityLevels {
+		if r.severityNumber >= s.number {
+			return s.level
+		}
+	}
+	return slog.LevelInfo
+}
+
+func (s *Server) report(err error) {
+	if s.options.OnError != nil {
+		s.options.OnError(err)
+	}
+}
+
+func rejectedMessage(rejected int64) string {
+	if rejected == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d log records are not valid logs", rejected)
+}
+
+// writeStatus answers an HTTP request with a google.rpc.Status, in the encoding of the request
+func writeStatus(w http.ResponseWriter, asJSON bool, httpStatus int, code codes.Code, message string) {
+	if asJSON {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(httpStatus)
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": int(code), "message": message})
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(httpStatus)
+	w.Write(encodeStatus(int(code), message))
+}
@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// migrations create and evolve the mirror's schema. Each runs once, in its own transaction,
+// in order; append new migrations rather than editing applied ones.
+var migrations = []string{
+	`CREATE TABLE fabric_logs (
+		id             TEXT PRIMARY KEY,
+		user_id        TEXT NOT NULL,
+		action         TEXT NOT NULL,
+		resource       TEXT NOT NULL,
+		logged_at      TIMESTAMPTZ,
+		raw_timestamp  TEXT NOT NULL,
+		description    TEXT NOT NULL,
+		metadata       JSONB,
+		collection     TEXT,
+		block_number   BIGINT NOT NULL,
+		transaction_id TEXT NOT NULL
+	);
+	CREATE INDEX fabric_logs_user_id_idx ON fabric_logs (user_id);
+	CREATE INDEX fabric_logs_action_idx ON fabric_logs (action);
+	CREATE INDEX fabric_logs_logged_at_idx ON fabric_logs (logged_at);
+	CREATE TABLE fabric_log_checkpoints (
+		consumer       TEXT PRIMARY KEY,
+		block_number   BIGINT NOT NULL,
+		transaction_id TEXT NOT NULL,
+		updated_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`,
+}
+
+// Migrate brings the schema up to date, recording applied migrations in fabric_log_migrations.
+// A transaction-level advisory lock keeps concurrent mirrors from migrating at the same time.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS fabric_log_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return fmt.Errorf("failed to create migrations table: %v", err)
+	}
+
+	for i, migration := range migrations {
+		version := i + 1
+		if err := migrate(ctx, db, version, migration); err != nil {
+			return fmt.Errorf("failed to apply migration %d: %v", version, err)
+		}
+	}
+
+	return nil
+}
+
+func migrate(ctx context.Context, db *sql.DB, version int, migration string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('fabric_log_migrations'))`); err != nil {
+		return err
+	}
+
+	var applied bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM fabric_log_migrations WHERE version = $1)`, version).Scan(&applied); err != nil {
+		return err
+	}
+	if applied {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, migration); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO fabric_log_migrations (version) VALUES ($1)`, version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,175 @@
+// Package postgres mirrors the logs on the ledger into PostgreSQL tables, for SQL reporting
+// and joins against warehouse data. It works with any database/sql driver for PostgreSQL,
+// such as github.com/jackc/pgx/v5/stdlib.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// DefaultConsumer is the conventional checkpoint name of a Postgres mirror
+const DefaultConsumer = "postgres-mirror"
+
+// Sink is a projection.Sink applying logs to the fabric_logs table. Logs are upserted by ID,
+// one database transaction per batch, so a batch replayed after a restart rewrites the same
+// rows. Keep the Projector's checkpoint in the same database with Checkpointer:
+//
+//	sink, err := postgres.NewSink(ctx, db)
+//	projector := projection.New(projection.NewEventSource(c, &fromBlock), sink, projection.Options{
+//		Checkpointer: postgres.Checkpointer(db, postgres.DefaultConsumer),
+//	})
+//	err = projector.Run(ctx)
+type Sink struct {
+	db *sql.DB
+}
+
+// NewSink migrates the schema in db and returns a Sink writing to it
+func NewSink(ctx context.Context, db *sql.DB) (*Sink, error) {
+	if err := Migrate(ctx, db); err != nil {
+		return nil, err
+	}
+	return &Sink{db: db}, nil
+}
+
+// Write upserts the logs carried by events, and deletes the pruned ones, in a single transaction
+func (s *Sink) Write(ctx context.Context, events []client.ContractEvent) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin mirror transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, event := range events {
+		apply := upsert
+		if event.Pruned() {
+			apply = remove
+		}
+		if err := apply(ctx, tx, event); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit mirror transaction: %v", err)
+	}
+
+	return nil
+}
+
+// upsert writes the log carried by event
+func upsert(ctx context.Context, tx *sql.Tx, event client.ContractEvent) error {
+	log := event.Log
+
+	var loggedAt sql.NullTime
+	if t, err := time.Parse(time.RFC3339Nano, log.Timestamp); err == nil {
+		loggedAt = sql.NullTime{Time: t, Valid: true}
+	}
+
+	// Metadata is a JSON object for every log the chaincode validates; anything else is kept as a JSON string
+	var metadata sql.NullString
+	if log.Metadata != "" {
+		metadata = sql.NullString{String: log.Metadata, Valid: true}
+		if !json.Valid([]byte(log.Metadata)) {
+			quoted, err := json.Marshal(log.Metadata)
+			if err != nil {
+				return err
+			}
+			metadata.String = string(quoted)
+		}
+	}
+
+	var collection sql.NullString
+	if log.Collection != "" {
+		collection = sql.NullString{String: log.Collection, Valid: true}
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO fabric_logs (id, user_id, action, resource, logged_at, raw_timestamp, description, metadata, collection, block_number, transaction_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (id) DO UPDATE SET
+			user_id = EXCLUDED.user_id,
+			action = EXCLUDED.action,
+			resource = EXCLUDED.resource,
+			logged_at = EXCLUDED.logged_at,
+			raw_timestamp = EXCLUDED.raw_timestamp,
+			description = EXCLUDED.description,
+			metadata = EXCLUDED.metadata,
+			collection = EXCLUDED.collection,
+			block_number = EXCLUDED.block_number,
+			transaction_id = EXCLUDED.transaction_id`,
+		log.ID, log.UserID, log.Action, log.Resource, loggedAt, log.Timestamp, log.Description, metadata, collection,
+		int64(event.BlockNumber), event.TransactionID)
+	if err != nil {
+		return fmt.Errorf("failed to mirror log %s: %v", log.ID, err)
+	}
+
+	return nil
+}
+
+// remove deletes the log pruned by event
+func remove(ctx context.Context, tx *sql.Tx, event client.ContractEvent) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM fabric_logs WHERE id = $1`, event.Log.ID); err != nil {
+		return fmt.Errorf("failed to delete pruned log %s: %v", event.Log.ID, err)
+	}
+
+	return nil
+}
+
+// Checkpointer returns a Checkpointer stored in the fabric_log_checkpoints table under consumer,
+// so several projections can share a database. The table is created by Migrate.
+func Checkpointer(db *sql.DB, consumer string) client.Checkpointer {
+	return &checkpointer{db: db, consumer: consumer}
+}
+
+type checkpointer struct {
+	db       *sql.DB
+	consumer string
+}
+
+// checkpointTimeout bounds each checkpoint query, since Checkpointer methods take no context
+const checkpointTimeout = 5 * time.Second
+
+func (c *checkpointer) Load() (*client.Checkpoint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), checkpointTimeout)
+	defer cancel()
+
+	var blockNumber int64
+	var checkpoint client.Checkpoint
+	err := c.db.QueryRowContext(ctx, `SELECT block_number, transaction_id FROM fabric_log_checkpoints WHERE consumer = $1`, c.consumer).
+		Scan(&blockNumber, &checkpoint.TransactionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %v", err)
+	}
+	checkpoint.BlockNumber = uint64(blockNumber)
+
+	return &checkpoint, nil
+}
+
+func (c *checkpointer) Save(checkpoint client.Checkpoint) error {
+	ctx, cancel := context.WithTimeout(context.Background(), checkpointTimeout)
+	defer cancel()
+
+	_, err := c.db.ExecContext(ctx, `
+		INSERT INTO fabric_log_checkpoints (consumer, block_number, transaction_id, updated_at)
+		VALUES ($1, $2, $3, now())
+		ON CONFLICT (consumer) DO UPDATE SET
+			block_number = EXCLUDED.block_number,
+			transaction_id = EXCLUDED.transaction_id,
+			updated_at = EXCLUDED.updated_at`,
+		c.consumer, int64(checkpoint.BlockNumber), checkpoint.TransactionID)
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package projection
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// FileSink appends each log as a JSON line to a file, with its block number and transaction ID.
+// A pruned log is appended as a line with its ID and "pruned": true. Appending is not
+// idempotent: events replayed after a restart are written again, and readers should keep the
+// last line for each log ID.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// fileRecord is one line written by a FileSink
+type fileRecord struct {
+	client.LogEvent
+	BlockNumber   uint64 `json:"blockNumber"`
+	TransactionID string `json:"transactionId"`
+	Pruned        bool   `json:"pruned,omitempty"`
+}
+
+// NewFileSink opens or creates the file at path for appending
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open projection file: %v", err)
+	}
+
+	return &FileSink{file: file}, nil
+}
+
+// Write appends events and syncs the file
+func (s *FileSink) Write(ctx context.Context, events []client.ContractEvent) error {
+	var lines []byte
+	for _, event := range events {
+		line, err := json.Marshal(fileRecord{LogEvent: event.Log, BlockNumber: event.BlockNumber, TransactionID: event.TransactionID, Pruned: event.Pruned()})
+		if err != nil {
+			return err
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.file.Write(lines); err != nil {
+		return fmt.Errorf("failed to write projection file: %v", err)
+	}
+	return s.file.Sync()
+}
+
+// Close closes the file
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
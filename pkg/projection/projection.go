@@ -0,0 +1,193 @@
+// Package projection keeps downstream systems in step with the logs on the ledger. A Projector
+// reads committed transactions from a BlockSource, in order, and writes them to a Sink in
+// batches, retrying failed writes and checkpointing after each one, so a Sink only has to
+// apply a batch of logs idempotently.
+package projection
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// Projector defaults
+const (
+	DefaultBatchSize     = 500
+	DefaultFlushInterval = time.Second
+)
+
+// Transaction is the logs written, or pruned, by one committed transaction
+type Transaction struct {
+	BlockNumber   uint64
+	TransactionID string
+	Events        []client.ContractEvent
+}
+
+// BlockSource delivers committed transactions in ledger order
+type BlockSource interface {
+	// Stream calls deliver with each transaction after checkpoint, or from the source's start
+	// position when checkpoint is nil, until ctx is done or deliver fails
+	Stream(ctx context.Context, checkpoint *client.Checkpoint, deliver func(Transaction) error) error
+}
+
+// Sink applies logs to a downstream system
+type Sink interface {
+	// Write applies events in ledger order. Events since the last checkpoint are written again
+	// after a failure or restart, so Write must be idempotent, typically by keying on the log ID.
+	// An event whose Pruned method returns true deletes its log: a Sink keeping a copy of the
+	// logs deletes it too, and one forwarding logs elsewhere skips the event.
+	Write(ctx context.Context, events []client.ContractEvent) error
+}
+
+// Options configures a Projector
+type Options struct {
+	// BatchSize is the most events passed to one Sink.Write; DefaultBatchSize is used otherwise.
+	// A transaction is never split, so a batch may exceed it by the size of one transaction.
+	BatchSize int
+	// FlushInterval is the longest events wait to be written; DefaultFlushInterval is used otherwise
+	FlushInterval time.Duration
+	// Retry controls how failed writes are retried; client.DefaultRetryPolicy is used when zero
+	Retry client.RetryPolicy
+	// Checkpointer records the last written transaction. Without one, a restarted Projector
+	// starts again from the source's start position.
+	Checkpointer client.Checkpointer
+}
+
+// Projector copies the transactions of a BlockSource into a Sink
+type Projector struct {
+	source  BlockSource
+	sink    Sink
+	options Options
+
+	mu         sync.Mutex
+	buffer     []client.ContractEvent
+	checkpoint *client.Checkpoint
+	firstAt    time.Time
+}
+
+// New returns a Projector from source to sink
+func New(source BlockSource, sink Sink, options Options) *Projector {
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultBatchSize
+	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = DefaultFlushInterval
+	}
+	if options.Retry.MaxAttempts == 0 {
+		options.Retry = client.DefaultRetryPolicy
+	}
+
+	return &Projector{source: source, sink: sink, options: options}
+}
+
+// Run projects transactions until ctx is done or a write fails after every retry. Events still
+// buffered when ctx is done are not written; they are replayed from the checkpoint next time.
+func (p *Projector) Run(ctx context.Context) error {
+	var checkpoint *client.Checkpoint
+	if p.options.Checkpointer != nil {
+		var err error
+		if checkpoint, err = p.options.Checkpointer.Load(); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Flush partial batches in the background; a failure stops the source
+	var flushErr error
+	var flusher sync.WaitGroup
+	flusher.Add(1)
+	go func() {
+		defer flusher.Done()
+		ticker := time.NewTicker(p.options.FlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := p.flush(ctx, false); err != nil {
+					flushErr = err
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	err := p.source.Stream(ctx, checkpoint, func(tx Transaction) error {
+		return p.add(ctx, tx)
+	})
+	cancel()
+	flusher.Wait()
+
+	if flushErr != nil {
+		return flushErr
+	}
+	return err
+}
+
+// add buffers a transaction, writing the buffer once it holds a full batch
+func (p *Projector) add(ctx context.Context, tx Transaction) error {
+	if len(tx.Events) == 0 {
+		return nil
+	}
+
+	p.mu.Lock()
+	if len(p.buffer) == 0 {
+		p.firstAt = time.Now()
+	}
+	p.buffer = append(p.buffer, tx.Events...)
+	p.checkpoint = &client.Checkpoint{BlockNumber: tx.BlockNumber, TransactionID: tx.TransactionID}
+	full := len(p.buffer) >= p.options.BatchSize
+	p.mu.Unlock()
+
+	if full {
+		return p.flush(ctx, true)
+	}
+	return nil
+}
+
+// flush writes the buffered events and saves the checkpoint of the last buffered transaction.
+// Unless force is set, a buffer younger than the flush interval is left to fill further.
+func (p *Projector) flush(ctx context.Context, force bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.buffer) == 0 || (!force && time.Since(p.firstAt) < p.options.FlushInterval) {
+		return nil
+	}
+
+	if err := p.write(ctx, p.buffer); err != nil {
+		return err
+	}
+	if p.options.Checkpointer != nil {
+		if err := p.options.Checkpointer.Save(*p.checkpoint); err != nil {
+			return err
+		}
+	}
+	p.buffer = nil
+
+	return nil
+}
+
+// write passes events to the sink, retrying failures with backoff until ctx is done
+func (p *Projector) write(ctx context.Context, events []client.ContractEvent) error {
+	for attempt := 1; ; attempt++ {
+		err := p.sink.Write(ctx, events)
+		if err == nil || attempt >= p.options.Retry.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+
+		timer := time.NewTimer(p.options.Retry.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package projection
+
+import (
+	"context"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// eventSource is a BlockSource reading the logging chaincode's events through a client
+type eventSource struct {
+	client    *client.Client
+	fromBlock *uint64
+}
+
+// NewEventSource returns a BlockSource reading chaincode events through c. Without a
+// checkpoint it starts at fromBlock, or with the next commit when fromBlock is nil.
+func NewEventSource(c *client.Client, fromBlock *uint64) BlockSource {
+	return &eventSource{client: c, fromBlock: fromBlock}
+}
+
+func (s *eventSource) Stream(ctx context.Context, checkpoint *client.Checkpoint, deliver func(Transaction) error) error {
+	opts := []client.ListenOption{client.IncludePruned()}
+	if checkpoint != nil {
+		opts = append(opts, client.FromBlock(checkpoint.BlockNumber), client.AfterTransaction(checkpoint.TransactionID))
+	} else if s.fromBlock != nil {
+		opts = append(opts, client.FromBlock(*s.fromBlock))
+	}
+
+	return s.client.NewTransactionListener(func(events []client.ContractEvent) error {
+		if len(events) == 0 {
+			return nil
+		}
+		return deliver(Transaction{
+			BlockNumber:   events[0].BlockNumber,
+			TransactionID: events[0].TransactionID,
+			Events:        events,
+		})
+	}, opts...).Run(ctx)
+}
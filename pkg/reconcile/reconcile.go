@@ -0,0 +1,204 @@
+// Package reconcile compares two snapshots of the log namespace, such as an export and the live
+// ledger or a mirror and an archive, and reports the records added, missing or changed between them.
+package reconcile
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/export"
+)
+
+// Kind classifies a difference between two snapshots
+type Kind string
+
+// Difference kinds, relative to the left snapshot
+const (
+	// Added records are only in the right snapshot
+	Added Kind = "added"
+	// Missing records are only in the left snapshot
+	Missing Kind = "missing"
+	// Changed records are in both snapshots with different contents
+	Changed Kind = "changed"
+)
+
+// Fields are the log fields compared by default, by their JSON names
+var Fields = []string{"id", "userId", "action", "resource", "timestamp", "description", "metadata"}
+
+// Difference describes one record that differs between the snapshots. A hash is empty on the
+// side the record is absent from.
+type Difference struct {
+	ID        string `json:"id"`
+	Kind      Kind   `json:"kind"`
+	LeftHash  string `json:"leftHash,omitempty"`
+	RightHash string `json:"rightHash,omitempty"`
+}
+
+// Report is the outcome of comparing two snapshots
+type Report struct {
+	LeftRecords  int `json:"leftRecords"`
+	RightRecords int `json:"rightRecords"`
+	Matched      int `json:"matched"`
+	// Differences are sorted by log ID
+	Differences []Difference `json:"differences"`
+}
+
+// Options configures Diff
+type Options struct {
+	// Fields restricts the comparison to the named fields, for exports written with a subset
+	// of columns; Fields is used when empty. The id field is always compared.
+	Fields []string
+}
+
+// Diff reads both sources to the end and compares them by log ID. The left snapshot's hashes are
+// held in memory while the right one is streamed.
+func Diff(ctx context.Context, left export.Source, right export.Source, options Options) (*Report, error) {
+	if len(options.Fields) == 0 {
+		options.Fields = Fields
+	}
+	for _, field := range options.Fields {
+		if _, ok := fieldValue(&client.LogEvent{}, field); !ok {
+			return nil, fmt.Errorf("unknown field %q", field)
+		}
+	}
+
+	report := &Report{}
+	leftHashes := map[string]string{}
+	err := drain(ctx, left, func(log *client.LogEvent) error {
+		if _, ok := leftHashes[log.ID]; ok {
+			return fmt.Errorf("the left snapshot holds log %s more than once", log.ID)
+		}
+		leftHashes[log.ID] = Hash(log, options.Fields)
+		report.LeftRecords++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read left snapshot: %v", err)
+	}
+
+	seen := map[string]bool{}
+	err = drain(ctx, right, func(log *client.LogEvent) error {
+		if seen[log.ID] {
+			return fmt.Errorf("the right snapshot holds log %s more than once", log.ID)
+		}
+		seen[log.ID] = true
+		report.RightRecords++
+
+		hash := Hash(log, options.Fields)
+		leftHash, ok := leftHashes[log.ID]
+		switch {
+		case !ok:
+			report.Differences = append(report.Differences, Difference{ID: log.ID, Kind: Added, RightHash: hash})
+		case leftHash != hash:
+			report.Differences = append(report.Differences, Difference{ID: log.ID, Kind: Changed, LeftHash: leftHash, RightHash: hash})
+		default:
+			report.Matched++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read right snapshot: %v", err)
+	}
+
+	for id, hash := range leftHashes {
+		if !seen[id] {
+			report.Differences = append(report.Differences, Difference{ID: id, Kind: Missing, LeftHash: hash})
+		}
+	}
+	sort.Slice(report.Differences, func(i, j int) bool {
+		return report.Differences[i].ID < report.Differences[j].ID
+	})
+
+	return report, nil
+}
+
+// Hash returns the hex SHA-256 of the given fields of log, encoded as a JSON array of strings
+// in field order
+func Hash(log *client.LogEvent, fields []string) string {
+	values := make([]string, len(fields))
+	for i, field := range fields {
+		values[i], _ = fieldValue(log, field)
+	}
+
+	data, _ := json.Marshal(values)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// InSync reports whether the snapshots hold the same records
+func (r *Report) InSync() bool {
+	return len(r.Differences) == 0
+}
+
+// Count returns the number of differences of kind
+func (r *Report) Count(kind Kind) int {
+	n := 0
+	for _, difference := range r.Differences {
+		if difference.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteNDJSON writes one line per difference
+func (r *Report) WriteNDJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	for _, difference := range r.Differences {
+		if err := encoder.Encode(difference); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String summarizes the report
+func (r *Report) String() string {
+	return fmt.Sprintf("%d left, %d right: %d matched, %d added, %d missing, %d changed",
+		r.LeftRecords, r.RightRecords, r.Matched, r.Count(Added), r.Count(Missing), r.Count(Changed))
+}
+
+func drain(ctx context.Context, source export.Source, fn func(log *client.LogEvent) error) error {
+	for {
+		log, err := source.Next(ctx)
+		if errors.Is(err, client.ErrIteratorDone) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(log); err != nil {
+			return err
+		}
+	}
+}
+
+func fieldValue(log *client.LogEvent, field string) (string, bool) {
+	switch field {
+	case "id":
+		return log.ID, true
+	case "userId":
+		return log.UserID, true
+	case "action":
+		return log.Action, true
+	case "resource":
+		return log.Resource, true
+	case "timestamp":
+		return log.Timestamp, true
+	case "description":
+		return log.Description, true
+	case "metadata":
+		return log.Metadata, true
+	case "collection":
+		return log.Collection, true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,200 @@
+// Package replicate copies selected logs from one channel or network to another, for
+// consortium members who keep a second audit network. A Replicator is a projection.Sink, so
+// the Projector reading the source channel provides ordering, retries and checkpointing.
+package replicate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// ProvenanceKey is the metadata field listing the hops a replicated log has taken, oldest first
+const ProvenanceKey = "replicatedFrom"
+
+// Hop records where a replicated log was copied from
+type Hop struct {
+	Network       string `json:"network,omitempty"`
+	Channel       string `json:"channel"`
+	BlockNumber   uint64 `json:"blockNumber"`
+	TransactionID string `json:"transactionId"`
+	// Timestamp is the log's timestamp on the source ledger
+	Timestamp string `json:"timestamp,omitempty"`
+}
+
+// Filter selects the logs to replicate
+type Filter func(log client.LogEvent) bool
+
+// ActionFilter selects logs with one of the given actions
+func ActionFilter(actions ...string) Filter {
+	allowed := map[string]bool{}
+	for _, action := range actions {
+		allowed[action] = true
+	}
+	return func(log client.LogEvent) bool {
+		return allowed[log.Action]
+	}
+}
+
+// SeverityFilter selects logs whose metadata level is at least level, such as "WARN".
+// Logs without a level are not selected.
+func SeverityFilter(level string) (Filter, error) {
+	minimum, ok := client.ParseSeverity(level)
+	if !ok {
+		return nil, fmt.Errorf("unknown severity %q", level)
+	}
+	return func(log client.LogEvent) bool {
+		severity, ok := client.LogSeverity(log)
+		return ok && severity >= minimum
+	}, nil
+}
+
+// MetadataFilter selects logs whose metadata field key holds one of values. The chaincode does
+// not record the submitting organization, so filter by organization on a field the producers
+// stamp, for example with an Enricher's Fields.
+func MetadataFilter(key string, values ...string) Filter {
+	allowed := map[string]bool{}
+	for _, value := range values {
+		allowed[value] = true
+	}
+	return func(log client.LogEvent) bool {
+		var metadata map[string]interface{}
+		if err := json.Unmarshal([]byte(log.Metadata), &metadata); err != nil {
+			return false
+		}
+		value, _ := metadata[key].(string)
+		return allowed[value]
+	}
+}
+
+// Options configures a Replicator
+type Options struct {
+	// SourceNetwork and SourceChannel identify where logs are read from, and TargetNetwork and
+	// TargetChannel where they are written. Network names are free-form and only need to be
+	// consistent between replicators.
+	SourceNetwork string
+	SourceChannel string
+	TargetNetwork string
+	TargetChannel string
+	// Filters must all select a log for it to be replicated; every log is replicated when empty
+	Filters []Filter
+	// Logger, when set, receives a record for each log skipped by loop prevention
+	Logger *slog.Logger
+}
+
+// Replicator re-submits selected logs to a target client under their original IDs, with their
+// provenance appended to the metadata. A log whose provenance already includes the target is
+// skipped, so replicators running in both directions never loop.
+type Replicator struct {
+	target  client.LoggingClient
+	options Options
+}
+
+// NewReplicator returns a Replicator writing to target
+func NewReplicator(target client.LoggingClient, options Options) (*Replicator, error) {
+	if options.SourceChannel == "" || options.TargetChannel == "" {
+		return nil, fmt.Errorf("source and target channels are required")
+	}
+	if options.SourceNetwork == options.TargetNetwork && options.SourceChannel == options.TargetChannel {
+		return nil, fmt.Errorf("source and target are the same channel")
+	}
+
+	return &Replicator{target: target, options: options}, nil
+}
+
+// Write replicates the selected logs among events in one batch. Logs already present on the
+// target, for example after a replay, are left as they are.
+func (r *Replicator) Write(ctx context.Context, events []client.ContractEvent) error {
+	var logs []client.LogEvent
+	for _, event := range events {
+		log, ok, err := r.replica(event)
+		if err != nil {
+			return err
+		}
+		if ok {
+			logs = append(logs, log)
+		}
+	}
+	if len(logs) == 0 {
+		return nil
+	}
+
+	err := r.target.CreateLogsBatch(ctx, logs)
+	if !errors.Is(err, client.ErrAlreadyExists) {
+		return err
+	}
+
+	// Some logs were replicated before; the batch is all or nothing, so submit them one by one
+	for _, log := range logs {
+		if err := r.target.CreateLog(ctx, log); err != nil && !errors.Is(err, client.ErrAlreadyExists) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// replica returns the log to submit for event, and false when it is not replicated
+func (r *Replicator) replica(event client.ContractEvent) (client.LogEvent, bool, error) {
+	log := event.Log
+	// The target applies a retention policy of its own
+	if event.Pruned() {
+		return log, false, nil
+	}
+	// Private fields never leave the source network
+	if log.Collection != "" {
+		return log, false, nil
+	}
+	for _, filter := range r.options.Filters {
+		if !filter(log) {
+			return log, false, nil
+		}
+	}
+
+	metadata := map[string]interface{}{}
+	if log.Metadata != "" {
+		if err := json.Unmarshal([]byte(log.Metadata), &metadata); err != nil {
+			return log, false, fmt.Errorf("failed to read metadata of log %s: %v", log.ID, err)
+		}
+	}
+
+	var hops []Hop
+	if raw, ok := metadata[ProvenanceKey]; ok {
+		rawJSON, err := json.Marshal(raw)
+		if err != nil {
+			return log, false, err
+		}
+		if err := json.Unmarshal(rawJSON, &hops); err != nil {
+			return log, false, fmt.Errorf("failed to read provenance of log %s: %v", log.ID, err)
+		}
+	}
+	for _, hop := range hops {
+		if hop.Network == r.options.TargetNetwork && hop.Channel == r.options.TargetChannel {
+			if r.options.Logger != nil {
+				r.options.Logger.Debug("skipping log that came from the target", "id", log.ID, "channel", hop.Channel, "network", hop.Network)
+			}
+			return log, false, nil
+		}
+	}
+
+	metadata[ProvenanceKey] = append(hops, Hop{
+		Network:       r.options.SourceNetwork,
+		Channel:       r.options.SourceChannel,
+		BlockNumber:   event.BlockNumber,
+		TransactionID: event.TransactionID,
+		Timestamp:     log.Timestamp,
+	})
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return log, false, err
+	}
+	log.Metadata = string(metadataJSON)
+	// The target ledger assigns its own timestamp; the original is kept with the provenance
+	log.Timestamp = ""
+
+	return log, true, nil
+}
@@ -0,0 +1,126 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/archive"
+)
+
+// File is one rendered report or signature
+type File struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Delivery sends the files of a report to their recipients
+type Delivery interface {
+	Deliver(ctx context.Context, report *Report, files []File) error
+}
+
+// StoreDelivery writes report files to object storage, under the date the window starts on
+type StoreDelivery struct {
+	Store archive.Store
+}
+
+// Deliver stores each file as <yyyy>/<mm>/<dd>/<name>
+func (d StoreDelivery) Deliver(ctx context.Context, report *Report, files []File) error {
+	for _, file := range files {
+		key := path.Join(report.WindowStart.Format("2006/01/02"), file.Name)
+		if err := d.Store.Put(ctx, key, bytes.NewReader(file.Data), int64(len(file.Data))); err != nil {
+			return fmt.Errorf("failed to store report file %s: %v", file.Name, err)
+		}
+	}
+	return nil
+}
+
+// MailDelivery emails report files as attachments through an SMTP server
+type MailDelivery struct {
+	// Addr is the host:port of the SMTP server, which must offer STARTTLS when Auth is set
+	Addr string
+	Auth smtp.Auth
+	From string
+	To   []string
+}
+
+// Deliver sends one message with a short summary and every file attached. The SMTP exchange
+// cannot be cancelled once started, so ctx only prevents it from starting.
+func (d MailDelivery) Deliver(ctx context.Context, report *Report, files []File) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	message, err := d.message(report, files)
+	if err != nil {
+		return err
+	}
+	if err := smtp.SendMail(d.Addr, d.Auth, d.From, d.To, message); err != nil {
+		return fmt.Errorf("failed to email report to %s: %v", strings.Join(d.To, ", "), err)
+	}
+	return nil
+}
+
+// message builds the MIME message of a report
+func (d MailDelivery) message(report *Report, files []File) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+
+	subject := fmt.Sprintf("%s, %s to %s", report.Title, report.WindowStart.Format("2006-01-02 15:04"), report.WindowEnd.Format("2006-01-02 15:04 UTC"))
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", d.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(d.To, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", parts.Boundary())
+
+	summary := fmt.Sprintf("%d logs from %d users, %d at %s or above.", report.Total, report.DistinctUsers, report.HighSeverityTotal, report.Severity)
+	if report.Integrity != nil {
+		summary += fmt.Sprintf(" %d hash chain divergences in %d entries checked.", len(report.Integrity.Divergences), report.Integrity.Checked)
+	}
+	summary += "\r\n\r\nThe report and its signature are attached.\r\n"
+	text, err := parts.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := text.Write([]byte(summary)); err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		part, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {file.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": file.Name})},
+		})
+		if err != nil {
+			return nil, err
+		}
+		// Lines of base64 are limited to 76 characters
+		encoded := base64.StdEncoding.EncodeToString(file.Data)
+		for len(encoded) > 76 {
+			if _, err := fmt.Fprintf(part, "%s\r\n", encoded[:76]); err != nil {
+				return nil, err
+			}
+			encoded = encoded[76:]
+		}
+		if _, err := fmt.Fprintf(part, "%s\r\n", encoded); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	message.Write(body.Bytes())
+	return message.Bytes(), nil
+}
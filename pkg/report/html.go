@@ -0,0 +1,152 @@
+package report
+
+import (
+	"html/template"
+	"io"
+	"time"
+)
+
+// htmlTemplate lays the report out as a single page with inline styles, so it reads the same
+// as an email attachment, a stored object or a file opened from disk
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"time":     func(t time.Time) string { return t.Format("2006-01-02 15:04:05 UTC") },
+	"severity": severityName,
+	"width":    barWidth,
+	"top":      topCounts,
+	"limit":    func() int { return reportListLimit },
+}).Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, -apple-system, "Segoe UI", sans-serif; font-size: 14px; color: #1f2328; margin: 2rem; }
+h1 { font-size: 1.5rem; margin: 0 0 0.25rem; }
+h2 { font-size: 1.1rem; margin: 2rem 0 0.5rem; border-bottom: 1px solid #d0d7de; padding-bottom: 0.25rem; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 0.25rem 0.5rem; border-bottom: 1px solid #eaeef2; vertical-align: top; }
+td.number, th.number { text-align: right; }
+.muted { color: #656d76; }
+.bar { background: #2f6feb; height: 0.7rem; border-radius: 2px; }
+.alert { color: #cf222e; font-weight: 600; }
+.ok { color: #1a7f37; font-weight: 600; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="muted">{{if .Source}}{{.Source}}, {{end}}{{time .WindowStart}} to {{time .WindowEnd}}. Generated {{time .GeneratedAt}}.</p>
+
+<h2>Summary</h2>
+<table>
+<tr><th>Logs</th><td class="number">{{.Total}}</td></tr>
+<tr><th>Private logs</th><td class="number">{{.PrivateLogs}}</td></tr>
+<tr><th>Users</th><td class="number">{{.DistinctUsers}}</td></tr>
+<tr><th>Actions</th><td class="number">{{len .Actions}}</td></tr>
+<tr><th>Logs at {{.Severity}} or above</th><td class="number{{if .HighSeverityTotal}} alert{{end}}">{{.HighSeverityTotal}}</td></tr>
+{{- with .Integrity}}
+<tr><th>Hash chain divergences</th><td class="number {{if .Divergences}}alert{{else}}ok{{end}}">{{len .Divergences}}</td></tr>
+{{- end}}
+</table>
+
+<h2>Activity</h2>
+{{- $activity := .Activity}}
+<table>
+{{- range .Activity}}
+<tr><td>{{.Name}}</td><td style="width: 70%"><div class="bar" style="width: {{width .Count $activity}}%"></div></td><td class="number">{{.Count}}</td></tr>
+{{- end}}
+</table>
+
+<h2>Actions</h2>
+{{- if .Actions}}
+<table>
+<tr><th>Action</th><th class="number">Logs</th></tr>
+{{- range top .Actions}}
+<tr><td>{{.Name}}</td><td class="number">{{.Count}}</td></tr>
+{{- end}}
+</table>
+{{- if gt (len .Actions) limit}}
+<p class="muted">{{len .Actions}} actions in total; the {{limit}} most frequent are listed.</p>
+{{- end}}
+{{- else}}
+<p class="muted">No logs were recorded in the window.</p>
+{{- end}}
+
+<h2>Users</h2>
+{{- if .Users}}
+<table>
+<tr><th>User</th><th class="number">Logs</th></tr>
+{{- range top .Users}}
+<tr><td>{{.Name}}</td><td class="number">{{.Count}}</td></tr>
+{{- end}}
+</table>
+{{- if gt (len .Users) limit}}
+<p class="muted">{{len .Users}} users in total; the {{limit}} most active are listed.</p>
+{{- end}}
+{{- else}}
+<p class="muted">No logs were recorded in the window.</p>
+{{- end}}
+
+<h2>Logs at {{.Severity}} or above</h2>
+{{- if .HighSeverity}}
+<table>
+<tr><th>Time</th><th>Level</th><th>User</th><th>Action</th><th>Resource</th><th>Description</th><th>ID</th></tr>
+{{- range .HighSeverity}}
+<tr><td>{{.Timestamp}}</td><td class="alert">{{severity .}}</td><td>{{.UserID}}</td><td>{{.Action}}</td><td>{{.Resource}}</td><td>{{.Description}}</td><td class="muted">{{.ID}}</td></tr>
+{{- end}}
+</table>
+{{- if gt .HighSeverityTotal (len .HighSeverity)}}
+<p class="muted">The first {{len .HighSeverity}} of {{.HighSeverityTotal}} logs are listed.</p>
+{{- end}}
+{{- else}}
+<p class="ok">None.</p>
+{{- end}}
+
+<h2>Integrity</h2>
+{{- with .Integrity}}
+<p>{{.Checked}} submitted logs recorded in the hash chain during the window were read back from the ledger.</p>
+{{- if .Divergences}}
+<table>
+<tr><th>Kind</th><th class="number">Entry</th><th>Log</th><th>Expected</th><th>Actual</th></tr>
+{{- range .Divergences}}
+<tr><td class="alert">{{.Kind}}</td><td class="number">{{.Sequence}}</td><td>{{.LogID}}</td><td>{{.Expected}}</td><td>{{.Actual}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p class="ok">Every entry matches the ledger.</p>
+{{- end}}
+{{- else}}
+<p class="muted">No hash chain was checked.</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// reportListLimit is the most actions and users a rendered report lists
+const reportListLimit = 25
+
+// RenderHTML writes report to w as an HTML page
+func RenderHTML(w io.Writer, report *Report) error {
+	return htmlTemplate.Execute(w, report)
+}
+
+// barWidth returns count as a percentage of the largest count
+func barWidth(count int, counts []Count) int {
+	max := 0
+	for _, c := range counts {
+		if c.Count > max {
+			max = c.Count
+		}
+	}
+	if max == 0 {
+		return 0
+	}
+	return count * 100 / max
+}
+
+// topCounts returns the first reportListLimit counts
+func topCounts(counts []Count) []Count {
+	if len(counts) > reportListLimit {
+		return counts[:reportListLimit]
+	}
+	return counts
+}
@@ -0,0 +1,248 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Page layout of rendered PDFs, in points: A4 with 50 point margins
+const (
+	pdfPageWidth  = 595
+	pdfPageHeight = 842
+	pdfMargin     = 50
+)
+
+// PDF fonts, from the standard 14 every reader provides
+const (
+	pdfRegular = "F1"
+	pdfBold    = "F2"
+	pdfMono    = "F3"
+)
+
+var pdfFonts = []struct{ name, base string }{
+	{pdfRegular, "Helvetica"},
+	{pdfBold, "Helvetica-Bold"},
+	{pdfMono, "Courier"},
+}
+
+// pdfDocument lays out lines of text top to bottom over as many pages as they need
+type pdfDocument struct {
+	pages []*bytes.Buffer
+	y     float64
+}
+
+// line adds a line of text in font at size points, cut to the width of the page. Text outside
+// Latin-1 is replaced, since the standard fonts cover no more, and control characters are
+// spaces.
+func (d *pdfDocument) line(font string, size float64, text string) {
+	if len(d.pages) == 0 || d.y-size*1.3 < pdfMargin {
+		d.pages = append(d.pages, &bytes.Buffer{})
+		d.y = pdfPageHeight - pdfMargin
+	}
+	d.y -= size * 1.3
+
+	// Courier glyphs are 0.6 em wide; 0.5 em is an average for Helvetica
+	em := 0.5
+	if font == pdfMono {
+		em = 0.6
+	}
+	text = cut(text, int((pdfPageWidth-2*pdfMargin)/(size*em)))
+	fmt.Fprintf(d.pages[len(d.pages)-1], "BT /%s %g Tf %d %.1f Td (%s) Tj ET\n", font, size, pdfMargin, d.y, pdfString(text))
+}
+
+// space starts a new page when less than height remains on the current one
+func (d *pdfDocument) space(height float64) {
+	if len(d.pages) > 0 && d.y-height < pdfMargin {
+		d.pages = append(d.pages, &bytes.Buffer{})
+		d.y = pdfPageHeight - pdfMargin
+	}
+}
+
+// gap leaves height points of blank space
+func (d *pdfDocument) gap(height float64) {
+	d.y -= height
+}
+
+// heading starts a section, on a new page when the heading and a few lines would not fit
+func (d *pdfDocument) heading(text string) {
+	d.gap(12)
+	d.space(80)
+	d.line(pdfBold, 13, text)
+	d.gap(4)
+}
+
+// writeTo writes the document as a PDF file, numbering its pages in their footers
+func (d *pdfDocument) writeTo(w io.Writer, title string, created time.Time) error {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// Objects 1 and 2 are the catalog and the page tree, followed by the fonts, the info
+	// dictionary, and a page and its content stream for each page
+	fontsStart := 3
+	infoObject := fontsStart + len(pdfFonts)
+	firstPage := infoObject + 1
+
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+
+	var fonts []string
+	for i, font := range pdfFonts {
+		object(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", font.base))
+		fonts = append(fonts, fmt.Sprintf("/%s %d 0 R", font.name, fontsStart+i))
+	}
+	object(fmt.Sprintf("<< /Title (%s) /Producer (fabric-logging-system) /CreationDate (D:%s) >>",
+		pdfString(title), created.UTC().Format("20060102150405Z")))
+
+	for i, page := range d.pages {
+		fmt.Fprintf(page, "BT /%s 8 Tf %d %d Td (%s) Tj ET\n", pdfRegular, pdfMargin, pdfMargin/2,
+			pdfString(fmt.Sprintf("%s - page %d of %d", title, i+1, len(d.pages))))
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, strings.Join(fonts, " "), firstPage+2*i+1))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.Bytes()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, infoObject, xref)
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// pdfString encodes s as the content of a PDF literal string in WinAnsiEncoding
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20:
+			b.WriteByte(' ')
+		case r == utf8.RuneError || (r >= 0x7f && r < 0xa0) || r > 0xff:
+			b.WriteByte('?')
+		default:
+			b.WriteByte(byte(r))
+		}
+	}
+	return b.String()
+}
+
+// cut shortens s to at most n characters, marking the cut
+func cut(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:n-3]) + "..."
+}
+
+// RenderPDF writes report to w as a PDF document of the same sections as RenderHTML
+func RenderPDF(w io.Writer, report *Report) error {
+	d := &pdfDocument{}
+	timeFormat := "2006-01-02 15:04:05 UTC"
+
+	d.line(pdfBold, 18, report.Title)
+	subtitle := fmt.Sprintf("%s to %s. Generated %s.", report.WindowStart.Format(timeFormat), report.WindowEnd.Format(timeFormat), report.GeneratedAt.Format(timeFormat))
+	if report.Source != "" {
+		subtitle = report.Source + ", " + subtitle
+	}
+	d.line(pdfRegular, 9, subtitle)
+
+	d.heading("Summary")
+	summary := [][2]string{
+		{"Logs", fmt.Sprint(report.Total)},
+		{"Private logs", fmt.Sprint(report.PrivateLogs)},
+		{"Users", fmt.Sprint(report.DistinctUsers)},
+		{"Actions", fmt.Sprint(len(report.Actions))},
+		{fmt.Sprintf("Logs at %s or above", report.Severity), fmt.Sprint(report.HighSeverityTotal)},
+	}
+	if report.Integrity != nil {
+		summary = append(summary, [2]string{"Hash chain divergences", fmt.Sprint(len(report.Integrity.Divergences))})
+	}
+	for _, row := range summary {
+		d.line(pdfMono, 9, fmt.Sprintf("%-40s %10s", row[0], row[1]))
+	}
+
+	d.heading("Activity")
+	max := 0
+	for _, c := range report.Activity {
+		if c.Count > max {
+			max = c.Count
+		}
+	}
+	for _, c := range report.Activity {
+		bar := 0
+		if max > 0 {
+			bar = c.Count * 50 / max
+		}
+		d.line(pdfMono, 9, fmt.Sprintf("%-16s %-50s %8d", c.Name, strings.Repeat("#", bar), c.Count))
+	}
+
+	for _, list := range []struct {
+		title, column, more string
+		counts              []Count
+	}{
+		{"Actions", "ACTION", "most frequent", report.Actions},
+		{"Users", "USER", "most active", report.Users},
+	} {
+		d.heading(list.title)
+		if len(list.counts) == 0 {
+			d.line(pdfRegular, 10, "No logs were recorded in the window.")
+			continue
+		}
+		d.line(pdfMono, 9, fmt.Sprintf("%-70s %10s", list.column, "LOGS"))
+		for _, c := range topCounts(list.counts) {
+			d.line(pdfMono, 9, fmt.Sprintf("%-70s %10d", cut(c.Name, 70), c.Count))
+		}
+		if len(list.counts) > reportListLimit {
+			d.line(pdfRegular, 9, fmt.Sprintf("%d %s in total; the %d %s are listed.", len(list.counts), strings.ToLower(list.title), reportListLimit, list.more))
+		}
+	}
+
+	d.heading(fmt.Sprintf("Logs at %s or above", report.Severity))
+	if len(report.HighSeverity) == 0 {
+		d.line(pdfRegular, 10, "None.")
+	}
+	for _, log := range report.HighSeverity {
+		d.space(40)
+		d.line(pdfMono, 8, fmt.Sprintf("%s  %-6s %s  %s  %s", log.Timestamp, severityName(log), log.UserID, log.Action, log.Resource))
+		d.line(pdfRegular, 8, "    "+log.Description)
+		d.line(pdfMono, 7, "    "+log.ID)
+	}
+	if report.HighSeverityTotal > len(report.HighSeverity) {
+		d.line(pdfRegular, 9, fmt.Sprintf("The first %d of %d logs are listed.", len(report.HighSeverity), report.HighSeverityTotal))
+	}
+
+	d.heading("Integrity")
+	switch {
+	case report.Integrity == nil:
+		d.line(pdfRegular, 10, "No hash chain was checked.")
+	case len(report.Integrity.Divergences) == 0:
+		d.line(pdfRegular, 10, fmt.Sprintf("%d submitted logs recorded in the hash chain during the window were read back from the ledger.", report.Integrity.Checked))
+		d.line(pdfRegular, 10, "Every entry matches the ledger.")
+	default:
+		d.line(pdfRegular, 10, fmt.Sprintf("%d submitted logs recorded in the hash chain during the window were read back from the ledger.", report.Integrity.Checked))
+		for _, divergence := range report.Integrity.Divergences {
+			d.line(pdfMono, 8, divergence.String())
+		}
+	}
+
+	return d.writeTo(w, report.Title, report.GeneratedAt)
+}
@@ -0,0 +1,200 @@
+// Package report generates compliance reports of the logs committed in a time window: activity
+// per action, user and period, the high-severity logs, and the result of checking a client's
+// hash chain against the ledger. Reports are rendered as HTML and PDF, signed with a Fabric
+// identity, and delivered on a cron schedule by email or to object storage.
+package report
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// Report defaults
+const (
+	DefaultSeverity  = "ERROR"
+	DefaultMaxEvents = 100
+	DefaultPageSize  = 200
+)
+
+// Report is the content of one compliance report
+type Report struct {
+	Title string `json:"title"`
+	// Source labels where the logs came from, such as the channel name
+	Source      string    `json:"source,omitempty"`
+	WindowStart time.Time `json:"windowStart"`
+	WindowEnd   time.Time `json:"windowEnd"`
+	GeneratedAt time.Time `json:"generatedAt"`
+
+	Total int `json:"total"`
+	// PrivateLogs counts the logs whose details live in a private data collection
+	PrivateLogs int `json:"privateLogs"`
+	// DistinctUsers is the number of users with at least one log
+	DistinctUsers int `json:"distinctUsers"`
+	// Activity counts the logs per hour for windows of up to two days, and per day otherwise
+	Activity []Count `json:"activity"`
+	// Actions and Users are sorted by count, the most frequent first
+	Actions []Count `json:"actions"`
+	Users   []Count `json:"users"`
+
+	// Severity is the least level of the logs in HighSeverity
+	Severity string `json:"severity"`
+	// HighSeverity lists the first logs at Severity or above, oldest first; HighSeverityTotal
+	// counts all of them
+	HighSeverity      []*client.LogEvent `json:"highSeverity"`
+	HighSeverityTotal int                `json:"highSeverityTotal"`
+
+	// Integrity is the result of the hash chain check, or nil when none was configured
+	Integrity *Integrity `json:"integrity,omitempty"`
+}
+
+// Count is the number of logs sharing one value, such as an action
+type Count struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Integrity is the result of checking the entries a client recorded in its hash chain during
+// the window against the ledger
+type Integrity struct {
+	Checked     int                 `json:"checked"`
+	Divergences []client.Divergence `json:"divergences"`
+}
+
+// GenerateOptions configures Generate
+type GenerateOptions struct {
+	Title  string
+	Source string
+	// Severity is the least level listed as high severity; DefaultSeverity is used when empty
+	Severity string
+	// MaxEvents is the most high-severity logs listed; DefaultMaxEvents is used otherwise
+	MaxEvents int
+	// Verifier, when set, checks the hash chain entries recorded during the window
+	Verifier *client.Verifier
+	// PageSize is the number of logs read per query page; DefaultPageSize is used otherwise
+	PageSize int32
+}
+
+// Generate reads the logs with timestamps from start up to but excluding end through c and
+// summarizes them in a Report
+func Generate(ctx context.Context, c *client.Client, start, end time.Time, options GenerateOptions) (*Report, error) {
+	start, end = start.UTC(), end.UTC()
+	if !end.After(start) {
+		return nil, fmt.Errorf("report window end %s is not after its start %s", end, start)
+	}
+	if options.Title == "" {
+		options.Title = "Compliance report"
+	}
+	if options.Severity == "" {
+		options.Severity = DefaultSeverity
+	}
+	threshold, ok := client.ParseSeverity(options.Severity)
+	if !ok {
+		return nil, fmt.Errorf("unknown severity %q", options.Severity)
+	}
+	if options.MaxEvents <= 0 {
+		options.MaxEvents = DefaultMaxEvents
+	}
+	if options.PageSize <= 0 {
+		options.PageSize = DefaultPageSize
+	}
+
+	report := &Report{
+		Title:       options.Title,
+		Source:      options.Source,
+		WindowStart: start,
+		WindowEnd:   end,
+		Severity:    options.Severity,
+	}
+
+	bucket, label := 24*time.Hour, "2006-01-02"
+	if end.Sub(start) <= 48*time.Hour {
+		bucket, label = time.Hour, "2006-01-02 15:00"
+	}
+	activity := make(map[time.Time]int)
+	actions := make(map[string]int)
+	users := make(map[string]int)
+
+	// The chaincode's time range is inclusive at both ends and has second precision
+	filter := client.LogFilter{
+		StartTime: start.Format(time.RFC3339),
+		EndTime:   end.Add(-time.Second).Format(time.RFC3339),
+	}
+	it := c.IterateLogs(filter, options.PageSize)
+	for {
+		log, err := it.Next(ctx)
+		if errors.Is(err, client.ErrIteratorDone) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read logs after %d records: %v", report.Total, err)
+		}
+
+		report.Total++
+		if log.Collection != "" {
+			report.PrivateLogs++
+		}
+		actions[log.Action]++
+		users[log.UserID]++
+		if t, err := time.Parse(time.RFC3339Nano, log.Timestamp); err == nil {
+			activity[t.UTC().Truncate(bucket)]++
+		}
+
+		if level, ok := client.LogSeverity(*log); ok && level >= threshold {
+			report.HighSeverityTotal++
+			report.HighSeverity = append(report.HighSeverity, log)
+		}
+	}
+
+	// Every period of the window is listed, including those without logs
+	for t := start.Truncate(bucket); t.Before(end); t = t.Add(bucket) {
+		report.Activity = append(report.Activity, Count{Name: t.Format(label), Count: activity[t]})
+	}
+	report.Actions = sortedCounts(actions)
+	report.Users = sortedCounts(users)
+	report.DistinctUsers = len(users)
+
+	sort.SliceStable(report.HighSeverity, func(i, j int) bool {
+		return report.HighSeverity[i].Timestamp < report.HighSeverity[j].Timestamp
+	})
+	if len(report.HighSeverity) > options.MaxEvents {
+		report.HighSeverity = report.HighSeverity[:options.MaxEvents]
+	}
+
+	if options.Verifier != nil {
+		divergences, checked, err := options.Verifier.VerifyBetween(ctx, c, start, end.Add(-time.Nanosecond))
+		if err != nil {
+			return nil, fmt.Errorf("failed to check the hash chain: %v", err)
+		}
+		report.Integrity = &Integrity{Checked: checked, Divergences: divergences}
+	}
+
+	report.GeneratedAt = time.Now().UTC()
+	return report, nil
+}
+
+// sortedCounts returns counts sorted by count, the most frequent first, then by name
+func sortedCounts(counts map[string]int) []Count {
+	sorted := make([]Count, 0, len(counts))
+	for name, count := range counts {
+		sorted = append(sorted, Count{Name: name, Count: count})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		return a.Count > b.Count || (a.Count == b.Count && a.Name < b.Name)
+	})
+	return sorted
+}
+
+// severityName returns the level recorded in log's metadata, or an empty string
+func severityName(log *client.LogEvent) string {
+	level, ok := client.LogSeverity(*log)
+	if !ok {
+		return ""
+	}
+	return level.String()
+}
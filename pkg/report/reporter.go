@@ -0,0 +1,187 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// Format is a rendering of a report
+type Format string
+
+// Report formats
+const (
+	FormatHTML Format = "html"
+	FormatPDF  Format = "pdf"
+)
+
+// Options configures a Reporter
+type Options struct {
+	// Schedule is when reports are published; it is required by Run
+	Schedule *Schedule
+	// Location is the time zone the schedule is read in; UTC is used when nil
+	Location *time.Location
+	// Window is the span of log timestamps each report covers, ending at the scheduled time.
+	// When zero it is the time since the previous scheduled time, so consecutive reports
+	// cover the ledger without gaps.
+	Window time.Duration
+	// Formats are the renderings published; both HTML and PDF when empty
+	Formats []Format
+	// Identity and Sign sign every rendering; the client's own identity is used when nil
+	Identity *client.Identity
+	Sign     client.Sign
+	// Deliveries receive every report; at least one is required
+	Deliveries []Delivery
+	// Generate configures the content of the reports
+	Generate GenerateOptions
+	// OnError is called by Run with the errors of a scheduled report, which is not retried
+	OnError func(error)
+}
+
+// Reporter publishes compliance reports on a schedule
+type Reporter struct {
+	client  *client.Client
+	options Options
+}
+
+// NewReporter returns a Reporter reading logs through c
+func NewReporter(c *client.Client, options Options) (*Reporter, error) {
+	if options.Identity == nil || options.Sign == nil {
+		options.Identity, options.Sign = c.Signer()
+	}
+	if len(options.Deliveries) == 0 {
+		return nil, fmt.Errorf("no report delivery configured")
+	}
+	if options.Location == nil {
+		options.Location = time.UTC
+	}
+	if len(options.Formats) == 0 {
+		options.Formats = []Format{FormatHTML, FormatPDF}
+	}
+	for _, format := range options.Formats {
+		if format != FormatHTML && format != FormatPDF {
+			return nil, fmt.Errorf("unsupported report format %q", format)
+		}
+	}
+	if options.Generate.Severity != "" {
+		if _, ok := client.ParseSeverity(options.Generate.Severity); !ok {
+			return nil, fmt.Errorf("unknown severity %q", options.Generate.Severity)
+		}
+	}
+
+	return &Reporter{client: c, options: options}, nil
+}
+
+// Run publishes a report at every scheduled time until ctx is done, and returns ctx's error.
+// Times that pass while the Reporter is not running are not caught up.
+func (r *Reporter) Run(ctx context.Context) error {
+	if r.options.Schedule == nil {
+		return fmt.Errorf("no report schedule configured")
+	}
+
+	for {
+		next := r.options.Schedule.Next(time.Now().In(r.options.Location))
+		if next.IsZero() {
+			return fmt.Errorf("the report schedule never fires")
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		if _, err := r.Publish(ctx, r.windowStart(next), next); err != nil && ctx.Err() == nil && r.options.OnError != nil {
+			r.options.OnError(err)
+		}
+	}
+}
+
+// PublishLatest publishes the report of the latest scheduled time that has passed, as Run
+// would have, and returns its files
+func (r *Reporter) PublishLatest(ctx context.Context) ([]File, error) {
+	if r.options.Schedule == nil {
+		return nil, fmt.Errorf("no report schedule configured")
+	}
+	end := r.options.Schedule.Previous(time.Now().In(r.options.Location))
+	if end.IsZero() {
+		return nil, fmt.Errorf("the report schedule never fired")
+	}
+	return r.Publish(ctx, r.windowStart(end), end)
+}
+
+// windowStart returns the start of the window of the report scheduled at end
+func (r *Reporter) windowStart(end time.Time) time.Time {
+	if r.options.Window > 0 {
+		return end.Add(-r.options.Window)
+	}
+	return r.options.Schedule.Previous(end)
+}
+
+// Publish generates the report of the logs from start up to but excluding end, renders and
+// signs it in every format, and hands the files to every delivery. It returns the files, and
+// stops at the first delivery that fails.
+func (r *Reporter) Publish(ctx context.Context, start, end time.Time) ([]File, error) {
+	report, err := Generate(ctx, r.client, start, end, r.options.Generate)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := r.Render(report)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, delivery := range r.options.Deliveries {
+		if err := delivery.Deliver(ctx, report, files); err != nil {
+			return files, err
+		}
+	}
+	return files, nil
+}
+
+// Render renders report in every format, each followed by its detached signature
+func (r *Reporter) Render(report *Report) ([]File, error) {
+	base := fmt.Sprintf("compliance-%s-%s", report.WindowStart.Format(timeKeyFormat), report.WindowEnd.Format(timeKeyFormat))
+
+	var files []File
+	for _, format := range r.options.Formats {
+		var rendered bytes.Buffer
+		var err error
+		contentType := "text/html; charset=utf-8"
+		if format == FormatPDF {
+			contentType = "application/pdf"
+			err = RenderPDF(&rendered, report)
+		} else {
+			err = RenderHTML(&rendered, report)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to render %s report: %v", format, err)
+		}
+
+		name := base + "." + string(format)
+		signature, err := Sign(name, rendered.Bytes(), r.options.Identity, r.options.Sign)
+		if err != nil {
+			return nil, err
+		}
+		signatureJSON, err := json.MarshalIndent(signature, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files,
+			File{Name: name, ContentType: contentType, Data: rendered.Bytes()},
+			File{Name: name + SignatureSuffix, ContentType: "application/json", Data: signatureJSON},
+		)
+	}
+	return files, nil
+}
+
+// timeKeyFormat names report windows in file names
+const timeKeyFormat = "20060102T150405Z"
@@ -0,0 +1,83 @@
+package report
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// ErrInvalidSignature is returned when a rendered report does not match its signature
+var ErrInvalidSignature = errors.New("invalid report signature")
+
+// SignatureSuffix is appended to a rendered report's name to name its detached signature
+const SignatureSuffix = ".sig.json"
+
+// Signature is a detached ECDSA signature over the SHA-256 of a rendered report, with the
+// signer's identity
+type Signature struct {
+	Name        string    `json:"name"`
+	SHA256      string    `json:"sha256"`
+	SignedAt    time.Time `json:"signedAt"`
+	MSPID       string    `json:"mspId"`
+	Certificate string    `json:"certificate"`
+	Signature   []byte    `json:"signature"`
+}
+
+// Sign signs the rendered report data named name with id
+func Sign(name string, data []byte, id *client.Identity, sign client.Sign) (*Signature, error) {
+	digest := sha256.Sum256(data)
+	signature, err := sign(digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign report %s: %v", name, err)
+	}
+
+	return &Signature{
+		Name:        name,
+		SHA256:      hex.EncodeToString(digest[:]),
+		SignedAt:    time.Now().UTC(),
+		MSPID:       id.MSPID,
+		Certificate: string(id.Certificate),
+		Signature:   signature,
+	}, nil
+}
+
+// Verify checks that signature covers data and, when roots is not nil, that the signing
+// certificate chains to one of roots. It returns the signing certificate.
+func Verify(data []byte, signature *Signature, roots *x509.CertPool) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(signature.Certificate))
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM certificate", ErrInvalidSignature)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to parse certificate: %v", ErrInvalidSignature, err)
+	}
+
+	key, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported public key type %T", ErrInvalidSignature, cert.PublicKey)
+	}
+	digest := sha256.Sum256(data)
+	if hex.EncodeToString(digest[:]) != signature.SHA256 || !ecdsa.VerifyASN1(key, digest[:], signature.Signature) {
+		return nil, fmt.Errorf("%w: report %s does not match its signature", ErrInvalidSignature, signature.Name)
+	}
+
+	if roots != nil {
+		_, err := cert.Verify(x509.VerifyOptions{
+			Roots:     roots,
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%w: untrusted signer: %v", ErrInvalidSignature, err)
+		}
+	}
+
+	return cert, nil
+}
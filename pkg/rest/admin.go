@@ -0,0 +1,119 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// retentionSetting names the retention policy among the on-chain settings
+const retentionSetting = "retention"
+
+// configurer is implemented by clients managing the settings the chaincode records on the ledger
+type configurer interface {
+	GetRetentionPolicy(ctx context.Context) (*client.RetentionPolicy, error)
+	CheckRetentionPolicy(ctx context.Context, policy client.RetentionPolicy) error
+	SetRetentionPolicy(ctx context.Context, policy client.RetentionPolicy) (*client.SubmitResult, error)
+}
+
+// ConfigResponse is the body of GET /admin/config: every on-chain setting, null when never set
+type ConfigResponse struct {
+	Retention *client.RetentionPolicy `json:"retention"`
+}
+
+// ConfigChangeResponse is the body of a change to an on-chain setting, made or, as a dry run,
+// checked by the chaincode without being recorded
+type ConfigChangeResponse struct {
+	Setting  string      `json:"setting"`
+	Current  interface{} `json:"current"`
+	Proposed interface{} `json:"proposed"`
+	// Changes lists the fields the change sets to another value
+	Changes []client.SettingChange `json:"changes"`
+	DryRun  bool                   `json:"dryRun"`
+	// Applied reports whether the change was recorded; a change without differences is not submitted
+	Applied       bool   `json:"applied"`
+	TransactionID string `json:"transactionId,omitempty"`
+	BlockNumber   uint64 `json:"blockNumber,omitempty"`
+}
+
+// configurer answers 404 when the backend does not manage on-chain settings
+func (s *Server) configurer(w http.ResponseWriter) (configurer, bool) {
+	c, ok := s.backend.(configurer)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("on-chain configuration is not managed by this server"))
+	}
+	return c, ok
+}
+
+func (s *Server) getConfig(w http.ResponseWriter, r *http.Request) {
+	c, ok := s.configurer(w)
+	if !ok {
+		return
+	}
+
+	retention, err := currentRetentionPolicy(r.Context(), c)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ConfigResponse{Retention: retention})
+}
+
+func (s *Server) setRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	c, ok := s.configurer(w)
+	if !ok {
+		return
+	}
+
+	var policy client.RetentionPolicy
+	if err := s.decode(w, r, &policy); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	// The chaincode records when and by whom the policy was set
+	policy.UpdatedAt, policy.UpdatedBy = "", ""
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	annotateAccess(r, func(record *AccessRecord) { record.Target = retentionSetting })
+
+	current, err := currentRetentionPolicy(r.Context(), c)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+	response := ConfigChangeResponse{
+		Setting:  retentionSetting,
+		Current:  current,
+		Proposed: policy,
+		Changes:  policy.Changes(current),
+		DryRun:   dryRun,
+	}
+
+	switch {
+	case dryRun:
+		err = c.CheckRetentionPolicy(r.Context(), policy)
+	case len(response.Changes) > 0:
+		var result *client.SubmitResult
+		if result, err = c.SetRetentionPolicy(r.Context(), policy); err == nil {
+			response.Applied = true
+			response.TransactionID, response.BlockNumber = result.TransactionID, result.BlockNumber
+		}
+	}
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// currentRetentionPolicy returns the retention policy on the ledger, or nil when none has been set
+func currentRetentionPolicy(ctx context.Context, c configurer) (*client.RetentionPolicy, error) {
+	policy, err := c.GetRetentionPolicy(ctx)
+	if errors.Is(err, client.ErrNoPolicy) {
+		return nil, nil
+	}
+	return policy, err
+}
@@ -0,0 +1,499 @@
+package rest
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// apiKeyHeader carries API keys in requests
+const apiKeyHeader = "X-API-Key"
+
+// apiKeyPrefix starts every API key, so leaked keys are easy to recognise
+const apiKeyPrefix = "fl_"
+
+// ErrAPIKeyNotFound is returned for operations on an API key that does not exist
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+// Scope limits what an API key may do. Each scope grants the key a role.
+type Scope string
+
+// Scopes of API keys, granting RoleReader, RoleWriter, RoleAuditor and RoleAdmin
+const (
+	ScopeRead  Scope = "read"
+	ScopeWrite Scope = "write"
+	ScopeAudit Scope = "audit"
+	ScopeAdmin Scope = "admin"
+)
+
+// APIKey is a credential for a machine client. Only a hash of its secret is stored.
+type APIKey struct {
+	ID     string  `json:"id"`
+	Name   string  `json:"name"`
+	UserID string  `json:"userId,omitempty"`
+	Scopes []Scope `json:"scopes"`
+	// Tenant is the tenant the key belongs to, when the gateway serves several
+	Tenant string `json:"tenant,omitempty"`
+
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	RotatedAt *time.Time `json:"rotatedAt,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+
+	SecretHash string `json:"secretHash"`
+	// PreviousSecretHash is the secret replaced by the last rotation, accepted until PreviousExpiresAt
+	PreviousSecretHash string     `json:"previousSecretHash,omitempty"`
+	PreviousExpiresAt  *time.Time `json:"previousExpiresAt,omitempty"`
+}
+
+// APIKeyStore persists API keys
+type APIKeyStore interface {
+	// Get returns the key with id, or an error wrapping ErrAPIKeyNotFound
+	Get(id string) (*APIKey, error)
+	// Put creates or replaces a key
+	Put(key APIKey) error
+	// List returns every key, revoked keys included
+	List() ([]APIKey, error)
+}
+
+// APIKeys issues, rotates and revokes API keys, and is the Authenticator of requests carrying one
+// in the X-API-Key header
+type APIKeys struct {
+	store APIKeyStore
+	now   func() time.Time
+	// mu serializes changes, so concurrent rotations of a key cannot lose one another
+	mu sync.Mutex
+}
+
+// NewAPIKeys returns an API key manager keeping its keys in store
+func NewAPIKeys(store APIKeyStore) *APIKeys {
+	return &APIKeys{store: store, now: time.Now}
+}
+
+// Issue creates a key and returns it with its secret token, which is shown only this once. A key
+// with a userID acts as that user; without one it may record logs of any user. A ttl of zero
+// issues a key that does not expire.
+func (k *APIKeys) Issue(name string, userID string, scopes []Scope, ttl time.Duration) (string, *APIKey, error) {
+	return k.IssueForTenant("", name, userID, scopes, ttl)
+}
+
+// IssueForTenant issues a key as Issue does, belonging to tenant
+func (k *APIKeys) IssueForTenant(tenant string, name string, userID string, scopes []Scope, ttl time.Duration) (string, *APIKey, error) {
+	if name == "" {
+		return "", nil, fmt.Errorf("an API key needs a name")
+	}
+	if len(scopes) == 0 {
+		return "", nil, fmt.Errorf("an API key needs at least one scope")
+	}
+	for _, scope := range scopes {
+		if _, ok := scopeRoles[scope]; !ok {
+			return "", nil, fmt.Errorf("unknown scope %s", scope)
+		}
+	}
+
+	id, err := randomBytes(8)
+	if err != nil {
+		return "", nil, err
+	}
+	secret, err := newSecret()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := k.now().UTC()
+	key := APIKey{
+		ID:         hex.EncodeToString(id),
+		Name:       name,
+		UserID:     userID,
+		Scopes:     scopes,
+		Tenant:     tenant,
+		CreatedAt:  now,
+		SecretHash: hashSecret(secret),
+	}
+	if ttl > 0 {
+		expires := now.Add(ttl)
+		key.ExpiresAt = &expires
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err := k.store.Put(key); err != nil {
+		return "", nil, err
+	}
+
+	return apiKeyPrefix + key.ID + "_" + secret, &key, nil
+}
+
+// Rotate gives the key a new secret and returns its token. The old secret keeps working for grace,
+// so clients can be redeployed with the new one without downtime.
+func (k *APIKeys) Rotate(id string, grace time.Duration) (string, *APIKey, error) {
+	secret, err := newSecret()
+	if err != nil {
+		return "", nil, err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	key, err := k.store.Get(id)
+	if err != nil {
+		return "", nil, err
+	}
+	if key.RevokedAt != nil {
+		return "", nil, fmt.Errorf("API key %s is revoked", id)
+	}
+
+	now := k.now().UTC()
+	key.PreviousSecretHash, key.PreviousExpiresAt = "", nil
+	if grace > 0 {
+		previousExpires := now.Add(grace)
+		key.PreviousSecretHash = key.SecretHash
+		key.PreviousExpiresAt = &previousExpires
+	}
+	key.SecretHash = hashSecret(secret)
+	key.RotatedAt = &now
+	if err := k.store.Put(*key); err != nil {
+		return "", nil, err
+	}
+
+	return apiKeyPrefix + key.ID + "_" + secret, key, nil
+}
+
+// Revoke disables the key immediately. Revoked keys are kept, so their use remains attributable.
+func (k *APIKeys) Revoke(id string) (*APIKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	key, err := k.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt == nil {
+		now := k.now().UTC()
+		key.RevokedAt = &now
+		if err := k.store.Put(*key); err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+// List returns every key, ordered by creation
+func (k *APIKeys) List() ([]APIKey, error) {
+	keys, err := k.store.List()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+
+	return keys, nil
+}
+
+// Authenticate checks the request's X-API-Key header
+func (k *APIKeys) Authenticate(r *http.Request) (*Principal, error) {
+	token := r.Header.Get(apiKeyHeader)
+	if token == "" {
+		return nil, fmt.Errorf("%w: no API key", ErrNoCredentials)
+	}
+
+	id, secret, ok := strings.Cut(strings.TrimPrefix(token, apiKeyPrefix), "_")
+	if !ok || !strings.HasPrefix(token, apiKeyPrefix) {
+		return nil, fmt.Errorf("%w: malformed API key", ErrUnauthenticated)
+	}
+	key, err := k.store.Get(id)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, fmt.Errorf("%w: unknown API key", ErrUnauthenticated)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := k.now()
+	hash := hashSecret(secret)
+	valid := subtle.ConstantTimeCompare([]byte(hash), []byte(key.SecretHash)) == 1
+	if !valid && key.PreviousSecretHash != "" && key.PreviousExpiresAt != nil && now.Before(*key.PreviousExpiresAt) {
+		valid = subtle.ConstantTimeCompare([]byte(hash), []byte(key.PreviousSecretHash)) == 1
+	}
+	switch {
+	case !valid:
+		return nil, fmt.Errorf("%w: invalid API key", ErrUnauthenticated)
+	case key.RevokedAt != nil:
+		return nil, fmt.Errorf("%w: API key %s is revoked", ErrUnauthenticated, key.ID)
+	case key.ExpiresAt != nil && !now.Before(*key.ExpiresAt):
+		return nil, fmt.Errorf("%w: API key %s is expired", ErrUnauthenticated, key.ID)
+	}
+
+	principal := &Principal{Subject: "apikey:" + key.ID, UserID: key.UserID, Tenant: key.Tenant}
+	for _, scope := range key.Scopes {
+		principal.Roles = append(principal.Roles, scopeRoles[scope])
+	}
+
+	return principal, nil
+}
+
+func hashSecret(secret string) string {
+	digest := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(digest[:])
+}
+
+func newSecret() (string, error) {
+	b, err := randomBytes(32)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate API key: %v", err)
+	}
+	return b, nil
+}
+
+// apiKeyBucket is the bolt bucket holding API keys, keyed by ID
+var apiKeyBucket = []byte("apikeys")
+
+// BoltAPIKeyStore keeps API keys in a bolt database
+type BoltAPIKeyStore struct {
+	db *bolt.DB
+}
+
+// NewBoltAPIKeyStore returns a store keeping API keys in db. The caller owns db and closes it.
+func NewBoltAPIKeyStore(db *bolt.DB) (*BoltAPIKeyStore, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(apiKeyBucket)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API key bucket: %v", err)
+	}
+
+	return &BoltAPIKeyStore{db: db}, nil
+}
+
+// Get reads the key with id
+func (b *BoltAPIKeyStore) Get(id string) (*APIKey, error) {
+	var key *APIKey
+	err := b.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(apiKeyBucket).Get([]byte(id))
+		if value == nil {
+			return nil
+		}
+		key = &APIKey{}
+		return json.Unmarshal(value, key)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API key: %v", err)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%w: %s", ErrAPIKeyNotFound, id)
+	}
+
+	return key, nil
+}
+
+// Put writes key
+func (b *BoltAPIKeyStore) Put(key APIKey) error {
+	keyJSON, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(apiKeyBucket).Put([]byte(key.ID), keyJSON)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write API key: %v", err)
+	}
+
+	return nil
+}
+
+// List reads every key
+func (b *BoltAPIKeyStore) List() ([]APIKey, error) {
+	var keys []APIKey
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(apiKeyBucket).ForEach(func(_, value []byte) error {
+			var key APIKey
+			if err := json.Unmarshal(value, &key); err != nil {
+				return err
+			}
+			keys = append(keys, key)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API keys: %v", err)
+	}
+
+	return keys, nil
+}
+
+// apiKeyView is an APIKey as the administration endpoints return it, without secret hashes
+type apiKeyView struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	UserID    string     `json:"userId,omitempty"`
+	Scopes    []Scope    `json:"scopes"`
+	Tenant    string     `json:"tenant,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	RotatedAt *time.Time `json:"rotatedAt,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+type issuedAPIKey struct {
+	Token string     `json:"token"`
+	Key   apiKeyView `json:"key"`
+}
+
+func newAPIKeyView(key *APIKey) apiKeyView {
+	return apiKeyView{
+		ID:        key.ID,
+		Name:      key.Name,
+		UserID:    key.UserID,
+		Scopes:    key.Scopes,
+		Tenant:    key.Tenant,
+		CreatedAt: key.CreatedAt,
+		ExpiresAt: key.ExpiresAt,
+		RotatedAt: key.RotatedAt,
+		RevokedAt: key.RevokedAt,
+	}
+}
+
+// apiKeysEnabled answers 404 when the server has no API keys to administer
+func (s *Server) apiKeysEnabled(w http.ResponseWriter) bool {
+	if s.apiKeys == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("API keys are not enabled"))
+		return false
+	}
+	return true
+}
+
+func (s *Server) listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if !s.apiKeysEnabled(w) {
+		return
+	}
+
+	keys, err := s.apiKeys.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	views := []apiKeyView{}
+	for i := range keys {
+		// The admins of a tenant see the keys of their tenant only
+		if keys[i].Tenant == s.tenant {
+			views = append(views, newAPIKeyView(&keys[i]))
+		}
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+func (s *Server) issueAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !s.apiKeysEnabled(w) {
+		return
+	}
+
+	var request struct {
+		Name       string  `json:"name"`
+		UserID     string  `json:"userId"`
+		Scopes     []Scope `json:"scopes"`
+		TTLSeconds int64   `json:"ttlSeconds"`
+	}
+	if err := s.decode(w, r, &request); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	token, key, err := s.apiKeys.IssueForTenant(s.tenant, request.Name, request.UserID, request.Scopes, time.Duration(request.TTLSeconds)*time.Second)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	annotateAccess(r, func(record *AccessRecord) { record.Target = key.ID })
+	writeJSON(w, http.StatusCreated, issuedAPIKey{Token: token, Key: newAPIKeyView(key)})
+}
+
+func (s *Server) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !s.apiKeysEnabled(w) {
+		return
+	}
+
+	if err := s.tenantAPIKey(pathParam(r, "id")); err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+	key, err := s.apiKeys.Revoke(pathParam(r, "id"))
+	if err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newAPIKeyView(key))
+}
+
+func (s *Server) rotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !s.apiKeysEnabled(w) {
+		return
+	}
+
+	var request struct {
+		GracePeriodSeconds int64 `json:"gracePeriodSeconds"`
+	}
+	if r.ContentLength != 0 {
+		if err := s.decode(w, r, &request); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	if err := s.tenantAPIKey(pathParam(r, "id")); err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+	token, key, err := s.apiKeys.Rotate(pathParam(r, "id"), time.Duration(request.GracePeriodSeconds)*time.Second)
+	if err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, issuedAPIKey{Token: token, Key: newAPIKeyView(key)})
+}
+
+// tenantAPIKey returns an error wrapping ErrAPIKeyNotFound unless the key with id exists and
+// belongs to the server's tenant, so tenants cannot tell the keys of others exist
+func (s *Server) tenantAPIKey(id string) error {
+	key, err := s.apiKeys.store.Get(id)
+	if err != nil {
+		return err
+	}
+	if key.Tenant != s.tenant {
+		return fmt.Errorf("%w: %s", ErrAPIKeyNotFound, id)
+	}
+	return nil
+}
+
+func writeAPIKeyError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		code = http.StatusNotFound
+	}
+	writeError(w, code, err)
+}
@@ -0,0 +1,208 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// AccessAction is the action of the logs a LedgerAuditor records
+const AccessAction = "API_ACCESS"
+
+// AccessRecord describes a request to an operation the OpenAPI document marks with x-audit,
+// whether it succeeded or not
+type AccessRecord struct {
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	// Target is the ID of the API key or export job the request acted on
+	Target     string `json:"target,omitempty"`
+	Subject    string `json:"subject,omitempty"`
+	UserID     string `json:"userId,omitempty"`
+	Tenant     string `json:"tenant,omitempty"`
+	RemoteAddr string `json:"remoteAddr,omitempty"`
+	Status     int    `json:"status"`
+	// Filter is the criteria of the logs exported, with the names of the GET /logs parameters
+	Filter map[string]string `json:"filter,omitempty"`
+	// Records is the number of logs the request returned, counting the whole export for a download
+	Records *int `json:"records,omitempty"`
+}
+
+// AccessAuditor records the audited requests of a Server
+type AccessAuditor interface {
+	RecordAccess(ctx context.Context, record AccessRecord) error
+}
+
+// auditAll is an AccessAuditor passing each record to several auditors
+type auditAll []AccessAuditor
+
+// AuditAll returns an AccessAuditor passing each record to every one of auditors
+func AuditAll(auditors ...AccessAuditor) AccessAuditor {
+	var set auditAll
+	for _, a := range auditors {
+		if a != nil {
+			set = append(set, a)
+		}
+	}
+	return set
+}
+
+// RecordAccess records to every auditor, even when some fail
+func (a auditAll) RecordAccess(ctx context.Context, record AccessRecord) error {
+	var errs []error
+	for _, auditor := range a {
+		errs = append(errs, auditor.RecordAccess(ctx, record))
+	}
+	return errors.Join(errs...)
+}
+
+// AccessLog is an AccessAuditor writing each record as a line of JSON
+type AccessLog struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewAccessLog returns an AccessLog writing to w
+func NewAccessLog(w io.Writer) *AccessLog {
+	return &AccessLog{encoder: json.NewEncoder(w)}
+}
+
+// RecordAccess writes record to the log
+func (a *AccessLog) RecordAccess(ctx context.Context, record AccessRecord) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.encoder.Encode(record); err != nil {
+		return fmt.Errorf("failed to write access log: %v", err)
+	}
+	return nil
+}
+
+// LedgerAuditor is an AccessAuditor recording each access as an API_ACCESS log on the ledger, so
+// access to the logs is audited by the same ledger. The log is recorded for the caller's user ID,
+// or its subject, and its metadata holds the whole record.
+type LedgerAuditor struct {
+	submitter  client.Submitter
+	generateID client.IDGenerator
+}
+
+// NewLedgerAuditor returns a LedgerAuditor recording through submitter, usually a
+// client.BatchingSubmitter so that audited requests do not wait for a transaction
+func NewLedgerAuditor(submitter client.Submitter) *LedgerAuditor {
+	return &LedgerAuditor{submitter: submitter, generateID: client.UUIDv7()}
+}
+
+// RecordAccess records record as a log
+func (a *LedgerAuditor) RecordAccess(ctx context.Context, record AccessRecord) error {
+	metadata, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode access record: %v", err)
+	}
+
+	log := client.LogEvent{
+		UserID:      record.UserID,
+		Action:      AccessAction,
+		Resource:    record.Path,
+		Description: fmt.Sprintf("%s %s answered %d", record.Method, record.Path, record.Status),
+		Metadata:    string(metadata),
+	}
+	switch {
+	case log.UserID != "":
+	case record.Subject != "":
+		log.UserID = record.Subject
+	default:
+		log.UserID = "anonymous"
+	}
+	if log.ID, err = a.generateID(log); err != nil {
+		return fmt.Errorf("failed to generate log ID: %v", err)
+	}
+
+	if err := a.submitter.CreateLog(ctx, log); err != nil {
+		return fmt.Errorf("failed to record access: %v", err)
+	}
+	return nil
+}
+
+// accessKey is the context key of the AccessRecord of an audited request
+type accessKey struct{}
+
+// annotateAccess lets a handler add to the record of its request, when the request is audited
+func annotateAccess(r *http.Request, annotate func(record *AccessRecord)) {
+	if record, ok := r.Context().Value(accessKey{}).(*AccessRecord); ok {
+		annotate(record)
+	}
+}
+
+// auditFilter converts query parameters to the Filter of an AccessRecord
+func auditFilter(values url.Values) map[string]string {
+	filter := map[string]string{}
+	for name := range values {
+		filter[name] = values.Get(name)
+	}
+	return filter
+}
+
+// statusWriter remembers the status code of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// audit starts the AccessRecord of a request to an audited operation, returning the writer and
+// request to serve it with and a function recording it once the handler returns
+func (s *Server) audit(w http.ResponseWriter, r *http.Request, op *operation, params map[string]string) (http.ResponseWriter, *http.Request, func(r *http.Request)) {
+	record := &AccessRecord{
+		Time:       time.Now().UTC(),
+		Operation:  op.id,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Target:     params["id"],
+		RemoteAddr: r.RemoteAddr,
+	}
+	writer := &statusWriter{ResponseWriter: w}
+	r = r.WithContext(context.WithValue(r.Context(), accessKey{}, record))
+
+	return writer, r, func(r *http.Request) {
+		record.Status = writer.status
+		if record.Status == 0 {
+			record.Status = http.StatusOK
+		}
+		if principal := PrincipalFrom(r.Context()); principal != nil {
+			record.Subject, record.UserID, record.Tenant = principal.Subject, principal.UserID, principal.Tenant
+		}
+
+		// The record must not be lost to the client disconnecting
+		ctx := context.WithoutCancel(r.Context())
+		if err := s.auditor.RecordAccess(ctx, *record); err != nil && s.auditErrors != nil {
+			s.auditErrors(*record, err)
+		}
+	}
+}
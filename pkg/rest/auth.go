@@ -0,0 +1,419 @@
+package rest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// ErrUnauthenticated is wrapped by errors of Authenticators for requests without valid credentials
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// ErrNoCredentials is wrapped by errors of Authenticators for requests carrying none of the
+// credentials they check, as opposed to invalid ones
+var ErrNoCredentials = fmt.Errorf("%w: no credentials", ErrUnauthenticated)
+
+// minKeyRefreshInterval limits how often an unknown key ID makes the OIDCAuthenticator fetch the
+// provider's keys again, so forged tokens cannot flood the provider
+const minKeyRefreshInterval = time.Minute
+
+// signingMethods are the asymmetric JWT algorithms accepted; symmetric and unsigned tokens never are
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	// Subject identifies the caller to its authenticator, such as the sub claim of a token
+	Subject string
+	// UserID is the user the caller acts as, recorded in its logs when Options.BindUserID is set
+	UserID string
+	// Claims holds the claims of the caller's token, if any
+	Claims map[string]interface{}
+	// Roles limits the operations the caller may use
+	Roles []Role
+	// Tenant is the tenant the caller belongs to, which a TenantRouter routes its requests to
+	Tenant string
+}
+
+// HasRole reports whether the caller holds any of roles. Admins hold every role.
+func (p *Principal) HasRole(roles ...Role) bool {
+	for _, held := range p.Roles {
+		if held == RoleAdmin {
+			return true
+		}
+		for _, role := range roles {
+			if held == role {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Authenticator identifies the caller of a request. It returns an error wrapping
+// ErrUnauthenticated when the request carries no valid credentials.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+type principalKey struct{}
+
+// anyOf is an Authenticator accepting the credentials of any of several authenticators
+type anyOf []Authenticator
+
+// AnyOf returns an Authenticator trying each of authenticators in turn. A request presenting
+// invalid credentials to one of them is rejected without trying the rest.
+func AnyOf(authenticators ...Authenticator) Authenticator {
+	var set anyOf
+	for _, a := range authenticators {
+		if a != nil {
+			set = append(set, a)
+		}
+	}
+	return set
+}
+
+// Authenticate returns the principal of the first authenticator finding its credentials
+func (a anyOf) Authenticate(r *http.Request) (*Principal, error) {
+	for _, authenticator := range a {
+		principal, err := authenticator.Authenticate(r)
+		if !errors.Is(err, ErrNoCredentials) {
+			return principal, err
+		}
+	}
+	return nil, ErrNoCredentials
+}
+
+// queryCredentials moves an access_token query parameter into the header its authenticator reads,
+// for clients such as browser WebSockets that cannot set headers. API keys are told from bearer
+// tokens by their prefix.
+func queryCredentials(r *http.Request) {
+	token := r.URL.Query().Get("access_token")
+	if token == "" || r.Header.Get("Authorization") != "" || r.Header.Get(apiKeyHeader) != "" {
+		return
+	}
+	if strings.HasPrefix(token, apiKeyPrefix) {
+		r.Header.Set(apiKeyHeader, token)
+	} else {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// PrincipalFrom returns the authenticated caller of the request with ctx, or nil when the server
+// has no Authenticator or the operation is public
+func PrincipalFrom(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
+
+// OIDCOptions configures an OIDCAuthenticator
+type OIDCOptions struct {
+	// Issuer is the provider's issuer URL. Tokens must carry it as their iss claim, and the
+	// provider's keys are discovered from its /.well-known/openid-configuration.
+	Issuer string
+	// JWKSURL names the provider's key set directly, skipping discovery
+	JWKSURL string
+	// Audience must appear in the aud claim of every token
+	Audience string
+	// UserClaim is the claim holding the user ID of the caller; sub is used when empty
+	UserClaim string
+	// RoleClaim is the claim holding the roles of the caller, a dotted path for nested claims
+	// such as realm_access.roles. Every caller gets DefaultRoles when empty.
+	RoleClaim string
+	// RoleMapping translates values of RoleClaim, such as group names, to roles. When nil the
+	// values are taken as role names.
+	RoleMapping map[string]Role
+	// DefaultRoles are given to every caller when RoleClaim is empty; reader and writer when nil.
+	// With RoleClaim set, callers whose claim maps to no role get none, and are refused.
+	DefaultRoles []Role
+	// TenantClaim is the claim holding the tenant of the caller, for a TenantRouter; callers
+	// belong to no tenant when empty
+	TenantClaim string
+	// Leeway tolerates clock skew when checking exp, nbf and iat
+	Leeway time.Duration
+	// HTTPClient fetches the discovery document and keys; http.DefaultClient is used when nil
+	HTTPClient *http.Client
+}
+
+// OIDCAuthenticator authenticates requests by an OpenID Connect bearer token, a JWT signed by
+// one of the provider's published keys
+type OIDCAuthenticator struct {
+	options OIDCOptions
+	parser  *jwt.Parser
+
+	mu      sync.Mutex
+	keys    map[string]interface{}
+	fetched time.Time
+}
+
+// NewOIDCAuthenticator discovers the provider's keys and returns an authenticator for its tokens.
+// Keys are fetched again when a token names a key ID not seen before, so key rotation is followed.
+func NewOIDCAuthenticator(ctx context.Context, options OIDCOptions) (*OIDCAuthenticator, error) {
+	if options.Issuer == "" {
+		return nil, fmt.Errorf("no OIDC issuer configured")
+	}
+	if options.Audience == "" {
+		return nil, fmt.Errorf("no OIDC audience configured")
+	}
+	if options.UserClaim == "" {
+		options.UserClaim = "sub"
+	}
+	if options.DefaultRoles == nil {
+		options.DefaultRoles = defaultRoles
+	}
+	if options.HTTPClient == nil {
+		options.HTTPClient = http.DefaultClient
+	}
+
+	if options.JWKSURL == "" {
+		var discovery struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := getJSON(ctx, options.HTTPClient, strings.TrimSuffix(options.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+			return nil, fmt.Errorf("failed to discover OIDC provider: %v", err)
+		}
+		if discovery.Issuer != options.Issuer {
+			return nil, fmt.Errorf("OIDC provider reports issuer %s instead of %s", discovery.Issuer, options.Issuer)
+		}
+		if discovery.JWKSURI == "" {
+			return nil, fmt.Errorf("OIDC provider %s publishes no jwks_uri", options.Issuer)
+		}
+		options.JWKSURL = discovery.JWKSURI
+	}
+
+	a := &OIDCAuthenticator{
+		options: options,
+		parser:  jwt.NewParser(jwt.WithValidMethods(signingMethods), jwt.WithoutClaimsValidation()),
+	}
+	if err := a.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Authenticate verifies the request's bearer token and returns the caller it names
+func (a *OIDCAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, fmt.Errorf("%w: no bearer token", ErrNoCredentials)
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := a.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return a.key(r.Context(), kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+	if err := a.verifyClaims(claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+
+	principal := &Principal{Claims: claims}
+	principal.Subject, _ = claims["sub"].(string)
+	principal.UserID, _ = claims[a.options.UserClaim].(string)
+	if principal.UserID == "" {
+		return nil, fmt.Errorf("%w: the token has no %s claim", ErrUnauthenticated, a.options.UserClaim)
+	}
+	if a.options.RoleClaim != "" {
+		principal.Roles = rolesFromClaims(claims, a.options.RoleClaim, a.options.RoleMapping)
+	} else {
+		principal.Roles = a.options.DefaultRoles
+	}
+	if a.options.TenantClaim != "" {
+		principal.Tenant, _ = claims[a.options.TenantClaim].(string)
+	}
+
+	return principal, nil
+}
+
+func (a *OIDCAuthenticator) verifyClaims(claims jwt.MapClaims) error {
+	now := time.Now()
+	leeway := int64(a.options.Leeway / time.Second)
+	if !claims.VerifyIssuer(a.options.Issuer, true) {
+		return fmt.Errorf("the token was not issued by %s", a.options.Issuer)
+	}
+	if !claims.VerifyAudience(a.options.Audience, true) {
+		return fmt.Errorf("the token is not intended for %s", a.options.Audience)
+	}
+	if !claims.VerifyExpiresAt(now.Unix()-leeway, true) {
+		return fmt.Errorf("the token is expired")
+	}
+	if !claims.VerifyNotBefore(now.Unix()+leeway, false) || !claims.VerifyIssuedAt(now.Unix()+leeway, false) {
+		return fmt.Errorf("the token is not valid yet")
+	}
+
+	return nil
+}
+
+// key returns the verification key named kid, fetching the key set again when kid is unknown.
+// A token without a key ID is accepted when the provider publishes a single key.
+func (a *OIDCAuthenticator) key(ctx context.Context, kid string) (interface{}, error) {
+	a.mu.Lock()
+	key, ok := a.lookup(kid)
+	stale := time.Since(a.fetched) >= minKeyRefreshInterval
+	a.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	if stale {
+		if err := a.refresh(ctx); err != nil {
+			return nil, err
+		}
+		a.mu.Lock()
+		key, ok = a.lookup(kid)
+		a.mu.Unlock()
+		if ok {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookup returns the key named kid. The caller holds mu.
+func (a *OIDCAuthenticator) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(a.keys) == 1 {
+		for _, key := range a.keys {
+			return key, true
+		}
+	}
+	key, ok := a.keys[kid]
+	return key, ok
+}
+
+// refresh fetches the provider's key set. Keys of unsupported types or uses are skipped.
+func (a *OIDCAuthenticator) refresh(ctx context.Context) error {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := getJSON(ctx, a.options.HTTPClient, a.options.JWKSURL, &set); err != nil {
+		return fmt.Errorf("failed to fetch OIDC signing keys: %v", err)
+	}
+
+	keys := map[string]interface{}{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("OIDC provider publishes no usable signing keys at %s", a.options.JWKSURL)
+	}
+
+	a.mu.Lock()
+	a.keys = keys
+	a.fetched = time.Now()
+	a.mu.Unlock()
+
+	return nil
+}
+
+// jsonWebKey is a public key of a JWK set (RFC 7517)
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+	}
+}
+
+func getJSON(ctx context.Context, httpClient *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
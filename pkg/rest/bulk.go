@@ -0,0 +1,158 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// BulkResponse reports the outcome of every line of a POST /logs/bulk body
+type BulkResponse struct {
+	Accepted int          `json:"accepted"`
+	Rejected int          `json:"rejected"`
+	Results  []BulkResult `json:"results"`
+}
+
+// BulkResult is the outcome of one line of a bulk body. Status is the HTTP status the log would
+// have been answered with on its own.
+type BulkResult struct {
+	// Line is the line number in the body, starting at 1
+	Line          int    `json:"line"`
+	ID            string `json:"id,omitempty"`
+	Status        int    `json:"status"`
+	Error         string `json:"error,omitempty"`
+	TransactionID string `json:"transactionId,omitempty"`
+	BlockNumber   uint64 `json:"blockNumber,omitempty"`
+}
+
+// bulkBatch is the logs of a bulk body awaiting submission, with the indexes of their results
+type bulkBatch struct {
+	logs    []client.LogEvent
+	results []int
+}
+
+// createLogsBulk records the logs of an NDJSON body, one log per line. Lines are validated as they
+// are read, and valid logs are submitted in batches of up to Options.BulkBatchSize while the body
+// is still streaming. A batch the chaincode rejects fails every log in it, but no other.
+func (s *Server) createLogsBulk(w http.ResponseWriter, r *http.Request) {
+	op := operationOf(r)
+	response := BulkResponse{Results: []BulkResult{}}
+	var batch bulkBatch
+	seen := map[string]bool{}
+
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(nil, int(s.maxBodyBytes))
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		result := BulkResult{Line: line}
+		log, err := s.bulkLog(r.Context(), op, data)
+		result.ID = log.ID
+		// A repeated ID would fail the whole batch it is submitted in, so it fails on its own
+		if err == nil && seen[log.ID] {
+			err = fmt.Errorf("%w: the log %s appears more than once in the body", client.ErrAlreadyExists, log.ID)
+		}
+		if err != nil {
+			result.Status, result.Error = bulkStatus(err), err.Error()
+			response.Results = append(response.Results, result)
+			continue
+		}
+		seen[log.ID] = true
+		response.Results = append(response.Results, result)
+		batch.logs = append(batch.logs, log)
+		batch.results = append(batch.results, len(response.Results)-1)
+
+		if len(batch.logs) >= s.bulkBatchSize {
+			s.submitBulk(r.Context(), &batch, response.Results)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		// The rest of the body cannot be read, so it is reported as a single failed line
+		code := http.StatusBadRequest
+		if errors.Is(err, bufio.ErrTooLong) {
+			code = http.StatusRequestEntityTooLarge
+			err = fmt.Errorf("%w: lines are limited to %d bytes", errBodyTooLarge, s.maxBodyBytes)
+		}
+		response.Results = append(response.Results, BulkResult{Line: line + 1, Status: code, Error: err.Error()})
+	}
+	s.submitBulk(r.Context(), &batch, response.Results)
+
+	for _, result := range response.Results {
+		if result.Status == http.StatusCreated {
+			response.Accepted++
+		} else {
+			response.Rejected++
+		}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// bulkLog decodes and validates one line of a bulk body
+func (s *Server) bulkLog(ctx context.Context, op *operation, data []byte) (client.LogEvent, error) {
+	var log client.LogEvent
+	if err := op.validateLine(data); err != nil {
+		return log, fmt.Errorf("%w: %v", client.ErrInvalidLog, err)
+	}
+	if err := json.Unmarshal(data, &log); err != nil {
+		return log, fmt.Errorf("%w: %v", client.ErrInvalidLog, err)
+	}
+	if err := s.bindUser(ctx, &log); err != nil {
+		return log, fmt.Errorf("%w: %v", client.ErrUnauthorized, err)
+	}
+	if err := s.assignID(&log); err != nil {
+		return log, err
+	}
+
+	return log, client.ValidateLog(log)
+}
+
+// submitBulk submits the pending logs of a bulk body in one transaction and records its outcome
+// in their results
+func (s *Server) submitBulk(ctx context.Context, batch *bulkBatch, results []BulkResult) {
+	if len(batch.logs) == 0 {
+		return
+	}
+
+	var result *client.SubmitResult
+	var err error
+	if sub, ok := s.backend.(submitter); ok {
+		result, err = sub.SubmitLogsBatch(ctx, batch.logs)
+		if errors.Is(err, client.ErrSampledOut) {
+			err = nil
+		}
+	} else {
+		err = s.backend.CreateLogsBatch(ctx, batch.logs)
+	}
+
+	for _, i := range batch.results {
+		if err != nil {
+			results[i].Status, results[i].Error = statusCode(err), err.Error()
+			continue
+		}
+		results[i].Status = http.StatusCreated
+		if result != nil {
+			results[i].TransactionID = result.TransactionID
+			results[i].BlockNumber = result.BlockNumber
+		}
+	}
+	*batch = bulkBatch{}
+}
+
+// bulkStatus maps the errors of lines rejected before submission to HTTP status codes
+func bulkStatus(err error) int {
+	if errors.Is(err, client.ErrInvalidLog) || errors.Is(err, client.ErrUnauthorized) || errors.Is(err, client.ErrAlreadyExists) {
+		return statusCode(err)
+	}
+	return http.StatusInternalServerError
+}
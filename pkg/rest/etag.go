@@ -0,0 +1,66 @@
+package rest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// heightReporter is implemented by clients reporting the height of the channel's ledger
+type heightReporter interface {
+	BlockHeight(ctx context.Context) (uint64, error)
+}
+
+// contentETag is the strong ETag of a response body. Logs never change once committed, so the
+// ETag of a log stays valid for as long as the log exists.
+func contentETag(body []byte) string {
+	digest := sha256.Sum256(body)
+	return `"` + base64.RawURLEncoding.EncodeToString(digest[:16]) + `"`
+}
+
+// heightETag is the weak ETag of query results read at a ledger height. Results cannot change
+// before another block is committed, so they are revalidated by height alone.
+func heightETag(height uint64) string {
+	return fmt.Sprintf(`W/"h%d"`, height)
+}
+
+// queryETag returns the ETag of the results of r's query, or "" when the backend cannot report
+// the ledger height. The height is read before the query runs, so a commit racing the query can
+// only make the ETag older than the results, which costs the next poll a full response.
+func (s *Server) queryETag(r *http.Request) string {
+	reporter, ok := s.backend.(heightReporter)
+	if !ok {
+		return ""
+	}
+	height, err := reporter.BlockHeight(r.Context())
+	if err != nil {
+		return ""
+	}
+	return heightETag(height)
+}
+
+// notModified sets the ETag of a response and answers 304 when the If-None-Match header of r
+// already names it, using the weak comparison RFC 9110 requires for If-None-Match
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	header := strings.Join(r.Header.Values("If-None-Match"), ",")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "" {
+			continue
+		}
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
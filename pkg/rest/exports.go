@@ -0,0 +1,556 @@
+package rest
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/export"
+)
+
+// Defaults of ExportJobOptions
+const (
+	DefaultExportRetention   = 24 * time.Hour
+	DefaultExportConcurrency = 2
+)
+
+// exportJobFile holds the state of a job in its directory
+const exportJobFile = "job.json"
+
+// ErrExportNotFound is returned for operations on an export job that does not exist
+var ErrExportNotFound = errors.New("export not found")
+
+// ExportStatus is the state of an export job
+type ExportStatus string
+
+// States of export jobs
+const (
+	ExportPending   ExportStatus = "pending"
+	ExportRunning   ExportStatus = "running"
+	ExportSucceeded ExportStatus = "succeeded"
+	ExportFailed    ExportStatus = "failed"
+	ExportCanceled  ExportStatus = "canceled"
+)
+
+// ExportRequest is the body of POST /exports
+type ExportRequest struct {
+	Format export.Format `json:"format"`
+	Filter ExportFilter  `json:"filter"`
+	// Columns selects and orders the exported fields; every field is exported when empty
+	Columns []string `json:"columns,omitempty"`
+	// MaxRecordsPerFile splits the export into files of that many records; zero writes one file
+	MaxRecordsPerFile int `json:"maxRecordsPerFile,omitempty"`
+}
+
+// ExportFilter selects the exported logs, with the criteria of GET /logs
+type ExportFilter struct {
+	User        string `json:"user,omitempty"`
+	Action      string `json:"action,omitempty"`
+	Resource    string `json:"resource,omitempty"`
+	From        string `json:"from,omitempty"`
+	To          string `json:"to,omitempty"`
+	SeverityGTE string `json:"severityGte,omitempty"`
+	Tag         string `json:"tag,omitempty"`
+}
+
+// ExportJob is an export running in the background
+type ExportJob struct {
+	ID      string        `json:"id"`
+	Status  ExportStatus  `json:"status"`
+	Request ExportRequest `json:"request"`
+	// Owner is the subject of the caller that started the job; only it and admins may see the job
+	Owner       string       `json:"owner,omitempty"`
+	Records     int          `json:"records"`
+	Files       []ExportFile `json:"files,omitempty"`
+	Error       string       `json:"error,omitempty"`
+	CreatedAt   time.Time    `json:"createdAt"`
+	StartedAt   *time.Time   `json:"startedAt,omitempty"`
+	CompletedAt *time.Time   `json:"completedAt,omitempty"`
+}
+
+// ExportFile is a completed file of an export job
+type ExportFile struct {
+	Name string `json:"name"`
+	// URL is the path the file is downloaded from
+	URL string `json:"url"`
+}
+
+// ExportJobOptions configures ExportJobs
+type ExportJobOptions struct {
+	// Directory holds one subdirectory of files per job
+	Directory string
+	// Retention is how long the files of a finished job are kept; DefaultExportRetention is used when zero
+	Retention time.Duration
+	// Concurrency is the most jobs running at once; others wait as pending.
+	// DefaultExportConcurrency is used when zero.
+	Concurrency int
+}
+
+// ExportJobs runs the export jobs of POST /exports and keeps their files until they expire. Job
+// states are saved in the directory, so finished jobs survive a restart; jobs a restart
+// interrupted are reported as failed.
+type ExportJobs struct {
+	options ExportJobOptions
+	slots   chan struct{}
+
+	mu      sync.Mutex
+	jobs    map[string]*ExportJob
+	cancels map[string]context.CancelFunc
+	running sync.WaitGroup
+}
+
+// NewExportJobs returns the export jobs kept in options.Directory, creating it if needed
+func NewExportJobs(options ExportJobOptions) (*ExportJobs, error) {
+	if options.Directory == "" {
+		return nil, fmt.Errorf("no export directory configured")
+	}
+	if options.Retention <= 0 {
+		options.Retention = DefaultExportRetention
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = DefaultExportConcurrency
+	}
+	if err := os.MkdirAll(options.Directory, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %v", err)
+	}
+
+	e := &ExportJobs{
+		options: options,
+		slots:   make(chan struct{}, options.Concurrency),
+		jobs:    map[string]*ExportJob{},
+		cancels: map[string]context.CancelFunc{},
+	}
+
+	entries, err := os.ReadDir(options.Directory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read export directory: %v", err)
+	}
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(options.Directory, entry.Name(), exportJobFile))
+		if err != nil {
+			continue
+		}
+		var job ExportJob
+		if err := json.Unmarshal(data, &job); err != nil || job.ID != entry.Name() {
+			continue
+		}
+		if job.Status == ExportPending || job.Status == ExportRunning {
+			now := time.Now().UTC()
+			job.Status, job.Error, job.CompletedAt = ExportFailed, "interrupted by a restart", &now
+			if err := e.save(&job); err != nil {
+				return nil, err
+			}
+		}
+		e.jobs[job.ID] = &job
+	}
+
+	return e, nil
+}
+
+// Close cancels the running jobs and waits for them to stop
+func (e *ExportJobs) Close() error {
+	e.mu.Lock()
+	for _, cancel := range e.cancels {
+		cancel()
+	}
+	e.mu.Unlock()
+
+	e.running.Wait()
+	return nil
+}
+
+// start saves a pending job and runs it in the background
+func (e *ExportJobs) start(owner string, request ExportRequest, source export.Source) (*ExportJob, error) {
+	e.expire()
+
+	id, err := randomBytes(16)
+	if err != nil {
+		return nil, err
+	}
+	job := &ExportJob{
+		ID:        hex.EncodeToString(id),
+		Status:    ExportPending,
+		Request:   request,
+		Owner:     owner,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := os.Mkdir(e.dir(job.ID), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %v", err)
+	}
+	if err := e.save(job); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.mu.Lock()
+	e.jobs[job.ID] = job
+	e.cancels[job.ID] = cancel
+	snapshot := *job
+	e.mu.Unlock()
+
+	e.running.Add(1)
+	go e.run(ctx, job.ID, source)
+
+	return &snapshot, nil
+}
+
+func (e *ExportJobs) run(ctx context.Context, id string, source export.Source) {
+	defer e.running.Done()
+
+	select {
+	case e.slots <- struct{}{}:
+		defer func() { <-e.slots }()
+	case <-ctx.Done():
+		e.finish(id, nil, ctx.Err())
+		return
+	}
+
+	job := e.update(id, func(job *ExportJob) {
+		now := time.Now().UTC()
+		job.Status, job.StartedAt = ExportRunning, &now
+	})
+	if job == nil {
+		return
+	}
+
+	options := export.Options{
+		Format:            job.Request.Format,
+		Directory:         e.dir(id),
+		Prefix:            "logs",
+		MaxRecordsPerFile: job.Request.MaxRecordsPerFile,
+		Progress: func(progress export.Progress) {
+			e.mu.Lock()
+			if job, ok := e.jobs[id]; ok {
+				job.Records = progress.Records
+			}
+			e.mu.Unlock()
+		},
+	}
+	if len(job.Request.Columns) > 0 {
+		// The columns were checked when the job was created
+		options.Columns, _ = export.SelectColumns(job.Request.Columns...)
+	}
+
+	files, err := export.Export(ctx, source, options)
+	e.finish(id, files, err)
+}
+
+// finish records the outcome of a job
+func (e *ExportJobs) finish(id string, files []string, err error) {
+	e.update(id, func(job *ExportJob) {
+		now := time.Now().UTC()
+		job.CompletedAt = &now
+		job.Files = nil
+		for _, file := range files {
+			name := filepath.Base(file)
+			job.Files = append(job.Files, ExportFile{Name: name, URL: path.Join("/exports", id, "files", name)})
+		}
+		switch {
+		case errors.Is(err, context.Canceled):
+			job.Status = ExportCanceled
+		case err != nil:
+			job.Status, job.Error = ExportFailed, err.Error()
+		default:
+			job.Status = ExportSucceeded
+		}
+	})
+
+	e.mu.Lock()
+	if cancel, ok := e.cancels[id]; ok {
+		cancel()
+		delete(e.cancels, id)
+	}
+	e.mu.Unlock()
+}
+
+// update changes a job and saves it, returning a copy, or nil when the job was removed
+func (e *ExportJobs) update(id string, change func(job *ExportJob)) *ExportJob {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	job, ok := e.jobs[id]
+	if !ok {
+		return nil
+	}
+	change(job)
+	// A job whose state cannot be saved still runs; it is reported as failed after a restart
+	e.save(job)
+
+	snapshot := *job
+	return &snapshot
+}
+
+// get returns a copy of a job
+func (e *ExportJobs) get(id string) (*ExportJob, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	job, ok := e.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrExportNotFound, id)
+	}
+	snapshot := *job
+	return &snapshot, nil
+}
+
+// remove cancels a job if it is still running and deletes it with its files
+func (e *ExportJobs) remove(id string) error {
+	e.mu.Lock()
+	_, ok := e.jobs[id]
+	cancel := e.cancels[id]
+	delete(e.jobs, id)
+	delete(e.cancels, id)
+	e.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrExportNotFound, id)
+	}
+
+	if cancel != nil {
+		cancel()
+	}
+	if err := os.RemoveAll(e.dir(id)); err != nil {
+		return fmt.Errorf("failed to remove export files: %v", err)
+	}
+	return nil
+}
+
+// expire removes the jobs finished longer than the retention ago
+func (e *ExportJobs) expire() {
+	cutoff := time.Now().Add(-e.options.Retention)
+
+	e.mu.Lock()
+	var expired []string
+	for id, job := range e.jobs {
+		if job.CompletedAt != nil && job.CompletedAt.Before(cutoff) {
+			expired = append(expired, id)
+		}
+	}
+	e.mu.Unlock()
+
+	for _, id := range expired {
+		e.remove(id)
+	}
+}
+
+func (e *ExportJobs) dir(id string) string {
+	return filepath.Join(e.options.Directory, id)
+}
+
+// save writes the state of a job to its directory
+func (e *ExportJobs) save(job *ExportJob) error {
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(e.dir(job.ID), exportJobFile)
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return fmt.Errorf("failed to save export job: %v", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to save export job: %v", err)
+	}
+	return nil
+}
+
+// values returns the filter as the query parameters of GET /logs
+func (f ExportFilter) values() url.Values {
+	values := url.Values{}
+	for name, value := range map[string]string{
+		"user":         f.User,
+		"action":       f.Action,
+		"resource":     f.Resource,
+		"from":         f.From,
+		"to":           f.To,
+		"severity_gte": f.SeverityGTE,
+		"tag":          f.Tag,
+	} {
+		if value != "" {
+			values.Set(name, value)
+		}
+	}
+	return values
+}
+
+// querySource yields the logs matching a query, fetched from the backend a page at a time when it
+// supports paging and all at once otherwise
+type querySource struct {
+	backend client.LoggingClient
+	query   *client.LogQuery
+	filter  client.LogFilter
+
+	logs     []*client.LogEvent
+	bookmark string
+	done     bool
+}
+
+func (q *querySource) Next(ctx context.Context) (*client.LogEvent, error) {
+	for {
+		for len(q.logs) > 0 {
+			log := q.logs[0]
+			q.logs = q.logs[1:]
+			if q.query.Matches(log) {
+				return log, nil
+			}
+		}
+		if q.done {
+			return nil, client.ErrIteratorDone
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		p, ok := q.backend.(pager)
+		if !ok {
+			logs, err := q.backend.QueryLogs(ctx, q.filter)
+			if err != nil {
+				return nil, err
+			}
+			q.logs, q.done = logs, true
+			continue
+		}
+
+		page, err := p.QueryLogsPage(ctx, q.filter, MaxPageLimit, q.bookmark)
+		if err != nil {
+			return nil, err
+		}
+		q.logs, q.bookmark = page.Records, page.Bookmark
+		q.done = len(page.Records) < MaxPageLimit || page.Bookmark == ""
+	}
+}
+
+// visible reports whether the caller of ctx may see job
+func visible(ctx context.Context, job *ExportJob) bool {
+	principal := PrincipalFrom(ctx)
+	return principal == nil || principal.Subject == job.Owner || principal.HasRole(RoleAdmin)
+}
+
+// exportsEnabled answers 404 when the server runs no export jobs
+func (s *Server) exportsEnabled(w http.ResponseWriter) bool {
+	if s.exports == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("exports are not enabled"))
+		return false
+	}
+	return true
+}
+
+func (s *Server) createExport(w http.ResponseWriter, r *http.Request) {
+	if !s.exportsEnabled(w) {
+		return
+	}
+
+	var request ExportRequest
+	if err := s.decode(w, r, &request); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	annotateAccess(r, func(record *AccessRecord) { record.Filter = auditFilter(request.Filter.values()) })
+	query, err := parseQuery(request.Filter.values())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	filter, _ := query.Filter()
+	if _, err := export.SelectColumns(request.Columns...); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	owner := ""
+	if principal := PrincipalFrom(r.Context()); principal != nil {
+		owner = principal.Subject
+	}
+	job, err := s.exports.start(owner, request, &querySource{backend: s.backend, query: query, filter: filter})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	annotateAccess(r, func(record *AccessRecord) { record.Target = job.ID })
+	w.Header().Set("Location", path.Join("/exports", job.ID))
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func (s *Server) readExport(w http.ResponseWriter, r *http.Request) {
+	if job, ok := s.export(w, r); ok {
+		writeJSON(w, http.StatusOK, job)
+	}
+}
+
+func (s *Server) deleteExport(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.export(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.exports.remove(job.ID); err != nil {
+		writeExportError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) downloadExport(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.export(w, r)
+	if !ok {
+		return
+	}
+
+	name := pathParam(r, "name")
+	for _, file := range job.Files {
+		if file.Name != name {
+			continue
+		}
+		f, err := os.Open(filepath.Join(s.exports.dir(job.ID), name))
+		if err != nil {
+			writeExportError(w, fmt.Errorf("%w: file %s is gone", ErrExportNotFound, name))
+			return
+		}
+		defer f.Close()
+
+		annotateAccess(r, func(record *AccessRecord) {
+			record.Filter = auditFilter(job.Request.Filter.values())
+			record.Records = &job.Records
+		})
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+		http.ServeContent(w, r, name, *job.CompletedAt, f)
+		return
+	}
+	writeError(w, http.StatusNotFound, fmt.Errorf("export %s has no file %s", job.ID, name))
+}
+
+// export returns the job named by the request's path, answering 404 for jobs the caller may not see
+func (s *Server) export(w http.ResponseWriter, r *http.Request) (*ExportJob, bool) {
+	if !s.exportsEnabled(w) {
+		return nil, false
+	}
+
+	job, err := s.exports.get(pathParam(r, "id"))
+	if err == nil && !visible(r.Context(), job) {
+		err = fmt.Errorf("%w: %s", ErrExportNotFound, job.ID)
+	}
+	if err != nil {
+		writeExportError(w, err)
+		return nil, false
+	}
+	return job, true
+}
+
+func writeExportError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	if errors.Is(err, ErrExportNotFound) {
+		code = http.StatusNotFound
+	}
+	writeError(w, code, err)
+}
@@ -0,0 +1,441 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// Limits of the Grafana endpoints, which count the logs of a time range on the server
+const (
+	// grafanaMaxLogs is the most logs a query or annotation request reads
+	grafanaMaxLogs = 100000
+	// grafanaMaxSeries is the most series a grouped target returns; the least frequent values
+	// are summed into an "other" series
+	grafanaMaxSeries = 20
+	// grafanaMaxAnnotations is the most annotations returned, the oldest first
+	grafanaMaxAnnotations = 1000
+)
+
+// Targets of the Grafana JSON datasource, listed by POST /grafana/search. logs counts the logs
+// of each interval, and the logs_by_ targets count them per value of a field; as a table they
+// count them over the whole range. logs_table lists the logs.
+const (
+	GrafanaTargetLogs           = "logs"
+	GrafanaTargetLogsByAction   = "logs_by_action"
+	GrafanaTargetLogsByUser     = "logs_by_user"
+	GrafanaTargetLogsByResource = "logs_by_resource"
+	GrafanaTargetLogsBySeverity = "logs_by_severity"
+	GrafanaTargetLogsTable      = "logs_table"
+)
+
+var grafanaTargets = []string{
+	GrafanaTargetLogs,
+	GrafanaTargetLogsByAction,
+	GrafanaTargetLogsByUser,
+	GrafanaTargetLogsByResource,
+	GrafanaTargetLogsBySeverity,
+	GrafanaTargetLogsTable,
+}
+
+// grafanaGroups reads the value a grouped target counts logs by
+var grafanaGroups = map[string]func(log *client.LogEvent) string{
+	GrafanaTargetLogsByAction:   func(log *client.LogEvent) string { return log.Action },
+	GrafanaTargetLogsByUser:     func(log *client.LogEvent) string { return log.UserID },
+	GrafanaTargetLogsByResource: func(log *client.LogEvent) string { return log.Resource },
+	GrafanaTargetLogsBySeverity: func(log *client.LogEvent) string {
+		if level, ok := client.LogSeverity(*log); ok {
+			return level.String()
+		}
+		return "NONE"
+	},
+}
+
+// grafanaFilterKeys are the filters of a target's payload and of ad hoc filters, as the
+// parameters of GET /logs name them
+var grafanaFilterKeys = []string{"user", "action", "resource", "tag", "severity_gte"}
+
+// grafanaRange is the time range of a panel
+type grafanaRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// grafanaQuery is the body of POST /grafana/query
+type grafanaQuery struct {
+	Range         grafanaRange `json:"range"`
+	IntervalMs    int64        `json:"intervalMs"`
+	MaxDataPoints int64        `json:"maxDataPoints"`
+	Targets       []struct {
+		Target string `json:"target"`
+		RefID  string `json:"refId"`
+		// Type is "timeserie", the default, or "table"
+		Type string `json:"type"`
+		// Payload holds the target's filters; older datasource versions send them as Data
+		Payload map[string]interface{} `json:"payload"`
+		Data    map[string]interface{} `json:"data"`
+		Hide    bool                   `json:"hide"`
+	} `json:"targets"`
+	AdhocFilters []struct {
+		Key      string `json:"key"`
+		Operator string `json:"operator"`
+		Value    string `json:"value"`
+	} `json:"adhocFilters"`
+}
+
+// grafanaSeries is a time series of a query response
+type grafanaSeries struct {
+	Target string `json:"target"`
+	// Datapoints are value and Unix millisecond pairs
+	Datapoints [][2]int64 `json:"datapoints"`
+}
+
+// grafanaTable is a table of a query response
+type grafanaTable struct {
+	Type    string          `json:"type"`
+	RefID   string          `json:"refId,omitempty"`
+	Columns []grafanaColumn `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+type grafanaColumn struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+// grafanaAnnotation is an annotation of POST /grafana/annotations
+type grafanaAnnotation struct {
+	// Annotation echoes the annotation of the request, as older datasource versions expect
+	Annotation json.RawMessage `json:"annotation,omitempty"`
+	Time       int64           `json:"time"`
+	Title      string          `json:"title"`
+	Text       string          `json:"text"`
+	Tags       []string        `json:"tags"`
+}
+
+// testGrafana answers the connection test of the datasource settings
+func (s *Server) testGrafana(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// searchGrafana lists the targets containing the request's target text
+func (s *Server) searchGrafana(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Target string `json:"target"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.maxBodyBytes)).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+
+	targets := []string{}
+	for _, target := range grafanaTargets {
+		if strings.Contains(target, strings.ToLower(request.Target)) {
+			targets = append(targets, target)
+		}
+	}
+	writeJSON(w, http.StatusOK, targets)
+}
+
+// queryGrafana answers the targets of a panel over its time range
+func (s *Server) queryGrafana(w http.ResponseWriter, r *http.Request) {
+	var request grafanaQuery
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.maxBodyBytes)).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+	if !request.Range.From.Before(request.Range.To) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("range.from must be before range.to"))
+		return
+	}
+	interval := grafanaInterval(request)
+
+	responses := []interface{}{}
+	for _, target := range request.Targets {
+		if target.Hide || target.Target == "" {
+			continue
+		}
+		if target.Target != GrafanaTargetLogs && target.Target != GrafanaTargetLogsTable && grafanaGroups[target.Target] == nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("unknown target %q: want one of %s", target.Target, strings.Join(grafanaTargets, ", ")))
+			return
+		}
+
+		filters := target.Payload
+		if filters == nil {
+			filters = target.Data
+		}
+		values := url.Values{}
+		for _, key := range grafanaFilterKeys {
+			if value, ok := filters[key]; ok && value != nil && value != "" {
+				values.Set(key, fmt.Sprint(value))
+			}
+		}
+		for _, filter := range request.AdhocFilters {
+			if filter.Operator != "=" {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("ad hoc filter %s %s: only = is supported", filter.Key, filter.Operator))
+				return
+			}
+			if !grafanaFilterKey(filter.Key) {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("unknown ad hoc filter %q: want one of %s", filter.Key, strings.Join(grafanaFilterKeys, ", ")))
+				return
+			}
+			values.Set(filter.Key, filter.Value)
+		}
+		logs, err := s.grafanaLogs(r.Context(), values, request.Range)
+		if err != nil {
+			writeClientError(w, err)
+			return
+		}
+
+		switch {
+		case target.Target == GrafanaTargetLogsTable:
+			responses = append(responses, logsTable(target.RefID, logs))
+		case target.Type == "table":
+			responses = append(responses, countsTable(target.RefID, target.Target, logs))
+		default:
+			for _, series := range countSeries(target.Target, logs, request.Range, interval) {
+				responses = append(responses, series)
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, responses)
+}
+
+// annotateGrafana marks the logs matching an annotation's query on the panels of its range. The
+// query takes the parameters of GET /logs, such as severity_gte=ERROR&action=LOGIN_FAILED.
+func (s *Server) annotateGrafana(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Range      grafanaRange    `json:"range"`
+		Annotation json.RawMessage `json:"annotation"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.maxBodyBytes)).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+	var annotation struct {
+		Query string `json:"query"`
+	}
+	json.Unmarshal(request.Annotation, &annotation)
+	values, err := url.ParseQuery(strings.TrimPrefix(strings.TrimSpace(annotation.Query), "?"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid annotation query: %v", err))
+		return
+	}
+	for key := range values {
+		if key == "from" || key == "to" {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("the annotation query must not set %s, the range of the dashboard", key))
+			return
+		}
+	}
+	values.Set("from", request.Range.From.Format(time.RFC3339Nano))
+	values.Set("to", request.Range.To.Format(time.RFC3339Nano))
+	query, err := parseQuery(values)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	logs, _, err := queryPage(r.Context(), s.backend, query, grafanaMaxAnnotations, cursor{})
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+	annotations := make([]grafanaAnnotation, 0, len(logs))
+	for _, log := range logs {
+		t, err := time.Parse(time.RFC3339Nano, log.Timestamp)
+		if err != nil {
+			continue
+		}
+		annotations = append(annotations, grafanaAnnotation{
+			Annotation: request.Annotation,
+			Time:       t.UnixMilli(),
+			Title:      log.Action,
+			Text:       log.Description,
+			Tags:       []string{log.UserID, log.Resource},
+		})
+	}
+	writeJSON(w, http.StatusOK, annotations)
+}
+
+// grafanaLogs reads the logs of a range matching the filters in values, failing when there are
+// more than grafanaMaxLogs
+func (s *Server) grafanaLogs(ctx context.Context, values url.Values, within grafanaRange) ([]*client.LogEvent, error) {
+	values.Set("from", within.From.Format(time.RFC3339Nano))
+	values.Set("to", within.To.Format(time.RFC3339Nano))
+	query, err := parseQuery(values)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", client.ErrInvalidLog, err)
+	}
+
+	var logs []*client.LogEvent
+	at := cursor{}
+	for {
+		page, next, err := queryPage(ctx, s.backend, query, MaxPageLimit, at)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, page...)
+		if len(logs) > grafanaMaxLogs {
+			return nil, fmt.Errorf("%w: the range holds more than %d logs; narrow it or filter the target", client.ErrInvalidLog, grafanaMaxLogs)
+		}
+		if next == nil {
+			return logs, nil
+		}
+		at = *next
+	}
+}
+
+func grafanaFilterKey(key string) bool {
+	for _, k := range grafanaFilterKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// grafanaInterval returns the width of the buckets of time series: the panel's interval, widened
+// so the range holds no more than its maximum number of data points, and at least a second
+func grafanaInterval(request grafanaQuery) time.Duration {
+	interval := time.Duration(request.IntervalMs) * time.Millisecond
+	span := request.Range.To.Sub(request.Range.From)
+	if request.MaxDataPoints > 0 {
+		if least := span / time.Duration(request.MaxDataPoints); interval < least {
+			interval = least
+		}
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+	return interval.Truncate(time.Second)
+}
+
+// countSeries counts logs per interval, in one series for the logs target and one per value of
+// the field of a grouped target
+func countSeries(target string, logs []*client.LogEvent, within grafanaRange, interval time.Duration) []grafanaSeries {
+	start := within.From.Truncate(interval)
+	buckets := int(within.To.Sub(start)/interval) + 1
+	counts := map[string][]int64{}
+	totals := map[string]int64{}
+	group := grafanaGroups[target]
+	for _, log := range logs {
+		t, err := time.Parse(time.RFC3339Nano, log.Timestamp)
+		if err != nil || t.Before(start) {
+			continue
+		}
+		bucket := int(t.Sub(start) / interval)
+		if bucket >= buckets {
+			continue
+		}
+		name := target
+		if group != nil {
+			name = group(log)
+		}
+		if counts[name] == nil {
+			counts[name] = make([]int64, buckets)
+		}
+		counts[name][bucket]++
+		totals[name]++
+	}
+	if group == nil && counts[target] == nil {
+		counts[target] = make([]int64, buckets)
+	}
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if totals[names[i]] != totals[names[j]] {
+			return totals[names[i]] > totals[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > grafanaMaxSeries {
+		other := make([]int64, buckets)
+		for _, name := range names[grafanaMaxSeries-1:] {
+			for i, count := range counts[name] {
+				other[i] += count
+			}
+		}
+		names = append(names[:grafanaMaxSeries-1], "other")
+		counts["other"] = other
+	}
+
+	series := make([]grafanaSeries, 0, len(names))
+	for _, name := range names {
+		s := grafanaSeries{Target: name, Datapoints: make([][2]int64, buckets)}
+		for i, count := range counts[name] {
+			s.Datapoints[i] = [2]int64{count, start.Add(time.Duration(i) * interval).UnixMilli()}
+		}
+		series = append(series, s)
+	}
+	return series
+}
+
+// countsTable counts logs over the whole range, per value of the field of a grouped target
+func countsTable(refID string, target string, logs []*client.LogEvent) grafanaTable {
+	table := grafanaTable{
+		Type:    "table",
+		RefID:   refID,
+		Columns: []grafanaColumn{{Text: target, Type: "string"}, {Text: "Count", Type: "number"}},
+		Rows:    [][]interface{}{},
+	}
+	group := grafanaGroups[target]
+	if group == nil {
+		table.Columns[0].Text = "Target"
+		table.Rows = append(table.Rows, []interface{}{target, len(logs)})
+		return table
+	}
+
+	counts := map[string]int{}
+	for _, log := range logs {
+		counts[group(log)]++
+	}
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		table.Rows = append(table.Rows, []interface{}{name, counts[name]})
+	}
+	return table
+}
+
+// logsTable lists logs, as GET /logs returns them
+func logsTable(refID string, logs []*client.LogEvent) grafanaTable {
+	table := grafanaTable{
+		Type:  "table",
+		RefID: refID,
+		Columns: []grafanaColumn{
+			{Text: "Time", Type: "time"},
+			{Text: "ID", Type: "string"},
+			{Text: "User", Type: "string"},
+			{Text: "Action", Type: "string"},
+			{Text: "Resource", Type: "string"},
+			{Text: "Description", Type: "string"},
+		},
+		Rows: make([][]interface{}, 0, len(logs)),
+	}
+	for _, log := range logs {
+		t, err := time.Parse(time.RFC3339Nano, log.Timestamp)
+		if err != nil {
+			continue
+		}
+		table.Rows = append(table.Rows, []interface{}{t.UnixMilli(), log.ID, log.UserID, log.Action, log.Resource, log.Description})
+	}
+	return table
+}
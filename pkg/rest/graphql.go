@@ -0,0 +1,300 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// maxGraphQLRootFields bounds the log and logs fields of one GraphQL request, each of which
+// queries the ledger
+const maxGraphQLRootFields = 20
+
+// graphQLSDL describes the schema of POST /graphql, served by GET /graphql/schema
+const graphQLSDL = `"""
+Read-only access to the logs recorded on the ledger
+"""
+type Query {
+  "The log with the given ID, or null when there is none"
+  log(id: ID!): LogEvent
+  "The logs matching filter, a page at a time, in the order GET /logs returns them"
+  logs(filter: LogFilter, first: Int = 100, after: String): LogConnection!
+}
+
+"Criteria of the logs to return, as the parameters of GET /logs"
+input LogFilter {
+  user: String
+  action: String
+  resource: String
+  "RFC 3339 timestamp of the earliest log"
+  from: String
+  "RFC 3339 timestamp of the latest log"
+  to: String
+  "Level name, such as WARN, of the least severe log"
+  severityGte: String
+  tag: String
+}
+
+type LogConnection {
+  nodes: [LogEvent!]!
+  pageInfo: PageInfo!
+}
+
+type PageInfo {
+  hasNextPage: Boolean!
+  "Cursor to pass as after for the next page, null after the last one"
+  endCursor: String
+}
+
+type LogEvent {
+  id: ID!
+  userId: String!
+  action: String!
+  resource: String!
+  timestamp: String!
+  description: String
+  metadata: String
+  collection: String
+  "Level name of the log's metadata severity, when it has one"
+  severity: String
+  "Tags of the log's metadata"
+  tags: [String!]!
+  "The transaction that recorded the log, when the server keeps receipts"
+  receipt: Receipt
+}
+
+type Receipt {
+  transactionId: String!
+  blockNumber: Int
+  finality: String!
+  status: String
+  submittedAt: String!
+}
+`
+
+// receipter is implemented by clients keeping the receipts of the logs they submitted
+type receipter interface {
+	Receipt(logID string) (*client.Receipt, error)
+}
+
+// GraphQLRequest is the body of POST /graphql
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// GraphQLResponse is the result of a GraphQL request. Data is absent when the request could not
+// be executed, and partial when Errors reports fields that failed.
+type GraphQLResponse struct {
+	Data   json.RawMessage `json:"data,omitempty"`
+	Errors []GraphQLError  `json:"errors,omitempty"`
+}
+
+// logConnection is the page of logs a logs field resolves to
+type logConnection struct {
+	nodes []*client.LogEvent
+	next  *cursor
+}
+
+// graphQLSchema builds the schema of graphQLSDL over the server's backend
+func (s *Server) graphQLSchema() *gqlSchema {
+	scalars := []string{"ID", "String", "Int", "Float", "Boolean"}
+	types := map[string]*gqlType{}
+	for _, name := range scalars {
+		types[name] = &gqlType{name: name}
+	}
+
+	types["LogFilter"] = &gqlType{name: "LogFilter", inputFields: map[string]gqlArgument{
+		"user":        {typ: "String"},
+		"action":      {typ: "String"},
+		"resource":    {typ: "String"},
+		"from":        {typ: "String"},
+		"to":          {typ: "String"},
+		"severityGte": {typ: "String"},
+		"tag":         {typ: "String"},
+	}}
+
+	types["Query"] = &gqlType{name: "Query", fields: map[string]*gqlField{
+		"log": {
+			typ:       "LogEvent",
+			arguments: map[string]gqlArgument{"id": {typ: "ID!"}},
+			resolve: func(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+				log, err := s.backend.ReadLog(ctx, args["id"].(string))
+				if errors.Is(err, client.ErrLogNotFound) {
+					return nil, nil
+				}
+				return log, err
+			},
+		},
+		"logs": {
+			typ: "LogConnection!",
+			arguments: map[string]gqlArgument{
+				"filter": {typ: "LogFilter"},
+				"first":  {typ: "Int", defaultValue: int64(DefaultPageLimit)},
+				"after":  {typ: "String"},
+			},
+			resolve: s.resolveLogs,
+		},
+	}}
+
+	types["LogConnection"] = &gqlType{name: "LogConnection", fields: map[string]*gqlField{
+		"nodes": {typ: "[LogEvent!]!", resolve: func(_ context.Context, parent interface{}, _ map[string]interface{}) (interface{}, error) {
+			return parent.(*logConnection).nodes, nil
+		}},
+		"pageInfo": {typ: "PageInfo!", resolve: func(_ context.Context, parent interface{}, _ map[string]interface{}) (interface{}, error) {
+			return parent, nil
+		}},
+	}}
+
+	types["PageInfo"] = &gqlType{name: "PageInfo", fields: map[string]*gqlField{
+		"hasNextPage": {typ: "Boolean!", resolve: func(_ context.Context, parent interface{}, _ map[string]interface{}) (interface{}, error) {
+			return parent.(*logConnection).next != nil, nil
+		}},
+		"endCursor": {typ: "String", resolve: func(_ context.Context, parent interface{}, _ map[string]interface{}) (interface{}, error) {
+			if next := parent.(*logConnection).next; next != nil {
+				return next.encode(), nil
+			}
+			return nil, nil
+		}},
+	}}
+
+	logField := func(typ string, value func(log *client.LogEvent) interface{}) *gqlField {
+		return &gqlField{typ: typ, resolve: func(_ context.Context, parent interface{}, _ map[string]interface{}) (interface{}, error) {
+			return value(parent.(*client.LogEvent)), nil
+		}}
+	}
+	optional := func(s string) interface{} {
+		if s == "" {
+			return nil
+		}
+		return s
+	}
+	types["LogEvent"] = &gqlType{name: "LogEvent", fields: map[string]*gqlField{
+		"id":          logField("ID!", func(log *client.LogEvent) interface{} { return log.ID }),
+		"userId":      logField("String!", func(log *client.LogEvent) interface{} { return log.UserID }),
+		"action":      logField("String!", func(log *client.LogEvent) interface{} { return log.Action }),
+		"resource":    logField("String!", func(log *client.LogEvent) interface{} { return log.Resource }),
+		"timestamp":   logField("String!", func(log *client.LogEvent) interface{} { return log.Timestamp }),
+		"description": logField("String", func(log *client.LogEvent) interface{} { return optional(log.Description) }),
+		"metadata":    logField("String", func(log *client.LogEvent) interface{} { return optional(log.Metadata) }),
+		"collection":  logField("String", func(log *client.LogEvent) interface{} { return optional(log.Collection) }),
+		"severity": logField("String", func(log *client.LogEvent) interface{} {
+			if level, ok := client.LogSeverity(*log); ok {
+				return level.String()
+			}
+			return nil
+		}),
+		"tags": logField("[String!]!", func(log *client.LogEvent) interface{} {
+			if tags := client.LogTags(*log); tags != nil {
+				return tags
+			}
+			return []string{}
+		}),
+		"receipt": {typ: "Receipt", resolve: s.resolveReceipt},
+	}}
+
+	receiptField := func(typ string, value func(receipt *client.Receipt) interface{}) *gqlField {
+		return &gqlField{typ: typ, resolve: func(_ context.Context, parent interface{}, _ map[string]interface{}) (interface{}, error) {
+			return value(parent.(*client.Receipt)), nil
+		}}
+	}
+	types["Receipt"] = &gqlType{name: "Receipt", fields: map[string]*gqlField{
+		"transactionId": receiptField("String!", func(receipt *client.Receipt) interface{} { return receipt.TransactionID }),
+		"blockNumber": receiptField("Int", func(receipt *client.Receipt) interface{} {
+			if receipt.BlockNumber == 0 {
+				return nil
+			}
+			return receipt.BlockNumber
+		}),
+		"finality":    receiptField("String!", func(receipt *client.Receipt) interface{} { return receipt.Finality }),
+		"status":      receiptField("String", func(receipt *client.Receipt) interface{} { return optional(receipt.Status) }),
+		"submittedAt": receiptField("String!", func(receipt *client.Receipt) interface{} { return receipt.SubmittedAt.UTC().Format(time.RFC3339Nano) }),
+	}}
+
+	return &gqlSchema{query: "Query", types: types, maxRootFields: maxGraphQLRootFields}
+}
+
+// resolveLogs queries a page of logs with the criteria and cursors of GET /logs
+func (s *Server) resolveLogs(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+	values := url.Values{}
+	if filter, ok := args["filter"].(map[string]interface{}); ok {
+		for field, param := range map[string]string{
+			"user":        "user",
+			"action":      "action",
+			"resource":    "resource",
+			"from":        "from",
+			"to":          "to",
+			"severityGte": "severity_gte",
+			"tag":         "tag",
+		} {
+			if value, ok := filter[field].(string); ok {
+				values.Set(param, value)
+			}
+		}
+	}
+	query, err := parseQuery(values)
+	if err != nil {
+		return nil, err
+	}
+
+	limit, _ := args["first"].(int)
+	if args["first"] == nil {
+		limit = DefaultPageLimit
+	}
+	if limit < 1 || limit > MaxPageLimit {
+		return nil, fmt.Errorf("first must be from 1 to %d", MaxPageLimit)
+	}
+	after, _ := args["after"].(string)
+	at, err := decodeCursor(after)
+	if err != nil {
+		return nil, err
+	}
+
+	logs, next, err := queryPage(ctx, s.backend, query, limit, at)
+	if err != nil {
+		return nil, err
+	}
+	return &logConnection{nodes: logs, next: next}, nil
+}
+
+// resolveReceipt looks up the receipt of a log; logs the server did not submit have none
+func (s *Server) resolveReceipt(_ context.Context, parent interface{}, _ map[string]interface{}) (interface{}, error) {
+	r, ok := s.backend.(receipter)
+	if !ok {
+		return nil, nil
+	}
+	receipt, err := r.Receipt(parent.(*client.LogEvent).ID)
+	if errors.Is(err, client.ErrReceiptNotFound) {
+		return nil, nil
+	}
+	return receipt, err
+}
+
+// executeGraphQL answers a GraphQL query. Errors of the query are reported in the response with
+// status 200, as GraphQL clients expect.
+func (s *Server) executeGraphQL(w http.ResponseWriter, r *http.Request) {
+	var request GraphQLRequest
+	if err := s.decode(w, r, &request); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	data, errs, err := s.graphQL.execute(r.Context(), request.Query, request.OperationName, request.Variables)
+	if err != nil {
+		writeJSON(w, http.StatusOK, GraphQLResponse{Errors: []GraphQLError{{Message: err.Error()}}})
+		return
+	}
+	writeJSON(w, http.StatusOK, GraphQLResponse{Data: data, Errors: errs})
+}
+
+func (s *Server) getGraphQLSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(graphQLSDL))
+}
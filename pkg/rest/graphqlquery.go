@@ -0,0 +1,1204 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// This file implements the part of the GraphQL query language a read-only API needs: query
+// operations with variables, aliases, arguments, named and inline fragments, the @skip and
+// @include directives, and __typename. Mutations, subscriptions and introspection are not
+// supported; the schema is served as SDL instead.
+
+// maxGraphQLDepth bounds the nesting of selection sets, lists and input objects in a document,
+// which are parsed recursively
+const maxGraphQLDepth = 64
+
+// gqlToken is a lexical token of a GraphQL document
+type gqlToken struct {
+	kind  gqlTokenKind
+	value string
+	pos   int
+}
+
+type gqlTokenKind int
+
+const (
+	gqlEOF gqlTokenKind = iota
+	gqlPunct
+	gqlName
+	gqlInt
+	gqlFloat
+	gqlString
+)
+
+// lexGraphQL splits a document into tokens, dropping whitespace, commas and comments
+func lexGraphQL(src string) ([]gqlToken, error) {
+	var tokens []gqlToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case strings.HasPrefix(src[i:], "\uFEFF"):
+			i += len("\uFEFF")
+		case c == '#':
+			for i < len(src) && src[i] != '\n' && src[i] != '\r' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "..."):
+			tokens = append(tokens, gqlToken{kind: gqlPunct, value: "...", pos: i})
+			i += 3
+		case strings.IndexByte("!$&():=@[]{}|", c) >= 0:
+			tokens = append(tokens, gqlToken{kind: gqlPunct, value: string(c), pos: i})
+			i++
+		case c == '_' || isLetter(c):
+			start := i
+			for i < len(src) && (src[i] == '_' || isLetter(src[i]) || isDigit(src[i])) {
+				i++
+			}
+			tokens = append(tokens, gqlToken{kind: gqlName, value: src[start:i], pos: start})
+		case c == '-' || isDigit(c):
+			token, end, err := lexNumber(src, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token)
+			i = end
+		case strings.HasPrefix(src[i:], `"""`):
+			end := i + 3
+			for end < len(src) && !strings.HasPrefix(src[end:], `"""`) {
+				if strings.HasPrefix(src[end:], `\"""`) {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf("unterminated block string at %d", i)
+			}
+			value := strings.ReplaceAll(src[i+3:end], `\"""`, `"""`)
+			tokens = append(tokens, gqlToken{kind: gqlString, value: blockString(value), pos: i})
+			i = end + 3
+		case c == '"':
+			end := i + 1
+			for end < len(src) && src[end] != '"' && src[end] != '\n' {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) || src[end] != '"' {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			// GraphQL strings escape characters as JSON strings do
+			var value string
+			if err := json.Unmarshal([]byte(src[i:end+1]), &value); err != nil {
+				return nil, fmt.Errorf("invalid string at %d: %v", i, err)
+			}
+			tokens = append(tokens, gqlToken{kind: gqlString, value: value, pos: i})
+			i = end + 1
+		default:
+			return nil, fmt.Errorf("unexpected character %q at %d", c, i)
+		}
+	}
+	return append(tokens, gqlToken{kind: gqlEOF, pos: len(src)}), nil
+}
+
+func lexNumber(src string, start int) (gqlToken, int, error) {
+	i := start
+	if src[i] == '-' {
+		i++
+	}
+	digits := func() int {
+		from := i
+		for i < len(src) && isDigit(src[i]) {
+			i++
+		}
+		return i - from
+	}
+	if digits() == 0 {
+		return gqlToken{}, 0, fmt.Errorf("invalid number at %d", start)
+	}
+	kind := gqlInt
+	if i < len(src) && src[i] == '.' {
+		i++
+		kind = gqlFloat
+		if digits() == 0 {
+			return gqlToken{}, 0, fmt.Errorf("invalid number at %d", start)
+		}
+	}
+	if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+		i++
+		kind = gqlFloat
+		if i < len(src) && (src[i] == '+' || src[i] == '-') {
+			i++
+		}
+		if digits() == 0 {
+			return gqlToken{}, 0, fmt.Errorf("invalid number at %d", start)
+		}
+	}
+	if i < len(src) && (src[i] == '_' || src[i] == '.' || isLetter(src[i])) {
+		return gqlToken{}, 0, fmt.Errorf("invalid number at %d", start)
+	}
+	return gqlToken{kind: kind, value: src[start:i], pos: start}, i, nil
+}
+
+// blockString removes the common indentation and the blank first and last lines of a block string
+func blockString(raw string) string {
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && (indent < 0 || len(line)-len(trimmed) < indent) {
+			indent = len(line) - len(trimmed)
+		}
+	}
+	for i := 1; i < len(lines) && indent > 0; i++ {
+		if len(lines[i]) >= indent {
+			lines[i] = lines[i][indent:]
+		} else {
+			lines[i] = ""
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// gqlDocument is a parsed GraphQL document
+type gqlDocument struct {
+	operations []*gqlOperation
+	fragments  map[string]*gqlFragment
+}
+
+type gqlOperation struct {
+	kind       string
+	name       string
+	variables  []gqlVariableDefinition
+	selections []*gqlSelection
+}
+
+type gqlVariableDefinition struct {
+	name         string
+	typ          string
+	defaultValue interface{}
+}
+
+type gqlFragment struct {
+	name          string
+	typeCondition string
+	selections    []*gqlSelection
+}
+
+// gqlSelection is a field, a fragment spread or an inline fragment
+type gqlSelection struct {
+	alias      string
+	name       string
+	arguments  map[string]interface{}
+	directives []gqlDirective
+	selections []*gqlSelection
+
+	// spread names the fragment of a fragment spread
+	spread string
+	// inline marks an inline fragment, which applies when typeCondition is empty or matches
+	inline        bool
+	typeCondition string
+}
+
+type gqlDirective struct {
+	name      string
+	arguments map[string]interface{}
+}
+
+// responseKey is the key of a field in the result
+func (f *gqlSelection) responseKey() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+// Values in documents are nil, bool, int64, float64, string, gqlEnum, gqlVariable, lists
+// []interface{} and input objects map[string]interface{}
+
+type gqlEnum string
+
+type gqlVariable string
+
+type gqlParser struct {
+	tokens []gqlToken
+	pos    int
+	// depth is the nesting of the selection sets, lists and input objects being parsed
+	depth int
+}
+
+// parseGraphQL parses an executable document
+func parseGraphQL(src string) (*gqlDocument, error) {
+	tokens, err := lexGraphQL(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &gqlParser{tokens: tokens}
+	doc := &gqlDocument{fragments: map[string]*gqlFragment{}}
+	for p.peek().kind != gqlEOF {
+		token := p.peek()
+		switch {
+		case token.kind == gqlPunct && token.value == "{":
+			selections, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, &gqlOperation{kind: "query", selections: selections})
+		case token.kind == gqlName && (token.value == "query" || token.value == "mutation" || token.value == "subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case token.kind == gqlName && token.value == "fragment":
+			fragment, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if doc.fragments[fragment.name] != nil {
+				return nil, fmt.Errorf("there can be only one fragment named %q", fragment.name)
+			}
+			doc.fragments[fragment.name] = fragment
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, fmt.Errorf("the document has no operation")
+	}
+	return doc, nil
+}
+
+func (p *gqlParser) peek() gqlToken {
+	return p.tokens[p.pos]
+}
+
+func (p *gqlParser) next() gqlToken {
+	token := p.tokens[p.pos]
+	if token.kind != gqlEOF {
+		p.pos++
+	}
+	return token
+}
+
+// punct consumes the punctuator s if it is next
+func (p *gqlParser) punct(s string) bool {
+	if token := p.peek(); token.kind == gqlPunct && token.value == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *gqlParser) expect(s string) error {
+	if !p.punct(s) {
+		return p.unexpected()
+	}
+	return nil
+}
+
+func (p *gqlParser) name() (string, error) {
+	if p.peek().kind != gqlName {
+		return "", p.unexpected()
+	}
+	return p.next().value, nil
+}
+
+// nest enters a nested selection set, list or input object; call the returned function to leave it
+func (p *gqlParser) nest() (func(), error) {
+	if p.depth >= maxGraphQLDepth {
+		return nil, fmt.Errorf("the document is nested deeper than %d levels", maxGraphQLDepth)
+	}
+	p.depth++
+	return func() { p.depth-- }, nil
+}
+
+func (p *gqlParser) unexpected() error {
+	token := p.peek()
+	if token.kind == gqlEOF {
+		return fmt.Errorf("syntax error: unexpected end of document")
+	}
+	return fmt.Errorf("syntax error: unexpected %q at %d", token.value, token.pos)
+}
+
+func (p *gqlParser) operation() (*gqlOperation, error) {
+	op := &gqlOperation{kind: p.next().value}
+	if p.peek().kind == gqlName {
+		op.name = p.next().value
+	}
+
+	if p.punct("(") {
+		for !p.punct(")") {
+			if err := p.expect("$"); err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			definition := gqlVariableDefinition{name: name}
+			if definition.typ, err = p.typeReference(); err != nil {
+				return nil, err
+			}
+			if p.punct("=") {
+				if definition.defaultValue, err = p.value(true); err != nil {
+					return nil, err
+				}
+			}
+			if _, err := p.directives(); err != nil {
+				return nil, err
+			}
+			op.variables = append(op.variables, definition)
+		}
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	op.selections, err = p.selectionSet()
+	return op, err
+}
+
+func (p *gqlParser) fragment() (*gqlFragment, error) {
+	p.next()
+	fragment := &gqlFragment{}
+	var err error
+	if fragment.name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if fragment.name == "on" {
+		return nil, fmt.Errorf("syntax error: a fragment cannot be named on")
+	}
+	if on, err := p.name(); err != nil || on != "on" {
+		return nil, fmt.Errorf("syntax error: expected a type condition for fragment %s", fragment.name)
+	}
+	if fragment.typeCondition, err = p.name(); err != nil {
+		return nil, err
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	fragment.selections, err = p.selectionSet()
+	return fragment, err
+}
+
+// typeReference parses a type such as String, [ID!] or LogFilter!, returned as written
+func (p *gqlParser) typeReference() (string, error) {
+	var typ string
+	if p.punct("[") {
+		leave, err := p.nest()
+		if err != nil {
+			return "", err
+		}
+		defer leave()
+		inner, err := p.typeReference()
+		if err != nil {
+			return "", err
+		}
+		if err := p.expect("]"); err != nil {
+			return "", err
+		}
+		typ = "[" + inner + "]"
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return "", err
+		}
+		typ = name
+	}
+	if p.punct("!") {
+		typ += "!"
+	}
+	return typ, nil
+}
+
+func (p *gqlParser) selectionSet() ([]*gqlSelection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	leave, err := p.nest()
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+
+	var selections []*gqlSelection
+	for !p.punct("}") {
+		selection, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, selection)
+	}
+	if len(selections) == 0 {
+		return nil, fmt.Errorf("syntax error: empty selection set")
+	}
+	return selections, nil
+}
+
+func (p *gqlParser) selection() (*gqlSelection, error) {
+	selection := &gqlSelection{}
+	var err error
+
+	if p.punct("...") {
+		if token := p.peek(); token.kind == gqlName && token.value != "on" {
+			selection.spread = p.next().value
+			selection.directives, err = p.directives()
+			return selection, err
+		}
+		selection.inline = true
+		if token := p.peek(); token.kind == gqlName && token.value == "on" {
+			p.next()
+			if selection.typeCondition, err = p.name(); err != nil {
+				return nil, err
+			}
+		}
+		if selection.directives, err = p.directives(); err != nil {
+			return nil, err
+		}
+		selection.selections, err = p.selectionSet()
+		return selection, err
+	}
+
+	if selection.name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if p.punct(":") {
+		selection.alias = selection.name
+		if selection.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if selection.arguments, err = p.arguments(false); err != nil {
+		return nil, err
+	}
+	if selection.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if token := p.peek(); token.kind == gqlPunct && token.value == "{" {
+		selection.selections, err = p.selectionSet()
+	}
+	return selection, err
+}
+
+func (p *gqlParser) arguments(constant bool) (map[string]interface{}, error) {
+	arguments := map[string]interface{}{}
+	if !p.punct("(") {
+		return arguments, nil
+	}
+	for !p.punct(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if _, ok := arguments[name]; ok {
+			return nil, fmt.Errorf("there can be only one argument named %q", name)
+		}
+		if arguments[name], err = p.value(constant); err != nil {
+			return nil, err
+		}
+	}
+	return arguments, nil
+}
+
+func (p *gqlParser) directives() ([]gqlDirective, error) {
+	var directives []gqlDirective
+	for p.punct("@") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		arguments, err := p.arguments(false)
+		if err != nil {
+			return nil, err
+		}
+		directives = append(directives, gqlDirective{name: name, arguments: arguments})
+	}
+	return directives, nil
+}
+
+// value parses a value; constant values, such as variable defaults, cannot name variables
+func (p *gqlParser) value(constant bool) (interface{}, error) {
+	token := p.next()
+	switch token.kind {
+	case gqlInt:
+		n, err := strconv.ParseInt(token.value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %s", token.value)
+		}
+		return n, nil
+	case gqlFloat:
+		f, err := strconv.ParseFloat(token.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float %s", token.value)
+		}
+		return f, nil
+	case gqlString:
+		return token.value, nil
+	case gqlName:
+		switch token.value {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return gqlEnum(token.value), nil
+	case gqlPunct:
+		switch token.value {
+		case "$":
+			if constant {
+				break
+			}
+			name, err := p.name()
+			return gqlVariable(name), err
+		case "[":
+			leave, err := p.nest()
+			if err != nil {
+				return nil, err
+			}
+			defer leave()
+			list := []interface{}{}
+			for !p.punct("]") {
+				item, err := p.value(constant)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, item)
+			}
+			return list, nil
+		case "{":
+			leave, err := p.nest()
+			if err != nil {
+				return nil, err
+			}
+			defer leave()
+			object := map[string]interface{}{}
+			for !p.punct("}") {
+				name, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+				if object[name], err = p.value(constant); err != nil {
+					return nil, err
+				}
+			}
+			return object, nil
+		}
+	}
+	// next does not move past the end of the document
+	if token.kind != gqlEOF {
+		p.pos--
+	}
+	return nil, p.unexpected()
+}
+
+// gqlSchema holds the types of a GraphQL schema. Types are referred to as written in SDL, such
+// as [LogEvent!]!.
+type gqlSchema struct {
+	query string
+	types map[string]*gqlType
+	// maxRootFields limits the fields of the query type a request may select, each of which
+	// queries the ledger
+	maxRootFields int
+}
+
+// gqlType is an object type with fields, an input type with inputFields, or a scalar with neither
+type gqlType struct {
+	name        string
+	fields      map[string]*gqlField
+	inputFields map[string]gqlArgument
+}
+
+type gqlField struct {
+	typ       string
+	arguments map[string]gqlArgument
+	// resolve returns the value of the field of parent, the value of the enclosing field
+	resolve func(ctx context.Context, parent interface{}, args map[string]interface{}) (interface{}, error)
+}
+
+type gqlArgument struct {
+	typ          string
+	defaultValue interface{}
+}
+
+// namedType strips the list and non-null wrappers of a type reference
+func namedType(typ string) string {
+	return strings.Trim(typ, "[]!")
+}
+
+func nonNull(typ string) bool {
+	return strings.HasSuffix(typ, "!")
+}
+
+// GraphQLError is an error of a GraphQL request, located by the response path of the field it
+// occurred in
+type GraphQLError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// gqlObject is the result of a selection set, keeping the order of its fields
+type gqlObject []gqlEntry
+
+type gqlEntry struct {
+	key   string
+	value interface{}
+}
+
+func (o gqlObject) MarshalJSON() ([]byte, error) {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, entry := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(entry.key)
+		value, err := json.Marshal(entry.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return []byte(b.String()), nil
+}
+
+// gqlExecution is the execution of one operation
+type gqlExecution struct {
+	schema    *gqlSchema
+	doc       *gqlDocument
+	variables map[string]interface{}
+	errors    []GraphQLError
+}
+
+// execute runs the operation named operationName, or the only one, of a document. Errors in the
+// request are returned; errors of fields are reported in the result's errors, with data.
+func (s *gqlSchema) execute(ctx context.Context, query string, operationName string, variables map[string]interface{}) (json.RawMessage, []GraphQLError, error) {
+	doc, err := parseGraphQL(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	op, err := doc.operation(operationName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if op.kind != "query" {
+		return nil, nil, fmt.Errorf("%s operations are not supported", op.kind)
+	}
+
+	e := &gqlExecution{schema: s, doc: doc}
+	if e.variables, err = s.coerceVariables(op, variables); err != nil {
+		return nil, nil, err
+	}
+	if err := e.validate(op); err != nil {
+		return nil, nil, err
+	}
+
+	data, ok := e.executeFields(ctx, s.types[s.query], nil, op.selections, nil)
+	if !ok {
+		return json.RawMessage("null"), e.errors, nil
+	}
+	result, err := json.Marshal(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode result: %v", err)
+	}
+	return result, e.errors, nil
+}
+
+func (d *gqlDocument) operation(name string) (*gqlOperation, error) {
+	if name == "" {
+		if len(d.operations) > 1 {
+			return nil, fmt.Errorf("operationName is required for a document with several operations")
+		}
+		return d.operations[0], nil
+	}
+	for _, op := range d.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+func (s *gqlSchema) coerceVariables(op *gqlOperation, values map[string]interface{}) (map[string]interface{}, error) {
+	variables := map[string]interface{}{}
+	for _, definition := range op.variables {
+		if t := s.types[namedType(definition.typ)]; t == nil || t.fields != nil {
+			return nil, fmt.Errorf("variable $%s has the type %s, which is not an input type", definition.name, definition.typ)
+		}
+		value, ok := values[definition.name]
+		if !ok {
+			if definition.defaultValue == nil {
+				if nonNull(definition.typ) {
+					return nil, fmt.Errorf("variable $%s of type %s was not provided", definition.name, definition.typ)
+				}
+				continue
+			}
+			value = definition.defaultValue
+		}
+		coerced, err := s.coerce(definition.typ, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of variable $%s: %v", definition.name, err)
+		}
+		variables[definition.name] = coerced
+	}
+	return variables, nil
+}
+
+// coerce converts an input value, from a literal or from JSON variables, to typ
+func (s *gqlSchema) coerce(typ string, value interface{}) (interface{}, error) {
+	if nonNull(typ) {
+		if value == nil {
+			return nil, fmt.Errorf("expected a non-null %s", strings.TrimSuffix(typ, "!"))
+		}
+		return s.coerce(strings.TrimSuffix(typ, "!"), value)
+	}
+	if value == nil {
+		return nil, nil
+	}
+	if strings.HasPrefix(typ, "[") {
+		inner := typ[1 : len(typ)-1]
+		list, ok := value.([]interface{})
+		if !ok {
+			// A single value is accepted where a list is expected
+			list = []interface{}{value}
+		}
+		coerced := make([]interface{}, len(list))
+		for i, item := range list {
+			var err error
+			if coerced[i], err = s.coerce(inner, item); err != nil {
+				return nil, err
+			}
+		}
+		return coerced, nil
+	}
+
+	switch typ {
+	case "String", "ID":
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case int64:
+			if typ == "ID" {
+				return strconv.FormatInt(v, 10), nil
+			}
+		}
+	case "Int":
+		switch v := value.(type) {
+		case int:
+			// Variables are coerced before they are substituted into arguments
+			return v, nil
+		case int64:
+			if v >= math.MinInt32 && v <= math.MaxInt32 {
+				return int(v), nil
+			}
+		case float64:
+			if v == math.Trunc(v) && v >= math.MinInt32 && v <= math.MaxInt32 {
+				return int(v), nil
+			}
+		}
+	case "Float":
+		switch v := value.(type) {
+		case int64:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		}
+	case "Boolean":
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+	default:
+		t := s.types[typ]
+		object, ok := value.(map[string]interface{})
+		if t == nil || t.inputFields == nil || !ok {
+			break
+		}
+		coerced := map[string]interface{}{}
+		for name := range object {
+			if _, ok := t.inputFields[name]; !ok {
+				return nil, fmt.Errorf("%s has no field %s", typ, name)
+			}
+		}
+		for name, field := range t.inputFields {
+			item, ok := object[name]
+			if !ok {
+				item = field.defaultValue
+			}
+			var err error
+			if coerced[name], err = s.coerce(field.typ, item); err != nil {
+				return nil, fmt.Errorf("invalid %s.%s: %v", typ, name, err)
+			}
+		}
+		return coerced, nil
+	}
+	return nil, fmt.Errorf("%v is not a valid %s", describeValue(value), typ)
+}
+
+func describeValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case gqlEnum:
+		return string(v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// substitute replaces the variables in a value with their values; unset variables are left out
+// of lists and objects and make a lone value absent
+func (e *gqlExecution) substitute(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case gqlVariable:
+		value, ok := e.variables[string(v)]
+		return value, ok
+	case []interface{}:
+		list := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			if item, ok := e.substitute(item); ok {
+				list = append(list, item)
+			}
+		}
+		return list, true
+	case map[string]interface{}:
+		object := map[string]interface{}{}
+		for name, item := range v {
+			if item, ok := e.substitute(item); ok {
+				object[name] = item
+			}
+		}
+		return object, true
+	}
+	return value, true
+}
+
+// arguments coerces the arguments of a field selection to the types the field declares
+func (e *gqlExecution) arguments(declared map[string]gqlArgument, given map[string]interface{}) (map[string]interface{}, error) {
+	args := map[string]interface{}{}
+	for name, argument := range declared {
+		value, ok := given[name]
+		if ok {
+			value, ok = e.substitute(value)
+		}
+		if !ok {
+			value = argument.defaultValue
+		}
+		coerced, err := e.schema.coerce(argument.typ, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument %s: %v", name, err)
+		}
+		args[name] = coerced
+	}
+	return args, nil
+}
+
+// included applies @skip and @include
+func (e *gqlExecution) included(directives []gqlDirective) (bool, error) {
+	for _, directive := range directives {
+		args, err := e.arguments(map[string]gqlArgument{"if": {typ: "Boolean!"}}, directive.arguments)
+		if err != nil {
+			return false, fmt.Errorf("@%s: %v", directive.name, err)
+		}
+		if condition := args["if"].(bool); (directive.name == "skip") == condition {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// validate checks the selections of op against the schema before anything is executed
+func (e *gqlExecution) validate(op *gqlOperation) error {
+	defined := map[string]bool{}
+	for _, definition := range op.variables {
+		defined[definition.name] = true
+	}
+
+	roots := 0
+	var walk func(typ *gqlType, selections []*gqlSelection, spreading map[string]bool) error
+	walk = func(typ *gqlType, selections []*gqlSelection, spreading map[string]bool) error {
+		for _, selection := range selections {
+			for _, directive := range selection.directives {
+				if directive.name != "skip" && directive.name != "include" {
+					return fmt.Errorf("unknown directive @%s", directive.name)
+				}
+				if err := checkVariables(directive.arguments, defined); err != nil {
+					return err
+				}
+			}
+
+			switch {
+			case selection.spread != "":
+				fragment := e.doc.fragments[selection.spread]
+				if fragment == nil {
+					return fmt.Errorf("unknown fragment %q", selection.spread)
+				}
+				if spreading[fragment.name] {
+					return fmt.Errorf("fragment %q spreads itself", fragment.name)
+				}
+				if fragment.typeCondition != typ.name {
+					return fmt.Errorf("fragment %q on %s cannot be spread within %s", fragment.name, fragment.typeCondition, typ.name)
+				}
+				spreading[fragment.name] = true
+				err := walk(typ, fragment.selections, spreading)
+				delete(spreading, fragment.name)
+				if err != nil {
+					return err
+				}
+			case selection.inline:
+				if selection.typeCondition != "" && selection.typeCondition != typ.name {
+					return fmt.Errorf("an inline fragment on %s cannot be used within %s", selection.typeCondition, typ.name)
+				}
+				if err := walk(typ, selection.selections, spreading); err != nil {
+					return err
+				}
+			case selection.name == "__typename":
+				if len(selection.arguments) > 0 || selection.selections != nil {
+					return fmt.Errorf("__typename takes no arguments or selections")
+				}
+			default:
+				field := typ.fields[selection.name]
+				if field == nil {
+					return fmt.Errorf("cannot query field %q on type %s", selection.name, typ.name)
+				}
+				if typ.name == e.schema.query {
+					roots++
+				}
+				for name := range selection.arguments {
+					if _, ok := field.arguments[name]; !ok {
+						return fmt.Errorf("unknown argument %q of %s.%s", name, typ.name, selection.name)
+					}
+				}
+				for name, argument := range field.arguments {
+					if _, ok := selection.arguments[name]; !ok && nonNull(argument.typ) && argument.defaultValue == nil {
+						return fmt.Errorf("%s.%s requires the argument %s", typ.name, selection.name, name)
+					}
+				}
+				if err := checkVariables(selection.arguments, defined); err != nil {
+					return err
+				}
+
+				result := e.schema.types[namedType(field.typ)]
+				switch {
+				case result.fields == nil && selection.selections != nil:
+					return fmt.Errorf("%s.%s of type %s has no fields to select", typ.name, selection.name, field.typ)
+				case result.fields != nil && selection.selections == nil:
+					return fmt.Errorf("%s.%s of type %s must have a selection of fields", typ.name, selection.name, field.typ)
+				case result.fields != nil:
+					if err := walk(result, selection.selections, spreading); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := walk(e.schema.types[e.schema.query], op.selections, map[string]bool{}); err != nil {
+		return err
+	}
+	if e.schema.maxRootFields > 0 && roots > e.schema.maxRootFields {
+		return fmt.Errorf("a request may select at most %d fields of %s", e.schema.maxRootFields, e.schema.query)
+	}
+	return nil
+}
+
+// checkVariables reports variables used in arguments that the operation does not define
+func checkVariables(arguments map[string]interface{}, defined map[string]bool) error {
+	var check func(value interface{}) error
+	check = func(value interface{}) error {
+		switch v := value.(type) {
+		case gqlVariable:
+			if !defined[string(v)] {
+				return fmt.Errorf("variable $%s is not defined", v)
+			}
+		case []interface{}:
+			for _, item := range v {
+				if err := check(item); err != nil {
+					return err
+				}
+			}
+		case map[string]interface{}:
+			for _, item := range v {
+				if err := check(item); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return check(map[string]interface{}(arguments))
+}
+
+// collectFields gathers the fields of selections by response key, in order, expanding fragments
+func (e *gqlExecution) collectFields(selections []*gqlSelection, keys *[]string, fields map[string][]*gqlSelection) error {
+	for _, selection := range selections {
+		include, err := e.included(selection.directives)
+		if err != nil {
+			return err
+		}
+		if !include {
+			continue
+		}
+
+		switch {
+		case selection.spread != "":
+			// Validation checked the fragment exists and applies to the type
+			if err := e.collectFields(e.doc.fragments[selection.spread].selections, keys, fields); err != nil {
+				return err
+			}
+		case selection.inline:
+			if err := e.collectFields(selection.selections, keys, fields); err != nil {
+				return err
+			}
+		default:
+			key := selection.responseKey()
+			if _, ok := fields[key]; !ok {
+				*keys = append(*keys, key)
+			}
+			fields[key] = append(fields[key], selection)
+		}
+	}
+	return nil
+}
+
+// executeFields resolves a selection set on parent, a value of typ. It fails when a non-null
+// field is null, which makes the enclosing value null.
+func (e *gqlExecution) executeFields(ctx context.Context, typ *gqlType, parent interface{}, selections []*gqlSelection, path []interface{}) (gqlObject, bool) {
+	var keys []string
+	fields := map[string][]*gqlSelection{}
+	if err := e.collectFields(selections, &keys, fields); err != nil {
+		e.fail(path, err)
+		return nil, false
+	}
+
+	object := gqlObject{}
+	for _, key := range keys {
+		selection := fields[key][0]
+		fieldPath := append(append([]interface{}{}, path...), key)
+		if selection.name == "__typename" {
+			object = append(object, gqlEntry{key: key, value: typ.name})
+			continue
+		}
+
+		field := typ.fields[selection.name]
+		args, err := e.arguments(field.arguments, selection.arguments)
+		var value interface{}
+		if err == nil {
+			value, err = field.resolve(ctx, parent, args)
+		}
+		if err != nil {
+			e.fail(fieldPath, err)
+			if nonNull(field.typ) {
+				return nil, false
+			}
+			object = append(object, gqlEntry{key: key})
+			continue
+		}
+
+		// Fields selected several times under one key select the union of their selections
+		var subselections []*gqlSelection
+		for _, same := range fields[key] {
+			subselections = append(subselections, same.selections...)
+		}
+		completed, ok := e.complete(ctx, field.typ, value, subselections, fieldPath)
+		if !ok {
+			return nil, false
+		}
+		object = append(object, gqlEntry{key: key, value: completed})
+	}
+	return object, true
+}
+
+// complete converts a resolved value to the result of typ
+func (e *gqlExecution) complete(ctx context.Context, typ string, value interface{}, selections []*gqlSelection, path []interface{}) (interface{}, bool) {
+	required := nonNull(typ)
+	typ = strings.TrimSuffix(typ, "!")
+
+	result, ok := e.completeNullable(ctx, typ, value, selections, path)
+	if ok && result == nil && required {
+		e.fail(path, fmt.Errorf("cannot return null for the non-null type %s!", typ))
+		ok = false
+	}
+	if !ok {
+		// A failed value becomes null at the nearest position that allows it
+		return nil, !required
+	}
+	return result, true
+}
+
+func (e *gqlExecution) completeNullable(ctx context.Context, typ string, value interface{}, selections []*gqlSelection, path []interface{}) (interface{}, bool) {
+	v := reflect.ValueOf(value)
+	if value == nil || ((v.Kind() == reflect.Pointer || v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.IsNil()) {
+		return nil, true
+	}
+
+	if strings.HasPrefix(typ, "[") {
+		inner := typ[1 : len(typ)-1]
+		if v.Kind() != reflect.Slice {
+			e.fail(path, fmt.Errorf("expected a list for %s", typ))
+			return nil, false
+		}
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			item, ok := e.complete(ctx, inner, v.Index(i).Interface(), selections, append(append([]interface{}{}, path...), i))
+			if !ok {
+				return nil, false
+			}
+			list[i] = item
+		}
+		return list, true
+	}
+
+	t := e.schema.types[typ]
+	if t.fields == nil {
+		return value, true
+	}
+	object, ok := e.executeFields(ctx, t, value, selections, path)
+	if !ok {
+		return nil, false
+	}
+	return object, true
+}
+
+func (e *gqlExecution) fail(path []interface{}, err error) {
+	e.errors = append(e.errors, GraphQLError{Message: err.Error(), Path: path})
+}
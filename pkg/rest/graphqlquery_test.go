@@ -0,0 +1,286 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/simulator"
+)
+
+func TestParseGraphQLMalformed(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		query string
+		err   string
+	}{
+		{"empty", "", "the document has no operation"},
+		{"comment only", "# nothing to see", "the document has no operation"},
+		{"unclosed selection set", "{ log(id: \"a\") { id }", "unexpected end of document"},
+		{"empty selection set", "{ }", "empty selection set"},
+		{"unterminated string", "{ log(id: \"a) { id } }", "unterminated string at 10"},
+		{"unterminated block string", "{ log(id: \"\"\"a) { id } }", "unterminated block string at 10"},
+		{"invalid escape", `{ log(id: "\q") { id } }`, "invalid string at 10"},
+		{"unexpected character", "{ log(id: \"a\") { id ? } }", "unexpected character '?' at 20"},
+		{"invalid number", "{ logs(first: 1.) { nodes { id } } }", "invalid number at 14"},
+		{"number followed by name", "{ logs(first: 12abc) { nodes { id } } }", "invalid number at 14"},
+		{"integer overflow", "{ logs(first: 99999999999999999999) { nodes { id } } }", "invalid integer 99999999999999999999"},
+		{"missing argument value", "{ log(id:) { id } }", `unexpected ")" at 9`},
+		{"end inside argument", "{ log(id: ", "unexpected end of document"},
+		{"duplicate argument", `{ log(id: "a", id: "b") { id } }`, `there can be only one argument named "id"`},
+		{"variable in default", "query($a: Int = $b) { logs(first: $a) { nodes { id } } }", `unexpected "$" at 16`},
+		{"variable without type", "query($a) { logs(first: $a) { nodes { id } } }", `unexpected ")" at 8`},
+		{"unclosed list type", "query($a: [Int) { logs(first: $a) { nodes { id } } }", `unexpected ")" at 14`},
+		{"stray token", "{ log(id: \"a\") { id } } }", `unexpected "}" at 24`},
+		{"unknown definition", "schema { query: Query }", `unexpected "schema" at 0`},
+		{"fragment named on", "fragment on on LogEvent { id } { log(id: \"a\") { id } }", "a fragment cannot be named on"},
+		{"fragment without type condition", "fragment f LogEvent { id } { log(id: \"a\") { id } }", "expected a type condition for fragment f"},
+		{"duplicate fragment", "fragment f on LogEvent { id } fragment f on LogEvent { action } { log(id: \"a\") { ...f } }", `there can be only one fragment named "f"`},
+		{"spread without name", "{ log(id: \"a\") { ... } }", `unexpected "}" at 21`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseGraphQL(tt.query)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("parseGraphQL(%q) = %v, want an error containing %q", tt.query, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseGraphQLDepth(t *testing.T) {
+	nested := func(depth int, open, inner, close string) string {
+		return strings.Repeat(open, depth) + inner + strings.Repeat(close, depth)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		query func(depth int) string
+	}{
+		{"selection sets", func(depth int) string {
+			return "{" + strings.Repeat(" a {", depth-1) + " a" + strings.Repeat(" }", depth)
+		}},
+		{"lists", func(depth int) string {
+			return "{ log(id: " + nested(depth, "[", "1", "]") + ") { id } }"
+		}},
+		{"input objects", func(depth int) string {
+			return "{ logs(filter: " + nested(depth, "{ a: ", "1", "}") + ") { nodes { id } } }"
+		}},
+		{"list types", func(depth int) string {
+			return "query($a: " + nested(depth, "[", "Int", "]") + ") { log(id: \"a\") { id } }"
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// A selection set or argument holds the nested value, which takes a level of its own
+			if _, err := parseGraphQL(tt.query(maxGraphQLDepth - 1)); err != nil {
+				t.Fatalf("parseGraphQL at depth %d: %v", maxGraphQLDepth-1, err)
+			}
+			for _, depth := range []int{maxGraphQLDepth + 1, 100000} {
+				_, err := parseGraphQL(tt.query(depth))
+				if err == nil || !strings.Contains(err.Error(), "nested deeper than") {
+					t.Fatalf("parseGraphQL at depth %d = %v, want a nesting error", depth, err)
+				}
+			}
+		})
+	}
+}
+
+func TestParseGraphQLFragments(t *testing.T) {
+	doc, err := parseGraphQL(`
+		query Logs($skip: Boolean = false) {
+			log(id: "a") { ...fields ... on LogEvent @skip(if: $skip) { action } ... { resource } }
+		}
+		fragment fields on LogEvent @include(if: true) { id userId }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fragment := doc.fragments["fields"]
+	if fragment == nil || fragment.typeCondition != "LogEvent" || len(fragment.selections) != 2 {
+		t.Fatalf("fragment fields = %+v", fragment)
+	}
+	selections := doc.operations[0].selections[0].selections
+	if len(selections) != 3 {
+		t.Fatalf("got %d selections, want 3", len(selections))
+	}
+	if s := selections[0]; s.spread != "fields" || s.inline {
+		t.Errorf("selection 0 = %+v, want a spread of fields", s)
+	}
+	if s := selections[1]; !s.inline || s.typeCondition != "LogEvent" || len(s.directives) != 1 || s.directives[0].name != "skip" {
+		t.Errorf("selection 1 = %+v, want an inline fragment on LogEvent with @skip", s)
+	}
+	if s := selections[2]; !s.inline || s.typeCondition != "" {
+		t.Errorf("selection 2 = %+v, want an inline fragment without type condition", s)
+	}
+}
+
+// newGraphQLServer returns a server over a simulator holding the given logs
+func newGraphQLServer(t *testing.T, logs ...client.LogEvent) *Server {
+	t.Helper()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	sim := simulator.New(simulator.WithClock(func() time.Time { return now }))
+	for _, log := range logs {
+		if err := sim.CreateLog(context.Background(), log); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewServer(sim, Options{})
+}
+
+func TestExecuteGraphQL(t *testing.T) {
+	s := newGraphQLServer(t,
+		client.LogEvent{ID: "a", UserID: "alice", Action: "LOGIN", Resource: "portal"},
+		client.LogEvent{ID: "b", UserID: "bob", Action: "LOGOUT", Resource: "portal"},
+	)
+
+	for _, tt := range []struct {
+		name      string
+		query     string
+		operation string
+		variables map[string]interface{}
+		data      string
+	}{
+		{
+			name:  "named fragment",
+			query: `{ log(id: "a") { ...who } } fragment who on LogEvent { id userId }`,
+			data:  `{"log":{"id":"a","userId":"alice"}}`,
+		},
+		{
+			name:  "nested fragments merge fields",
+			query: `{ log(id: "a") { id ...outer } } fragment outer on LogEvent { ...inner action } fragment inner on LogEvent { id resource }`,
+			data:  `{"log":{"id":"a","resource":"portal","action":"LOGIN"}}`,
+		},
+		{
+			name:  "inline fragments",
+			query: `{ log(id: "b") { ... on LogEvent { id } ... { action __typename } } }`,
+			data:  `{"log":{"id":"b","action":"LOGOUT","__typename":"LogEvent"}}`,
+		},
+		{
+			name:      "skip and include",
+			query:     `query($yes: Boolean!) { log(id: "a") { id @skip(if: $yes) action @include(if: $yes) ... @skip(if: true) { userId } } }`,
+			variables: map[string]interface{}{"yes": true},
+			data:      `{"log":{"action":"LOGIN"}}`,
+		},
+		{
+			name:  "aliases",
+			query: `{ first: log(id: "a") { id } second: log(id: "b") { id } missing: log(id: "c") { id } }`,
+			data:  `{"first":{"id":"a"},"second":{"id":"b"},"missing":null}`,
+		},
+		{
+			name:      "operation name and variables",
+			query:     `query One($id: ID!) { log(id: $id) { id } } query Two { log(id: "b") { userId } }`,
+			operation: "One",
+			variables: map[string]interface{}{"id": "a"},
+			data:      `{"log":{"id":"a"}}`,
+		},
+		{
+			name:  "connection",
+			query: `{ logs(filter: {user: "bob"}, first: 5) { nodes { id } pageInfo { hasNextPage endCursor } } }`,
+			data:  `{"logs":{"nodes":[{"id":"b"}],"pageInfo":{"hasNextPage":false,"endCursor":null}}}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			data, errs, err := s.graphQL.execute(context.Background(), tt.query, tt.operation, tt.variables)
+			if err != nil || len(errs) > 0 {
+				t.Fatalf("execute: %v %v", err, errs)
+			}
+			if string(data) != tt.data {
+				t.Errorf("data = %s, want %s", data, tt.data)
+			}
+		})
+	}
+}
+
+func TestExecuteGraphQLRejected(t *testing.T) {
+	s := newGraphQLServer(t)
+
+	tooMany := "{"
+	for i := 0; i <= maxGraphQLRootFields; i++ {
+		tooMany += " l" + string(rune('a'+i)) + `: log(id: "a") { id }`
+	}
+	tooMany += " }"
+
+	for _, tt := range []struct {
+		name      string
+		query     string
+		operation string
+		variables map[string]interface{}
+		err       string
+	}{
+		{"syntax error", "{ log(id: ", "", nil, "unexpected end of document"},
+		{"mutation", `mutation { log(id: "a") { id } }`, "", nil, "mutation operations are not supported"},
+		{"several operations", `query A { log(id: "a") { id } } query B { log(id: "b") { id } }`, "", nil, "operationName is required"},
+		{"unknown operation", `query A { log(id: "a") { id } }`, "B", nil, `unknown operation "B"`},
+		{"unknown fragment", `{ log(id: "a") { ...nope } }`, "", nil, `unknown fragment "nope"`},
+		{"fragment cycle", `{ log(id: "a") { ...f } } fragment f on LogEvent { ...g } fragment g on LogEvent { ...f }`, "", nil, `fragment "f" spreads itself`},
+		{"fragment on another type", `{ log(id: "a") { ...f } } fragment f on Receipt { status }`, "", nil, `fragment "f" on Receipt cannot be spread within LogEvent`},
+		{"inline fragment on another type", `{ log(id: "a") { ... on Query { log(id: "b") { id } } } }`, "", nil, "an inline fragment on Query cannot be used within LogEvent"},
+		{"unknown field", `{ log(id: "a") { secret } }`, "", nil, `cannot query field "secret" on type LogEvent`},
+		{"unknown argument", `{ log(id: "a", channel: "x") { id } }`, "", nil, `unknown argument "channel" of Query.log`},
+		{"missing argument", `{ log { id } }`, "", nil, "Query.log requires the argument id"},
+		{"scalar with selections", `{ log(id: "a") { id { value } } }`, "", nil, "LogEvent.id of type ID! has no fields to select"},
+		{"object without selections", `{ log(id: "a") }`, "", nil, "Query.log of type LogEvent must have a selection of fields"},
+		{"unknown directive", `{ log(id: "a") @defer { id } }`, "", nil, "unknown directive @defer"},
+		{"undefined variable", `{ log(id: $id) { id } }`, "", nil, "variable $id is not defined"},
+		{"missing variable", `query($id: ID!) { log(id: $id) { id } }`, "", nil, "variable $id of type ID! was not provided"},
+		{"invalid variable", `query($n: Int) { logs(first: $n) { nodes { id } } }`, "", map[string]interface{}{"n": "ten"}, `invalid value of variable $n: "ten" is not a valid Int`},
+		{"output type variable", `query($l: LogEvent) { log(id: "a") { id } }`, "", nil, "variable $l has the type LogEvent, which is not an input type"},
+		{"too many root fields", tooMany, "", nil, "a request may select at most 20 fields of Query"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := s.graphQL.execute(context.Background(), tt.query, tt.operation, tt.variables)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("execute = %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestExecuteGraphQLFieldErrors(t *testing.T) {
+	s := newGraphQLServer(t, client.LogEvent{ID: "a", UserID: "alice", Action: "LOGIN", Resource: "portal"})
+
+	data, errs, err := s.graphQL.execute(context.Background(), `{ log(id: "a") { id } logs(first: 0) { nodes { id } } }`, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// logs is non-null, so its failure nulls the whole result
+	if string(data) != "null" {
+		t.Errorf("data = %s, want null", data)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Message, "first must be from 1") {
+		t.Fatalf("errors = %+v", errs)
+	}
+	if path, _ := json.Marshal(errs[0].Path); string(path) != `["logs"]` {
+		t.Errorf("path = %s, want [\"logs\"]", path)
+	}
+}
+
+func TestGraphQLEndpoint(t *testing.T) {
+	s := newGraphQLServer(t, client.LogEvent{ID: "a", UserID: "alice", Action: "LOGIN", Resource: "portal"})
+
+	for _, tt := range []struct {
+		name string
+		body string
+		want string
+	}{
+		{"query", `{"query": "{ log(id: \"a\") { ...f } } fragment f on LogEvent { id }"}`, `{"data":{"log":{"id":"a"}}}`},
+		{"malformed query", `{"query": "{ log(id: \"a\") { id "}`, `{"errors":[{"message":"syntax error: unexpected end of document"}]}`},
+		{"too deep", `{"query": "{ log(id: ` + strings.Repeat("[", 200) + `"}`, `{"errors":[{"message":"the document is nested deeper than 64 levels"}]}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(tt.body)))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			if got := strings.TrimSpace(w.Body.String()); got != tt.want {
+				t.Errorf("body = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// healthTimeout bounds each probe of the backend
+const healthTimeout = 5 * time.Second
+
+// connectionChecker is implemented by clients reporting whether their gateway connections are usable
+type connectionChecker interface {
+	CheckConnections() error
+}
+
+// pinger is implemented by clients that can check the chaincode answers
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// HealthResponse is the body of a passing /healthz or /readyz
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+// Drain prepares the server for shutdown: /readyz fails from then on, so load balancers stop
+// routing to it, and live streams end, so that http.Server.Shutdown only waits for requests that
+// complete, such as submissions awaiting commit. Streams opened after Drain end at once.
+func (s *Server) Drain() {
+	s.drain()
+}
+
+// streamContext returns a context of ctx that is also done once the server drains
+func (s *Server) streamContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(s.draining, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// checkHealth reports whether the process can serve: it fails when no gateway peer can be reached
+func (s *Server) checkHealth(w http.ResponseWriter, r *http.Request) {
+	if err := s.health(); err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// health returns why the backend cannot serve, if it cannot
+func (s *Server) health() error {
+	if checker, ok := s.backend.(connectionChecker); ok {
+		return checker.CheckConnections()
+	}
+	return nil
+}
+
+// checkReady reports whether the server should receive traffic: it fails while draining and when
+// the chaincode does not answer Ping
+func (s *Server) checkReady(w http.ResponseWriter, r *http.Request) {
+	if err := s.readiness(r.Context()); err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// readiness returns why the server should not receive traffic, if it should not
+func (s *Server) readiness(ctx context.Context) error {
+	if s.draining.Err() != nil {
+		return fmt.Errorf("the server is shutting down")
+	}
+	if p, ok := s.backend.(pinger); ok {
+		ctx, cancel := context.WithTimeout(ctx, healthTimeout)
+		defer cancel()
+		if err := p.Ping(ctx); err != nil {
+			return fmt.Errorf("the chaincode does not answer: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,402 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+	"gopkg.in/yaml.v2"
+)
+
+// specYAML is the OpenAPI 3 document describing the API. It is the source of truth for routing
+// and request validation: every operation it declares is served by the handler registered under
+// its operationId, and requests are checked against its parameter and body schemas before the
+// handler runs. Client SDKs can be generated from it.
+//
+//go:embed openapi.yaml
+var specYAML []byte
+
+// Spec returns the OpenAPI document of the API
+func Spec() []byte {
+	return append([]byte(nil), specYAML...)
+}
+
+// operation is one method on one path of the OpenAPI document
+type operation struct {
+	id       string
+	method   string
+	segments []string
+	// literals counts the segments that are not templates; the most specific path wins
+	literals   int
+	parameters []parameter
+	body       *gojsonschema.Schema
+	// lines validates each line of an NDJSON body. Such bodies are streamed to the handler
+	// unread, so it checks each line itself with validateLine.
+	lines *gojsonschema.Schema
+	// bodyRequired rejects requests without a body
+	bodyRequired bool
+	// public operations are served without authentication
+	public bool
+	// roles may use the operation, given by its x-roles extension
+	roles []Role
+	// queryToken operations accept credentials in an access_token query parameter
+	queryToken bool
+	// audited operations are recorded by the server's AccessAuditor, given by their x-audit extension
+	audited bool
+}
+
+// parameter is a query or path parameter of an operation
+type parameter struct {
+	name     string
+	in       string
+	required bool
+	// typ is the JSON type of the parameter, used to convert the raw value before validation
+	typ    string
+	schema *gojsonschema.Schema
+}
+
+type pathParamsKey struct{}
+
+type operationKey struct{}
+
+// errBodyTooLarge is returned by validate for request bodies over the size limit
+var errBodyTooLarge = errors.New("request body too large")
+
+// loadOperations compiles the operations of the OpenAPI document
+func loadOperations(spec []byte) ([]*operation, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(spec, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %v", err)
+	}
+	doc, ok := normalize(raw).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("OpenAPI document is not an object")
+	}
+	components, _ := doc["components"].(map[string]interface{})
+	security, _ := doc["security"].([]interface{})
+
+	// Schemas are compiled with the document's components alongside, so their $refs resolve
+	compile := func(schema interface{}) (*gojsonschema.Schema, error) {
+		root := map[string]interface{}{"components": components}
+		object, ok := schema.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("schema is not an object")
+		}
+		for k, v := range object {
+			root[k] = v
+		}
+		return gojsonschema.NewSchema(gojsonschema.NewGoLoader(root))
+	}
+
+	paths, _ := doc["paths"].(map[string]interface{})
+	var operations []*operation
+	for path, item := range paths {
+		methods, _ := item.(map[string]interface{})
+		for method, value := range methods {
+			definition, _ := value.(map[string]interface{})
+			op := &operation{
+				method:   strings.ToUpper(method),
+				segments: strings.Split(strings.Trim(path, "/"), "/"),
+			}
+			op.id, _ = definition["operationId"].(string)
+			if op.id == "" {
+				return nil, fmt.Errorf("%s %s has no operationId", op.method, path)
+			}
+			for _, segment := range op.segments {
+				if !strings.HasPrefix(segment, "{") {
+					op.literals++
+				}
+			}
+
+			// An operation's own security requirements replace the document's; none makes it public
+			requirements := security
+			if own, ok := definition["security"].([]interface{}); ok {
+				requirements = own
+			}
+			op.public = len(requirements) == 0
+			roles, _ := definition["x-roles"].([]interface{})
+			for _, value := range roles {
+				name, _ := value.(string)
+				role, err := ParseRole(name)
+				if err != nil {
+					return nil, fmt.Errorf("invalid x-roles of %s: %v", op.id, err)
+				}
+				op.roles = append(op.roles, role)
+			}
+			op.audited, _ = definition["x-audit"].(bool)
+			if !op.public && len(op.roles) == 0 {
+				return nil, fmt.Errorf("%s requires authentication but declares no x-roles", op.id)
+			}
+
+			params, _ := definition["parameters"].([]interface{})
+			for _, value := range params {
+				p, _ := value.(map[string]interface{})
+				if ref, ok := p["$ref"].(string); ok {
+					shared, _ := components["parameters"].(map[string]interface{})
+					p, _ = shared[strings.TrimPrefix(ref, "#/components/parameters/")].(map[string]interface{})
+					if p == nil {
+						return nil, fmt.Errorf("unresolved parameter %s of %s", ref, op.id)
+					}
+				}
+				param := parameter{}
+				param.name, _ = p["name"].(string)
+				param.in, _ = p["in"].(string)
+				param.required, _ = p["required"].(bool)
+				if param.in == "query" && param.name == "access_token" {
+					op.queryToken = true
+				}
+				schema, _ := p["schema"].(map[string]interface{})
+				param.typ = schemaType(schema, components)
+				var err error
+				if param.schema, err = compile(schema); err != nil {
+					return nil, fmt.Errorf("failed to compile schema of parameter %s of %s: %v", param.name, op.id, err)
+				}
+				op.parameters = append(op.parameters, param)
+			}
+
+			if body, ok := definition["requestBody"].(map[string]interface{}); ok {
+				op.bodyRequired, _ = body["required"].(bool)
+				content, _ := body["content"].(map[string]interface{})
+				var err error
+				if media, ok := content["application/x-ndjson"].(map[string]interface{}); ok {
+					if op.lines, err = compile(media["schema"]); err != nil {
+						return nil, fmt.Errorf("failed to compile request body schema of %s: %v", op.id, err)
+					}
+				} else {
+					media, _ := content["application/json"].(map[string]interface{})
+					if op.body, err = compile(media["schema"]); err != nil {
+						return nil, fmt.Errorf("failed to compile request body schema of %s: %v", op.id, err)
+					}
+				}
+			}
+
+			operations = append(operations, op)
+		}
+	}
+
+	// Paths with more literal segments are matched first, so /logs/batch wins over /logs/{id}
+	sort.SliceStable(operations, func(i, j int) bool {
+		return operations[i].literals > operations[j].literals
+	})
+
+	return operations, nil
+}
+
+// match finds the operation for a request. It returns the path parameters of the operation, or
+// when the path is declared but not for this method, the methods it allows.
+func match(operations []*operation, method string, escapedPath string) (*operation, map[string]string, []string) {
+	segments := strings.Split(strings.Trim(escapedPath, "/"), "/")
+
+	var allowed []string
+	var matchedLiterals = -1
+	for _, op := range operations {
+		params, ok := op.matchPath(segments)
+		if !ok || (matchedLiterals >= 0 && op.literals < matchedLiterals) {
+			continue
+		}
+		matchedLiterals = op.literals
+		if op.method == method || (method == http.MethodHead && op.method == http.MethodGet) {
+			return op, params, nil
+		}
+		allowed = append(allowed, op.method)
+	}
+	sort.Strings(allowed)
+
+	return nil, nil, allowed
+}
+
+func (op *operation) matchPath(segments []string) (map[string]string, bool) {
+	if len(segments) != len(op.segments) {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	for i, segment := range op.segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			value, err := url.PathUnescape(segments[i])
+			if err != nil || value == "" {
+				return nil, false
+			}
+			params[segment[1:len(segment)-1]] = value
+			continue
+		}
+		if segment != segments[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+// validate checks the request's parameters and body against the operation's schemas. The body is
+// read, so it is replaced by a copy the handler can read again.
+func (op *operation) validate(r *http.Request, pathParams map[string]string, maxBodyBytes int64) error {
+	query := r.URL.Query()
+	declared := map[string]bool{}
+	for _, param := range op.parameters {
+		var value string
+		var present bool
+		switch param.in {
+		case "path":
+			value, present = pathParams[param.name]
+		case "query":
+			declared[param.name] = true
+			present = query.Has(param.name)
+			value = query.Get(param.name)
+		default:
+			continue
+		}
+		if !present {
+			if param.required {
+				return fmt.Errorf("missing required parameter %s", param.name)
+			}
+			continue
+		}
+
+		typed, err := convert(value, param.typ)
+		if err != nil {
+			return fmt.Errorf("invalid parameter %s: %v", param.name, err)
+		}
+		if err := check(param.schema, gojsonschema.NewGoLoader(typed)); err != nil {
+			return fmt.Errorf("invalid parameter %s: %v", param.name, err)
+		}
+	}
+	for name := range query {
+		if !declared[name] {
+			return fmt.Errorf("unknown parameter %s", name)
+		}
+	}
+
+	if op.body == nil {
+		return nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+	if err != nil {
+		return fmt.Errorf("failed to read request body: %v", err)
+	}
+	if int64(len(body)) > maxBodyBytes {
+		return fmt.Errorf("%w: the limit is %d bytes", errBodyTooLarge, maxBodyBytes)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.bodyRequired {
+			return fmt.Errorf("missing request body")
+		}
+		return nil
+	}
+	if !json.Valid(body) {
+		return fmt.Errorf("request body is not valid JSON")
+	}
+
+	return check(op.body, gojsonschema.NewBytesLoader(body))
+}
+
+// validateLine checks one line of an NDJSON body against the operation's line schema
+func (op *operation) validateLine(line []byte) error {
+	if !json.Valid(line) {
+		return fmt.Errorf("line is not valid JSON")
+	}
+	return check(op.lines, gojsonschema.NewBytesLoader(line))
+}
+
+// check validates a document against schema, describing every violation found
+func check(schema *gojsonschema.Schema, document gojsonschema.JSONLoader) error {
+	result, err := schema.Validate(document)
+	if err != nil {
+		return err
+	}
+	if result.Valid() {
+		return nil
+	}
+
+	var violations []string
+	for _, violation := range result.Errors() {
+		violations = append(violations, violation.String())
+	}
+	return fmt.Errorf("%s", strings.Join(violations, "; "))
+}
+
+// convert turns a raw parameter value into the JSON type its schema expects
+func convert(value string, typ string) (interface{}, error) {
+	switch typ {
+	case "integer":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", value)
+		}
+		return n, nil
+	case "number":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", value)
+		}
+		return f, nil
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", value)
+		}
+		return b, nil
+	default:
+		return value, nil
+	}
+}
+
+// schemaType returns the type of schema, following a $ref into components
+func schemaType(schema map[string]interface{}, components map[string]interface{}) string {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		schemas, _ := components["schemas"].(map[string]interface{})
+		target, _ := schemas[name].(map[string]interface{})
+		return schemaType(target, components)
+	}
+	typ, _ := schema["type"].(string)
+	return typ
+}
+
+// normalize converts the map[interface{}]interface{} values decoded from YAML into the
+// map[string]interface{} values of JSON
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			object[fmt.Sprint(key)] = normalize(item)
+		}
+		return object
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalize(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// pathParam returns a path parameter of the request's operation
+func pathParam(r *http.Request, name string) string {
+	params, _ := r.Context().Value(pathParamsKey{}).(map[string]string)
+	return params[name]
+}
+
+// operationOf returns the operation the request was routed to
+func operationOf(r *http.Request) *operation {
+	op, _ := r.Context().Value(operationKey{}).(*operation)
+	return op
+}
+
+func withOperation(r *http.Request, op *operation, params map[string]string) *http.Request {
+	ctx := context.WithValue(r.Context(), operationKey{}, op)
+	return r.WithContext(context.WithValue(ctx, pathParamsKey{}, params))
+}
@@ -0,0 +1,171 @@
+package rest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// Page sizes of GET /logs
+const (
+	// DefaultPageLimit is the number of logs returned when the request sets no limit
+	DefaultPageLimit = 100
+	// MaxPageLimit is the largest limit a request may set
+	MaxPageLimit = 1000
+)
+
+// ErrInvalidCursor is returned for page cursors that no previous page returned
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// pager is implemented by clients that query the chaincode a page at a time
+type pager interface {
+	QueryLogsPage(ctx context.Context, filter client.LogFilter, pageSize int32, bookmark string) (*client.LogPage, error)
+}
+
+// cursor is the position of the next page of a query. It is opaque to clients, which send back
+// the value the Link header of the previous page gives them.
+type cursor struct {
+	// Bookmark is the chaincode bookmark the next page is fetched from
+	Bookmark string `json:"b,omitempty"`
+	// Skip counts the logs after Bookmark already returned
+	Skip int `json:"s,omitempty"`
+	// PageSize is the chaincode page size Bookmark was fetched with, so Skip stays within a page
+	PageSize int32 `json:"n,omitempty"`
+}
+
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	if s == "" {
+		return c, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil || c.Skip < 0 || c.PageSize < 0 || (c.PageSize > 0 && c.Skip >= int(c.PageSize)) {
+		return cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// QueryPage returns up to limit logs of backend matching query, from the cursor of a previous page
+// or "" for the first, and the cursor of the next page, or "" after the last one. It pages as
+// GET /logs does, so other APIs of the gateway share its cursors.
+func QueryPage(ctx context.Context, backend client.LoggingClient, query *client.LogQuery, limit int, pageCursor string) ([]*client.LogEvent, string, error) {
+	at, err := decodeCursor(pageCursor)
+	if err != nil {
+		return nil, "", err
+	}
+	logs, next, err := queryPage(ctx, backend, query, limit, at)
+	if err != nil || next == nil {
+		return logs, "", err
+	}
+	return logs, next.encode(), nil
+}
+
+// queryPage returns up to limit logs matching query from the position at, and the position of the
+// next page, or nil after the last one. Criteria the chaincode cannot apply are applied to its
+// results, so a page may take several chaincode queries to fill.
+func queryPage(ctx context.Context, backend client.LoggingClient, query *client.LogQuery, limit int, at cursor) ([]*client.LogEvent, *cursor, error) {
+	filter, err := query.Filter()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p, ok := backend.(pager)
+	if !ok {
+		return queryAll(ctx, backend, query, filter, limit, at)
+	}
+
+	pageSize := at.PageSize
+	if pageSize == 0 {
+		pageSize = int32(limit)
+		if query.FiltersOnClient() {
+			pageSize = MaxPageLimit
+		}
+	}
+
+	logs := []*client.LogEvent{}
+	bookmark, skip := at.Bookmark, at.Skip
+	for {
+		page, err := p.QueryLogsPage(ctx, filter, pageSize, bookmark)
+		if err != nil {
+			return nil, nil, err
+		}
+		last := len(page.Records) < int(pageSize) || page.Bookmark == ""
+
+		for i := skip; i < len(page.Records); i++ {
+			if !query.Matches(page.Records[i]) {
+				continue
+			}
+			logs = append(logs, page.Records[i])
+			if len(logs) < limit {
+				continue
+			}
+			switch {
+			case i+1 < len(page.Records):
+				return logs, &cursor{Bookmark: bookmark, Skip: i + 1, PageSize: pageSize}, nil
+			case last:
+				return logs, nil, nil
+			default:
+				return logs, &cursor{Bookmark: page.Bookmark, PageSize: pageSize}, nil
+			}
+		}
+
+		if last {
+			return logs, nil, nil
+		}
+		bookmark, skip = page.Bookmark, 0
+	}
+}
+
+// queryAll pages through the complete results of clients that cannot query a page at a time
+func queryAll(ctx context.Context, backend client.LoggingClient, query *client.LogQuery, filter client.LogFilter, limit int, at cursor) ([]*client.LogEvent, *cursor, error) {
+	all, err := backend.QueryLogs(ctx, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logs := []*client.LogEvent{}
+	for i := at.Skip; i < len(all); i++ {
+		if !query.Matches(all[i]) {
+			continue
+		}
+		logs = append(logs, all[i])
+		if len(logs) == limit && i+1 < len(all) {
+			return logs, &cursor{Skip: i + 1}, nil
+		}
+	}
+	return logs, nil, nil
+}
+
+// setLinks sets the Link header (RFC 8288) of a page of r's results: first always, next when
+// another page follows
+func setLinks(w http.ResponseWriter, r *http.Request, next *cursor) {
+	link := func(rel string, c *cursor) string {
+		query := r.URL.Query()
+		query.Del("cursor")
+		if c != nil {
+			query.Set("cursor", c.encode())
+		}
+		target := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+		return fmt.Sprintf("<%s>; rel=%q", target.String(), rel)
+	}
+
+	w.Header().Add("Link", link("first", nil))
+	if next != nil {
+		w.Header().Add("Link", link("next", next))
+	}
+}
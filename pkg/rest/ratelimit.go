@@ -0,0 +1,201 @@
+package rest
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricsNamespace prefixes every metric exported by the server, as it does the client's
+const metricsNamespace = "fabric_logging"
+
+// rateLimitSweep is how often buckets left unused until they refilled are forgotten
+const rateLimitSweep = time.Minute
+
+// RateLimit is a token bucket admitting Rate requests per second on average, in bursts of up to
+// Burst requests. A zero Rate disables the limit.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RequestLimiterOptions configures a RequestLimiter
+type RequestLimiterOptions struct {
+	// PerCaller limits each authenticated caller: each API key, or each token subject
+	PerCaller RateLimit
+	// PerIP limits each client address, before authentication
+	PerIP RateLimit
+	// ForwardedFor takes the client address from the last entry of X-Forwarded-For. Only set it
+	// when a proxy in front of the server sets that header, or clients could choose their address.
+	ForwardedFor bool
+	// Registerer receives the limiter's metrics; prometheus.DefaultRegisterer is used when nil
+	Registerer prometheus.Registerer
+}
+
+// RequestLimiter rejects requests beyond the rate limits of their caller or client address with
+// 429 Too Many Requests, so no client can flood the Fabric network through the gateway.
+// Public operations, such as the health probes, are not limited.
+type RequestLimiter struct {
+	options  RequestLimiterOptions
+	callers  *bucketSet
+	ips      *bucketSet
+	rejected *prometheus.CounterVec
+}
+
+// bucketSet holds a token bucket per key, created on first use
+type bucketSet struct {
+	limit RateLimit
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+type bucket struct {
+	limiter *client.RateLimiter
+	used    time.Time
+}
+
+// NewRequestLimiter returns a limiter applying the limits of options and registers its metrics
+func NewRequestLimiter(options RequestLimiterOptions) (*RequestLimiter, error) {
+	if options.Registerer == nil {
+		options.Registerer = prometheus.DefaultRegisterer
+	}
+
+	l := &RequestLimiter{
+		options: options,
+		callers: newBucketSet(options.PerCaller),
+		ips:     newBucketSet(options.PerIP),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "rest",
+			Name:      "rate_limited_requests_total",
+			Help:      "Requests rejected with 429 for exceeding a rate limit, by the limit exceeded.",
+		}, []string{"limit"}),
+	}
+
+	collectors := []prometheus.Collector{l.rejected}
+	for name, set := range map[string]*bucketSet{"caller": l.callers, "ip": l.ips} {
+		if set == nil {
+			continue
+		}
+		collectors = append(collectors, prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   metricsNamespace,
+			Subsystem:   "rest",
+			Name:        "rate_limit_buckets",
+			Help:        "Callers or client addresses whose rate limit is being tracked.",
+			ConstLabels: prometheus.Labels{"limit": name},
+		}, set.len))
+	}
+	for _, collector := range collectors {
+		if err := options.Registerer.Register(collector); err != nil {
+			return nil, fmt.Errorf("failed to register rate limit metrics: %v", err)
+		}
+	}
+
+	return l, nil
+}
+
+func newBucketSet(limit RateLimit) *bucketSet {
+	if limit.Rate <= 0 {
+		return nil
+	}
+	if limit.Burst < 1 {
+		limit.Burst = int(math.Ceil(limit.Rate))
+	}
+	return &bucketSet{limit: limit, buckets: map[string]*bucket{}, swept: time.Now()}
+}
+
+// allowIP takes a token from the bucket of r's client address, answering 429 and returning false
+// when there is none
+func (l *RequestLimiter) allowIP(w http.ResponseWriter, r *http.Request) bool {
+	return l.allow(w, l.ips, "ip", l.clientIP(r))
+}
+
+// allowCaller takes a token from the bucket of an authenticated caller, answering 429 and
+// returning false when there is none
+func (l *RequestLimiter) allowCaller(w http.ResponseWriter, principal *Principal) bool {
+	return l.allow(w, l.callers, "caller", principal.Subject)
+}
+
+func (l *RequestLimiter) allow(w http.ResponseWriter, set *bucketSet, name string, key string) bool {
+	if set == nil {
+		return true
+	}
+
+	wait, ok := set.take(key)
+	if ok {
+		return true
+	}
+	l.rejected.WithLabelValues(name).Inc()
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	writeError(w, http.StatusTooManyRequests, fmt.Errorf("the %s rate limit of %g requests per second is exceeded; retry in %s", name, set.limit.Rate, time.Duration(seconds)*time.Second))
+	return false
+}
+
+// clientIP is the address r came from, without its port
+func (l *RequestLimiter) clientIP(r *http.Request) string {
+	if l.options.ForwardedFor {
+		if hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ","); hops[len(hops)-1] != "" {
+			return strings.TrimSpace(hops[len(hops)-1])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// take takes a token from key's bucket, or returns how long until one is available
+func (s *bucketSet) take(key string) (time.Duration, bool) {
+	now := time.Now()
+	s.mu.Lock()
+	s.sweep(now)
+	b := s.buckets[key]
+	if b == nil {
+		b = &bucket{limiter: client.NewRateLimiter(s.limit.Rate, s.limit.Burst)}
+		s.buckets[key] = b
+	}
+	b.used = now
+	s.mu.Unlock()
+
+	if b.limiter.Allow(1) {
+		return 0, true
+	}
+	return b.limiter.Delay(1), false
+}
+
+// sweep forgets the buckets unused for long enough to have refilled, which a new bucket would
+// match. The caller holds mu.
+func (s *bucketSet) sweep(now time.Time) {
+	if now.Sub(s.swept) < rateLimitSweep {
+		return
+	}
+	s.swept = now
+
+	refill := time.Duration(float64(s.limit.Burst) / s.limit.Rate * float64(time.Second))
+	for key, b := range s.buckets {
+		if now.Sub(b.used) > refill {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func (s *bucketSet) len() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return float64(len(s.buckets))
+}
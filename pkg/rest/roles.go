@@ -0,0 +1,108 @@
+package rest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Role is what a caller is entitled to do. Every operation of the OpenAPI document lists the
+// roles that may use it in its x-roles extension; RoleAdmin may use every operation.
+type Role string
+
+// Roles of callers
+const (
+	// RoleReader reads and queries logs
+	RoleReader Role = "reader"
+	// RoleWriter records logs
+	RoleWriter Role = "writer"
+	// RoleAuditor reads logs and uses the export and history endpoints
+	RoleAuditor Role = "auditor"
+	// RoleAdmin may use every operation, including API key management
+	RoleAdmin Role = "admin"
+)
+
+// defaultRoles are given to OIDC callers when no role claim is configured, so deployments that do not
+// assign roles keep working
+var defaultRoles = []Role{RoleReader, RoleWriter}
+
+// scopeRoles grants each API key scope its role
+var scopeRoles = map[Scope]Role{
+	ScopeRead:  RoleReader,
+	ScopeWrite: RoleWriter,
+	ScopeAudit: RoleAuditor,
+	ScopeAdmin: RoleAdmin,
+}
+
+// ParseRole returns the role named s
+func ParseRole(s string) (Role, error) {
+	switch role := Role(strings.TrimSpace(s)); role {
+	case RoleReader, RoleWriter, RoleAuditor, RoleAdmin:
+		return role, nil
+	default:
+		return "", fmt.Errorf("unknown role %q", s)
+	}
+}
+
+// ParseRoles parses a comma-separated list of roles
+func ParseRoles(s string) ([]Role, error) {
+	roles := []Role{}
+	for _, name := range strings.Split(s, ",") {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		role, err := ParseRole(name)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+// rolesFromClaims reads the roles of a token from claim, a dotted path such as
+// realm_access.roles. The claim may be an array of strings or a space-separated string, as the
+// scope claim is. Values are translated by mapping when it is set and unknown values are ignored.
+func rolesFromClaims(claims map[string]interface{}, claim string, mapping map[string]Role) []Role {
+	var value interface{} = claims
+	for _, name := range strings.Split(claim, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+
+	var names []string
+	switch v := value.(type) {
+	case string:
+		names = strings.Fields(v)
+	case []interface{}:
+		for _, item := range v {
+			if name, ok := item.(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+
+	var roles []Role
+	for _, name := range names {
+		if mapping != nil {
+			if role, ok := mapping[name]; ok {
+				roles = append(roles, role)
+			}
+			continue
+		}
+		if role, err := ParseRole(name); err == nil {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+func joinRoles(roles []Role) string {
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = string(role)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,528 @@
+// Package rest exposes the logging chaincode over HTTP and JSON, so consumers without a Fabric
+// SDK, including browsers, can record and query logs through a gateway client.
+//
+// The API is described by the OpenAPI document returned by Spec and served at /openapi.yaml.
+// Requests are routed and validated by that document:
+//
+//	POST /logs          record one log, returning its ID
+//	POST /logs/batch    record several logs in one transaction
+//	POST /logs/bulk     record an NDJSON stream of logs in batches, reporting on every line
+//	GET  /logs/{id}     read one log
+//	GET  /logs          query logs a page at a time, following the Link header
+//	GET  /logs/stream   follow newly committed logs as Server-Sent Events
+//	GET  /logs/tail     follow newly committed logs over a WebSocket
+//	POST /exports       export logs to files in the background
+//	POST /ingest/webhook/{source}  record the audit events a SaaS product delivers by webhook
+//	POST /grafana/query count logs over time for Grafana, with /grafana/search and /grafana/annotations
+//	GET  /admin/config  read the settings the chaincode records, changed with PUT /admin/config/retention
+//	GET  /healthz       check the gateway peers can be reached
+//	GET  /readyz        check the chaincode answers and the server is not draining
+//
+// A TenantRouter serves several tenants, each through a Server of its own backend.
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// DefaultMaxBodyBytes limits request bodies when Options leaves the limit unset
+const DefaultMaxBodyBytes = 4 << 20
+
+// Options configures a Server
+type Options struct {
+	// IDGenerator assigns IDs to logs posted without one; client.UUIDv7 is used when nil
+	IDGenerator client.IDGenerator
+	// MaxBodyBytes limits the size of request bodies, and of each line of NDJSON bodies, which are
+	// otherwise unlimited; DefaultMaxBodyBytes is used when zero
+	MaxBodyBytes int64
+	// BulkBatchSize is the most logs of a POST /logs/bulk body submitted in one transaction;
+	// client.DefaultMaxBatchSize is used when zero
+	BulkBatchSize int
+
+	// Authenticator identifies the caller of every operation the OpenAPI document does not mark
+	// public. Every request is accepted when nil, which is only safe on a trusted network.
+	Authenticator Authenticator
+	// APIKeys, when set, authenticates requests by API key besides Authenticator and serves the
+	// /apikeys administration endpoints to admins
+	APIKeys *APIKeys
+	// RateLimiter, when set, limits the request rate of each caller and client address
+	RateLimiter *RequestLimiter
+	// AccessAuditor, when set, records every request to the operations the OpenAPI document marks
+	// with x-audit: administration and exports
+	AccessAuditor AccessAuditor
+	// AuditErrors is called when AccessAuditor fails to record a request, which is otherwise
+	// ignored since the request has been served
+	AuditErrors func(record AccessRecord, err error)
+	// TailBuffer is the number of logs queued for each /logs/tail connection before logs are
+	// dropped for it; DefaultTailBuffer is used when zero
+	TailBuffer int
+	// Exports, when set, runs the export jobs of /exports
+	Exports *ExportJobs
+	// Webhooks, when set, records the deliveries of /ingest/webhook/{source}, which are
+	// authenticated by their source's signature rather than Authenticator
+	Webhooks *Webhooks
+	// BindUserID records logs under the authenticated caller's user ID and rejects logs naming
+	// another user. With a client.UserIdentityClient backend, each caller's logs are then signed
+	// by the caller's own Fabric identity.
+	BindUserID bool
+	// Tenant, when set, serves only the callers of that tenant, as the server of a tenant in a
+	// TenantRouter; others are refused even when they hold the operation's roles
+	Tenant string
+}
+
+// submitter is implemented by clients reporting the transaction that recorded a submission
+type submitter interface {
+	SubmitLog(ctx context.Context, log client.LogEvent, opts ...client.SubmitOption) (*client.SubmitResult, error)
+	SubmitLogsBatch(ctx context.Context, logs []client.LogEvent, opts ...client.SubmitOption) (*client.SubmitResult, error)
+}
+
+// Server is an http.Handler translating REST calls into calls of a LoggingClient
+type Server struct {
+	backend       client.LoggingClient
+	generateID    client.IDGenerator
+	maxBodyBytes  int64
+	bulkBatchSize int
+	tailBuffer    int
+	authenticator Authenticator
+	apiKeys       *APIKeys
+	limiter       *RequestLimiter
+	auditor       AccessAuditor
+	auditErrors   func(record AccessRecord, err error)
+	exports       *ExportJobs
+	webhooks      *Webhooks
+	bindUserID    bool
+	tenant        string
+	// listen streams committed logs when the backend delivers chaincode events
+	listen listenFunc
+	// draining is done once Drain is called
+	draining context.Context
+	drain    context.CancelFunc
+	// graphQL is the schema of POST /graphql
+	graphQL *gqlSchema
+
+	operations []*operation
+	handlers   map[string]http.HandlerFunc
+}
+
+// CreateResponse is returned by the create endpoints
+type CreateResponse struct {
+	IDs           []string `json:"ids"`
+	TransactionID string   `json:"transactionId,omitempty"`
+	BlockNumber   uint64   `json:"blockNumber,omitempty"`
+}
+
+// ErrorResponse is the body of every failed request
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewServer returns a Server backed by backend. The backend is not closed by the server.
+// NewServer panics if the embedded OpenAPI document declares an operation without a handler.
+func NewServer(backend client.LoggingClient, options Options) *Server {
+	s := &Server{
+		backend:       backend,
+		generateID:    options.IDGenerator,
+		maxBodyBytes:  options.MaxBodyBytes,
+		bulkBatchSize: options.BulkBatchSize,
+		tailBuffer:    options.TailBuffer,
+		authenticator: options.Authenticator,
+		apiKeys:       options.APIKeys,
+		limiter:       options.RateLimiter,
+		auditor:       options.AccessAuditor,
+		auditErrors:   options.AuditErrors,
+		exports:       options.Exports,
+		webhooks:      options.Webhooks,
+		bindUserID:    options.BindUserID,
+		tenant:        options.Tenant,
+	}
+	s.draining, s.drain = context.WithCancel(context.Background())
+	s.graphQL = s.graphQLSchema()
+	if s.apiKeys != nil {
+		s.authenticator = AnyOf(options.Authenticator, s.apiKeys)
+	}
+	if source, ok := backend.(eventSource); ok {
+		s.listen = func(ctx context.Context, handler client.EventHandler, opts ...client.ListenOption) error {
+			return source.NewEventListener(handler, opts...).Run(ctx)
+		}
+	}
+	if s.generateID == nil {
+		s.generateID = client.UUIDv7()
+	}
+	if s.maxBodyBytes == 0 {
+		s.maxBodyBytes = DefaultMaxBodyBytes
+	}
+	if s.bulkBatchSize <= 0 {
+		s.bulkBatchSize = client.DefaultMaxBatchSize
+	}
+	if s.tailBuffer <= 0 {
+		s.tailBuffer = DefaultTailBuffer
+	}
+
+	// Handlers are registered by the operationId the OpenAPI document gives them
+	s.handlers = map[string]http.HandlerFunc{
+		"queryLogs":          s.queryLogs,
+		"createLog":          s.createLog,
+		"createLogsBatch":    s.createLogsBatch,
+		"createLogsBulk":     s.createLogsBulk,
+		"readLog":            s.readLog,
+		"streamLogs":         s.streamLogs,
+		"tailLogs":           s.tailLogs,
+		"getSpec":            s.getSpec,
+		"executeGraphQL":     s.executeGraphQL,
+		"getGraphQLSchema":   s.getGraphQLSchema,
+		"checkHealth":        s.checkHealth,
+		"checkReady":         s.checkReady,
+		"listAPIKeys":        s.listAPIKeys,
+		"issueAPIKey":        s.issueAPIKey,
+		"revokeAPIKey":       s.revokeAPIKey,
+		"rotateAPIKey":       s.rotateAPIKey,
+		"createExport":       s.createExport,
+		"readExport":         s.readExport,
+		"deleteExport":       s.deleteExport,
+		"downloadExport":     s.downloadExport,
+		"ingestWebhook":      s.ingestWebhook,
+		"verifyWebhook":      s.verifyWebhook,
+		"testGrafana":        s.testGrafana,
+		"searchGrafana":      s.searchGrafana,
+		"queryGrafana":       s.queryGrafana,
+		"annotateGrafana":    s.annotateGrafana,
+		"getConfig":          s.getConfig,
+		"setRetentionPolicy": s.setRetentionPolicy,
+	}
+	var err error
+	if s.operations, err = loadOperations(specYAML); err != nil {
+		panic(err)
+	}
+	for _, op := range s.operations {
+		if s.handlers[op.id] == nil {
+			panic(fmt.Sprintf("no handler for operation %s", op.id))
+		}
+	}
+
+	return s
+}
+
+// ServeHTTP validates a request against the OpenAPI document and passes it to the handler of its operation
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	op, params, allowed := match(s.operations, r.Method, r.URL.EscapedPath())
+	if op == nil {
+		if len(allowed) == 0 {
+			writeError(w, http.StatusNotFound, fmt.Errorf("no route for %s", r.URL.Path))
+			return
+		}
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", r.Method))
+		return
+	}
+
+	if s.auditor != nil && op.audited {
+		var recordAccess func(r *http.Request)
+		w, r, recordAccess = s.audit(w, r, op, params)
+		defer func() { recordAccess(r) }()
+	}
+	if s.limiter != nil && !op.public && !s.limiter.allowIP(w, r) {
+		return
+	}
+	if s.authenticator != nil && !op.public {
+		// A TenantRouter has authenticated the caller already
+		principal := PrincipalFrom(r.Context())
+		if principal == nil {
+			if op.queryToken {
+				queryCredentials(r)
+			}
+			var err error
+			if principal, err = s.authenticator.Authenticate(r); err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="fabric-logging", error="invalid_token"`)
+				writeError(w, http.StatusUnauthorized, err)
+				return
+			}
+			// The principal is known from here on, so even refused requests are audited with it
+			r = r.WithContext(context.WithValue(r.Context(), principalKey{}, principal))
+		}
+		if s.limiter != nil && !s.limiter.allowCaller(w, principal) {
+			return
+		}
+		if !principal.HasRole(op.roles...) {
+			writeError(w, http.StatusForbidden, fmt.Errorf("%s requires one of the roles %s", op.id, joinRoles(op.roles)))
+			return
+		}
+	}
+	if s.tenant != "" && !op.public {
+		if principal := PrincipalFrom(r.Context()); principal == nil || principal.Tenant != s.tenant {
+			writeError(w, http.StatusForbidden, fmt.Errorf("the caller does not belong to tenant %s", s.tenant))
+			return
+		}
+	}
+
+	if err := op.validate(r, params, s.maxBodyBytes); err != nil {
+		code := http.StatusBadRequest
+		if errors.Is(err, errBodyTooLarge) {
+			code = http.StatusRequestEntityTooLarge
+		}
+		writeError(w, code, err)
+		return
+	}
+
+	s.handlers[op.id](w, withOperation(r, op, params))
+}
+
+func (s *Server) createLog(w http.ResponseWriter, r *http.Request) {
+	var log client.LogEvent
+	if err := s.decode(w, r, &log); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.bindUser(r.Context(), &log); err != nil {
+		writeError(w, http.StatusForbidden, err)
+		return
+	}
+	if err := s.assignID(&log); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := CreateResponse{IDs: []string{log.ID}}
+	if sub, ok := s.backend.(submitter); ok {
+		result, err := sub.SubmitLog(r.Context(), log)
+		if err != nil && !errors.Is(err, client.ErrSampledOut) {
+			writeClientError(w, err)
+			return
+		}
+		response.setResult(result)
+	} else if err := s.backend.CreateLog(r.Context(), log); err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/logs/"+url.PathEscape(log.ID))
+	writeJSON(w, http.StatusCreated, response)
+}
+
+func (s *Server) createLogsBatch(w http.ResponseWriter, r *http.Request) {
+	var logs []client.LogEvent
+	if err := s.decode(w, r, &logs); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	response := CreateResponse{IDs: make([]string, len(logs))}
+	for i := range logs {
+		if err := s.bindUser(r.Context(), &logs[i]); err != nil {
+			writeError(w, http.StatusForbidden, err)
+			return
+		}
+		if err := s.assignID(&logs[i]); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.IDs[i] = logs[i].ID
+	}
+
+	if sub, ok := s.backend.(submitter); ok {
+		result, err := sub.SubmitLogsBatch(r.Context(), logs)
+		if err != nil && !errors.Is(err, client.ErrSampledOut) {
+			writeClientError(w, err)
+			return
+		}
+		response.setResult(result)
+	} else if err := s.backend.CreateLogsBatch(r.Context(), logs); err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, response)
+}
+
+func (s *Server) readLog(w http.ResponseWriter, r *http.Request) {
+	log, err := s.backend.ReadLog(r.Context(), pathParam(r, "id"))
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+	body, err := json.Marshal(log)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if notModified(w, r, contentETag(body)) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(append(body, '\n'))
+}
+
+func (s *Server) getSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(specYAML)
+}
+
+func (s *Server) queryLogs(w http.ResponseWriter, r *http.Request) {
+	query, err := parseQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	limit := DefaultPageLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > MaxPageLimit {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("limit must be an integer from 1 to %d", MaxPageLimit))
+			return
+		}
+	}
+	at, err := decodeCursor(r.URL.Query().Get("cursor"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if notModified(w, r, s.queryETag(r)) {
+		return
+	}
+
+	logs, next, err := queryPage(r.Context(), s.backend, query, limit, at)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	setLinks(w, r, next)
+	writeJSON(w, http.StatusOK, logs)
+}
+
+// parseQuery builds a LogQuery from query parameters. from and to are RFC 3339 timestamps, and
+// severity_gte a level name such as WARN.
+func parseQuery(values url.Values) (*client.LogQuery, error) {
+	query := client.Query().
+		User(values.Get("user")).
+		Action(values.Get("action")).
+		Resource(values.Get("resource")).
+		Tag(values.Get("tag"))
+
+	for _, bound := range []struct {
+		name  string
+		apply func(time.Time) *client.LogQuery
+	}{
+		{"from", query.Since},
+		{"to", query.Until},
+	} {
+		value := values.Get(bound.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be an RFC 3339 timestamp: %v", bound.name, err)
+		}
+		bound.apply(t)
+	}
+
+	if level := values.Get("severity_gte"); level != "" {
+		query.Severity(">=", level)
+	}
+
+	if _, err := query.Filter(); err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+// decode reads a JSON request body into v, rejecting unknown fields and bodies over the size limit
+func (s *Server) decode(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.maxBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %v", err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("invalid request body: unexpected data after the JSON value")
+	}
+
+	return nil
+}
+
+// bindUser records log under the caller's user ID when the server binds user IDs to callers
+func (s *Server) bindUser(ctx context.Context, log *client.LogEvent) error {
+	principal := PrincipalFrom(ctx)
+	// Principals without a user, such as API keys issued to ingest agents, record any user's logs
+	if !s.bindUserID || principal == nil || principal.UserID == "" {
+		return nil
+	}
+
+	if log.UserID == "" {
+		log.UserID = principal.UserID
+	} else if log.UserID != principal.UserID {
+		return fmt.Errorf("%s may not record logs of user %s", principal.UserID, log.UserID)
+	}
+
+	return nil
+}
+
+func (s *Server) assignID(log *client.LogEvent) error {
+	if log.ID != "" {
+		return nil
+	}
+
+	id, err := s.generateID(*log)
+	if err != nil {
+		return fmt.Errorf("failed to generate log ID: %v", err)
+	}
+	log.ID = id
+
+	return nil
+}
+
+func (r *CreateResponse) setResult(result *client.SubmitResult) {
+	if result != nil {
+		r.TransactionID = result.TransactionID
+		r.BlockNumber = result.BlockNumber
+	}
+}
+
+// statusCode maps client errors to HTTP status codes
+func statusCode(err error) int {
+	var transient *client.TransientError
+	switch {
+	case errors.Is(err, client.ErrInvalidLog):
+		return http.StatusBadRequest
+	case errors.Is(err, client.ErrLogNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, client.ErrAlreadyExists):
+		return http.StatusConflict
+	case errors.Is(err, client.ErrUnauthorized):
+		return http.StatusForbidden
+	case errors.Is(err, client.ErrQuotaExceeded):
+		return http.StatusTooManyRequests
+	case errors.Is(err, client.ErrBackpressure), errors.As(err, &transient):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+func writeClientError(w http.ResponseWriter, err error) {
+	writeError(w, statusCode(err), err)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, ErrorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
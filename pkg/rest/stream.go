@@ -0,0 +1,183 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// streamHeartbeat is how often an idle event stream sends a comment, so proxies keep it open
+const streamHeartbeat = 15 * time.Second
+
+// eventSource is implemented by clients delivering committed logs from chaincode events
+type eventSource interface {
+	NewEventListener(handler client.EventHandler, opts ...client.ListenOption) *client.EventListener
+}
+
+// listenFunc delivers committed logs to handler until ctx is done or handler fails
+type listenFunc func(ctx context.Context, handler client.EventHandler, opts ...client.ListenOption) error
+
+// streamPosition is the ID of a streamed event: the block, transaction and index of the log in the
+// transaction. Browsers send the last one they received in the Last-Event-ID header on
+// reconnecting, and the stream resumes right after it.
+type streamPosition struct {
+	block uint64
+	txID  string
+	index int
+}
+
+func (p streamPosition) String() string {
+	return fmt.Sprintf("%d:%s:%d", p.block, p.txID, p.index)
+}
+
+// streamPositions numbers the logs of each transaction as a listener delivers them, in order
+type streamPositions struct {
+	last streamPosition
+}
+
+// next returns the position of the next delivered log
+func (p *streamPositions) next(event client.ContractEvent) streamPosition {
+	position := streamPosition{block: event.BlockNumber, txID: event.TransactionID}
+	if position.block == p.last.block && position.txID == p.last.txID {
+		position.index = p.last.index + 1
+	}
+	p.last = position
+	return position
+}
+
+func parseStreamPosition(s string) (streamPosition, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return streamPosition{}, fmt.Errorf("invalid Last-Event-ID %q", s)
+	}
+	block, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return streamPosition{}, fmt.Errorf("invalid Last-Event-ID %q", s)
+	}
+	index, err := strconv.Atoi(parts[2])
+	if err != nil || index < 0 {
+		return streamPosition{}, fmt.Errorf("invalid Last-Event-ID %q", s)
+	}
+	return streamPosition{block: block, txID: parts[1], index: index}, nil
+}
+
+// streamLogs sends the logs committed from now on, or after Last-Event-ID, as Server-Sent Events
+// matching the query parameters. Each listener reads the chaincode events of its own stream, so
+// every client resumes from its own position.
+func (s *Server) streamLogs(w http.ResponseWriter, r *http.Request) {
+	if s.listen == nil {
+		writeError(w, http.StatusNotImplemented, fmt.Errorf("the backend cannot stream chaincode events"))
+		return
+	}
+	query, err := parseQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	filter, _ := query.Filter()
+
+	var opts []client.ListenOption
+	var resume *streamPosition
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+		position, err := parseStreamPosition(lastID)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		// The resumed block is replayed from its start and skipped up to the last event sent
+		resume = &position
+		opts = append(opts, client.FromBlock(position.block))
+	}
+
+	// Streams outlive the server's write timeout
+	controller := http.NewResponseController(w)
+	controller.SetWriteDeadline(time.Time{})
+
+	ctx, cancel := s.streamContext(r.Context())
+	defer cancel()
+
+	type streamEvent struct {
+		position streamPosition
+		log      client.LogEvent
+	}
+	events := make(chan streamEvent)
+	failed := make(chan error, 1)
+	go func() {
+		var positions streamPositions
+		failed <- s.listen(ctx, func(event client.ContractEvent) error {
+			position := positions.next(event)
+
+			if resume != nil {
+				if position.block < resume.block {
+					return nil
+				}
+				if position.block == resume.block {
+					if position.txID == resume.txID && position.index == resume.index {
+						resume = nil
+					}
+					return nil
+				}
+				resume = nil
+			}
+			if !matchesFilter(filter, event.Log) || !query.Matches(&event.Log) {
+				return nil
+			}
+
+			select {
+			case events <- streamEvent{position: position, log: event.Log}:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}, opts...)
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	controller.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case event := <-events:
+			data, err := json.Marshal(event.log)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "id: %s\nevent: log\ndata: %s\n\n", event.position, data); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case err := <-failed:
+			if err != nil && !errors.Is(err, context.Canceled) {
+				fmt.Fprintf(w, "event: error\ndata: %s\n\n", strings.ReplaceAll(err.Error(), "\n", " "))
+			}
+			return
+		case <-ctx.Done():
+			return
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// matchesFilter applies the criteria of filter the chaincode would, for logs delivered by events
+func matchesFilter(filter client.LogFilter, log client.LogEvent) bool {
+	return (filter.UserID == "" || log.UserID == filter.UserID) &&
+		(filter.Action == "" || log.Action == filter.Action) &&
+		(filter.Resource == "" || log.Resource == filter.Resource)
+}
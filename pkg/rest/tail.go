@@ -0,0 +1,195 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"golang.org/x/net/websocket"
+)
+
+// DefaultTailBuffer is the number of logs queued for a tail connection when Options leaves it unset
+const DefaultTailBuffer = 256
+
+// tailWriteTimeout closes tail connections whose client stops reading altogether
+const tailWriteTimeout = 10 * time.Second
+
+// Types of TailMessage
+const (
+	TailLog        = "log"
+	TailDropped    = "dropped"
+	TailSubscribed = "subscribed"
+	TailHeartbeat  = "heartbeat"
+	TailError      = "error"
+)
+
+// TailSubscription selects the logs a tail connection receives. Clients send one as a JSON
+// message at any time to replace the filter given by the query parameters.
+type TailSubscription struct {
+	User        string `json:"user,omitempty"`
+	Action      string `json:"action,omitempty"`
+	SeverityGTE string `json:"severityGte,omitempty"`
+}
+
+// TailMessage is a JSON message sent on a tail connection
+type TailMessage struct {
+	Type string `json:"type"`
+	// ID is the ledger position of a log, in the format of the Last-Event-ID of GET /logs/stream
+	ID  string           `json:"id,omitempty"`
+	Log *client.LogEvent `json:"log,omitempty"`
+	// Dropped counts the logs not delivered because the client read too slowly
+	Dropped      int64             `json:"dropped,omitempty"`
+	Subscription *TailSubscription `json:"subscription,omitempty"`
+	Error        string            `json:"error,omitempty"`
+}
+
+// tailFilter is a compiled TailSubscription
+type tailFilter struct {
+	query  *client.LogQuery
+	filter client.LogFilter
+}
+
+func (sub TailSubscription) compile() (*tailFilter, error) {
+	values := url.Values{}
+	for name, value := range map[string]string{"user": sub.User, "action": sub.Action, "severity_gte": sub.SeverityGTE} {
+		if value != "" {
+			values.Set(name, value)
+		}
+	}
+
+	query, err := parseQuery(values)
+	if err != nil {
+		return nil, err
+	}
+	filter, _ := query.Filter()
+	return &tailFilter{query: query, filter: filter}, nil
+}
+
+func (f *tailFilter) matches(log *client.LogEvent) bool {
+	return matchesFilter(f.filter, *log) && f.query.Matches(log)
+}
+
+// tailLogs upgrades the request to a WebSocket pushing the logs committed from now on that match
+// the connection's subscription. Each connection has a bounded queue: when the client reads too
+// slowly, logs are dropped rather than slowing the listener, and the client is told how many.
+func (s *Server) tailLogs(w http.ResponseWriter, r *http.Request) {
+	if s.listen == nil {
+		writeError(w, http.StatusNotImplemented, fmt.Errorf("the backend cannot stream chaincode events"))
+		return
+	}
+	query := r.URL.Query()
+	subscription := TailSubscription{User: query.Get("user"), Action: query.Get("action"), SeverityGTE: query.Get("severity_gte")}
+	filter, err := subscription.compile()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	server := websocket.Server{
+		// Callers are authenticated by token, not cookies, so any origin may connect
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(ws *websocket.Conn) {
+			s.tail(r.Context(), ws, filter)
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+func (s *Server) tail(ctx context.Context, ws *websocket.Conn, filter *tailFilter) {
+	// The hijacked connection may still carry the server's deadlines
+	ws.SetDeadline(time.Time{})
+
+	ctx, cancel := s.streamContext(ctx)
+	defer cancel()
+
+	var current atomic.Pointer[tailFilter]
+	current.Store(filter)
+	queue := make(chan TailMessage, s.tailBuffer)
+	replies := make(chan TailMessage, 1)
+	var dropped atomic.Int64
+
+	// Messages from the client replace the subscription; the connection ends when reading fails
+	go func() {
+		defer cancel()
+		for {
+			var subscription TailSubscription
+			err := websocket.JSON.Receive(ws, &subscription)
+			reply := TailMessage{Type: TailSubscribed, Subscription: &subscription}
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			switch {
+			case errors.As(err, &syntaxErr) || errors.As(err, &typeErr):
+				reply = TailMessage{Type: TailError, Error: fmt.Sprintf("invalid subscription: %v", err)}
+			case err != nil:
+				return
+			default:
+				if filter, err := subscription.compile(); err != nil {
+					reply = TailMessage{Type: TailError, Error: err.Error()}
+				} else {
+					current.Store(filter)
+				}
+			}
+			select {
+			case replies <- reply:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	failed := make(chan error, 1)
+	go func() {
+		var positions streamPositions
+		failed <- s.listen(ctx, func(event client.ContractEvent) error {
+			position := positions.next(event)
+			if !current.Load().matches(&event.Log) {
+				return nil
+			}
+
+			log := event.Log
+			select {
+			case queue <- TailMessage{Type: TailLog, ID: position.String(), Log: &log}:
+			default:
+				dropped.Add(1)
+			}
+			return nil
+		})
+	}()
+
+	send := func(message TailMessage) error {
+		ws.SetWriteDeadline(time.Now().Add(tailWriteTimeout))
+		return websocket.JSON.Send(ws, message)
+	}
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		var message TailMessage
+		select {
+		case message = <-queue:
+		case message = <-replies:
+		case <-heartbeat.C:
+			message = TailMessage{Type: TailHeartbeat}
+		case err := <-failed:
+			if err != nil && !errors.Is(err, context.Canceled) {
+				send(TailMessage{Type: TailError, Error: err.Error()})
+			}
+			return
+		case <-ctx.Done():
+			return
+		}
+		if err := send(message); err != nil {
+			return
+		}
+		if n := dropped.Swap(0); n > 0 {
+			if err := send(TailMessage{Type: TailDropped, Dropped: n}); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// TenantRouter is an http.Handler serving several tenants from one gateway, for hosted
+// deployments. Each tenant has a Server of its own, whose backend connects to the tenant's
+// channel or chaincode with the tenant's Fabric credentials. Requests are routed by the tenant of
+// the authenticated caller, so a caller can only reach the backend of its own tenant, and each
+// Server refuses callers of other tenants as well.
+type TenantRouter struct {
+	authenticator Authenticator
+	servers       map[string]*Server
+	// webhookServers maps each webhook source to the Server of the tenant configuring it, since
+	// deliveries are authenticated by their signature rather than a caller
+	webhookServers map[string]*Server
+	operations     []*operation
+
+	// HealthErrors, when set, is called with each tenant failing a health or readiness check.
+	// The checks are public, so their answers name neither the tenants nor their errors.
+	HealthErrors func(tenant string, err error)
+}
+
+// NewTenantRouter returns a router authenticating callers with authenticator, which must set
+// Principal.Tenant, and serving each tenant through its Server in servers, keyed by tenant. Each
+// Server must be created with Options.Tenant set to its key, and webhook source names must be
+// unique across tenants.
+func NewTenantRouter(authenticator Authenticator, servers map[string]*Server) (*TenantRouter, error) {
+	if authenticator == nil {
+		return nil, fmt.Errorf("a tenant router requires an authenticator")
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no tenants configured")
+	}
+
+	t := &TenantRouter{
+		authenticator:  authenticator,
+		servers:        servers,
+		webhookServers: map[string]*Server{},
+	}
+	for tenant, s := range servers {
+		if tenant == "" {
+			return nil, fmt.Errorf("a tenant needs an ID")
+		}
+		if s.tenant != tenant {
+			return nil, fmt.Errorf("the server of tenant %s serves tenant %q", tenant, s.tenant)
+		}
+		if s.authenticator == nil {
+			return nil, fmt.Errorf("the server of tenant %s does not authenticate callers", tenant)
+		}
+		t.operations = s.operations
+		if s.webhooks == nil {
+			continue
+		}
+		for source := range s.webhooks.sources {
+			if other := t.webhookServers[source]; other != nil {
+				return nil, fmt.Errorf("webhook source %s is configured by tenants %s and %s", source, other.tenant, tenant)
+			}
+			t.webhookServers[source] = s
+		}
+	}
+	return t, nil
+}
+
+// ServeHTTP authenticates the caller and passes the request to the Server of its tenant. The
+// OpenAPI document and health checks are answered for every tenant, and webhook deliveries are
+// passed to the tenant configuring their source.
+func (t *TenantRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	op, params, _ := match(t.operations, r.Method, r.URL.EscapedPath())
+	if op == nil {
+		// Any Server answers 404 and 405 alike
+		t.anyServer().ServeHTTP(w, r)
+		return
+	}
+
+	if op.public {
+		switch op.id {
+		case "getSpec":
+			t.anyServer().getSpec(w, r)
+		case "checkHealth":
+			t.checkHealth(w, r)
+		case "checkReady":
+			t.checkReady(w, r)
+		default:
+			s := t.webhookServers[params["source"]]
+			if s == nil {
+				writeError(w, http.StatusNotFound, fmt.Errorf("no webhook source %s", params["source"]))
+				return
+			}
+			s.ServeHTTP(w, r)
+		}
+		return
+	}
+
+	if op.queryToken {
+		queryCredentials(r)
+	}
+	principal, err := t.authenticator.Authenticate(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="fabric-logging", error="invalid_token"`)
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	s := t.servers[principal.Tenant]
+	if s == nil {
+		writeError(w, http.StatusForbidden, fmt.Errorf("the caller belongs to no tenant of this gateway"))
+		return
+	}
+	s.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+}
+
+// Drain drains the Server of every tenant
+func (t *TenantRouter) Drain() {
+	for _, s := range t.servers {
+		s.Drain()
+	}
+}
+
+// checkHealth fails when the gateway peers of any tenant cannot be reached
+func (t *TenantRouter) checkHealth(w http.ResponseWriter, r *http.Request) {
+	t.check(w, "unhealthy", func(s *Server) error { return s.health() })
+}
+
+// checkReady fails while draining and when the chaincode of any tenant does not answer
+func (t *TenantRouter) checkReady(w http.ResponseWriter, r *http.Request) {
+	t.check(w, "not ready", func(s *Server) error { return s.readiness(r.Context()) })
+}
+
+// check answers whether every tenant passes check, passing the failures to HealthErrors
+func (t *TenantRouter) check(w http.ResponseWriter, state string, check func(s *Server) error) {
+	failed := false
+	for _, tenant := range t.tenants() {
+		if err := check(t.servers[tenant]); err != nil {
+			failed = true
+			if t.HealthErrors != nil {
+				t.HealthErrors(tenant, err)
+			}
+		}
+	}
+	if failed {
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("the gateway is %s", state))
+		return
+	}
+	writeJSON(w, http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// tenants returns the tenants in order
+func (t *TenantRouter) tenants() []string {
+	tenants := make([]string, 0, len(t.servers))
+	for tenant := range t.servers {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	return tenants
+}
+
+// anyServer returns the Server of the first tenant, for answers that do not depend on the tenant
+func (t *TenantRouter) anyServer() *Server {
+	return t.servers[t.tenants()[0]]
+}
@@ -0,0 +1,535 @@
+package rest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultWebhookTolerance is how old a Stripe signature's timestamp may be when a source sets
+// no tolerance
+const DefaultWebhookTolerance = 5 * time.Minute
+
+// Signature schemes of webhook sources
+const (
+	// WebhookGitHub checks the X-Hub-Signature-256 header GitHub sets: sha256= and the hex
+	// HMAC-SHA256 of the body
+	WebhookGitHub = "github"
+	// WebhookStripe checks the Stripe-Signature header: a timestamp and the hex HMAC-SHA256 of
+	// the timestamp, a dot and the body, rejecting timestamps older than the tolerance
+	WebhookStripe = "stripe"
+	// WebhookHMAC checks an HMAC of the body in a header the source names, with its algorithm,
+	// encoding and prefix
+	WebhookHMAC = "hmac"
+	// WebhookToken compares a header with the secret, as Okta event hooks send it in
+	// Authorization
+	WebhookToken = "token"
+)
+
+// eventTimeKey is the metadata field holding the time a source gives an event
+const eventTimeKey = "eventTime"
+
+// errBadSignature is returned for webhook deliveries failing their source's signature check
+var errBadSignature = errors.New("invalid webhook signature")
+
+// WebhookConfig is a webhook sources file, in YAML or JSON, keyed by the {source} of
+// POST /ingest/webhook/{source}. Secrets may be given as ${NAME} to read them from the
+// environment.
+type WebhookConfig struct {
+	Sources map[string]WebhookSource `json:"sources" yaml:"sources"`
+}
+
+// WebhookSource describes how a source signs its deliveries and maps them to logs, with a
+// text/template per field executed on a WebhookEvent. UserID, Action and Resource are required.
+// Without an ID template, a log's ID is derived from the source, the body and the event's index,
+// so a delivery made again is recorded once. The chaincode stamps logs with the time of their
+// transaction, so Timestamp, the time the source gives an event, is recorded in the metadata
+// under eventTime.
+type WebhookSource struct {
+	Signature WebhookSignature `json:"signature" yaml:"signature"`
+	// Events is the dotted path of an array in the body holding several events, such as
+	// data.events for Okta, each recorded as a log; the body is one event when empty
+	Events      string `json:"events,omitempty" yaml:"events,omitempty"`
+	ID          string `json:"id,omitempty" yaml:"id,omitempty"`
+	UserID      string `json:"userId" yaml:"userId"`
+	Action      string `json:"action" yaml:"action"`
+	Resource    string `json:"resource" yaml:"resource"`
+	Timestamp   string `json:"timestamp,omitempty" yaml:"timestamp,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Metadata must execute to a JSON object
+	Metadata string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+}
+
+// WebhookSignature is how a source signs its deliveries
+type WebhookSignature struct {
+	// Scheme is WebhookGitHub, WebhookStripe, WebhookHMAC or WebhookToken
+	Scheme string `json:"scheme" yaml:"scheme"`
+	Secret string `json:"secret" yaml:"secret"`
+	// Header holds the signature of the hmac scheme, or the secret of the token scheme, where
+	// Authorization is used when empty
+	Header string `json:"header,omitempty" yaml:"header,omitempty"`
+	// Algorithm is sha1, sha256 or sha512; sha256 is used when empty
+	Algorithm string `json:"algorithm,omitempty" yaml:"algorithm,omitempty"`
+	// Encoding is hex or base64; hex is used when empty
+	Encoding string `json:"encoding,omitempty" yaml:"encoding,omitempty"`
+	// Prefix precedes the signature in the header, such as sha256=
+	Prefix string `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	// Tolerance is how old a stripe signature may be, such as "5m"; DefaultWebhookTolerance is
+	// used when empty
+	Tolerance string `json:"tolerance,omitempty" yaml:"tolerance,omitempty"`
+}
+
+// LoadWebhookConfig reads the webhook sources file at path
+func LoadWebhookConfig(path string) (*WebhookConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook configuration: %v", err)
+	}
+
+	var cfg WebhookConfig
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse webhook configuration %s: %v", path, err)
+	}
+	return &cfg, nil
+}
+
+// WebhookEvent is the data a WebhookSource's templates execute on
+type WebhookEvent struct {
+	Source string
+	// Headers holds the first value of each request header, by canonical name such as
+	// X-Github-Event
+	Headers map[string]string
+	// Body is the decoded request body
+	Body interface{}
+	// Event is the element of the Events array recorded, or the body when there is no such array
+	Event interface{}
+	// Index is the position of Event in the Events array
+	Index int
+}
+
+// templateFuncs are the functions available to a WebhookSource's templates besides the
+// built-in ones
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	// action turns an event type, such as user.session.start or charge.succeeded, into an action:
+	// upper case, with underscores for the characters an action cannot hold
+	"action": func(eventType string) string {
+		return strings.Map(func(r rune) rune {
+			switch {
+			case r >= 'a' && r <= 'z':
+				return r - 'a' + 'A'
+			case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+				return r
+			}
+			return '_'
+		}, eventType)
+	},
+	// default returns value, or fallback when value is empty
+	"default": func(fallback, value interface{}) interface{} {
+		if value == nil || value == "" {
+			return fallback
+		}
+		return value
+	},
+	// field returns the value at a dotted path of a decoded JSON object, or nil when there is
+	// none; unlike .Event.a.b it does not fail when an event lacks the field
+	"field": fieldAt,
+}
+
+// fieldAt returns the value at a dotted path of a decoded JSON object, or nil when there is none
+func fieldAt(v interface{}, path string) interface{} {
+	for _, key := range strings.Split(path, ".") {
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = object[key]
+	}
+	return v
+}
+
+// Webhooks verifies and maps the deliveries of POST /ingest/webhook/{source}
+type Webhooks struct {
+	sources map[string]*webhookSource
+}
+
+// webhookSource is a parsed WebhookSource
+type webhookSource struct {
+	signature WebhookSignature
+	newHash   func() hash.Hash
+	tolerance time.Duration
+	events    string
+
+	id, userID, action, resource, timestamp, description, metadata *template.Template
+}
+
+// NewWebhooks returns the Webhooks of the sources cfg configures
+func NewWebhooks(cfg *WebhookConfig) (*Webhooks, error) {
+	webhooks := &Webhooks{sources: make(map[string]*webhookSource, len(cfg.Sources))}
+	for name, source := range cfg.Sources {
+		ws, err := newWebhookSource(source)
+		if err != nil {
+			return nil, fmt.Errorf("webhook source %s: %v", name, err)
+		}
+		webhooks.sources[name] = ws
+	}
+	return webhooks, nil
+}
+
+func newWebhookSource(source WebhookSource) (*webhookSource, error) {
+	ws := &webhookSource{signature: source.Signature, events: source.Events, tolerance: DefaultWebhookTolerance}
+	ws.signature.Secret = os.ExpandEnv(ws.signature.Secret)
+	if ws.signature.Secret == "" {
+		return nil, fmt.Errorf("no signature secret")
+	}
+
+	switch ws.signature.Scheme {
+	case WebhookGitHub, WebhookStripe:
+		ws.newHash = sha256.New
+	case WebhookHMAC:
+		if ws.signature.Header == "" {
+			return nil, fmt.Errorf("the hmac scheme needs a header")
+		}
+		switch ws.signature.Algorithm {
+		case "sha1":
+			ws.newHash = sha1.New
+		case "", "sha256":
+			ws.newHash = sha256.New
+		case "sha512":
+			ws.newHash = sha512.New
+		default:
+			return nil, fmt.Errorf("unknown algorithm %q", ws.signature.Algorithm)
+		}
+		switch ws.signature.Encoding {
+		case "", "hex", "base64":
+		default:
+			return nil, fmt.Errorf("unknown encoding %q", ws.signature.Encoding)
+		}
+	case WebhookToken:
+		if ws.signature.Header == "" {
+			ws.signature.Header = "Authorization"
+		}
+	default:
+		return nil, fmt.Errorf("unknown signature scheme %q", ws.signature.Scheme)
+	}
+	if ws.signature.Tolerance != "" {
+		var err error
+		if ws.tolerance, err = time.ParseDuration(ws.signature.Tolerance); err != nil {
+			return nil, fmt.Errorf("invalid tolerance: %v", err)
+		}
+	}
+
+	for _, field := range []struct {
+		name     string
+		text     string
+		required bool
+		t        **template.Template
+	}{
+		{"id", source.ID, false, &ws.id},
+		{"userId", source.UserID, true, &ws.userID},
+		{"action", source.Action, true, &ws.action},
+		{"resource", source.Resource, true, &ws.resource},
+		{"timestamp", source.Timestamp, false, &ws.timestamp},
+		{"description", source.Description, false, &ws.description},
+		{"metadata", source.Metadata, false, &ws.metadata},
+	} {
+		if field.text == "" {
+			if field.required {
+				return nil, fmt.Errorf("no %s template", field.name)
+			}
+			continue
+		}
+		t, err := template.New(field.name).Option("missingkey=error").Funcs(templateFuncs).Parse(field.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s template: %v", field.name, err)
+		}
+		*field.t = t
+	}
+	return ws, nil
+}
+
+// verify checks the signature of a delivery of body
+func (ws *webhookSource) verify(header http.Header, body []byte, now time.Time) error {
+	secret := []byte(ws.signature.Secret)
+	switch ws.signature.Scheme {
+	case WebhookGitHub:
+		value, ok := strings.CutPrefix(header.Get("X-Hub-Signature-256"), "sha256=")
+		if !ok {
+			return fmt.Errorf("%w: no X-Hub-Signature-256 header", errBadSignature)
+		}
+		return ws.checkMAC(value, hex.DecodeString, body)
+
+	case WebhookStripe:
+		var timestamp string
+		var signatures []string
+		for _, item := range strings.Split(header.Get("Stripe-Signature"), ",") {
+			key, value, _ := strings.Cut(strings.TrimSpace(item), "=")
+			switch key {
+			case "t":
+				timestamp = value
+			case "v1":
+				signatures = append(signatures, value)
+			}
+		}
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil || len(signatures) == 0 {
+			return fmt.Errorf("%w: no valid Stripe-Signature header", errBadSignature)
+		}
+		if age := now.Sub(time.Unix(seconds, 0)); age > ws.tolerance || age < -ws.tolerance {
+			return fmt.Errorf("%w: the signature timestamp is outside the tolerance of %s", errBadSignature, ws.tolerance)
+		}
+		signed := append([]byte(timestamp+"."), body...)
+		// Stripe signs with every secret of an endpoint while one is rolled
+		for _, signature := range signatures {
+			if ws.checkMAC(signature, hex.DecodeString, signed) == nil {
+				return nil
+			}
+		}
+		return errBadSignature
+
+	case WebhookHMAC:
+		value, ok := strings.CutPrefix(header.Get(ws.signature.Header), ws.signature.Prefix)
+		if !ok || value == "" {
+			return fmt.Errorf("%w: no %s header", errBadSignature, ws.signature.Header)
+		}
+		decode := hex.DecodeString
+		if ws.signature.Encoding == "base64" {
+			decode = base64.StdEncoding.DecodeString
+		}
+		return ws.checkMAC(value, decode, body)
+
+	default:
+		value := header.Get(ws.signature.Header)
+		if subtle.ConstantTimeCompare([]byte(value), secret) != 1 {
+			return fmt.Errorf("%w: the %s header does not hold the secret", errBadSignature, ws.signature.Header)
+		}
+		return nil
+	}
+}
+
+// checkMAC compares an encoded MAC with the source's MAC of signed
+func (ws *webhookSource) checkMAC(encoded string, decode func(string) ([]byte, error), signed []byte) error {
+	signature, err := decode(encoded)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errBadSignature, err)
+	}
+	mac := hmac.New(ws.newHash, []byte(ws.signature.Secret))
+	mac.Write(signed)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return errBadSignature
+	}
+	return nil
+}
+
+// logs maps a delivery to the logs of its events
+func (ws *webhookSource) logs(source string, header http.Header, body []byte, now time.Time) ([]client.LogEvent, error) {
+	data := WebhookEvent{Source: source, Headers: make(map[string]string, len(header))}
+	for name, values := range header {
+		if len(values) > 0 {
+			data.Headers[name] = values[0]
+		}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data.Body); err != nil {
+		return nil, fmt.Errorf("%w: the body is not JSON: %v", client.ErrInvalidLog, err)
+	}
+
+	events := []interface{}{data.Body}
+	if ws.events != "" {
+		var ok bool
+		if events, ok = fieldAt(data.Body, ws.events).([]interface{}); !ok {
+			return nil, fmt.Errorf("%w: the body has no %s array", client.ErrInvalidLog, ws.events)
+		}
+	}
+
+	// Deliveries made again carry the same body
+	bodyHash := sha256.Sum256(body)
+
+	logs := make([]client.LogEvent, 0, len(events))
+	for i, event := range events {
+		data.Event, data.Index = event, i
+		log := client.LogEvent{
+			ID:        fmt.Sprintf("%s-%x-%d", source, bodyHash[:16], i),
+			Timestamp: now.UTC().Format(time.RFC3339Nano),
+		}
+		var eventTime string
+		for _, field := range []struct {
+			t     *template.Template
+			value *string
+		}{
+			{ws.id, &log.ID},
+			{ws.userID, &log.UserID},
+			{ws.action, &log.Action},
+			{ws.resource, &log.Resource},
+			{ws.timestamp, &eventTime},
+			{ws.description, &log.Description},
+			{ws.metadata, &log.Metadata},
+		} {
+			if field.t == nil {
+				continue
+			}
+			var buf bytes.Buffer
+			if err := field.t.Execute(&buf, data); err != nil {
+				return nil, fmt.Errorf("%w: event %d: %v", client.ErrInvalidLog, i, err)
+			}
+			*field.value = buf.String()
+		}
+		if eventTime != "" {
+			if err := withEventTime(&log, eventTime); err != nil {
+				return nil, fmt.Errorf("%w: event %d: %v", client.ErrInvalidLog, i, err)
+			}
+		}
+		logs = append(logs, log)
+	}
+	return logs, nil
+}
+
+// withEventTime records eventTime in the metadata of log
+func withEventTime(log *client.LogEvent, eventTime string) error {
+	metadata := map[string]interface{}{}
+	if log.Metadata != "" {
+		decoder := json.NewDecoder(strings.NewReader(log.Metadata))
+		decoder.UseNumber()
+		if err := decoder.Decode(&metadata); err != nil {
+			return fmt.Errorf("the metadata is not a JSON object: %v", err)
+		}
+	}
+	metadata[eventTimeKey] = eventTime
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	log.Metadata = string(metadataJSON)
+	return nil
+}
+
+// webhookSource returns the source of a request, answering 404 when webhooks are not enabled
+// or the source is unknown
+func (s *Server) webhookSource(w http.ResponseWriter, r *http.Request) (string, *webhookSource, bool) {
+	name := pathParam(r, "source")
+	if s.webhooks == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("webhooks are not enabled"))
+		return "", nil, false
+	}
+	source := s.webhooks.sources[name]
+	if source == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no webhook source %s", name))
+		return "", nil, false
+	}
+	return name, source, true
+}
+
+// authenticateWebhook checks the signature of a delivery, then the rate limits of the client address
+// and of the source, answering the request and returning false when either fails
+func (s *Server) authenticateWebhook(w http.ResponseWriter, r *http.Request, name string, source *webhookSource, body []byte) bool {
+	if s.limiter != nil && !s.limiter.allowIP(w, r) {
+		return false
+	}
+	if err := source.verify(r.Header, body, time.Now()); err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return false
+	}
+	return s.limiter == nil || s.limiter.allowCaller(w, &Principal{Subject: "webhook:" + name})
+}
+
+func (s *Server) ingestWebhook(w http.ResponseWriter, r *http.Request) {
+	name, source, ok := s.webhookSource(w, r)
+	if !ok {
+		return
+	}
+	// The body was read and size-limited by validation, which left a copy
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("failed to read request body: %v", err))
+		return
+	}
+	if !s.authenticateWebhook(w, r, name, source, body) {
+		return
+	}
+
+	logs, err := source.logs(name, r.Header, body, time.Now())
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+	response := CreateResponse{IDs: make([]string, len(logs))}
+	for i := range logs {
+		if err := s.assignID(&logs[i]); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if err := client.ValidateLog(logs[i]); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("event %d: %v", i, err))
+			return
+		}
+		response.IDs[i] = logs[i].ID
+	}
+	if len(logs) == 0 {
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+
+	err = s.backend.CreateLogsBatch(r.Context(), logs)
+	if errors.Is(err, client.ErrAlreadyExists) {
+		// The source delivers again when it misses an answer, and the logs' IDs are those of the
+		// first delivery; the batch is all or nothing, so its logs are recorded one by one and
+		// those already recorded skipped
+		err = nil
+		for _, log := range logs {
+			if err = s.backend.CreateLog(r.Context(), log); errors.Is(err, client.ErrAlreadyExists) {
+				err = nil
+			}
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, response)
+}
+
+// verifyWebhook answers the one-time verification request of Okta event hooks, which
+// carries a challenge to echo
+func (s *Server) verifyWebhook(w http.ResponseWriter, r *http.Request) {
+	name, source, ok := s.webhookSource(w, r)
+	if !ok {
+		return
+	}
+	if !s.authenticateWebhook(w, r, name, source, nil) {
+		return
+	}
+	challenge := r.Header.Get("X-Okta-Verification-Challenge")
+	if challenge == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("no X-Okta-Verification-Challenge header"))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"verification": challenge})
+}
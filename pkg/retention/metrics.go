@@ -0,0 +1,90 @@
+package retention
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricsNamespace prefixes every metric exported by the service, as it does the client's
+const metricsNamespace = "fabric_logging"
+
+// metrics holds the Prometheus instruments of a Service
+type metrics struct {
+	runs        *prometheus.CounterVec
+	found       prometheus.Gauge
+	archived    prometheus.Counter
+	pruned      prometheus.Counter
+	lastSuccess prometheus.Gauge
+}
+
+func newMetrics(registerer prometheus.Registerer) (*metrics, error) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	m := &metrics{
+		runs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "retention",
+			Name:      "runs_total",
+			Help:      "Applications of the retention policy, by result: pruned, dry_run or failed.",
+		}, []string{"result"}),
+		found: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "retention",
+			Name:      "expired_logs",
+			Help:      "Logs past the retention policy found by the last run, at most one run's bound.",
+		}),
+		archived: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "retention",
+			Name:      "archived_logs_total",
+			Help:      "Logs archived before being pruned.",
+		}),
+		pruned: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "retention",
+			Name:      "pruned_logs_total",
+			Help:      "Logs deleted from the world state.",
+		}),
+		lastSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "retention",
+			Name:      "last_success_timestamp_seconds",
+			Help:      "Unix time of the last run that succeeded, dry runs included.",
+		}),
+	}
+
+	for _, collector := range []prometheus.Collector{m.runs, m.found, m.archived, m.pruned, m.lastSuccess} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, fmt.Errorf("failed to register retention metrics: %v", err)
+		}
+	}
+
+	return m, nil
+}
+
+// observe records a run's result, which may be partial or nil when err is set
+func (m *metrics) observe(result *Result, err error) {
+	if result != nil {
+		m.found.Set(float64(result.Found))
+		if result.Archive != "" {
+			m.archived.Add(float64(result.Found))
+		}
+		m.pruned.Add(float64(result.Pruned))
+	}
+
+	switch {
+	case err != nil:
+		m.runs.WithLabelValues("failed").Inc()
+	case result.DryRun:
+		m.runs.WithLabelValues("dry_run").Inc()
+	default:
+		m.runs.WithLabelValues("pruned").Inc()
+	}
+	if err == nil {
+		m.lastSuccess.Set(float64(time.Now().Unix()))
+	}
+}
@@ -0,0 +1,323 @@
+// Package retention applies the retention policy recorded on the ledger: on a cron schedule it
+// prunes the logs past the policy in bounded batches, archiving them to object storage first
+// when the policy asks for it, so lifecycle management does not depend on anyone remembering
+// to run fablog prune.
+package retention
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/archive"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/export"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/report"
+)
+
+// Service defaults
+const (
+	DefaultBatchSize  = client.DefaultMaxBatchSize
+	DefaultMaxBatches = 10
+	DefaultPageSize   = 100
+)
+
+// keyTimeFormat names archive objects by the time of the run
+const keyTimeFormat = "20060102T150405Z"
+
+// Options configures a Service
+type Options struct {
+	// Schedule is when Run applies the policy, read in Location, or UTC when Location is nil
+	Schedule *report.Schedule
+	Location *time.Location
+	// BatchSize is the number of logs pruned per transaction; DefaultBatchSize is used otherwise
+	BatchSize int
+	// MaxBatches bounds the transactions of one run, so a large backlog is worked off over
+	// several runs; DefaultMaxBatches is used otherwise
+	MaxBatches int
+	// DryRun only finds the logs past the policy, without archiving or pruning them
+	DryRun bool
+	// Store receives the archives of policies asking for them. Runs of such policies fail
+	// without it rather than prune logs that were not archived.
+	Store archive.Store
+	// Format is export.FormatNDJSON, written gzip compressed, or export.FormatParquet. NDJSON
+	// is used when empty.
+	Format export.Format
+	// PageSize is the number of logs read per query page; DefaultPageSize is used otherwise
+	PageSize int32
+	// Registerer receives the service's metrics; prometheus.DefaultRegisterer is used when nil
+	Registerer prometheus.Registerer
+	// OnResult is called by Run with the result of each run, and OnError with its failures
+	OnResult func(*Result)
+	OnError  func(error)
+}
+
+// Result describes one application of the policy
+type Result struct {
+	Policy *client.RetentionPolicy
+	// Cutoff is the time before which logs are past the policy
+	Cutoff time.Time
+	DryRun bool
+	// Found is the number of logs past the policy found by the run, at most one run's bound,
+	// and Private how many of them are private
+	Found   int
+	Private int
+	Oldest  time.Time
+	Newest  time.Time
+	// More reports that further logs are past the policy, left to the next run
+	More bool
+	// Archive is the key of the archive object written, and ArchiveSHA256 its hash
+	Archive       string
+	ArchiveSHA256 string
+	// Pruned is the number of logs deleted, in Batches transactions
+	Pruned  int
+	Batches int
+}
+
+// Summary describes the result in one line
+func (r *Result) Summary() string {
+	if r.Found == 0 {
+		return fmt.Sprintf("no logs recorded before %s", r.Cutoff.Format(time.RFC3339))
+	}
+
+	summary := fmt.Sprintf("%d logs recorded from %s to %s", r.Found, r.Oldest.Format(time.RFC3339), r.Newest.Format(time.RFC3339))
+	if r.Private > 0 {
+		summary += fmt.Sprintf(", %d of them private", r.Private)
+	}
+	if r.DryRun {
+		summary = "would prune " + summary
+	} else {
+		summary = fmt.Sprintf("pruned %d of %s in %d transactions", r.Pruned, summary, r.Batches)
+	}
+	if r.Archive != "" {
+		summary += fmt.Sprintf(", archived to %s", r.Archive)
+	}
+	if r.More {
+		summary += "; more remain for the next run"
+	}
+	return summary
+}
+
+// Service applies the ledger's retention policy
+type Service struct {
+	client  *client.Client
+	options Options
+	metrics *metrics
+	mu      sync.Mutex
+}
+
+// NewService returns a Service reading the policy and pruning logs through c, whose identity
+// must hold the logging.retention attribute, and registers its metrics
+func NewService(c *client.Client, options Options) (*Service, error) {
+	if options.Location == nil {
+		options.Location = time.UTC
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultBatchSize
+	}
+	if options.MaxBatches <= 0 {
+		options.MaxBatches = DefaultMaxBatches
+	}
+	if options.Format == "" {
+		options.Format = export.FormatNDJSON
+	}
+	if options.Format != export.FormatNDJSON && options.Format != export.FormatParquet {
+		return nil, fmt.Errorf("unsupported archive format %q", options.Format)
+	}
+	if options.PageSize <= 0 {
+		options.PageSize = DefaultPageSize
+	}
+
+	m, err := newMetrics(options.Registerer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{client: c, options: options, metrics: m}, nil
+}
+
+// Run applies the policy at every scheduled time until ctx is done, and returns ctx's error.
+// Times that pass while the Service is not running are not caught up.
+func (s *Service) Run(ctx context.Context) error {
+	if s.options.Schedule == nil {
+		return fmt.Errorf("no retention schedule configured")
+	}
+
+	for {
+		next := s.options.Schedule.Next(time.Now().In(s.options.Location))
+		if next.IsZero() {
+			return fmt.Errorf("the retention schedule never fires")
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		result, err := s.Apply(ctx)
+		if err != nil && ctx.Err() == nil && s.options.OnError != nil {
+			s.options.OnError(err)
+		}
+		if result != nil && s.options.OnResult != nil {
+			s.options.OnResult(result)
+		}
+	}
+}
+
+// Apply reads the policy and prunes up to one run's bound of the logs past it, archiving them
+// first when the policy asks for it. The batches pruned before a failure stay pruned and are
+// counted in the result returned with the error; the next run resumes with the rest. Logs
+// archived by a run that then failed to prune them are archived again by the next.
+func (s *Service) Apply(ctx context.Context) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.apply(ctx)
+	s.metrics.observe(result, err)
+	return result, err
+}
+
+func (s *Service) apply(ctx context.Context) (*Result, error) {
+	policy, err := s.client.GetRetentionPolicy(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read retention policy: %w", err)
+	}
+	if policy.MaxAgeDays < 1 {
+		return nil, fmt.Errorf("invalid retention policy: keeps logs for %d days", policy.MaxAgeDays)
+	}
+	if policy.Archive && s.options.Store == nil && !s.options.DryRun {
+		return nil, fmt.Errorf("the retention policy asks for archives, but no archive store is configured")
+	}
+
+	now := time.Now().UTC()
+	result := &Result{Policy: policy, Cutoff: policy.Cutoff(now), DryRun: s.options.DryRun}
+	logs, err := s.find(ctx, result)
+	if err != nil {
+		return nil, err
+	}
+	if s.options.DryRun || len(logs) == 0 {
+		return result, nil
+	}
+
+	if policy.Archive {
+		if err := s.archive(ctx, result, logs, now); err != nil {
+			return result, err
+		}
+	}
+
+	for start := 0; start < len(logs); start += s.options.BatchSize {
+		end := start + s.options.BatchSize
+		if end > len(logs) {
+			end = len(logs)
+		}
+		ids := make([]string, 0, end-start)
+		for _, log := range logs[start:end] {
+			ids = append(ids, log.ID)
+		}
+
+		if _, err := s.client.PruneLogs(ctx, result.Cutoff, ids); err != nil {
+			if errors.Is(err, client.ErrUnauthorized) {
+				return result, fmt.Errorf("pruning requires an identity holding the logging.retention attribute: %w", err)
+			}
+			return result, fmt.Errorf("failed to prune logs: %w", err)
+		}
+		result.Pruned += len(ids)
+		result.Batches++
+	}
+
+	return result, nil
+}
+
+// find reads the logs recorded before the cutoff, up to one run's bound, and records them in
+// result. The chaincode's time range is inclusive and compares text, so timestamps are checked
+// again here.
+func (s *Service) find(ctx context.Context, result *Result) ([]*client.LogEvent, error) {
+	limit := s.options.BatchSize * s.options.MaxBatches
+	filter := client.LogFilter{EndTime: result.Cutoff.Add(-time.Second).Format(time.RFC3339)}
+	it := s.client.IterateLogs(filter, s.options.PageSize)
+
+	var logs []*client.LogEvent
+	for {
+		log, err := it.Next(ctx)
+		if errors.Is(err, client.ErrIteratorDone) {
+			return logs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read logs: %w", err)
+		}
+		timestamp, err := time.Parse(time.RFC3339, log.Timestamp)
+		if err != nil || !timestamp.Before(result.Cutoff) {
+			continue
+		}
+		if len(logs) == limit {
+			result.More = true
+			return logs, nil
+		}
+
+		logs = append(logs, log)
+		result.Found++
+		if log.Collection != "" {
+			result.Private++
+		}
+		if result.Oldest.IsZero() || timestamp.Before(result.Oldest) {
+			result.Oldest = timestamp
+		}
+		if timestamp.After(result.Newest) {
+			result.Newest = timestamp
+		}
+	}
+}
+
+// archive writes logs to the store as one object named after the run
+func (s *Service) archive(ctx context.Context, result *Result, logs []*client.LogEvent, now time.Time) error {
+	var buf bytes.Buffer
+	hasher := sha256.New()
+	var w io.Writer = io.MultiWriter(&buf, hasher)
+
+	var compressed *gzip.Writer
+	if s.options.Format == export.FormatNDJSON {
+		compressed = gzip.NewWriter(w)
+		w = compressed
+	}
+	writer, err := export.NewWriter(s.options.Format, w, export.DefaultColumns)
+	if err != nil {
+		return err
+	}
+	for _, log := range logs {
+		if err := writer.Write(log); err != nil {
+			return fmt.Errorf("failed to write log %s to archive: %v", log.ID, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	if compressed != nil {
+		if err := compressed.Close(); err != nil {
+			return err
+		}
+	}
+
+	key := fmt.Sprintf("retention/%s/%s.%s", now.Format("2006/01/02"), now.Format(keyTimeFormat), s.options.Format)
+	if s.options.Format == export.FormatNDJSON {
+		key += ".gz"
+	}
+	if err := s.options.Store.Put(ctx, key, bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != nil {
+		return fmt.Errorf("failed to store archive %s: %v", key, err)
+	}
+
+	result.Archive = key
+	result.ArchiveSHA256 = hex.EncodeToString(hasher.Sum(nil))
+	return nil
+}
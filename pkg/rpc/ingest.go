@@ -0,0 +1,187 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/rpc/loggingpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ingestPipeline is the number of batches of an Ingest stream waiting for submission before the
+// server stops reading the stream, so that gRPC flow control holds the producer back
+const ingestPipeline = 4
+
+// ingestBatch is a batch of an Ingest stream, acknowledged once submitted
+type ingestBatch struct {
+	first uint64
+	logs  []client.LogEvent
+	// err rejects the batch without submitting it
+	err error
+}
+
+// Ingest records the logs a producer streams. Logs are gathered into batches of up to
+// IngestBatchSize, each submitted as one transaction once full or IngestFlushInterval after its
+// first log, and acknowledged in order once committed. A failed batch is acknowledged with its
+// error and the stream goes on, so the producer decides what to send again. When the producer
+// closes its side, the stream ends once every batch is acknowledged.
+func (s *Server) Ingest(stream loggingpb.LoggingService_IngestServer) error {
+	ctx := stream.Context()
+	principal, err := s.authorize(ctx, "Ingest")
+	if err != nil {
+		return err
+	}
+
+	received := make(chan *loggingpb.IngestRequest)
+	ended := make(chan error, 1)
+	go func() {
+		for {
+			request, err := stream.Recv()
+			if err != nil {
+				ended <- err
+				return
+			}
+			select {
+			case received <- request:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	batches := make(chan ingestBatch, ingestPipeline)
+	acked := make(chan error, 1)
+	go func() {
+		acked <- s.acknowledge(ctx, stream, batches)
+	}()
+
+	// queue hands a batch to the acknowledging goroutine, blocking while the pipeline is full
+	queue := func(batch ingestBatch) error {
+		select {
+		case batches <- batch:
+			return nil
+		case err := <-acked:
+			// Acknowledging only stops early when acks cannot be sent
+			return err
+		}
+	}
+
+	var pending []client.LogEvent
+	var first, sequence uint64
+	flush := time.NewTimer(s.ingestFlush)
+	flush.Stop()
+	queuePending := func() error {
+		flush.Stop()
+		if len(pending) == 0 {
+			return nil
+		}
+		batch := ingestBatch{first: first, logs: pending}
+		pending = nil
+		return queue(batch)
+	}
+
+	// finish acknowledges the batches queued so far and ends the stream with err
+	finish := func(err error) error {
+		if queueErr := queuePending(); queueErr != nil {
+			return queueErr
+		}
+		close(batches)
+		if ackErr := <-acked; ackErr != nil {
+			return ackErr
+		}
+		return err
+	}
+
+	for {
+		select {
+		case request := <-received:
+			for _, event := range request.Logs {
+				log := fromProto(event)
+				if err := s.prepare(principal, &log); err != nil {
+					// Logs are acknowledged in order, so the logs before it go first
+					if err := queuePending(); err != nil {
+						return err
+					}
+					if err := queue(ingestBatch{first: sequence, logs: []client.LogEvent{log}, err: err}); err != nil {
+						return err
+					}
+					sequence++
+					continue
+				}
+
+				if len(pending) == 0 {
+					first = sequence
+					flush.Reset(s.ingestFlush)
+				}
+				pending = append(pending, log)
+				sequence++
+				if len(pending) >= s.ingestBatch {
+					if err := queuePending(); err != nil {
+						return err
+					}
+				}
+			}
+
+		case <-flush.C:
+			if err := queuePending(); err != nil {
+				return err
+			}
+
+		case err := <-ended:
+			if err == io.EOF {
+				return finish(nil)
+			}
+			return err
+
+		case <-s.draining.Done():
+			// Logs the server has not read were never acknowledged, so the producer sends them again
+			return finish(status.Error(codes.Unavailable, "the server is shutting down"))
+
+		case err := <-acked:
+			return err
+		}
+	}
+}
+
+// acknowledge submits the batches of an Ingest stream in order and sends an ack for each
+func (s *Server) acknowledge(ctx context.Context, stream loggingpb.LoggingService_IngestServer, batches <-chan ingestBatch) error {
+	for {
+		var batch ingestBatch
+		select {
+		case next, ok := <-batches:
+			if !ok {
+				return nil
+			}
+			batch = next
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		ack := &loggingpb.IngestAck{FirstSequence: batch.first, Ids: make([]string, len(batch.logs))}
+		for i, log := range batch.logs {
+			ack.Ids[i] = log.ID
+		}
+
+		err := batch.err
+		if err == nil {
+			var result client.SubmitResult
+			result, err = s.submitBatch(ctx, batch.logs)
+			ack.TransactionId, ack.BlockNumber = result.TransactionID, result.BlockNumber
+		}
+		if err != nil {
+			st := status.Convert(statusError(err))
+			ack.Error = st.Message()
+			switch st.Code() {
+			case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded:
+				ack.Retryable = true
+			}
+		}
+
+		if err := stream.Send(ack); err != nil {
+			return err
+		}
+	}
+}
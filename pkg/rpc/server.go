@@ -0,0 +1,424 @@
+// Package rpc serves the logging system over gRPC, for internal services that want typed calls
+// without the HTTP and JSON of the REST API. The service is defined in loggingpb/logging.proto;
+// regenerate its Go code from the repository root with
+//
+//	protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative pkg/rpc/loggingpb/logging.proto
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/rest"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/rpc/loggingpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// methodRoles are the roles that may call each method, as x-roles gives them for the REST
+// operations doing the same
+var methodRoles = map[string][]rest.Role{
+	"CreateLog":       {rest.RoleWriter},
+	"CreateLogsBatch": {rest.RoleWriter},
+	"GetLog":          {rest.RoleReader, rest.RoleAuditor},
+	"QueryLogs":       {rest.RoleReader, rest.RoleAuditor},
+	"WatchLogs":       {rest.RoleReader, rest.RoleAuditor},
+	"Ingest":          {rest.RoleWriter},
+}
+
+// Options configures a Server
+type Options struct {
+	// IDGenerator assigns IDs to logs created without one; client.UUIDv7 is used when nil
+	IDGenerator client.IDGenerator
+	// Authenticator, when set, authenticates every call with the credentials of the REST API,
+	// sent as the authorization or x-api-key metadata, and checks the caller's roles
+	Authenticator rest.Authenticator
+	// BindUserID records logs under the authenticated caller's user ID and rejects logs naming
+	// another user, as rest.Options.BindUserID does
+	BindUserID bool
+	// IngestBatchSize is the most logs of an Ingest stream submitted in one transaction;
+	// client.DefaultMaxBatchSize is used when zero
+	IngestBatchSize int
+	// IngestFlushInterval is the longest a log of an Ingest stream waits for its batch to fill;
+	// client.DefaultFlushInterval is used when zero
+	IngestFlushInterval time.Duration
+}
+
+// submitter is implemented by clients reporting the transaction that recorded a submission
+type submitter interface {
+	SubmitLog(ctx context.Context, log client.LogEvent, opts ...client.SubmitOption) (*client.SubmitResult, error)
+	SubmitLogsBatch(ctx context.Context, logs []client.LogEvent, opts ...client.SubmitOption) (*client.SubmitResult, error)
+}
+
+// eventSource is implemented by clients delivering committed logs from chaincode events
+type eventSource interface {
+	NewEventListener(handler client.EventHandler, opts ...client.ListenOption) *client.EventListener
+}
+
+// Server implements loggingpb.LoggingServiceServer over a LoggingClient
+type Server struct {
+	loggingpb.UnimplementedLoggingServiceServer
+
+	backend       client.LoggingClient
+	generateID    client.IDGenerator
+	authenticator rest.Authenticator
+	bindUserID    bool
+	ingestBatch   int
+	ingestFlush   time.Duration
+	// draining is done once Drain is called
+	draining context.Context
+	drain    context.CancelFunc
+}
+
+// NewServer returns a Server backed by backend. The backend is not closed by the server.
+func NewServer(backend client.LoggingClient, options Options) *Server {
+	s := &Server{
+		backend:       backend,
+		generateID:    options.IDGenerator,
+		authenticator: options.Authenticator,
+		bindUserID:    options.BindUserID,
+		ingestBatch:   options.IngestBatchSize,
+		ingestFlush:   options.IngestFlushInterval,
+	}
+	s.draining, s.drain = context.WithCancel(context.Background())
+	if s.generateID == nil {
+		s.generateID = client.UUIDv7()
+	}
+	if s.ingestBatch <= 0 {
+		s.ingestBatch = client.DefaultMaxBatchSize
+	}
+	if s.ingestFlush <= 0 {
+		s.ingestFlush = client.DefaultFlushInterval
+	}
+	return s
+}
+
+// Register registers the service with a grpc.Server
+func (s *Server) Register(registrar grpc.ServiceRegistrar) {
+	loggingpb.RegisterLoggingServiceServer(registrar, s)
+}
+
+// Drain ends the WatchLogs and Ingest streams, including those opened from then on, so that
+// grpc.Server.GracefulStop only waits for calls that complete
+func (s *Server) Drain() {
+	s.drain()
+}
+
+// CreateLog records a log, assigning its ID when it has none
+func (s *Server) CreateLog(ctx context.Context, request *loggingpb.CreateLogRequest) (*loggingpb.CreateLogResponse, error) {
+	principal, err := s.authorize(ctx, "CreateLog")
+	if err != nil {
+		return nil, err
+	}
+	if request.Log == nil {
+		return nil, status.Error(codes.InvalidArgument, "log is required")
+	}
+	log := fromProto(request.Log)
+	if err := s.prepare(principal, &log); err != nil {
+		return nil, err
+	}
+
+	response := &loggingpb.CreateLogResponse{Id: log.ID}
+	if sub, ok := s.backend.(submitter); ok {
+		result, err := sub.SubmitLog(ctx, log)
+		if err != nil && !errors.Is(err, client.ErrSampledOut) {
+			return nil, statusError(err)
+		}
+		if result != nil {
+			response.TransactionId, response.BlockNumber = result.TransactionID, result.BlockNumber
+		}
+	} else if err := s.backend.CreateLog(ctx, log); err != nil {
+		return nil, statusError(err)
+	}
+	return response, nil
+}
+
+// CreateLogsBatch records logs in one transaction, assigning the IDs of those that have none
+func (s *Server) CreateLogsBatch(ctx context.Context, request *loggingpb.CreateLogsBatchRequest) (*loggingpb.CreateLogsBatchResponse, error) {
+	principal, err := s.authorize(ctx, "CreateLogsBatch")
+	if err != nil {
+		return nil, err
+	}
+	if len(request.Logs) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "logs must not be empty")
+	}
+	logs := make([]client.LogEvent, len(request.Logs))
+	response := &loggingpb.CreateLogsBatchResponse{Ids: make([]string, len(logs))}
+	for i, log := range request.Logs {
+		logs[i] = fromProto(log)
+		if err := s.prepare(principal, &logs[i]); err != nil {
+			return nil, err
+		}
+		response.Ids[i] = logs[i].ID
+	}
+
+	result, err := s.submitBatch(ctx, logs)
+	if err != nil {
+		return nil, err
+	}
+	response.TransactionId, response.BlockNumber = result.TransactionID, result.BlockNumber
+	return response, nil
+}
+
+// submitBatch records logs in one transaction, reporting it when the backend can
+func (s *Server) submitBatch(ctx context.Context, logs []client.LogEvent) (client.SubmitResult, error) {
+	sub, ok := s.backend.(submitter)
+	if !ok {
+		if err := s.backend.CreateLogsBatch(ctx, logs); err != nil {
+			return client.SubmitResult{}, statusError(err)
+		}
+		return client.SubmitResult{}, nil
+	}
+
+	result, err := sub.SubmitLogsBatch(ctx, logs)
+	if err != nil && !errors.Is(err, client.ErrSampledOut) {
+		return client.SubmitResult{}, statusError(err)
+	}
+	if result == nil {
+		return client.SubmitResult{}, nil
+	}
+	return *result, nil
+}
+
+// GetLog reads a log
+func (s *Server) GetLog(ctx context.Context, request *loggingpb.GetLogRequest) (*loggingpb.LogEvent, error) {
+	if _, err := s.authorize(ctx, "GetLog"); err != nil {
+		return nil, err
+	}
+	if request.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	log, err := s.backend.ReadLog(ctx, request.Id)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toProto(log), nil
+}
+
+// QueryLogs returns a page of the logs matching the request's filter, with the cursors of GET /logs
+func (s *Server) QueryLogs(ctx context.Context, request *loggingpb.QueryLogsRequest) (*loggingpb.QueryLogsResponse, error) {
+	if _, err := s.authorize(ctx, "QueryLogs"); err != nil {
+		return nil, err
+	}
+	query, err := buildQuery(request.Filter)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	limit := int(request.PageSize)
+	if limit == 0 {
+		limit = rest.DefaultPageLimit
+	}
+	if limit < 0 || limit > rest.MaxPageLimit {
+		return nil, status.Errorf(codes.InvalidArgument, "page_size must be from 0 to %d", rest.MaxPageLimit)
+	}
+
+	logs, next, err := rest.QueryPage(ctx, s.backend, query, limit, request.PageToken)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	response := &loggingpb.QueryLogsResponse{Logs: make([]*loggingpb.LogEvent, len(logs)), NextPageToken: next}
+	for i, log := range logs {
+		response.Logs[i] = toProto(log)
+	}
+	return response, nil
+}
+
+// WatchLogs sends the logs matching the request's filter as they are committed, until the client
+// cancels or the server drains
+func (s *Server) WatchLogs(request *loggingpb.WatchLogsRequest, stream loggingpb.LoggingService_WatchLogsServer) error {
+	if _, err := s.authorize(stream.Context(), "WatchLogs"); err != nil {
+		return err
+	}
+	source, ok := s.backend.(eventSource)
+	if !ok {
+		return status.Error(codes.Unimplemented, "the backend cannot stream chaincode events")
+	}
+	query, err := buildQuery(request.Filter)
+	if err != nil {
+		return statusError(err)
+	}
+	filter, _ := query.Filter()
+
+	var opts []client.ListenOption
+	if request.FromBlock > 0 {
+		opts = append(opts, client.FromBlock(request.FromBlock))
+	}
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	stop := context.AfterFunc(s.draining, cancel)
+	defer stop()
+
+	err = source.NewEventListener(func(event client.ContractEvent) error {
+		if !matchesFilter(filter, event.Log) || !query.Matches(&event.Log) {
+			return nil
+		}
+		return stream.Send(&loggingpb.WatchLogsResponse{
+			Log:           toProto(&event.Log),
+			BlockNumber:   event.BlockNumber,
+			TransactionId: event.TransactionID,
+		})
+	}, opts...).Run(ctx)
+	if err != nil && ctx.Err() == nil {
+		return statusError(err)
+	}
+	return nil
+}
+
+// authorize authenticates the caller of method and checks its roles. It returns a nil principal
+// when the server has no Authenticator.
+func (s *Server) authorize(ctx context.Context, method string) (*rest.Principal, error) {
+	if s.authenticator == nil {
+		return nil, nil
+	}
+
+	// Authenticators read HTTP headers, which gRPC sends as metadata
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for name, values := range md {
+		if strings.HasPrefix(name, ":") || strings.HasSuffix(name, "-bin") {
+			continue
+		}
+		for _, value := range values {
+			r.Header.Add(name, value)
+		}
+	}
+
+	principal, err := s.authenticator.Authenticate(r)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if roles := methodRoles[method]; !principal.HasRole(roles...) {
+		names := make([]string, len(roles))
+		for i, role := range roles {
+			names[i] = string(role)
+		}
+		return nil, status.Errorf(codes.PermissionDenied, "%s requires one of the roles %s", method, strings.Join(names, ", "))
+	}
+	return principal, nil
+}
+
+// prepare binds log to the caller's user ID when the server binds user IDs and assigns its ID
+func (s *Server) prepare(principal *rest.Principal, log *client.LogEvent) error {
+	// Principals without a user, such as API keys issued to ingest agents, record any user's logs
+	if s.bindUserID && principal != nil && principal.UserID != "" {
+		if log.UserID == "" {
+			log.UserID = principal.UserID
+		} else if log.UserID != principal.UserID {
+			return status.Errorf(codes.PermissionDenied, "%s may not record logs of user %s", principal.UserID, log.UserID)
+		}
+	}
+
+	if log.ID != "" {
+		return nil
+	}
+	id, err := s.generateID(*log)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to generate log ID: %v", err)
+	}
+	log.ID = id
+	return nil
+}
+
+// buildQuery converts the filter of a request to a LogQuery
+func buildQuery(filter *loggingpb.LogFilter) (*client.LogQuery, error) {
+	query := client.Query()
+	if filter == nil {
+		return query, nil
+	}
+	query.User(filter.UserId).Action(filter.Action).Resource(filter.Resource).Tag(filter.Tag)
+
+	for _, bound := range []struct {
+		name  string
+		value string
+		apply func(time.Time) *client.LogQuery
+	}{
+		{"from", filter.From, query.Since},
+		{"to", filter.To, query.Until},
+	} {
+		if bound.value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, bound.value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s must be an RFC 3339 timestamp: %v", client.ErrInvalidQuery, bound.name, err)
+		}
+		bound.apply(t)
+	}
+	if filter.SeverityGte != "" {
+		query.Severity(">=", filter.SeverityGte)
+	}
+
+	if _, err := query.Filter(); err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+// matchesFilter applies the criteria the chaincode applies to queries to a delivered log
+func matchesFilter(filter client.LogFilter, log client.LogEvent) bool {
+	return (filter.UserID == "" || log.UserID == filter.UserID) &&
+		(filter.Action == "" || log.Action == filter.Action) &&
+		(filter.Resource == "" || log.Resource == filter.Resource)
+}
+
+// statusError converts an error of the client to a gRPC status
+func statusError(err error) error {
+	var transient *client.TransientError
+	code := codes.Internal
+	switch {
+	case errors.Is(err, client.ErrInvalidLog), errors.Is(err, client.ErrInvalidQuery), errors.Is(err, rest.ErrInvalidCursor):
+		code = codes.InvalidArgument
+	case errors.Is(err, client.ErrLogNotFound):
+		code = codes.NotFound
+	case errors.Is(err, client.ErrAlreadyExists):
+		code = codes.AlreadyExists
+	case errors.Is(err, client.ErrUnauthorized):
+		code = codes.PermissionDenied
+	case errors.Is(err, client.ErrQuotaExceeded):
+		code = codes.ResourceExhausted
+	case errors.Is(err, client.ErrBackpressure), errors.As(err, &transient):
+		code = codes.Unavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	}
+	return status.Error(code, err.Error())
+}
+
+func fromProto(log *loggingpb.LogEvent) client.LogEvent {
+	return client.LogEvent{
+		ID:          log.Id,
+		UserID:      log.UserId,
+		Action:      log.Action,
+		Resource:    log.Resource,
+		Timestamp:   log.Timestamp,
+		Description: log.Description,
+		Metadata:    log.Metadata,
+		Collection:  log.Collection,
+	}
+}
+
+func toProto(log *client.LogEvent) *loggingpb.LogEvent {
+	return &loggingpb.LogEvent{
+		Id:          log.ID,
+		UserId:      log.UserID,
+		Action:      log.Action,
+		Resource:    log.Resource,
+		Timestamp:   log.Timestamp,
+		Description: log.Description,
+		Metadata:    log.Metadata,
+		Collection:  log.Collection,
+	}
+}
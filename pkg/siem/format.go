@@ -0,0 +1,390 @@
+// Package siem exports the logs committed on the ledger to security information and event
+// management systems, so the ledger feeds existing SOC workflows. A Sink formats each log as an
+// ArcSight Common Event Format (CEF) or IBM QRadar Log Event Extended Format (LEEF) event, with
+// fields chosen by a Mapping, and sends it over syslog on TCP, TLS or UDP.
+package siem
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"gopkg.in/yaml.v2"
+)
+
+// Event formats
+const (
+	FormatCEF  = "cef"
+	FormatLEEF = "leef"
+)
+
+// Mapping defaults
+const (
+	DefaultVendor  = "Fabric"
+	DefaultProduct = "Fabric Logging"
+	DefaultVersion = "1.0"
+)
+
+// Mapping chooses the header and extension fields of the events of logs. EventClassID, Name,
+// Severity and Extensions are text/templates executed on an Event. Without a template, the
+// event class ID is the log's action and the name its description, or its action when it has
+// none; the severity follows the log's level, from 1 for DEBUG to 10 for FATAL, and is 3 for logs
+// without one.
+type Mapping struct {
+	Vendor  string `json:"vendor,omitempty" yaml:"vendor,omitempty"`
+	Product string `json:"product,omitempty" yaml:"product,omitempty"`
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+	// EventClassID is the CEF Signature ID or the LEEF EventID
+	EventClassID string `json:"eventClassId,omitempty" yaml:"eventClassId,omitempty"`
+	// Name is the CEF Name; LEEF has none
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Severity must execute to a number from 0 to 10; it is the CEF Severity or the LEEF sev
+	Severity string `json:"severity,omitempty" yaml:"severity,omitempty"`
+	// Extensions maps extension keys to templates, adding to the format's default extensions or
+	// replacing them; an extension whose template executes to nothing is left out, so mapping a
+	// key to "" removes a default
+	Extensions map[string]string `json:"extensions,omitempty" yaml:"extensions,omitempty"`
+}
+
+// Event is the data the templates of a Mapping are executed on
+type Event struct {
+	Log           client.LogEvent
+	BlockNumber   uint64
+	TransactionID string
+	// Metadata is the log's decoded metadata, or nil when it is not a JSON object
+	Metadata map[string]interface{}
+	// Level is the log's severity, such as WARN, or empty when it has none
+	Level string
+	// Time is the log's timestamp, or the zero time when it cannot be parsed
+	Time time.Time
+}
+
+// defaultExtensions are the extensions of each format when a Mapping sets none. The CEF keys are
+// from the ArcSight dictionary, with the custom string and number fields labelled; the LEEF keys
+// are the predefined attributes and custom keys QRadar can map to properties.
+var defaultExtensions = map[string]map[string]string{
+	FormatCEF: {
+		"rt":         "{{if not .Time.IsZero}}{{.Time.UnixMilli}}{{end}}",
+		"externalId": "{{.Log.ID}}",
+		"suser":      "{{.Log.UserID}}",
+		"act":        "{{.Log.Action}}",
+		"msg":        "{{.Log.Description}}",
+		"cs1":        "{{.Log.Resource}}",
+		"cs1Label":   "resource",
+		"cs2":        "{{.TransactionID}}",
+		"cs2Label":   "transactionId",
+		"cn1":        "{{.BlockNumber}}",
+		"cn1Label":   "blockNumber",
+	},
+	FormatLEEF: {
+		"devTime":       "{{if not .Time.IsZero}}{{.Time.UTC.Format \"Jan 02 2006 15:04:05.000 MST\"}}{{end}}",
+		"devTimeFormat": "{{if not .Time.IsZero}}MMM dd yyyy HH:mm:ss.SSS z{{end}}",
+		"cat":           "{{.Log.Action}}",
+		"usrName":       "{{.Log.UserID}}",
+		"logId":         "{{.Log.ID}}",
+		"resource":      "{{.Log.Resource}}",
+		"msg":           "{{.Log.Description}}",
+		"transactionId": "{{.TransactionID}}",
+		"blockNumber":   "{{.BlockNumber}}",
+	},
+}
+
+// levelSeverities places the log levels on the 0 to 10 scale of CEF and LEEF
+var levelSeverities = []struct {
+	level    slog.Level
+	severity int
+}{
+	{slog.LevelError + 4, 10},
+	{slog.LevelError, 8},
+	{slog.LevelWarn, 6},
+	{slog.LevelInfo, 3},
+	{slog.LevelDebug, 1},
+}
+
+// extensionKey matches the extension keys both formats accept
+var extensionKey = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// templateFuncs are the functions available to a Mapping's templates besides the built-in ones
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	// default returns value, or fallback when value is empty
+	"default": func(fallback, value interface{}) interface{} {
+		if value == nil || value == "" {
+			return fallback
+		}
+		return value
+	},
+	// field returns the value at a dotted path of a decoded JSON object, or nil when there is
+	// none; unlike .Metadata.a.b it does not fail when a log lacks the field
+	"field": func(v interface{}, path string) interface{} {
+		for _, key := range strings.Split(path, ".") {
+			object, ok := v.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			v = object[key]
+		}
+		return v
+	},
+}
+
+// mappingConfig is a mapping file
+type mappingConfig struct {
+	Mapping Mapping `json:"mapping" yaml:"mapping"`
+}
+
+// LoadMapping reads the mapping of a mapping file, in YAML or JSON
+func LoadMapping(path string) (Mapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Mapping{}, fmt.Errorf("failed to read mapping: %v", err)
+	}
+
+	var cfg mappingConfig
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return Mapping{}, fmt.Errorf("failed to parse mapping %s: %v", path, err)
+	}
+	return cfg.Mapping, nil
+}
+
+// Formatter formats logs as the events of a format
+type Formatter struct {
+	format               string
+	vendor, product, ver string
+	eventClassID, name   *template.Template
+	severity             *template.Template
+	extensionKeys        []string
+	extensions           map[string]*template.Template
+}
+
+// NewFormatter returns a Formatter of format, FormatCEF or FormatLEEF, with the fields of mapping
+func NewFormatter(format string, mapping Mapping) (*Formatter, error) {
+	format = strings.ToLower(format)
+	defaults, ok := defaultExtensions[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown event format %q: want %s or %s", format, FormatCEF, FormatLEEF)
+	}
+	f := &Formatter{
+		format:     format,
+		vendor:     mapping.Vendor,
+		product:    mapping.Product,
+		ver:        mapping.Version,
+		extensions: map[string]*template.Template{},
+	}
+	if f.vendor == "" {
+		f.vendor = DefaultVendor
+	}
+	if f.product == "" {
+		f.product = DefaultProduct
+	}
+	if f.ver == "" {
+		f.ver = DefaultVersion
+	}
+
+	for _, field := range []struct {
+		name, text string
+		t          **template.Template
+	}{
+		{"eventClassId", mapping.EventClassID, &f.eventClassID},
+		{"name", mapping.Name, &f.name},
+		{"severity", mapping.Severity, &f.severity},
+	} {
+		if field.text == "" {
+			continue
+		}
+		t, err := template.New(field.name).Option("missingkey=error").Funcs(templateFuncs).Parse(field.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s template: %v", field.name, err)
+		}
+		*field.t = t
+	}
+
+	texts := map[string]string{}
+	for key, text := range defaults {
+		texts[key] = text
+	}
+	for key, text := range mapping.Extensions {
+		if !extensionKey.MatchString(key) {
+			return nil, fmt.Errorf("invalid extension key %q: want letters, digits and underscores", key)
+		}
+		texts[key] = text
+	}
+	for key, text := range texts {
+		if text == "" {
+			continue
+		}
+		t, err := template.New(key).Option("missingkey=error").Funcs(templateFuncs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template of extension %s: %v", key, err)
+		}
+		f.extensions[key] = t
+		f.extensionKeys = append(f.extensionKeys, key)
+	}
+	sort.Strings(f.extensionKeys)
+	return f, nil
+}
+
+// Format returns the event of the log carried by event
+func (f *Formatter) Format(event client.ContractEvent) ([]byte, error) {
+	data := newEvent(event)
+
+	eventClassID := data.Log.Action
+	if f.eventClassID != nil {
+		var err error
+		if eventClassID, err = execute(f.eventClassID, data); err != nil {
+			return nil, err
+		}
+	}
+	name := data.Log.Description
+	if name == "" {
+		name = data.Log.Action
+	}
+	if f.name != nil {
+		var err error
+		if name, err = execute(f.name, data); err != nil {
+			return nil, err
+		}
+	}
+	severity, err := f.severityOf(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	if f.format == FormatCEF {
+		fmt.Fprintf(&b, "CEF:0|%s|%s|%s|%s|%s|%d|", cefHeader(f.vendor), cefHeader(f.product), cefHeader(f.ver),
+			cefHeader(eventClassID), cefHeader(truncate(name, 512)), severity)
+	} else {
+		// LEEF 2.0 declares the delimiter of the attributes, a tab as in LEEF 1.0
+		fmt.Fprintf(&b, "LEEF:2.0|%s|%s|%s|%s|x09|sev=%d", leefHeader(f.vendor), leefHeader(f.product), leefHeader(f.ver),
+			leefHeader(eventClassID), severity)
+	}
+
+	first := f.format == FormatCEF
+	for _, key := range f.extensionKeys {
+		value, err := execute(f.extensions[key], data)
+		if err != nil {
+			return nil, err
+		}
+		if value == "" {
+			continue
+		}
+		if f.format == FormatCEF {
+			if !first {
+				b.WriteByte(' ')
+			}
+			fmt.Fprintf(&b, "%s=%s", key, cefValue(value))
+		} else {
+			fmt.Fprintf(&b, "\t%s=%s", key, leefValue(value))
+		}
+		first = false
+	}
+	return b.Bytes(), nil
+}
+
+// severityOf returns the 0 to 10 severity of an event
+func (f *Formatter) severityOf(data Event) (int, error) {
+	if f.severity == nil {
+		return defaultSeverity(data.Log), nil
+	}
+	text, err := execute(f.severity, data)
+	if err != nil {
+		return 0, err
+	}
+	severity, err := strconv.Atoi(strings.TrimSpace(text))
+	if err != nil || severity < 0 || severity > 10 {
+		return 0, fmt.Errorf("severity %q of log %s is not a number from 0 to 10", text, data.Log.ID)
+	}
+	return severity, nil
+}
+
+// defaultSeverity places the level of log on the 0 to 10 scale
+func defaultSeverity(log client.LogEvent) int {
+	level, ok := client.LogSeverity(log)
+	if !ok {
+		return 3
+	}
+	for _, s := range levelSeverities {
+		if level >= s.level {
+			return s.severity
+		}
+	}
+	return 0
+}
+
+func newEvent(event client.ContractEvent) Event {
+	data := Event{Log: event.Log, BlockNumber: event.BlockNumber, TransactionID: event.TransactionID}
+	var metadata map[string]interface{}
+	if json.Unmarshal([]byte(event.Log.Metadata), &metadata) == nil {
+		data.Metadata = metadata
+	}
+	if level, ok := client.LogSeverity(event.Log); ok {
+		data.Level = level.String()
+	}
+	if t, err := time.Parse(time.RFC3339Nano, event.Log.Timestamp); err == nil {
+		data.Time = t
+	}
+	return data
+}
+
+func execute(t *template.Template, data Event) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to map log %s: %v", data.Log.ID, err)
+	}
+	return b.String(), nil
+}
+
+// cefHeaderReplacer escapes a CEF header field, where pipes and backslashes are escaped and line
+// breaks are not allowed
+var cefHeaderReplacer = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+
+func cefHeader(s string) string {
+	return cefHeaderReplacer.Replace(s)
+}
+
+// cefValueReplacer escapes a CEF extension value, where equal signs and backslashes are escaped
+// and line breaks are written as \n and \r
+var cefValueReplacer = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+
+func cefValue(s string) string {
+	return cefValueReplacer.Replace(s)
+}
+
+// LEEF defines no escapes, so the characters that would end a header field, an attribute or the
+// event are replaced with spaces
+var (
+	leefHeaderReplacer = strings.NewReplacer("|", " ", "\r", " ", "\n", " ", "\t", " ")
+	leefValueReplacer  = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+)
+
+func leefHeader(s string) string {
+	return leefHeaderReplacer.Replace(s)
+}
+
+func leefValue(s string) string {
+	return leefValueReplacer.Replace(s)
+}
+
+// truncate shortens s to at most n characters
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
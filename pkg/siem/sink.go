@@ -0,0 +1,271 @@
+package siem
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/syslog"
+)
+
+// Sink defaults
+const (
+	DefaultNetwork  = "tcp"
+	DefaultFacility = "security"
+	DefaultAppName  = "fabric-logging"
+	// DefaultTimeout bounds connecting to the SIEM and each write
+	DefaultTimeout = 10 * time.Second
+)
+
+// Framings of messages sent over TCP and TLS (RFC 6587)
+const (
+	// FramingOctetCounting prefixes each message with its length, as RFC 5425 requires over TLS
+	FramingOctetCounting = "octet-counting"
+	// FramingNewline ends each message with a line break, as most SIEM syslog listeners expect
+	FramingNewline = "newline"
+)
+
+// syslogSeverities places the log levels on the syslog severities, from crit for FATAL to
+// debug for DEBUG
+var syslogSeverities = []struct {
+	level    slog.Level
+	severity int
+}{
+	{slog.LevelError + 4, 2},
+	{slog.LevelError, 3},
+	{slog.LevelWarn, 4},
+	{slog.LevelInfo, 6},
+}
+
+// Config describes the SIEM's syslog listener and the messages sent to it
+type Config struct {
+	// Address is the listener's host and port, e.g. arcsight.example.com:6514
+	Address string
+	// Network is "tcp" or "udp"; DefaultNetwork is used when empty. Over UDP each event is sent
+	// in a datagram of its own, and events the network drops are lost.
+	Network string
+	// TLS configures TLS over TCP, which is used whenever set
+	TLS *tls.Config
+	// Framing is FramingOctetCounting or FramingNewline; octet counting is used with TLS and
+	// newlines otherwise when empty
+	Framing string
+	// Facility is the syslog facility keyword, such as "local4"; DefaultFacility is used when empty
+	Facility string
+	// Hostname and AppName fill the syslog header; the host name and DefaultAppName are used
+	// when empty
+	Hostname string
+	AppName  string
+	// Timeout bounds connecting and each write; DefaultTimeout is used when zero
+	Timeout time.Duration
+}
+
+// Sink is a projection.Sink sending each log as a CEF or LEEF event in an RFC 5424 syslog message.
+// Syslog carries no acknowledgements, so a write succeeds once the messages are handed to the
+// connection, and the events since the last checkpoint are sent again after a failure or restart;
+// correlate on the log ID (externalId in CEF, logId in LEEF) when it matters. Run it with a
+// projection.Projector:
+//
+//	sink, err := siem.NewSink(siem.Config{Address: "qradar.example.com:514"}, formatter)
+//	projector := projection.New(projection.NewEventSource(c, nil), sink, projection.Options{Checkpointer: checkpointer})
+//	err = projector.Run(ctx)
+type Sink struct {
+	config    Config
+	formatter *Formatter
+	facility  int
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSink returns a Sink sending the events formatter formats to the configured listener. It
+// connects on the first write.
+func NewSink(config Config, formatter *Formatter) (*Sink, error) {
+	if config.Address == "" {
+		return nil, fmt.Errorf("no SIEM address configured")
+	}
+	if formatter == nil {
+		return nil, fmt.Errorf("no event formatter configured")
+	}
+	if config.Network == "" {
+		config.Network = DefaultNetwork
+	}
+	if config.Network != "tcp" && config.Network != "udp" {
+		return nil, fmt.Errorf("unknown network %q: want tcp or udp", config.Network)
+	}
+	if config.Network == "udp" && config.TLS != nil {
+		return nil, fmt.Errorf("TLS needs the tcp network")
+	}
+	switch config.Framing {
+	case "":
+		config.Framing = FramingNewline
+		if config.TLS != nil {
+			config.Framing = FramingOctetCounting
+		}
+	case FramingOctetCounting, FramingNewline:
+	default:
+		return nil, fmt.Errorf("unknown framing %q: want %s or %s", config.Framing, FramingOctetCounting, FramingNewline)
+	}
+	if config.Facility == "" {
+		config.Facility = DefaultFacility
+	}
+	facility := -1
+	for code := 0; code < 24; code++ {
+		if syslog.FacilityName(code) == config.Facility {
+			facility = code
+		}
+	}
+	if facility < 0 {
+		return nil, fmt.Errorf("unknown syslog facility %q", config.Facility)
+	}
+	if config.Hostname == "" {
+		config.Hostname, _ = os.Hostname()
+	}
+	if config.AppName == "" {
+		config.AppName = DefaultAppName
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+
+	return &Sink{config: config, formatter: formatter, facility: facility}, nil
+}
+
+// Write sends the logs carried by events, connecting first when the sink is not connected. A
+// failed connection is closed, so the Projector's retry connects again. Pruned logs are not
+// sent: the SIEM applies its own retention to what it received.
+func (s *Sink) Write(ctx context.Context, events []client.ContractEvent) error {
+	messages := make([][]byte, 0, len(events))
+	for _, event := range events {
+		if event.Pruned() {
+			continue
+		}
+		msg, err := s.formatter.Format(event)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, s.message(event.Log, msg))
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		conn, err := s.dial(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to connect to SIEM at %s: %v", s.config.Address, err)
+		}
+		s.conn = conn
+	}
+	if err := s.send(ctx, messages); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return fmt.Errorf("failed to send logs to SIEM at %s: %v", s.config.Address, err)
+	}
+	return nil
+}
+
+// Close closes the connection to the SIEM
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+func (s *Sink) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: s.config.Timeout, KeepAlive: 30 * time.Second}
+	if s.config.TLS != nil {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: s.config.TLS}
+		return tlsDialer.DialContext(ctx, "tcp", s.config.Address)
+	}
+	return dialer.DialContext(ctx, s.config.Network, s.config.Address)
+}
+
+// send writes messages, each in a datagram over UDP and framed over TCP
+func (s *Sink) send(ctx context.Context, messages [][]byte) error {
+	deadline := time.Now().Add(s.config.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := s.conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+
+	if s.config.Network == "udp" {
+		for _, msg := range messages {
+			if _, err := s.conn.Write(msg); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	w := bufio.NewWriter(s.conn)
+	for _, msg := range messages {
+		if s.config.Framing == FramingOctetCounting {
+			w.WriteString(strconv.Itoa(len(msg)))
+			w.WriteByte(' ')
+			w.Write(msg)
+		} else {
+			w.Write(msg)
+			w.WriteByte('\n')
+		}
+	}
+	return w.Flush()
+}
+
+// message wraps an event in an RFC 5424 message, with the timestamp and severity of its log
+func (s *Sink) message(log client.LogEvent, event []byte) []byte {
+	timestamp := "-"
+	if t, err := time.Parse(time.RFC3339Nano, log.Timestamp); err == nil {
+		timestamp = t.UTC().Format("2006-01-02T15:04:05.000000Z07:00")
+	}
+	header := fmt.Sprintf("<%d>1 %s %s %s - - - ", s.facility*8+syslogSeverity(log), timestamp,
+		headerField(s.config.Hostname, 255), headerField(s.config.AppName, 48))
+	return append([]byte(header), event...)
+}
+
+// syslogSeverity returns the syslog severity of the level of log, notice for logs without one
+func syslogSeverity(log client.LogEvent) int {
+	level, ok := client.LogSeverity(log)
+	if !ok {
+		return 5
+	}
+	for _, s := range syslogSeverities {
+		if level >= s.level {
+			return s.severity
+		}
+	}
+	return 7
+}
+
+// headerField returns s as an RFC 5424 header field: printable ASCII of at most n characters,
+// or "-" when empty
+func headerField(s string, n int) string {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s) && len(b) < n; i++ {
+		if s[i] > 32 && s[i] < 127 {
+			b = append(b, s[i])
+		}
+	}
+	if len(b) == 0 {
+		return "-"
+	}
+	return string(b)
+}
@@ -0,0 +1,382 @@
+package simulator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Query is the subset of a CouchDB Mango query the simulator evaluates. Selectors support
+// implicit equality, nested objects and dotted field paths, the combination operators $and,
+// $or, $nor and $not, and the condition operators $eq, $ne, $gt, $gte, $lt, $lte, $in, $nin,
+// $exists, $regex and $all. Values are compared with CouchDB's collation across types, except
+// that strings compare by code point rather than by ICU collation.
+type Query struct {
+	Selector map[string]interface{}
+	Sort     []SortField
+	Limit    int
+	Skip     int
+}
+
+// SortField orders query results by one field
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+func parseQuery(queryString string) (*Query, error) {
+	var raw struct {
+		Selector map[string]interface{} `json:"selector"`
+		Sort     []interface{}          `json:"sort"`
+		Limit    int                    `json:"limit"`
+		Skip     int                    `json:"skip"`
+	}
+	decoder := json.NewDecoder(strings.NewReader(queryString))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to parse query: %v", err)
+	}
+	if raw.Selector == nil {
+		return nil, fmt.Errorf("query has no selector")
+	}
+
+	q := &Query{Selector: raw.Selector, Limit: raw.Limit, Skip: raw.Skip}
+	for _, item := range raw.Sort {
+		switch field := item.(type) {
+		case string:
+			q.Sort = append(q.Sort, SortField{Field: field})
+		case map[string]interface{}:
+			for name, direction := range field {
+				switch direction {
+				case "asc":
+					q.Sort = append(q.Sort, SortField{Field: name})
+				case "desc":
+					q.Sort = append(q.Sort, SortField{Field: name, Descending: true})
+				default:
+					return nil, fmt.Errorf("invalid sort direction %v for field %s", direction, name)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("invalid sort field %v", item)
+		}
+	}
+
+	return q, nil
+}
+
+// window applies skip and limit to results
+func (q *Query) window(results []KV) []KV {
+	if q.Skip > 0 {
+		if q.Skip >= len(results) {
+			return nil
+		}
+		results = results[q.Skip:]
+	}
+	if q.Limit > 0 && q.Limit < len(results) {
+		results = results[:q.Limit]
+	}
+	return results
+}
+
+// less orders documents by the query's sort fields
+func (q *Query) less(a, b map[string]interface{}) bool {
+	for _, field := range q.Sort {
+		av, _ := lookup(a, field.Field)
+		bv, _ := lookup(b, field.Field)
+		c := collate(av, bv)
+		if c == 0 {
+			continue
+		}
+		if field.Descending {
+			return c > 0
+		}
+		return c < 0
+	}
+	return false
+}
+
+func decodeDocument(value []byte) (map[string]interface{}, bool) {
+	var document map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, false
+	}
+	return document, true
+}
+
+// matchSelector reports whether document satisfies every clause of selector
+func matchSelector(selector map[string]interface{}, document map[string]interface{}) (bool, error) {
+	for field, condition := range selector {
+		var matched bool
+		var err error
+		switch field {
+		case "$and", "$or", "$nor":
+			matched, err = matchCombination(field, condition, document)
+		case "$not":
+			sub, ok := condition.(map[string]interface{})
+			if !ok {
+				return false, fmt.Errorf("$not requires a selector")
+			}
+			matched, err = matchSelector(sub, document)
+			matched = !matched
+		default:
+			if strings.HasPrefix(field, "$") {
+				return false, fmt.Errorf("unsupported operator %s", field)
+			}
+			value, exists := lookup(document, field)
+			matched, err = matchCondition(condition, value, exists)
+		}
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func matchCombination(operator string, condition interface{}, document map[string]interface{}) (bool, error) {
+	clauses, ok := condition.([]interface{})
+	if !ok {
+		return false, fmt.Errorf("%s requires an array of selectors", operator)
+	}
+
+	for _, clause := range clauses {
+		sub, ok := clause.(map[string]interface{})
+		if !ok {
+			return false, fmt.Errorf("%s requires an array of selectors", operator)
+		}
+		matched, err := matchSelector(sub, document)
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case operator == "$and" && !matched:
+			return false, nil
+		case operator == "$or" && matched:
+			return true, nil
+		case operator == "$nor" && matched:
+			return false, nil
+		}
+	}
+
+	return operator != "$or", nil
+}
+
+// matchCondition evaluates the condition on one field. A condition is a value to equal, an
+// object of operators, or an object without operators selecting fields of a nested object.
+func matchCondition(condition interface{}, value interface{}, exists bool) (bool, error) {
+	operators, ok := condition.(map[string]interface{})
+	if !ok || !hasOperators(operators) {
+		if ok {
+			nested, isObject := value.(map[string]interface{})
+			if !isObject {
+				return false, nil
+			}
+			return matchSelector(operators, nested)
+		}
+		return exists && collate(value, condition) == 0, nil
+	}
+
+	for operator, operand := range operators {
+		matched, err := matchOperator(operator, operand, value, exists)
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func hasOperators(object map[string]interface{}) bool {
+	for key := range object {
+		if strings.HasPrefix(key, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+func matchOperator(operator string, operand interface{}, value interface{}, exists bool) (bool, error) {
+	switch operator {
+	case "$exists":
+		want, ok := operand.(bool)
+		if !ok {
+			return false, fmt.Errorf("$exists requires a boolean")
+		}
+		return exists == want, nil
+	case "$ne":
+		return !exists || collate(value, operand) != 0, nil
+	case "$nin":
+		values, ok := operand.([]interface{})
+		if !ok {
+			return false, fmt.Errorf("$nin requires an array")
+		}
+		for _, v := range values {
+			if exists && collate(value, v) == 0 {
+				return false, nil
+			}
+		}
+		return true, nil
+	case "$not":
+		matched, err := matchCondition(operand, value, exists)
+		return !matched, err
+	}
+
+	if !exists {
+		return false, nil
+	}
+
+	switch operator {
+	case "$eq":
+		return collate(value, operand) == 0, nil
+	case "$gt":
+		return collate(value, operand) > 0, nil
+	case "$gte":
+		return collate(value, operand) >= 0, nil
+	case "$lt":
+		return collate(value, operand) < 0, nil
+	case "$lte":
+		return collate(value, operand) <= 0, nil
+	case "$in":
+		values, ok := operand.([]interface{})
+		if !ok {
+			return false, fmt.Errorf("$in requires an array")
+		}
+		for _, v := range values {
+			if collate(value, v) == 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$regex":
+		pattern, ok := operand.(string)
+		if !ok {
+			return false, fmt.Errorf("$regex requires a string")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return false, fmt.Errorf("invalid $regex %q: %v", pattern, err)
+		}
+		s, ok := value.(string)
+		return ok && re.MatchString(s), nil
+	case "$all":
+		want, ok := operand.([]interface{})
+		if !ok {
+			return false, fmt.Errorf("$all requires an array")
+		}
+		have, ok := value.([]interface{})
+		if !ok {
+			return false, nil
+		}
+		for _, w := range want {
+			found := false
+			for _, h := range have {
+				if collate(h, w) == 0 {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false, nil
+			}
+		}
+		return true, nil
+	default:
+		return false, fmt.Errorf("unsupported operator %s", operator)
+	}
+}
+
+// lookup returns the value at a dotted field path
+func lookup(document map[string]interface{}, path string) (interface{}, bool) {
+	var value interface{} = document
+	for _, part := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		value, ok = object[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// collate compares two JSON values in CouchDB order: null, false, true, numbers, strings,
+// arrays and then objects
+func collate(a, b interface{}) int {
+	// false and true have ranks of their own, so equal ranks below mean equal booleans
+	ra, rb := collationRank(a), collationRank(b)
+	if ra != rb {
+		return ra - rb
+	}
+
+	switch av := a.(type) {
+	case json.Number:
+		af, _ := av.Float64()
+		bf, _ := b.(json.Number).Float64()
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		default:
+			return 0
+		}
+	case string:
+		return strings.Compare(av, b.(string))
+	case []interface{}:
+		bv := b.([]interface{})
+		for i := 0; i < len(av) && i < len(bv); i++ {
+			if c := collate(av[i], bv[i]); c != 0 {
+				return c
+			}
+		}
+		return len(av) - len(bv)
+	case map[string]interface{}:
+		bv := b.(map[string]interface{})
+		keys := make([]string, 0, len(av))
+		for key := range av {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			other, ok := bv[key]
+			if !ok {
+				return 1
+			}
+			if c := collate(av[key], other); c != 0 {
+				return c
+			}
+		}
+		return len(av) - len(bv)
+	default:
+		return 0
+	}
+}
+
+func collationRank(v interface{}) int {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if v {
+			return 2
+		}
+		return 1
+	case json.Number:
+		return 3
+	case string:
+		return 4
+	case []interface{}:
+		return 5
+	case map[string]interface{}:
+		return 6
+	default:
+		return 0
+	}
+}
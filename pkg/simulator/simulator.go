@@ -0,0 +1,496 @@
+// Package simulator is an in-memory stand-in for the logging chaincode. Simulator implements
+// client.LoggingClient over a fake world state with CouchDB rich-query emulation, so code
+// integrating with the ledger can be unit-tested without a running Fabric network.
+//
+// The simulator applies the chaincode's rules: logs are validated as the chaincode validates
+// them, IDs are unique, batches are all or nothing, and the chaincode assigns timestamps. Each
+// transaction is committed in a block of its own and emits the chaincode's events.
+package simulator
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// logObjectType is the composite-key namespace of log records, as in the chaincode
+const logObjectType = "LOG"
+
+// prunedObjectType is the composite-key namespace of the tombstones PruneLogs leaves, as in the
+// chaincode
+const prunedObjectType = "PRUNED"
+
+// ErrClosed is returned by calls made after Close
+var ErrClosed = errors.New("simulator is closed")
+
+// record is a log as the chaincode stores it in the world state
+type record struct {
+	DocType string `json:"docType"`
+	client.LogEvent
+}
+
+// Option configures a Simulator
+type Option func(*Simulator)
+
+// WithClock sets the time source used to timestamp new logs, for deterministic tests
+func WithClock(now func() time.Time) Option {
+	return func(s *Simulator) {
+		s.now = now
+	}
+}
+
+// WithEventHandler calls handler with every log committed, as an EventListener would deliver it
+func WithEventHandler(handler client.EventHandler) Option {
+	return func(s *Simulator) {
+		s.handlers = append(s.handlers, handler)
+	}
+}
+
+// Simulator is an in-memory logging chaincode implementing client.LoggingClient. It is safe for
+// concurrent use; transactions are applied one at a time.
+type Simulator struct {
+	stub     *Stub
+	now      func() time.Time
+	handlers []client.EventHandler
+
+	// mu serializes transactions, as ordering does on a network
+	mu          sync.Mutex
+	blockNumber uint64
+	events      []client.ContractEvent
+	closed      bool
+}
+
+var _ client.LoggingClient = (*Simulator)(nil)
+
+// New returns a simulator with an empty ledger
+func New(opts ...Option) *Simulator {
+	s := &Simulator{stub: NewStub(), now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Stub returns the simulator's world state, so tests can inspect it or run their own queries
+func (s *Simulator) Stub() *Stub {
+	return s.stub
+}
+
+// CreateLog records a new log, timestamped by the simulator
+func (s *Simulator) CreateLog(ctx context.Context, log client.LogEvent) error {
+	// The chaincode's CreateLog takes no collection
+	log.Collection = ""
+	_, err := s.submit(ctx, client.LogCreatedEvent, []client.LogEvent{log}, true)
+	return err
+}
+
+// CreateLogsBatch records several new logs in one transaction; none is recorded if any fails
+func (s *Simulator) CreateLogsBatch(ctx context.Context, logs []client.LogEvent) error {
+	_, err := s.submit(ctx, client.LogsCreatedEvent, logs, true)
+	return err
+}
+
+// RestoreLogsBatch records logs keeping their timestamps, as the chaincode's RestoreLogsBatch
+// does for a restore identity
+func (s *Simulator) RestoreLogsBatch(ctx context.Context, logs []client.LogEvent) (*client.SubmitResult, error) {
+	for _, log := range logs {
+		if _, err := time.Parse(time.RFC3339, log.Timestamp); err != nil {
+			return nil, fmt.Errorf("%w: the timestamp of log %s must be RFC 3339: %v", client.ErrInvalidLog, log.ID, err)
+		}
+	}
+	return s.submit(ctx, client.LogsCreatedEvent, logs, false)
+}
+
+// PruneLogs deletes logs recorded before the cutoff in one transaction, as the chaincode's
+// PruneLogs does for a retention identity. Logs already deleted are skipped, and nothing is
+// deleted if any log was recorded at or after before. Each log deleted leaves a tombstone,
+// without PrunedBy since the simulator has no client identity.
+func (s *Simulator) PruneLogs(ctx context.Context, before time.Time, ids []string) (*client.SubmitResult, error) {
+	if err := s.check(ctx); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%w: no logs to prune", client.ErrInvalidLog)
+	}
+	cutoff := before.Truncate(time.Second)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrClosed
+	}
+
+	prunedAt := s.now().UTC().Format(time.RFC3339)
+	writes := make(map[string][]byte, 2*len(ids))
+	for _, id := range ids {
+		key, err := CreateCompositeKey(logObjectType, []string{id})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create key for log %s: %v", id, err)
+		}
+		value := s.stub.GetState(key)
+		if value == nil {
+			continue
+		}
+
+		log, err := decodeRecord(value)
+		if err != nil {
+			return nil, err
+		}
+		timestamp, err := time.Parse(time.RFC3339, log.Timestamp)
+		if err != nil || !timestamp.Before(cutoff) {
+			return nil, fmt.Errorf("%w: the log %s was recorded at %s, not before %s", client.ErrInvalidLog, id, log.Timestamp, cutoff.Format(time.RFC3339))
+		}
+		writes[key] = nil
+
+		tombstoneJSON, err := json.Marshal(struct {
+			DocType string `json:"docType"`
+			client.PrunedLog
+		}{prunedObjectType, client.PrunedLog{
+			ID:        id,
+			Timestamp: log.Timestamp,
+			PrunedAt:  prunedAt,
+			Before:    cutoff.UTC().Format(time.RFC3339),
+		}})
+		if err != nil {
+			return nil, err
+		}
+		tombstoneKey, err := CreateCompositeKey(prunedObjectType, []string{id})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create key for the tombstone of log %s: %v", id, err)
+		}
+		writes[tombstoneKey] = tombstoneJSON
+	}
+
+	s.stub.Commit(writes)
+	s.blockNumber++
+	return &client.SubmitResult{
+		TransactionID: newTransactionID(),
+		Finality:      client.FinalityCommitted,
+		BlockNumber:   s.blockNumber,
+		Status:        "VALID",
+	}, nil
+}
+
+// ReadLog returns the log with given id
+func (s *Simulator) ReadLog(ctx context.Context, id string) (*client.LogEvent, error) {
+	if err := s.check(ctx); err != nil {
+		return nil, err
+	}
+
+	value, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, fmt.Errorf("%w: the log %s does not exist", client.ErrLogNotFound, id)
+	}
+
+	return decodeRecord(value)
+}
+
+// PrunedLog returns the tombstone PruneLogs left for the log with given id
+func (s *Simulator) PrunedLog(ctx context.Context, id string) (*client.PrunedLog, error) {
+	if err := s.check(ctx); err != nil {
+		return nil, err
+	}
+
+	key, err := CreateCompositeKey(prunedObjectType, []string{id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key for the tombstone of log %s: %v", id, err)
+	}
+	value := s.stub.GetState(key)
+	if value == nil {
+		return nil, fmt.Errorf("%w: the log %s has not been pruned", client.ErrLogNotFound, id)
+	}
+
+	var tombstone client.PrunedLog
+	if err := json.Unmarshal(value, &tombstone); err != nil {
+		return nil, err
+	}
+	return &tombstone, nil
+}
+
+// LogExists reports whether a log with given id exists
+func (s *Simulator) LogExists(ctx context.Context, id string) (bool, error) {
+	if err := s.check(ctx); err != nil {
+		return false, err
+	}
+
+	value, err := s.get(id)
+	return value != nil, err
+}
+
+// Ping succeeds until the simulator is closed, as the chaincode's Ping does while it runs
+func (s *Simulator) Ping(ctx context.Context) error {
+	return s.check(ctx)
+}
+
+// GetAllLogs returns every log in key order
+func (s *Simulator) GetAllLogs(ctx context.Context) ([]*client.LogEvent, error) {
+	if err := s.check(ctx); err != nil {
+		return nil, err
+	}
+
+	results, err := s.stub.GetStateByPartialCompositeKey(logObjectType, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeRecords(results)
+}
+
+// GetAllLogsPage returns one page of logs starting at bookmark
+func (s *Simulator) GetAllLogsPage(ctx context.Context, pageSize int32, bookmark string) (*client.LogPage, error) {
+	if err := s.check(ctx); err != nil {
+		return nil, err
+	}
+
+	results, next, err := s.stub.GetStateByPartialCompositeKeyWithPagination(logObjectType, nil, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	return newPage(results, next)
+}
+
+// QueryLogs returns the logs matching filter, through the same rich query the chaincode runs
+func (s *Simulator) QueryLogs(ctx context.Context, filter client.LogFilter) ([]*client.LogEvent, error) {
+	if err := s.check(ctx); err != nil {
+		return nil, err
+	}
+
+	results, err := s.stub.GetQueryResult(queryString(filter))
+	if err != nil {
+		return nil, err
+	}
+	return decodeRecords(results)
+}
+
+// QueryLogsPage returns one page of the logs matching filter, starting at bookmark
+func (s *Simulator) QueryLogsPage(ctx context.Context, filter client.LogFilter, pageSize int32, bookmark string) (*client.LogPage, error) {
+	if err := s.check(ctx); err != nil {
+		return nil, err
+	}
+
+	results, next, err := s.stub.GetQueryResultWithPagination(queryString(filter), pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	return newPage(results, next)
+}
+
+// Events returns the events of every committed transaction, in commit order
+func (s *Simulator) Events() []client.ContractEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]client.ContractEvent(nil), s.events...)
+}
+
+// BlockHeight returns the number of blocks committed, counting the genesis block, as a peer reports it
+func (s *Simulator) BlockHeight(ctx context.Context) (uint64, error) {
+	if err := s.check(ctx); err != nil {
+		return 0, err
+	}
+	return s.BlockNumber() + 1, nil
+}
+
+// BlockNumber returns the number of the last committed block, or zero before the first transaction
+func (s *Simulator) BlockNumber() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.blockNumber
+}
+
+// Close makes later calls fail with ErrClosed
+func (s *Simulator) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	return nil
+}
+
+// submit runs one write transaction and then passes its events to the event handlers, outside
+// the lock so a handler may call back into the simulator
+func (s *Simulator) submit(ctx context.Context, eventName string, logs []client.LogEvent, stamp bool) (*client.SubmitResult, error) {
+	if err := s.check(ctx); err != nil {
+		return nil, err
+	}
+	if len(logs) == 0 {
+		return nil, fmt.Errorf("%w: the logs batch is empty", client.ErrInvalidLog)
+	}
+
+	result, events, err := s.commit(eventName, logs, stamp)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, handler := range s.handlers {
+		for _, event := range events {
+			if err := handler(event); err != nil {
+				errs = append(errs, err)
+				break
+			}
+		}
+	}
+
+	// The transaction is committed either way; handler failures are reported alongside it
+	return result, errors.Join(errs...)
+}
+
+// commit validates and writes logs in a block of their own. Reads see only state committed
+// before the transaction, as on a peer, so duplicates within the batch are caught separately.
+func (s *Simulator) commit(eventName string, logs []client.LogEvent, stamp bool) (*client.SubmitResult, []client.ContractEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, nil, ErrClosed
+	}
+
+	timestamp := s.now().Format(time.RFC3339)
+	writes := make(map[string][]byte, len(logs))
+	stored := make([]client.LogEvent, len(logs))
+	for i, log := range logs {
+		if err := client.ValidateLog(log); err != nil {
+			return nil, nil, err
+		}
+		if log.Collection != "" {
+			return nil, nil, fmt.Errorf("%w: the log %s names collection %s; only CreatePrivateLog writes private logs", client.ErrInvalidLog, log.ID, log.Collection)
+		}
+
+		key, err := CreateCompositeKey(logObjectType, []string{log.ID})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create key for log %s: %v", log.ID, err)
+		}
+		if _, ok := writes[key]; ok {
+			return nil, nil, fmt.Errorf("%w: the log %s appears more than once in the batch", client.ErrAlreadyExists, log.ID)
+		}
+		if s.stub.GetState(key) != nil {
+			return nil, nil, fmt.Errorf("%w: the log %s already exists", client.ErrAlreadyExists, log.ID)
+		}
+
+		if stamp {
+			log.Timestamp = timestamp
+		}
+		value, err := json.Marshal(record{DocType: logObjectType, LogEvent: log})
+		if err != nil {
+			return nil, nil, err
+		}
+		writes[key] = value
+		stored[i] = log
+	}
+
+	s.stub.Commit(writes)
+	s.blockNumber++
+	result := &client.SubmitResult{
+		TransactionID: newTransactionID(),
+		Finality:      client.FinalityCommitted,
+		BlockNumber:   s.blockNumber,
+		Status:        "VALID",
+	}
+
+	events := make([]client.ContractEvent, len(stored))
+	for i, log := range stored {
+		events[i] = client.ContractEvent{
+			BlockNumber:   result.BlockNumber,
+			TransactionID: result.TransactionID,
+			EventName:     eventName,
+			Log:           log,
+		}
+	}
+	s.events = append(s.events, events...)
+
+	return result, events, nil
+}
+
+func (s *Simulator) check(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+func (s *Simulator) get(id string) ([]byte, error) {
+	key, err := CreateCompositeKey(logObjectType, []string{id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key for log %s: %v", id, err)
+	}
+	return s.stub.GetState(key), nil
+}
+
+// queryString compiles filter into the selector the chaincode's QueryLogs builds
+func queryString(filter client.LogFilter) string {
+	selector := map[string]interface{}{"docType": logObjectType}
+	if filter.UserID != "" {
+		selector["userId"] = filter.UserID
+	}
+	if filter.Action != "" {
+		selector["action"] = filter.Action
+	}
+	if filter.Resource != "" {
+		selector["resource"] = filter.Resource
+	}
+	if filter.StartTime != "" || filter.EndTime != "" {
+		timestamp := map[string]string{}
+		if filter.StartTime != "" {
+			timestamp["$gte"] = filter.StartTime
+		}
+		if filter.EndTime != "" {
+			timestamp["$lte"] = filter.EndTime
+		}
+		selector["timestamp"] = timestamp
+	}
+
+	queryJSON, _ := json.Marshal(map[string]interface{}{"selector": selector})
+	return string(queryJSON)
+}
+
+func decodeRecord(value []byte) (*client.LogEvent, error) {
+	var r record
+	if err := json.Unmarshal(value, &r); err != nil {
+		return nil, err
+	}
+	return &r.LogEvent, nil
+}
+
+func decodeRecords(results []KV) ([]*client.LogEvent, error) {
+	var logs []*client.LogEvent
+	for _, result := range results {
+		log, err := decodeRecord(result.Value)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, log)
+	}
+	return logs, nil
+}
+
+func newPage(results []KV, bookmark string) (*client.LogPage, error) {
+	logs, err := decodeRecords(results)
+	if err != nil {
+		return nil, err
+	}
+	return &client.LogPage{Records: logs, FetchedRecordsCount: int32(len(logs)), Bookmark: bookmark}, nil
+}
+
+func newTransactionID() string {
+	id := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,247 @@
+package simulator
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Composite keys are built as Fabric's shim builds them, so keys and their order match a peer's
+const (
+	compositeKeyNamespace = "\x00"
+	minUnicodeRuneValue   = 0
+	maxUnicodeRuneValue   = utf8.MaxRune
+)
+
+// KV is one world state entry returned by a query
+type KV struct {
+	Key   string
+	Value []byte
+}
+
+// Stub is an in-memory world state emulating the parts of the chaincode stub the logging
+// contract relies on: composite keys, range scans with pagination and CouchDB rich queries.
+// Reads see only committed state, as on a peer; writes are applied together by Commit.
+type Stub struct {
+	mu    sync.RWMutex
+	state map[string][]byte
+}
+
+// NewStub returns an empty world state
+func NewStub() *Stub {
+	return &Stub{state: map[string][]byte{}}
+}
+
+// CreateCompositeKey combines objectType and attributes into a composite key
+func CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	if err := validateCompositeKeyAttribute(objectType); err != nil {
+		return "", err
+	}
+
+	key := compositeKeyNamespace + objectType + string(rune(minUnicodeRuneValue))
+	for _, attribute := range attributes {
+		if err := validateCompositeKeyAttribute(attribute); err != nil {
+			return "", err
+		}
+		key += attribute + string(rune(minUnicodeRuneValue))
+	}
+
+	return key, nil
+}
+
+func validateCompositeKeyAttribute(s string) error {
+	if !utf8.ValidString(s) {
+		return fmt.Errorf("not a valid utf8 string: [%x]", s)
+	}
+	for index, r := range s {
+		if r == minUnicodeRuneValue || r == maxUnicodeRuneValue {
+			return fmt.Errorf("input contains unicode %#U starting at position [%d]. %#U and %#U are not allowed in the input attribute of a composite key",
+				r, index, minUnicodeRuneValue, maxUnicodeRuneValue)
+		}
+	}
+
+	return nil
+}
+
+// GetState returns the committed value of key, or nil when it does not exist
+func (s *Stub) GetState(key string) []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.state[key]
+}
+
+// Commit applies a transaction's write set. A nil value deletes the key.
+func (s *Stub) Commit(writes map[string][]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, value := range writes {
+		if value == nil {
+			delete(s.state, key)
+		} else {
+			s.state[key] = value
+		}
+	}
+}
+
+// GetStateByPartialCompositeKey returns the entries whose composite key starts with objectType
+// and attributes, in key order
+func (s *Stub) GetStateByPartialCompositeKey(objectType string, attributes []string) ([]KV, error) {
+	prefix, err := CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var results []KV
+	for _, key := range s.sortedKeys() {
+		if strings.HasPrefix(key, prefix) {
+			results = append(results, KV{Key: key, Value: s.state[key]})
+		}
+	}
+
+	return results, nil
+}
+
+// GetStateByPartialCompositeKeyWithPagination returns one page of GetStateByPartialCompositeKey
+// starting after bookmark, and the bookmark of the next page
+func (s *Stub) GetStateByPartialCompositeKeyWithPagination(objectType string, attributes []string, pageSize int32, bookmark string) ([]KV, string, error) {
+	results, err := s.GetStateByPartialCompositeKey(objectType, attributes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return paginate(results, pageSize, bookmark, false)
+}
+
+// GetQueryResult runs a CouchDB Mango query over the JSON values in the world state. See
+// Query for the supported subset.
+func (s *Stub) GetQueryResult(queryString string) ([]KV, error) {
+	q, err := parseQuery(queryString)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := s.query(q)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.window(results), nil
+}
+
+// GetQueryResultWithPagination returns one page of GetQueryResult starting after bookmark, and
+// the bookmark of the next page
+func (s *Stub) GetQueryResultWithPagination(queryString string, pageSize int32, bookmark string) ([]KV, string, error) {
+	q, err := parseQuery(queryString)
+	if err != nil {
+		return nil, "", err
+	}
+
+	results, err := s.query(q)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return paginate(q.window(results), pageSize, bookmark, len(q.Sort) > 0)
+}
+
+// query returns the entries matching q's selector, in q's sort order or else key order
+func (s *Stub) query(q *Query) ([]KV, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var results []KV
+	var documents []map[string]interface{}
+	for _, key := range s.sortedKeys() {
+		document, ok := decodeDocument(s.state[key])
+		if !ok {
+			continue
+		}
+		matched, err := matchSelector(q.Selector, document)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			results = append(results, KV{Key: key, Value: s.state[key]})
+			documents = append(documents, document)
+		}
+	}
+
+	if len(q.Sort) > 0 {
+		order := make([]int, len(results))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return q.less(documents[order[i]], documents[order[j]])
+		})
+		sorted := make([]KV, len(results))
+		for i, index := range order {
+			sorted[i] = results[index]
+		}
+		results = sorted
+	}
+
+	return results, nil
+}
+
+// sortedKeys returns the keys of the world state in order. The caller holds the lock.
+func (s *Stub) sortedKeys() []string {
+	keys := make([]string, 0, len(s.state))
+	for key := range s.state {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// paginate returns up to pageSize results following the one bookmark names. The bookmark is the
+// last key of the previous page; when that key is gone, an unsorted query resumes at the next key.
+func paginate(results []KV, pageSize int32, bookmark string, sorted bool) ([]KV, string, error) {
+	if pageSize <= 0 {
+		return nil, "", fmt.Errorf("page size must be positive")
+	}
+
+	start := 0
+	if bookmark != "" {
+		last, err := base64.RawURLEncoding.DecodeString(bookmark)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid bookmark %q", bookmark)
+		}
+		start = -1
+		for i, result := range results {
+			if result.Key == string(last) || (!sorted && result.Key > string(last)) {
+				start = i
+				if result.Key == string(last) {
+					start++
+				}
+				break
+			}
+		}
+		if start < 0 {
+			if sorted {
+				return nil, "", fmt.Errorf("bookmark %q no longer matches the query", bookmark)
+			}
+			start = len(results)
+		}
+	}
+
+	end := start + int(pageSize)
+	if end > len(results) {
+		end = len(results)
+	}
+	page := results[start:end]
+	if len(page) == 0 {
+		return page, bookmark, nil
+	}
+
+	return page, base64.RawURLEncoding.EncodeToString([]byte(page[len(page)-1].Key)), nil
+}
@@ -0,0 +1,45 @@
+package splunk
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricsNamespace prefixes every metric exported by the server, as it does the client's
+const metricsNamespace = "fabric_logging"
+
+// metrics holds the Prometheus instruments of a Server
+type metrics struct {
+	events       *prometheus.CounterVec
+	authFailures prometheus.Counter
+}
+
+func newMetrics(registerer prometheus.Registerer) (*metrics, error) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	m := &metrics{
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "hec",
+			Name:      "events_total",
+			Help:      "Events received over the HTTP Event Collector protocol, by result: submitted or failed.",
+		}, []string{"result"}),
+		authFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "hec",
+			Name:      "auth_failures_total",
+			Help:      "Requests without a valid HEC token.",
+		}),
+	}
+
+	for _, collector := range []prometheus.Collector{m.events, m.authFailures} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, fmt.Errorf("failed to register hec metrics: %v", err)
+		}
+	}
+
+	return m, nil
+}
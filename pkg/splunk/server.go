@@ -0,0 +1,473 @@
+// Package splunk speaks the Splunk HTTP Event Collector (HEC) protocol in both directions. A
+// Server accepts the requests of anything that already sends events to Splunk, such as the
+// Splunk logging libraries, the OpenTelemetry Collector's splunk_hec exporter or Vector, and
+// records their events on the ledger. A Sink mirrors the logs committed on the ledger out to a
+// real HEC, so they can be searched in Splunk.
+package splunk
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultMaxBodyBytes is the largest request body read by default, after decompression
+const DefaultMaxBodyBytes = 8 << 20
+
+// backpressureWait is how long a request waits before retrying an event the submitter pushed back
+const backpressureWait = 100 * time.Millisecond
+
+// Paths of the collector, as Splunk serves them
+const (
+	EventPath  = "/services/collector/event"
+	RawPath    = "/services/collector/raw"
+	HealthPath = "/services/collector/health"
+)
+
+// Event keys read for the fields of a log, besides the id, userId, action and resource keys.
+// The first message key found becomes the description and the first level key the severity;
+// every other key, and the event's host, source, sourcetype, index and fields, is kept in the
+// metadata.
+var (
+	DefaultMessageKeys = []string{"message", "msg", "log"}
+	levelKeys          = []string{"level", "severity"}
+)
+
+// Options configures a Server
+type Options struct {
+	// Submitter receives the logs, usually a BatchingSubmitter
+	Submitter client.Submitter
+	// Flush submits every log handed to Submitter, such as BatchingSubmitter.Flush. A request is
+	// only answered with success once flushed, so the sender keeps its events until they are
+	// committed; without Flush it is answered once its events are handed over.
+	Flush func(ctx context.Context) error
+	// Tokens maps each HEC token accepted to its name. Events without a userId key are recorded
+	// under the name of their token, and without a source under http:<name>, as Splunk names
+	// the source of a token.
+	Tokens map[string]string
+	// Action is used for events without an action key; LOG_<LEVEL> is recorded otherwise
+	Action string
+	// MessageKeys are the event keys the description is read from; DefaultMessageKeys is used when empty
+	MessageKeys []string
+	// MaxBodyBytes is the largest request body read, after decompression; DefaultMaxBodyBytes is
+	// used when zero
+	MaxBodyBytes int64
+	// Registerer receives the fabric_logging_hec_* metrics; prometheus.DefaultRegisterer is used when nil
+	Registerer prometheus.Registerer
+	// OnError is called with the events the Submitter rejected
+	OnError func(error)
+}
+
+// Server is an http.Handler serving the event, raw and health endpoints of a HEC
+type Server struct {
+	options Options
+	handler *client.SlogHandler
+	metrics *metrics
+}
+
+// response is the body of every answer, with the codes Splunk gives them
+type response struct {
+	Text               string `json:"text"`
+	Code               int    `json:"code"`
+	InvalidEventNumber *int   `json:"invalid-event-number,omitempty"`
+}
+
+// HEC answers, by the codes HEC clients act on
+var (
+	responseSuccess      = response{Text: "Success", Code: 0}
+	responseNoToken      = response{Text: "Token is required", Code: 2}
+	responseInvalidAuth  = response{Text: "Invalid authorization", Code: 3}
+	responseInvalidToken = response{Text: "Invalid token", Code: 4}
+	responseNoData       = response{Text: "No data", Code: 5}
+	responseInvalidData  = response{Text: "Invalid data format", Code: 6}
+	responseBusy         = response{Text: "Server is busy", Code: 9}
+	responseNoEvent      = response{Text: "Event field is required", Code: 12}
+	responseBlankEvent   = response{Text: "Event field cannot be blank", Code: 13}
+	responseHealthy      = response{Text: "HEC is healthy", Code: 17}
+)
+
+// errInvalidEvent carries the answer to a request with an invalid event
+type errInvalidEvent struct {
+	response response
+}
+
+func (e *errInvalidEvent) Error() string {
+	return fmt.Sprintf("%s at event %d", e.response.Text, *e.response.InvalidEventNumber)
+}
+
+// event is an event of the event endpoint
+type event struct {
+	Time       json.RawMessage        `json:"time"`
+	Host       string                 `json:"host"`
+	Source     string                 `json:"source"`
+	SourceType string                 `json:"sourcetype"`
+	Index      string                 `json:"index"`
+	Event      interface{}            `json:"event"`
+	Fields     map[string]interface{} `json:"fields"`
+}
+
+// NewServer returns a Server submitting through options.Submitter
+func NewServer(options Options) (*Server, error) {
+	if options.Submitter == nil {
+		return nil, fmt.Errorf("no submitter configured")
+	}
+	if len(options.Tokens) == 0 {
+		return nil, fmt.Errorf("no HEC tokens configured")
+	}
+	if len(options.MessageKeys) == 0 {
+		options.MessageKeys = DefaultMessageKeys
+	}
+	if options.MaxBodyBytes <= 0 {
+		options.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+
+	m, err := newMetrics(options.Registerer)
+	if err != nil {
+		return nil, err
+	}
+	handler := client.NewSlogHandler(options.Submitter, client.SlogHandlerOptions{
+		Level: slog.LevelDebug - 4,
+	})
+	return &Server{options: options, handler: handler, metrics: m}, nil
+}
+
+// ServeHTTP routes a request to the collector endpoint of its path. The event endpoint is also
+// served at /services/collector and with the /1.0 suffix, as Splunk does.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/1.0")
+	switch {
+	case path == HealthPath:
+		writeResponse(w, http.StatusOK, responseHealthy)
+		return
+	case path != EventPath && path != RawPath && path != "/services/collector":
+		writeResponse(w, http.StatusNotFound, response{Text: "The requested URL was not found on this server.", Code: 404})
+		return
+	case r.Method != http.MethodPost:
+		w.Header().Set("Allow", http.MethodPost)
+		writeResponse(w, http.StatusMethodNotAllowed, response{Text: "Method not allowed", Code: 405})
+		return
+	}
+
+	name, status, answer := s.authenticate(r)
+	if status != http.StatusOK {
+		s.metrics.authFailures.Inc()
+		writeResponse(w, status, answer)
+		return
+	}
+
+	body, err := s.body(w, r)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, responseInvalidData)
+		return
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		writeResponse(w, http.StatusBadRequest, responseNoData)
+		return
+	}
+
+	defaults := event{
+		Host:       r.URL.Query().Get("host"),
+		Source:     r.URL.Query().Get("source"),
+		SourceType: r.URL.Query().Get("sourcetype"),
+		Index:      r.URL.Query().Get("index"),
+	}
+	if defaults.Source == "" {
+		defaults.Source = "http:" + name
+	}
+	if path == RawPath {
+		err = s.raw(r.Context(), name, defaults, body)
+	} else {
+		err = s.events(r.Context(), name, defaults, body)
+	}
+
+	// Events before an invalid one are recorded, as Splunk indexes them
+	var invalid *errInvalidEvent
+	if err == nil || errors.As(err, &invalid) {
+		if s.options.Flush != nil {
+			if flushErr := s.options.Flush(r.Context()); flushErr != nil {
+				// The sender retries a request answered busy
+				s.report(fmt.Errorf("failed to flush the events of token %s: %w", name, flushErr))
+				writeResponse(w, http.StatusServiceUnavailable, responseBusy)
+				return
+			}
+		}
+	}
+	switch {
+	case invalid != nil:
+		writeResponse(w, http.StatusBadRequest, invalid.response)
+	case err != nil:
+		writeResponse(w, http.StatusServiceUnavailable, responseBusy)
+	default:
+		writeResponse(w, http.StatusOK, responseSuccess)
+	}
+}
+
+// authenticate returns the name of the request's token, taken from an Authorization header of
+// the Splunk scheme or from the password of basic authentication
+func (s *Server) authenticate(r *http.Request) (string, int, response) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", http.StatusUnauthorized, responseNoToken
+	}
+	token, ok := strings.CutPrefix(header, "Splunk ")
+	if !ok {
+		if _, token, ok = r.BasicAuth(); !ok {
+			return "", http.StatusUnauthorized, responseInvalidAuth
+		}
+	}
+	for candidate, name := range s.options.Tokens {
+		if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(candidate)) == 1 {
+			return name, http.StatusOK, response{}
+		}
+	}
+	return "", http.StatusForbidden, responseInvalidToken
+}
+
+// body reads the request body, decompressing it when it is gzipped, up to the size limit
+func (s *Server) body(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	var reader io.Reader = http.MaxBytesReader(w, r.Body, s.options.MaxBodyBytes)
+	if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	body, err := io.ReadAll(io.LimitReader(reader, s.options.MaxBodyBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > s.options.MaxBodyBytes {
+		return nil, fmt.Errorf("the body exceeds %d bytes", s.options.MaxBodyBytes)
+	}
+	return body, nil
+}
+
+// events submits the events of an event endpoint body: JSON objects one after another
+func (s *Server) events(ctx context.Context, name string, defaults event, body []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	for n := 0; ; n++ {
+		e := defaults
+		e.Event, e.Fields = nil, nil
+		var raw map[string]json.RawMessage
+		err := decoder.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err == nil {
+			err = decodeEvent(raw, &e)
+		}
+		if err != nil {
+			return invalidEvent(responseInvalidData, n)
+		}
+		if _, ok := raw["event"]; !ok {
+			return invalidEvent(responseNoEvent, n)
+		}
+		if e.Event == nil || e.Event == "" {
+			return invalidEvent(responseBlankEvent, n)
+		}
+		t, ok := eventTime(e.Time)
+		if !ok {
+			return invalidEvent(responseInvalidData, n)
+		}
+		if err := s.submit(ctx, s.record(name, e, t)); err != nil {
+			return err
+		}
+	}
+}
+
+// decodeEvent reads the keys of an event present in raw over the defaults in e
+func decodeEvent(raw map[string]json.RawMessage, e *event) error {
+	for key, target := range map[string]interface{}{
+		"time":       &e.Time,
+		"host":       &e.Host,
+		"source":     &e.Source,
+		"sourcetype": &e.SourceType,
+		"index":      &e.Index,
+		"fields":     &e.Fields,
+	} {
+		if value, ok := raw[key]; ok && string(value) != "null" {
+			if err := json.Unmarshal(value, target); err != nil {
+				return err
+			}
+		}
+	}
+	if value, ok := raw["event"]; ok {
+		decoder := json.NewDecoder(bytes.NewReader(value))
+		decoder.UseNumber()
+		return decoder.Decode(&e.Event)
+	}
+	return nil
+}
+
+// raw submits each line of a raw endpoint body as an event
+func (s *Server) raw(ctx context.Context, name string, defaults event, body []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(nil, len(body)+1)
+	now := time.Now()
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		e := defaults
+		e.Event = line
+		if err := s.submit(ctx, s.record(name, e, now)); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// submit hands the log of an event to the submitter, waiting while it pushes back. Events the
+// submitter rejects are reported and skipped, as sending them again would not help.
+func (s *Server) submit(ctx context.Context, r slog.Record) error {
+	for {
+		err := s.handler.Handle(ctx, r)
+		switch {
+		case err == nil:
+			s.metrics.events.WithLabelValues("submitted").Inc()
+		case errors.Is(err, client.ErrBackpressure):
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backpressureWait):
+			}
+			continue
+		default:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			s.metrics.events.WithLabelValues("failed").Inc()
+			s.report(fmt.Errorf("failed to submit an event of %s: %w", r.Message, err))
+		}
+		return nil
+	}
+}
+
+// record builds the slog record of an event
+func (s *Server) record(name string, e event, t time.Time) slog.Record {
+	fields := map[string]interface{}{}
+	message := ""
+	switch value := e.Event.(type) {
+	case map[string]interface{}:
+		for key, v := range value {
+			fields[key] = v
+		}
+		for _, key := range s.options.MessageKeys {
+			if v, ok := fields[key]; ok {
+				message = strings.TrimRight(fmt.Sprint(v), "\r\n")
+				delete(fields, key)
+				break
+			}
+		}
+	case string:
+		message = strings.TrimRight(value, "\r\n")
+	default:
+		data, _ := json.Marshal(value)
+		message = string(data)
+	}
+	for key, v := range e.Fields {
+		if _, ok := fields[key]; !ok {
+			fields[key] = v
+		}
+	}
+	for key, v := range map[string]string{"host": e.Host, "source": e.Source, "sourcetype": e.SourceType, "index": e.Index} {
+		if _, ok := fields[key]; !ok && v != "" {
+			fields[key] = v
+		}
+	}
+
+	level := slog.LevelInfo
+	for _, key := range levelKeys {
+		value, ok := fields[key]
+		if !ok {
+			continue
+		}
+		if parsed, ok := client.ParseSeverity(fmt.Sprint(value)); ok {
+			level = parsed
+			delete(fields, key)
+		}
+		break
+	}
+
+	for _, key := range []string{client.SlogIDKey, client.SlogUserIDKey, client.SlogActionKey, client.SlogResourceKey} {
+		if value, ok := fields[key]; ok {
+			fields[key] = fmt.Sprint(value)
+		}
+	}
+	if _, ok := fields[client.SlogUserIDKey]; !ok {
+		fields[client.SlogUserIDKey] = name
+	}
+	if _, ok := fields[client.SlogActionKey]; !ok && s.options.Action != "" {
+		fields[client.SlogActionKey] = s.options.Action
+	}
+	if _, ok := fields[client.SlogResourceKey]; !ok {
+		fields[client.SlogResourceKey] = e.Source
+	}
+
+	r := slog.NewRecord(t, level, client.Truncate(message, client.MaxDescriptionLength), 0)
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		r.AddAttrs(slog.Any(key, fields[key]))
+	}
+	return r
+}
+
+func (s *Server) report(err error) {
+	if s.options.OnError != nil {
+		s.options.OnError(err)
+	}
+}
+
+// invalidEvent returns the answer to a request whose event n is invalid
+func invalidEvent(answer response, n int) error {
+	answer.InvalidEventNumber = &n
+	return &errInvalidEvent{response: answer}
+}
+
+// eventTime reads an event time, given as whole or fractional Unix seconds in a number or a
+// string; events without one get the current time
+func eventTime(raw json.RawMessage) (time.Time, bool) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return time.Now(), true
+	}
+	text := string(raw)
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+	seconds, err := strconv.ParseFloat(text, 64)
+	if err != nil || seconds < 0 || math.IsInf(seconds, 0) {
+		return time.Time{}, false
+	}
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(math.Round(fraction*1e6))*1e3), true
+}
+
+func writeResponse(w http.ResponseWriter, status int, answer response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(answer)
+}
@@ -0,0 +1,130 @@
+package splunk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// DefaultSourceType is the sourcetype of the events a Sink sends when Config.SourceType is empty
+const DefaultSourceType = "fabric:log"
+
+// Config describes the HEC a Sink sends to
+type Config struct {
+	// URL is the collector endpoint, e.g. https://splunk.example.com:8088
+	URL   string
+	Token string
+	// Index, Source, SourceType and Host are set on every event; the token's defaults apply to
+	// those left empty, except SourceType, which defaults to DefaultSourceType
+	Index      string
+	Source     string
+	SourceType string
+	Host       string
+	// HTTPClient sends requests; http.DefaultClient is used when nil
+	HTTPClient *http.Client
+}
+
+// Event is the event sent for a log, with its ledger position
+type Event struct {
+	client.LogEvent
+	BlockNumber   uint64 `json:"blockNumber"`
+	TransactionID string `json:"transactionId"`
+}
+
+// Sink is a projection.Sink sending the logs on the ledger to a Splunk HTTP Event Collector,
+// timestamped with the time of the log. HEC does not deduplicate events, so the few events the
+// projector replays after a restart are indexed again; search on the id field when it matters.
+// Do not point it at a Server recording to the same ledger, which would record every log
+// again. Run it with a projection.Projector:
+//
+//	sink, err := splunk.NewSink(splunk.Config{URL: "https://splunk.example.com:8088", Token: token})
+//	projector := projection.New(projection.NewEventSource(c, nil), sink, projection.Options{Checkpointer: checkpointer})
+//	err = projector.Run(ctx)
+type Sink struct {
+	config Config
+	http   *http.Client
+}
+
+// NewSink returns a Sink sending to the configured collector
+func NewSink(config Config) (*Sink, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("no HEC URL configured")
+	}
+	if config.Token == "" {
+		return nil, fmt.Errorf("no HEC token configured")
+	}
+	if config.SourceType == "" {
+		config.SourceType = DefaultSourceType
+	}
+
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Sink{config: config, http: httpClient}, nil
+}
+
+// Write sends the logs carried by events with one request. Pruned logs are not sent: HEC cannot
+// delete events, and Splunk applies its own retention to the index.
+func (s *Sink) Write(ctx context.Context, events []client.ContractEvent) error {
+	var body bytes.Buffer
+	for _, event := range events {
+		if event.Pruned() {
+			continue
+		}
+		payload := map[string]interface{}{
+			"sourcetype": s.config.SourceType,
+			"event":      Event{LogEvent: event.Log, BlockNumber: event.BlockNumber, TransactionID: event.TransactionID},
+		}
+		if t, err := time.Parse(time.RFC3339Nano, event.Log.Timestamp); err == nil {
+			payload["time"] = json.Number(fmt.Sprintf("%d.%03d", t.Unix(), t.Nanosecond()/int(time.Millisecond)))
+		}
+		for key, value := range map[string]string{"index": s.config.Index, "source": s.config.Source, "host": s.config.Host} {
+			if value != "" {
+				payload[key] = value
+			}
+		}
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body.Write(data)
+		body.WriteByte('\n')
+	}
+	if body.Len() == 0 {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(s.config.URL, "/")+EventPath, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Splunk "+s.config.Token)
+
+	// Failed requests are retried by the Projector, which sends the whole batch again
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach HEC: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read HEC response: %v", err)
+	}
+	var answer response
+	if err := json.Unmarshal(respBody, &answer); err != nil || resp.StatusCode >= 300 || answer.Code != 0 {
+		return fmt.Errorf("failed to send logs to HEC: %s: %s", http.StatusText(resp.StatusCode), respBody)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package stats
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// metricsNamespace prefixes every metric exported by the exporter, as it does the client's
+const metricsNamespace = "fabric_logging"
+
+// metrics holds the Prometheus instruments of an Exporter
+type metrics struct {
+	logs                prometheus.Gauge
+	logsByAction        *prometheus.GaugeVec
+	alerts              prometheus.Gauge
+	newestLog           prometheus.Gauge
+	lastCommitBlock     prometheus.Gauge
+	lastCommitTimestamp prometheus.Gauge
+	scans               *prometheus.CounterVec
+	scanDuration        prometheus.Gauge
+}
+
+func newMetrics(registerer prometheus.Registerer) (*metrics, error) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	m := &metrics{
+		logs: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "ledger",
+			Name:      "logs_total",
+			Help:      "Logs in the world state at the last scan.",
+		}),
+		logsByAction: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "ledger",
+			Name:      "logs_by_action",
+			Help:      "Logs in the world state at the last scan, by action; the least frequent actions are counted as other.",
+		}, []string{"action"}),
+		alerts: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "ledger",
+			Name:      "alerts_total",
+			Help:      "Logs in the world state at or above the alert severity at the last scan.",
+		}),
+		newestLog: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "ledger",
+			Name:      "newest_log_timestamp_seconds",
+			Help:      "Unix time recorded in the newest log of the world state.",
+		}),
+		lastCommitBlock: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "ledger",
+			Name:      "last_commit_block",
+			Help:      "Number of the block of the latest transaction writing logs.",
+		}),
+		lastCommitTimestamp: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "ledger",
+			Name:      "last_commit_timestamp_seconds",
+			Help:      "Unix time the exporter saw the latest transaction writing logs commit.",
+		}),
+		scans: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "ledger",
+			Name:      "scans_total",
+			Help:      "Scans of the world state, by result: succeeded or failed.",
+		}, []string{"result"}),
+		scanDuration: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "ledger",
+			Name:      "scan_duration_seconds",
+			Help:      "Duration of the last scan of the world state.",
+		}),
+	}
+
+	for _, collector := range []prometheus.Collector{m.logs, m.logsByAction, m.alerts, m.newestLog, m.lastCommitBlock, m.lastCommitTimestamp, m.scans, m.scanDuration} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, fmt.Errorf("failed to register ledger metrics: %v", err)
+		}
+	}
+
+	return m, nil
+}
+
+// observeScan records a scan's statistics, which are nil when err is set
+func (m *metrics) observeScan(stats *Stats, err error, duration time.Duration, maxActions int) {
+	m.scanDuration.Set(duration.Seconds())
+	if err != nil {
+		m.scans.WithLabelValues("failed").Inc()
+		return
+	}
+	m.scans.WithLabelValues("succeeded").Inc()
+
+	m.logs.Set(float64(stats.Logs))
+	// Actions whose logs were all pruned disappear rather than report 0 forever
+	m.logsByAction.Reset()
+	for action, count := range topActions(stats.ByAction, maxActions) {
+		m.logsByAction.WithLabelValues(action).Set(float64(count))
+	}
+	m.alerts.Set(float64(stats.Alerts))
+	if !stats.Newest.IsZero() {
+		m.newestLog.Set(float64(stats.Newest.UnixNano()) / 1e9)
+	}
+}
+
+// observeCommit records the commit of a transaction writing event's log
+func (m *metrics) observeCommit(event client.ContractEvent) {
+	m.lastCommitBlock.Set(float64(event.BlockNumber))
+	m.lastCommitTimestamp.Set(float64(time.Now().Unix()))
+}
@@ -0,0 +1,215 @@
+// Package stats exports statistics of the logs on the ledger as Prometheus gauges: how many logs
+// the world state holds, per action and at alerting severity, and the block and time of the
+// latest commit, so alerting rules can watch the ledger itself, such as firing when no log has
+// been committed for ten minutes.
+package stats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// Exporter defaults
+const (
+	DefaultInterval = time.Minute
+	DefaultPageSize = 1000
+	// DefaultAlertSeverity is the least level counted as an alert, that of alert routes
+	DefaultAlertSeverity = "ERROR"
+	DefaultMaxActions    = 100
+)
+
+// OtherAction labels the logs of the actions beyond MaxActions
+const OtherAction = "other"
+
+// Options configures an Exporter
+type Options struct {
+	// Interval is the time between scans of the ledger; DefaultInterval is used when zero
+	Interval time.Duration
+	// PageSize is the number of logs read per query page; DefaultPageSize is used when zero
+	PageSize int32
+	// AlertSeverity is the least level of the logs counted as alerts; DefaultAlertSeverity is
+	// used when empty. Logs without a level are never counted.
+	AlertSeverity string
+	// MaxActions bounds the actions labelling logs_by_action, the most frequent first; the logs
+	// of the others are counted under OtherAction. DefaultMaxActions is used when zero.
+	MaxActions int
+	// Checkpointer, when set, keeps the position of the latest commit, so a restarted exporter
+	// reports last_commit_block before the next commit
+	Checkpointer client.Checkpointer
+	// Registerer receives the exporter's metrics; prometheus.DefaultRegisterer is used when nil
+	Registerer prometheus.Registerer
+	// OnError is called with the failures of scans, which are retried at the next interval
+	OnError func(error)
+}
+
+// Stats are the statistics of one scan of the ledger
+type Stats struct {
+	// Logs is the number of logs in the world state, and ByAction their number per action
+	Logs     int
+	ByAction map[string]int
+	// Alerts is the number of logs at or above the alert severity
+	Alerts int
+	// Newest is the timestamp of the newest log, zero when there is none
+	Newest time.Time
+}
+
+// Exporter scans the logs of the ledger at every interval and follows its commits, exposing the
+// results as gauges. The chaincode has no aggregate queries, so every scan reads each log; set
+// the interval with the ledger's size in mind.
+type Exporter struct {
+	client  *client.Client
+	options Options
+	level   slog.Level
+	metrics *metrics
+
+	mu sync.Mutex
+}
+
+// NewExporter returns an Exporter reading the ledger through c, and registers its metrics
+func NewExporter(c *client.Client, options Options) (*Exporter, error) {
+	if options.Interval <= 0 {
+		options.Interval = DefaultInterval
+	}
+	if options.PageSize <= 0 {
+		options.PageSize = DefaultPageSize
+	}
+	if options.AlertSeverity == "" {
+		options.AlertSeverity = DefaultAlertSeverity
+	}
+	level, ok := client.ParseSeverity(options.AlertSeverity)
+	if !ok {
+		return nil, fmt.Errorf("unknown severity %q", options.AlertSeverity)
+	}
+	if options.MaxActions <= 0 {
+		options.MaxActions = DefaultMaxActions
+	}
+
+	m, err := newMetrics(options.Registerer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Exporter{client: c, options: options, level: level, metrics: m}, nil
+}
+
+// Run scans the ledger now and at every interval, and follows its commits, until ctx is done,
+// returning ctx's error, or until following the commits fails
+func (e *Exporter) Run(ctx context.Context) error {
+	if e.options.Checkpointer != nil {
+		checkpoint, err := e.options.Checkpointer.Load()
+		if err != nil {
+			return err
+		}
+		if checkpoint != nil {
+			e.metrics.lastCommitBlock.Set(float64(checkpoint.BlockNumber))
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var opts []client.ListenOption
+	if e.options.Checkpointer != nil {
+		opts = append(opts, client.WithCheckpointer(e.options.Checkpointer))
+	}
+	listener := e.client.NewEventListener(func(event client.ContractEvent) error {
+		e.metrics.observeCommit(event)
+		return nil
+	}, opts...)
+	listened := make(chan error, 1)
+	go func() {
+		listened <- listener.Run(ctx)
+	}()
+
+	ticker := time.NewTicker(e.options.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := e.Scan(ctx); err != nil && ctx.Err() == nil && e.options.OnError != nil {
+			e.options.OnError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-listened:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("failed to follow commits: %v", err)
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan reads every log of the world state and updates the gauges with their statistics. The
+// gauges keep their previous values when the scan fails.
+func (e *Exporter) Scan(ctx context.Context) (*Stats, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	start := time.Now()
+	stats, err := e.scan(ctx)
+	e.metrics.observeScan(stats, err, time.Since(start), e.options.MaxActions)
+	return stats, err
+}
+
+func (e *Exporter) scan(ctx context.Context) (*Stats, error) {
+	stats := &Stats{ByAction: map[string]int{}}
+	it := e.client.IterateAllLogs(e.options.PageSize)
+	for {
+		log, err := it.Next(ctx)
+		if errors.Is(err, client.ErrIteratorDone) {
+			return stats, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read logs: %w", err)
+		}
+
+		stats.Logs++
+		stats.ByAction[log.Action]++
+		if level, ok := client.LogSeverity(*log); ok && level >= e.level {
+			stats.Alerts++
+		}
+		if t, err := time.Parse(time.RFC3339Nano, log.Timestamp); err == nil && t.After(stats.Newest) {
+			stats.Newest = t
+		}
+	}
+}
+
+// topActions returns the actions of byAction with their counts, at most max of them by count
+// and the rest summed under OtherAction
+func topActions(byAction map[string]int, max int) map[string]int {
+	if len(byAction) <= max {
+		return byAction
+	}
+
+	actions := make([]string, 0, len(byAction))
+	for action := range byAction {
+		actions = append(actions, action)
+	}
+	sort.Slice(actions, func(i, j int) bool {
+		if byAction[actions[i]] != byAction[actions[j]] {
+			return byAction[actions[i]] > byAction[actions[j]]
+		}
+		return actions[i] < actions[j]
+	})
+
+	top := make(map[string]int, max)
+	for i, action := range actions {
+		if i < max-1 {
+			top[action] = byAction[action]
+		} else {
+			top[OtherAction] += byAction[action]
+		}
+	}
+	return top
+}
@@ -0,0 +1,52 @@
+package syslog
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricsNamespace prefixes every metric exported by the server, as it does the client's
+const metricsNamespace = "fabric_logging"
+
+// metrics holds the Prometheus instruments of a Server
+type metrics struct {
+	messages    *prometheus.CounterVec
+	parseErrors *prometheus.CounterVec
+	connections *prometheus.GaugeVec
+}
+
+func newMetrics(registerer prometheus.Registerer) (*metrics, error) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	m := &metrics{
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "syslog",
+			Name:      "messages_total",
+			Help:      "Syslog messages received, by transport and result: submitted, dropped or failed.",
+		}, []string{"transport", "result"}),
+		parseErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "syslog",
+			Name:      "parse_errors_total",
+			Help:      "Syslog messages whose header could not be read, submitted whole, by transport.",
+		}, []string{"transport"}),
+		connections: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "syslog",
+			Name:      "connections",
+			Help:      "Open TCP and TLS connections of syslog senders, by transport.",
+		}, []string{"transport"}),
+	}
+
+	for _, collector := range []prometheus.Collector{m.messages, m.parseErrors, m.connections} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, fmt.Errorf("failed to register syslog metrics: %v", err)
+		}
+	}
+
+	return m, nil
+}
@@ -0,0 +1,291 @@
+package syslog
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Facility and severity of messages without a priority, user.notice as RFC 3164 assigns them
+const (
+	DefaultFacility = 1
+	DefaultSeverity = 5
+)
+
+// facilityNames are the keywords of the facility codes
+var facilityNames = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// severityNames are the keywords of the severity codes, from emerg to debug
+var severityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// FacilityName returns the keyword of a facility code, such as "auth" or "local0"
+func FacilityName(facility int) string {
+	if facility < 0 || facility >= len(facilityNames) {
+		return strconv.Itoa(facility)
+	}
+	return facilityNames[facility]
+}
+
+// SeverityName returns the keyword of a severity code, such as "err" or "warning"
+func SeverityName(severity int) string {
+	if severity < 0 || severity >= len(severityNames) {
+		return strconv.Itoa(severity)
+	}
+	return severityNames[severity]
+}
+
+// Message is a parsed syslog message. Fields the message leaves out, or gives as the RFC 5424
+// nil value "-", are empty.
+type Message struct {
+	Facility int
+	Severity int
+	// Timestamp is zero when the message has none
+	Timestamp time.Time
+	Hostname  string
+	// AppName is the APP-NAME of RFC 5424, or the TAG of RFC 3164
+	AppName string
+	ProcID  string
+	MsgID   string
+	// StructuredData holds the parameters of each SD-ELEMENT by SD-ID
+	StructuredData map[string]map[string]string
+	Message        string
+	// RFC5424 reports whether the message was in the format of RFC 5424 rather than RFC 3164
+	RFC5424 bool
+}
+
+// Parse reads a message in the format of RFC 5424 or, failing that, RFC 3164. RFC 3164
+// timestamps carry neither year nor time zone; they are read in the location of received, the
+// time the message arrived, in the year that puts them closest to it. RFC 3164 allows any
+// content, so only malformed RFC 5424 headers are errors; messages without a priority are
+// user.notice.
+func Parse(data []byte, received time.Time) (*Message, error) {
+	facility, severity, rest, ok := parsePriority(data)
+	if !ok {
+		facility, severity, rest = DefaultFacility, DefaultSeverity, data
+	}
+	message := &Message{Facility: facility, Severity: severity}
+
+	if version, ok := parseVersion(rest); ok {
+		if version != 1 {
+			return nil, fmt.Errorf("unsupported syslog version %d", version)
+		}
+		if err := message.parse5424(rest[2:]); err != nil {
+			return nil, err
+		}
+		return message, nil
+	}
+
+	message.parse3164(rest, received)
+	return message, nil
+}
+
+// parsePriority reads the <PRI> starting data
+func parsePriority(data []byte) (facility int, severity int, rest []byte, ok bool) {
+	if len(data) < 3 || data[0] != '<' {
+		return 0, 0, nil, false
+	}
+	end := bytes.IndexByte(data[:min(len(data), 5)], '>')
+	if end < 2 {
+		return 0, 0, nil, false
+	}
+	pri, err := strconv.Atoi(string(data[1:end]))
+	if err != nil || pri < 0 || pri > 191 || (end > 2 && data[1] == '0') {
+		return 0, 0, nil, false
+	}
+	return pri / 8, pri % 8, data[end+1:], true
+}
+
+// parseVersion reads the "1 " following the priority of an RFC 5424 message
+func parseVersion(data []byte) (int, bool) {
+	if len(data) < 2 || data[0] < '1' || data[0] > '9' || data[1] != ' ' {
+		return 0, false
+	}
+	return int(data[0] - '0'), true
+}
+
+// parse5424 reads the header fields, structured data and message following the version
+func (m *Message) parse5424(data []byte) error {
+	m.RFC5424 = true
+	s := string(data)
+
+	var header [5]string
+	for i := range header {
+		end := strings.IndexByte(s, ' ')
+		if end < 0 {
+			return fmt.Errorf("truncated RFC 5424 header")
+		}
+		header[i], s = s[:end], s[end+1:]
+		if header[i] == "" {
+			return fmt.Errorf("empty field in RFC 5424 header")
+		}
+		if header[i] == "-" {
+			header[i] = ""
+		}
+	}
+
+	if header[0] != "" {
+		t, err := time.Parse(time.RFC3339Nano, header[0])
+		if err != nil {
+			return fmt.Errorf("invalid RFC 5424 timestamp %q", header[0])
+		}
+		m.Timestamp = t
+	}
+	m.Hostname, m.AppName, m.ProcID, m.MsgID = header[1], header[2], header[3], header[4]
+
+	rest, err := m.parseStructuredData(s)
+	if err != nil {
+		return err
+	}
+	if rest != "" {
+		if rest[0] != ' ' {
+			return fmt.Errorf("no space between structured data and message")
+		}
+		rest = strings.TrimPrefix(rest[1:], "\ufeff")
+	}
+	m.Message = strings.TrimRight(rest, "\r\n")
+	return nil
+}
+
+// parseStructuredData reads the nil value or the SD-ELEMENTs starting s and returns what follows
+func (m *Message) parseStructuredData(s string) (string, error) {
+	if strings.HasPrefix(s, "-") {
+		return s[1:], nil
+	}
+	if !strings.HasPrefix(s, "[") {
+		return "", fmt.Errorf("invalid RFC 5424 structured data")
+	}
+
+	m.StructuredData = make(map[string]map[string]string)
+	for strings.HasPrefix(s, "[") {
+		end := strings.IndexAny(s, " ]")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated structured data element")
+		}
+		id := s[1:end]
+		if id == "" {
+			return "", fmt.Errorf("structured data element without an ID")
+		}
+		params := make(map[string]string)
+		m.StructuredData[id] = params
+		s = s[end:]
+
+		for strings.HasPrefix(s, " ") {
+			s = s[1:]
+			eq := strings.Index(s, "=\"")
+			if eq <= 0 {
+				return "", fmt.Errorf("invalid parameter in structured data element %s", id)
+			}
+			name := s[:eq]
+			value, rest, err := parseParamValue(s[eq+2:])
+			if err != nil {
+				return "", fmt.Errorf("invalid parameter %s in structured data element %s: %v", name, id, err)
+			}
+			params[name] = value
+			s = rest
+		}
+		if !strings.HasPrefix(s, "]") {
+			return "", fmt.Errorf("unterminated structured data element %s", id)
+		}
+		s = s[1:]
+	}
+	return s, nil
+}
+
+// parseParamValue reads a PARAM-VALUE up to its closing quote, undoing the escapes of '"', '\'
+// and ']'
+func parseParamValue(s string) (string, string, error) {
+	var value strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return value.String(), s[i+1:], nil
+		case '\\':
+			if i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\' || s[i+1] == ']') {
+				i++
+			}
+		}
+		value.WriteByte(s[i])
+	}
+	return "", "", fmt.Errorf("unterminated value")
+}
+
+// parse3164 reads the optional timestamp, hostname and tag of a BSD syslog message. Senders
+// differ in what they leave out, so whatever cannot be read is left in the message.
+func (m *Message) parse3164(data []byte, received time.Time) {
+	s := strings.TrimRight(string(data), "\r\n\x00")
+
+	if t, rest, ok := parseTimestamp3164(s, received); ok {
+		m.Timestamp = t
+		s = rest
+
+		// The hostname is the next word, unless the sender left it out and the word is the tag
+		if end := strings.IndexByte(s, ' '); end > 0 && !strings.ContainsAny(s[:end], ":[") {
+			m.Hostname, s = s[:end], s[end+1:]
+		}
+	}
+
+	if tag, pid, rest, ok := parseTag(s); ok {
+		m.AppName, m.ProcID, s = tag, pid, rest
+	}
+	m.Message = s
+}
+
+// parseTimestamp3164 reads a "Jan _2 15:04:05" timestamp and the space after it, or an RFC 3339
+// one as some senders use in the legacy format
+func parseTimestamp3164(s string, received time.Time) (time.Time, string, bool) {
+	if len(s) > len(time.Stamp) && s[len(time.Stamp)] == ' ' {
+		if t, err := time.ParseInLocation(time.Stamp, s[:len(time.Stamp)], received.Location()); err == nil {
+			return inferYear(t, received), s[len(time.Stamp)+1:], true
+		}
+	}
+	if end := strings.IndexByte(s, ' '); end > 0 {
+		if t, err := time.Parse(time.RFC3339Nano, s[:end]); err == nil {
+			return t, s[end+1:], true
+		}
+	}
+	return time.Time{}, s, false
+}
+
+// inferYear dates t, read without a year, in the year that puts it closest to received
+func inferYear(t time.Time, received time.Time) time.Time {
+	t = t.AddDate(received.Year()-t.Year(), 0, 0)
+	switch {
+	case t.After(received.AddDate(0, 1, 0)):
+		return t.AddDate(-1, 0, 0)
+	case t.Before(received.AddDate(0, -11, 0)):
+		return t.AddDate(1, 0, 0)
+	}
+	return t
+}
+
+// maxTagLength bounds the tag read, so a message that merely contains a colon keeps its text
+const maxTagLength = 48
+
+// parseTag reads a "tag[pid]: " or "tag: " prefix
+func parseTag(s string) (tag string, pid string, rest string, ok bool) {
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./", r))
+	})
+	if end <= 0 || end > maxTagLength {
+		return "", "", s, false
+	}
+	tag, rest = s[:end], s[end:]
+
+	if strings.HasPrefix(rest, "[") {
+		closing := strings.IndexByte(rest, ']')
+		if closing < 0 {
+			return "", "", s, false
+		}
+		pid, rest = rest[1:closing], rest[closing+1:]
+	}
+	if !strings.HasPrefix(rest, ":") {
+		return "", "", s, false
+	}
+	return tag, pid, strings.TrimPrefix(rest[1:], " "), true
+}
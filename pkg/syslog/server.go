@@ -0,0 +1,357 @@
+// Package syslog receives syslog messages, in the formats of RFC 5424 and RFC 3164, over UDP, TCP
+// and TLS, and submits them as logs, so network devices and systems that only speak syslog feed
+// the ledger without changes. TCP and TLS streams may frame messages by octet counting, as RFC 5425
+// requires, or end them with line breaks, as most BSD senders do (RFC 6587).
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultMaxMessageSize is the largest message read from a TCP or TLS stream by default
+const DefaultMaxMessageSize = 64 * 1024
+
+// backpressureWait is how long a stream waits before retrying a message the submitter pushed back
+const backpressureWait = 100 * time.Millisecond
+
+// Transports, as they label metrics and the transport metadata of logs
+const (
+	TransportUDP = "udp"
+	TransportTCP = "tcp"
+	TransportTLS = "tls"
+)
+
+// severityLevels places the syslog severities, from emerg to debug, on the slog scale, so
+// queries and alerts on the level metadata treat syslog messages like any other log
+var severityLevels = []slog.Level{
+	slog.LevelError + 4,
+	slog.LevelError + 3,
+	slog.LevelError + 2,
+	slog.LevelError,
+	slog.LevelWarn,
+	slog.LevelInfo + 2,
+	slog.LevelInfo,
+	slog.LevelDebug,
+}
+
+// Options configures a Server
+type Options struct {
+	// Submitter receives the logs, usually a BatchingSubmitter
+	Submitter client.Submitter
+	// UserID is recorded as the user of every message. The message's app name is used when
+	// empty, or "syslog" when it has none.
+	UserID string
+	// Action is recorded as the action of every message. The message's MSGID is used when
+	// empty, or SYSLOG_<SEVERITY> when it has none, such as SYSLOG_ERR.
+	Action string
+	// Location is the time zone of RFC 3164 timestamps, which carry none; time.Local is used when nil
+	Location *time.Location
+	// MaxMessageSize is the largest message read from a stream; DefaultMaxMessageSize is used
+	// when zero. Longer messages are skipped.
+	MaxMessageSize int
+	// Registerer receives the fabric_logging_syslog_* metrics; prometheus.DefaultRegisterer is used when nil
+	Registerer prometheus.Registerer
+	// OnError is called with the messages the Submitter rejected and the connections that failed
+	OnError func(error)
+}
+
+// Server receives syslog messages and submits them as logs. A message's text becomes the
+// description and its hostname, or the sender's address, the resource. Its facility,
+// severity, app name, process ID, message ID, structured data, sender and transport are
+// recorded in the metadata, with the level its severity maps to and its timestamp. Messages
+// whose header cannot be read are submitted whole, with a parseError metadata field.
+type Server struct {
+	options Options
+	handler *client.SlogHandler
+	metrics *metrics
+}
+
+// NewServer returns a Server submitting through options.Submitter
+func NewServer(options Options) (*Server, error) {
+	if options.Submitter == nil {
+		return nil, fmt.Errorf("no submitter configured")
+	}
+	if options.Location == nil {
+		options.Location = time.Local
+	}
+	if options.MaxMessageSize <= 0 {
+		options.MaxMessageSize = DefaultMaxMessageSize
+	}
+
+	m, err := newMetrics(options.Registerer)
+	if err != nil {
+		return nil, err
+	}
+	handler := client.NewSlogHandler(options.Submitter, client.SlogHandlerOptions{Level: slog.LevelDebug})
+	return &Server{options: options, handler: handler, metrics: m}, nil
+}
+
+// ServeUDP receives one message per datagram on conn until ctx is done, then closes conn. Messages
+// the submitter pushes back with client.ErrBackpressure are dropped, as UDP senders cannot be
+// held back, and only counted.
+func (s *Server) ServeUDP(ctx context.Context, conn net.PacketConn) error {
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+		if n > 0 {
+			s.handle(ctx, buf[:n], addr, TransportUDP, false)
+		}
+	}
+}
+
+// ServeTCP accepts connections on listener until ctx is done, then closes listener and the
+// connections. While the submitter pushes back, a connection is not read from, so TCP flow
+// control holds its sender back.
+func (s *Server) ServeTCP(ctx context.Context, listener net.Listener) error {
+	return s.serve(ctx, listener, TransportTCP)
+}
+
+// ServeTLS serves listener as ServeTCP does, over TLS with config. Setting config.ClientAuth
+// and config.ClientCAs restricts the senders to those with certificates.
+func (s *Server) ServeTLS(ctx context.Context, listener net.Listener, config *tls.Config) error {
+	return s.serve(ctx, tls.NewListener(listener, config), TransportTLS)
+}
+
+func (s *Server) serve(ctx context.Context, listener net.Listener, transport string) error {
+	stop := context.AfterFunc(ctx, func() { listener.Close() })
+	defer stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveConn(ctx, conn, transport)
+		}()
+	}
+}
+
+// serveConn reads the messages of one connection until it ends or ctx is done
+func (s *Server) serveConn(ctx context.Context, conn net.Conn, transport string) {
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	defer conn.Close()
+
+	s.metrics.connections.WithLabelValues(transport).Inc()
+	defer s.metrics.connections.WithLabelValues(transport).Dec()
+
+	reader := bufio.NewReaderSize(conn, s.options.MaxMessageSize)
+	for {
+		frame, err := s.readFrame(reader)
+		if errors.Is(err, errMessageTooLong) {
+			s.report(fmt.Errorf("skipped a message from %s: %w", conn.RemoteAddr(), err))
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				s.report(fmt.Errorf("failed to read from %s: %v", conn.RemoteAddr(), err))
+			}
+			return
+		}
+		if len(frame) > 0 {
+			s.handle(ctx, frame, conn.RemoteAddr(), transport, true)
+		}
+	}
+}
+
+// errMessageTooLong is returned for messages longer than MaxMessageSize
+var errMessageTooLong = errors.New("message too long")
+
+// readFrame reads the next message of a stream, framed by octet counting when it starts with
+// a digit and by a line break otherwise
+func (s *Server) readFrame(reader *bufio.Reader) ([]byte, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] >= '1' && first[0] <= '9' {
+		prefix, err := reader.ReadSlice(' ')
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		length, err := strconv.Atoi(string(prefix[:len(prefix)-1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid octet count %q", prefix)
+		}
+		if length > s.options.MaxMessageSize {
+			// The stream stays in step by skipping exactly the message
+			if _, err := reader.Discard(length); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: %d bytes", errMessageTooLong, length)
+		}
+		frame := make([]byte, length)
+		if _, err := io.ReadFull(reader, frame); err != nil {
+			return nil, err
+		}
+		return frame, nil
+	}
+
+	line, err := reader.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		for errors.Is(err, bufio.ErrBufferFull) {
+			_, err = reader.ReadSlice('\n')
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: a line exceeds %d bytes", errMessageTooLong, s.options.MaxMessageSize)
+	}
+	if err != nil && !(errors.Is(err, io.EOF) && len(line) > 0) {
+		return nil, err
+	}
+	// The reader's buffer is reused by the next read
+	return append([]byte(nil), bytes.TrimRight(line, "\r\n")...), nil
+}
+
+// handle submits a message, retrying while the submitter pushes back when wait is set
+func (s *Server) handle(ctx context.Context, data []byte, sender net.Addr, transport string, wait bool) {
+	record := s.record(data, sender, transport)
+	for {
+		err := s.handler.Handle(ctx, record)
+		switch {
+		case err == nil:
+			s.metrics.messages.WithLabelValues(transport, "submitted").Inc()
+		case errors.Is(err, client.ErrBackpressure) && wait:
+			select {
+			case <-ctx.Done():
+				s.metrics.messages.WithLabelValues(transport, "dropped").Inc()
+				return
+			case <-time.After(backpressureWait):
+			}
+			continue
+		case errors.Is(err, client.ErrBackpressure):
+			s.metrics.messages.WithLabelValues(transport, "dropped").Inc()
+		default:
+			s.metrics.messages.WithLabelValues(transport, "failed").Inc()
+			s.report(fmt.Errorf("failed to submit a message from %s: %w", sender, err))
+		}
+		return
+	}
+}
+
+// record builds the slog record of a message
+func (s *Server) record(data []byte, sender net.Addr, transport string) slog.Record {
+	received := time.Now().In(s.options.Location)
+	host := sender.String()
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	fields := map[string]interface{}{
+		"sender":    host,
+		"transport": transport,
+	}
+
+	message, err := Parse(data, received)
+	if err != nil {
+		s.metrics.parseErrors.WithLabelValues(transport).Inc()
+		fields["parseError"] = err.Error()
+		message = &Message{Facility: DefaultFacility, Severity: DefaultSeverity}
+		if facility, severity, _, ok := parsePriority(data); ok {
+			message.Facility, message.Severity = facility, severity
+		}
+		message.Message = strings.TrimRight(string(data), "\r\n\x00")
+	}
+
+	fields["facility"] = FacilityName(message.Facility)
+	fields["severity"] = SeverityName(message.Severity)
+	for key, value := range map[string]string{
+		"hostname": message.Hostname,
+		"appName":  message.AppName,
+		"procId":   message.ProcID,
+		"msgId":    message.MsgID,
+	} {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+	if len(message.StructuredData) > 0 {
+		fields["structuredData"] = message.StructuredData
+	}
+
+	fields[client.SlogUserIDKey] = s.options.UserID
+	if s.options.UserID == "" {
+		fields[client.SlogUserIDKey] = "syslog"
+		if message.AppName != "" {
+			fields[client.SlogUserIDKey] = message.AppName
+		}
+	}
+	fields[client.SlogActionKey] = s.options.Action
+	if s.options.Action == "" {
+		fields[client.SlogActionKey] = "SYSLOG_" + strings.ToUpper(SeverityName(message.Severity))
+		if message.MsgID != "" {
+			fields[client.SlogActionKey] = message.MsgID
+		}
+	}
+	fields[client.SlogResourceKey] = host
+	if message.Hostname != "" {
+		fields[client.SlogResourceKey] = message.Hostname
+	}
+
+	t := message.Timestamp
+	if t.IsZero() {
+		t = received
+	}
+	record := slog.NewRecord(t, severityLevels[message.Severity], client.Truncate(message.Message, client.MaxDescriptionLength), 0)
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		record.AddAttrs(slog.Any(key, fields[key]))
+	}
+	return record
+}
+
+func (s *Server) report(err error) {
+	if s.options.OnError != nil {
+		s.options.OnError(err)
+	}
+}
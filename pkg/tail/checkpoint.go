@@ -0,0 +1,64 @@
+package tail
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// headLength is the most bytes at the start of a file hashed to recognize it after a restart
+const headLength = 1024
+
+// position is how far a file has been read. Head is the SHA-256 of its first HeadLength bytes,
+// telling whether the file at the path is still the one the position belongs to.
+type position struct {
+	Offset     int64  `json:"offset"`
+	Head       string `json:"head"`
+	HeadLength int64  `json:"headLength"`
+}
+
+// readHead hashes the first n bytes of file
+func readHead(file *os.File, n int64) (string, error) {
+	head := make([]byte, n)
+	if _, err := file.ReadAt(head, 0); err != nil && err != io.EOF {
+		return "", err
+	}
+	sum := sha256.Sum256(head)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// loadPositions reads the checkpoint file at path, which may not exist yet
+func loadPositions(path string) (map[string]*position, error) {
+	positions := make(map[string]*position)
+	if path == "" {
+		return positions, nil
+	}
+
+	positionsJSON, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return positions, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %v", err)
+	}
+	if err := json.Unmarshal(positionsJSON, &positions); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %v", path, err)
+	}
+	return positions, nil
+}
+
+// savePositions replaces the checkpoint file atomically
+func savePositions(path string, positions map[string]position) error {
+	positionsJSON, err := json.MarshalIndent(positions, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path+".tmp", positionsJSON, 0600); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %v", err)
+	}
+	return os.Rename(path+".tmp", path)
+}
@@ -0,0 +1,100 @@
+package tail
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config is an agent configuration file, in YAML or JSON
+type Config struct {
+	// Checkpoint is the file positions are saved to
+	Checkpoint string        `json:"checkpoint,omitempty" yaml:"checkpoint,omitempty"`
+	Inputs     []InputConfig `json:"inputs" yaml:"inputs"`
+}
+
+// InputConfig is an Input in a Config
+type InputConfig struct {
+	Path string `json:"path" yaml:"path"`
+	// Format is text, json or regex; text is used when empty
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+	// Pattern is the regular expression of the regex format, with named groups
+	Pattern    string            `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	Fields     map[string]string `json:"fields,omitempty" yaml:"fields,omitempty"`
+	TimeFormat string            `json:"timeFormat,omitempty" yaml:"timeFormat,omitempty"`
+	Multiline  *struct {
+		Start    string `json:"start" yaml:"start"`
+		MaxLines int    `json:"maxLines,omitempty" yaml:"maxLines,omitempty"`
+		Timeout  string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	} `json:"multiline,omitempty" yaml:"multiline,omitempty"`
+	UserID   string `json:"userId,omitempty" yaml:"userId,omitempty"`
+	Action   string `json:"action,omitempty" yaml:"action,omitempty"`
+	Resource string `json:"resource,omitempty" yaml:"resource,omitempty"`
+}
+
+// LoadConfig reads the agent configuration file at path
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read agent configuration: %v", err)
+	}
+
+	var cfg Config
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse agent configuration %s: %v", path, err)
+	}
+	return &cfg, nil
+}
+
+// Options returns the agent options the configuration describes, leaving the submitter to
+// the caller
+func (cfg *Config) Options() (Options, error) {
+	options := Options{CheckpointPath: cfg.Checkpoint}
+	for _, ic := range cfg.Inputs {
+		in := Input{
+			Path:       ic.Path,
+			Fields:     ic.Fields,
+			TimeFormat: ic.TimeFormat,
+			UserID:     ic.UserID,
+			Action:     ic.Action,
+			Resource:   ic.Resource,
+		}
+
+		switch ic.Format {
+		case "", "text":
+			in.Parser = TextParser{}
+		case "json":
+			in.Parser = JSONParser{}
+		case "regex":
+			parser, err := NewRegexParser(ic.Pattern)
+			if err != nil {
+				return Options{}, fmt.Errorf("invalid input %s: %v", ic.Path, err)
+			}
+			in.Parser = parser
+		default:
+			return Options{}, fmt.Errorf("invalid input %s: unknown format %q, expected text, json or regex", ic.Path, ic.Format)
+		}
+		if ic.Pattern != "" && ic.Format != "regex" {
+			return Options{}, fmt.Errorf("invalid input %s: a pattern needs the regex format", ic.Path)
+		}
+
+		if m := ic.Multiline; m != nil {
+			start, err := regexp.Compile(m.Start)
+			if err != nil {
+				return Options{}, fmt.Errorf("invalid multiline start of input %s: %v", ic.Path, err)
+			}
+			in.Multiline = &Multiline{Start: start, MaxLines: m.MaxLines}
+			if m.Timeout != "" {
+				if in.Multiline.Timeout, err = time.ParseDuration(m.Timeout); err != nil {
+					return Options{}, fmt.Errorf("invalid multiline timeout of input %s: %v", ic.Path, err)
+				}
+			}
+		}
+
+		options.Inputs = append(options.Inputs, in)
+	}
+	return options, nil
+}
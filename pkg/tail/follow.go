@@ -0,0 +1,357 @@
+package tail
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// follower reads the entries of one input
+type follower struct {
+	agent *Agent
+	input *input
+	// resume is the checkpointed position of the input, if any
+	resume *position
+
+	// pending holds the lines of a multiline entry not yet submitted, which ends at pendingEnd
+	pending    []string
+	pendingEnd int64
+	pendingAt  time.Time
+
+	mu   sync.Mutex
+	file *os.File
+	// submitted is the offset in file after the last entry handed to the submitter
+	submitted int64
+}
+
+func (f *follower) run(ctx context.Context) error {
+	if f.input.Path == Stdin {
+		return f.followStdin(ctx)
+	}
+	return f.followFile(ctx)
+}
+
+// followFile reads the file to its end, then polls it for new lines. When the path is renamed
+// and a new file created in its place, the rest of the old file is read before the new one is
+// followed from its start; when the file shrinks, it was truncated and is read again from its
+// start.
+func (f *follower) followFile(ctx context.Context) error {
+	file, offset, err := f.open(ctx)
+	if err != nil || file == nil {
+		return err
+	}
+
+	reader := bufio.NewReader(file)
+	var partial strings.Builder
+	for {
+		chunk, err := reader.ReadString('\n')
+		offset += int64(len(chunk))
+		if err == nil {
+			partial.WriteString(chunk)
+			line := strings.TrimSuffix(strings.TrimSuffix(partial.String(), "\n"), "\r")
+			partial.Reset()
+			if err := f.line(ctx, line, offset); err != nil {
+				return err
+			}
+			continue
+		}
+		if !errors.Is(err, io.EOF) {
+			return err
+		}
+		// A line without its line break yet waits for the rest
+		partial.WriteString(chunk)
+
+		if err := f.idle(ctx); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(f.agent.options.PollInterval):
+		}
+
+		current, err := os.Stat(f.input.Path)
+		if err != nil {
+			// Renamed, with no new file yet: the old one may still be written to
+			continue
+		}
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case !os.SameFile(info, current):
+			// Rotated: finish the old file, then start on the new one
+			if err := f.drain(ctx, reader, &partial, &offset); err != nil {
+				return err
+			}
+			next, err := os.Open(f.input.Path)
+			if err != nil {
+				continue
+			}
+			f.mu.Lock()
+			f.file.Close()
+			f.file, f.submitted = next, 0
+			f.mu.Unlock()
+			file, offset = next, 0
+			reader.Reset(file)
+		case current.Size() < offset:
+			// Truncated in place
+			if err := f.flush(ctx); err != nil {
+				return err
+			}
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			f.mu.Lock()
+			f.submitted = 0
+			f.mu.Unlock()
+			offset = 0
+			partial.Reset()
+			reader.Reset(file)
+		}
+	}
+}
+
+// open opens the file, waiting for it to be created, and seeks to the position to read from
+func (f *follower) open(ctx context.Context) (*os.File, int64, error) {
+	for {
+		file, err := os.Open(f.input.Path)
+		if err == nil {
+			offset, err := f.startOffset(file)
+			if err != nil {
+				file.Close()
+				return nil, 0, err
+			}
+			if _, err := file.Seek(offset, io.SeekStart); err != nil {
+				file.Close()
+				return nil, 0, err
+			}
+
+			f.mu.Lock()
+			f.file, f.submitted = file, offset
+			f.mu.Unlock()
+			return file, offset, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, 0, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, 0, nil
+		case <-time.After(f.agent.options.PollInterval):
+		}
+	}
+}
+
+// startOffset returns the checkpointed offset when it belongs to this file, and otherwise
+// the start of the file, or its end with FromEnd
+func (f *follower) startOffset(file *os.File) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	if f.resume != nil && f.resume.Offset <= info.Size() {
+		head, err := readHead(file, f.resume.HeadLength)
+		if err != nil {
+			return 0, err
+		}
+		if head == f.resume.Head {
+			return f.resume.Offset, nil
+		}
+		// Another file took the path while the agent was stopped, so it is read in full
+		return 0, nil
+	}
+	if f.resume == nil && f.agent.options.FromEnd {
+		return info.Size(), nil
+	}
+	return 0, nil
+}
+
+// drain reads the rest of a rotated file, including a last line without a line break
+func (f *follower) drain(ctx context.Context, reader *bufio.Reader, partial *strings.Builder, offset *int64) error {
+	for {
+		chunk, err := reader.ReadString('\n')
+		*offset += int64(len(chunk))
+		partial.WriteString(chunk)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		if partial.Len() > 0 {
+			line := strings.TrimSuffix(strings.TrimSuffix(partial.String(), "\n"), "\r")
+			partial.Reset()
+			if err := f.line(ctx, line, *offset); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			return f.flush(ctx)
+		}
+	}
+}
+
+// followStdin reads standard input to its end
+func (f *follower) followStdin(ctx context.Context) error {
+	type read struct {
+		line string
+		err  error
+	}
+	lines := make(chan read)
+	go func() {
+		reader := bufio.NewReader(os.Stdin)
+		for {
+			line, err := reader.ReadString('\n')
+			if line != "" || err != nil {
+				select {
+				case lines <- read{strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(f.agent.options.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := f.idle(ctx); err != nil {
+				return err
+			}
+		case r := <-lines:
+			if r.line != "" || r.err == nil {
+				if err := f.line(ctx, r.line, 0); err != nil {
+					return err
+				}
+			}
+			if errors.Is(r.err, io.EOF) {
+				return f.flush(ctx)
+			}
+			if r.err != nil {
+				return r.err
+			}
+		}
+	}
+}
+
+// line takes the next line, which ends at end in the file
+func (f *follower) line(ctx context.Context, line string, end int64) error {
+	m := f.input.Multiline
+	if m == nil {
+		return f.submit(ctx, line, end)
+	}
+
+	if len(f.pending) > 0 && (m.Start.MatchString(line) || len(f.pending) >= m.MaxLines) {
+		if err := f.flush(ctx); err != nil {
+			return err
+		}
+	}
+	f.pending = append(f.pending, line)
+	f.pendingEnd = end
+	f.pendingAt = time.Now()
+	return nil
+}
+
+// idle submits a multiline entry that has waited its timeout for more lines
+func (f *follower) idle(ctx context.Context) error {
+	if len(f.pending) > 0 && time.Since(f.pendingAt) >= f.input.Multiline.Timeout {
+		return f.flush(ctx)
+	}
+	return nil
+}
+
+// flush submits the pending multiline entry, if any
+func (f *follower) flush(ctx context.Context) error {
+	if len(f.pending) == 0 {
+		return nil
+	}
+	entry := strings.Join(f.pending, "\n")
+	f.pending = nil
+	return f.submit(ctx, entry, f.pendingEnd)
+}
+
+// submit parses an entry and hands its log to the submitter, waiting while the submitter
+// pushes back. Logs the submitter rejects are reported to OnError and skipped; an error is
+// only returned when ctx is done.
+func (f *follower) submit(ctx context.Context, entry string, end int64) error {
+	if strings.TrimSpace(entry) == "" {
+		f.advance(end)
+		return nil
+	}
+
+	record := f.input.record(entry)
+	for {
+		err := f.input.handler.Handle(ctx, record)
+		if errors.Is(err, client.ErrBackpressure) {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(f.agent.options.PollInterval):
+			}
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			f.agent.report(fmt.Errorf("failed to submit entry of %s ending at offset %d: %w", f.input.Path, end, err))
+		}
+		f.advance(end)
+		return nil
+	}
+}
+
+// close closes the file, once its position has been checkpointed
+func (f *follower) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+}
+
+func (f *follower) advance(end int64) {
+	f.mu.Lock()
+	f.submitted = end
+	f.mu.Unlock()
+}
+
+// position returns the position to checkpoint, or false for standard input and files not
+// opened yet
+func (f *follower) position() (position, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return position{}, false
+	}
+	length := f.submitted
+	if length > headLength {
+		length = headLength
+	}
+	head, err := readHead(f.file, length)
+	if err != nil {
+		return position{}, false
+	}
+	return position{Offset: f.submitted, Head: head, HeadLength: length}, true
+}
@@ -0,0 +1,154 @@
+package tail
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// Field names a Parser's output is read with. The id, userId, action and resource fields fill
+// the log's fields, message becomes its description, level and time are recorded as the
+// metadata a SlogHandler records, and every other field is kept in the metadata.
+const (
+	MessageField = "message"
+	LevelField   = "level"
+	TimeField    = "time"
+)
+
+// Parser turns an entry, one line or the lines merged by Multiline, into fields
+type Parser interface {
+	Parse(entry string) (map[string]interface{}, error)
+}
+
+// TextParser keeps the whole entry as the message
+type TextParser struct{}
+
+// Parse returns the entry as the message field
+func (TextParser) Parse(entry string) (map[string]interface{}, error) {
+	return map[string]interface{}{MessageField: entry}, nil
+}
+
+// JSONParser reads entries holding one JSON object each, such as the output of structured loggers
+type JSONParser struct{}
+
+// Parse decodes the object, keeping numbers as written
+func (JSONParser) Parse(entry string) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(entry))
+	decoder.UseNumber()
+
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, fmt.Errorf("not a JSON object: %v", err)
+	}
+	if fields == nil || decoder.More() {
+		return nil, fmt.Errorf("not a single JSON object")
+	}
+	return fields, nil
+}
+
+// RegexParser reads the named groups of a regular expression as fields
+type RegexParser struct {
+	pattern *regexp.Regexp
+}
+
+// NewRegexParser compiles pattern, which must have named groups such as (?P<level>\w+). The
+// pattern matches the whole of multiline entries, with . matching line breaks, so a group such
+// as (?P<message>.*) ending it takes a stack trace along.
+func NewRegexParser(pattern string) (*RegexParser, error) {
+	re, err := regexp.Compile("(?s)" + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %v", err)
+	}
+
+	named := false
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			named = true
+		}
+	}
+	if !named {
+		return nil, fmt.Errorf("the pattern %q has no named groups", pattern)
+	}
+
+	return &RegexParser{pattern: re}, nil
+}
+
+// Parse matches the entry and returns its named groups that matched
+func (p *RegexParser) Parse(entry string) (map[string]interface{}, error) {
+	match := p.pattern.FindStringSubmatchIndex(entry)
+	if match == nil {
+		return nil, fmt.Errorf("does not match the pattern")
+	}
+
+	fields := make(map[string]interface{})
+	for i, name := range p.pattern.SubexpNames() {
+		if name != "" && match[2*i] >= 0 {
+			fields[name] = entry[match[2*i]:match[2*i+1]]
+		}
+	}
+	return fields, nil
+}
+
+// record builds the slog record of an entry. Entries the parser rejects are shipped with the
+// whole entry as the message and the parser's error in the parseError field, so no line is
+// lost to a pattern that does not fit.
+func (in *input) record(entry string) slog.Record {
+	fields, err := in.Parser.Parse(entry)
+	if err != nil {
+		fields = map[string]interface{}{MessageField: entry, "parseError": err.Error()}
+	}
+	for from, to := range in.Fields {
+		if value, ok := fields[from]; ok {
+			delete(fields, from)
+			fields[to] = value
+		}
+	}
+
+	t := time.Now()
+	if value, ok := fields[TimeField].(string); ok {
+		if parsed, err := time.Parse(in.TimeFormat, value); err == nil {
+			t = parsed
+			delete(fields, TimeField)
+		}
+	}
+
+	level := slog.LevelInfo
+	if value, ok := fields[LevelField]; ok {
+		if parsed, ok := client.ParseSeverity(fmt.Sprint(value)); ok {
+			level = parsed
+			delete(fields, LevelField)
+		}
+	}
+
+	message := entry
+	if value, ok := fields[MessageField]; ok {
+		message = fmt.Sprint(value)
+		delete(fields, MessageField)
+	}
+
+	for _, key := range []string{client.SlogIDKey, client.SlogUserIDKey, client.SlogActionKey, client.SlogResourceKey} {
+		if value, ok := fields[key]; ok {
+			fields[key] = fmt.Sprint(value)
+		}
+	}
+	if _, ok := fields[client.SlogActionKey]; !ok && in.Action != "" {
+		fields[client.SlogActionKey] = in.Action
+	}
+
+	record := slog.NewRecord(t, level, client.Truncate(message, client.MaxDescriptionLength), 0)
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		record.AddAttrs(slog.Any(key, fields[key]))
+	}
+	return record
+}
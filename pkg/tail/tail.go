@@ -0,0 +1,251 @@
+// Package tail ships existing application logs to the ledger: it follows local files, or
+// standard input, through rotation and truncation, merges multiline entries such as stack
+// traces, parses each entry with a regular expression or as JSON, and submits it through a
+// client.Submitter, usually a BatchingSubmitter. The position reached in every file is saved
+// to a checkpoint once the entries before it have been submitted, so a restarted agent resumes
+// where it stopped.
+package tail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/isiddharthsingh/fabric-logging-system/pkg/client"
+)
+
+// Agent defaults
+const (
+	DefaultPollInterval       = 250 * time.Millisecond
+	DefaultCheckpointInterval = 5 * time.Second
+	DefaultMultilineMaxLines  = 500
+	DefaultMultilineTimeout   = 2 * time.Second
+)
+
+// Stdin is the Input path reading standard input
+const Stdin = "-"
+
+// Input is a file, or standard input, and how its entries become logs
+type Input struct {
+	// Path is the file followed, or Stdin
+	Path   string
+	Parser Parser
+	// Fields renames parsed fields, such as "msg" to "message" or "user" to "userId"
+	Fields map[string]string
+	// TimeFormat is the layout of the time field; time.RFC3339Nano is used when empty. Entries
+	// without a readable time are stamped with the time they are read.
+	TimeFormat string
+	// Multiline, when set, merges continuation lines into the entry they follow
+	Multiline *Multiline
+	// UserID, Action and Resource are used for entries without such fields. Resource is the
+	// path when empty, and logs without an action are recorded as LOG_<LEVEL>.
+	UserID   string
+	Action   string
+	Resource string
+}
+
+// Multiline merges the lines of an entry spanning several, such as a stack trace
+type Multiline struct {
+	// Start matches the first line of an entry; lines not matching it continue the entry before
+	Start *regexp.Regexp
+	// MaxLines ends an entry after this many lines; DefaultMultilineMaxLines is used otherwise
+	MaxLines int
+	// Timeout ends an entry when no line follows within it; DefaultMultilineTimeout is used otherwise
+	Timeout time.Duration
+}
+
+// Options configures an Agent
+type Options struct {
+	Inputs []Input
+	// Submitter receives the logs
+	Submitter client.Submitter
+	// Flush submits every log handed to Submitter, such as BatchingSubmitter.Flush. Positions
+	// are only checkpointed once the logs before them are flushed.
+	Flush func(ctx context.Context) error
+	// CheckpointPath is the file positions are saved to; positions are not saved when empty
+	CheckpointPath string
+	// CheckpointInterval is how often positions are saved; DefaultCheckpointInterval is used otherwise
+	CheckpointInterval time.Duration
+	// PollInterval is how often files at their end are checked for new lines, rotation and
+	// truncation; DefaultPollInterval is used otherwise
+	PollInterval time.Duration
+	// FromEnd starts files without a checkpointed position at their end instead of their start
+	FromEnd bool
+	// OnError is called with the failures the agent carries on after, such as logs the
+	// Submitter rejected
+	OnError func(error)
+}
+
+// Agent follows its inputs and submits their entries
+type Agent struct {
+	options   Options
+	inputs    []*input
+	followers []*follower
+}
+
+// input is an Input with its defaults applied
+type input struct {
+	Input
+	handler *client.SlogHandler
+}
+
+// NewAgent checks the inputs and returns an Agent
+func NewAgent(options Options) (*Agent, error) {
+	if len(options.Inputs) == 0 {
+		return nil, fmt.Errorf("no inputs configured")
+	}
+	if options.Submitter == nil {
+		return nil, fmt.Errorf("no submitter configured")
+	}
+	if options.CheckpointInterval <= 0 {
+		options.CheckpointInterval = DefaultCheckpointInterval
+	}
+	if options.PollInterval <= 0 {
+		options.PollInterval = DefaultPollInterval
+	}
+
+	a := &Agent{options: options}
+	paths := make(map[string]bool)
+	for _, in := range options.Inputs {
+		if in.Path == "" {
+			return nil, fmt.Errorf("an input has no path")
+		}
+		if paths[in.Path] {
+			return nil, fmt.Errorf("the input %s is defined twice", in.Path)
+		}
+		paths[in.Path] = true
+
+		if in.Parser == nil {
+			in.Parser = TextParser{}
+		}
+		if in.TimeFormat == "" {
+			in.TimeFormat = time.RFC3339Nano
+		}
+		if m := in.Multiline; m != nil {
+			if m.Start == nil {
+				return nil, fmt.Errorf("the multiline settings of input %s have no start pattern", in.Path)
+			}
+			multiline := *m
+			if multiline.MaxLines <= 0 {
+				multiline.MaxLines = DefaultMultilineMaxLines
+			}
+			if multiline.Timeout <= 0 {
+				multiline.Timeout = DefaultMultilineTimeout
+			}
+			in.Multiline = &multiline
+		}
+		if in.Resource == "" {
+			in.Resource = in.Path
+			if in.Path == Stdin {
+				in.Resource = "stdin"
+			}
+		}
+
+		handler := client.NewSlogHandler(options.Submitter, client.SlogHandlerOptions{
+			UserID:   in.UserID,
+			Resource: in.Resource,
+		})
+		a.inputs = append(a.inputs, &input{Input: in, handler: handler})
+	}
+
+	return a, nil
+}
+
+// Run follows the inputs until ctx is done, or until every input has ended, which only
+// standard input does, and saves the positions reached. It returns an error when no input
+// could be followed.
+func (a *Agent) Run(ctx context.Context) error {
+	positions, err := loadPositions(a.options.CheckpointPath)
+	if err != nil {
+		return err
+	}
+
+	a.followers = nil
+	for _, in := range a.inputs {
+		a.followers = append(a.followers, &follower{agent: a, input: in, resume: positions[in.Path]})
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var failures []error
+	done := make(chan struct{})
+	for _, f := range a.followers {
+		wg.Add(1)
+		go func(f *follower) {
+			defer wg.Done()
+			if err := f.run(ctx); err != nil && ctx.Err() == nil {
+				err = fmt.Errorf("stopped following %s: %w", f.input.Path, err)
+				a.report(err)
+				mu.Lock()
+				failures = append(failures, err)
+				mu.Unlock()
+			}
+		}(f)
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	defer func() {
+		for _, f := range a.followers {
+			f.close()
+		}
+	}()
+
+	ticker := time.NewTicker(a.options.CheckpointInterval)
+	defer ticker.Stop()
+	for running := true; running; {
+		select {
+		case <-ctx.Done():
+			running = false
+		case <-done:
+			running = false
+		case <-ticker.C:
+			if err := a.checkpoint(ctx); err != nil && ctx.Err() == nil {
+				a.report(err)
+			}
+		}
+	}
+	<-done
+
+	// The entries read before the end are still flushed and checkpointed
+	final, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+	if err := a.checkpoint(final); err != nil {
+		return err
+	}
+	if len(failures) == len(a.followers) {
+		return errors.Join(failures...)
+	}
+	return nil
+}
+
+// checkpoint flushes the logs read so far and then saves the positions after them
+func (a *Agent) checkpoint(ctx context.Context) error {
+	positions := make(map[string]position)
+	for _, f := range a.followers {
+		if p, ok := f.position(); ok {
+			positions[f.input.Path] = p
+		}
+	}
+
+	if a.options.Flush != nil {
+		if err := a.options.Flush(ctx); err != nil {
+			return fmt.Errorf("failed to flush logs: %w", err)
+		}
+	}
+	if a.options.CheckpointPath == "" {
+		return nil
+	}
+	return savePositions(a.options.CheckpointPath, positions)
+}
+
+func (a *Agent) report(err error) {
+	if a.options.OnError != nil {
+		a.options.OnError(err)
+	}
+}